@@ -0,0 +1,263 @@
+package builder
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"text/template"
+
+	"github.com/devopsfaith/api2html/engine"
+)
+
+const (
+	bundleFolder  = "bundle"
+	enginePackage = "github.com/devopsfaith/api2html"
+)
+
+// Builder defines the interface for packaging an api2html project into a single binary
+type Builder interface {
+	Build() error
+}
+
+// New returns a GoBuilder packaging the project at basePath, described by the config file at
+// cfgPath (relative to the basePath), into the outputPath binary
+func New(basePath, cfgPath, outputPath string) *GoBuilder {
+	return &GoBuilder{
+		BasePath:      basePath,
+		ConfigPath:    cfgPath,
+		OutputPath:    outputPath,
+		EngineVersion: generatorVersion(),
+		Parser:        engine.ParseConfigFromFile,
+		Runner:        runGo,
+	}
+}
+
+// GoBuilder is a Builder copying the config, the templates and the static assets of a project
+// into a temporary go module that embeds them, and compiling it with the go toolchain
+type GoBuilder struct {
+	// BasePath is the root folder of the project
+	BasePath string
+	// ConfigPath is the path of the config file, relative to the BasePath
+	ConfigPath string
+	// OutputPath is the path of the binary to generate
+	OutputPath string
+	// Include contains extra files or folders to embed, relative to the BasePath
+	Include []string
+	// EngineVersion is the version of the api2html module to compile with. By default, it is the
+	// version of the api2html module generating the binary
+	EngineVersion string
+	// EnginePath is an optional local copy of the api2html module to compile with
+	EnginePath string
+	// Parser parses the config file
+	Parser func(string) (engine.Config, error)
+	// Runner executes the go toolchain with the received args in the received folder
+	Runner func(dir string, args ...string) error
+}
+
+// Build implements the Builder interface
+func (b *GoBuilder) Build() error {
+	if b.EnginePath == "" && !pinnedVersion(b.EngineVersion) {
+		return fmt.Errorf("unable to pin the engine version %q: set a released version or the path of the engine to compile with", b.EngineVersion)
+	}
+
+	cfg, err := b.Parser(filepath.Join(b.BasePath, b.ConfigPath))
+	if err != nil {
+		return err
+	}
+
+	output, err := filepath.Abs(b.OutputPath)
+	if err != nil {
+		return err
+	}
+
+	workDir, err := ioutil.TempDir("", "api2html-build")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(workDir)
+
+	for _, name := range b.bundleContents(cfg) {
+		if err := b.copy(name, filepath.Join(workDir, bundleFolder)); err != nil {
+			return err
+		}
+	}
+
+	if err := b.writeModule(workDir); err != nil {
+		return err
+	}
+
+	if err := b.Runner(workDir, "mod", "tidy"); err != nil {
+		return err
+	}
+	if err := b.Runner(workDir, "build", "-o", output, "."); err != nil {
+		return err
+	}
+	log.Println("binary created at", output)
+	return nil
+}
+
+func (b *GoBuilder) bundleContents(cfg engine.Config) []string {
+	contents := []string{b.ConfigPath, "static"}
+	for _, section := range []map[string]string{cfg.Templates, cfg.Layouts} {
+		for _, path := range section {
+			// the whole folder is embedded, so the partials get included too
+			if dir := filepath.Dir(path); dir != "." {
+				contents = append(contents, dir)
+				continue
+			}
+			contents = append(contents, path)
+		}
+	}
+	if cfg.PublicFolder != nil {
+		contents = append(contents, cfg.PublicFolder.Path)
+	}
+	return append(contents, b.Include...)
+}
+
+func (b *GoBuilder) copy(name, target string) error {
+	clean := filepath.Clean(name)
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("unable to embed %s: the path must be inside the project folder", name)
+	}
+	source := filepath.Join(b.BasePath, clean)
+	if _, err := os.Stat(source); os.IsNotExist(err) {
+		log.Println("skipping the missing path", source)
+		return nil
+	}
+
+	return filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(b.BasePath, path)
+		if err != nil {
+			return err
+		}
+		dest := filepath.Join(target, rel)
+		if info.IsDir() {
+			return os.MkdirAll(dest, 0755)
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(dest, data, 0644)
+	})
+}
+
+func (b *GoBuilder) writeModule(workDir string) error {
+	mod := bytes.NewBufferString("module api2html-bundle\n")
+	if b.EnginePath != "" {
+		path, err := filepath.Abs(b.EnginePath)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(mod, "\nrequire %s v0.0.0\n\nreplace %s => %s\n", enginePackage, enginePackage, path)
+	} else {
+		fmt.Fprintf(mod, "\nrequire %s %s\n", enginePackage, b.EngineVersion)
+	}
+	if err := ioutil.WriteFile(filepath.Join(workDir, "go.mod"), mod.Bytes(), 0644); err != nil {
+		return err
+	}
+
+	main := &bytes.Buffer{}
+	if err := mainTmpl.Execute(main, map[string]string{
+		"Bundle": bundleFolder,
+		"Config": filepath.ToSlash(filepath.Clean(b.ConfigPath)),
+	}); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(workDir, "main.go"), main.Bytes(), 0644)
+}
+
+// generatorVersion returns the version of the api2html module the running binary was built
+// with. It is empty for the development builds
+func generatorVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	if info.Main.Path == enginePackage {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == enginePackage {
+			return dep.Version
+		}
+	}
+	return ""
+}
+
+// pinnedVersion checks if the version identifies a single release of the module, so the
+// binaries built from the same project are reproducible
+func pinnedVersion(version string) bool {
+	return strings.HasPrefix(version, "v") && version != "(devel)"
+}
+
+func runGo(dir string, args ...string) error {
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+var mainTmpl = template.Must(template.New("main").Parse(`// Code generated by api2html build. DO NOT EDIT.
+
+package main
+
+import (
+	"embed"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+
+	"github.com/devopsfaith/api2html/engine"
+)
+
+//go:embed {{ .Bundle }}
+var bundle embed.FS
+
+func main() {
+	port := flag.Int("p", 8080, "Listen port")
+	devel := flag.Bool("d", false, "Enable the devel")
+	flag.Parse()
+
+	files, err := fs.Sub(bundle, "{{ .Bundle }}")
+	if err != nil {
+		log.Fatal(err)
+	}
+	f, err := files.Open("{{ .Config }}")
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg, err := engine.ParseConfigFormat(f, engine.ConfigFormat("{{ .Config }}"))
+	f.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	opts := []engine.Option{engine.WithTemplateFS(files)}
+	if *devel {
+		opts = append(opts, engine.WithDevel())
+	}
+	h, err := engine.NewFromConfig(cfg, opts...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer h.(io.Closer).Close()
+	log.Println(http.ListenAndServe(fmt.Sprintf(":%d", *port), h))
+}
+`))
@@ -0,0 +1,163 @@
+package builder
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/devopsfaith/api2html/engine"
+)
+
+func TestGoBuilder_Build(t *testing.T) {
+	project, err := ioutil.TempDir(".", "project")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(project)
+
+	for name, content := range map[string]string{
+		"config.json":              "{}",
+		"tmpl/home.mustache":       "home {{> tmpl/footer}}",
+		"tmpl/footer.mustache":     "footer",
+		"static/404":               "404",
+		"public/js/app.js":         "app",
+		"extra/data.json":          "{}",
+		"not_embedded/secret.json": "{}",
+	} {
+		path := filepath.Join(project, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Error(err)
+			return
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	calls := []string{}
+	b := New(project, "config.json", "my-site")
+	b.Include = []string{"extra"}
+	b.EngineVersion = "v1.2.3"
+	b.Parser = func(path string) (engine.Config, error) {
+		if path != filepath.Join(project, "config.json") {
+			t.Errorf("unexpected config path: %s", path)
+		}
+		return engine.Config{
+			Templates:    map[string]string{"home": "./tmpl/home.mustache"},
+			PublicFolder: &engine.PublicFolder{Path: "./public", Prefix: "/js"},
+		}, nil
+	}
+	b.Runner = func(dir string, args ...string) error {
+		calls = append(calls, strings.Join(args, " "))
+		if len(calls) > 1 {
+			return nil
+		}
+		for _, name := range []string{
+			"main.go",
+			"go.mod",
+			"bundle/config.json",
+			"bundle/tmpl/home.mustache",
+			"bundle/tmpl/footer.mustache",
+			"bundle/static/404",
+			"bundle/public/js/app.js",
+			"bundle/extra/data.json",
+		} {
+			if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+				t.Errorf("the file %s has not been created", name)
+			}
+		}
+		if _, err := os.Stat(filepath.Join(dir, "bundle/not_embedded")); err == nil {
+			t.Error("unexpected embedded folder")
+		}
+		main, _ := ioutil.ReadFile(filepath.Join(dir, "main.go"))
+		if !strings.Contains(string(main), `files.Open("config.json")`) || !strings.Contains(string(main), "engine.WithTemplateFS(files)") {
+			t.Errorf("unexpected main file: %s", string(main))
+		}
+		mod, _ := ioutil.ReadFile(filepath.Join(dir, "go.mod"))
+		if !strings.Contains(string(mod), "require github.com/devopsfaith/api2html v1.2.3") {
+			t.Errorf("unexpected go.mod: %s", string(mod))
+		}
+		return nil
+	}
+
+	if err := b.Build(); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+
+	output, _ := filepath.Abs("my-site")
+	expected := []string{
+		"mod tidy",
+		"build -o " + output + " .",
+	}
+	if strings.Join(calls, ",") != strings.Join(expected, ",") {
+		t.Errorf("unexpected calls: %v", calls)
+	}
+}
+
+func TestGoBuilder_Build_enginePath(t *testing.T) {
+	calls := []string{}
+	b := New(".", "config.json", "my-site")
+	b.EnginePath = "/src/api2html"
+	b.Parser = func(_ string) (engine.Config, error) { return engine.Config{}, nil }
+	b.Runner = func(dir string, args ...string) error {
+		calls = append(calls, strings.Join(args, " "))
+		mod, _ := ioutil.ReadFile(filepath.Join(dir, "go.mod"))
+		if !strings.Contains(string(mod), "replace github.com/devopsfaith/api2html => /src/api2html") {
+			t.Errorf("unexpected go.mod: %s", string(mod))
+		}
+		return nil
+	}
+
+	if err := b.Build(); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if len(calls) != 2 || calls[0] != "mod tidy" {
+		t.Errorf("unexpected calls: %v", calls)
+	}
+}
+
+func TestGoBuilder_Build_koPathOutsideTheProject(t *testing.T) {
+	b := New(".", "config.json", "my-site")
+	b.EngineVersion = "v1.2.3"
+	b.Parser = func(_ string) (engine.Config, error) {
+		return engine.Config{Templates: map[string]string{"a": "../tmpl/a.mustache"}}, nil
+	}
+	b.Runner = func(_ string, _ ...string) error {
+		t.Error("the go toolchain should not be called")
+		return nil
+	}
+	if err := b.Build(); err == nil {
+		t.Error("expecting error")
+	}
+}
+
+func TestGoBuilder_Build_koEngineVersion(t *testing.T) {
+	for _, version := range []string{"", "latest", "(devel)"} {
+		b := New(".", "config.json", "my-site")
+		b.EngineVersion = version
+		b.Parser = func(_ string) (engine.Config, error) {
+			t.Error("the config should not be parsed")
+			return engine.Config{}, nil
+		}
+		if err := b.Build(); err == nil {
+			t.Errorf("%q: expecting error", version)
+		}
+	}
+}
+
+func TestGoBuilder_Build_koParser(t *testing.T) {
+	expectedErr := fmt.Errorf("boooom")
+	b := New(".", "config.json", "my-site")
+	b.EngineVersion = "v1.2.3"
+	b.Parser = func(_ string) (engine.Config, error) { return engine.Config{}, expectedErr }
+	if err := b.Build(); err != expectedErr {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
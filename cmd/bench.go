@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/devopsfaith/api2html/engine"
+	"github.com/spf13/cobra"
+)
+
+var (
+	benchCfgFile     string
+	benchPage        string
+	benchURL         string
+	benchTarget      string
+	benchParams      []string
+	benchRequests    int
+	benchConcurrency int
+
+	benchCmd = &cobra.Command{
+		Use:     "bench",
+		Short:   "Benchmark the rendering of a page.",
+		Long:    "Send a number of requests of a page to in-process handlers created with the config, or to a running instance, and report the latency percentiles of the backend calls, the decoding and the rendering, along with the allocations per request.",
+		RunE:    benchWrapper{defaultBenchmarkFactory}.Bench,
+		Aliases: []string{"benchmark"},
+		Example: "api2html bench -c config.json --page product --param id=42 --concurrency 50",
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(benchCmd)
+
+	benchCmd.PersistentFlags().StringVarP(&benchCfgFile, "config", "c", "api2html.conf", "Path to the configuration filename")
+	benchCmd.PersistentFlags().StringVar(&benchPage, "page", "", "Name of the page to request")
+	benchCmd.PersistentFlags().StringSliceVar(&benchParams, "param", []string{}, "(comma-separated) params of the URL pattern of the page, as key=value")
+	benchCmd.PersistentFlags().StringVarP(&benchURL, "url", "u", "", "Path to request instead of the URL pattern of the page")
+	benchCmd.PersistentFlags().StringVarP(&benchTarget, "target", "t", "", "Base URL of a running instance to request instead of the in-process handlers")
+	benchCmd.PersistentFlags().IntVarP(&benchRequests, "requests", "n", 1000, "Number of requests")
+	benchCmd.PersistentFlags().IntVar(&benchConcurrency, "concurrency", 10, "Number of requests in flight at the same time")
+}
+
+type benchmarkRunner interface {
+	Run() (engine.BenchReport, error)
+}
+
+type benchmarkFactory func(cfgPath, path, target string) (benchmarkRunner, error)
+
+func defaultBenchmarkFactory(cfgPath, path, target string) (benchmarkRunner, error) {
+	var b *engine.Benchmark
+	if target != "" {
+		b = engine.NewRemoteBenchmark(target, path)
+	} else {
+		var err error
+		if b, err = engine.DefaultFactory.NewBenchmark(cfgPath, path); err != nil {
+			return nil, err
+		}
+	}
+	b.Requests = benchRequests
+	b.Concurrency = benchConcurrency
+	return b, nil
+}
+
+type benchWrapper struct {
+	bf benchmarkFactory
+}
+
+func (b benchWrapper) Bench(cmd *cobra.Command, _ []string) error {
+	report, err := b.bench()
+	if err != nil {
+		log.Println("bench aborted:", err.Error())
+		return err
+	}
+	var w io.Writer = os.Stdout
+	if cmd != nil {
+		w = cmd.OutOrStdout()
+	}
+	fmt.Fprint(w, report.String())
+	return nil
+}
+
+func (b benchWrapper) bench() (engine.BenchReport, error) {
+	path, err := benchPath()
+	if err != nil {
+		return engine.BenchReport{}, err
+	}
+	runner, err := b.bf(benchCfgFile, path, benchTarget)
+	if err != nil {
+		return engine.BenchReport{}, err
+	}
+	return runner.Run()
+}
+
+// benchPath returns the path of the url flag or the URL pattern of the page with its params
+// replaced
+func benchPath() (string, error) {
+	if benchURL != "" {
+		return benchURL, nil
+	}
+	if benchPage == "" {
+		return "", fmt.Errorf("either the page or the url is required")
+	}
+	cfg, err := engine.ParseConfigFromFile(benchCfgFile)
+	if err != nil {
+		return "", err
+	}
+	for _, page := range cfg.Pages {
+		if page.Name != benchPage {
+			continue
+		}
+		route, err := engine.ParseRoutePattern(page.URLPattern)
+		if err != nil {
+			return "", err
+		}
+		segments := strings.Split(route.Path, "/")
+		params := map[string]string{}
+		for _, p := range benchParams {
+			kv := strings.SplitN(p, "=", 2)
+			if len(kv) != 2 {
+				return "", fmt.Errorf("invalid param: %q", p)
+			}
+			params[kv[0]] = kv[1]
+		}
+		for i, segment := range segments {
+			if !strings.HasPrefix(segment, ":") && !strings.HasPrefix(segment, "*") {
+				continue
+			}
+			v, ok := params[segment[1:]]
+			if !ok {
+				return "", fmt.Errorf("missing the param %s of the URL pattern %s", segment[1:], page.URLPattern)
+			}
+			segments[i] = v
+		}
+		return strings.Join(segments, "/"), nil
+	}
+	return "", fmt.Errorf("unknown page: %s", benchPage)
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/devopsfaith/api2html/engine"
+)
+
+func Test_benchWrapper(t *testing.T) {
+	benchCfgFile = "config1.json"
+	benchURL = "/products/42"
+	benchTarget = "http://localhost:8080"
+	defer func() { benchURL, benchTarget = "", "" }()
+
+	subject := benchWrapper{func(cfg, path, target string) (benchmarkRunner, error) {
+		if cfg != benchCfgFile || path != benchURL || target != benchTarget {
+			return nil, fmt.Errorf("unexpected args: %s, %s, %s", cfg, path, target)
+		}
+		return fakeBenchmark{}, nil
+	}}
+	if err := subject.Bench(nil, []string{}); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+
+	expectedError := fmt.Errorf("expect me")
+	subject = benchWrapper{func(_, _, _ string) (benchmarkRunner, error) {
+		return fakeBenchmark{expectedError}, nil
+	}}
+	if err := subject.Bench(nil, []string{}); err != expectedError {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func Test_benchPath(t *testing.T) {
+	benchCfgFile = filepath.Join(t.TempDir(), "config.json")
+	defer func() { benchPage, benchParams = "", []string{} }()
+	cfg := `{"pages": [{"name": "product", "URLPattern": "/products/:category/:id"}]}`
+	if err := ioutil.WriteFile(benchCfgFile, []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	benchPage = "product"
+	benchParams = []string{"category=shoes", "id=42"}
+	if path, err := benchPath(); err != nil || path != "/products/shoes/42" {
+		t.Errorf("unexpected path: %s %v", path, err)
+	}
+
+	for _, tc := range []struct {
+		page   string
+		params []string
+	}{
+		{"", []string{}},
+		{"unknown", []string{}},
+		{"product", []string{"category=shoes"}},
+		{"product", []string{"category"}},
+	} {
+		benchPage, benchParams = tc.page, tc.params
+		if _, err := benchPath(); err == nil {
+			t.Errorf("%s %v: expecting an error", tc.page, tc.params)
+		}
+	}
+}
+
+type fakeBenchmark struct {
+	err error
+}
+
+func (f fakeBenchmark) Run() (engine.BenchReport, error) {
+	return engine.BenchReport{Requests: 1, Duration: 1}, f.err
+}
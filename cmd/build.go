@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"github.com/devopsfaith/api2html/builder"
+	"github.com/spf13/cobra"
+)
+
+var (
+	buildBasePath      string
+	buildCfgFile       string
+	buildOutput        string
+	buildEngineVersion string
+	buildEnginePath    string
+	buildInclude       []string
+
+	buildCmd = &cobra.Command{
+		Use:     "build",
+		Short:   "Package the api2html site into a single binary.",
+		Long:    "Package the config, the templates and the static assets of the api2html site into a single binary.",
+		RunE:    builderWrapper{defaultBuilderFactory}.Build,
+		Aliases: []string{"package"},
+		Example: "api2html build -c config.json -o my-site",
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(buildCmd)
+
+	buildCmd.PersistentFlags().StringVarP(&buildBasePath, "path", "p", os.Getenv("PWD"), "Base path of the site to package")
+	buildCmd.PersistentFlags().StringVarP(&buildCfgFile, "config", "c", "config.json", "Path to the configuration filename, relative to the base path")
+	buildCmd.PersistentFlags().StringVarP(&buildOutput, "output", "o", "api2html-site", "Path of the generated binary")
+	buildCmd.PersistentFlags().StringSliceVarP(&buildInclude, "include", "i", []string{}, "(comma-separated) extra files or folders to embed")
+	buildCmd.PersistentFlags().StringVar(&buildEngineVersion, "engine-version", "", "Version of the api2html engine to compile with (default the version of this binary)")
+	buildCmd.PersistentFlags().StringVar(&buildEnginePath, "engine-path", "", "Path to a local copy of the api2html engine to compile with")
+}
+
+type builderFactory func(basePath, cfgPath, outputPath string) builder.Builder
+
+func defaultBuilderFactory(basePath, cfgPath, outputPath string) builder.Builder {
+	b := builder.New(basePath, cfgPath, outputPath)
+	b.Include = buildInclude
+	if buildEngineVersion != "" {
+		b.EngineVersion = buildEngineVersion
+	}
+	b.EnginePath = buildEnginePath
+	return b
+}
+
+type builderWrapper struct {
+	bf builderFactory
+}
+
+func (b builderWrapper) Build(_ *cobra.Command, _ []string) error {
+	start := time.Now()
+
+	if err := b.bf(buildBasePath, buildCfgFile, buildOutput).Build(); err != nil {
+		log.Println("build aborted:", err.Error())
+		return err
+	}
+
+	log.Println("binary built! time:", time.Since(start))
+	return nil
+}
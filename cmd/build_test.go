@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/devopsfaith/api2html/builder"
+)
+
+func Test_defaultBuilderFactory(t *testing.T) {
+	b := defaultBuilderFactory(".", "config.json", "output")
+	switch b.(type) {
+	case *builder.GoBuilder:
+	default:
+		t.Errorf("unexpected builder type: %T", b)
+	}
+}
+
+func Test_builderWrapper_koErroredBuilder(t *testing.T) {
+	expectedError := fmt.Errorf("expect me")
+
+	subject := builderWrapper{func(_, _, _ string) builder.Builder {
+		return erroredBuilder{expectedError}
+	}}
+
+	if err := subject.Build(nil, []string{}); err == nil {
+		t.Error("expecting error!")
+	} else if err != expectedError {
+		t.Errorf("unexpected error! want: %s, got: %s", expectedError.Error(), err.Error())
+	}
+}
+
+func Test_builderWrapper(t *testing.T) {
+	buildBasePath = "basepath1"
+	buildCfgFile = "config1.json"
+	buildOutput = "output1"
+
+	subject := builderWrapper{func(bp, cfg, out string) builder.Builder {
+		if bp != buildBasePath || cfg != buildCfgFile || out != buildOutput {
+			return erroredBuilder{fmt.Errorf("unexpected args: %s, %s, %s", bp, cfg, out)}
+		}
+		return erroredBuilder{}
+	}}
+
+	if err := subject.Build(nil, []string{}); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+}
+
+type erroredBuilder struct {
+	err error
+}
+
+func (e erroredBuilder) Build() error {
+	return e.err
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/devopsfaith/api2html/engine"
+	"github.com/spf13/cobra"
+)
+
+var (
+	checkCmd = &cobra.Command{
+		Use:     "check [config]",
+		Short:   "Validate the api2html configuration.",
+		Long:    "Validate the configuration, its templates and layouts, the URL patterns, the durations and the params of the backend URL patterns without starting the server.",
+		RunE:    checkWrapper{defaultConfigChecker}.Check,
+		Args:    cobra.MaximumNArgs(1),
+		Aliases: []string{"validate", "lint"},
+		Example: "api2html check config.json",
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+}
+
+type configChecker func(cfgPath string) []error
+
+func defaultConfigChecker(cfgPath string) []error {
+	cfg, err := engine.ParseConfigFromFile(cfgPath)
+	if err != nil {
+		return []error{fmt.Errorf("parsing %s: %s", cfgPath, err.Error())}
+	}
+	return engine.CheckConfig(nil, cfg)
+}
+
+type checkWrapper struct {
+	cc configChecker
+}
+
+func (c checkWrapper) Check(cmd *cobra.Command, args []string) error {
+	cfgPath := "api2html.conf"
+	if len(args) > 0 {
+		cfgPath = args[0]
+	}
+	var w io.Writer = os.Stdout
+	if cmd != nil {
+		w = cmd.OutOrStdout()
+	}
+
+	errs := c.cc(cfgPath)
+	for _, err := range errs {
+		fmt.Fprintln(w, err.Error())
+	}
+	if len(errs) > 0 {
+		err := fmt.Errorf("%d problems found in %s", len(errs), cfgPath)
+		log.Println("check aborted:", err.Error())
+		return err
+	}
+
+	fmt.Fprintln(w, cfgPath, "is valid")
+	return nil
+}
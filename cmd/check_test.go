@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"fmt"
+	"testing"
+)
+
+func Test_checkWrapper(t *testing.T) {
+	var checked string
+	subject := checkWrapper{func(cfg string) []error {
+		checked = cfg
+		return nil
+	}}
+
+	if err := subject.Check(nil, []string{}); err != nil || checked != "api2html.conf" {
+		t.Errorf("unexpected result: %s %v", checked, err)
+	}
+	if err := subject.Check(nil, []string{"config1.json"}); err != nil || checked != "config1.json" {
+		t.Errorf("unexpected result: %s %v", checked, err)
+	}
+}
+
+func Test_checkWrapper_ko(t *testing.T) {
+	subject := checkWrapper{func(_ string) []error {
+		return []error{fmt.Errorf("page a: unknown layout"), fmt.Errorf("page b: invalid budget")}
+	}}
+
+	err := subject.Check(nil, []string{"config1.json"})
+	if err == nil || err.Error() != "2 problems found in config1.json" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
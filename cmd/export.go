@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"log"
+	"time"
+
+	"github.com/devopsfaith/api2html/engine"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportCfgFile     string
+	exportOutput      string
+	exportURLs        []string
+	exportConcurrency int
+
+	exportCmd = &cobra.Command{
+		Use:     "export",
+		Short:   "Render the api2html pages to static HTML files.",
+		Long:    "Render a list of URLs, or the pages without params and the URLs listed by the sitemap sources of the pages, to static HTML files ready to be hosted by a CDN.",
+		RunE:    exportWrapper{defaultExporterFactory}.Export,
+		Aliases: []string{"static"},
+		Example: "api2html export -c config.json -o dist",
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+
+	exportCmd.PersistentFlags().StringVarP(&exportCfgFile, "config", "c", "api2html.conf", "Path to the configuration filename")
+	exportCmd.PersistentFlags().StringVarP(&exportOutput, "output", "o", "dist", "Folder of the generated files")
+	exportCmd.PersistentFlags().StringSliceVarP(&exportURLs, "url", "u", []string{}, "(comma-separated) paths to render instead of the ones listed by the pages")
+	exportCmd.PersistentFlags().IntVar(&exportConcurrency, "concurrency", 4, "Number of paths rendered at the same time")
+}
+
+type staticExporter interface {
+	URLs() ([]string, error)
+	Export([]string) error
+	Close() error
+}
+
+type exporterFactory func(cfgPath, output string) (staticExporter, error)
+
+func defaultExporterFactory(cfgPath, output string) (staticExporter, error) {
+	e, err := engine.DefaultFactory.NewStaticExporter(cfgPath, output)
+	if err != nil {
+		return nil, err
+	}
+	e.Concurrency = exportConcurrency
+	return e, nil
+}
+
+type exportWrapper struct {
+	ef exporterFactory
+}
+
+func (e exportWrapper) Export(_ *cobra.Command, _ []string) error {
+	start := time.Now()
+
+	if err := e.export(); err != nil {
+		log.Println("export aborted:", err.Error())
+		return err
+	}
+
+	log.Println("site exported! time:", time.Since(start))
+	return nil
+}
+
+func (e exportWrapper) export() error {
+	exporter, err := e.ef(exportCfgFile, exportOutput)
+	if err != nil {
+		return err
+	}
+	defer exporter.Close()
+	urls := exportURLs
+	if len(urls) == 0 {
+		if urls, err = exporter.URLs(); err != nil {
+			return err
+		}
+	}
+	return exporter.Export(urls)
+}
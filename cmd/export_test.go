@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+	"testing"
+)
+
+func Test_exportWrapper(t *testing.T) {
+	exportCfgFile = "config1.json"
+	exportOutput = "output1"
+	defer func() { exportURLs = []string{} }()
+
+	exporter := &fakeExporter{urls: []string{"/", "/about"}}
+	subject := exportWrapper{func(cfg, output string) (staticExporter, error) {
+		if cfg != exportCfgFile || output != exportOutput {
+			return nil, fmt.Errorf("unexpected args: %s, %s", cfg, output)
+		}
+		return exporter, nil
+	}}
+
+	if err := subject.Export(nil, []string{}); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+	if fmt.Sprint(exporter.exported) != "[/ /about]" {
+		t.Errorf("unexpected paths: %v", exporter.exported)
+	}
+	if !exporter.closed {
+		t.Error("the exporter was not closed")
+	}
+
+	exportURLs = []string{"/products/42"}
+	if err := subject.Export(nil, []string{}); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+	if fmt.Sprint(exporter.exported) != "[/products/42]" {
+		t.Errorf("unexpected paths: %v", exporter.exported)
+	}
+}
+
+func Test_exportWrapper_ko(t *testing.T) {
+	expectedError := fmt.Errorf("expect me")
+	exporter := &fakeExporter{err: expectedError}
+	for _, subject := range []exportWrapper{
+		{func(_, _ string) (staticExporter, error) { return nil, expectedError }},
+		{func(_, _ string) (staticExporter, error) { return exporter, nil }},
+	} {
+		if err := subject.Export(nil, []string{}); err != expectedError {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if !exporter.closed {
+		t.Error("the failed exporter was not closed")
+	}
+}
+
+type fakeExporter struct {
+	urls     []string
+	exported []string
+	err      error
+	closed   bool
+}
+
+func (f *fakeExporter) URLs() ([]string, error) {
+	return f.urls, f.err
+}
+
+func (f *fakeExporter) Export(urls []string) error {
+	f.exported = urls
+	return nil
+}
+
+func (f *fakeExporter) Close() error {
+	f.closed = true
+	return nil
+}
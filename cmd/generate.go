@@ -20,7 +20,7 @@ var (
 		Short:   "Generate the final api2html templates.",
 		Long:    "Generate the final api2html templates.",
 		RunE:    generatorWrapper{defaultGeneratorFactory}.Generate,
-		Aliases: []string{"create", "new"},
+		Aliases: []string{"create"},
 		Example: "api2html generate -i en_US -r partial",
 	}
 
@@ -153,8 +153,8 @@ func Test_generatorWatchWrapper_koErroredGeneratorAfterChange(t *testing.T) {
 	}}}
 
 	wg := &sync.WaitGroup{}
+	wg.Add(1)
 	go func() {
-		wg.Add(1)
 		if werr := subject.Watch(nil, []string{}); werr == nil {
 			t.Error("expecting error!")
 		} else if werr != expectedError {
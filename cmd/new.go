@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/devopsfaith/api2html/skeleton"
+	"github.com/spf13/cobra"
+)
+
+var (
+	newCfgFile string
+	newLayout  string
+
+	newCmd = &cobra.Command{
+		Use:     "new",
+		Short:   "Scaffold api2html sites and pages.",
+		Example: "api2html new site my-site",
+	}
+
+	newSiteCmd = &cobra.Command{
+		Use:     "site [path]",
+		Short:   "Create a site with a home page, its template, layout and fixture.",
+		RunE:    newSiteWrapper{defaultSiteSkelFactory}.Create,
+		Args:    cobra.MaximumNArgs(1),
+		Example: "api2html new site my-site",
+	}
+
+	newPageCmd = &cobra.Command{
+		Use:     "page <name>",
+		Short:   "Add a page, its template and fixture to the config.",
+		RunE:    newPageWrapper{defaultPageSkelFactory}.Create,
+		Args:    cobra.ExactArgs(1),
+		Example: "api2html new page product -c config.json",
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(newCmd)
+	newCmd.AddCommand(newSiteCmd)
+	newCmd.AddCommand(newPageCmd)
+
+	newPageCmd.PersistentFlags().StringVarP(&newCfgFile, "config", "c", "config.json", "Path to the JSON configuration filename")
+	newPageCmd.PersistentFlags().StringVarP(&newLayout, "layout", "l", "main", "Layout of the page, if declared in the config")
+}
+
+func defaultSiteSkelFactory(outputPath string) skeleton.Skel {
+	return skeleton.NewSite(outputPath)
+}
+
+type newSiteWrapper struct {
+	sk skelFactory
+}
+
+func (n newSiteWrapper) Create(_ *cobra.Command, args []string) error {
+	path := "."
+	if len(args) > 0 {
+		path = args[0]
+	}
+	if err := n.sk(path).Create(); err != nil {
+		log.Println("site creation aborted:", err.Error())
+		return err
+	}
+	return nil
+}
+
+type pageSkelFactory func(cfgPath, name, layout string) skeleton.Skel
+
+func defaultPageSkelFactory(cfgPath, name, layout string) skeleton.Skel {
+	return skeleton.NewPage(cfgPath, name, layout)
+}
+
+type newPageWrapper struct {
+	sk pageSkelFactory
+}
+
+func (n newPageWrapper) Create(_ *cobra.Command, args []string) error {
+	if err := n.sk(newCfgFile, args[0], newLayout).Create(); err != nil {
+		log.Println("page creation aborted:", err.Error())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/devopsfaith/api2html/skeleton"
+)
+
+func Test_newSiteWrapper(t *testing.T) {
+	var created string
+	subject := newSiteWrapper{func(path string) skeleton.Skel {
+		created = path
+		return erroredSkel{}
+	}}
+
+	if err := subject.Create(nil, []string{}); err != nil || created != "." {
+		t.Errorf("unexpected result: %s %v", created, err)
+	}
+	if err := subject.Create(nil, []string{"my-site"}); err != nil || created != "my-site" {
+		t.Errorf("unexpected result: %s %v", created, err)
+	}
+}
+
+func Test_newPageWrapper(t *testing.T) {
+	newCfgFile = "config1.json"
+	newLayout = "layout1"
+	expectedError := fmt.Errorf("expect me")
+
+	subject := newPageWrapper{func(cfg, name, layout string) skeleton.Skel {
+		if cfg != newCfgFile || name != "product" || layout != newLayout {
+			return erroredSkel{fmt.Errorf("unexpected args: %s, %s, %s", cfg, name, layout)}
+		}
+		return erroredSkel{}
+	}}
+	if err := subject.Create(nil, []string{"product"}); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+
+	subject = newPageWrapper{func(_, _, _ string) skeleton.Skel {
+		return erroredSkel{expectedError}
+	}}
+	if err := subject.Create(nil, []string{"product"}); err != expectedError {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
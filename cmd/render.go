@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/devopsfaith/api2html/engine"
+	"github.com/spf13/cobra"
+)
+
+var (
+	renderCfgFile string
+	renderPage    string
+	renderData    string
+	renderOutput  string
+	renderParams  []string
+
+	renderCmd = &cobra.Command{
+		Use:     "render",
+		Short:   "Render a page with a local JSON fixture.",
+		Long:    "Render the template and the layout of a page with a local JSON fixture as the backend response and print the HTML, without requesting the backend.",
+		RunE:    renderWrapper{defaultPageRenderer}.Render,
+		Example: "api2html render -c config.json --page home -d payload.json",
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(renderCmd)
+
+	renderCmd.PersistentFlags().StringVarP(&renderCfgFile, "config", "c", "api2html.conf", "Path to the configuration filename")
+	renderCmd.PersistentFlags().StringVar(&renderPage, "page", "", "Name of the page to render")
+	renderCmd.PersistentFlags().StringVarP(&renderData, "data", "d", "", "Path to the JSON fixture with the backend response")
+	renderCmd.PersistentFlags().StringVarP(&renderOutput, "output", "o", "", "Path of the rendered HTML (stdout if empty)")
+	renderCmd.PersistentFlags().StringSliceVar(&renderParams, "param", []string{}, "(comma-separated) params of the request, as key=value")
+}
+
+type pageRenderer func(w io.Writer, cfgPath, page string, data []byte, params map[string]string) error
+
+func defaultPageRenderer(w io.Writer, cfgPath, page string, data []byte, params map[string]string) error {
+	cfg, err := engine.ParseConfigFromFile(cfgPath)
+	if err != nil {
+		return err
+	}
+	return engine.RenderPage(w, cfg, page, data, params)
+}
+
+type renderWrapper struct {
+	pr pageRenderer
+}
+
+func (r renderWrapper) Render(cmd *cobra.Command, _ []string) error {
+	if err := r.render(cmd); err != nil {
+		log.Println("render aborted:", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (r renderWrapper) render(cmd *cobra.Command) error {
+	if renderPage == "" {
+		return fmt.Errorf("the page is required")
+	}
+	params := make(map[string]string, len(renderParams))
+	for _, p := range renderParams {
+		kv := strings.SplitN(p, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("invalid param: %q", p)
+		}
+		params[kv[0]] = kv[1]
+	}
+
+	data := []byte("{}")
+	if renderData != "" {
+		b, err := ioutil.ReadFile(renderData)
+		if err != nil {
+			return err
+		}
+		data = b
+	}
+
+	var w io.Writer = os.Stdout
+	if cmd != nil {
+		w = cmd.OutOrStdout()
+	}
+	if renderOutput != "" {
+		f, err := os.Create(renderOutput)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return r.pr(w, renderCfgFile, renderPage, data, params)
+}
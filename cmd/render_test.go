@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func Test_renderWrapper(t *testing.T) {
+	dir := t.TempDir()
+	renderCfgFile = "config1.json"
+	renderPage = "home"
+	renderData = filepath.Join(dir, "payload.json")
+	renderOutput = filepath.Join(dir, "home.html")
+	renderParams = []string{"id=42", "q=a=b"}
+	defer func() { renderPage, renderData, renderOutput, renderParams = "", "", "", []string{} }()
+
+	if err := ioutil.WriteFile(renderData, []byte(`{"a":1}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	subject := renderWrapper{func(w io.Writer, cfg, page string, data []byte, params map[string]string) error {
+		if cfg != renderCfgFile || page != renderPage || string(data) != `{"a":1}` {
+			return fmt.Errorf("unexpected args: %s, %s, %s", cfg, page, data)
+		}
+		if len(params) != 2 || params["id"] != "42" || params["q"] != "a=b" {
+			return fmt.Errorf("unexpected params: %v", params)
+		}
+		_, err := w.Write([]byte("<p>1</p>"))
+		return err
+	}}
+
+	if err := subject.Render(nil, []string{}); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if b, err := ioutil.ReadFile(renderOutput); err != nil || string(b) != "<p>1</p>" {
+		t.Errorf("unexpected output: %s %v", b, err)
+	}
+}
+
+func Test_renderWrapper_ko(t *testing.T) {
+	expectedError := fmt.Errorf("expect me")
+	subject := renderWrapper{func(_ io.Writer, _, _ string, _ []byte, _ map[string]string) error {
+		return expectedError
+	}}
+	defer func() { renderPage, renderParams = "", []string{} }()
+
+	renderPage = ""
+	if err := subject.Render(nil, []string{}); err == nil {
+		t.Error("expecting an error without page")
+	}
+
+	renderPage = "home"
+	renderParams = []string{"id"}
+	if err := subject.Render(nil, []string{}); err == nil {
+		t.Error("expecting an error with an invalid param")
+	}
+
+	renderParams = []string{}
+	if err := subject.Render(nil, []string{}); err != expectedError {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
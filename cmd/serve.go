@@ -10,9 +10,11 @@ import (
 )
 
 var (
-	cfgFile string
-	devel   bool
-	port    int
+	cfgFile      string
+	devel        bool
+	port         int
+	watch        bool
+	pollInterval time.Duration
 
 	serveCmd = &cobra.Command{
 		Use:     "serve",
@@ -29,9 +31,11 @@ var (
 func init() {
 	rootCmd.AddCommand(serveCmd)
 
-	serveCmd.PersistentFlags().StringVarP(&cfgFile, "config", "c", "api2html.conf", "Path to the configuration filename")
+	serveCmd.PersistentFlags().StringVarP(&cfgFile, "config", "c", "api2html.conf", "Path to the configuration filename or URL of the remote configuration")
 	serveCmd.PersistentFlags().BoolVarP(&devel, "devel", "d", false, "Enable the devel")
 	serveCmd.PersistentFlags().IntVarP(&port, "port", "p", 8080, "Listen port")
+	serveCmd.PersistentFlags().BoolVarP(&watch, "watch", "w", false, "Reload the config and the templates when they change")
+	serveCmd.PersistentFlags().DurationVar(&pollInterval, "poll-interval", 30*time.Second, "Time between polls of the remote configuration when watching it")
 }
 
 type engineWrapper interface {
@@ -41,7 +45,25 @@ type engineWrapper interface {
 type engineFactory func(cfgPath string, devel bool) (engineWrapper, error)
 
 func defaultEngineFactory(cfgPath string, devel bool) (engineWrapper, error) {
-	return engine.New(cfgPath, devel)
+	r := engine.NewReloader(engine.DefaultFactory, cfgPath, devel)
+	r.RemoteInterval = pollInterval
+	if watch {
+		return r, nil
+	}
+	if err := r.Load(); err != nil {
+		return nil, err
+	}
+	return signalReloader{r}, nil
+}
+
+// signalReloader serves an engine loaded on start, reloading it only on SIGHUP
+type signalReloader struct {
+	*engine.Reloader
+}
+
+// Run implements the engineWrapper interface
+func (s signalReloader) Run(addr ...string) error {
+	return s.Serve(addr...)
 }
 
 type serveWrapper struct {
@@ -8,7 +8,6 @@ import (
 	"testing"
 
 	"github.com/devopsfaith/api2html/engine"
-	"github.com/gin-gonic/gin"
 )
 
 func Test_defaultEngineFactory(t *testing.T) {
@@ -31,8 +30,31 @@ func Test_defaultEngineFactory(t *testing.T) {
 		t.Errorf("getting the default engine: %s", err.Error())
 		return
 	}
+	switch g := g.(type) {
+	case signalReloader:
+		if !g.Ready() {
+			t.Error("the engine is not loaded")
+		}
+	default:
+		t.Errorf("unexpected engine type: %T", g)
+	}
+
+	if _, err := defaultEngineFactory("unknown.json", false); err == nil {
+		t.Error("expecting error")
+	}
+}
+
+func Test_defaultEngineFactory_watch(t *testing.T) {
+	watch = true
+	defer func() { watch = false }()
+
+	g, err := defaultEngineFactory("unknown.json", false)
+	if err != nil {
+		t.Errorf("getting the default engine: %s", err.Error())
+		return
+	}
 	switch g.(type) {
-	case *gin.Engine:
+	case *engine.Reloader:
 	default:
 		t.Errorf("unexpected engine type: %T", g)
 	}
@@ -0,0 +1,223 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"log/syslog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gregjones/httpcache"
+	"github.com/rs/zerolog"
+)
+
+const (
+	accessLogKey = "api2html_access_log"
+
+	// StdoutLogSink writes the records to the standard output
+	StdoutLogSink = "stdout"
+	// StderrLogSink writes the records to the standard error
+	StderrLogSink = "stderr"
+	// FileLogSink appends the records to a file
+	FileLogSink = "file"
+	// SyslogLogSink sends the records to the local or a remote syslog daemon
+	SyslogLogSink = "syslog"
+
+	defaultSyslogTag = "api2html"
+)
+
+// NewAccessLogInstrumentation returns the gin middleware writing a JSON access record for every
+// request to the sinks of the received config. If the config enables the app logs, the messages
+// of the standard logger are written to the sinks as JSON records too
+func NewAccessLogInstrumentation(cfg AccessLog) (Instrumentation, error) {
+	w, err := NewLogSinks(cfg.Sinks)
+	if err != nil {
+		return Instrumentation{}, err
+	}
+	logger := zerolog.New(w).With().Timestamp().Logger()
+	if cfg.AppLogs {
+		log.SetFlags(0)
+		log.SetOutput(logger.With().Str("type", "app").Logger())
+	}
+	a := NewAccessLogger(logger.With().Str("type", "access").Logger())
+	return Instrumentation{Middlewares: []gin.HandlerFunc{a.HandlerFunc()}}, nil
+}
+
+// NewLogSinks returns a writer fanning out the records to all the received sinks. If there are
+// no sinks, the records are written to the standard output
+func NewLogSinks(sinks []LogSink) (io.Writer, error) {
+	if len(sinks) == 0 {
+		return os.Stdout, nil
+	}
+	writers := make([]io.Writer, len(sinks))
+	for i, sink := range sinks {
+		w, err := newLogSink(sink)
+		if err != nil {
+			return nil, err
+		}
+		writers[i] = w
+	}
+	if len(writers) == 1 {
+		return writers[0], nil
+	}
+	return io.MultiWriter(writers...), nil
+}
+
+func newLogSink(sink LogSink) (io.Writer, error) {
+	switch sink.Type {
+	case StdoutLogSink, "":
+		return os.Stdout, nil
+	case StderrLogSink:
+		return os.Stderr, nil
+	case FileLogSink:
+		if sink.Path == "" {
+			return nil, fmt.Errorf("the file log sink requires a path")
+		}
+		return os.OpenFile(sink.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	case SyslogLogSink:
+		tag := sink.Tag
+		if tag == "" {
+			tag = defaultSyslogTag
+		}
+		return syslog.Dial(sink.Network, sink.Address, syslog.LOG_INFO|syslog.LOG_LOCAL0, tag)
+	}
+	return nil, fmt.Errorf("unknown log sink: %s", sink.Type)
+}
+
+// NewAccessLogger creates an AccessLogger writing the records with the received logger
+func NewAccessLogger(logger zerolog.Logger) *AccessLogger {
+	return &AccessLogger{Logger: logger}
+}
+
+// AccessLogger is a Hooks implementation collecting the lifecycle of every request and writing
+// it as a single structured record once the request is completed
+type AccessLogger struct {
+	NoopHooks
+	Logger zerolog.Logger
+}
+
+// accessLogEntry collects the info of a request. The backends of a page are requested
+// concurrently, so it is guarded by a mutex
+type accessLogEntry struct {
+	mutex         sync.Mutex
+	page          string
+	backendStart  time.Time
+	backendEnd    time.Time
+	backendStatus int
+	backendError  string
+	cache         string
+	render        time.Duration
+}
+
+// HandlerFunc returns a gin middleware attaching the hooks to the request and writing its record
+// once it is completed
+func (a *AccessLogger) HandlerFunc() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		entry := &accessLogEntry{}
+		c.Set(accessLogKey, entry)
+		addHooks(c, a)
+		c.Next()
+		a.write(c, entry, time.Since(start))
+	}
+}
+
+func (a *AccessLogger) write(c *gin.Context, entry *accessLogEntry, latency time.Duration) {
+	entry.mutex.Lock()
+	defer entry.mutex.Unlock()
+
+	record := a.Logger.Info().
+		Str("method", c.Request.Method).
+		Str("path", c.Request.URL.Path).
+		Int("status", c.Writer.Status()).
+		Int("size", c.Writer.Size()).
+		Str("ip", c.ClientIP()).
+		Str("user_agent", c.Request.UserAgent()).
+		Dur("latency", latency)
+	if entry.page != "" {
+		record = record.Str("page", entry.page)
+	}
+	if !entry.backendStart.IsZero() {
+		record = record.Dur("backend_latency", entry.backendEnd.Sub(entry.backendStart)).
+			Str("cache", entry.cache)
+	}
+	if entry.backendStatus != 0 {
+		record = record.Int("upstream_status", entry.backendStatus)
+	}
+	if entry.backendError != "" {
+		record = record.Str("upstream_error", entry.backendError)
+	}
+	if entry.render > 0 {
+		record = record.Dur("render_latency", entry.render)
+	}
+	record.Send()
+}
+
+// OnRequest implements the Hooks interface by recording the page name
+func (a *AccessLogger) OnRequest(c *gin.Context, name string) {
+	if entry := accessLogEntryFromContext(c); entry != nil {
+		entry.mutex.Lock()
+		entry.page = name
+		entry.mutex.Unlock()
+	}
+}
+
+// OnBackendCall implements the Hooks interface by recording the time between the first backend
+// call and the last backend response, the upstream status and the result of the backend cache.
+// If the page has several backends, the last failed one is recorded
+func (a *AccessLogger) OnBackendCall(c *gin.Context, _ *http.Request) func(*http.Response, error) {
+	entry := accessLogEntryFromContext(c)
+	if entry == nil {
+		return func(_ *http.Response, _ error) {}
+	}
+	entry.mutex.Lock()
+	if entry.backendStart.IsZero() {
+		entry.backendStart = time.Now()
+	}
+	entry.mutex.Unlock()
+
+	return func(resp *http.Response, err error) {
+		entry.mutex.Lock()
+		defer entry.mutex.Unlock()
+		entry.backendEnd = time.Now()
+		if err != nil {
+			entry.backendError = err.Error()
+			return
+		}
+		if entry.backendStatus == 0 || resp.StatusCode >= http.StatusBadRequest {
+			entry.backendStatus = resp.StatusCode
+		}
+		if resp.Header.Get(httpcache.XFromCache) != "" {
+			if entry.cache == "" {
+				entry.cache = "hit"
+			}
+			return
+		}
+		entry.cache = "miss"
+	}
+}
+
+// OnRender implements the Hooks interface by recording the render latency
+func (a *AccessLogger) OnRender(c *gin.Context) func(error) {
+	start := time.Now()
+	return func(_ error) {
+		if entry := accessLogEntryFromContext(c); entry != nil {
+			entry.mutex.Lock()
+			entry.render += time.Since(start)
+			entry.mutex.Unlock()
+		}
+	}
+}
+
+func accessLogEntryFromContext(c *gin.Context) *accessLogEntry {
+	if v, ok := c.Get(accessLogKey); ok {
+		if entry, ok := v.(*accessLogEntry); ok {
+			return entry
+		}
+	}
+	return nil
+}
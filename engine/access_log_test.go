@@ -0,0 +1,99 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gregjones/httpcache"
+	"github.com/rs/zerolog"
+)
+
+func TestAccessLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	a := NewAccessLogger(zerolog.New(buf))
+
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(a.HandlerFunc())
+	e.GET("/page", func(c *gin.Context) {
+		hooks := HooksFromContext(c)
+		hooks.OnRequest(c, "page")
+		req, _ := http.NewRequest("GET", "http://api.example.com/a", nil)
+		cached := &http.Response{StatusCode: http.StatusOK, Header: http.Header{httpcache.XFromCache: []string{"1"}}}
+		hooks.OnBackendCall(c, req)(cached, nil)
+		hooks.OnBackendCall(c, req)(&http.Response{StatusCode: http.StatusNotFound, Header: http.Header{}}, nil)
+		hooks.OnRender(c)(nil)
+		c.String(http.StatusOK, "ok")
+	})
+	e.GET("/static", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+
+	assertResponse(t, e, "/page", http.StatusOK, "ok")
+	record := map[string]interface{}{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Error(err)
+		return
+	}
+	for k, v := range map[string]interface{}{
+		"method":          "GET",
+		"path":            "/page",
+		"status":          200.0,
+		"page":            "page",
+		"upstream_status": 404.0,
+		"cache":           "miss",
+	} {
+		if record[k] != v {
+			t.Errorf("unexpected %s: %v", k, record[k])
+		}
+	}
+	for _, k := range []string{"latency", "backend_latency", "render_latency"} {
+		if _, ok := record[k]; !ok {
+			t.Errorf("%s not logged", k)
+		}
+	}
+
+	buf.Reset()
+	assertResponse(t, e, "/static", http.StatusOK, "ok")
+	record = map[string]interface{}{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Error(err)
+		return
+	}
+	for _, k := range []string{"page", "backend_latency", "cache", "upstream_status", "render_latency"} {
+		if _, ok := record[k]; ok {
+			t.Errorf("unexpected %s: %v", k, record[k])
+		}
+	}
+}
+
+func TestNewLogSinks(t *testing.T) {
+	if _, err := NewLogSinks([]LogSink{{Type: "unknown"}}); err == nil {
+		t.Error("expecting an error with an unknown sink")
+	}
+	if _, err := NewLogSinks([]LogSink{{Type: FileLogSink}}); err == nil {
+		t.Error("expecting an error with a file sink without path")
+	}
+
+	dir, err := ioutil.TempDir("", "logs")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "access.log")
+	w, err := NewLogSinks([]LogSink{{Type: FileLogSink, Path: name}, {Type: StdoutLogSink}})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	w.Write([]byte("{}\n"))
+	w.Write([]byte("{}\n"))
+	if data, _ := ioutil.ReadFile(name); string(data) != "{}\n{}\n" {
+		t.Errorf("unexpected content: %s", string(data))
+	}
+}
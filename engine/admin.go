@@ -0,0 +1,203 @@
+package engine
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultAdminPath is the path of the admin endpoint
+const DefaultAdminPath = "/admin"
+
+const (
+	// adminErrorWindow is the period of the recent errors of the pages
+	adminErrorWindow = 5 * time.Minute
+	// adminMaxRecentErrors is the number of failures of every page kept to count the recent ones
+	adminMaxRecentErrors = 100
+)
+
+// NewAdminAPI creates an AdminAPI describing the pages of the config and the renderers of the
+// store
+func NewAdminAPI(cfg Config, store *TemplateStore) *AdminAPI {
+	stats := make(map[string]*pageStats, len(cfg.Pages))
+	for _, page := range cfg.Pages {
+		stats[page.Name] = &pageStats{}
+	}
+	return &AdminAPI{Pages: cfg.Pages, Store: store, LoadedAt: time.Now(), stats: stats}
+}
+
+// AdminAPI exposes the pages of the engine, their templates, backends and cache TTLs, along with
+// the number of requests and failures of every page since the engine was loaded
+type AdminAPI struct {
+	Pages []Page
+	Store *TemplateStore
+	// LoadedAt is the time the engine was built
+	LoadedAt time.Time
+	mutex    sync.Mutex
+	stats    map[string]*pageStats
+}
+
+type pageStats struct {
+	requests    uint64
+	errors      uint64
+	recent      []time.Time
+	lastError   string
+	lastErrorAt time.Time
+}
+
+// AdminReport describes the engine and its pages
+type AdminReport struct {
+	LoadedAt time.Time `json:"loaded_at"`
+	// TemplatesUpdatedAt is the time of the last publication of a renderer
+	TemplatesUpdatedAt time.Time    `json:"templates_updated_at"`
+	Pages              []PageReport `json:"pages"`
+}
+
+// PageReport describes a page and its failures. The errors are the responses with a status code
+// of 500 or greater, and the recent ones are those of the last five minutes
+type PageReport struct {
+	Name              string            `json:"name"`
+	URLPattern        string            `json:"url_pattern"`
+	Template          string            `json:"template"`
+	Layout            string            `json:"layout,omitempty"`
+	BackendURLPattern string            `json:"backend_url_pattern,omitempty"`
+	Backends          map[string]string `json:"backends,omitempty"`
+	CacheTTL          string            `json:"cache_ttl,omitempty"`
+	BackendCacheTTL   string            `json:"backend_cache_ttl,omitempty"`
+	StaleTTL          string            `json:"stale_ttl,omitempty"`
+	Requests          uint64            `json:"requests"`
+	Errors            uint64            `json:"errors"`
+	RecentErrors      int               `json:"recent_errors"`
+	LastError         string            `json:"last_error,omitempty"`
+	LastErrorAt       *time.Time        `json:"last_error_at,omitempty"`
+}
+
+// Report returns the description of the engine and all its pages
+func (a *AdminAPI) Report() AdminReport {
+	r := AdminReport{LoadedAt: a.LoadedAt, Pages: make([]PageReport, 0, len(a.Pages))}
+	if a.Store != nil {
+		r.TemplatesUpdatedAt = a.Store.UpdatedAt()
+	}
+	for _, page := range a.Pages {
+		r.Pages = append(r.Pages, a.pageReport(page))
+	}
+	return r
+}
+
+func (a *AdminAPI) pageReport(page Page) PageReport {
+	r := PageReport{
+		Name:              page.Name,
+		URLPattern:        page.URLPattern,
+		Template:          page.Template,
+		Layout:            page.Layout,
+		BackendURLPattern: page.BackendURLPattern,
+		CacheTTL:          page.CacheTTL,
+		BackendCacheTTL:   page.BackendCacheTTL,
+		StaleTTL:          page.StaleTTL,
+	}
+	if len(page.Backends) > 0 {
+		r.Backends = make(map[string]string, len(page.Backends))
+		for name, backend := range page.Backends {
+			r.Backends[name] = backend.URLPattern
+		}
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	s, ok := a.stats[page.Name]
+	if !ok {
+		return r
+	}
+	r.Requests, r.Errors, r.LastError = s.requests, s.errors, s.lastError
+	if !s.lastErrorAt.IsZero() {
+		at := s.lastErrorAt
+		r.LastErrorAt = &at
+	}
+	since := time.Now().Add(-adminErrorWindow)
+	for _, t := range s.recent {
+		if t.After(since) {
+			r.RecentErrors++
+		}
+	}
+	return r
+}
+
+// record counts the response of a request of the page
+func (a *AdminAPI) record(name string, status int, err string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	s, ok := a.stats[name]
+	if !ok {
+		return
+	}
+	s.requests++
+	if status < http.StatusInternalServerError {
+		return
+	}
+	now := time.Now()
+	s.errors++
+	s.lastError, s.lastErrorAt = err, now
+	if s.lastError == "" {
+		s.lastError = http.StatusText(status)
+	}
+	if len(s.recent) == adminMaxRecentErrors {
+		s.recent = s.recent[1:]
+	}
+	s.recent = append(s.recent, now)
+}
+
+// HandlerFunc returns a gin middleware recording the responses of the requests handled by the
+// pages
+func (a *AdminAPI) HandlerFunc() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hooks := &adminHooks{}
+		addHooks(c, hooks)
+		c.Next()
+		if hooks.page == "" {
+			return
+		}
+		err := ""
+		if last := c.Errors.Last(); last != nil {
+			err = last.Error()
+		}
+		a.record(hooks.page, c.Writer.Status(), err)
+	}
+}
+
+// Routes returns a route setter registering the report of all the pages at the received path and
+// the report of every page at path/pages/:name. If the token is not empty, it is required as a
+// bearer token
+func (a *AdminAPI) Routes(path, token string) func(*gin.Engine) {
+	authorized := bearerTokenAuth(token)
+	return func(e *gin.Engine) {
+		e.GET(path, authorized, func(c *gin.Context) {
+			c.Header("Cache-Control", "no-store")
+			c.JSON(http.StatusOK, a.Report())
+		})
+		e.GET(path+"/pages/:name", authorized, func(c *gin.Context) {
+			for _, page := range a.Pages {
+				if page.Name == c.Param("name") {
+					c.Header("Cache-Control", "no-store")
+					c.JSON(http.StatusOK, a.pageReport(page))
+					return
+				}
+			}
+			c.AbortWithStatus(http.StatusNotFound)
+		})
+	}
+}
+
+// adminHooks records the name of the first page handling the request
+type adminHooks struct {
+	NoopHooks
+	page string
+}
+
+// OnRequest implements the Hooks interface
+func (h *adminHooks) OnRequest(_ *gin.Context, name string) {
+	if h.page == "" {
+		h.page = name
+	}
+}
@@ -0,0 +1,86 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAdminAPI(t *testing.T) {
+	store := NewTemplateStore()
+	store.Set("product", EmptyRenderer)
+	admin := NewAdminAPI(Config{Pages: []Page{
+		{
+			Name:              "product",
+			URLPattern:        "/products/:id",
+			Template:          "product",
+			BackendURLPattern: "http://api/products/:id",
+			Backends:          map[string]PageBackend{"reviews": {URLPattern: "http://api/reviews/:id"}},
+			CacheTTL:          "10m",
+		},
+		{Name: "home", URLPattern: "/", Template: "home", Layout: "main"},
+	}}, store)
+
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(admin.HandlerFunc())
+	admin.Routes(DefaultAdminPath, "secret")(e)
+	e.GET("/products/:id", func(c *gin.Context) {
+		HooksFromContext(c).OnRequest(c, "product")
+		if c.Param("id") == "0" {
+			c.AbortWithError(http.StatusBadGateway, errors.New("backend down"))
+			return
+		}
+		c.String(http.StatusOK, "product")
+	})
+
+	for _, path := range []string{"/products/1", "/products/0", "/products/2", "/unknown"} {
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", DefaultAdminPath, nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+
+	req := httptest.NewRequest("GET", DefaultAdminPath, nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	var report AdminReport
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.TemplatesUpdatedAt.IsZero() || len(report.Pages) != 2 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	product := report.Pages[0]
+	if product.Requests != 3 || product.Errors != 1 || product.RecentErrors != 1 ||
+		product.LastError != "backend down" || product.LastErrorAt == nil {
+		t.Errorf("unexpected page report: %+v", product)
+	}
+	if product.Backends["reviews"] != "http://api/reviews/:id" || product.CacheTTL != "10m" {
+		t.Errorf("unexpected page report: %+v", product)
+	}
+	if home := report.Pages[1]; home.Requests != 0 || home.Layout != "main" {
+		t.Errorf("unexpected page report: %+v", home)
+	}
+
+	for path, status := range map[string]int{
+		DefaultAdminPath + "/pages/home":    http.StatusOK,
+		DefaultAdminPath + "/pages/unknown": http.StatusNotFound,
+	} {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		if w.Code != status {
+			t.Errorf("%s: unexpected status code: %d", path, w.Code)
+		}
+	}
+}
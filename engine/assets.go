@@ -0,0 +1,140 @@
+package engine
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+)
+
+const assetsKey = "api2html_assets"
+
+var assetKeyReplacer = regexp.MustCompile("[^a-zA-Z0-9]")
+
+// Asset contains the info regarding a file of the public folder
+type Asset struct {
+	// Path is the path of the file, relative to the public folder
+	Path string
+	// URL is the public URL of the file
+	URL string
+	// Integrity is the subresource integrity hash of the file content
+	Integrity string
+}
+
+// Script returns a script tag loading the asset and checking its integrity
+func (a Asset) Script() string {
+	return fmt.Sprintf(`<script src="%s" integrity="%s" crossorigin="anonymous"></script>`,
+		html.EscapeString(a.URL), a.Integrity)
+}
+
+// Stylesheet returns a link tag loading the asset and checking its integrity
+func (a Asset) Stylesheet() string {
+	return fmt.Sprintf(`<link rel="stylesheet" href="%s" integrity="%s" crossorigin="anonymous">`,
+		html.EscapeString(a.URL), a.Integrity)
+}
+
+// AssetManifest indexes the assets of the public folder by their key. Since the mustache
+// templates use the dot as a separator, the key of an asset is its relative path with all the
+// non alphanumeric chars replaced by underscores (js/app.min.js -> js_app_min_js)
+type AssetManifest map[string]Asset
+
+// NewAssetManifest creates an AssetManifest by hashing all the files of the public folder
+func NewAssetManifest(cfg PublicFolder) (AssetManifest, error) {
+	m := AssetManifest{}
+	err := filepath.Walk(cfg.Path, func(name string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(cfg.Path, name)
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		m[AssetKey(rel)] = Asset{
+			Path:      rel,
+			URL:       path.Join("/", cfg.Prefix, rel),
+			Integrity: Integrity(data),
+		}
+		return nil
+	})
+	return m, err
+}
+
+// AssetKey returns the key of the asset with the received path in an AssetManifest
+func AssetKey(name string) string {
+	return assetKeyReplacer.ReplaceAllString(name, "_")
+}
+
+// Integrity returns the sha384 subresource integrity hash of the received content
+func Integrity(data []byte) string {
+	sum := sha512.Sum384(data)
+	return "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// AssetsMiddleware returns a gin middleware attaching the asset manifest to the request, so it
+// is exposed to the templates through the helper
+func AssetsMiddleware(m AssetManifest) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(assetsKey, m)
+		c.Next()
+	}
+}
+
+// AssetsFromContext returns the asset manifest attached to the gin context, if any
+func AssetsFromContext(c *gin.Context) AssetManifest {
+	if c == nil {
+		return nil
+	}
+	if v, ok := c.Get(assetsKey); ok {
+		if m, ok := v.(AssetManifest); ok {
+			return m
+		}
+	}
+	return nil
+}
+
+// newAssetsMiddleware hashes the public folder once or, in devel mode, for every request, so
+// the changes in the assets are not hidden by stale hashes
+func newAssetsMiddleware(cfg PublicFolder, fingerprints map[string]string, devel bool) (gin.HandlerFunc, error) {
+	if !devel {
+		m, err := NewAssetManifest(cfg)
+		if err != nil {
+			return nil, err
+		}
+		m.fingerprint(cfg, fingerprints)
+		return AssetsMiddleware(m), nil
+	}
+	return func(c *gin.Context) {
+		m, err := NewAssetManifest(cfg)
+		if err != nil {
+			log.Println("hashing the public folder:", err.Error())
+		}
+		c.Set(assetsKey, m)
+		c.Next()
+	}, nil
+}
+
+// fingerprint points the assets to their fingerprinted copies, hiding the copies themselves
+func (m AssetManifest) fingerprint(cfg PublicFolder, fingerprints map[string]string) {
+	for original, fingerprinted := range fingerprints {
+		delete(m, AssetKey(fingerprinted))
+		asset, ok := m[AssetKey(original)]
+		if !ok {
+			continue
+		}
+		asset.URL = path.Join("/", cfg.Prefix, fingerprinted)
+		m[AssetKey(original)] = asset
+	}
+}
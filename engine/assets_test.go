@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+const appIntegrity = "sha384-HT2E9NfWiuQ/w1PRai+hTyqW16NIoCGA/m8VQDUopfAtcz6YQjtsMmQd5uRbVDpW"
+
+func TestNewAssetManifest(t *testing.T) {
+	dir, err := ioutil.TempDir(".", "assets")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	if err := os.MkdirAll(filepath.Join(dir, "js"), 0755); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "js", "app.min.js"), []byte("alert(1)"), 0644); err != nil {
+		t.Error(err)
+		return
+	}
+
+	m, err := NewAssetManifest(PublicFolder{Path: dir, Prefix: "/static"})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if len(m) != 1 {
+		t.Errorf("unexpected manifest: %v", m)
+		return
+	}
+	asset, ok := m["js_app_min_js"]
+	if !ok {
+		t.Errorf("asset not found: %v", m)
+		return
+	}
+	if asset.Path != "js/app.min.js" {
+		t.Errorf("unexpected path: %s", asset.Path)
+	}
+	if asset.URL != "/static/js/app.min.js" {
+		t.Errorf("unexpected url: %s", asset.URL)
+	}
+	if asset.Integrity != appIntegrity {
+		t.Errorf("unexpected integrity: %s", asset.Integrity)
+	}
+}
+
+func TestNewAssetManifest_koUnknownFolder(t *testing.T) {
+	if _, err := NewAssetManifest(PublicFolder{Path: "unknown"}); err == nil {
+		t.Error("expecting error")
+	}
+}
+
+func TestAsset_tags(t *testing.T) {
+	a := Asset{URL: "/a.js?v=1&b=2", Integrity: appIntegrity}
+	expected := `<script src="/a.js?v=1&amp;b=2" integrity="` + appIntegrity + `" crossorigin="anonymous"></script>`
+	if s := a.Script(); s != expected {
+		t.Errorf("unexpected script tag: %s", s)
+	}
+	expected = `<link rel="stylesheet" href="/a.js?v=1&amp;b=2" integrity="` + appIntegrity + `" crossorigin="anonymous">`
+	if s := a.Stylesheet(); s != expected {
+		t.Errorf("unexpected stylesheet tag: %s", s)
+	}
+}
+
+func TestFactory_New_sri(t *testing.T) {
+	dir, err := ioutil.TempDir(".", "public")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "app.js"), []byte("alert(1)"), 0644); err != nil {
+		t.Error(err)
+		return
+	}
+
+	ef := DefaultFactory
+	ef.TemplateFS = fstest.MapFS{
+		"a.mustache": {Data: []byte("{{{Helper.Assets.app_js.Script}}}")},
+	}
+	ef.Parser = func(_ string) (Config, error) {
+		return Config{
+			Pages:        []Page{{URLPattern: "/a", Template: "a"}},
+			Templates:    map[string]string{"a": "a.mustache"},
+			PublicFolder: &PublicFolder{Path: dir, Prefix: "/js", SRI: true},
+		}, nil
+	}
+
+	e, err := ef.New("something", false)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	assertResponse(t, e, "/a", http.StatusOK, `<script src="/js/app.js" integrity="`+appIntegrity+`" crossorigin="anonymous"></script>`)
+	assertResponse(t, e, "/js/app.js", http.StatusOK, "alert(1)")
+}
@@ -0,0 +1,127 @@
+package engine
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	authPagesKey  = "api2html_auth_pages"
+	returnToParam = "return_to"
+)
+
+// ErrNoAuthSecret is the error returned when the login redirect has no secret for signing the
+// return_to param
+var ErrNoAuthSecret = fmt.Errorf("the login redirect requires a secret")
+
+// NewAuthPagesHandler creates an AuthPagesHandler rendering the received 401 and 403 pages
+func NewAuthPagesHandler(cfg AuthPages, unauthorized, forbidden ErrorHandler) (*AuthPagesHandler, error) {
+	if cfg.LoginURL != "" && cfg.Secret == "" {
+		return nil, ErrNoAuthSecret
+	}
+	return &AuthPagesHandler{
+		Unauthorized: unauthorized.Content,
+		Forbidden:    forbidden.Content,
+		LoginURL:     cfg.LoginURL,
+		secret:       []byte(cfg.Secret),
+	}, nil
+}
+
+// AuthPagesHandler renders the 401 and 403 pages for the requests rejected with the Unauthorized
+// and Forbidden functions, redirecting the unauthenticated users to the login route if defined
+type AuthPagesHandler struct {
+	Unauthorized []byte
+	Forbidden    []byte
+	LoginURL     string
+	secret       []byte
+}
+
+// HandlerFunc returns a gin middleware attaching the handler to the request. It must be
+// registered before the auth middlewares
+func (a *AuthPagesHandler) HandlerFunc() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(authPagesKey, a)
+		c.Next()
+	}
+}
+
+func (a *AuthPagesHandler) unauthorized(c *gin.Context) {
+	if a.LoginURL != "" && (c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead) {
+		sep := "?"
+		if strings.Contains(a.LoginURL, "?") {
+			sep = "&"
+		}
+		returnTo := SignReturnTo(a.secret, c.Request.URL.RequestURI())
+		c.Redirect(http.StatusFound, a.LoginURL+sep+returnToParam+"="+url.QueryEscape(returnTo))
+		c.Abort()
+		return
+	}
+	c.Data(http.StatusUnauthorized, "text/html; charset=utf-8", a.Unauthorized)
+	c.Abort()
+}
+
+// Unauthorized aborts the request rendering the 401 page or redirecting to the login route. It
+// is intended to be called by the auth middlewares
+func Unauthorized(c *gin.Context) {
+	if a := authPagesFromContext(c); a != nil {
+		a.unauthorized(c)
+		return
+	}
+	c.AbortWithStatus(http.StatusUnauthorized)
+}
+
+// Forbidden aborts the request rendering the 403 page. It is intended to be called by the auth
+// middlewares
+func Forbidden(c *gin.Context) {
+	if a := authPagesFromContext(c); a != nil {
+		c.Data(http.StatusForbidden, "text/html; charset=utf-8", a.Forbidden)
+		c.Abort()
+		return
+	}
+	c.AbortWithStatus(http.StatusForbidden)
+}
+
+func authPagesFromContext(c *gin.Context) *AuthPagesHandler {
+	if v, ok := c.Get(authPagesKey); ok {
+		if a, ok := v.(*AuthPagesHandler); ok {
+			return a
+		}
+	}
+	return nil
+}
+
+// SignReturnTo returns the value of the return_to param for the received target, prefixed by
+// its signature
+func SignReturnTo(secret []byte, target string) string {
+	return returnToSignature(secret, target) + "." + target
+}
+
+// VerifyReturnTo returns the target of the received return_to param if the signature is valid
+// and the target is a local path, so the login route can redirect the user back safely
+func VerifyReturnTo(secret []byte, value string) (string, bool) {
+	parts := strings.SplitN(value, ".", 2)
+	if len(parts) != 2 {
+		return "", false
+	}
+	if !hmac.Equal([]byte(parts[0]), []byte(returnToSignature(secret, parts[1]))) {
+		return "", false
+	}
+	target := parts[1]
+	if !strings.HasPrefix(target, "/") || strings.HasPrefix(target, "//") || strings.HasPrefix(target, "/\\") {
+		return "", false
+	}
+	return target, true
+}
+
+func returnToSignature(secret []byte, target string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(target))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
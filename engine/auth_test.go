@@ -0,0 +1,103 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNewAuthPagesHandler_koNoSecret(t *testing.T) {
+	if _, err := NewAuthPagesHandler(AuthPages{LoginURL: "/login"}, Default401StaticHandler, Default403StaticHandler); err != ErrNoAuthSecret {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestSignReturnTo(t *testing.T) {
+	secret := []byte("s3cr3t")
+	for _, target := range []string{"/cart?item=1.5", "/"} {
+		value := SignReturnTo(secret, target)
+		if res, ok := VerifyReturnTo(secret, value); !ok || res != target {
+			t.Errorf("%s: unexpected result: %s, %v", target, res, ok)
+		}
+		if _, ok := VerifyReturnTo([]byte("other"), value); ok {
+			t.Errorf("%s: accepting a value signed with other secret", target)
+		}
+	}
+	for _, value := range []string{
+		"",
+		"/cart",
+		"sig./cart",
+		strings.Replace(SignReturnTo(secret, "/cart"), "/cart", "/admin", 1),
+		SignReturnTo(secret, "https://evil.com"),
+		SignReturnTo(secret, "//evil.com"),
+		SignReturnTo(secret, "/\\evil.com"),
+	} {
+		if res, ok := VerifyReturnTo(secret, value); ok {
+			t.Errorf("%s: unexpected result: %s", value, res)
+		}
+	}
+}
+
+func TestFactory_New_authPages(t *testing.T) {
+	auth := func(c *gin.Context) {
+		switch c.Request.Header.Get("Authorization") {
+		case "":
+			Unauthorized(c)
+		case "guest":
+			Forbidden(c)
+		}
+	}
+
+	for _, tc := range []struct {
+		name     string
+		cfg      *AuthPages
+		method   string
+		header   string
+		status   int
+		body     string
+		location string
+	}{
+		{name: "no config", method: "GET", status: http.StatusUnauthorized},
+		{name: "401 page", cfg: &AuthPages{}, method: "GET", status: http.StatusUnauthorized, body: default401Tmpl},
+		{name: "403 page", cfg: &AuthPages{LoginURL: "/login", Secret: "s3cr3t"}, method: "GET", header: "guest", status: http.StatusForbidden, body: default403Tmpl},
+		{name: "redirect", cfg: &AuthPages{LoginURL: "/login?lang=en", Secret: "s3cr3t"}, method: "GET", status: http.StatusFound, location: "/login?lang=en&return_to=" + url.QueryEscape(SignReturnTo([]byte("s3cr3t"), "/private?a=1"))},
+		{name: "no redirect", cfg: &AuthPages{LoginURL: "/login", Secret: "s3cr3t"}, method: "POST", status: http.StatusUnauthorized, body: default401Tmpl},
+		{name: "authorized", cfg: &AuthPages{}, method: "GET", header: "admin", status: http.StatusOK, body: "private"},
+	} {
+		cfg := tc.cfg
+		ef := DefaultFactory.Use(auth)
+		ef.ErrorHandlerFactory = func(_ string, _ int) (ErrorHandler, error) { return ErrorHandler{}, ErrNoResponseGeneratorDefined }
+		ef.Parser = func(_ string) (Config, error) { return Config{AuthPages: cfg}, nil }
+		ef.Routes = append(ef.Routes, func(e *gin.Engine) {
+			e.Handle("GET", "/private", func(c *gin.Context) { c.String(http.StatusOK, "private") })
+			e.Handle("POST", "/private", func(c *gin.Context) { c.String(http.StatusOK, "private") })
+		})
+
+		e, err := ef.New("something", false)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tc.name, err.Error())
+			continue
+		}
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(tc.method, "/private?a=1", nil)
+		if tc.header != "" {
+			req.Header.Set("Authorization", tc.header)
+		}
+		e.ServeHTTP(w, req)
+
+		if w.Code != tc.status {
+			t.Errorf("%s: unexpected status code: %d", tc.name, w.Code)
+		}
+		if tc.body != "" && w.Body.String() != tc.body {
+			t.Errorf("%s: unexpected body: %s", tc.name, w.Body.String())
+		}
+		if location := w.Header().Get("Location"); location != tc.location {
+			t.Errorf("%s: unexpected location: %s", tc.name, location)
+		}
+	}
+}
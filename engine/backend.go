@@ -2,16 +2,18 @@ package engine
 
 import (
 	"bytes"
+	"context"
+	"fmt"
 	"net/http"
+	"path"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gregjones/httpcache"
-	newrelic "github.com/newrelic/go-agent"
-	nrgin "github.com/newrelic/go-agent/_integrations/nrgin/v1"
 )
 
 var (
-	cachedTransport  = httpcache.NewMemoryCacheTransport()
+	cachedTransport  = BackendCacheStats.Transport(httpcache.NewMemoryCacheTransport())
 	cachedHTTPClient = http.Client{Transport: cachedTransport}
 )
 
@@ -30,22 +32,69 @@ func CachedClient(URLPattern string) Backend {
 // NewBackend creates a Backend with the received http client and url pattern
 func NewBackend(client *http.Client, URLPattern string) Backend {
 	urlPattern := []byte(URLPattern)
-	actualTransport := client.Transport
 	return func(params map[string]string, headers map[string]string, c *gin.Context) (*http.Response, error) {
-		if newrelicApp != nil {
-			defer newrelic.StartSegment(nrgin.Transaction(c), "Backend").End()
-			client.Transport = newrelic.NewRoundTripper(nrgin.Transaction(c), actualTransport)
-		}
+		return doBackendRequest(client, string(replaceParams(urlPattern, params)), headers, c)
+	}
+}
+
+// ErrPathOutsidePrefix is the error returned by the proxy backends when the cleaned path of the
+// request is not under their prefix
+var ErrPathOutsidePrefix = fmt.Errorf("the path escapes the prefix of the proxy")
 
-		req, err := http.NewRequest("GET", string(replaceParams(urlPattern, params)), nil)
-		if err != nil {
-			return nil, err
+// NewProxyBackend creates a Backend requesting the cleaned path (without the prefix) and the query
+// of the inbound request to the base URL. The paths escaping the prefix, like /api/../admin, are
+// rejected with an ErrPathOutsidePrefix
+func NewProxyBackend(client *http.Client, baseURL, prefix string) Backend {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	prefix = strings.TrimSuffix(prefix, "/")
+	return func(_ map[string]string, headers map[string]string, c *gin.Context) (*http.Response, error) {
+		p, ok := proxiedPath(c.Request.URL.Path, prefix)
+		if !ok {
+			return nil, ErrPathOutsidePrefix
 		}
-		for k, v := range headers {
-			req.Header.Add(k, v)
+		u := baseURL + p
+		if c.Request.URL.RawQuery != "" {
+			u += "?" + c.Request.URL.RawQuery
 		}
-		return client.Do(req)
+		return doBackendRequest(client, u, headers, c)
+	}
+}
+
+// proxiedPath returns the cleaned path without the prefix, keeping its trailing slash, or false
+// if it is not under the prefix
+func proxiedPath(p, prefix string) (string, bool) {
+	cleaned := path.Clean("/" + p)
+	if cleaned != prefix && !strings.HasPrefix(cleaned, prefix+"/") {
+		return "", false
+	}
+	cleaned = strings.TrimPrefix(cleaned, prefix)
+	if strings.HasSuffix(p, "/") && !strings.HasSuffix(cleaned, "/") {
+		cleaned += "/"
+	}
+	return cleaned, true
+}
+
+func doBackendRequest(client *http.Client, u string, headers map[string]string, c *gin.Context) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(requestContext(c), "GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header.Add(k, v)
+	}
+	done := HooksFromContext(c).OnBackendCall(c, req)
+	resp, err := client.Do(req)
+	done(resp, err)
+	return resp, err
+}
+
+// requestContext returns the context of the inbound request, so the backend requests are
+// cancelled with it
+func requestContext(c *gin.Context) context.Context {
+	if c == nil || c.Request == nil {
+		return context.Background()
 	}
+	return c.Request.Context()
 }
 
 func replaceParams(URLPattern []byte, params map[string]string) []byte {
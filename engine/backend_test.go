@@ -59,3 +59,46 @@ func TestReplaceParams(t *testing.T) {
 		t.Error("The replace is not working as expected.")
 	}
 }
+
+func TestNewProxyBackend(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.URL.RequestURI())
+	}))
+	defer mockServer.Close()
+	backend := NewProxyBackend(http.DefaultClient, mockServer.URL+"/v1/", "/api/")
+
+	for path, expected := range map[string]string{
+		"/api/products/1?lang=en":    "/v1/products/1?lang=en",
+		"/api/products/":             "/v1/products/",
+		"/api/products/../about":     "/v1/about",
+		"/api//products":             "/v1/products",
+		"/api/../admin":              "",
+		"/api/products/../../admin/": "",
+		"/api/products/../../api2/x": "",
+	} {
+		context, _ := gin.CreateTestContext(httptest.NewRecorder())
+		context.Request = httptest.NewRequest("GET", "http://example.com/", nil)
+		context.Request.URL.Path, context.Request.URL.RawQuery = path, ""
+		if i := bytes.IndexByte([]byte(path), '?'); i > 0 {
+			context.Request.URL.Path, context.Request.URL.RawQuery = path[:i], path[i+1:]
+		}
+		resp, err := backend(nil, nil, context)
+		if expected == "" {
+			if err != ErrPathOutsidePrefix {
+				t.Errorf("%s: unexpected error: %v", path, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", path, err.Error())
+			continue
+		}
+		body := &bytes.Buffer{}
+		body.ReadFrom(resp.Body)
+		resp.Body.Close()
+		if body.String() != expected {
+			t.Errorf("%s: unexpected backend path: %s", path, body.String())
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultBenchRequests    = 1000
+	defaultBenchConcurrency = 10
+)
+
+type benchTimingsKey struct{}
+
+// NewBenchmark creates the engine of the config, with the hooks timing the backend calls, the
+// decoding and the rendering of its requests, and a Benchmark requesting the path to it
+func (ef Factory) NewBenchmark(cfgPath, path string) (*Benchmark, error) {
+	cfg, err := ef.Parser(cfgPath)
+	if err != nil {
+		return nil, err
+	}
+	var store *TemplateStore
+	storeFactory := ef.TemplateStoreFactory
+	ef.TemplateStoreFactory = func() *TemplateStore {
+		store = storeFactory()
+		return store
+	}
+	e, err := ef.Use(HooksMiddleware(benchHooks{})).NewFromConfig(cfg, false)
+	if err != nil {
+		return nil, err
+	}
+	b := NewBenchmark(e, path)
+	b.templates = &HealthChecker{Pages: cfg.Pages, Store: store}
+	return b, nil
+}
+
+// NewRemoteBenchmark creates a Benchmark requesting the path to the running instance of the
+// base URL. Just the total latency of its requests is measured
+func NewRemoteBenchmark(baseURL, path string) *Benchmark {
+	b := NewBenchmark(remoteHandler{strings.TrimSuffix(baseURL, "/"), &http.Client{}}, path)
+	b.Remote = true
+	return b
+}
+
+// NewBenchmark creates a Benchmark requesting the path to the received handler
+func NewBenchmark(h http.Handler, path string) *Benchmark {
+	return &Benchmark{
+		Handler:     h,
+		Path:        path,
+		Requests:    defaultBenchRequests,
+		Concurrency: defaultBenchConcurrency,
+	}
+}
+
+// Benchmark sends a number of requests of the same path to a handler, with a number of them in
+// flight at the same time, and reports the percentiles of their latencies. The requests of the
+// engines created by the Factory.NewBenchmark also report the time spent in the backend calls,
+// the decoding and the rendering, so the template performance regressions can be told apart from
+// the slow backends
+type Benchmark struct {
+	Handler     http.Handler
+	Path        string
+	Requests    int
+	Concurrency int
+	// Headers are added to every request
+	Headers map[string]string
+	// Remote flags the benchmarks of a running instance, without allocation stats
+	Remote    bool
+	templates *HealthChecker
+}
+
+// BenchReport contains the results of a Benchmark
+type BenchReport struct {
+	Requests int
+	// Failures is the number of requests answered with a status code other than 200
+	Failures int
+	Duration time.Duration
+	Total    LatencyPercentiles
+	Backend  LatencyPercentiles
+	Decode   LatencyPercentiles
+	Render   LatencyPercentiles
+	// AllocsPerRequest and BytesPerRequest are the heap allocations of the process divided by
+	// the number of requests. They are not measured for the remote benchmarks
+	AllocsPerRequest uint64
+	BytesPerRequest  uint64
+	Remote           bool
+}
+
+// LatencyPercentiles contains the percentiles of a set of durations
+type LatencyPercentiles struct {
+	P50 time.Duration
+	P90 time.Duration
+	P99 time.Duration
+	Max time.Duration
+}
+
+// benchTimings accumulates the durations of the phases of a request
+type benchTimings struct {
+	mutex   sync.Mutex
+	backend time.Duration
+	decode  time.Duration
+	render  time.Duration
+}
+
+func (t *benchTimings) add(d *time.Duration, start time.Time) {
+	t.mutex.Lock()
+	*d += time.Since(start)
+	t.mutex.Unlock()
+}
+
+// Run sends the requests once the templates of the pages are published and returns the report
+func (b *Benchmark) Run() (BenchReport, error) {
+	if b.templates != nil {
+		if err := b.templates.waitTemplates(exportTemplatesTimeout); err != nil {
+			return BenchReport{}, err
+		}
+	}
+	requests := b.Requests
+	if requests <= 0 {
+		requests = defaultBenchRequests
+	}
+	concurrency := b.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultBenchConcurrency
+	}
+
+	timings := make([]*benchTimings, requests)
+	totals := make([]time.Duration, requests)
+	failed := make([]bool, requests)
+	queue := make(chan int)
+	var wg sync.WaitGroup
+
+	runtime.GC()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range queue {
+				timings[n] = &benchTimings{}
+				req, err := http.NewRequest(http.MethodGet, b.Path, nil)
+				if err != nil {
+					failed[n] = true
+					continue
+				}
+				req = req.WithContext(context.WithValue(req.Context(), benchTimingsKey{}, timings[n]))
+				for k, v := range b.Headers {
+					req.Header.Set(k, v)
+				}
+				rec := &fragmentWriter{header: http.Header{}, status: http.StatusOK}
+				requestStart := time.Now()
+				b.Handler.ServeHTTP(rec, req)
+				totals[n] = time.Since(requestStart)
+				failed[n] = rec.status != http.StatusOK
+			}
+		}()
+	}
+	for n := 0; n < requests; n++ {
+		queue <- n
+	}
+	close(queue)
+	wg.Wait()
+	report := BenchReport{Requests: requests, Duration: time.Since(start), Remote: b.Remote}
+	runtime.ReadMemStats(&after)
+
+	backend := make([]time.Duration, requests)
+	decode := make([]time.Duration, requests)
+	render := make([]time.Duration, requests)
+	for n, t := range timings {
+		// the background refreshes of the stale responses may still be running
+		t.mutex.Lock()
+		backend[n], decode[n], render[n] = t.backend, t.decode, t.render
+		t.mutex.Unlock()
+		if failed[n] {
+			report.Failures++
+		}
+	}
+	report.Total = percentiles(totals)
+	report.Backend = percentiles(backend)
+	report.Decode = percentiles(decode)
+	report.Render = percentiles(render)
+	if !b.Remote {
+		report.AllocsPerRequest = (after.Mallocs - before.Mallocs) / uint64(requests)
+		report.BytesPerRequest = (after.TotalAlloc - before.TotalAlloc) / uint64(requests)
+	}
+	return report, nil
+}
+
+// String implements the Stringer interface
+func (r BenchReport) String() string {
+	buf := &bytes.Buffer{}
+	rate := float64(r.Requests) / r.Duration.Seconds()
+	fmt.Fprintf(buf, "requests: %d (%d failed) in %s, %.1f req/s\n", r.Requests, r.Failures, r.Duration.Round(time.Millisecond), rate)
+	w := tabwriter.NewWriter(buf, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "\tp50\tp90\tp99\tmax\t")
+	names := []string{"total"}
+	values := []LatencyPercentiles{r.Total}
+	if !r.Remote {
+		names = append(names, "backend", "decode", "render")
+		values = append(values, r.Backend, r.Decode, r.Render)
+	}
+	for i, p := range values {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t\n", names[i], p.P50, p.P90, p.P99, p.Max)
+	}
+	w.Flush()
+	if !r.Remote {
+		fmt.Fprintf(buf, "allocs/request: %d, bytes/request: %d\n", r.AllocsPerRequest, r.BytesPerRequest)
+	}
+	return buf.String()
+}
+
+// percentiles returns the percentiles of the durations, sorting them in place
+func percentiles(ds []time.Duration) LatencyPercentiles {
+	if len(ds) == 0 {
+		return LatencyPercentiles{}
+	}
+	sort.Slice(ds, func(i, j int) bool { return ds[i] < ds[j] })
+	at := func(p float64) time.Duration {
+		return ds[int(p*float64(len(ds)-1))]
+	}
+	return LatencyPercentiles{P50: at(0.5), P90: at(0.9), P99: at(0.99), Max: ds[len(ds)-1]}
+}
+
+// benchHooks adds the durations of the backend calls, the decoding and the rendering to the
+// timings of the benchmark requests
+type benchHooks struct {
+	NoopHooks
+}
+
+func (benchHooks) timings(c *gin.Context) (*benchTimings, bool) {
+	t, ok := c.Request.Context().Value(benchTimingsKey{}).(*benchTimings)
+	return t, ok
+}
+
+// OnBackendCall implements the Hooks interface
+func (h benchHooks) OnBackendCall(c *gin.Context, _ *http.Request) func(*http.Response, error) {
+	t, ok := h.timings(c)
+	if !ok {
+		return func(_ *http.Response, _ error) {}
+	}
+	start := time.Now()
+	return func(_ *http.Response, _ error) { t.add(&t.backend, start) }
+}
+
+// OnDecode implements the Hooks interface
+func (h benchHooks) OnDecode(c *gin.Context) func(error) {
+	t, ok := h.timings(c)
+	if !ok {
+		return func(_ error) {}
+	}
+	start := time.Now()
+	return func(_ error) { t.add(&t.decode, start) }
+}
+
+// OnRender implements the Hooks interface
+func (h benchHooks) OnRender(c *gin.Context) func(error) {
+	t, ok := h.timings(c)
+	if !ok {
+		return func(_ error) {}
+	}
+	start := time.Now()
+	return func(_ error) { t.add(&t.render, start) }
+}
+
+// remoteHandler sends the requests to a running instance, copying the status code and the body
+// of its responses
+type remoteHandler struct {
+	baseURL string
+	client  *http.Client
+}
+
+func (r remoteHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	out, err := http.NewRequestWithContext(req.Context(), req.Method, r.baseURL+req.URL.RequestURI(), nil)
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	out.Header = req.Header
+	resp, err := r.client.Do(out)
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
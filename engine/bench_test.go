@@ -0,0 +1,98 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestBenchmark(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(HooksMiddleware(benchHooks{}))
+	e.GET("/products/:id", func(c *gin.Context) {
+		hooks := HooksFromContext(c)
+		done := hooks.OnBackendCall(c, nil)
+		time.Sleep(2 * time.Millisecond)
+		done(nil, nil)
+		hooks.OnDecode(c)(nil)
+		renderDone := hooks.OnRender(c)
+		time.Sleep(time.Millisecond)
+		renderDone(nil)
+		if c.Param("id") == "broken" {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		c.String(http.StatusOK, "ok")
+	})
+
+	b := NewBenchmark(e, "/products/42")
+	b.Requests = 20
+	b.Concurrency = 4
+	report, err := b.Run()
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if report.Requests != 20 || report.Failures != 0 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	if report.Backend.P50 < 2*time.Millisecond || report.Render.P50 < time.Millisecond || report.Total.P50 < 3*time.Millisecond {
+		t.Errorf("unexpected latencies: %+v", report)
+	}
+	if report.Total.Max < report.Total.P99 || report.Total.P99 < report.Total.P50 {
+		t.Errorf("unexpected percentiles: %+v", report.Total)
+	}
+	if s := report.String(); !strings.Contains(s, "requests: 20 (0 failed)") || !strings.Contains(s, "render") || !strings.Contains(s, "allocs/request") {
+		t.Errorf("unexpected output: %s", s)
+	}
+
+	b.Path = "/products/broken"
+	if report, err := b.Run(); err != nil || report.Failures != 20 {
+		t.Errorf("unexpected report: %+v %v", report, err)
+	}
+}
+
+func TestNewRemoteBenchmark(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.RequestURI() != "/products/42?lang=en" || r.Header.Get("X-Test") != "yes" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer s.Close()
+
+	b := NewRemoteBenchmark(s.URL+"/", "/products/42?lang=en")
+	b.Requests = 10
+	b.Headers = map[string]string{"X-Test": "yes"}
+	report, err := b.Run()
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if report.Failures != 0 || report.Total.P50 <= 0 || report.Backend.Max != 0 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	if s := report.String(); strings.Contains(s, "render") || strings.Contains(s, "allocs/request") {
+		t.Errorf("unexpected output: %s", s)
+	}
+}
+
+func Test_percentiles(t *testing.T) {
+	ds := []time.Duration{}
+	for i := 100; i > 0; i-- {
+		ds = append(ds, time.Duration(i))
+	}
+	p := percentiles(ds)
+	if p.P50 != 50 || p.P90 != 90 || p.P99 != 99 || p.Max != 100 {
+		t.Errorf("unexpected percentiles: %+v", p)
+	}
+	if p := percentiles(nil); p.Max != 0 {
+		t.Errorf("unexpected percentiles: %+v", p)
+	}
+}
@@ -0,0 +1,229 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultMaxBodySize = 4096
+	redactedValue      = "[REDACTED]"
+)
+
+var defaultRedactedFields = []string{"Authorization", "Cookie", "Set-Cookie"}
+
+// NewBodyLogger creates a BodyLogger with the received configuration. Authorization and
+// cookie headers are always redacted
+func NewBodyLogger(cfg RequestLogging) *BodyLogger {
+	size := cfg.MaxBodySize
+	if size <= 0 {
+		size = defaultMaxBodySize
+	}
+
+	redacted := map[string]struct{}{}
+	names := []string{}
+	for _, fields := range [][]string{defaultRedactedFields, cfg.RedactedFields} {
+		for _, field := range fields {
+			redacted[strings.ToLower(field)] = struct{}{}
+			names = append(names, regexp.QuoteMeta(field))
+		}
+	}
+	alternatives := strings.Join(names, "|")
+
+	pages := map[string]struct{}{}
+	for _, name := range cfg.Pages {
+		pages[name] = struct{}{}
+	}
+
+	return &BodyLogger{
+		MaxBodySize: size,
+		redacted:    redacted,
+		pages:       pages,
+		jsonFields:  regexp.MustCompile(`(?i)("(?:` + alternatives + `)"\s*:\s*)("(?:[^"\\]|\\.)*"?|[^,}\]\s]*)`),
+		formFields:  regexp.MustCompile(`(?i)(^|&)((?:` + alternatives + `)=)([^&]*)`),
+	}
+}
+
+// BodyLogger logs the inbound requests and the backend responses of the designated pages,
+// capping the size of the logged bodies and hiding the redacted fields
+type BodyLogger struct {
+	NoopHooks
+	MaxBodySize int
+	redacted    map[string]struct{}
+	pages       map[string]struct{}
+	jsonFields  *regexp.Regexp
+	formFields  *regexp.Regexp
+}
+
+// Logs returns true if the requests to the page with the received name should be logged
+func (b *BodyLogger) Logs(name string) bool {
+	if len(b.pages) == 0 {
+		return true
+	}
+	_, ok := b.pages[name]
+	return ok
+}
+
+// HandlerFunc returns a gin middleware that logs the inbound request and attaches the logger
+// to its hooks, so the backend response gets captured and logged too
+func (b *BodyLogger) HandlerFunc(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var body []byte
+		truncated := false
+		if c.Request.Body != nil {
+			body, truncated, c.Request.Body = b.peek(c.Request.Body)
+		}
+		log.Println("request logger:", name, c.Request.Method, b.redactURL(c.Request.URL),
+			b.redactHeaders(c.Request.Header), b.redactBody(body, truncated))
+		addHooks(c, b)
+		c.Next()
+	}
+}
+
+// OnBackendCall implements the Hooks interface by capturing the backend response
+func (b *BodyLogger) OnBackendCall(_ *gin.Context, _ *http.Request) func(*http.Response, error) {
+	return func(resp *http.Response, _ error) { b.Capture(resp) }
+}
+
+// Capture replaces the body of the received response with a reader that logs the consumed
+// content when closed
+func (b *BodyLogger) Capture(resp *http.Response) {
+	if resp == nil || resp.Body == nil {
+		return
+	}
+	target := ""
+	if resp.Request != nil {
+		target = b.redactURL(resp.Request.URL)
+	}
+	headers := b.redactHeaders(resp.Header)
+	status := resp.StatusCode
+	resp.Body = &capturedBody{
+		ReadCloser: resp.Body,
+		max:        b.MaxBodySize,
+		onClose: func(body []byte, truncated bool) {
+			log.Println("request logger: backend response", target, status, headers, b.redactBody(body, truncated))
+		},
+	}
+}
+
+func (b *BodyLogger) peek(r io.ReadCloser) ([]byte, bool, io.ReadCloser) {
+	data, _ := ioutil.ReadAll(io.LimitReader(r, int64(b.MaxBodySize+1)))
+	body := readCloser{io.MultiReader(bytes.NewReader(data), r), r}
+	if len(data) > b.MaxBodySize {
+		return data[:b.MaxBodySize], true, body
+	}
+	return data, false, body
+}
+
+func (b *BodyLogger) redactURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	query := u.Query()
+	for k := range query {
+		if _, ok := b.redacted[strings.ToLower(k)]; ok {
+			query.Set(k, redactedValue)
+		}
+	}
+	clean := *u
+	clean.RawQuery = query.Encode()
+	return clean.String()
+}
+
+func (b *BodyLogger) redactHeaders(h http.Header) http.Header {
+	result := http.Header{}
+	for k, v := range h {
+		if _, ok := b.redacted[strings.ToLower(k)]; ok {
+			result[k] = []string{redactedValue}
+			continue
+		}
+		result[k] = v
+	}
+	return result
+}
+
+func (b *BodyLogger) redactBody(body []byte, truncated bool) string {
+	if len(body) == 0 {
+		return ""
+	}
+	suffix := ""
+	if truncated {
+		suffix = fmt.Sprintf("... [truncated at %d bytes]", b.MaxBodySize)
+	}
+
+	var v interface{}
+	if !truncated && json.Unmarshal(body, &v) == nil {
+		if data, err := json.Marshal(b.redactValue(v)); err == nil {
+			return string(data)
+		}
+	}
+
+	body = b.jsonFields.ReplaceAll(body, []byte(`${1}"`+redactedValue+`"`))
+	body = b.formFields.ReplaceAll(body, []byte(`${1}${2}`+redactedValue))
+	return string(body) + suffix
+}
+
+func (b *BodyLogger) redactValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, field := range t {
+			if _, ok := b.redacted[strings.ToLower(k)]; ok {
+				t[k] = redactedValue
+				continue
+			}
+			t[k] = b.redactValue(field)
+		}
+	case []interface{}:
+		for i, item := range t {
+			t[i] = b.redactValue(item)
+		}
+	}
+	return v
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+type capturedBody struct {
+	io.ReadCloser
+	max       int
+	buf       bytes.Buffer
+	truncated bool
+	closed    bool
+	onClose   func([]byte, bool)
+}
+
+func (c *capturedBody) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	if remaining := c.max - c.buf.Len(); remaining > 0 {
+		if n > remaining {
+			c.buf.Write(p[:remaining])
+			c.truncated = true
+		} else {
+			c.buf.Write(p[:n])
+		}
+	} else if n > 0 {
+		c.truncated = true
+	}
+	return n, err
+}
+
+func (c *capturedBody) Close() error {
+	if !c.closed {
+		c.closed = true
+		c.onClose(c.buf.Bytes(), c.truncated)
+	}
+	return c.ReadCloser.Close()
+}
@@ -0,0 +1,115 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestBodyLogger_Logs(t *testing.T) {
+	if !NewBodyLogger(RequestLogging{}).Logs("any") {
+		t.Error("an empty page list should log every page")
+	}
+	b := NewBodyLogger(RequestLogging{Pages: []string{"a"}})
+	if !b.Logs("a") {
+		t.Error("page a should be logged")
+	}
+	if b.Logs("b") {
+		t.Error("page b should not be logged")
+	}
+}
+
+func TestBodyLogger_redactBody(t *testing.T) {
+	b := NewBodyLogger(RequestLogging{RedactedFields: []string{"password", "token"}, MaxBodySize: 40})
+
+	for _, tc := range []struct {
+		body      string
+		truncated bool
+		expected  string
+	}{
+		{
+			body:     `{"user":"foo","password":"bar","nested":[{"Token":"abc"}]}`,
+			expected: `{"nested":[{"Token":"[REDACTED]"}],"password":"[REDACTED]","user":"foo"}`,
+		},
+		{
+			body:      `{"user":"foo","password":"bar","nes`,
+			truncated: true,
+			expected:  `{"user":"foo","password":"[REDACTED]","nes... [truncated at 40 bytes]`,
+		},
+		{
+			body:     `user=foo&password=bar&token=baz`,
+			expected: `user=foo&password=[REDACTED]&token=[REDACTED]`,
+		},
+	} {
+		if res := b.redactBody([]byte(tc.body), tc.truncated); res != tc.expected {
+			t.Errorf("unexpected redacted body. have: %s, want: %s", res, tc.expected)
+		}
+	}
+}
+
+func TestBodyLogger_HandlerFunc(t *testing.T) {
+	buff := &bytes.Buffer{}
+	log.SetOutput(buff)
+	defer log.SetOutput(os.Stderr)
+
+	backendResponse := `{"id":42,"secret":"do not print me","description":"` + strings.Repeat("a", 100) + `"}`
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, backendResponse)
+	}))
+	defer mockServer.Close()
+
+	b := NewBodyLogger(RequestLogging{RedactedFields: []string{"secret"}, MaxBodySize: 50})
+	backend := DefaultClient(mockServer.URL)
+
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.POST("/", b.HandlerFunc("test"), func(c *gin.Context) {
+		body, err := ioutil.ReadAll(c.Request.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		if string(body) != `{"secret":"s3cr3t-body"}` {
+			t.Errorf("unexpected request body: %s", string(body))
+		}
+		resp, err := backend(map[string]string{}, map[string]string{}, c)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		data, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(data) != backendResponse {
+			t.Errorf("unexpected backend response: %s", string(data))
+		}
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/?secret=s3cr3t-query", bytes.NewBufferString(`{"secret":"s3cr3t-body"}`))
+	req.Header.Set("Authorization", "Bearer s3cr3t-header")
+	e.ServeHTTP(w, req)
+
+	if w.Result().StatusCode != http.StatusOK {
+		t.Errorf("unexpected status code: %d", w.Result().StatusCode)
+	}
+
+	logged := buff.String()
+	for _, unexpected := range []string{"s3cr3t-body", "s3cr3t-query", "s3cr3t-header", "do not print me"} {
+		if strings.Contains(logged, unexpected) {
+			t.Errorf("the log contains a redacted value (%s): %s", unexpected, logged)
+		}
+	}
+	for _, expected := range []string{"request logger: test POST", "backend response", `"id":42`, "[truncated at 50 bytes]"} {
+		if !strings.Contains(logged, expected) {
+			t.Errorf("the log does not contain %s: %s", expected, logged)
+		}
+	}
+}
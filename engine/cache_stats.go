@@ -0,0 +1,282 @@
+package engine
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gregjones/httpcache"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// CacheAgeBuckets are the upper bounds, in seconds, of the age distribution of the entries served
+// from the cache
+var CacheAgeBuckets = []float64{1, 5, 15, 30, 60, 300, 900, 3600}
+
+// BackendCacheStats collects the metrics of the cache used by the CachedClient backends
+var BackendCacheStats = NewCacheStats()
+
+// NewCacheStats creates an empty CacheStats
+func NewCacheStats() *CacheStats {
+	return &CacheStats{
+		stored:     map[string]time.Time{},
+		ageBuckets: make([]uint64, len(CacheAgeBuckets)),
+	}
+}
+
+// CacheStats collects the hits, misses, revalidations, stale serves and evictions of a httpcache
+// transport and the age of the entries it serves
+type CacheStats struct {
+	mutex       sync.Mutex
+	hits        uint64
+	misses      uint64
+	revalidated uint64
+	stale       uint64
+	evictions   uint64
+	stored      map[string]time.Time
+	ageBuckets  []uint64
+	ageCount    uint64
+	ageSum      float64
+	caches      []httpcache.Cache
+}
+
+// Transport instruments the received httpcache transport and its cache
+func (s *CacheStats) Transport(t *httpcache.Transport) http.RoundTripper {
+	next := t.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	t.Transport = &originTransport{next}
+	t.Cache = &instrumentedCache{t.Cache, s}
+	t.MarkCachedResponses = true
+	s.mutex.Lock()
+	s.caches = append(s.caches, t.Cache)
+	s.mutex.Unlock()
+	return &cacheTransport{t, s}
+}
+
+// Purge deletes the entries stored through the instrumented transports from their caches
+func (s *CacheStats) Purge() {
+	s.mutex.Lock()
+	keys := make([]string, 0, len(s.stored))
+	for key := range s.stored {
+		keys = append(keys, key)
+	}
+	caches := s.caches
+	s.mutex.Unlock()
+	for _, cache := range caches {
+		for _, key := range keys {
+			cache.Delete(key)
+		}
+	}
+}
+
+// Report returns the collected metrics
+func (s *CacheStats) Report() CacheReport {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	r := CacheReport{
+		Hits:          s.hits,
+		Misses:        s.misses,
+		Revalidated:   s.revalidated,
+		Stale:         s.stale,
+		Evictions:     s.evictions,
+		Entries:       len(s.stored),
+		AgeCount:      s.ageCount,
+		AgeSumSeconds: s.ageSum,
+		AgeBuckets:    make([]CacheAgeBucket, len(CacheAgeBuckets)),
+	}
+	for i, bound := range CacheAgeBuckets {
+		r.AgeBuckets[i] = CacheAgeBucket{UpperBound: bound, Count: s.ageBuckets[i]}
+	}
+	return r
+}
+
+// HandlerFunc returns a gin handler exposing the report as JSON
+func (s *CacheStats) HandlerFunc() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, s.Report())
+	}
+}
+
+func (s *CacheStats) missed() {
+	s.mutex.Lock()
+	s.misses++
+	s.mutex.Unlock()
+}
+
+// served increments the received counter and records the age of the served entry
+func (s *CacheStats) served(counter *uint64, key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	*counter++
+	stored, ok := s.stored[key]
+	if !ok {
+		return
+	}
+	age := time.Since(stored).Seconds()
+	for i, bound := range CacheAgeBuckets {
+		if age <= bound {
+			s.ageBuckets[i]++
+		}
+	}
+	s.ageCount++
+	s.ageSum += age
+}
+
+func (s *CacheStats) set(key string) {
+	s.mutex.Lock()
+	s.stored[key] = time.Now()
+	s.mutex.Unlock()
+}
+
+func (s *CacheStats) delete(key string) {
+	s.mutex.Lock()
+	if _, ok := s.stored[key]; ok {
+		s.evictions++
+		delete(s.stored, key)
+	}
+	s.mutex.Unlock()
+}
+
+// CacheReport contains the metrics of a cache
+type CacheReport struct {
+	Hits          uint64           `json:"hits"`
+	Misses        uint64           `json:"misses"`
+	Revalidated   uint64           `json:"revalidated"`
+	Stale         uint64           `json:"stale"`
+	Evictions     uint64           `json:"evictions"`
+	Entries       int              `json:"entries"`
+	AgeCount      uint64           `json:"age_count"`
+	AgeSumSeconds float64          `json:"age_sum_seconds"`
+	AgeBuckets    []CacheAgeBucket `json:"age_buckets"`
+}
+
+// CacheAgeBucket is the cumulative number of entries served with an age up to the upper bound,
+// in seconds
+type CacheAgeBucket struct {
+	UpperBound float64 `json:"le"`
+	Count      uint64  `json:"count"`
+}
+
+type instrumentedCache struct {
+	httpcache.Cache
+	stats *CacheStats
+}
+
+// Set implements the httpcache.Cache interface
+func (c *instrumentedCache) Set(key string, data []byte) {
+	c.Cache.Set(key, data)
+	c.stats.set(key)
+}
+
+// Delete implements the httpcache.Cache interface
+func (c *instrumentedCache) Delete(key string) {
+	c.Cache.Delete(key)
+	c.stats.delete(key)
+}
+
+type originCallKey struct{}
+
+// originCall records the request sent to the origin (if any) while resolving a cached request
+type originCall struct {
+	sent   bool
+	status int
+}
+
+// cacheTransport classifies the responses of the httpcache transport
+type cacheTransport struct {
+	next  http.RoundTripper
+	stats *CacheStats
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	call := &originCall{}
+	resp, err := t.next.RoundTrip(req.WithContext(context.WithValue(req.Context(), originCallKey{}, call)))
+	if err != nil {
+		return resp, err
+	}
+	// the cache keys of the GET requests are their URLs
+	key := req.URL.String()
+	switch {
+	case resp.Header.Get(httpcache.XFromCache) == "":
+		t.stats.missed()
+	case !call.sent:
+		t.stats.served(&t.stats.hits, key)
+	case call.status == http.StatusNotModified:
+		t.stats.served(&t.stats.revalidated, key)
+	default:
+		// the origin failed and the cached response was served because of its stale-if-error
+		t.stats.served(&t.stats.stale, key)
+	}
+	return resp, nil
+}
+
+// originTransport flags the requests actually sent to the origin
+type originTransport struct {
+	next http.RoundTripper
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (t *originTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if call, ok := req.Context().Value(originCallKey{}).(*originCall); ok {
+		call.sent = true
+		if err == nil {
+			call.status = resp.StatusCode
+		}
+	}
+	return resp, err
+}
+
+// NewCacheCollector creates a prometheus collector exposing the metrics of the received stats
+func NewCacheCollector(namespace string, s *CacheStats) prometheus.Collector {
+	if namespace == "" {
+		namespace = defaultPrometheusNamespace
+	}
+	return &cacheCollector{
+		stats: s,
+		requests: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "backend_cache_requests_total"),
+			"Number of backend requests handled by the cache, by result.", []string{"result"}, nil),
+		evictions: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "backend_cache_evictions_total"),
+			"Number of entries removed from the cache.", nil, nil),
+		entries: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "backend_cache_entries"),
+			"Number of entries in the cache.", nil, nil),
+		age: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "backend_cache_entry_age_seconds"),
+			"Age of the entries served from the cache.", nil, nil),
+	}
+}
+
+type cacheCollector struct {
+	stats     *CacheStats
+	requests  *prometheus.Desc
+	evictions *prometheus.Desc
+	entries   *prometheus.Desc
+	age       *prometheus.Desc
+}
+
+// Describe implements the prometheus.Collector interface
+func (c *cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.requests
+	ch <- c.evictions
+	ch <- c.entries
+	ch <- c.age
+}
+
+// Collect implements the prometheus.Collector interface
+func (c *cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	r := c.stats.Report()
+	for result, v := range map[string]uint64{"hit": r.Hits, "miss": r.Misses, "revalidated": r.Revalidated, "stale": r.Stale} {
+		ch <- prometheus.MustNewConstMetric(c.requests, prometheus.CounterValue, float64(v), result)
+	}
+	ch <- prometheus.MustNewConstMetric(c.evictions, prometheus.CounterValue, float64(r.Evictions))
+	ch <- prometheus.MustNewConstMetric(c.entries, prometheus.GaugeValue, float64(r.Entries))
+	buckets := map[float64]uint64{}
+	for _, b := range r.AgeBuckets {
+		buckets[b.UpperBound] = b.Count
+	}
+	ch <- prometheus.MustNewConstHistogram(c.age, r.AgeCount, r.AgeSumSeconds, buckets)
+}
@@ -0,0 +1,87 @@
+package engine
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gregjones/httpcache"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCacheStats(t *testing.T) {
+	var failing int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/fresh":
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/etag":
+			w.Header().Set("Cache-Control", "max-age=0")
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/stale":
+			w.Header().Set("Cache-Control", "max-age=0, stale-if-error=60")
+			if atomic.LoadInt32(&failing) == 1 {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		case "/gone":
+			if atomic.LoadInt32(&failing) == 1 {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Cache-Control", "max-age=0")
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	s := NewCacheStats()
+	client := &http.Client{Transport: s.Transport(httpcache.NewMemoryCacheTransport())}
+	get := func(path string) {
+		resp, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", path, err.Error())
+			return
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+
+	for _, path := range []string{"/fresh", "/fresh", "/etag", "/etag", "/stale", "/gone"} {
+		get(path)
+	}
+	atomic.StoreInt32(&failing, 1)
+	get("/stale")
+	get("/gone")
+
+	// the not found response replaces the evicted entry
+	r := s.Report()
+	if r.Hits != 1 || r.Revalidated != 1 || r.Stale != 1 || r.Misses != 5 || r.Evictions != 1 || r.Entries != 4 {
+		t.Errorf("unexpected report: %+v", r)
+	}
+	if r.AgeCount != 3 || r.AgeBuckets[0].UpperBound != 1 || r.AgeBuckets[0].Count != 3 {
+		t.Errorf("unexpected age distribution: %+v", r)
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(NewCacheCollector("", s))
+	expected := `
+# HELP api2html_backend_cache_requests_total Number of backend requests handled by the cache, by result.
+# TYPE api2html_backend_cache_requests_total counter
+api2html_backend_cache_requests_total{result="hit"} 1
+api2html_backend_cache_requests_total{result="miss"} 5
+api2html_backend_cache_requests_total{result="revalidated"} 1
+api2html_backend_cache_requests_total{result="stale"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "api2html_backend_cache_requests_total"); err != nil {
+		t.Error(err)
+	}
+}
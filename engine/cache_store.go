@@ -0,0 +1,208 @@
+package engine
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/gregjones/httpcache"
+)
+
+const (
+	// MemoryCacheStore is the in-process store of the backend responses, used by default
+	MemoryCacheStore = "memory"
+	// RedisCacheStore is the store of the backend responses shared by several instances
+	RedisCacheStore = "redis"
+)
+
+// CacheStore stores the cached backend responses. It is compatible with the httpcache.Cache
+// interface
+type CacheStore interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, responseBytes []byte)
+	Delete(key string)
+}
+
+// CacheStoreFactory returns the CacheStore of a page, expiring the entries after the received
+// TTL. A zero TTL keeps them until they are replaced or deleted
+type CacheStoreFactory func(ttl time.Duration) CacheStore
+
+// NewCacheStoreFactory returns the CacheStoreFactory declared by the config
+func NewCacheStoreFactory(cfg BackendCache) (CacheStoreFactory, error) {
+	switch cfg.Store {
+	case "", MemoryCacheStore:
+		return nil, nil
+	case RedisCacheStore:
+		return NewRedisCacheStoreFactory(cfg), nil
+	}
+	return nil, fmt.Errorf("unknown backend cache store: %q", cfg.Store)
+}
+
+// pageClient returns the http client of the backends of the page, signing the requests,
+// authorizing them with an OAuth2 client and retrying the failed ones if the page defines a
+// signing method, an OAuth2 client and a retry policy. The
+// responses of the pages forwarding credentials are not cached, so they can not be leaked to
+// other users
+func pageClient(page Page) *http.Client {
+	client := page.registry.backendClient(page.BackendCacheTTL)
+	if page.Forward.forwardsCredentials() {
+		client = &http.Client{Transport: http.DefaultTransport}
+	}
+	if page.Signing != nil {
+		signer, err := NewRequestSigner(*page.Signing)
+		if err != nil {
+			log.Println(page.Name, err.Error())
+		} else {
+			client = &http.Client{Transport: NewSigningTransport(client.Transport, signer)}
+		}
+	}
+	if page.OAuth2Client != "" {
+		t, err := page.registry.oauth2Transport(client.Transport, page.OAuth2Client)
+		if err != nil {
+			log.Println(page.Name, err.Error())
+		} else {
+			client = &http.Client{Transport: t}
+		}
+	}
+	if page.Retry == nil {
+		return client
+	}
+	return &http.Client{Transport: NewRetryTransport(client.Transport, *page.Retry)}
+}
+
+// backendClient returns the http client of the backends of a page: the shared cached one or,
+// if the registry has a cache store, a client caching the responses into a store with the TTL
+func (r *registry) backendClient(ttl string) *http.Client {
+	if r == nil || r.cacheStore == nil {
+		return &cachedHTTPClient
+	}
+	d, _ := time.ParseDuration(ttl)
+	t := httpcache.NewTransport(r.cacheStore(d))
+	return &http.Client{Transport: BackendCacheStats.Transport(t)}
+}
+
+// NewMemoryCacheStoreFactory returns a CacheStoreFactory creating in-process MemoryStores
+func NewMemoryCacheStoreFactory() CacheStoreFactory {
+	return func(ttl time.Duration) CacheStore {
+		return &MemoryStore{TTL: ttl, entries: map[string]fragmentEntry{}}
+	}
+}
+
+// MemoryStore is a CacheStore keeping the entries in process
+type MemoryStore struct {
+	// TTL is the expiration of the entries. If zero, they do not expire
+	TTL     time.Duration
+	mutex   sync.Mutex
+	entries map[string]fragmentEntry
+}
+
+// Get implements the CacheStore interface
+func (m *MemoryStore) Get(key string) ([]byte, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	e, ok := m.entries[key]
+	if !ok || (!e.expires.IsZero() && time.Now().After(e.expires)) {
+		return nil, false
+	}
+	return e.body, true
+}
+
+// Set implements the CacheStore interface
+func (m *MemoryStore) Set(key string, responseBytes []byte) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	now := time.Now()
+	if len(m.entries) >= staleMaxEntries {
+		for k, e := range m.entries {
+			if !e.expires.IsZero() && now.After(e.expires) {
+				delete(m.entries, k)
+			}
+		}
+	}
+	e := fragmentEntry{body: responseBytes}
+	if m.TTL > 0 {
+		e.expires = now.Add(m.TTL)
+	}
+	m.entries[key] = e
+}
+
+// Delete implements the CacheStore interface
+func (m *MemoryStore) Delete(key string) {
+	m.mutex.Lock()
+	delete(m.entries, key)
+	m.mutex.Unlock()
+}
+
+// NewRedisCacheStoreFactory returns a CacheStoreFactory creating RedisCacheStores sharing a
+// connection pool to the server of the config
+func NewRedisCacheStoreFactory(cfg BackendCache) CacheStoreFactory {
+	pool := &redis.Pool{
+		MaxIdle:     10,
+		IdleTimeout: 4 * time.Minute,
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial(
+				"tcp",
+				cfg.Address,
+				redis.DialPassword(cfg.Password),
+				redis.DialDatabase(cfg.DB),
+			)
+		},
+	}
+	prefix := cfg.Prefix
+	if prefix == "" {
+		prefix = "api2html:"
+	}
+	return func(ttl time.Duration) CacheStore {
+		return &RedisStore{Pool: pool, Prefix: prefix, TTL: ttl}
+	}
+}
+
+// RedisStore is a CacheStore keeping the entries in a redis server
+type RedisStore struct {
+	Pool *redis.Pool
+	// Prefix is added to the keys of the entries
+	Prefix string
+	// TTL is the expiration of the entries. If zero, they do not expire
+	TTL time.Duration
+}
+
+// Get implements the CacheStore interface
+func (r *RedisStore) Get(key string) ([]byte, bool) {
+	conn := r.Pool.Get()
+	defer conn.Close()
+	data, err := redis.Bytes(conn.Do("GET", r.Prefix+key))
+	if err != nil {
+		if err != redis.ErrNil {
+			log.Println("reading the backend cache:", err.Error())
+		}
+		return nil, false
+	}
+	return data, true
+}
+
+// Set implements the CacheStore interface
+func (r *RedisStore) Set(key string, responseBytes []byte) {
+	conn := r.Pool.Get()
+	defer conn.Close()
+	var err error
+	if r.TTL > 0 {
+		_, err = conn.Do("SET", r.Prefix+key, responseBytes, "PX", r.TTL.Milliseconds())
+	} else {
+		_, err = conn.Do("SET", r.Prefix+key, responseBytes)
+	}
+	if err != nil {
+		log.Println("writing the backend cache:", err.Error())
+	}
+}
+
+// Delete implements the CacheStore interface
+func (r *RedisStore) Delete(key string) {
+	conn := r.Pool.Get()
+	defer conn.Close()
+	if _, err := conn.Do("DEL", r.Prefix+key); err != nil {
+		log.Println("deleting from the backend cache:", err.Error())
+	}
+}
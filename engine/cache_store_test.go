@@ -0,0 +1,108 @@
+package engine
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type mapCacheStore struct {
+	mutex sync.Mutex
+	data  map[string][]byte
+	ttl   time.Duration
+}
+
+func (m *mapCacheStore) Get(key string) ([]byte, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	v, ok := m.data[key]
+	return v, ok
+}
+
+func (m *mapCacheStore) Set(key string, value []byte) {
+	m.mutex.Lock()
+	m.data[key] = value
+	m.mutex.Unlock()
+}
+
+func (m *mapCacheStore) Delete(key string) {
+	m.mutex.Lock()
+	delete(m.data, key)
+	m.mutex.Unlock()
+}
+
+func TestRegistry_backendClient(t *testing.T) {
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls++
+		w.Header().Set("Cache-Control", "public, max-age=60")
+		fmt.Fprint(w, `{"a":1}`)
+	}))
+	defer ts.Close()
+
+	store := &mapCacheStore{data: map[string][]byte{}}
+	reg := newRegistry()
+	reg.cacheStore = func(ttl time.Duration) CacheStore {
+		store.ttl = ttl
+		return store
+	}
+
+	client := reg.backendClient("5m")
+	if store.ttl != 5*time.Minute {
+		t.Errorf("unexpected ttl: %s", store.ttl)
+	}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(ts.URL)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	if calls != 1 {
+		t.Errorf("unexpected number of backend calls: %d", calls)
+	}
+	if len(store.data) != 1 {
+		t.Errorf("unexpected number of entries: %d", len(store.data))
+	}
+
+	for _, r := range []*registry{nil, newRegistry()} {
+		if r.backendClient("5m") != &cachedHTTPClient {
+			t.Error("expecting the in-process cached client")
+		}
+	}
+}
+
+func TestNewCacheStoreFactory(t *testing.T) {
+	if f, err := NewCacheStoreFactory(BackendCache{Store: MemoryCacheStore}); err != nil || f != nil {
+		t.Errorf("unexpected result: %v", err)
+	}
+	if f, err := NewCacheStoreFactory(BackendCache{Store: RedisCacheStore, Address: "localhost:6379"}); err != nil || f == nil {
+		t.Errorf("unexpected result: %v", err)
+	}
+	if _, err := NewCacheStoreFactory(BackendCache{Store: "memcached"}); err == nil {
+		t.Error("expecting an error")
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	s := NewMemoryCacheStoreFactory()(20 * time.Millisecond)
+	s.Set("a", []byte("1"))
+	if v, ok := s.Get("a"); !ok || string(v) != "1" {
+		t.Errorf("unexpected value: %s", v)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, ok := s.Get("a"); ok {
+		t.Error("unexpected expired value")
+	}
+	s.Set("b", []byte("2"))
+	s.Delete("b")
+	if _, ok := s.Get("b"); ok {
+		t.Error("unexpected deleted value")
+	}
+}
@@ -0,0 +1,154 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	canaryKey           = "api2html_canary"
+	defaultCanaryCookie = "api2html_canary"
+	canaryCookieMaxAge  = 30 * 24 * 60 * 60
+)
+
+// ErrNoCanary is the error returned when promoting or aborting a template without a canary
+var ErrNoCanary = fmt.Errorf("no canary in progress")
+
+// CanaryMiddleware assigns a sticky bucket (from 0 to 99) to every visitor, stored in the
+// cookie with the received name, so the canary renderers can split the traffic
+func CanaryMiddleware(cookie string) gin.HandlerFunc {
+	if cookie == "" {
+		cookie = defaultCanaryCookie
+	}
+	return func(c *gin.Context) {
+		value, err := c.Cookie(cookie)
+		bucket, perr := strconv.Atoi(value)
+		if err != nil || perr != nil || bucket < 0 || bucket > 99 {
+			bucket = rand.Intn(100)
+			c.SetCookie(cookie, strconv.Itoa(bucket), canaryCookieMaxAge, "/", "", false, true)
+		}
+		c.Set(canaryKey, bucket)
+		c.Next()
+	}
+}
+
+// CanaryBucketFromContext returns the bucket assigned by the CanaryMiddleware, or -1 if none
+func CanaryBucketFromContext(c *gin.Context) int {
+	if c == nil {
+		return -1
+	}
+	v, ok := c.Get(canaryKey)
+	if !ok {
+		return -1
+	}
+	bucket, ok := v.(int)
+	if !ok {
+		return -1
+	}
+	return bucket
+}
+
+// NewCanaryRenderer creates a CanaryRenderer sending the received percentage of the traffic to
+// the canary renderer
+func NewCanaryRenderer(stable, canary Renderer, percentage int) *CanaryRenderer {
+	return &CanaryRenderer{
+		Stable:     stable,
+		Canary:     canary,
+		Percentage: percentage,
+		stable:     &canaryStats{},
+		canary:     &canaryStats{},
+	}
+}
+
+// CanaryRenderer splits the traffic between the previous version of a renderer and a new
+// one, measuring the errors and the latency of both of them
+type CanaryRenderer struct {
+	Stable     Renderer
+	Canary     Renderer
+	Percentage int
+	stable     *canaryStats
+	canary     *canaryStats
+}
+
+// Render implements the Renderer interface with the stable renderer
+func (r *CanaryRenderer) Render(w io.Writer, v interface{}) error {
+	return r.Stable.Render(w, v)
+}
+
+// Select returns the renderer for the bucket of the visitor. The visitors without bucket get
+// the stable one
+func (r *CanaryRenderer) Select(c *gin.Context) Renderer {
+	if bucket := CanaryBucketFromContext(c); bucket >= 0 && bucket < r.Percentage {
+		return measuredRenderer{r.Canary, r.canary}
+	}
+	return measuredRenderer{r.Stable, r.stable}
+}
+
+// Report returns the metrics collected for both versions
+func (r *CanaryRenderer) Report() CanaryReport {
+	return CanaryReport{
+		Percentage: r.Percentage,
+		Stable:     r.stable.stats(),
+		Canary:     r.canary.stats(),
+	}
+}
+
+// CanaryReport contains the metrics of a canary rollout
+type CanaryReport struct {
+	Percentage int         `json:"percentage"`
+	Stable     CanaryStats `json:"stable"`
+	Canary     CanaryStats `json:"canary"`
+}
+
+// CanaryStats contains the metrics of a version of a renderer
+type CanaryStats struct {
+	Requests           int64   `json:"requests"`
+	Errors             int64   `json:"errors"`
+	MeanLatencySeconds float64 `json:"mean_latency_seconds"`
+}
+
+type canaryStats struct {
+	mutex    sync.Mutex
+	requests int64
+	errors   int64
+	latency  time.Duration
+}
+
+func (s *canaryStats) observe(latency time.Duration, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.requests++
+	s.latency += latency
+	if err != nil {
+		s.errors++
+	}
+}
+
+func (s *canaryStats) stats() CanaryStats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	res := CanaryStats{Requests: s.requests, Errors: s.errors}
+	if s.requests > 0 {
+		res.MeanLatencySeconds = s.latency.Seconds() / float64(s.requests)
+	}
+	return res
+}
+
+type measuredRenderer struct {
+	Renderer
+	stats *canaryStats
+}
+
+// Render implements the Renderer interface
+func (m measuredRenderer) Render(w io.Writer, v interface{}) error {
+	start := time.Now()
+	err := m.Renderer.Render(w, v)
+	m.stats.observe(time.Since(start), err)
+	return err
+}
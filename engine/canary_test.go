@@ -0,0 +1,162 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCanaryMiddleware(t *testing.T) {
+	e := gin.New()
+	e.Use(CanaryMiddleware(""))
+	e.GET("/", func(c *gin.Context) {
+		c.String(http.StatusOK, "%d", CanaryBucketFromContext(c))
+	})
+
+	for _, tc := range []struct {
+		cookie    string
+		body      string
+		newCookie bool
+	}{
+		{cookie: "", newCookie: true},
+		{cookie: "42", body: "42"},
+		{cookie: "0", body: "0"},
+		{cookie: "100", newCookie: true},
+		{cookie: "nope", newCookie: true},
+	} {
+		req, _ := http.NewRequest("GET", "/", nil)
+		if tc.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: defaultCanaryCookie, Value: tc.cookie})
+		}
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+
+		cookies := w.Result().Cookies()
+		if !tc.newCookie {
+			if len(cookies) != 0 || w.Body.String() != tc.body {
+				t.Errorf("%s: unexpected response: %s %v", tc.cookie, w.Body.String(), cookies)
+			}
+			continue
+		}
+		if len(cookies) != 1 || cookies[0].Name != defaultCanaryCookie || cookies[0].Value != w.Body.String() {
+			t.Errorf("%s: unexpected cookies: %v", tc.cookie, cookies)
+		}
+	}
+
+	if bucket := CanaryBucketFromContext(nil); bucket != -1 {
+		t.Errorf("unexpected bucket: %d", bucket)
+	}
+}
+
+func TestCanaryRenderer(t *testing.T) {
+	stable := RendererFunc(func(w io.Writer, _ interface{}) error {
+		_, err := io.WriteString(w, "stable")
+		return err
+	})
+	canary := RendererFunc(func(w io.Writer, _ interface{}) error {
+		return fmt.Errorf("boom")
+	})
+	r := NewCanaryRenderer(stable, canary, 20)
+
+	for _, bucket := range []int{0, 19, 20, 99, -1} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		if bucket >= 0 {
+			c.Set(canaryKey, bucket)
+		}
+		r.Select(c).Render(&bytes.Buffer{}, nil)
+	}
+
+	report := r.Report()
+	if report.Percentage != 20 || report.Stable.Requests != 3 || report.Stable.Errors != 0 ||
+		report.Canary.Requests != 2 || report.Canary.Errors != 2 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := r.Render(buf, nil); err != nil || buf.String() != "stable" {
+		t.Errorf("unexpected render: %s %v", buf.String(), err)
+	}
+}
+
+func TestTemplateGraph_canary(t *testing.T) {
+	g, _ := newTestTemplateGraph(t)
+	g.CanaryPercentage = 10
+	store := NewTemplateStore()
+
+	if err := g.PromoteCanary(store, "main"); err != ErrNoCanary {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	for _, layout := range []string{`[{{{ content }}}]`, `({{{ content }}})`, `<{{{ content }}}>`} {
+		r, err := NewMustacheRenderer(bytes.NewBufferString(layout))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := g.Publish(store, "main", r); err != nil {
+			t.Errorf("unexpected error: %s", err.Error())
+			return
+		}
+	}
+
+	reports := g.Canaries(store, "main")
+	if len(reports) != 3 {
+		t.Errorf("unexpected reports: %v", reports)
+	}
+	assertCanary(t, store, "main-:-about", "[about]", "<about>")
+
+	if err := g.AbortCanary(store, "main"); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+	assertRender(t, store, "main-:-about", "[about]")
+
+	r, _ := NewMustacheRenderer(bytes.NewBufferString(`{{{ content }}}!`))
+	g.Publish(store, "main", r)
+	if err := g.PromoteCanary(store, "main"); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+	assertRender(t, store, "main-:-about", "about!")
+	if reports := g.Canaries(store, "main"); len(reports) != 0 {
+		t.Errorf("unexpected reports: %v", reports)
+	}
+}
+
+func assertCanary(t *testing.T, store *TemplateStore, topic, stable, canary string) {
+	r, ok := store.Get(topic)
+	if !ok {
+		t.Errorf("%s not found", topic)
+		return
+	}
+	c, ok := r.(*CanaryRenderer)
+	if !ok {
+		t.Errorf("%s: unexpected renderer: %T", topic, r)
+		return
+	}
+	for i, renderer := range []Renderer{c.Stable, c.Canary} {
+		buf := &bytes.Buffer{}
+		renderer.Render(buf, nil)
+		if expected := []string{stable, canary}[i]; buf.String() != expected {
+			t.Errorf("%s: unexpected render: %s", topic, buf.String())
+		}
+	}
+}
+
+func assertRender(t *testing.T, store *TemplateStore, topic, expected string) {
+	r, ok := store.Get(topic)
+	if !ok {
+		t.Errorf("%s not found", topic)
+		return
+	}
+	if _, ok := r.(*CanaryRenderer); ok {
+		t.Errorf("%s: unexpected canary", topic)
+	}
+	buf := &bytes.Buffer{}
+	r.Render(buf, nil)
+	if buf.String() != expected {
+		t.Errorf("%s: unexpected render: %s", topic, buf.String())
+	}
+}
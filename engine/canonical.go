@@ -0,0 +1,124 @@
+package engine
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// TrailingSlashStrip removes the trailing slash of the URL patterns, so /foo/ redirects to /foo
+	TrailingSlashStrip = "strip"
+	// TrailingSlashAdd adds a trailing slash to the URL patterns, so /foo redirects to /foo/
+	TrailingSlashAdd = "add"
+
+	canonicalURLKey = "api2html-canonical-url"
+)
+
+// validate returns an error if the trailing slash policy is unknown
+func (cu *CanonicalURL) validate() error {
+	if cu == nil {
+		return nil
+	}
+	switch cu.TrailingSlash {
+	case "", TrailingSlashStrip, TrailingSlashAdd:
+		return nil
+	}
+	return fmt.Errorf("unknown trailing slash policy %q, use %s or %s", cu.TrailingSlash, TrailingSlashStrip, TrailingSlashAdd)
+}
+
+// routePattern returns the URL pattern with the trailing slash of the policy. The router
+// redirects the requests with the other form to it
+func (cu *CanonicalURL) routePattern(pattern string) string {
+	if cu == nil || cu.Disabled || pattern == "/" || pattern == "" {
+		return pattern
+	}
+	switch cu.TrailingSlash {
+	case TrailingSlashStrip:
+		return strings.TrimSuffix(pattern, "/")
+	case TrailingSlashAdd:
+		last := pattern[strings.LastIndex(pattern, "/")+1:]
+		if last != "" && !strings.HasPrefix(last, "*") {
+			return pattern + "/"
+		}
+	}
+	return pattern
+}
+
+// handlerFunc returns a middleware redirecting the requests with upper case letters in the path
+// or with the stripped query params to their canonical URL, and exposing the canonical URL of
+// the rest of them to the templates. It returns nil if the canonical URLs are disabled
+func (cu *CanonicalURL) handlerFunc() gin.HandlerFunc {
+	if cu == nil || cu.Disabled {
+		return nil
+	}
+	return func(c *gin.Context) {
+		u := *c.Request.URL
+		changed := false
+		if cu.Lowercase {
+			if lower := strings.ToLower(u.Path); lower != u.Path {
+				u.Path, u.RawPath = lower, ""
+				changed = true
+			}
+		}
+		if len(cu.StripParams) > 0 && u.RawQuery != "" {
+			query := u.Query()
+			for name := range query {
+				if cu.strips(name) {
+					query.Del(name)
+					changed = true
+				}
+			}
+			if changed {
+				u.RawQuery = query.Encode()
+			}
+		}
+		if changed {
+			if strings.HasPrefix(u.Path, "//") {
+				// the browsers take //host/path as a protocol relative URL to another host
+				u.Path, u.RawPath = "/"+strings.TrimLeft(u.Path, "/"), ""
+			}
+			c.Redirect(http.StatusMovedPermanently, u.RequestURI())
+			c.Abort()
+			return
+		}
+		c.Set(canonicalURLKey, cu.canonicalURL(c.Request))
+	}
+}
+
+// strips returns true if the query param is one of the stripped ones. The names ending with an
+// asterisk are prefixes (utm_*)
+func (cu *CanonicalURL) strips(name string) bool {
+	for _, p := range cu.StripParams {
+		if strings.HasSuffix(p, "*") && strings.HasPrefix(name, p[:len(p)-1]) || p == name {
+			return true
+		}
+	}
+	return false
+}
+
+// canonicalURL returns the absolute URL of the request with the base URL of the config or, if
+// it is empty, with the scheme and the host of the request
+func (cu *CanonicalURL) canonicalURL(r *http.Request) string {
+	base := strings.TrimSuffix(cu.BaseURL, "/")
+	if base == "" {
+		scheme := "http"
+		if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+		base = scheme + "://" + r.Host
+	}
+	canonical := base + r.URL.EscapedPath()
+	if r.URL.RawQuery != "" {
+		canonical += "?" + r.URL.RawQuery
+	}
+	return canonical
+}
+
+// CanonicalURLFromContext returns the canonical URL of the request, if the canonical URLs are
+// enabled for its page
+func CanonicalURLFromContext(c *gin.Context) string {
+	return c.GetString(canonicalURLKey)
+}
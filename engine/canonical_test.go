@@ -0,0 +1,100 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCanonicalURL_routePattern(t *testing.T) {
+	strip := &CanonicalURL{TrailingSlash: TrailingSlashStrip}
+	add := &CanonicalURL{TrailingSlash: TrailingSlashAdd}
+	for _, tc := range []struct {
+		canonical         *CanonicalURL
+		pattern, expected string
+	}{
+		{nil, "/foo/", "/foo/"},
+		{&CanonicalURL{}, "/foo/", "/foo/"},
+		{&CanonicalURL{TrailingSlash: TrailingSlashStrip, Disabled: true}, "/foo/", "/foo/"},
+		{strip, "/foo/", "/foo"},
+		{strip, "/foo", "/foo"},
+		{strip, "/", "/"},
+		{add, "/foo", "/foo/"},
+		{add, "/foo/:id", "/foo/:id/"},
+		{add, "/foo/", "/foo/"},
+		{add, "/files/*path", "/files/*path"},
+		{add, "/", "/"},
+	} {
+		if pattern := tc.canonical.routePattern(tc.pattern); pattern != tc.expected {
+			t.Errorf("%+v %s: unexpected pattern %s", tc.canonical, tc.pattern, pattern)
+		}
+	}
+
+	if err := (&CanonicalURL{TrailingSlash: "remove"}).validate(); err == nil {
+		t.Error("expecting an error")
+	}
+	if err := add.validate(); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+}
+
+func TestCanonicalURL_handlerFunc(t *testing.T) {
+	if (*CanonicalURL)(nil).handlerFunc() != nil || (&CanonicalURL{Disabled: true}).handlerFunc() != nil {
+		t.Error("unexpected middleware")
+	}
+
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	canonical := &CanonicalURL{Lowercase: true, StripParams: []string{"utm_*", "fbclid"}}
+	e.GET("/products/:id", canonical.handlerFunc(), func(c *gin.Context) {
+		c.String(http.StatusOK, "%v", requestExtra(c, map[string]interface{}{"a": 1})["canonical_url"])
+	})
+
+	for _, tc := range []struct {
+		url, proto string
+		status     int
+		location   string
+		body       string
+	}{
+		{"/products/ABC?page=2", "", http.StatusMovedPermanently, "/products/abc?page=2", ""},
+		{"/products/abc?utm_source=news&utm_medium=email&fbclid=x&page=2", "", http.StatusMovedPermanently, "/products/abc?page=2", ""},
+		{"/products/abc?fbclid=x", "", http.StatusMovedPermanently, "/products/abc", ""},
+		{"/products/abc?page=2", "", http.StatusOK, "", "http://example.com/products/abc?page=2"},
+		{"/products/abc", "https", http.StatusOK, "", "https://example.com/products/abc"},
+	} {
+		req := httptest.NewRequest("GET", "http://example.com"+tc.url, nil)
+		if tc.proto != "" {
+			req.Header.Set("X-Forwarded-Proto", tc.proto)
+		}
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		if w.Code != tc.status || w.Header().Get("Location") != tc.location {
+			t.Errorf("%s: unexpected response: %d %s", tc.url, w.Code, w.Header().Get("Location"))
+		}
+		if tc.body != "" && w.Body.String() != tc.body {
+			t.Errorf("%s: unexpected canonical URL: %s", tc.url, w.Body.String())
+		}
+	}
+
+	canonical.BaseURL = "https://www.example.com/"
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/products/abc", nil))
+	if w.Body.String() != "https://www.example.com/products/abc" {
+		t.Errorf("unexpected canonical URL: %s", w.Body.String())
+	}
+
+	// the redirections never point to another host
+	e = gin.New()
+	e.GET("/*path", canonical.handlerFunc(), func(c *gin.Context) {})
+	for _, u := range []string{"//EVIL.com/x", "///EVIL.com", "//evil.com/?utm_source=x"} {
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, httptest.NewRequest("GET", "http://example.com"+u, nil))
+		location := w.Header().Get("Location")
+		if w.Code != http.StatusMovedPermanently || strings.HasPrefix(location, "//") {
+			t.Errorf("%s: unexpected response: %d %s", u, w.Code, location)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package engine
+
+import (
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// backendParamRegexp matches the params of the backend URL patterns, skipping the ports
+var backendParamRegexp = regexp.MustCompile(`:([A-Za-z_]\w*)`)
+
+// CheckConfig returns all the problems of the config found without starting the engine: the
+// declared templates and layouts that can not be read or parsed, the pages referring to
+// undeclared ones, the URL patterns colliding with the ones of other pages, the durations that
+// do not parse, the invalid params of the URL patterns and the params of the backend URL
+// patterns missing from the URL pattern of their pages. The files are read from the fs.FS or,
+// if it is nil, from the local filesystem
+func CheckConfig(fsys fs.FS, cfg Config) []error {
+	errs := checkTemplates(fsys, cfg)
+	pages := make([]Page, 0, len(cfg.Pages))
+	for _, page := range cfg.Pages {
+		route, err := ParseRoutePattern(page.URLPattern)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("page %s: invalid URL pattern %s: %s", page.Name, page.URLPattern, err.Error()))
+			continue
+		}
+		page.URLPattern, page.route = route.Path, route
+		pages = append(pages, page)
+		for _, err := range checkPage(cfg, page) {
+			errs = append(errs, fmt.Errorf("page %s: %s", page.Name, err.Error()))
+		}
+	}
+	return append(errs, checkURLPatterns(pages)...)
+}
+
+// checkTemplates parses every declared template and layout with the engine of its pages
+func checkTemplates(fsys fs.FS, cfg Config) []error {
+	goNames := goTemplateNames(cfg)
+	errs := []error{}
+	for _, section := range []struct {
+		kind  string
+		files map[string]string
+	}{
+		{"template", cfg.Templates},
+		{"layout", cfg.Layouts},
+	} {
+		for _, name := range sortedKeys(section.files) {
+			path := section.files[name]
+			f, err := openFile(fsys, path)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s %s: %s", section.kind, name, err.Error()))
+				continue
+			}
+			if goNames[name] {
+				_, err = NewGoTemplateRenderer(f)
+			} else {
+				_, err = NewMustacheRendererFS(fsys, f)
+			}
+			f.Close()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s %s: parsing %s: %s", section.kind, name, path, err.Error()))
+			}
+		}
+	}
+	return errs
+}
+
+// checkPage returns the problems of the templates, the durations and the backend URL patterns
+// of the page
+func checkPage(cfg Config, page Page) []error {
+	errs := []error{}
+	if _, ok := cfg.Templates[page.Template]; !ok && page.Renderer == "" {
+		errs = append(errs, fmt.Errorf("unknown template %q, declare it in the templates section", page.Template))
+	}
+	if _, ok := cfg.Layouts[page.Layout]; page.Layout != "" && !ok {
+		errs = append(errs, fmt.Errorf("unknown layout %q, declare it in the layouts section", page.Layout))
+	}
+	if err := validErrorTemplates(page.ErrorTemplates, cfg.Templates); err != nil {
+		errs = append(errs, err)
+	}
+
+	durations := map[string]string{
+		"CacheTTL":          page.CacheTTL,
+		"backend_cache_ttl": page.BackendCacheTTL,
+		"stale_ttl":         page.StaleTTL,
+		"budget":            page.Budget,
+	}
+	if page.Concurrency != nil {
+		durations["concurrency.queue_timeout"] = page.Concurrency.QueueTimeout
+	}
+	for name, backend := range page.Backends {
+		durations["backends."+name+".timeout"] = backend.Timeout
+	}
+	for _, field := range sortedKeys(durations) {
+		if v := durations[field]; v != "" {
+			if _, err := time.ParseDuration(v); err != nil {
+				errs = append(errs, fmt.Errorf("invalid %s %q, use a duration like 30s or 5m", field, v))
+			}
+		}
+	}
+
+	params := map[string]bool{}
+	for _, segment := range strings.Split(page.URLPattern, "/") {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			params[segment[1:]] = true
+		}
+	}
+	for _, re := range page.route.Constraints {
+		for _, group := range re.SubexpNames() {
+			if group != "" {
+				params[group] = true
+			}
+		}
+	}
+	if cfg.I18n != nil {
+		params[LangParam] = true
+	}
+	patterns := map[string]string{"BackendURLPattern": page.BackendURLPattern}
+	for name, backend := range page.Backends {
+		patterns["backends."+name+".url_pattern"] = backend.URLPattern
+	}
+	for _, field := range sortedKeys(patterns) {
+		for _, m := range backendParamRegexp.FindAllStringSubmatch(patterns[field], -1) {
+			if !params[m[1]] {
+				errs = append(errs, fmt.Errorf("the %s uses the param :%s missing from the URL pattern %s", field, m[1], page.URLPattern))
+			}
+		}
+	}
+	return errs
+}
+
+// checkURLPatterns returns an error for every pair of pages with URL patterns the router can
+// not register together: the same path, the same prefix followed by different wildcards or by
+// a wildcard and a static segment, or a catch-all sharing its prefix with another path
+func checkURLPatterns(pages []Page) []error {
+	errs := []error{}
+	for i, a := range pages {
+		for _, b := range pages[i+1:] {
+			// the pages of different hosts share the route of their URL pattern
+			if a.URLPattern == b.URLPattern && !strings.EqualFold(a.Host, b.Host) {
+				continue
+			}
+			if urlPatternsCollide(a.URLPattern, b.URLPattern) {
+				errs = append(errs, fmt.Errorf("pages %s and %s: the URL patterns %s and %s collide", a.Name, b.Name, a.URLPattern, b.URLPattern))
+			}
+		}
+	}
+	return errs
+}
+
+func urlPatternsCollide(a, b string) bool {
+	sa, sb := strings.Split(a, "/"), strings.Split(b, "/")
+	for i := 0; i < len(sa) && i < len(sb); i++ {
+		if sa[i] == sb[i] {
+			continue
+		}
+		if strings.HasPrefix(sa[i], "*") || strings.HasPrefix(sb[i], "*") {
+			return true
+		}
+		// the path ending with a slash can share its prefix with a param (/products/:id)
+		return sa[i] != "" && sb[i] != "" && (isWildcardSegment(sa[i]) || isWildcardSegment(sb[i]))
+	}
+	return len(sa) == len(sb)
+}
+
+func isWildcardSegment(segment string) bool {
+	return strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*")
+}
+
+// sortedKeys returns the keys of the map in order, so the problems are reported in a stable way
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,135 @@
+package engine
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestCheckConfig(t *testing.T) {
+	fsys := fstest.MapFS{
+		"home.mustache":   {Data: []byte("<h1>{{Extra.title}}</h1>")},
+		"broken.mustache": {Data: []byte("{{#section}}")},
+		"layout.mustache": {Data: []byte("<body>{{{content}}}</body>")},
+		"page.tmpl":       {Data: []byte(`{{ if }}`)},
+	}
+	cfg := Config{
+		Templates: map[string]string{
+			"home":    "home.mustache",
+			"broken":  "broken.mustache",
+			"go":      "page.tmpl",
+			"missing": "missing.mustache",
+		},
+		Layouts: map[string]string{"main": "layout.mustache"},
+		Pages: []Page{
+			{
+				Name:              "home",
+				URLPattern:        "/",
+				BackendURLPattern: "http://api.example.com:8080/home",
+				Template:          "home",
+				Layout:            "main",
+				CacheTTL:          "3600s",
+			},
+			{
+				Name:              "product",
+				URLPattern:        "/products/:id",
+				BackendURLPattern: "http://api.example.com/products/:id",
+				Template:          "home",
+				Layout:            "main",
+			},
+			{
+				Name:              "reviews",
+				URLPattern:        "/products/:slug/reviews",
+				BackendURLPattern: "http://api.example.com/reviews/:product",
+				Template:          "home",
+				Layout:            "unknown",
+				CacheTTL:          "1h",
+				StaleTTL:          "forever",
+				Backends:          map[string]PageBackend{"user": {URLPattern: "http://api.example.com/users/:user", Timeout: "1s"}},
+			},
+			{Name: "go", URLPattern: "/go", Template: "go", Engine: GoTemplateEngine},
+			{Name: "undeclared", URLPattern: "/undeclared", Template: "unknown"},
+		},
+	}
+
+	expected := []string{
+		"template broken: parsing broken.mustache: line 1: Section section has no closing tag",
+		"template go: parsing page.tmpl: template: api2html:1: missing value for if",
+		"template missing: open missing.mustache: file does not exist",
+		"page reviews: unknown layout \"unknown\", declare it in the layouts section",
+		"page reviews: invalid stale_ttl \"forever\", use a duration like 30s or 5m",
+		"page reviews: the BackendURLPattern uses the param :product missing from the URL pattern /products/:slug/reviews",
+		"page reviews: the backends.user.url_pattern uses the param :user missing from the URL pattern /products/:slug/reviews",
+		"page undeclared: unknown template \"unknown\", declare it in the templates section",
+		"pages product and reviews: the URL patterns /products/:id and /products/:slug/reviews collide",
+	}
+	errs := CheckConfig(fsys, cfg)
+	if len(errs) != len(expected) {
+		t.Errorf("unexpected number of problems: %d %v", len(errs), errs)
+		return
+	}
+	for i, err := range errs {
+		if err.Error() != expected[i] {
+			t.Errorf("unexpected problem #%d: %s", i, err.Error())
+		}
+	}
+}
+
+func Test_urlPatternsCollide(t *testing.T) {
+	for _, tc := range []struct {
+		a, b    string
+		collide bool
+	}{
+		{"/", "/:id", false},
+		{"/products/", "/products/:id", false},
+		{"/products/:id", "/products/:id/reviews", false},
+		{"/products/:id", "/users/:id", false},
+		{"/products/:id", "/products/:id", true},
+		{"/products/:id", "/products/:slug", true},
+		{"/products/new", "/products/:id", true},
+		{"/files/*path", "/files/", true},
+	} {
+		if collide := urlPatternsCollide(tc.a, tc.b); collide != tc.collide {
+			t.Errorf("%s %s: unexpected result %v", tc.a, tc.b, collide)
+		}
+	}
+}
+
+func Test_checkURLPatterns_hosts(t *testing.T) {
+	errs := checkURLPatterns([]Page{
+		{Name: "home", URLPattern: "/"},
+		{Name: "blog", URLPattern: "/", Host: "blog.example.com"},
+		{Name: "shop", URLPattern: "/", Host: "shop.example.com"},
+		{Name: "other-shop", URLPattern: "/", Host: "Shop.example.com"},
+		{Name: "product", URLPattern: "/:id", Host: "shop.example.com"},
+	})
+	if len(errs) != 1 || errs[0].Error() != "pages shop and other-shop: the URL patterns / and / collide" {
+		t.Errorf("unexpected problems: %v", errs)
+	}
+}
+
+func TestCheckConfig_routePatterns(t *testing.T) {
+	cfg := Config{
+		Templates: map[string]string{"post": "post.mustache"},
+		Pages: []Page{
+			{Name: "post", URLPattern: `/blog/{slug:(?P<year>\d{4})-.+}`, BackendURLPattern: "http://example.com/:year/:slug/:lang", Template: "post"},
+			{Name: "tag", URLPattern: "/blog/{tag:[a-z}", Template: "post"},
+			{Name: "archive", URLPattern: "/blog/{year:[0-9]+}", Template: "post"},
+		},
+	}
+	fsys := fstest.MapFS{"post.mustache": {Data: []byte("{{Data.title}}")}}
+	expected := []string{
+		"page post: the BackendURLPattern uses the param :lang missing from the URL pattern /blog/:slug",
+		"page tag: invalid URL pattern /blog/{tag:[a-z}: param tag: error parsing regexp: missing closing ]: `[a-z)$`",
+		"pages post and archive: the URL patterns /blog/:slug and /blog/:year collide",
+	}
+	errs := CheckConfig(fsys, cfg)
+	if len(errs) != len(expected) {
+		t.Errorf("unexpected problems: %v", errs)
+		return
+	}
+	for i, err := range errs {
+		if err.Error() != expected[i] {
+			t.Errorf("unexpected problem #%d: %s", i, err.Error())
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NewConcurrencyLimiter creates a ConcurrencyLimiter with the received config
+func NewConcurrencyLimiter(cfg PageConcurrency) *ConcurrencyLimiter {
+	queueTimeout := time.Duration(0)
+	if cfg.QueueTimeout != "" {
+		d, err := time.ParseDuration(cfg.QueueTimeout)
+		if err != nil {
+			log.Println("parsing the queue timeout:", err.Error())
+		} else {
+			queueTimeout = d
+		}
+	}
+	return &ConcurrencyLimiter{
+		QueueTimeout: queueTimeout,
+		slots:        make(chan struct{}, cfg.MaxInFlight),
+	}
+}
+
+// ConcurrencyLimiter caps the number of requests of a page processed at the same time, so an
+// expensive page can not starve the rest of the site. The excess requests wait for a free slot
+// during the QueueTimeout and get a 503 response if none is released
+type ConcurrencyLimiter struct {
+	QueueTimeout time.Duration
+	slots        chan struct{}
+}
+
+// HandlerFunc returns a gin middleware holding a slot while the rest of the handlers run
+func (l *ConcurrencyLimiter) HandlerFunc() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !l.acquire(c) {
+			shed(c)
+			return
+		}
+		defer l.release()
+		c.Next()
+	}
+}
+
+func (l *ConcurrencyLimiter) acquire(c *gin.Context) bool {
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+	}
+	if l.QueueTimeout <= 0 {
+		return false
+	}
+	timer := time.NewTimer(l.QueueTimeout)
+	defer timer.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-c.Request.Context().Done():
+		return false
+	}
+}
+
+func (l *ConcurrencyLimiter) release() {
+	<-l.slots
+}
@@ -0,0 +1,64 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestConcurrencyLimiter(t *testing.T) {
+	for _, tc := range []struct {
+		queueTimeout string
+		status       int
+	}{
+		{status: http.StatusServiceUnavailable},
+		{queueTimeout: "10ms", status: http.StatusServiceUnavailable},
+		{queueTimeout: "1s", status: http.StatusOK},
+	} {
+		release := make(chan struct{})
+		started := make(chan struct{}, 1)
+		e := gin.New()
+		e.GET("/", NewConcurrencyLimiter(PageConcurrency{MaxInFlight: 1, QueueTimeout: tc.queueTimeout}).HandlerFunc(), func(c *gin.Context) {
+			select {
+			case started <- struct{}{}:
+				<-release
+			default:
+			}
+			c.String(http.StatusOK, "ok")
+		})
+
+		done := make(chan int)
+		go func() {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/", nil)
+			e.ServeHTTP(w, req)
+			done <- w.Code
+		}()
+		<-started
+
+		if tc.status == http.StatusOK {
+			go func() {
+				time.Sleep(50 * time.Millisecond)
+				close(release)
+			}()
+		}
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		e.ServeHTTP(w, req)
+		if w.Code != tc.status {
+			t.Errorf("%q: unexpected status code: %d", tc.queueTimeout, w.Code)
+		}
+		if tc.status != http.StatusOK {
+			if w.Header().Get("Retry-After") == "" {
+				t.Errorf("%q: missing Retry-After header", tc.queueTimeout)
+			}
+			close(release)
+		}
+		if code := <-done; code != http.StatusOK {
+			t.Errorf("%q: unexpected status code of the first request: %d", tc.queueTimeout, code)
+		}
+	}
+}
@@ -1,48 +1,194 @@
 package engine
 
 import (
+	"bytes"
 	"encoding/json"
-	"bytes"	
+	"fmt"
 	"io"
 	"os"
-	
+	"path/filepath"
+	"strings"
+
 	"github.com/ghodss/yaml"
-	
+	"github.com/pelletier/go-toml/v2"
 )
 
+const (
+	// ConfigFormatJSON is the format of the config files with the .json extension
+	ConfigFormatJSON = "json"
+	// ConfigFormatYAML is the format of the config files with the .yaml or .yml extension
+	ConfigFormatYAML = "yaml"
+	// ConfigFormatTOML is the format of the config files with the .toml extension
+	ConfigFormatTOML = "toml"
+)
 
-// ParseConfigFromFile creates a Config with the contents of the received filepath
+// ParseConfigFromFile creates a Config with the contents of the received filepath. The format is
+// selected by the extension of the file. The files with other extensions are parsed as JSON if
+// they start with a curly brace and as YAML otherwise. The files matching the include patterns
+// of the config are parsed and merged into it (see Config.Include)
 func ParseConfigFromFile(path string) (Config, error) {
+	cfg, err := parseConfigFile(path, map[string]bool{})
+	if err != nil {
+		return cfg, err
+	}
+	mergeExtra(&cfg)
+	return cfg, nil
+}
+
+// parseConfigFile decodes the config file and merges the files it includes, skipping the
+// inherited extra data. The parents holds the absolute paths of the files including it, so
+// the include cycles are detected
+func parseConfigFile(path string, parents map[string]bool) (Config, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return Config{}, err
+	}
+	if parents[absPath] {
+		return Config{}, fmt.Errorf("include cycle: %s", path)
+	}
 	configFile, err := os.Open(path)
 	if err != nil {
 		return Config{}, err
 	}
-	cfg, err := ParseConfig(configFile)
+	cfg, err := decodeConfig(configFile, ConfigFormat(path))
 	configFile.Close()
-	return cfg, err
+	if err != nil || len(cfg.Include) == 0 {
+		return cfg, err
+	}
+
+	parents[absPath] = true
+	defer delete(parents, absPath)
+	for _, pattern := range cfg.Include {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(path), pattern)
+		}
+		cfg.includeDirs = append(cfg.includeDirs, filepath.Dir(pattern))
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			return Config{}, fmt.Errorf("include %s: %s", pattern, err.Error())
+		}
+		if len(paths) == 0 && !hasGlobMeta(pattern) {
+			return Config{}, fmt.Errorf("include %s: file not found", pattern)
+		}
+		for _, p := range paths {
+			included, err := parseConfigFile(p, parents)
+			if err != nil {
+				return Config{}, err
+			}
+			// the extra data of the included file takes precedence over the one of the
+			// including file for its own pages
+			mergeExtra(&included)
+			if err := mergeIncludedConfig(&cfg, included, p); err != nil {
+				return Config{}, err
+			}
+		}
+	}
+	return cfg, nil
+}
+
+// mergeIncludedConfig appends the pages of the included config and adds its templates and
+// layouts. The pages already declared and the templates and layouts declared with different
+// paths are reported as errors
+func mergeIncludedConfig(cfg *Config, included Config, path string) error {
+	names := map[string]bool{}
+	for _, page := range cfg.Pages {
+		names[page.Name] = true
+	}
+	for _, page := range included.Pages {
+		if page.Name != "" && names[page.Name] {
+			return fmt.Errorf("include %s: page %s already declared", path, page.Name)
+		}
+		names[page.Name] = true
+		cfg.Pages = append(cfg.Pages, page)
+	}
+	for _, section := range []struct {
+		kind string
+		dst  *map[string]string
+		src  map[string]string
+	}{
+		{"template", &cfg.Templates, included.Templates},
+		{"layout", &cfg.Layouts, included.Layouts},
+	} {
+		for name, tmplPath := range section.src {
+			if *section.dst == nil {
+				*section.dst = map[string]string{}
+			}
+			if current, ok := (*section.dst)[name]; ok && current != tmplPath {
+				return fmt.Errorf("include %s: %s %s already declared with %s", path, section.kind, name, current)
+			}
+			(*section.dst)[name] = tmplPath
+		}
+	}
+	cfg.includeDirs = append(cfg.includeDirs, included.includeDirs...)
+	return nil
+}
+
+func hasGlobMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[")
 }
 
-// ParseConfig parses the content of the reader into a Config
+// ConfigFormat returns the format of the config file selected by its extension or an empty
+// string if the extension is not a known one
+func ConfigFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return ConfigFormatJSON
+	case ".yaml", ".yml":
+		return ConfigFormatYAML
+	case ".toml":
+		return ConfigFormatTOML
+	}
+	return ""
+}
+
+// ParseConfig parses the content of the reader into a Config. The content is parsed as JSON if
+// it starts with a curly brace and as YAML otherwise
 func ParseConfig(r io.Reader) (Config, error) {
+	return ParseConfigFormat(r, "")
+}
+
+// ParseConfigFormat parses the content of the reader into a Config with the received format. If
+// the format is empty, it is detected as ParseConfig does. The includes are not resolved, since
+// their paths are relative to the config file (see ParseConfigFromFile)
+func ParseConfigFormat(r io.Reader, format string) (Config, error) {
+	cfg, err := decodeConfig(r, format)
+	if err != nil {
+		return cfg, err
+	}
+	mergeExtra(&cfg)
+	return cfg, nil
+}
+
+func decodeConfig(r io.Reader, format string) (Config, error) {
 	var cfg Config
 	var buf bytes.Buffer
-	
-	buf.ReadFrom(r)	
+
+	buf.ReadFrom(r)
 	cb := buf.Bytes()
-	
-	switch {
-	case bytes.HasPrefix(bytes.TrimSpace(cb), []byte("{")):
-		err := json.Unmarshal(cb, &cfg)
-		if err != nil {
-			return cfg, err
-		}
-	default:	
-		err := yaml.Unmarshal(cb, &cfg)
-		if err != nil {
-			return cfg, err
+
+	if format == "" {
+		format = ConfigFormatYAML
+		if bytes.HasPrefix(bytes.TrimSpace(cb), []byte("{")) {
+			format = ConfigFormatJSON
 		}
 	}
-	
+
+	var err error
+	switch format {
+	case ConfigFormatJSON:
+		err = json.Unmarshal(cb, &cfg)
+	case ConfigFormatYAML:
+		err = yaml.Unmarshal(cb, &cfg)
+	case ConfigFormatTOML:
+		err = unmarshalTOML(cb, &cfg)
+	default:
+		err = fmt.Errorf("unknown config format: %s", format)
+	}
+	return cfg, err
+}
+
+// mergeExtra adds the extra data of the config to its pages, without replacing their own keys
+func mergeExtra(cfg *Config) {
 	for p, page := range cfg.Pages {
 		if len(page.Extra) == 0 {
 			cfg.Pages[p].Extra = cfg.Extra
@@ -54,5 +200,18 @@ func ParseConfig(r io.Reader) (Config, error) {
 			}
 		}
 	}
-	return cfg, nil
+}
+
+// unmarshalTOML decodes the TOML document through its JSON version, so the config keeps the
+// names of its JSON fields, as the YAML decoder does
+func unmarshalTOML(data []byte, cfg *Config) error {
+	var v map[string]interface{}
+	if err := toml.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, cfg)
 }
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -155,3 +157,176 @@ func TestParseConfig_extra(t *testing.T) {
 		}
 	}
 }
+
+func TestParseConfigFromFile_formats(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"config.json": `{
+	"templates": {"home": "home.mustache"},
+	"pages": [{"name": "home", "URLPattern": "/", "Template": "home", "CacheTTL": "3600s", "backend_cache_ttl": "1m"}],
+	"extra": {"lang": "en-US", "year": 2018}
+}`,
+		"config.yml": `templates:
+  home: home.mustache
+pages:
+  - name: home
+    URLPattern: /
+    Template: home
+    CacheTTL: 3600s
+    backend_cache_ttl: 1m
+extra:
+  lang: en-US
+  year: 2018
+`,
+		"config.toml": `[templates]
+home = "home.mustache"
+
+[[pages]]
+name = "home"
+URLPattern = "/"
+Template = "home"
+CacheTTL = "3600s"
+backend_cache_ttl = "1m"
+
+[extra]
+lang = "en-US"
+year = 2018
+`,
+		// the unknown extensions are sniffed
+		"config.conf": `{"templates": {"home": "home.mustache"}, "pages": [{"name": "home", "URLPattern": "/", "Template": "home", "CacheTTL": "3600s", "backend_cache_ttl": "1m"}], "extra": {"lang": "en-US", "year": 2018}}`,
+	} {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := ParseConfigFromFile(path)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", name, err.Error())
+			continue
+		}
+		if cfg.Templates["home"] != "home.mustache" || len(cfg.Pages) != 1 {
+			t.Errorf("%s: unexpected config: %+v", name, cfg)
+			continue
+		}
+		page := cfg.Pages[0]
+		if page.Name != "home" || page.URLPattern != "/" || page.CacheTTL != "3600s" || page.BackendCacheTTL != "1m" {
+			t.Errorf("%s: unexpected page: %+v", name, page)
+		}
+		if page.Extra["lang"] != "en-US" || page.Extra["year"] != float64(2018) {
+			t.Errorf("%s: unexpected extra: %v", name, page.Extra)
+		}
+	}
+}
+
+func TestParseConfigFormat_ko(t *testing.T) {
+	for format, content := range map[string]string{
+		ConfigFormatJSON: `templates: {}`,
+		ConfigFormatTOML: `[templates`,
+		"xml":            `<config/>`,
+	} {
+		if _, err := ParseConfigFormat(bytes.NewBufferString(content), format); err == nil {
+			t.Errorf("%s: expecting an error", format)
+		}
+	}
+}
+
+func writeConfigFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestParseConfigFromFile_include(t *testing.T) {
+	dir := t.TempDir()
+	writeConfigFiles(t, dir, map[string]string{
+		"config.json": `{
+	"include": ["pages/*.json", "shop.toml"],
+	"templates": {"home": "home.mustache"},
+	"layouts": {"main": "main.mustache"},
+	"pages": [{"name": "home", "URLPattern": "/", "Template": "home"}],
+	"extra": {"lang": "en-US", "team": "web"}
+}`,
+		"pages/blog.json": `{
+	"templates": {"post": "post.mustache", "home": "home.mustache"},
+	"pages": [
+		{"name": "post", "URLPattern": "/blog/:slug", "Template": "post", "Layout": "main"},
+		{"name": "archive", "URLPattern": "/blog", "Template": "post", "Extra": {"team": "editorial"}}
+	],
+	"extra": {"team": "blog"}
+}`,
+		"pages/legacy.yml": `pages: [{name: legacy}]`,
+		"shop.toml": `include = ["shop/*.json"]
+
+[templates]
+product = "product.mustache"
+
+[[pages]]
+name = "product"
+URLPattern = "/products/:id"
+Template = "product"
+`,
+	})
+
+	cfg, err := ParseConfigFromFile(filepath.Join(dir, "config.json"))
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	names := []string{}
+	for _, page := range cfg.Pages {
+		names = append(names, page.Name)
+	}
+	if strings.Join(names, ",") != "home,post,archive,product" {
+		t.Errorf("unexpected pages: %v", names)
+	}
+	if len(cfg.Templates) != 3 || cfg.Templates["product"] != "product.mustache" || cfg.Layouts["main"] != "main.mustache" {
+		t.Errorf("unexpected templates: %v %v", cfg.Templates, cfg.Layouts)
+	}
+	for i, team := range []string{"web", "blog", "editorial", "web"} {
+		if extra := cfg.Pages[i].Extra; extra["team"] != team || extra["lang"] != "en-US" {
+			t.Errorf("%s: unexpected extra: %v", cfg.Pages[i].Name, extra)
+		}
+	}
+	if dirs := reloaderDirs(filepath.Join(dir, "config.json"), cfg); len(dirs) != 5 || dirs[1] != filepath.Join(dir, "pages") || dirs[2] != filepath.Join(dir, "shop") {
+		t.Errorf("unexpected watched folders: %v", dirs)
+	}
+}
+
+func TestParseConfigFromFile_includeKo(t *testing.T) {
+	for name, files := range map[string]map[string]string{
+		"duplicated page": {
+			"config.json": `{"include": ["pages.json"], "pages": [{"name": "home"}]}`,
+			"pages.json":  `{"pages": [{"name": "home"}]}`,
+		},
+		"conflicting template": {
+			"config.json": `{"include": ["pages.json"], "templates": {"home": "home.mustache"}}`,
+			"pages.json":  `{"templates": {"home": "other.mustache"}}`,
+		},
+		"missing file": {
+			"config.json": `{"include": ["pages.json"]}`,
+		},
+		"bad pattern": {
+			"config.json": `{"include": ["[pages.json"]}`,
+		},
+		"wrong file": {
+			"config.json": `{"include": ["pages.json"]}`,
+			"pages.json":  `{"pages": `,
+		},
+		"cycle": {
+			"config.json": `{"include": ["pages.json"]}`,
+			"pages.json":  `{"include": ["config.json"]}`,
+		},
+	} {
+		dir := t.TempDir()
+		writeConfigFiles(t, dir, files)
+		if _, err := ParseConfigFromFile(filepath.Join(dir, "config.json")); err == nil {
+			t.Errorf("%s: expecting an error", name)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package engine
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const cspNonceKey = "api2html_csp_nonce"
+
+// DefaultNonceDirectives are the CSP directives getting the nonce of the request by default
+var DefaultNonceDirectives = []string{"script-src", "style-src"}
+
+// NewCSPMiddleware returns a gin middleware generating a random nonce for every request, exposed
+// to the templates as Extra.csp_nonce, and sending the Content-Security-Policy header of the
+// received config with the nonce added to its nonce directives
+func NewCSPMiddleware(cfg ContentSecurityPolicy) gin.HandlerFunc {
+	header := "Content-Security-Policy"
+	if cfg.ReportOnly {
+		header = "Content-Security-Policy-Report-Only"
+	}
+	nonceDirectives := cfg.NonceDirectives
+	if len(nonceDirectives) == 0 {
+		nonceDirectives = DefaultNonceDirectives
+	}
+	return func(c *gin.Context) {
+		nonce, err := newCSPNonce()
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		c.Set(cspNonceKey, nonce)
+		c.Header(header, cspHeader(cfg.Directives, nonceDirectives, nonce))
+		c.Next()
+	}
+}
+
+// CSPNonce returns the CSP nonce of the request, if any
+func CSPNonce(c *gin.Context) string {
+	if c == nil {
+		return ""
+	}
+	return c.GetString(cspNonceKey)
+}
+
+// cspHeader returns the policy with the directives sorted by name and the nonce added to the
+// nonce directives
+func cspHeader(directives map[string][]string, nonceDirectives []string, nonce string) string {
+	sources := make(map[string][]string, len(directives)+len(nonceDirectives))
+	for name, values := range directives {
+		sources[name] = values
+	}
+	for _, name := range nonceDirectives {
+		sources[name] = append(append([]string{}, sources[name]...), "'nonce-"+nonce+"'")
+	}
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	policy := make([]string, len(names))
+	for i, name := range names {
+		policy[i] = strings.TrimSpace(name + " " + strings.Join(sources[name], " "))
+	}
+	return strings.Join(policy, "; ")
+}
+
+func newCSPNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
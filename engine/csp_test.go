@@ -0,0 +1,83 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNewCSPMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(NewCSPMiddleware(ContentSecurityPolicy{
+		Directives: map[string][]string{
+			"default-src":               {"'self'"},
+			"script-src":                {"'self'", "https://cdn.example.com"},
+			"upgrade-insecure-requests": nil,
+		},
+	}))
+	e.GET("/", func(c *gin.Context) {
+		c.String(http.StatusOK, "%v", requestExtra(c, map[string]interface{}{"site": "example"})["csp_nonce"])
+	})
+
+	nonces := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		nonce := w.Body.String()
+		if nonce == "" || nonces[nonce] {
+			t.Errorf("#%d: unexpected nonce: %q", i, nonce)
+		}
+		nonces[nonce] = true
+
+		expected := "default-src 'self'; script-src 'self' https://cdn.example.com 'nonce-" + nonce +
+			"'; style-src 'nonce-" + nonce + "'; upgrade-insecure-requests"
+		if h := w.Header().Get("Content-Security-Policy"); h != expected {
+			t.Errorf("#%d: unexpected Content-Security-Policy header: %s", i, h)
+		}
+	}
+}
+
+func TestNewCSPMiddleware_reportOnly(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.GET("/", NewCSPMiddleware(ContentSecurityPolicy{
+		NonceDirectives: []string{"script-src"},
+		ReportOnly:      true,
+	}), func(c *gin.Context) {
+		c.String(http.StatusOK, "%s", CSPNonce(c))
+	})
+
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Header().Get("Content-Security-Policy") != "" {
+		t.Error("unexpected Content-Security-Policy header")
+	}
+	h := w.Header().Get("Content-Security-Policy-Report-Only")
+	if h != "script-src 'nonce-"+w.Body.String()+"'" || strings.Contains(h, "style-src") {
+		t.Errorf("unexpected Content-Security-Policy-Report-Only header: %s", h)
+	}
+}
+
+func TestRequestExtra(t *testing.T) {
+	extra := map[string]interface{}{"site": "example"}
+	if res := requestExtra(nil, extra); len(res) != 1 {
+		t.Errorf("unexpected extra: %v", res)
+	}
+	c := &gin.Context{}
+	if res := requestExtra(c, extra); len(res) != 1 {
+		t.Errorf("unexpected extra: %v", res)
+	}
+	c.Set(cspNonceKey, "n0nc3")
+	c.Set(jwtUserKey, map[string]interface{}{"sub": "user-1"})
+	res := requestExtra(c, extra)
+	if res["site"] != "example" || res["csp_nonce"] != "n0nc3" || res["user"] == nil {
+		t.Errorf("unexpected extra: %v", res)
+	}
+	if len(extra) != 1 {
+		t.Errorf("the extra of the page has been modified: %v", extra)
+	}
+}
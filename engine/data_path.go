@@ -0,0 +1,65 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmespath/go-jmespath"
+)
+
+// withDataPath wraps the ResponseGenerator replacing the decoded backend data with the result of
+// the JMESPath expression of the page. The objects are stored in the Data of the response and
+// the arrays of objects in its Array. If the expression matches nothing, the data is empty
+func withDataPath(page Page, rg ResponseGenerator) ResponseGenerator {
+	if page.DataPath == "" {
+		return rg
+	}
+	expr, err := jmespath.Compile(page.DataPath)
+	if err != nil {
+		return func(_ *gin.Context) (ResponseContext, error) {
+			return ResponseContext{}, err
+		}
+	}
+	return func(c *gin.Context) (ResponseContext, error) {
+		result, err := rg(c)
+		if err != nil {
+			return result, err
+		}
+		var input interface{} = result.Data
+		if result.Array != nil {
+			array := make([]interface{}, len(result.Array))
+			for i, item := range result.Array {
+				array[i] = item
+			}
+			input = array
+		}
+		v, err := expr.Search(input)
+		if err != nil {
+			return result, err
+		}
+		result.Data, result.Array, err = extractedData(v)
+		return result, err
+	}
+}
+
+// extractedData splits the result of a JMESPath expression or a script into the Data and the
+// Array of a response context
+func extractedData(v interface{}) (map[string]interface{}, []map[string]interface{}, error) {
+	switch v := v.(type) {
+	case nil:
+		return map[string]interface{}{}, nil, nil
+	case map[string]interface{}:
+		return v, nil, nil
+	case []interface{}:
+		array := make([]map[string]interface{}, len(v))
+		for i, item := range v {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, nil, fmt.Errorf("the data is an array of %T instead of objects", item)
+			}
+			array[i] = m
+		}
+		return nil, array, nil
+	}
+	return nil, nil, fmt.Errorf("the data is a %T instead of an object or an array", v)
+}
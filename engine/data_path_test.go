@@ -0,0 +1,61 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func Test_withDataPath(t *testing.T) {
+	data := map[string]interface{}{
+		"data": map[string]interface{}{
+			"attributes": map[string]interface{}{
+				"title": "products",
+				"items": []interface{}{
+					map[string]interface{}{"name": "laptop"},
+					map[string]interface{}{"name": "phone"},
+				},
+			},
+		},
+	}
+	rg := func(_ *gin.Context) (ResponseContext, error) {
+		return ResponseContext{Data: data, Extra: map[string]interface{}{"a": "b"}}, nil
+	}
+
+	result, err := withDataPath(Page{DataPath: "data.attributes.items"}, rg)(nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if result.Data != nil || len(result.Array) != 2 || result.Array[1]["name"] != "phone" || result.Extra["a"] != "b" {
+		t.Errorf("unexpected result: %v", result)
+	}
+
+	result, err = withDataPath(Page{DataPath: "data.attributes.{title: title, first: items[0].name}"}, rg)(nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if result.Array != nil || result.Data["title"] != "products" || result.Data["first"] != "laptop" {
+		t.Errorf("unexpected result: %v", result)
+	}
+
+	result, err = withDataPath(Page{DataPath: "unknown.path"}, rg)(nil)
+	if err != nil || result.Data == nil || len(result.Data) != 0 || result.Array != nil {
+		t.Errorf("unexpected result: %v %v", result, err)
+	}
+
+	arrayRG := func(_ *gin.Context) (ResponseContext, error) {
+		return ResponseContext{Array: []map[string]interface{}{{"name": "laptop"}, {"name": "phone"}}}, nil
+	}
+	result, err = withDataPath(Page{DataPath: "[?name == 'phone']"}, arrayRG)(nil)
+	if err != nil || len(result.Array) != 1 || result.Array[0]["name"] != "phone" {
+		t.Errorf("unexpected result: %v %v", result, err)
+	}
+
+	for _, path := range []string{"data.attributes.title", "data.attributes.items[*].name", "data.["} {
+		if _, err := withDataPath(Page{DataPath: path}, rg)(nil); err == nil {
+			t.Errorf("%s: expecting an error", path)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cbroglie/mustache"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// DebugQueryParam is the query param enabling the debug mode of a request. Its value selects
+	// the format of the report: json or html (the default)
+	DebugQueryParam = "api2html_debug"
+
+	debugKey = "api2html_debug"
+)
+
+// DebugReport describes how a page was rendered: the template, the backend calls, the decoded
+// response context and the timings
+type DebugReport struct {
+	Page     string `json:"page"`
+	Template string `json:"template"`
+	Layout   string `json:"layout,omitempty"`
+	Status   int    `json:"status"`
+	// Error is the error of the failed requests
+	Error    string         `json:"error,omitempty"`
+	Backends []DebugBackend `json:"backends"`
+	// RenderTime is the duration of the rendering
+	RenderTime string `json:"render_time,omitempty"`
+	TotalTime  string `json:"total_time"`
+	// Context is the response context the template is rendered with
+	Context *ResponseContext `json:"context,omitempty"`
+	// Size is the size of the rendered response
+	Size int `json:"size"`
+}
+
+// DebugBackend describes a backend call
+type DebugBackend struct {
+	URL      string `json:"url"`
+	Status   int    `json:"status,omitempty"`
+	Duration string `json:"duration"`
+	Error    string `json:"error,omitempty"`
+}
+
+// DebugMiddleware returns a gin middleware replacing the responses of the requests with the
+// DebugQueryParam by the DebugReport of their pages, as JSON or as an HTML panel. It must be
+// registered just in devel mode, since the reports expose the backend URLs and responses
+func DebugMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		format, ok := c.GetQuery(DebugQueryParam)
+		if !ok {
+			c.Next()
+			return
+		}
+		start := time.Now()
+		d := &debugRecorder{}
+		addHooks(c, d)
+		c.Set(debugKey, d)
+		w := c.Writer
+		buf := &debugWriter{ResponseWriter: w}
+		c.Writer = buf
+		c.Next()
+		c.Writer = w
+
+		d.mutex.Lock()
+		report := d.report
+		d.mutex.Unlock()
+		report.Status = w.Status()
+		report.TotalTime = time.Since(start).String()
+		report.Size = buf.body.Len()
+		if report.Error == "" {
+			if last := c.Errors.Last(); last != nil {
+				report.Error = last.Error()
+			}
+		}
+		for _, k := range []string{"Content-Length", "ETag", "Last-Modified", "Content-Encoding"} {
+			w.Header().Del(k)
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		if format == "json" {
+			c.JSON(report.Status, report)
+			return
+		}
+		panel, err := mustache.Render(debugPanelTmpl, report)
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.Data(report.Status, "text/html; charset=utf-8", []byte(panel))
+	}
+}
+
+// recordDebugContext adds the page and the response context to the DebugReport of the request,
+// if the debug mode is enabled
+func recordDebugContext(c *gin.Context, page Page, result ResponseContext, err error) {
+	if c == nil {
+		return
+	}
+	v, ok := c.Get(debugKey)
+	if !ok {
+		return
+	}
+	d := v.(*debugRecorder)
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.report.Page, d.report.Template, d.report.Layout = page.Name, page.Template, page.Layout
+	result.Extra = requestExtra(c, result.Extra)
+	d.report.Context = &result
+	if err != nil {
+		d.report.Error = err.Error()
+	}
+}
+
+// debugRecorder collects the DebugReport of a request through its hooks
+type debugRecorder struct {
+	NoopHooks
+	mutex  sync.Mutex
+	report DebugReport
+}
+
+// OnRequest implements the Hooks interface
+func (d *debugRecorder) OnRequest(_ *gin.Context, name string) {
+	d.mutex.Lock()
+	if d.report.Page == "" {
+		d.report.Page = name
+	}
+	d.mutex.Unlock()
+}
+
+// OnBackendCall implements the Hooks interface
+func (d *debugRecorder) OnBackendCall(_ *gin.Context, req *http.Request) func(*http.Response, error) {
+	start := time.Now()
+	return func(resp *http.Response, err error) {
+		call := DebugBackend{URL: req.URL.String(), Duration: time.Since(start).String()}
+		if err != nil {
+			call.Error = err.Error()
+		} else if resp != nil {
+			call.Status = resp.StatusCode
+		}
+		d.mutex.Lock()
+		d.report.Backends = append(d.report.Backends, call)
+		d.mutex.Unlock()
+	}
+}
+
+// OnRender implements the Hooks interface
+func (d *debugRecorder) OnRender(_ *gin.Context) func(error) {
+	start := time.Now()
+	return func(_ error) {
+		d.mutex.Lock()
+		d.report.RenderTime = time.Since(start).String()
+		d.mutex.Unlock()
+	}
+}
+
+// debugWriter keeps the response of the page, so it can be replaced by the report
+type debugWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *debugWriter) Write(p []byte) (int, error) { return w.body.Write(p) }
+
+func (w *debugWriter) WriteString(s string) (int, error) { return w.body.WriteString(s) }
+
+func (w *debugWriter) WriteHeaderNow() {}
+
+func (w *debugWriter) Size() int { return w.body.Len() }
+
+func (w *debugWriter) Written() bool { return w.body.Len() > 0 }
+
+// String returns the response context as indented JSON
+func (r DebugReport) String() string {
+	d, err := json.MarshalIndent(r.Context, "", "\t")
+	if err != nil {
+		return err.Error()
+	}
+	return string(d)
+}
@@ -0,0 +1,69 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDebugMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(DebugMiddleware())
+	e.GET("/products/:id", func(c *gin.Context) {
+		HooksFromContext(c).OnRequest(c, "product")
+		req, _ := http.NewRequest("GET", "http://api/products/"+c.Param("id"), nil)
+		done := HooksFromContext(c).OnBackendCall(c, req)
+		if c.Param("id") == "0" {
+			err := errors.New("backend down")
+			done(nil, err)
+			recordDebugContext(c, Page{Name: "product", Template: "product"}, ResponseContext{}, err)
+			c.AbortWithError(http.StatusBadGateway, err)
+			return
+		}
+		done(&http.Response{StatusCode: http.StatusOK}, nil)
+		result := ResponseContext{Data: map[string]interface{}{"name": "shoes"}}
+		recordDebugContext(c, Page{Name: "product", Template: "product", Layout: "main"}, result, nil)
+		c.Header("ETag", `"abc"`)
+		c.String(http.StatusOK, "shoes")
+	})
+
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/products/42", nil))
+	if w.Body.String() != "shoes" || w.Header().Get("ETag") == "" {
+		t.Errorf("unexpected response: %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/products/42?"+DebugQueryParam+"=json", nil))
+	var report DebugReport
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Page != "product" || report.Template != "product" || report.Layout != "main" ||
+		report.Status != http.StatusOK || report.Size != 5 || w.Header().Get("ETag") != "" {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	if len(report.Backends) != 1 || report.Backends[0].URL != "http://api/products/42" || report.Backends[0].Status != http.StatusOK {
+		t.Errorf("unexpected backends: %+v", report.Backends)
+	}
+	if report.Context == nil || report.Context.Data["name"] != "shoes" {
+		t.Errorf("unexpected context: %+v", report.Context)
+	}
+
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/products/0?"+DebugQueryParam, nil))
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+	for _, expected := range []string{"API2HTML Debugger", "http://api/products/0", "backend down"} {
+		if !strings.Contains(w.Body.String(), expected) {
+			t.Errorf("the panel does not contain %q: %s", expected, w.Body.String())
+		}
+	}
+}
@@ -1,13 +1,112 @@
 package engine
 
 import (
+	"bufio"
+	"encoding/csv"
 	"encoding/json"
+	"encoding/xml"
+	"fmt"
 	"io"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// JSONEncoding is the default encoding of the backend responses. It can not be replaced
+	JSONEncoding = "json"
+	// XMLEncoding is the encoding of the pages whose backends return XML payloads
+	XMLEncoding = "xml"
+	// CSVEncoding is the encoding of the pages whose backends return CSV reports
+	CSVEncoding = "csv"
 )
 
 // Decoder defines the signature for response decoder functions
 type Decoder func(io.Reader, *ResponseContext) error
 
+var (
+	decoders = map[string]Decoder{
+		XMLEncoding:     XMLDecoder,
+		CSVEncoding:     CSVDecoder,
+		MsgpackEncoding: MsgpackDecoder,
+	}
+	decodersMutex sync.RWMutex
+)
+
+// RegisterDecoder makes the decoder available to the pages and backends declaring the received
+// name as their encoding. The decoders put the objects into the Data property of the
+// ResponseContext and the arrays into the Array one
+func RegisterDecoder(name string, d Decoder) {
+	if loadingPlugin(func(r *registry) { r.decoders[name] = d }) {
+		return
+	}
+	decodersMutex.Lock()
+	decoders[name] = d
+	decodersMutex.Unlock()
+}
+
+// DecoderByName returns the decoder registered with the received name, if any
+func DecoderByName(name string) (Decoder, bool) {
+	decodersMutex.RLock()
+	d, ok := decoders[name]
+	decodersMutex.RUnlock()
+	return d, ok
+}
+
+// pageDecoder returns the decoder of the backend responses of the page. The json one decodes
+// arrays if the page has the IsArray flag and objects otherwise
+func pageDecoder(page Page) (Decoder, error) {
+	if page.Encoding == "" || page.Encoding == JSONEncoding {
+		if page.IsArray {
+			return JSONArrayDecoder, nil
+		}
+		return JSONDecoder, nil
+	}
+	if page.Encoding == CSVEncoding && page.CSV != nil {
+		return NewCSVDecoder(*page.CSV)
+	}
+	if page.Encoding == ProtobufEncoding {
+		return protobufDecoder(page.Protobuf)
+	}
+	return page.registry.encodingDecoder(page.Encoding)
+}
+
+// backendDecoder returns the decoder of the responses of the additional backend of a page. The
+// json one decodes both arrays and objects
+func backendDecoder(page Page, cfg PageBackend) (Decoder, error) {
+	if cfg.Encoding == "" || cfg.Encoding == JSONEncoding {
+		return JSONAutoDecoder, nil
+	}
+	if cfg.Encoding == ProtobufEncoding {
+		return protobufDecoder(cfg.Protobuf)
+	}
+	return page.registry.encodingDecoder(cfg.Encoding)
+}
+
+func protobufDecoder(cfg *Protobuf) (Decoder, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("the protobuf encoding requires a descriptor and a message type")
+	}
+	return NewProtobufDecoder(*cfg)
+}
+
+// encodingDecoder returns the decoder of the registry (or the one registered in the package, if
+// it is nil) with the received name
+func (r *registry) encodingDecoder(name string) (Decoder, error) {
+	var d Decoder
+	var ok bool
+	if r == nil {
+		d, ok = DecoderByName(name)
+	} else {
+		d, ok = r.decoders[name]
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown encoding: %s", name)
+	}
+	return d, nil
+}
+
 // JSONDecoder decodes the reader content and puts it into the Data property of the
 // injected ResponseContext
 func JSONDecoder(r io.Reader, c *ResponseContext) error {
@@ -33,3 +132,164 @@ func JSONArrayDecoder(r io.Reader, c *ResponseContext) error {
 	c.Array = target
 	return nil
 }
+
+// JSONAutoDecoder decodes the reader content with the JSONArrayDecoder if it is an array or
+// with the JSONDecoder otherwise
+func JSONAutoDecoder(r io.Reader, c *ResponseContext) error {
+	br := bufio.NewReader(r)
+	for {
+		b, err := br.Peek(1)
+		if err != nil {
+			return err
+		}
+		if !unicode.IsSpace(rune(b[0])) {
+			if b[0] == '[' {
+				return JSONArrayDecoder(br, c)
+			}
+			return JSONDecoder(br, c)
+		}
+		br.ReadByte()
+	}
+}
+
+// XMLDecoder decodes the XML reader content and puts the content of the root element into the
+// Data property of the injected ResponseContext. The attributes are stored with the @ prefix, the
+// repeated child elements as arrays and the text of the elements with attributes or children
+// under the #text key
+func XMLDecoder(r io.Reader, c *ResponseContext) error {
+	decoder := xml.NewDecoder(r)
+	for {
+		t, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		if start, ok := t.(xml.StartElement); ok {
+			v, err := decodeXMLElement(decoder, start)
+			if err != nil {
+				return err
+			}
+			data, ok := v.(map[string]interface{})
+			if !ok {
+				data = map[string]interface{}{"#text": v}
+			}
+			c.Data = data
+			return nil
+		}
+	}
+}
+
+// decodeXMLElement returns the content of the element: a string if it only contains text or a
+// map otherwise
+func decodeXMLElement(decoder *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	node := map[string]interface{}{}
+	for _, attr := range start.Attr {
+		node["@"+attr.Name.Local] = attr.Value
+	}
+	text := &strings.Builder{}
+	for {
+		t, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := t.(type) {
+		case xml.StartElement:
+			child, err := decodeXMLElement(decoder, t)
+			if err != nil {
+				return nil, err
+			}
+			name := t.Name.Local
+			switch prev := node[name].(type) {
+			case nil:
+				node[name] = child
+			case []interface{}:
+				node[name] = append(prev, child)
+			default:
+				node[name] = []interface{}{prev, child}
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			content := strings.TrimSpace(text.String())
+			if len(node) == 0 {
+				return content, nil
+			}
+			if content != "" {
+				node["#text"] = content
+			}
+			return node, nil
+		}
+	}
+}
+
+// CSVDecoder decodes the CSV reader content, using its first row as the header, and puts the
+// rest of the rows into the Array property of the injected ResponseContext as maps with the
+// names of the columns as keys
+func CSVDecoder(r io.Reader, c *ResponseContext) error {
+	return decodeCSV(csv.NewReader(r), nil, nil, c)
+}
+
+// NewCSVDecoder returns a decoder like the CSVDecoder with the delimiter, the column renames and
+// the column names of the received config
+func NewCSVDecoder(cfg CSV) (Decoder, error) {
+	delimiter := ','
+	if cfg.Delimiter != "" {
+		if utf8.RuneCountInString(cfg.Delimiter) != 1 {
+			return nil, fmt.Errorf("the csv delimiter must be a single char: %q", cfg.Delimiter)
+		}
+		delimiter, _ = utf8.DecodeRuneInString(cfg.Delimiter)
+	}
+	return func(r io.Reader, c *ResponseContext) error {
+		reader := csv.NewReader(r)
+		reader.Comma = delimiter
+		return decodeCSV(reader, cfg.Columns, cfg.Headers, c)
+	}, nil
+}
+
+// decodeCSV decodes the rows of the reader. If there are no columns, they are read from the
+// first row. The columns with an entry in the headers map are renamed
+func decodeCSV(reader *csv.Reader, columns []string, headers map[string]string, c *ResponseContext) error {
+	reader.TrimLeadingSpace = true
+	// the short and long rows are handled below
+	reader.FieldsPerRecord = -1
+	if len(columns) == 0 {
+		header, err := reader.Read()
+		if err == io.EOF {
+			c.Array = []map[string]interface{}{}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		columns = header
+	}
+	keys := make([]string, len(columns))
+	for i, column := range columns {
+		column = strings.TrimSpace(column)
+		if name, ok := headers[column]; ok {
+			column = name
+		}
+		keys[i] = column
+	}
+
+	rows := []map[string]interface{}{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		row := make(map[string]interface{}, len(keys))
+		for i, key := range keys {
+			if i < len(record) {
+				row[key] = record[i]
+			} else {
+				row[key] = ""
+			}
+		}
+		rows = append(rows, row)
+	}
+	c.Array = rows
+	return nil
+}
@@ -2,6 +2,7 @@ package engine
 
 import (
 	"bytes"
+	"io"
 	"testing"
 )
 
@@ -32,3 +33,142 @@ func TestJSONArrayDecoder(t *testing.T) {
 		t.Errorf("unexpected obj value: %v", r.Data)
 	}
 }
+
+func TestJSONAutoDecoder(t *testing.T) {
+	c := &ResponseContext{}
+	if err := JSONAutoDecoder(bytes.NewBufferString(` {"a":1}`), c); err != nil || c.Data["a"] == nil {
+		t.Errorf("unexpected result: %v %v", c, err)
+	}
+	c = &ResponseContext{}
+	if err := JSONAutoDecoder(bytes.NewBufferString("\n [{\"a\":1}]"), c); err != nil || len(c.Array) != 1 {
+		t.Errorf("unexpected result: %v %v", c, err)
+	}
+	if err := JSONAutoDecoder(bytes.NewBufferString("  "), &ResponseContext{}); err == nil {
+		t.Error("expecting an error")
+	}
+}
+
+func TestXMLDecoder(t *testing.T) {
+	c := &ResponseContext{}
+	payload := `<?xml version="1.0"?>
+<catalog id="42">
+	<title>Books</title>
+	<book lang="en"><name>Dune</name></book>
+	<book lang="es"><name>Niebla</name></book>
+	<note lang="en">Out of stock</note>
+</catalog>`
+	if err := XMLDecoder(bytes.NewBufferString(payload), c); err != nil {
+		t.Error(err)
+		return
+	}
+	if c.Data["@id"] != "42" || c.Data["title"] != "Books" {
+		t.Errorf("unexpected obj value: %v", c.Data)
+	}
+	books, ok := c.Data["book"].([]interface{})
+	if !ok || len(books) != 2 {
+		t.Errorf("unexpected books: %v", c.Data["book"])
+		return
+	}
+	if book := books[1].(map[string]interface{}); book["@lang"] != "es" || book["name"] != "Niebla" {
+		t.Errorf("unexpected book: %v", book)
+	}
+	if note := c.Data["note"].(map[string]interface{}); note["#text"] != "Out of stock" {
+		t.Errorf("unexpected note: %v", note)
+	}
+
+	c = &ResponseContext{}
+	if err := XMLDecoder(bytes.NewBufferString(`<message>hi</message>`), c); err != nil || c.Data["#text"] != "hi" {
+		t.Errorf("unexpected result: %v %v", c.Data, err)
+	}
+	if err := XMLDecoder(bytes.NewBufferString(`<a><b></a>`), &ResponseContext{}); err == nil {
+		t.Error("expecting an error")
+	}
+}
+
+func TestRegisterDecoder(t *testing.T) {
+	if _, err := pageDecoder(Page{Encoding: "unknown"}); err == nil {
+		t.Error("expecting an error with an unknown encoding")
+	}
+	if _, err := backendDecoder(Page{}, PageBackend{Encoding: "unknown"}); err == nil {
+		t.Error("expecting an error with an unknown encoding")
+	}
+
+	RegisterDecoder("test", func(_ io.Reader, c *ResponseContext) error {
+		c.Array = []map[string]interface{}{{"a": "b"}}
+		return nil
+	})
+	for _, cfg := range []struct {
+		decoder func() (Decoder, error)
+		array   bool
+	}{
+		{func() (Decoder, error) { return pageDecoder(Page{Encoding: "test"}) }, true},
+		{func() (Decoder, error) { return backendDecoder(Page{}, PageBackend{Encoding: "test"}) }, true},
+		{func() (Decoder, error) { return pageDecoder(Page{IsArray: true}) }, true},
+		{func() (Decoder, error) { return pageDecoder(Page{Encoding: JSONEncoding}) }, false},
+	} {
+		d, err := cfg.decoder()
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		c := &ResponseContext{}
+		if err := d(bytes.NewBufferString(`[{"a":"b"}]`), c); (err == nil) != cfg.array {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if cfg.array && len(c.Array) != 1 {
+			t.Errorf("unexpected array: %v", c.Array)
+		}
+	}
+}
+
+func TestCSVDecoder(t *testing.T) {
+	c := &ResponseContext{}
+	if err := CSVDecoder(bytes.NewBufferString("name, price\nlaptop, 999\nphone\n"), c); err != nil {
+		t.Error(err)
+		return
+	}
+	if len(c.Array) != 2 || c.Array[0]["name"] != "laptop" || c.Array[0]["price"] != "999" || c.Array[1]["price"] != "" {
+		t.Errorf("unexpected array: %v", c.Array)
+	}
+
+	c = &ResponseContext{}
+	if err := CSVDecoder(bytes.NewBufferString(""), c); err != nil || c.Array == nil || len(c.Array) != 0 {
+		t.Errorf("unexpected result: %v %v", c.Array, err)
+	}
+	if err := CSVDecoder(bytes.NewBufferString("a,\"b\n1,2\n"), c); err == nil {
+		t.Error("expecting an error with a malformed csv")
+	}
+}
+
+func TestNewCSVDecoder(t *testing.T) {
+	if _, err := NewCSVDecoder(CSV{Delimiter: ";;"}); err == nil {
+		t.Error("expecting an error with a long delimiter")
+	}
+
+	d, err := pageDecoder(Page{Encoding: CSVEncoding, CSV: &CSV{
+		Delimiter: ";",
+		Headers:   map[string]string{"Unit Price": "price"},
+	}})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	c := &ResponseContext{}
+	if err := d(bytes.NewBufferString("Name;Unit Price\nlaptop;999,99\n"), c); err != nil {
+		t.Error(err)
+		return
+	}
+	if len(c.Array) != 1 || c.Array[0]["Name"] != "laptop" || c.Array[0]["price"] != "999,99" {
+		t.Errorf("unexpected array: %v", c.Array)
+	}
+
+	d, _ = NewCSVDecoder(CSV{Delimiter: "\t", Columns: []string{"name", "price"}})
+	c = &ResponseContext{}
+	if err := d(bytes.NewBufferString("laptop\t999\nphone\t499\n"), c); err != nil {
+		t.Error(err)
+		return
+	}
+	if len(c.Array) != 2 || c.Array[1]["name"] != "phone" || c.Array[1]["price"] != "499" {
+		t.Errorf("unexpected array: %v", c.Array)
+	}
+}
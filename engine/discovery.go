@@ -0,0 +1,192 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DNSSRVProvider resolves the hosts from the DNS SRV records of the service
+	DNSSRVProvider = "dns_srv"
+	// ConsulProvider resolves the hosts from the passing instances of a Consul service
+	ConsulProvider = "consul"
+
+	defaultDiscoveryInterval = 30 * time.Second
+	defaultConsulAddress     = "http://127.0.0.1:8500"
+)
+
+// DiscoveredHost is a base URL returned by a Discoverer
+type DiscoveredHost struct {
+	URL    string
+	Weight int
+}
+
+// Discoverer defines the interface for resolving the hosts of a failover backend
+type Discoverer interface {
+	// Discover returns the current hosts of the service. It may block until they change
+	Discover() ([]DiscoveredHost, error)
+}
+
+// NewDiscoverer creates the Discoverer of the provider defined in the received config
+func NewDiscoverer(cfg BackendDiscovery) (Discoverer, error) {
+	interval, err := time.ParseDuration(cfg.Interval)
+	if err != nil || interval <= 0 {
+		interval = defaultDiscoveryInterval
+	}
+	scheme := cfg.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+
+	switch cfg.Provider {
+	case DNSSRVProvider:
+		return &SRVDiscoverer{
+			Name:      cfg.Service,
+			Scheme:    scheme,
+			Interval:  interval,
+			LookupSRV: net.LookupSRV,
+		}, nil
+	case ConsulProvider:
+		address := cfg.ConsulAddress
+		if address == "" {
+			address = defaultConsulAddress
+		}
+		return &ConsulDiscoverer{
+			Address: address,
+			Service: cfg.Service,
+			Scheme:  scheme,
+			Wait:    interval,
+			Client:  http.DefaultClient,
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown discovery provider: %s", cfg.Provider)
+}
+
+// SRVDiscoverer is a Discoverer looking up the SRV records of a name at every interval
+type SRVDiscoverer struct {
+	// Name is the full SRV name, like _api._tcp.company.com
+	Name     string
+	Scheme   string
+	Interval time.Duration
+	// LookupSRV has the signature of net.LookupSRV
+	LookupSRV  func(service, proto, name string) (string, []*net.SRV, error)
+	lastLookup time.Time
+}
+
+// Discover implements the Discoverer interface. Every call after the first one waits for the
+// interval to pass
+func (d *SRVDiscoverer) Discover() ([]DiscoveredHost, error) {
+	if !d.lastLookup.IsZero() {
+		time.Sleep(time.Until(d.lastLookup.Add(d.Interval)))
+	}
+	d.lastLookup = time.Now()
+
+	_, records, err := d.LookupSRV("", "", d.Name)
+	if err != nil {
+		return nil, err
+	}
+	// the records are already sorted by priority and randomized by weight
+	hosts := make([]DiscoveredHost, len(records))
+	for i, record := range records {
+		host := net.JoinHostPort(strings.TrimSuffix(record.Target, "."), strconv.Itoa(int(record.Port)))
+		hosts[i] = DiscoveredHost{URL: d.Scheme + "://" + host, Weight: int(record.Weight)}
+	}
+	return hosts, nil
+}
+
+// ConsulDiscoverer is a Discoverer watching the passing instances of a Consul service with
+// blocking queries
+type ConsulDiscoverer struct {
+	Address string
+	Service string
+	Scheme  string
+	// Wait is the max time a blocking query waits for a change
+	Wait   time.Duration
+	Client *http.Client
+	index  string
+}
+
+type consulServiceEntry struct {
+	Node struct {
+		Address string
+	}
+	Service struct {
+		Address string
+		Port    int
+		Weights struct {
+			Passing int
+		}
+	}
+}
+
+// Discover implements the Discoverer interface. Every call after the first one blocks until
+// the instances of the service change or the wait time expires
+func (d *ConsulDiscoverer) Discover() ([]DiscoveredHost, error) {
+	query := url.Values{}
+	query.Set("passing", "1")
+	query.Set("wait", fmt.Sprintf("%ds", int(d.Wait.Seconds())))
+	if d.index != "" {
+		query.Set("index", d.index)
+	}
+	URL := fmt.Sprintf("%s/v1/health/service/%s?%s", strings.TrimSuffix(d.Address, "/"), url.PathEscape(d.Service), query.Encode())
+
+	resp, err := d.Client.Get(URL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("consul: unexpected status code %d", resp.StatusCode)
+	}
+
+	var entries []consulServiceEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, err
+	}
+	d.index = resp.Header.Get("X-Consul-Index")
+
+	hosts := make([]DiscoveredHost, len(entries))
+	for i, entry := range entries {
+		address := entry.Service.Address
+		if address == "" {
+			address = entry.Node.Address
+		}
+		host := net.JoinHostPort(address, strconv.Itoa(entry.Service.Port))
+		hosts[i] = DiscoveredHost{URL: d.Scheme + "://" + host, Weight: entry.Service.Weights.Passing}
+	}
+	return hosts, nil
+}
+
+// Discover updates the targets of the pool with the hosts returned by the discoverer, keeping
+// the current ones when the lookup fails or returns no hosts. It blocks until the received
+// channel is closed
+func (p *TargetPool) Discover(d Discoverer, stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		hosts, err := d.Discover()
+		if err != nil || len(hosts) == 0 {
+			if err != nil {
+				log.Println("discovering the backend targets:", err.Error())
+			}
+			select {
+			case <-stop:
+				return
+			case <-time.After(p.interval):
+			}
+			continue
+		}
+		p.Update(hosts)
+	}
+}
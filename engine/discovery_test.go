@@ -0,0 +1,156 @@
+package engine
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewDiscoverer(t *testing.T) {
+	d, err := NewDiscoverer(BackendDiscovery{Provider: DNSSRVProvider, Service: "_api._tcp.example.com"})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	srv, ok := d.(*SRVDiscoverer)
+	if !ok || srv.Scheme != "http" || srv.Interval != defaultDiscoveryInterval {
+		t.Errorf("unexpected discoverer: %v", d)
+	}
+
+	d, err = NewDiscoverer(BackendDiscovery{Provider: ConsulProvider, Service: "api", Scheme: "https", Interval: "5s"})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	consul, ok := d.(*ConsulDiscoverer)
+	if !ok || consul.Address != defaultConsulAddress || consul.Scheme != "https" || consul.Wait != 5*time.Second {
+		t.Errorf("unexpected discoverer: %v", d)
+	}
+
+	if _, err := NewDiscoverer(BackendDiscovery{Provider: "unknown"}); err == nil {
+		t.Error("expecting an error")
+	}
+}
+
+func TestSRVDiscoverer(t *testing.T) {
+	d := &SRVDiscoverer{
+		Name:     "_api._tcp.example.com",
+		Scheme:   "http",
+		Interval: time.Millisecond,
+		LookupSRV: func(service, proto, name string) (string, []*net.SRV, error) {
+			if name != "_api._tcp.example.com" {
+				return "", nil, fmt.Errorf("unexpected name: %s", name)
+			}
+			return "", []*net.SRV{
+				{Target: "a.example.com.", Port: 8080, Priority: 1, Weight: 3},
+				{Target: "b.example.com.", Port: 8081, Priority: 2, Weight: 1},
+			}, nil
+		},
+	}
+
+	for i := 0; i < 2; i++ {
+		hosts, err := d.Discover()
+		if err != nil {
+			t.Errorf("unexpected error: %s", err.Error())
+			return
+		}
+		if len(hosts) != 2 ||
+			hosts[0] != (DiscoveredHost{"http://a.example.com:8080", 3}) ||
+			hosts[1] != (DiscoveredHost{"http://b.example.com:8081", 1}) {
+			t.Errorf("unexpected hosts: %v", hosts)
+		}
+	}
+}
+
+func TestConsulDiscoverer(t *testing.T) {
+	queries := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/health/service/api" {
+			http.NotFound(w, r)
+			return
+		}
+		queries = append(queries, r.URL.Query().Get("index"))
+		w.Header().Set("X-Consul-Index", "42")
+		fmt.Fprint(w, `[
+			{"Node":{"Address":"10.0.0.1"},"Service":{"Address":"","Port":8080,"Weights":{"Passing":2}}},
+			{"Node":{"Address":"10.0.0.2"},"Service":{"Address":"10.0.1.2","Port":8081,"Weights":{"Passing":1}}}
+		]`)
+	}))
+	defer ts.Close()
+
+	d := &ConsulDiscoverer{Address: ts.URL, Service: "api", Scheme: "http", Wait: time.Second, Client: http.DefaultClient}
+	for i := 0; i < 2; i++ {
+		hosts, err := d.Discover()
+		if err != nil {
+			t.Errorf("unexpected error: %s", err.Error())
+			return
+		}
+		if len(hosts) != 2 ||
+			hosts[0] != (DiscoveredHost{"http://10.0.0.1:8080", 2}) ||
+			hosts[1] != (DiscoveredHost{"http://10.0.1.2:8081", 1}) {
+			t.Errorf("unexpected hosts: %v", hosts)
+		}
+	}
+	if len(queries) != 2 || queries[0] != "" || queries[1] != "42" {
+		t.Errorf("unexpected blocking queries: %v", queries)
+	}
+
+	d.Service = "unknown"
+	if _, err := d.Discover(); err == nil {
+		t.Error("expecting an error")
+	}
+}
+
+type fakeDiscoverer chan []DiscoveredHost
+
+func (f fakeDiscoverer) Discover() ([]DiscoveredHost, error) {
+	return <-f, nil
+}
+
+func TestTargetPool_Discover(t *testing.T) {
+	pool := NewTargetPool(BackendFailover{
+		Hosts:               []string{"http://a.example.com"},
+		Weights:             map[string]int{"http://b.example.com": 5},
+		HealthCheckInterval: "1ms",
+	})
+	pool.failure(pool.Targets()[0])
+
+	d := fakeDiscoverer(make(chan []DiscoveredHost))
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		pool.Discover(d, stop)
+		close(done)
+	}()
+
+	d <- []DiscoveredHost{{URL: "http://b.example.com", Weight: 2}, {URL: "http://a.example.com/", Weight: 3}}
+	d <- []DiscoveredHost{}
+	close(stop)
+	select {
+	case d <- nil:
+		<-done
+	case <-done:
+	}
+
+	targets := pool.Targets()
+	if len(targets) != 2 {
+		t.Errorf("unexpected targets: %v", targets)
+		return
+	}
+	if targets[0].BaseURL != "http://b.example.com" || targets[0].Weight != 5 || !targets[0].Healthy() {
+		t.Errorf("unexpected target: %v", targets[0])
+	}
+	if targets[1].BaseURL != "http://a.example.com" || targets[1].Weight != 3 || targets[1].Healthy() {
+		t.Errorf("the known target should keep its state: %v", targets[1])
+	}
+}
+
+func TestNewFailoverBackend_noTargets(t *testing.T) {
+	backend := NewFailoverBackend(http.DefaultClient, "http://api.example.com/test", NewTargetPool(BackendFailover{}))
+	if _, err := backend(params, headers, nil); err != ErrNoBackendTargets {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
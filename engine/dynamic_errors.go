@@ -0,0 +1,216 @@
+package engine
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cbroglie/mustache"
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader is the header with the ID of the request, received from the proxies or
+// generated when rendering an error page
+const RequestIDHeader = "X-Request-Id"
+
+// defaultSuggestionsTimeout is the maximum duration of the suggestions requests without timeout
+const defaultSuggestionsTimeout = 500 * time.Millisecond
+
+// ErrorContext describes the failure of a request, exposed as the Error of the response context
+// of the error templates
+type ErrorContext struct {
+	// Status is the status code of the response
+	Status int
+	// Class is the kind of failure: not_found, unauthorized, forbidden, rate_limited,
+	// bad_gateway, unavailable, timeout, client_error or internal
+	Class string
+	// Message is the description of the failure safe to show to the users
+	Message string
+	// Path is the requested path
+	Path string
+	// RequestID identifies the request, so the users can report it
+	RequestID string
+	// Suggestions is the decoded response of the suggestions backend, if any
+	Suggestions interface{} `json:",omitempty"`
+}
+
+// newErrorContext returns the ErrorContext of the request failed with the status code and the
+// error, if any. The generated request IDs are added to the response headers
+func newErrorContext(c *gin.Context, status int, err error) *ErrorContext {
+	ctx := &ErrorContext{Status: status, Class: errorClass(status), Message: http.StatusText(status)}
+	var re *ResponseError
+	if errors.As(err, &re) {
+		ctx.Message = re.UserMessage()
+	}
+	if c == nil || c.Request == nil {
+		return ctx
+	}
+	ctx.Path = c.Request.URL.Path
+	ctx.RequestID = c.GetHeader(RequestIDHeader)
+	if ctx.RequestID == "" {
+		b := make([]byte, 8)
+		rand.Read(b)
+		ctx.RequestID = hex.EncodeToString(b)
+		c.Header(RequestIDHeader, ctx.RequestID)
+	}
+	return ctx
+}
+
+// errorClass returns the kind of failure of the status code
+func errorClass(status int) string {
+	switch status {
+	case http.StatusNotFound:
+		return "not_found"
+	case http.StatusUnauthorized:
+		return "unauthorized"
+	case http.StatusForbidden:
+		return "forbidden"
+	case http.StatusTooManyRequests:
+		return "rate_limited"
+	case http.StatusBadGateway:
+		return "bad_gateway"
+	case http.StatusServiceUnavailable:
+		return "unavailable"
+	case http.StatusGatewayTimeout:
+		return "timeout"
+	}
+	if status < http.StatusInternalServerError {
+		return "client_error"
+	}
+	return "internal"
+}
+
+// NewDynamicErrorHandler creates a DynamicErrorHandler parsing the content of the received
+// ErrorHandler as a mustache template
+func NewDynamicErrorHandler(eh ErrorHandler, cfg DynamicErrorPages, extra map[string]interface{}) (*DynamicErrorHandler, error) {
+	tmpl, err := mustache.ParseString(string(eh.Content))
+	if err != nil {
+		return nil, err
+	}
+	timeout := defaultSuggestionsTimeout
+	if d, err := time.ParseDuration(cfg.Timeout); err == nil && d > 0 {
+		timeout = d
+	}
+	return &DynamicErrorHandler{
+		Template:       tmpl,
+		ErrorCode:      eh.ErrorCode,
+		Extra:          extra,
+		SuggestionsURL: cfg.SuggestionsURL,
+		client:         &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// DynamicErrorHandler is an ErrorHandler rendering its template with the context of the failure.
+// The not found pages get the suggestions of the SuggestionsURL, if any
+type DynamicErrorHandler struct {
+	Template  *mustache.Template
+	ErrorCode int
+	Extra     map[string]interface{}
+	// SuggestionsURL is the backend URL of the suggestions, with the :path placeholder replaced
+	// with the requested path
+	SuggestionsURL string
+	client         *http.Client
+}
+
+// HandlerFunc is a gin middleware rendering the aborted requests with the status code of the
+// handler
+func (e *DynamicErrorHandler) HandlerFunc() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if !c.IsAborted() || c.Writer.Status() != e.ErrorCode || c.Writer.Size() > 0 {
+			return
+		}
+		e.write(c)
+	}
+}
+
+// NoRouteHandlerFunc is a gin handler rendering the requests dispatched by the gin special
+// handlers (NoRoute, NoMethod)
+func (e *DynamicErrorHandler) NoRouteHandlerFunc() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		HooksFromContext(c).OnRequest(c, "DynamicErrorHandler")
+		c.Status(e.ErrorCode)
+		e.write(c)
+	}
+}
+
+func (e *DynamicErrorHandler) write(c *gin.Context) {
+	var err error
+	if last := c.Errors.Last(); last != nil {
+		err = last.Err
+	}
+	ctx := newErrorContext(c, e.ErrorCode, err)
+	if e.ErrorCode == http.StatusNotFound && e.SuggestionsURL != "" {
+		ctx.Suggestions = e.suggestions(c.Request.URL.Path)
+	}
+	body, rerr := e.Template.Render(ResponseContext{
+		Extra:   requestExtra(c, e.Extra),
+		Helper:  newTplHelper(c),
+		Context: c,
+		Error:   ctx,
+	})
+	if rerr != nil {
+		log.Println("rendering the", e.ErrorCode, "template:", rerr.Error())
+		return
+	}
+	if c.Writer.Header().Get("Content-Type") == "" {
+		c.Header("Content-Type", "text/html; charset=utf-8")
+	}
+	c.Writer.Write([]byte(body))
+}
+
+// suggestions returns the decoded response of the suggestions backend for the path or nil if
+// the request fails
+func (e *DynamicErrorHandler) suggestions(path string) interface{} {
+	resp, err := e.client.Get(strings.Replace(e.SuggestionsURL, ":path", url.QueryEscape(path), -1))
+	if err != nil {
+		log.Println("requesting the suggestions:", err.Error())
+		return nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Println("requesting the suggestions: unexpected status code", resp.StatusCode)
+		return nil
+	}
+	var v interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		log.Println("decoding the suggestions:", err.Error())
+		return nil
+	}
+	return v
+}
+
+// errorHandlerFunc returns the middleware of the ErrorHandler or, if the error pages of the
+// config are dynamic, the one of a DynamicErrorHandler with its content
+func errorHandlerFunc(cfg Config, eh ErrorHandler) gin.HandlerFunc {
+	if cfg.DynamicErrorPages == nil {
+		return eh.HandlerFunc()
+	}
+	d, err := NewDynamicErrorHandler(eh, *cfg.DynamicErrorPages, cfg.Extra)
+	if err != nil {
+		log.Println("parsing the", eh.ErrorCode, "template:", err.Error())
+		return eh.HandlerFunc()
+	}
+	return d.HandlerFunc()
+}
+
+// notFoundHandlerFunc returns the handler of the StaticHandler or, if the error pages of the
+// config are dynamic, the one of a DynamicErrorHandler with its content
+func notFoundHandlerFunc(cfg Config, sh StaticHandler) gin.HandlerFunc {
+	if cfg.DynamicErrorPages == nil {
+		return sh.HandlerFunc()
+	}
+	d, err := NewDynamicErrorHandler(ErrorHandler{sh.Content, http.StatusNotFound}, *cfg.DynamicErrorPages, cfg.Extra)
+	if err != nil {
+		log.Println("parsing the 404 template:", err.Error())
+		return sh.HandlerFunc()
+	}
+	return d.NoRouteHandlerFunc()
+}
@@ -0,0 +1,68 @@
+package engine
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDynamicErrorHandler(t *testing.T) {
+	search := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `[{"title": "results for %s", "url": "/search"}]`, r.URL.Query().Get("q"))
+	}))
+	defer search.Close()
+
+	cfg := DynamicErrorPages{SuggestionsURL: search.URL + "/?q=:path"}
+	extra := map[string]interface{}{"site": "shop"}
+	notFound, err := NewDynamicErrorHandler(ErrorHandler{
+		[]byte(`{{Extra.site}} {{Error.Class}} {{Error.Path}}{{#Error.Suggestions}} <a href="{{url}}">{{title}}</a>{{/Error.Suggestions}} {{Error.RequestID}}`),
+		http.StatusNotFound,
+	}, cfg, extra)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	badGateway, err := NewDynamicErrorHandler(ErrorHandler{
+		[]byte(`{{Error.Status}} {{Error.Class}}: {{Error.Message}}`),
+		http.StatusBadGateway,
+	}, cfg, extra)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(badGateway.HandlerFunc())
+	e.NoRoute(notFound.NoRouteHandlerFunc())
+	e.GET("/a", func(c *gin.Context) {
+		c.AbortWithError(http.StatusBadGateway, &ResponseError{StatusCode: http.StatusBadGateway, Message: "the catalog is down"})
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/unknown/page", nil)
+	req.Header.Set(RequestIDHeader, "abc")
+	e.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+	if body := w.Body.String(); body != `shop not_found /unknown/page <a href="/search">results for /unknown/page</a> abc` {
+		t.Errorf("unexpected body: %s", body)
+	}
+
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/a", nil))
+	if w.Code != http.StatusBadGateway || w.Body.String() != "502 bad_gateway: the catalog is down" {
+		t.Errorf("unexpected response: %d %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get(RequestIDHeader) == "" {
+		t.Error("expecting a generated request id")
+	}
+
+	if _, err := NewDynamicErrorHandler(ErrorHandler{[]byte("{{#a}}"), http.StatusNotFound}, cfg, nil); err == nil {
+		t.Error("expecting an error with an invalid template")
+	}
+}
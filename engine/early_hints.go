@@ -0,0 +1,107 @@
+package engine
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var preloadDestinations = map[string]string{
+	".css":   "style",
+	".js":    "script",
+	".mjs":   "script",
+	".woff":  "font",
+	".woff2": "font",
+	".ttf":   "font",
+	".otf":   "font",
+	".png":   "image",
+	".jpg":   "image",
+	".jpeg":  "image",
+	".gif":   "image",
+	".svg":   "image",
+	".webp":  "image",
+	".avif":  "image",
+}
+
+// NewEarlyHintsMiddleware returns a gin middleware adding a preload Link header for every
+// critical asset of the received config and sending them in a 103 Early Hints response, so the
+// browsers fetch them while the backends are requested and the page is rendered. If the config
+// enables the push and the connection supports it, the assets are pushed instead
+func NewEarlyHintsMiddleware(cfg EarlyHints) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		urls := criticalAssetURLs(cfg.Assets, AssetsFromContext(c))
+		header := c.Writer.Header()
+		for i, asset := range cfg.Assets {
+			header.Add("Link", preloadLink(urls[i], asset.As))
+		}
+
+		if cfg.Push {
+			if pusher := c.Writer.Pusher(); pusher != nil {
+				for _, u := range urls {
+					pusher.Push(u, nil)
+				}
+				c.Next()
+				return
+			}
+		}
+		// the 1xx responses can not be sent to the HTTP/1.0 clients
+		if len(urls) > 0 && c.Request.ProtoAtLeast(1, 1) {
+			writeEarlyHints(c.Writer)
+		}
+		c.Next()
+	}
+}
+
+// criticalAssetURLs returns the URLs of the assets, replacing the paths relative to the public
+// folder with the URLs in the asset manifest, so the fingerprinted copies are preloaded
+func criticalAssetURLs(assets []CriticalAsset, m AssetManifest) []string {
+	urls := make([]string, len(assets))
+	for i, asset := range assets {
+		urls[i] = asset.Path
+		if a, ok := m[AssetKey(asset.Path)]; ok {
+			urls[i] = a.URL
+		}
+	}
+	return urls
+}
+
+// preloadLink returns the value of the preload Link header of the asset at the received URL. If
+// the destination is empty, it is guessed from the extension of the URL
+func preloadLink(u, as string) string {
+	if as == "" {
+		as = preloadDestinations[strings.ToLower(path.Ext(u))]
+	}
+	link := fmt.Sprintf("<%s>; rel=preload", u)
+	if as != "" {
+		link += "; as=" + as
+	}
+	// the fonts are always fetched in anonymous mode
+	if as == "font" {
+		link += "; crossorigin"
+	}
+	return link
+}
+
+// writeEarlyHints sends the Link headers already set as a 103 Early Hints response. The gin
+// writer delays the status codes until the body is written, so the informational response is
+// sent through the wrapped writer. The rest of the headers are hidden while sending it
+func writeEarlyHints(w gin.ResponseWriter) {
+	u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+	if !ok {
+		return
+	}
+	header := w.Header()
+	final := header.Clone()
+	for name := range header {
+		if name != "Link" {
+			delete(header, name)
+		}
+	}
+	u.Unwrap().WriteHeader(http.StatusEarlyHints)
+	for name, values := range final {
+		header[name] = values
+	}
+}
@@ -0,0 +1,79 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNewEarlyHintsMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(AssetsMiddleware(AssetManifest{
+		"css_app_css": {Path: "css/app.css", URL: "/static/css/app.1a2b3c4d.css"},
+	}))
+	e.GET("/", func(c *gin.Context) {
+		c.Header("Set-Cookie", "session=abc")
+		c.Next()
+	}, NewEarlyHintsMiddleware(EarlyHints{Assets: []CriticalAsset{
+		{Path: "css/app.css"},
+		{Path: "/fonts/main.woff2"},
+		{Path: "https://cdn.example.com/app", As: "script"},
+	}}), func(c *gin.Context) {
+		c.String(http.StatusOK, "hi")
+	})
+	s := httptest.NewServer(e)
+	defer s.Close()
+
+	expected := []string{
+		"</static/css/app.1a2b3c4d.css>; rel=preload; as=style",
+		"</fonts/main.woff2>; rel=preload; as=font; crossorigin",
+		"<https://cdn.example.com/app>; rel=preload; as=script",
+	}
+	var hints []textproto.MIMEHeader
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			if code == http.StatusEarlyHints {
+				hints = append(hints, header)
+			}
+			return nil
+		},
+	}
+	req, _ := http.NewRequest("GET", s.URL, nil)
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	resp.Body.Close()
+
+	if len(hints) != 1 {
+		t.Errorf("unexpected number of early hints: %d", len(hints))
+		return
+	}
+	if hints[0].Get("Set-Cookie") != "" {
+		t.Error("the early hints should only contain the links")
+	}
+	assertLinks(t, hints[0]["Link"], expected)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Set-Cookie") != "session=abc" {
+		t.Errorf("unexpected response: %d %v", resp.StatusCode, resp.Header)
+	}
+	assertLinks(t, resp.Header["Link"], expected)
+}
+
+func assertLinks(t *testing.T, links, expected []string) {
+	if len(links) != len(expected) {
+		t.Errorf("unexpected links: %v", links)
+		return
+	}
+	for i, link := range links {
+		if link != expected[i] {
+			t.Errorf("unexpected link #%d: %s", i, link)
+		}
+	}
+}
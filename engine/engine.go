@@ -12,9 +12,7 @@
 //	 		return errNilEngine
 //	 	}
 //
-//	 	time.Sleep(time.Second)
-//
-//	 	return eW.Run(fmt.Sprintf(":%d", port))
+//	 	return e.Run(fmt.Sprintf(":%d", port))
 //	}
 package engine
 
@@ -24,7 +22,6 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	newrelic "github.com/newrelic/go-agent"
 )
 
 // Config is a struct with all the required definitions for building an API2HTML engine
@@ -38,12 +35,441 @@ type Config struct {
 	Extra            map[string]interface{} `json:"extra"`
 	PublicFolder     *PublicFolder          `json:"public_folder"`
 	NewRelic         *NewRelic              `json:"newrelic"`
+	Prometheus       *Prometheus            `json:"prometheus"`
+	OpenTelemetry    *OpenTelemetry         `json:"opentelemetry"`
+	RequestLogging   *RequestLogging        `json:"request_logging"`
+	AssetPipeline    *AssetPipeline         `json:"asset_pipeline"`
+	GeoIP            *GeoIP                 `json:"geoip"`
+	RobotsTXT        *RobotsTXT             `json:"robots_txt"`
+	WellKnown        []WellKnown            `json:"well_known"`
+	AuthPages        *AuthPages             `json:"auth_pages"`
+	// AccessLog writes a structured JSON record for every request to the configured sinks
+	AccessLog *AccessLog `json:"access_log"`
+	// TemplateVersions is the number of versions of every template and layout kept in devel
+	// mode, so they can be rolled back. Defaults to 10
+	TemplateVersions int `json:"template_versions"`
+	// TemplateCanary enables the canary rollouts of the pushed and reloaded templates
+	TemplateCanary *TemplateCanary `json:"template_canary"`
+	// I18n detects the locale of the requests and translates the templates
+	I18n *I18n `json:"i18n"`
+	// Health configures the liveness and readiness endpoints
+	Health *HealthChecks `json:"health"`
+	// Warmup renders some URLs on start, before reporting the instance as ready
+	Warmup *Warmup `json:"warmup"`
+	// Refresh declares the endpoint reloading the templates and purging the caches on demand
+	Refresh *Refresh `json:"refresh"`
+	// Admin declares the endpoints describing the pages at runtime
+	Admin *Admin `json:"admin"`
+	// LiveReload makes the browsers reload the pages on every template update, in devel mode
+	LiveReload bool `json:"live_reload"`
+	// Plugins are the paths of the Go plugins registering response generators and renderers
+	Plugins []string `json:"plugins"`
+	// TLS serves the pages over HTTPS
+	TLS *TLS `json:"tls"`
+	// FileSource selects the files taking precedence when the engine has embedded files:
+	// embedded or disk. Defaults to disk in devel mode and to embedded otherwise
+	FileSource string `json:"file_source"`
+	// TemplateSets declares alternative versions of the templates and layouts, so all the pages
+	// can be switched between them at once
+	TemplateSets *TemplateSets `json:"template_sets"`
+	TrapRoutes   *TrapRoutes   `json:"trap_routes"`
+	// TracePropagation forwards the inbound trace headers to the backends
+	TracePropagation *TracePropagation `json:"trace_propagation"`
+	// LoadShedding rejects the requests while the server is saturated
+	LoadShedding *LoadShedding `json:"load_shedding"`
+	// RateLimit limits the requests of the whole site
+	RateLimit *RateLimit `json:"rate_limit"`
+	// RenderProxy renders all the backend endpoints under a prefix without declaring a page
+	// for each one of them
+	RenderProxy *RenderProxy `json:"render_proxy"`
+	// Helpers declares the built-in template helpers available to the mustache templates as
+	// lambda sections, using the keys as names
+	Helpers map[string]TemplateHelper `json:"helpers"`
+	// BackendCache selects the store of the cached backend responses
+	BackendCache *BackendCache `json:"backend_cache"`
+	// PageCache selects the store of the rendered pages enabling the page cache
+	PageCache *PageCache `json:"page_cache"`
+	// Middlewares declares the named middlewares the pages can add to their routes
+	Middlewares map[string]PageMiddleware `json:"middlewares"`
+	// TrustedProxies are the IPs and CIDRs of the proxies in front of the engine. The client IP
+	// used by the IP allowlists, the rate limits, the trap routes and the logs is taken from the
+	// X-Forwarded-For and X-Real-IP headers only when the request comes from one of them. By
+	// default, no proxy is trusted and the client IP is the remote address of the request
+	TrustedProxies []string `json:"trusted_proxies"`
+	// Protect adds named middlewares to all the requests under a path prefix, like the basic
+	// auth of a staging site or the IP allowlist of the admin pages
+	Protect []ProtectedPath `json:"protect"`
+	// RemoteTemplates loads the templates, layouts and partials from a bucket, reloading them
+	// when they change
+	RemoteTemplates *RemoteTemplates `json:"remote_templates"`
+	// GitTemplates loads the templates, layouts and partials from a git repository, pulling it
+	// periodically and on every push notification
+	GitTemplates *GitTemplates `json:"git_templates"`
+	// SitemapXML generates the sitemap.xml file with the URLs of the pages, replacing the
+	// static/sitemap.xml file
+	SitemapXML *SitemapXML `json:"sitemap_xml"`
+	// CSP adds a Content-Security-Policy header with a per request nonce to all the responses
+	CSP *ContentSecurityPolicy `json:"csp"`
+	// OAuth2Clients declares the OAuth2 clients the pages can use to authorize their backend
+	// requests with bearer tokens, using the keys as names
+	OAuth2Clients map[string]OAuth2Client `json:"oauth2_clients"`
+	// Environment is the name of the environment the engine runs in (staging, production...).
+	// The API2HTML_ENVIRONMENT env var takes precedence over it
+	Environment string `json:"environment"`
+	// PartialCache keeps the rendered versions of the partials used as keys, so the expensive
+	// sections are not rendered on every request
+	PartialCache map[string]CachedPartial `json:"partial_cache"`
+	// DynamicErrorPages renders the error templates of the static folder as mustache templates
+	// with the context of the failures
+	DynamicErrorPages *DynamicErrorPages `json:"dynamic_error_pages"`
+	// StatusMapping maps the status codes of the failed backend responses to the responses of
+	// all the pages. The mappings of the pages take precedence
+	StatusMapping map[int]StatusMapping `json:"status_mapping"`
+	// Canonical redirects the requests of all the pages to their canonical URLs and exposes them
+	// to the templates. The pages can replace it with their own
+	Canonical *CanonicalURL `json:"canonical"`
+	// Include contains the paths or glob patterns of the config files merged into this one,
+	// relative to its folder. Their pages are appended and their templates and layouts are
+	// added. The rest of their sections are ignored
+	Include []string `json:"include"`
+	// Redirects are the rules redirecting the legacy URLs to their new location, checked in
+	// order before routing the requests to the pages
+	Redirects []URLRedirect `json:"redirects"`
+	// Rewrites are the rules serving the legacy URLs with the pages of other URLs, checked in
+	// order before the redirects
+	Rewrites []URLRewrite `json:"rewrites"`
+	// includeDirs are the folders of the include patterns, watched by the Reloader
+	includeDirs []string
+	// registry has the helpers, the decoders, the plugins, the caches and the clients of the
+	// engine of the config
+	registry *registry
+}
+
+// CanonicalURL contains the canonicalization of the URLs of the pages
+type CanonicalURL struct {
+	// TrailingSlash is the policy of the trailing slash of the URL patterns: strip (/foo/ is
+	// redirected to /foo) or add (/foo is redirected to /foo/). By default, the URL patterns
+	// are kept as declared
+	TrailingSlash string `json:"trailing_slash"`
+	// Lowercase redirects the paths with upper case letters to their lower case version
+	Lowercase bool `json:"lowercase"`
+	// StripParams are the query params redirected to the URL without them, like the tracking
+	// ones. The names ending with an asterisk are prefixes (utm_*)
+	StripParams []string `json:"strip_params"`
+	// BaseURL is the scheme and host of the canonical URL exposed to the templates as
+	// Extra.canonical_url (https://www.example.com). Defaults to the ones of the request
+	BaseURL string `json:"base_url"`
+	// Disabled turns off the canonical URLs of the config for a page
+	Disabled bool `json:"disabled"`
+}
+
+// SitemapXML contains the settings of the generated sitemap.xml file
+type SitemapXML struct {
+	// BaseURL is the scheme and host prepended to the paths of the pages (https://example.com)
+	BaseURL string `json:"base_url"`
+	// Interval is the time between regenerations of the sitemap. Defaults to 1h
+	Interval string `json:"interval"`
+}
+
+// GitTemplates contains the git repository storing the templates, layouts and partials
+type GitTemplates struct {
+	// Repository is the URL of the repository, cloned with the git command
+	Repository string `json:"repository"`
+	// Branch is the branch to pull. Defaults to main
+	Branch string `json:"branch"`
+	// Dir is the folder of the local clone. Defaults to api2html-templates in the temp folder
+	Dir string `json:"dir"`
+	// Interval is the time between pulls. Defaults to 1m
+	Interval string `json:"interval"`
+	// WebhookPath is the path of the endpoint receiving the push notifications. Defaults to
+	// /__api2html/git-templates
+	WebhookPath string `json:"webhook_path"`
+	// WebhookSecret verifies the X-Hub-Signature-256 header of the push notifications
+	WebhookSecret string `json:"webhook_secret"`
+}
+
+// RemoteTemplates contains the bucket storing the templates, layouts and partials
+type RemoteTemplates struct {
+	// URL is the URL of the bucket, like s3://my-bucket?region=eu-west-1 or gs://my-bucket
+	URL string `json:"url"`
+	// Prefix is the folder of the bucket the paths of the config are relative to
+	Prefix string `json:"prefix"`
+	// Interval is the time between checks of the bucket. Defaults to 30s
+	Interval string `json:"interval"`
+}
+
+// PageMiddleware declares a named middleware of the pages
+type PageMiddleware struct {
+	// Type is the built-in middleware: basic_auth, ip_allowlist, request_logging, headers or jwt
+	Type string `json:"type"`
+	// Users contains the passwords of the basic_auth users
+	Users map[string]string `json:"users"`
+	// Realm is the realm of the basic_auth challenge. Defaults to Authorization Required
+	Realm string `json:"realm"`
+	// Allow contains the IPs and CIDRs allowed by the ip_allowlist
+	Allow []string `json:"allow"`
+	// MaxBodySize and RedactedFields configure the request_logging like the RequestLogging
+	MaxBodySize    int      `json:"max_body_size"`
+	RedactedFields []string `json:"redacted_fields"`
+	// Headers are the response headers added by the headers middleware
+	Headers map[string]string `json:"headers"`
+	// JWT contains the validation of the tokens of the jwt middleware
+	JWT *JWTAuth `json:"jwt"`
+}
+
+// ProtectedPath contains the named middlewares of the requests under a path prefix
+type ProtectedPath struct {
+	// Prefix is the path prefix of the protected requests (/admin). It matches whole segments,
+	// so /admin does not protect /administrators. Use / to protect the whole site
+	Prefix string `json:"prefix"`
+	// Middlewares are the names of the middlewares of the requests, in order
+	Middlewares []string `json:"middlewares"`
+}
+
+// JWTAuth contains the keys and the claims validating the JWT of the requests
+type JWTAuth struct {
+	// Secret verifies the tokens signed with HMAC (HS256, HS384 and HS512)
+	Secret string `json:"secret"`
+	// JWKSURL is the endpoint of the key set verifying the tokens signed with RSA (RS256, RS384
+	// and RS512) or ECDSA (ES256, ES384 and ES512)
+	JWKSURL string `json:"jwks_url"`
+	// JWKSRefresh is the time between the refreshes of the key set. Defaults to 1h
+	JWKSRefresh string `json:"jwks_refresh"`
+	// Issuer and Audience, if not empty, must match the iss and aud claims of the tokens
+	Issuer   string `json:"issuer"`
+	Audience string `json:"audience"`
+	// Cookie is the name of the cookie with the token, used if there is no bearer token in the
+	// Authorization header
+	Cookie string `json:"cookie"`
+	// Claims are the claims exposed to the templates as Extra.user. If empty, all of them are
+	// exposed
+	Claims []string `json:"claims"`
+	// Leeway is the clock skew tolerated checking the exp and nbf claims. Defaults to 1m
+	Leeway string `json:"leeway"`
+}
+
+// BackendCache contains the store of the cached backend responses, so they can be shared by
+// several instances
+type BackendCache struct {
+	// Store is the type of the store: memory (the default) or redis
+	Store string `json:"store"`
+	// Address is the host and port of the redis server
+	Address  string `json:"address"`
+	Password string `json:"password"`
+	DB       int    `json:"db"`
+	// Prefix is added to the keys of the entries. Defaults to api2html:
+	Prefix string `json:"prefix"`
+}
+
+// PageCache declares the store of the rendered pages and their invalidation endpoints
+type PageCache struct {
+	BackendCache
+	// Vary lists the request headers whose values select the cached version of the pages, along
+	// with their URLs
+	Vary []string `json:"vary"`
+	// Token is required as a bearer token by the invalidation endpoints. If empty, they are
+	// available just in devel mode
+	Token string `json:"token"`
+}
+
+// TemplateHelper declares a built-in template helper, like
+// {{#formatDate}}{{ created_at }}{{/formatDate}}
+type TemplateHelper struct {
+	// Type is the built-in helper: date, truncate, currency, upper or lower
+	Type string `json:"type"`
+	// Format is the Go layout of the date helper (defaults to 2006-01-02), the max number of
+	// chars of the truncate helper or the symbol of the currency helper
+	Format string `json:"format"`
+}
+
+// RenderProxy contains the backend proxied under the prefix and the templates rendering its
+// responses
+type RenderProxy struct {
+	// Prefix is the path prefix of the proxied requests, like /api. It is removed from the
+	// backend requests
+	Prefix string `json:"prefix"`
+	// Backend is the base URL receiving the path and the query of the proxied requests
+	Backend string `json:"backend"`
+	// Template and Layout render the responses not matching any route
+	Template string `json:"template"`
+	Layout   string `json:"layout"`
+	// Routes override the template and the layout of the paths matching them, in order
+	Routes []RenderProxyRoute `json:"routes"`
+	// CacheTTL is the max age of the rendered responses. Defaults to 1h
+	CacheTTL string `json:"cache_ttl"`
+}
+
+// RenderProxyRoute contains the template and the layout of the proxied paths matching the
+// pattern
+type RenderProxyRoute struct {
+	// Pattern is the path.Match pattern of the paths, without the prefix, like /products/*
+	Pattern  string `json:"pattern"`
+	Template string `json:"template"`
+	Layout   string `json:"layout"`
+}
+
+// LoadShedding contains the limits of the server. Every request exceeding any of them gets a
+// 503 response
+type LoadShedding struct {
+	// MaxInFlight is the max number of requests processed at the same time. If zero, they are
+	// not limited
+	MaxInFlight int `json:"max_in_flight"`
+	// QueueTimeout is the max time the excess requests wait for a free slot. If empty, they are
+	// rejected immediately
+	QueueTimeout string `json:"queue_timeout"`
+	// MaxHeapMB is the max size of the allocated heap, in megabytes. If zero, the memory
+	// pressure is ignored
+	MaxHeapMB int `json:"max_heap_mb"`
+}
+
+// TracePropagation contains the trace headers to forward to the backends
+type TracePropagation struct {
+	// Headers is the list of headers to forward. Defaults to the W3C Trace Context, B3 and
+	// X-Cloud-Trace-Context headers
+	Headers []string `json:"headers"`
+}
+
+// TrapRoutes contains the paths only requested by scanners and the penalty for their visitors
+type TrapRoutes struct {
+	// Paths contains the trap paths or path.Match patterns, like /wp-login.php or /*.php
+	Paths []string `json:"paths"`
+	// Tarpit is the delay of the responses to the trap paths. If empty, they are not delayed
+	Tarpit string `json:"tarpit"`
+	// BlockDuration is the time the visitors of the trap paths get a 403 response for every
+	// request. If empty, they are not blocked
+	BlockDuration string `json:"block_duration"`
+}
+
+// TemplateSets contains the named template sets and the active one
+type TemplateSets struct {
+	// Sets contains the templates and layouts of every set, overriding the global ones
+	Sets map[string]TemplateSet `json:"sets"`
+	// Active is the set used on start. Defaults to the first one in alphabetical order
+	Active string `json:"active"`
+	// Token is the bearer token required by the switch endpoints. If empty, they are only
+	// exposed in devel mode
+	Token string `json:"token"`
+}
+
+// TemplateSet contains the templates and layouts of a template set
+type TemplateSet struct {
+	Templates map[string]string `json:"templates"`
+	Layouts   map[string]string `json:"layouts"`
+}
+
+// HealthChecks contains the paths of the liveness and readiness endpoints and the backends
+// probed by the readiness one
+type HealthChecks struct {
+	// Path is the path of the liveness endpoint. Defaults to /healthz
+	Path string `json:"path"`
+	// ReadinessPath is the path of the readiness endpoint. Defaults to /readyz
+	ReadinessPath string `json:"readiness_path"`
+	// Backends contains the URLs probed by the readiness endpoint by name
+	Backends map[string]string `json:"backends"`
+	// Timeout is the max duration of every probe. Defaults to 2s
+	Timeout string `json:"timeout"`
+}
+
+// Admin declares the endpoints describing the pages, their templates, backends and cache TTLs and
+// their failures
+type Admin struct {
+	// Path is the path of the endpoints. Defaults to /admin
+	Path string `json:"path"`
+	// Token is required as a bearer token. If empty, the endpoints are available just in devel
+	// mode
+	Token string `json:"token"`
+}
+
+// Refresh declares the endpoint reloading the templates from their source and purging the caches
+type Refresh struct {
+	// Path is the path of the endpoint. Defaults to /admin/refresh
+	Path string `json:"path"`
+	// Token is required as a bearer token. If empty, the endpoint is available just in devel mode
+	Token string `json:"token"`
+}
+
+// Warmup lists the URLs rendered on start, before the instance reports itself as ready
+type Warmup struct {
+	// URLs are the paths of the requests, with their query strings
+	URLs []string `json:"urls"`
+	// StaticPages adds the URL patterns of the pages without params to the URLs
+	StaticPages bool `json:"static_pages"`
+	// Headers are added to every request
+	Headers map[string]string `json:"headers"`
+	// Concurrency is the number of URLs rendered at the same time. Defaults to 4
+	Concurrency int `json:"concurrency"`
+	// Timeout is the max duration of the warm up. Defaults to 1m
+	Timeout string `json:"timeout"`
+}
+
+// TLS contains the certificate and key files served over HTTPS or the autocert config, and the
+// address of the plain HTTP listener redirecting to HTTPS
+type TLS struct {
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+	// Autocert obtains and renews the certificates from Let's Encrypt instead of loading the files
+	Autocert *Autocert `json:"autocert"`
+	// RedirectAddress is the address of the listener redirecting the plain HTTP requests to
+	// HTTPS and answering the ACME challenges, like :80. Disabled if empty
+	RedirectAddress string `json:"redirect_address"`
+}
+
+// Autocert contains the hosts allowed to get a certificate and the directory caching them
+type Autocert struct {
+	Hosts []string `json:"hosts"`
+	// CacheDir keeps the certificates between restarts. Recommended, since Let's Encrypt
+	// rate-limits the issuance
+	CacheDir string `json:"cache_dir"`
+	Email    string `json:"email"`
+	// DirectoryURL is the ACME directory, like the Let's Encrypt staging one. Defaults to the
+	// Let's Encrypt production directory
+	DirectoryURL string `json:"directory_url"`
+}
+
+// I18n contains the supported locales, the ways to select them and their translation bundles
+type I18n struct {
+	// Locales are the supported locales (en, es, pt-BR). The first one is the default
+	Locales []string `json:"locales"`
+	// Bundles contains the path of the translation bundle of every locale: a JSON file, with
+	// the nested keys joined by dots, or a gettext PO file
+	Bundles map[string]string `json:"bundles"`
+	// Cookie is the name of the cookie selecting the locale
+	Cookie string `json:"cookie"`
+	// PathPrefix registers the pages under the prefix of every locale too (/es/products),
+	// selecting the locale
+	PathPrefix bool `json:"path_prefix"`
+}
+
+// TemplateCanary contains the config of the canary rollouts of the templates
+type TemplateCanary struct {
+	// Percentage is the percentage of the visitors getting the new versions until they are
+	// promoted
+	Percentage int `json:"percentage"`
+	// Cookie is the name of the cookie keeping every visitor in the same version. Defaults to
+	// api2html_canary
+	Cookie string `json:"cookie"`
 }
 
 // PublicFolder contains the info regarding the static contents to be served
 type PublicFolder struct {
 	Path   string `json:"path_to_folder"`
 	Prefix string `json:"url_prefix"`
+	// SRI enables the hashing of the public files, exposing them to the templates as Helper.Assets
+	SRI bool `json:"sri"`
+	// CacheControl is the Cache-Control header of the public files not matching any of the
+	// CachePolicies. If empty, no header is added
+	CacheControl string `json:"cache_control"`
+	// CachePolicies are the Cache-Control headers to add to the public files, depending on their
+	// path. The first matching policy wins
+	CachePolicies []CachePolicy `json:"cache_policies"`
+}
+
+// CachePolicy defines the Cache-Control header of the public files matching a pattern
+type CachePolicy struct {
+	// Pattern is a glob matched against the path of the file, relative to the public folder. If it
+	// has no slashes, it is matched against the name of the file (*.css, *.????????.js...)
+	Pattern      string `json:"pattern"`
+	CacheControl string `json:"cache_control"`
 }
 
 // NewRelic contains the info regarding the app name and the newrelic license key
@@ -52,6 +478,141 @@ type NewRelic struct {
 	License string `json:"license"`
 }
 
+// Prometheus contains the info regarding the prometheus metrics endpoint
+type Prometheus struct {
+	// Path is the path of the metrics endpoint. Defaults to /metrics
+	Path string `json:"path"`
+	// Namespace is the prefix of all the metric names. Defaults to api2html
+	Namespace string `json:"namespace"`
+	// Buckets are the boundaries of the duration histograms, in seconds. Defaults to the
+	// prometheus ones
+	Buckets []float64 `json:"buckets"`
+	// PageBuckets overrides the Buckets of the pages used as keys
+	PageBuckets map[string][]float64 `json:"page_buckets"`
+	// Labels are extra labels added to every metric, with the request value of the source used
+	// as value: header:X-Tenant, cookie:locale, query:variant or param:locale
+	Labels map[string]string `json:"labels"`
+}
+
+// OpenTelemetry contains the info regarding the OTLP collector receiving the traces
+type OpenTelemetry struct {
+	// ServiceName is the name of the service reported with every span. Defaults to api2html
+	ServiceName string `json:"service_name"`
+	// Endpoint is the host and port of the OTLP/HTTP collector
+	Endpoint string `json:"endpoint"`
+	// Insecure disables the TLS when connecting to the collector
+	Insecure bool `json:"insecure"`
+	// SampleRatio is the ratio of the new traces sampled, between 0 and 1. The inbound traces
+	// keep their sampling decision. If zero, all the traces are sampled
+	SampleRatio float64 `json:"sample_ratio"`
+}
+
+// RequestLogging contains the info regarding the pages whose inbound requests and backend
+// responses should be logged
+type RequestLogging struct {
+	// Pages is the list of page names to log. If empty, all the pages will be logged
+	Pages []string `json:"pages"`
+	// MaxBodySize is the max number of bytes of every body to log. Defaults to 4096
+	MaxBodySize int `json:"max_body_size"`
+	// RedactedFields is the list of headers, query params and body fields to hide
+	RedactedFields []string `json:"redacted_fields"`
+}
+
+// AccessLog contains the sinks of the access records
+type AccessLog struct {
+	// Sinks are the destinations of the records. Defaults to the standard output
+	Sinks []LogSink `json:"sinks"`
+	// AppLogs writes the messages of the engine to the sinks as JSON records too
+	AppLogs bool `json:"app_logs"`
+}
+
+// LogSink defines a destination of the structured logs
+type LogSink struct {
+	// Type is the kind of sink: stdout, stderr, file or syslog
+	Type string `json:"type"`
+	// Path is the file the records are appended to
+	Path string `json:"path"`
+	// Network and Address locate a remote syslog daemon (udp, logs.example.com:514). If empty,
+	// the local one is used
+	Network string `json:"network"`
+	Address string `json:"address"`
+	// Tag is the syslog tag of the records. Defaults to api2html
+	Tag string `json:"tag"`
+}
+
+// RobotsTXT contains the rules of the generated robots.txt file. If defined, it replaces the
+// static/robots.txt file
+type RobotsTXT struct {
+	// Rules are the groups of directives, one per user agent
+	Rules []RobotsRule `json:"rules"`
+	// Sitemaps are the absolute URLs of the sitemaps to advertise
+	Sitemaps []string `json:"sitemaps"`
+	// NoIndex disallows the crawling of the whole site and marks all the pages as noindex,
+	// ignoring the rules and the page directives. Intended for staging environments
+	NoIndex bool `json:"noindex"`
+	// Environments replaces the rules, sitemaps and noindex flag with the ones declared for the
+	// environment of the engine, using the environment names as keys
+	Environments map[string]RobotsTXT `json:"environments"`
+}
+
+// RobotsRule defines the paths allowed and disallowed for a user agent
+type RobotsRule struct {
+	// UserAgent is the crawler the rule applies to. Defaults to *
+	UserAgent string   `json:"user_agent"`
+	Allow     []string `json:"allow"`
+	Disallow  []string `json:"disallow"`
+}
+
+// AuthPages contains the info regarding the pages rendered when the auth middlewares reject a
+// request
+type AuthPages struct {
+	// LoginURL is the route the unauthenticated users are redirected to, with the signed
+	// return_to param. If empty, the 401 page is rendered
+	LoginURL string `json:"login_url"`
+	// Secret is the key signing the return_to param. Required if the LoginURL is defined
+	Secret string `json:"secret"`
+}
+
+// WellKnown defines a boilerplate file (security.txt, humans.txt, site.webmanifest...) rendered
+// on start with the global extra values
+type WellKnown struct {
+	// Path is the URL path of the file, like /.well-known/security.txt
+	Path string `json:"path"`
+	// Template is the path of the mustache template of the file
+	Template string `json:"template"`
+	// Content is the inline mustache template of the file, used if no Template is defined
+	Content string `json:"content"`
+	// ContentType is the Content-Type header of the file. Defaults to the one of its extension
+	ContentType string `json:"content_type"`
+}
+
+// GeoIP contains the info regarding the MaxMind database locating the clients
+type GeoIP struct {
+	// Database is the path of the GeoIP2 or GeoLite2 (City or Country) database
+	Database string `json:"database"`
+}
+
+// AssetPipeline contains the commands building the assets before serving them
+type AssetPipeline struct {
+	// Steps are the commands to run, in order
+	Steps []AssetStep `json:"steps"`
+	// Fingerprint adds the hash of their content to the name of the generated files, so they can
+	// be cached forever. It is ignored in devel mode
+	Fingerprint bool `json:"fingerprint"`
+}
+
+// AssetStep defines a command of the asset pipeline
+type AssetStep struct {
+	Name string `json:"name"`
+	// Command is the program to run and its args
+	Command []string `json:"command"`
+	// Sources are the files and folders to watch in devel mode, rerunning the command on every change
+	Sources []string `json:"sources"`
+	// Output is the file or folder generated by the command. It must be inside the public folder
+	// in order to be fingerprinted
+	Output string `json:"output"`
+}
+
 // Page defines the behaviour of the engine for a given URL pattern
 type Page struct {
 	Name              string
@@ -63,11 +624,483 @@ type Page struct {
 	Header            string
 	IsArray           bool
 	Extra             map[string]interface{}
+	// BackendFailover defines the alternative hosts of the backend
+	BackendFailover *BackendFailover `json:"backend_failover"`
+	// Robots is the list of robots directives of the page (noindex, nofollow...), added as the
+	// X-Robots-Tag header and exposed to the templates
+	Robots string `json:"robots"`
+	// GeoVariants overrides the page definition for the clients located in the countries (ES) or
+	// regions (US-CA) used as keys. It requires the GeoIP section
+	GeoVariants map[string]GeoVariant `json:"geo_variants"`
+	// Concurrency limits the requests of the page processed at the same time
+	Concurrency *PageConcurrency `json:"concurrency"`
+	// Engine is the template engine of the template and the layout of the page: mustache (the
+	// default) or gotemplate. The templates and layouts can not be shared by pages with
+	// different engines
+	Engine string `json:"engine"`
+	// Backends are additional backends requested concurrently, with their responses stored in
+	// the Data of the response context under their names
+	Backends map[string]PageBackend `json:"backends"`
+	// Budget is the max duration of the requests of the additional backends, counted from the
+	// start of the request (800ms). The backends exceeding it are listed in the Missing of the
+	// response context instead of delaying the page
+	Budget string `json:"budget"`
+	// Encoding is the encoding of the backend responses: json (the default), xml, csv, msgpack,
+	// protobuf or the name of a registered decoder
+	Encoding string `json:"encoding"`
+	// GraphQL sends a query to a GraphQL endpoint instead of requesting the BackendURLPattern
+	GraphQL *GraphQLQuery `json:"graphql"`
+	// BackendCacheTTL is the expiration of the backend responses of the page kept in a shared
+	// backend cache store. If empty, they are kept until replaced
+	BackendCacheTTL string `json:"backend_cache_ttl"`
+	// StaleTTL is the time the last rendered version of every URL of the page is kept after its
+	// CacheTTL, served while it is rendered again in the background or if the rendering fails.
+	// If empty, the stale responses are never served
+	StaleTTL string `json:"stale_ttl"`
+	// Middlewares are the names of the middlewares added to the route of the page, in order
+	Middlewares []string `json:"middlewares"`
+	// Retry retries the failed backend requests of the page
+	Retry *BackendRetry `json:"retry"`
+	// Sitemap defines how the page is listed in the generated sitemap
+	Sitemap *PageSitemap `json:"sitemap"`
+	// Minify collapses the whitespace and strips the comments of the rendered HTML
+	Minify bool `json:"minify"`
+	// Stream renders the elements of the backend array incrementally, sending them as soon as
+	// they are decoded. It requires the IsArray flag
+	Stream *PageStream `json:"stream"`
+	// RateLimit limits the requests of the page
+	RateLimit *RateLimit `json:"rate_limit"`
+	// Forward contains the headers and cookies of the request forwarded to the backends
+	Forward *ForwardRequest `json:"forward"`
+	// Signing signs the backend requests of the page
+	Signing *BackendSigning `json:"signing"`
+	// OAuth2Client is the name of the OAuth2 client authorizing the backend requests of the page
+	OAuth2Client string `json:"oauth2_client"`
+	// Headers are the response headers of the page (Content-Security-Policy, X-Frame-Options,
+	// Strict-Transport-Security...), added to the rendered and the error responses
+	Headers map[string]string `json:"headers"`
+	// CSP replaces the global content security policy for the page
+	CSP *ContentSecurityPolicy `json:"csp"`
+	// Formats are the alternative representations of the page, selected with the format query
+	// param (?format=json) or with the Accept header. The json format without template returns
+	// the decoded backend data
+	Formats map[string]PageFormat `json:"formats"`
+	// Markdown are the paths of the string fields of the backend data (body, author.bio)
+	// converted from markdown into sanitized HTML, rendered with the triple mustache. The arrays
+	// found along the paths are traversed
+	Markdown []string `json:"markdown"`
+	// Sanitize removes the unsafe elements and attributes of the HTML fields of the backend data
+	Sanitize *FieldSanitizer `json:"sanitize"`
+	// Locales overrides the template and the layout of the page for the locales (es, pt-BR) or
+	// the languages used as keys. It requires the I18n section
+	Locales map[string]LocaleVariant `json:"locales"`
+	// EarlyHints declares the critical assets of the page, preloaded by the browsers while the
+	// page is rendered
+	EarlyHints *EarlyHints `json:"early_hints"`
+	// CSV configures the decoding of the backend responses of the pages with the csv encoding
+	CSV *CSV `json:"csv"`
+	// Protobuf declares the message type of the backend responses of the pages with the
+	// protobuf encoding
+	Protobuf *Protobuf `json:"protobuf"`
+	// DataPath is a JMESPath expression (data.attributes.items) extracting the data of the
+	// template from the decoded backend response, before adding the additional backends
+	DataPath string `json:"data_path"`
+	// Redirects are the rules redirecting the requests depending on the backend data, checked in
+	// order after extracting the data path
+	Redirects []RedirectRule `json:"redirects"`
+	// Transform are the transformations applied in order to the backend data, after adding the
+	// additional backends
+	Transform []Transformation `json:"transform"`
+	// Script is the JavaScript hook post-processing the backend data after the transformations
+	Script *PageScript `json:"script"`
+	// ESI replaces the <esi:include/> tags of the rendered page with the content of the pages
+	// they include
+	ESI bool `json:"esi"`
+	// PageCache keeps the rendered versions of the page in the page cache of the config, during
+	// its CacheTTL
+	PageCache bool `json:"page_cache"`
+	// ErrorTemplates are the names of the templates rendering the failed requests of the page,
+	// with its layout, by status code ("404", "500") or for the backend timeouts ("timeout")
+	ErrorTemplates map[string]string `json:"error_templates"`
+	// StatusMapping maps the status codes of the failed backend responses to the responses of
+	// the page
+	StatusMapping map[int]StatusMapping `json:"status_mapping"`
+	// Generator is the name of the registered response generator replacing the backend of the
+	// page
+	Generator string `json:"generator"`
+	// Renderer is the name of the registered renderer replacing the template and the layout of
+	// the page
+	Renderer string `json:"renderer"`
+	// Host restricts the page to the requests of a domain (www.example.com) or of its
+	// subdomains (*.example.com). The pages of different hosts can share the same URL pattern,
+	// and the pages without a host serve the rest of the domains
+	Host string `json:"host"`
+	// Canonical replaces the canonical URLs of the config for the page
+	Canonical *CanonicalURL `json:"canonical"`
+	// route contains the constraints of the params of the URL pattern
+	route RoutePattern
+	// authenticated marks the pages behind a jwt or a basic_auth middleware, so their responses
+	// are not kept by the shared caches
+	authenticated bool
+	// pools are the failover target pools of the engine of the page
+	pools *targetPools
+	// registry has the decoders, the plugins, the cache store and the OAuth2 clients of the
+	// engine of the page
+	registry *registry
+}
+
+// CachedPartial declares the cache of a rendered partial
+type CachedPartial struct {
+	// TTL is the time the rendered versions of the partial are kept (10m)
+	TTL string `json:"ttl"`
+	// Key is a mustache template rendered with the context of the partial, identifying its
+	// versions by their data inputs ({{Extra.menu_version}}-{{Extra.locale}}). If empty, the
+	// partial has a single version
+	Key string `json:"key"`
+}
+
+// DynamicErrorPages configures the error pages rendered with the context of the failures
+type DynamicErrorPages struct {
+	// SuggestionsURL is the backend URL returning the suggestions of the not found pages, with
+	// the :path placeholder replaced by the requested path (http://api.company.com/search?q=:path)
+	SuggestionsURL string `json:"suggestions_url"`
+	// Timeout is the maximum duration of the suggestions requests. Defaults to 500ms
+	Timeout string `json:"timeout"`
+}
+
+// StatusMapping is the response of the requests whose backend fails with a status code
+type StatusMapping struct {
+	// Status is the status code of the response, rendered with its error template. Defaults to
+	// 302 for the redirections
+	Status int `json:"status"`
+	// Redirect is the location the requests are redirected to (/login)
+	Redirect string `json:"redirect"`
+	// ReturnParam is the query param of the redirection receiving the requested URI (next)
+	ReturnParam string `json:"return_param"`
+	// RetryAfter is the value in seconds of the Retry-After header of the response
+	RetryAfter int `json:"retry_after"`
+}
+
+// RedirectRule redirects the requests of a page when their backend data matches a condition
+type RedirectRule struct {
+	// When is a JMESPath expression evaluated against the backend data (status == 'moved',
+	// canonical_url). The rule matches unless the result is false, null or empty
+	When string `json:"when"`
+	// Target is the mustache template of the location, rendered with the backend data
+	// (/products/{{replaced_by}})
+	Target string `json:"target"`
+	// Status is the status code of the redirection: 301, 302, 303, 307 or 308. Defaults to 302
+	Status int `json:"status"`
+}
+
+// URLRedirect redirects the requests matching a URL pattern to another location
+type URLRedirect struct {
+	// Source is the URL pattern of the redirected requests, with the syntax of the pages
+	// (/blog/:year/:slug or /old/{path:*})
+	Source string `json:"source"`
+	// Target is the location of the redirection. The params of the source are replaced in it
+	// (/articles/:slug or https://docs.example.com/:path)
+	Target string `json:"target"`
+	// Status is the status code of the redirection: 301, 302, 303, 307 or 308. Defaults to 301
+	Status int `json:"status"`
+	// PreserveQuery appends the query string of the request to the target
+	PreserveQuery bool `json:"preserve_query"`
+}
+
+// URLRewrite serves the requests matching a URL pattern as if they were requesting another path
+type URLRewrite struct {
+	// Source is the URL pattern of the rewritten requests, with the syntax of the pages
+	Source string `json:"source"`
+	// Target is the path the requests are routed to. The params of the source are replaced in
+	// it and its query params are added to the ones of the request (/search?category=:name)
+	Target string `json:"target"`
+}
+
+// Transformation reshapes the backend data of a page before rendering it
+type Transformation struct {
+	// Type is the kind of transformation: rename, pick, omit, sort, group_by or compute
+	Type string `json:"type"`
+	// Path is the dot path of the transformed object or array (author, items). The arrays found
+	// along the path are traversed. If empty, the whole data is transformed
+	Path string `json:"path"`
+	// Fields maps the current keys to the new ones in the rename transformations
+	Fields map[string]string `json:"fields"`
+	// Keys are the keys kept by the pick transformations or removed by the omit ones
+	Keys []string `json:"keys"`
+	// By is the key of the sort and the group_by transformations
+	By string `json:"by"`
+	// Desc sorts in descending order
+	Desc bool `json:"desc"`
+	// Field is the key added by the compute transformations
+	Field string `json:"field"`
+	// Expression is the JMESPath expression evaluated against every object by the compute
+	// transformations
+	Expression string `json:"expression"`
+}
+
+// PageScript is a JavaScript file defining a process(data, request) function called with the
+// backend data of every request of a page
+type PageScript struct {
+	// Path is the path of the script
+	Path string `json:"path"`
+	// Timeout is the maximum duration of every call (50ms, 1s). Defaults to 100ms
+	Timeout string `json:"timeout"`
+}
+
+// Protobuf contains the descriptor and the type of the protobuf messages returned by a backend
+type Protobuf struct {
+	// Descriptor is the path of the FileDescriptorSet with the message type and its dependencies,
+	// generated with protoc --include_imports --descriptor_set_out
+	Descriptor string `json:"descriptor"`
+	// Message is the full name of the message type, like catalog.v1.Product
+	Message string `json:"message"`
+}
+
+// CSV contains the format of the CSV backend responses and the mapping of their columns to the
+// keys of the decoded rows
+type CSV struct {
+	// Delimiter is the field separator. Defaults to the comma
+	Delimiter string `json:"delimiter"`
+	// Headers renames the columns of the header row (Unit Price -> price)
+	Headers map[string]string `json:"headers"`
+	// Columns are the names of the columns of the responses without header row. If empty, they
+	// are read from the first row
+	Columns []string `json:"columns"`
+}
+
+// EarlyHints contains the critical assets of a page, sent in a 103 Early Hints response before
+// rendering it
+type EarlyHints struct {
+	Assets []CriticalAsset `json:"assets"`
+	// Push pushes the assets instead when the connection supports the HTTP/2 server push
+	Push bool `json:"push"`
+}
+
+// CriticalAsset is a CSS, JS, font or image required to render the first paint of a page
+type CriticalAsset struct {
+	// Path is the path of the asset relative to the public folder, replaced with its fingerprinted
+	// URL when the SRI is enabled, or its URL
+	Path string `json:"path"`
+	// As is the destination of the asset (style, script, font, image...). If empty, it is
+	// guessed from the extension of the path
+	As string `json:"as"`
+}
+
+// LocaleVariant contains the template and the layout of a page for a locale
+type LocaleVariant struct {
+	Template string `json:"template"`
+	Layout   string `json:"layout"`
+}
+
+// FieldSanitizer contains the paths of the string fields of the backend data holding rich text
+// HTML and the policy sanitizing them
+type FieldSanitizer struct {
+	// Fields are the paths of the fields (body, author.bio). The arrays found along the paths
+	// are traversed
+	Fields []string `json:"fields"`
+	// Policy is the base policy: ugc (the default) or strict
+	Policy string `json:"policy"`
+	// AllowElements contains additional elements allowed by the policy with their allowed
+	// attributes, like "iframe": ["src", "width", "height"]
+	AllowElements map[string][]string `json:"allow_elements"`
+}
+
+// PageFormat is an alternative representation of a page, like the raw JSON or an AMP version
+type PageFormat struct {
+	// MediaType is matched with the Accept header and sent as the Content-Type header. Defaults
+	// to application/json for the json format and to text/html for the rest
+	MediaType string `json:"media_type"`
+	// Template and Layout render the format. If Layout is empty, the one of the page is used
+	Template string `json:"template"`
+	Layout   string `json:"layout"`
+}
+
+// ContentSecurityPolicy contains the directives of the Content-Security-Policy header. A random
+// nonce is generated for every request, exposed to the templates as Extra.csp_nonce and added
+// to the nonce directives
+type ContentSecurityPolicy struct {
+	// Directives contains the sources of every directive, like "img-src": ["'self'", "data:"]
+	Directives map[string][]string `json:"directives"`
+	// NonceDirectives are the directives getting the nonce. Defaults to script-src and style-src
+	NonceDirectives []string `json:"nonce_directives"`
+	// ReportOnly sends the policy as the Content-Security-Policy-Report-Only header
+	ReportOnly bool `json:"report_only"`
+}
+
+// OAuth2Client contains the token endpoint and the credentials of an OAuth2 client using the
+// client credentials flow
+type OAuth2Client struct {
+	TokenURL     string   `json:"token_url"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	Scopes       []string `json:"scopes"`
+	// Params are additional params of the token requests, like the audience
+	Params map[string]string `json:"params"`
+}
+
+// BackendSigning contains the method and the credentials signing the backend requests
+type BackendSigning struct {
+	// Type is the signing method: hmac or aws_sigv4
+	Type string `json:"type"`
+	// KeyID and Secret sign the requests with hmac
+	KeyID  string `json:"key_id"`
+	Secret string `json:"secret"`
+	// Region and Service are the scope of the aws_sigv4 signatures. The service defaults to
+	// execute-api (AWS API Gateway)
+	Region  string `json:"region"`
+	Service string `json:"service"`
+	// AccessKeyID, SecretAccessKey and SessionToken are the AWS credentials. If empty, they are
+	// read from the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN env vars
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key"`
+	SessionToken    string `json:"session_token"`
+}
+
+// ForwardRequest whitelists the headers and cookies of the inbound requests forwarded to the
+// backends, so the personalized APIs can be rendered. The backend responses of the pages
+// forwarding cookies or the Authorization header are not cached
+type ForwardRequest struct {
+	Headers []string `json:"headers"`
+	Cookies []string `json:"cookies"`
+}
+
+// RateLimit contains the token buckets limiting the rate of the requests. The rejected requests
+// get a 429 response, rendered with the static/429 template if it exists
+type RateLimit struct {
+	// Rate is the number of requests per second allowed in the long run
+	Rate float64 `json:"rate"`
+	// Burst is the max number of requests allowed at once. Defaults to the rate, rounded up
+	Burst int `json:"burst"`
+	// Key selects the bucket of every request: ip (one per client, the default) or global
+	Key string `json:"key"`
+}
+
+// PageStream contains the template rendering every element of a streamed array. The template of
+// the page renders the rest of the page with an empty Array, marking the position of the items
+// with the {{{Stream}}} tag
+type PageStream struct {
+	// ItemTemplate is the name of the template rendering every element, with the element as Data
+	ItemTemplate string `json:"item_template"`
 }
 
-// New creates a gin engine with the default Factory
+// PageSitemap defines how the URLs of a page are listed in the generated sitemap. The pages
+// without params are always listed, unless excluded. The pages with params are only listed if
+// they have a source
+type PageSitemap struct {
+	// Exclude removes the page from the sitemap
+	Exclude bool `json:"exclude"`
+	// Source is the URL of a backend list endpoint returning a JSON array of items, one per URL
+	// of the page
+	Source string `json:"source"`
+	// Params maps the params of the URLPattern to the fields of the items replacing them
+	Params map[string]string `json:"params"`
+	// LastMod is the field of the items with the last modification date of their URLs
+	LastMod    string `json:"lastmod"`
+	ChangeFreq string `json:"changefreq"`
+	Priority   string `json:"priority"`
+}
+
+// BackendRetry contains the retry policy of the failed backend requests (errors and 502, 503
+// and 504 responses). The waits between attempts grow exponentially, with full jitter
+type BackendRetry struct {
+	// MaxAttempts is the max number of attempts, including the first one. Defaults to 3
+	MaxAttempts int `json:"max_attempts"`
+	// InitialBackoff is the max wait before the first retry. Defaults to 100ms
+	InitialBackoff string `json:"initial_backoff"`
+	// MaxBackoff caps the max wait between attempts. Defaults to 2s
+	MaxBackoff string `json:"max_backoff"`
+	// Budget is the max time spent in a request, including all its attempts. No retry is
+	// started if it would exceed the budget. If empty, the time is not limited
+	Budget string `json:"budget"`
+	// IdempotentOnly only retries the GET and HEAD requests
+	IdempotentOnly bool `json:"idempotent_only"`
+}
+
+// GraphQLQuery contains the query of a page and its GraphQL endpoint
+type GraphQLQuery struct {
+	Endpoint string `json:"endpoint"`
+	Query    string `json:"query"`
+	// Variables maps the variables of the query to the params of the page supplying their values
+	Variables map[string]string `json:"variables"`
+}
+
+// PageBackend defines a named backend of a page
+type PageBackend struct {
+	// URLPattern is the URL of the backend, with the params of the page, like
+	// http://api.company.com/users/:id
+	URLPattern string `json:"url_pattern"`
+	// Timeout is the max duration of the backend request. If empty, it is not limited
+	Timeout string `json:"timeout"`
+	// Encoding is the encoding of the backend responses: json (the default), xml, csv, msgpack,
+	// protobuf or the name of a registered decoder
+	Encoding string `json:"encoding"`
+	// Protobuf declares the message type of the responses with the protobuf encoding
+	Protobuf *Protobuf `json:"protobuf"`
+}
+
+// PageConcurrency contains the max number of in-flight requests of a page
+type PageConcurrency struct {
+	// MaxInFlight is the max number of requests processed at the same time
+	MaxInFlight int `json:"max_in_flight"`
+	// QueueTimeout is the max time the excess requests wait for a free slot before getting a
+	// 503 response. If empty, they get it immediately
+	QueueTimeout string `json:"queue_timeout"`
+}
+
+// BackendFailover contains the base URLs (scheme and host) replacing the one of the
+// BackendURLPattern, in priority order. The requests are sent to the healthy target selected by
+// the policy, failing over to the next ones
+type BackendFailover struct {
+	Hosts []string `json:"hosts"`
+	// Policy selects the target of every request: priority (the first healthy one), round_robin
+	// (weighted) or least_latency. Defaults to priority
+	Policy string `json:"policy"`
+	// Weights are the round_robin weights of the hosts. Defaults to 1
+	Weights map[string]int `json:"weights"`
+	// FailureThreshold is the number of consecutive failures (errors or 5xx responses) marking
+	// a target as unhealthy. Defaults to 1
+	FailureThreshold int `json:"failure_threshold"`
+	// HealthCheckPath is the path requested to the unhealthy targets in order to detect their
+	// recovery. If empty, the targets are considered healthy again after the interval
+	HealthCheckPath string `json:"health_check_path"`
+	// HealthCheckInterval is the time between health checks. Defaults to 10s
+	HealthCheckInterval string `json:"health_check_interval"`
+	// Discovery resolves the hosts from a service registry, replacing the static ones
+	Discovery *BackendDiscovery `json:"discovery"`
+}
+
+// BackendDiscovery contains the config for resolving the hosts of a failover backend
+type BackendDiscovery struct {
+	// Provider is the service registry to query: dns_srv or consul
+	Provider string `json:"provider"`
+	// Service is the SRV name (_api._tcp.company.com) or the Consul service name
+	Service string `json:"service"`
+	// Scheme is the scheme of the discovered hosts. Defaults to http
+	Scheme string `json:"scheme"`
+	// ConsulAddress is the address of the Consul agent. Defaults to http://127.0.0.1:8500
+	ConsulAddress string `json:"consul_address"`
+	// Interval is the time between DNS lookups and the max wait of the Consul blocking
+	// queries. Defaults to 30s
+	Interval string `json:"interval"`
+}
+
+// GeoVariant contains the values overriding the page definition for a location. The Extra
+// values are merged with the ones of the page
+type GeoVariant struct {
+	Template          string                 `json:"template"`
+	Layout            string                 `json:"layout"`
+	BackendURLPattern string                 `json:"backend_url_pattern"`
+	Extra             map[string]interface{} `json:"extra"`
+}
+
+// New creates a gin engine with the default Factory. The returned engine can not be closed, so
+// the programs replacing it should use the Factory instead
 func New(cfgPath string, devel bool) (*gin.Engine, error) {
-	return DefaultFactory.New(cfgPath, devel)
+	e, err := DefaultFactory.New(cfgPath, devel)
+	if err != nil {
+		return nil, err
+	}
+	return e.Engine, nil
 }
 
 // Backend defines the signature of the function that creates a response for a request
@@ -112,5 +1145,3 @@ var ErrNoRendererDefined = fmt.Errorf("no rendered defined")
 
 // EmptyRenderer is the Renderer to be use if no other is defined
 var EmptyRenderer = ErrorRenderer{ErrNoRendererDefined}
-
-var newrelicApp *newrelic.Application
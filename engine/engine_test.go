@@ -8,8 +8,6 @@ import (
 	"os"
 	"testing"
 	"time"
-
-	"github.com/gin-gonic/gin"
 )
 
 func TestNew(t *testing.T) {
@@ -116,7 +114,7 @@ func TestNew(t *testing.T) {
 	assertResponse(t, e, "/s.txt", http.StatusOK, "12345")
 }
 
-func assertResponse(t *testing.T, e *gin.Engine, url string, status int, body string) {
+func assertResponse(t *testing.T, e http.Handler, url string, status int, body string) {
 	w := httptest.NewRecorder()
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
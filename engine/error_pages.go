@@ -0,0 +1,126 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TimeoutErrorTemplate is the key of the error template of the requests failed because of a
+// backend timeout, used when the page does not declare a template for the 504 status code
+const TimeoutErrorTemplate = "timeout"
+
+// NewErrorPages creates the ErrorPages of the page or returns nil if the page does not declare
+// error templates. The returned ErrorPages will be keeping themselves subscribed to the latest
+// version of the error templates using the given subscription channel
+func NewErrorPages(page Page, subscriptionChan chan Subscription) *ErrorPages {
+	if len(page.ErrorTemplates) == 0 {
+		return nil
+	}
+	e := &ErrorPages{
+		Page:      page,
+		Subscribe: subscriptionChan,
+		renderers: map[string]Renderer{},
+	}
+	for key, tmpl := range page.ErrorTemplates {
+		go e.updateRenderer(key, errorTemplateTopic(page, tmpl))
+	}
+	return e
+}
+
+// ErrorPages renders the failed requests of a page with the error template of their status code,
+// wrapped by the layout of the page. The status codes without error template get the global
+// static ones
+type ErrorPages struct {
+	Page      Page
+	Subscribe chan Subscription
+	mutex     sync.RWMutex
+	renderers map[string]Renderer
+}
+
+func (e *ErrorPages) updateRenderer(key, topic string) {
+	subscribeRenderer(e.Subscribe, topic, make(chan Renderer), func(r Renderer) {
+		e.setRenderer(key, r)
+	})
+}
+
+// loadRenderers implements the rendererSubscriber interface
+func (e *ErrorPages) loadRenderers(store *TemplateStore) {
+	for key, tmpl := range e.Page.ErrorTemplates {
+		if r, ok := store.Get(errorTemplateTopic(e.Page, tmpl)); ok {
+			e.setRenderer(key, r)
+		}
+	}
+}
+
+func (e *ErrorPages) setRenderer(key string, r Renderer) {
+	e.mutex.Lock()
+	e.renderers[key] = r
+	e.mutex.Unlock()
+}
+
+// Renderer returns the renderer of the error template of the status code, if it is available
+func (e *ErrorPages) Renderer(status int) (Renderer, bool) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	r, ok := e.renderers[strconv.Itoa(status)]
+	if !ok && status == http.StatusGatewayTimeout {
+		r, ok = e.renderers[TimeoutErrorTemplate]
+	}
+	return r, ok
+}
+
+// serveErrorPage writes the response of the failed request rendered with the error template of
+// its status code. It returns false if the page has no error template for the status code or the
+// template fails
+func (h *Handler) serveErrorPage(c *gin.Context, status int, result ResponseContext, err error) bool {
+	if h.ErrorPages == nil {
+		return false
+	}
+	r, ok := h.ErrorPages.Renderer(status)
+	if !ok {
+		return false
+	}
+	if h.Page.Minify {
+		r = MinifiedRenderer{r}
+	}
+	result.Extra = requestExtra(c, result.Extra)
+	result.Error = newErrorContext(c, status, err)
+	buf := &bytes.Buffer{}
+	if rerr := r.Render(buf, result); rerr != nil {
+		log.Println(h.Page.Name, "rendering the error template:", rerr.Error())
+		return false
+	}
+	c.Error(err)
+	c.Data(status, "text/html; charset=utf-8", buf.Bytes())
+	c.Abort()
+	return true
+}
+
+// errorTemplateTopic returns the topic of the template store publishing the error template of
+// the page
+func errorTemplateTopic(page Page, tmpl string) string {
+	if page.Layout != "" {
+		return layoutTopic(page.Layout, tmpl)
+	}
+	return tmpl
+}
+
+// validErrorTemplates returns an error if a key of the error templates is not an error status
+// code nor the timeout one, or if a template is not declared
+func validErrorTemplates(errorTemplates, templates map[string]string) error {
+	for key, tmpl := range errorTemplates {
+		if status, err := strconv.Atoi(key); key != TimeoutErrorTemplate && (err != nil || status < 400 || status > 599) {
+			return fmt.Errorf("invalid error template key: %q", key)
+		}
+		if _, ok := templates[tmpl]; !ok {
+			return fmt.Errorf("unknown error template: %s", tmpl)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestHandler_errorPages(t *testing.T) {
+	store := NewTemplateStore()
+	page := Page{
+		Name:           "product",
+		Layout:         "main",
+		ErrorTemplates: map[string]string{"404": "product_not_found", TimeoutErrorTemplate: "product_timeout"},
+	}
+	statuses := map[string]int{"a": http.StatusNotFound, "b": http.StatusGatewayTimeout, "c": http.StatusBadGateway}
+	rg := func(c *gin.Context) (ResponseContext, error) {
+		result := ResponseContext{Params: requestParams(c)}
+		return result, &ResponseError{StatusCode: statuses[c.Param("id")]}
+	}
+	h := NewHandler(HandlerConfig{page, EmptyRenderer, rg, "public, max-age=60"}, store.Subscribe)
+
+	time.Sleep(100 * time.Millisecond)
+
+	store.Set(layoutTopic("main", "product_not_found"), RendererFunc(func(w io.Writer, v interface{}) error {
+		_, err := fmt.Fprintf(w, "product %s not found", v.(ResponseContext).Params["id"])
+		return err
+	}))
+	store.Set(layoutTopic("main", "product_timeout"), RendererFunc(func(w io.Writer, _ interface{}) error {
+		_, err := io.WriteString(w, "try again later")
+		return err
+	}))
+
+	time.Sleep(100 * time.Millisecond)
+
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(ResponseErrorMessageHandler())
+	notFound := ErrorHandler{[]byte("static not found"), http.StatusNotFound}
+	e.Use(notFound.HandlerFunc())
+	e.GET("/products/:id", h.HandlerFunc)
+
+	for _, tc := range []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{path: "/products/a", status: http.StatusNotFound, body: "product a not found"},
+		{path: "/products/b", status: http.StatusGatewayTimeout, body: "try again later"},
+		{path: "/products/c", status: http.StatusBadGateway, body: "Bad Gateway"},
+	} {
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, httptest.NewRequest("GET", tc.path, nil))
+		if w.Code != tc.status || w.Body.String() != tc.body {
+			t.Errorf("%s: unexpected response: %d %q", tc.path, w.Code, w.Body.String())
+		}
+	}
+}
+
+func Test_validErrorTemplates(t *testing.T) {
+	templates := map[string]string{"not_found": "not_found.mustache"}
+	if err := validErrorTemplates(map[string]string{"404": "not_found", "timeout": "not_found"}, templates); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+	for _, errorTemplates := range []map[string]string{
+		{"200": "not_found"},
+		{"not_found": "not_found"},
+		{"404": "unknown"},
+	} {
+		if err := validErrorTemplates(errorTemplates, templates); err == nil {
+			t.Errorf("expecting an error with %v", errorTemplates)
+		}
+	}
+}
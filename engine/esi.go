@@ -0,0 +1,218 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// esiDepthHeader is the header of the fragment requests counting the nesting level
+const esiDepthHeader = "X-Api2html-Esi-Depth"
+
+// maxESIDepth is the max nesting level of the fragments
+const maxESIDepth = 3
+
+var (
+	esiIncludeRegexp   = regexp.MustCompile(`<esi:include\s+([^>]*?)\s*/>`)
+	esiAttributeRegexp = regexp.MustCompile(`([\w-]+)="([^"]*)"`)
+)
+
+// NewFragmentComposer creates a FragmentComposer dispatching the fragment requests to the
+// received handler
+func NewFragmentComposer(h http.Handler, pages []Page) *FragmentComposer {
+	byName := make(map[string]Page, len(pages))
+	for _, page := range pages {
+		byName[page.Name] = page
+	}
+	return &FragmentComposer{Handler: h, Pages: byName, cache: map[string]fragmentEntry{}}
+}
+
+// FragmentComposer replaces the <esi:include/> tags of the rendered pages with the content of
+// other pages. The fragments are requested concurrently to the Handler, with the headers of the
+// page request, and kept during the cache TTL of their pages.
+//
+// The tags include the pages by name, with the rest of the attributes as the params of their
+// URL patterns (<esi:include page="reviews" id="42"/>), or by path (<esi:include src="/footer"
+// ttl="10m"/>). The failed fragments fail the page unless their tags have the
+// onerror="continue" attribute
+type FragmentComposer struct {
+	Handler http.Handler
+	Pages   map[string]Page
+	mutex   sync.Mutex
+	cache   map[string]fragmentEntry
+}
+
+type fragmentEntry struct {
+	body    []byte
+	expires time.Time
+}
+
+type fragmentInclude struct {
+	path     string
+	ttl      time.Duration
+	optional bool
+}
+
+// Compose returns the received body with its include tags replaced by the fragments
+func (f *FragmentComposer) Compose(r *http.Request, body []byte) ([]byte, error) {
+	matches := esiIncludeRegexp.FindAllSubmatchIndex(body, -1)
+	if len(matches) == 0 {
+		return body, nil
+	}
+	depth, _ := strconv.Atoi(r.Header.Get(esiDepthHeader))
+	if depth >= maxESIDepth {
+		return nil, fmt.Errorf("the fragments exceed the max depth of %d", maxESIDepth)
+	}
+
+	fragments := make([][]byte, len(matches))
+	errs := make([]error, len(matches))
+	var wg sync.WaitGroup
+	for i, m := range matches {
+		include, err := f.parseInclude(body[m[2]:m[3]])
+		if err != nil {
+			return nil, err
+		}
+		wg.Add(1)
+		go func(i int, include fragmentInclude) {
+			defer wg.Done()
+			fragments[i], errs[i] = f.fragment(r, include, depth+1)
+			if errs[i] != nil && include.optional {
+				fragments[i], errs[i] = nil, nil
+			}
+		}(i, include)
+	}
+	wg.Wait()
+
+	composed := &bytes.Buffer{}
+	last := 0
+	for i, m := range matches {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		composed.Write(body[last:m[0]])
+		composed.Write(fragments[i])
+		last = m[1]
+	}
+	composed.Write(body[last:])
+	return composed.Bytes(), nil
+}
+
+// Purge discards the cached fragments
+func (f *FragmentComposer) Purge() {
+	f.mutex.Lock()
+	f.cache = map[string]fragmentEntry{}
+	f.mutex.Unlock()
+}
+
+// parseInclude returns the path and the TTL of the fragment of the include tag attributes
+func (f *FragmentComposer) parseInclude(attrs []byte) (fragmentInclude, error) {
+	values := map[string]string{}
+	for _, m := range esiAttributeRegexp.FindAllSubmatch(attrs, -1) {
+		values[string(m[1])] = string(m[2])
+	}
+	include := fragmentInclude{optional: values["onerror"] == "continue"}
+	delete(values, "onerror")
+
+	if src, ok := values["src"]; ok {
+		include.path = src
+		if ttl, ok := values["ttl"]; ok {
+			d, err := time.ParseDuration(ttl)
+			if err != nil {
+				return include, fmt.Errorf("invalid fragment ttl: %q", ttl)
+			}
+			include.ttl = d
+		}
+		return include, nil
+	}
+
+	name := values["page"]
+	page, ok := f.Pages[name]
+	if !ok {
+		return include, fmt.Errorf("unknown fragment page: %q", name)
+	}
+	delete(values, "page")
+	for k, v := range values {
+		values[k] = url.PathEscape(v)
+	}
+	include.path = string(replaceParams([]byte(page.URLPattern), values))
+	if !page.Forward.forwardsCredentials() && !page.authenticated {
+		// the personalized fragments and the ones of the authenticated users are not shared
+		include.ttl, _ = time.ParseDuration(page.CacheTTL)
+	}
+	return include, nil
+}
+
+// fragment returns the content of the fragment, from the cache if it is still fresh
+func (f *FragmentComposer) fragment(r *http.Request, include fragmentInclude, depth int) ([]byte, error) {
+	if include.ttl > 0 {
+		f.mutex.Lock()
+		e, ok := f.cache[include.path]
+		f.mutex.Unlock()
+		if ok && time.Now().Before(e.expires) {
+			return e.body, nil
+		}
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, include.path, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range r.Header {
+		switch k {
+		case "If-None-Match", "If-Modified-Since", "Accept-Encoding", "Range":
+			// the fragments must be complete and uncompressed
+		default:
+			req.Header[k] = v
+		}
+	}
+	req.Header.Set(esiDepthHeader, strconv.Itoa(depth))
+	req.RemoteAddr = r.RemoteAddr
+
+	w := &fragmentWriter{header: http.Header{}, status: http.StatusOK}
+	f.Handler.ServeHTTP(w, req)
+	if w.status != http.StatusOK {
+		return nil, fmt.Errorf("the fragment %s failed with the status code %d", include.path, w.status)
+	}
+
+	body := w.body.Bytes()
+	if include.ttl > 0 && !privateResponse(w.header) {
+		f.mutex.Lock()
+		if len(f.cache) >= staleMaxEntries {
+			now := time.Now()
+			for k, e := range f.cache {
+				if now.After(e.expires) {
+					delete(f.cache, k)
+				}
+			}
+		}
+		f.cache[include.path] = fragmentEntry{body: body, expires: time.Now().Add(include.ttl)}
+		f.mutex.Unlock()
+	}
+	return body, nil
+}
+
+// privateResponse returns true if the Cache-Control header of the response keeps it out of the
+// shared caches, like the ones of the authenticated pages included by path
+func privateResponse(header http.Header) bool {
+	cc := strings.ToLower(header.Get("Cache-Control"))
+	return strings.Contains(cc, "private") || strings.Contains(cc, "no-store")
+}
+
+// fragmentWriter is the http.ResponseWriter collecting the responses of the fragment requests
+type fragmentWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *fragmentWriter) Header() http.Header { return w.header }
+
+func (w *fragmentWriter) Write(p []byte) (int, error) { return w.body.Write(p) }
+
+func (w *fragmentWriter) WriteHeader(status int) { w.status = status }
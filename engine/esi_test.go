@@ -0,0 +1,94 @@
+package engine
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestFragmentComposer_Compose(t *testing.T) {
+	var calls int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/reviews/", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		fmt.Fprintf(w, "reviews of %s for %s", r.URL.Path[len("/reviews/"):], r.Header.Get("Accept-Language"))
+	})
+	mux.HandleFunc("/footer", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, "footer")
+	})
+	mux.HandleFunc("/nested", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `<esi:include src="/nested"/>`)
+	})
+	mux.HandleFunc("/broken", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	var composer *FragmentComposer
+	// the nested fragments are composed by the handler of their pages
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/nested" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		body, err := composer.Compose(r, []byte(`<esi:include src="/nested"/>`))
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write(body)
+	})
+	composer = NewFragmentComposer(handler, []Page{{Name: "reviews", URLPattern: "/reviews/:id", CacheTTL: "1m"}})
+
+	r := httptest.NewRequest("GET", "/products/42", nil)
+	r.Header.Set("Accept-Language", "es")
+	page := `<main>product</main><esi:include page="reviews" id="42"/><esi:include src="/footer" /><esi:include src="/broken" onerror="continue"/>`
+	for i := 0; i < 2; i++ {
+		body, err := composer.Compose(r, []byte(page))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if string(body) != "<main>product</main>reviews of 42 for esfooter" {
+			t.Errorf("unexpected body: %s", body)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("the fragment was not cached: %d calls", calls)
+	}
+
+	for _, page := range []string{
+		`<esi:include src="/broken"/>`,
+		`<esi:include page="unknown"/>`,
+		`<esi:include src="/nested"/>`,
+	} {
+		if _, err := composer.Compose(r, []byte(page)); err == nil {
+			t.Errorf("expecting an error composing %s", page)
+		}
+	}
+}
+
+func TestFragmentComposer_Compose_authenticated(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _, _ := r.BasicAuth()
+		w.Header().Set("Cache-Control", "private, max-age=60")
+		fmt.Fprintf(w, "hi, %s", user)
+	})
+	composer := NewFragmentComposer(handler, []Page{{Name: "account", URLPattern: "/account", CacheTTL: "1m", authenticated: true}})
+
+	for _, page := range []string{`<esi:include page="account"/>`, `<esi:include src="/account" ttl="1m"/>`} {
+		for _, user := range []string{"alice", "bob"} {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.SetBasicAuth(user, "secret")
+			body, err := composer.Compose(r, []byte(page))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if string(body) != "hi, "+user {
+				t.Errorf("%s: unexpected body: %s", page, body)
+			}
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package engine
+
+import (
+	"bytes"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// contentVersionsMaxEntries is the number of URLs tracked by a handler before forgetting them
+const contentVersionsMaxEntries = 10000
+
+// contentVersions tracks the ETag of the last rendered content of every URL of a handler and the
+// time it changed, used as its Last-Modified date
+type contentVersions struct {
+	mutex   sync.Mutex
+	entries map[string]contentVersion
+}
+
+type contentVersion struct {
+	etag     string
+	modified time.Time
+}
+
+// modTime returns the time the content of the URL changed to the received ETag
+func (v *contentVersions) modTime(key, etag string) time.Time {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if e, ok := v.entries[key]; ok && e.etag == etag {
+		return e.modified
+	}
+	if v.entries == nil || len(v.entries) >= contentVersionsMaxEntries {
+		v.entries = map[string]contentVersion{}
+	}
+	now := time.Now()
+	v.entries[key] = contentVersion{etag, now}
+	return now
+}
+
+// writeRendered writes the rendered content with its ETag and Last-Modified headers, replying
+// with a 304 response to the matching conditional requests. The live reload script is injected
+// into the content, if enabled
+func (h *Handler) writeRendered(c *gin.Context, body []byte) {
+	if h.LiveReload {
+		body = injectLiveReload(c, body)
+	}
+	etag := contentETag(body)
+	c.Header("ETag", etag)
+	modTime := h.versions.modTime(c.Request.URL.RequestURI(), etag)
+	http.ServeContent(c.Writer, c.Request, "", modTime, bytes.NewReader(body))
+}
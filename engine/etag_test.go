@@ -0,0 +1,68 @@
+package engine
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestHandler_conditionalRequests(t *testing.T) {
+	cfg := HandlerConfig{
+		Renderer: RendererFunc(func(w io.Writer, _ interface{}) error {
+			_, err := w.Write([]byte("<html>rendered</html>"))
+			return err
+		}),
+		ResponseGenerator: func(_ *gin.Context) (ResponseContext, error) {
+			return ResponseContext{}, nil
+		},
+		CacheControl: "public, max-age=60",
+	}
+	h := NewHandler(cfg, make(chan Subscription, 10))
+
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.GET("/", h.HandlerFunc)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	e.ServeHTTP(w, req)
+	if w.Result().StatusCode != http.StatusOK || w.Body.String() != "<html>rendered</html>" {
+		t.Errorf("unexpected response: %d %s", w.Result().StatusCode, w.Body.String())
+	}
+	etag := w.Header().Get("ETag")
+	lastModified := w.Header().Get("Last-Modified")
+	if etag == "" || lastModified == "" {
+		t.Errorf("unexpected headers: %v", w.Header())
+		return
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	for _, h := range []http.Header{
+		{"If-None-Match": []string{etag}},
+		{"If-Modified-Since": []string{lastModified}},
+	} {
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/", nil)
+		req.Header = h
+		e.ServeHTTP(w, req)
+		if w.Result().StatusCode != http.StatusNotModified {
+			t.Errorf("unexpected status code: %d", w.Result().StatusCode)
+		}
+		if w.Header().Get("Cache-Control") != "public, max-age=60" {
+			t.Errorf("unexpected Cache-Control header: %s", w.Header().Get("Cache-Control"))
+		}
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/", nil)
+	req.Header.Set("If-None-Match", `"other"`)
+	e.ServeHTTP(w, req)
+	if w.Result().StatusCode != http.StatusOK {
+		t.Errorf("unexpected status code: %d", w.Result().StatusCode)
+	}
+}
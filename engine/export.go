@@ -0,0 +1,177 @@
+package engine
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultExportConcurrency = 4
+	exportTemplatesTimeout   = time.Minute
+)
+
+// NewStaticExporter creates the engine of the config and a StaticExporter rendering its pages
+// into the output folder. The engine is released by closing the StaticExporter
+func (ef Factory) NewStaticExporter(cfgPath, output string) (*StaticExporter, error) {
+	cfg, err := ef.Parser(cfgPath)
+	if err != nil {
+		return nil, err
+	}
+	var store *TemplateStore
+	storeFactory := ef.TemplateStoreFactory
+	ef.TemplateStoreFactory = func() *TemplateStore {
+		store = storeFactory()
+		return store
+	}
+	e, err := ef.NewFromConfig(cfg, false)
+	if err != nil {
+		return nil, err
+	}
+	pages, err := routePages(cfg)
+	if err != nil {
+		e.Close()
+		return nil, err
+	}
+	exporter := NewStaticExporter(e, pages, store, output)
+	exporter.engine = e
+	return exporter, nil
+}
+
+// NewStaticExporter creates a StaticExporter dispatching the requests of the pages to the
+// received handler
+func NewStaticExporter(h http.Handler, pages []Page, store *TemplateStore, output string) *StaticExporter {
+	return &StaticExporter{
+		Handler:     h,
+		Pages:       pages,
+		Output:      output,
+		Concurrency: defaultExportConcurrency,
+		templates:   &HealthChecker{Pages: pages, Store: store},
+		sitemap:     NewSitemapGenerator(SitemapXML{}, pages),
+	}
+}
+
+// StaticExporter renders a list of paths with the engine and stores the responses as static
+// HTML files in the Output folder, so the site can be hosted by a CDN. Every path is stored as
+// the index.html file of its folder, unless its last segment has an extension
+type StaticExporter struct {
+	Handler http.Handler
+	Pages   []Page
+	Output  string
+	// Headers are added to every request
+	Headers     map[string]string
+	Concurrency int
+	templates   *HealthChecker
+	sitemap     *SitemapGenerator
+	// engine is the engine created for the exporter, if any
+	engine *Engine
+}
+
+// Close releases the engine created with the exporter. The received handlers are not closed
+func (e *StaticExporter) Close() error {
+	if e.engine == nil {
+		return nil
+	}
+	return e.engine.Close()
+}
+
+// URLs returns the paths of the pages without params and the ones listed by the sitemap sources
+// of the pages with params. The pages excluded from the sitemap are skipped
+func (e *StaticExporter) URLs() ([]string, error) {
+	paths := []string{}
+	for _, page := range e.Pages {
+		urls, err := e.sitemap.pageURLs(page)
+		if err != nil {
+			return nil, fmt.Errorf("listing the URLs of the page %s: %s", page.Name, err.Error())
+		}
+		for _, u := range urls {
+			paths = append(paths, u.Loc)
+		}
+	}
+	return paths, nil
+}
+
+// Export renders the paths once all the templates of the pages are published, writing the
+// successful responses into the Output folder. The redirections are skipped. It returns an
+// error if any of the paths fails
+func (e *StaticExporter) Export(paths []string) error {
+	if err := e.templates.waitTemplates(exportTemplatesTimeout); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	concurrency := e.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultExportConcurrency
+	}
+	queue := make(chan string)
+	var mutex sync.Mutex
+	failed := 0
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range queue {
+				if err := e.export(p); err != nil {
+					log.Println("export:", p, err.Error())
+					mutex.Lock()
+					failed++
+					mutex.Unlock()
+				}
+			}
+		}()
+	}
+	for _, p := range paths {
+		queue <- p
+	}
+	close(queue)
+	wg.Wait()
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d paths failed", failed, len(paths))
+	}
+	log.Println("export:", len(paths), "paths rendered in", time.Since(start))
+	return nil
+}
+
+func (e *StaticExporter) export(p string) error {
+	req, err := http.NewRequest(http.MethodGet, p, nil)
+	if err != nil {
+		return err
+	}
+	for k, v := range e.Headers {
+		req.Header.Set(k, v)
+	}
+	rec := &fragmentWriter{header: http.Header{}, status: http.StatusOK}
+	e.Handler.ServeHTTP(rec, req)
+	switch {
+	case rec.status >= http.StatusMultipleChoices && rec.status < http.StatusBadRequest:
+		log.Println("export: skipping the redirection of", p, "to", rec.header.Get("Location"))
+		return nil
+	case rec.status != http.StatusOK:
+		return fmt.Errorf("unexpected status code %d", rec.status)
+	}
+
+	filename := filepath.Join(e.Output, filepath.FromSlash(exportFile(req.URL.Path)))
+	if err := os.MkdirAll(filepath.Dir(filename), os.ModePerm); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, rec.body.Bytes(), 0644)
+}
+
+// exportFile returns the relative file name storing the response of the path
+func exportFile(p string) string {
+	clean := strings.TrimPrefix(path.Clean("/"+p), "/")
+	if strings.HasSuffix(p, "/") || path.Ext(clean) == "" {
+		return path.Join(clean, "index.html")
+	}
+	return clean
+}
@@ -0,0 +1,107 @@
+package engine
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestStaticExporter(t *testing.T) {
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `[{"id":1},{"id":2}]`)
+	}))
+	defer source.Close()
+
+	pages := []Page{
+		{Name: "home", URLPattern: "/", Template: "home"},
+		{Name: "product", URLPattern: "/products/:id", Template: "product", Sitemap: &PageSitemap{Source: source.URL, Params: map[string]string{"id": "id"}}},
+		{Name: "search", URLPattern: "/search/:q", Template: "home"},
+		{Name: "feed", URLPattern: "/feed.xml", Template: "home"},
+		{Name: "private", URLPattern: "/private", Template: "home", Sitemap: &PageSitemap{Exclude: true}},
+	}
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/moved":
+			http.Redirect(w, r, "/", http.StatusMovedPermanently)
+		case "/broken":
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			fmt.Fprint(w, "rendered "+r.URL.Path)
+		}
+	})
+	store := NewTemplateStore()
+	store.Set("home", EmptyRenderer)
+	store.Set("product", EmptyRenderer)
+	dir := t.TempDir()
+	e := NewStaticExporter(h, pages, store, dir)
+
+	paths, err := e.URLs()
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if fmt.Sprint(paths) != "[/ /products/1 /products/2 /feed.xml]" {
+		t.Errorf("unexpected paths: %v", paths)
+	}
+
+	if err := e.Export(append(paths, "/moved")); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	for name, expected := range map[string]string{
+		"index.html":            "rendered /",
+		"products/1/index.html": "rendered /products/1",
+		"products/2/index.html": "rendered /products/2",
+		"feed.xml":              "rendered /feed.xml",
+	} {
+		b, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil || string(b) != expected {
+			t.Errorf("%s: unexpected content: %s %v", name, b, err)
+		}
+	}
+	if _, err := ioutil.ReadFile(filepath.Join(dir, "moved", "index.html")); err == nil {
+		t.Error("the redirection should be skipped")
+	}
+
+	if err := e.Export([]string{"/", "/broken"}); err == nil || err.Error() != "1 of 2 paths failed" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func Test_exportFile(t *testing.T) {
+	for p, expected := range map[string]string{
+		"/":                 "index.html",
+		"/about":            "about/index.html",
+		"/about/":           "about/index.html",
+		"/sitemap.xml":      "sitemap.xml",
+		"/../../etc/passwd": "etc/passwd/index.html",
+	} {
+		if file := exportFile(p); file != expected {
+			t.Errorf("%s: unexpected file %s", p, file)
+		}
+	}
+}
+
+func TestFactory_NewStaticExporter_close(t *testing.T) {
+	var store *TemplateStore
+	ef := DefaultFactory
+	ef.Parser = func(_ string) (Config, error) { return Config{}, nil }
+	ef.TemplateStoreFactory = func() *TemplateStore {
+		store = NewTemplateStore()
+		return store
+	}
+	e, err := ef.NewStaticExporter("config.json", t.TempDir())
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if err := e.Close(); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+	if !store.closed() {
+		t.Error("the engine of the exporter was not closed")
+	}
+}
@@ -1,21 +1,25 @@
 package engine
 
 import (
+	"context"
 	"fmt"
+	"io/fs"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/gin-contrib/static"
 	"github.com/gin-gonic/gin"
-	newrelic "github.com/newrelic/go-agent"
-	nrgin "github.com/newrelic/go-agent/_integrations/nrgin/v1"
+	"github.com/jmespath/go-jmespath"
 )
 
 // DefaultFactory is an Factory ready to be used
 var DefaultFactory = Factory{
 	TemplateStoreFactory: NewTemplateStore,
-	Parser:               ParseConfigFromFile,
+	Parser:               ParseConfigFromSource,
 	MustachePageFactory:  NewMustachePageFactory,
 	StaticHandlerFactory: NewStaticHandler,
 	ErrorHandlerFactory:  NewErrorHandler,
@@ -28,40 +32,539 @@ type Factory struct {
 	MustachePageFactory  func(*gin.Engine, *TemplateStore) MustachePageFactory
 	StaticHandlerFactory func(string) (StaticHandler, error)
 	ErrorHandlerFactory  func(string, int) (ErrorHandler, error)
+	// Middlewares are registered into the gin engine before mounting the pages, so they are
+	// executed for every page and route
+	Middlewares []gin.HandlerFunc
+	// Routes are called with the gin engine before mounting the pages, so embedders can add
+	// their own endpoints
+	Routes []func(*gin.Engine)
+	// TemplateFS is the filesystem containing the templates, layouts, partials, static files and
+	// public folder, so they can be embedded into the binary. If nil, the local filesystem is
+	// used. Otherwise, the FileSource of the config selects whether the embedded files or the
+	// ones in the local filesystem take precedence
+	TemplateFS fs.FS
+	// Helpers are the custom template helpers of the engines built by the factory, besides the
+	// ones declared in the config
+	Helpers map[string]HelperFunc
+	// PageMiddlewares are the custom named middlewares the pages can add to their routes,
+	// besides the ones declared in the config
+	PageMiddlewares map[string]PageMiddlewareFunc
+	// Decoders are the custom decoders of the backend responses of the built engines, so the
+	// pages and their backends can declare their names as encoding
+	Decoders map[string]Decoder
+
+	// ResponseGenerators and Renderers are the custom response generators and renderers of
+	// the built engines, so the pages can declare their names as generator and renderer
+	ResponseGenerators map[string]ResponseGeneratorFactory
+	Renderers          map[string]RendererFactory
+	// BackendCacheStore, if set, replaces the in-process cache of the backend responses, unless
+	// the config declares its own backend cache store
+	BackendCacheStore CacheStoreFactory
+}
+
+// Use returns a copy of the factory registering the received middlewares
+func (ef Factory) Use(middlewares ...gin.HandlerFunc) Factory {
+	ef.Middlewares = append(append([]gin.HandlerFunc{}, ef.Middlewares...), middlewares...)
+	return ef
+}
+
+// Handle returns a copy of the factory registering the received route setters
+func (ef Factory) Handle(routes ...func(*gin.Engine)) Factory {
+	ef.Routes = append(append([]func(*gin.Engine){}, ef.Routes...), routes...)
+	return ef
+}
+
+// WithHelper returns a copy of the factory registering the received template helper
+func (ef Factory) WithHelper(name string, h HelperFunc) Factory {
+	helpers := map[string]HelperFunc{name: h}
+	for k, v := range ef.Helpers {
+		if k != name {
+			helpers[k] = v
+		}
+	}
+	ef.Helpers = helpers
+	return ef
 }
 
-// New creates a gin engine with the received config and the injected factories
-func (ef Factory) New(cfgPath string, devel bool) (*gin.Engine, error) {
+// WithDecoder returns a copy of the factory registering the received decoder
+func (ef Factory) WithDecoder(name string, d Decoder) Factory {
+	decoders := map[string]Decoder{name: d}
+	for k, v := range ef.Decoders {
+		if k != name {
+			decoders[k] = v
+		}
+	}
+	ef.Decoders = decoders
+	return ef
+}
+
+// WithResponseGenerator returns a copy of the factory registering the received response
+// generator factory
+func (ef Factory) WithResponseGenerator(name string, f ResponseGeneratorFactory) Factory {
+	generators := map[string]ResponseGeneratorFactory{name: f}
+	for k, v := range ef.ResponseGenerators {
+		if k != name {
+			generators[k] = v
+		}
+	}
+	ef.ResponseGenerators = generators
+	return ef
+}
+
+// WithRenderer returns a copy of the factory registering the received renderer factory
+func (ef Factory) WithRenderer(name string, f RendererFactory) Factory {
+	renderers := map[string]RendererFactory{name: f}
+	for k, v := range ef.Renderers {
+		if k != name {
+			renderers[k] = v
+		}
+	}
+	ef.Renderers = renderers
+	return ef
+}
+
+// WithBackendCacheStore returns a copy of the factory caching the backend responses into the
+// stores returned by the received factory
+func (ef Factory) WithBackendCacheStore(f CacheStoreFactory) Factory {
+	ef.BackendCacheStore = f
+	return ef
+}
+
+// Engine is the gin engine serving the pages of a config. It owns the watchers, the
+// subscriptions and the exporters started for it, so it must be closed once it is replaced or
+// no longer served
+type Engine struct {
+	*gin.Engine
+	closers []func() error
+	once    sync.Once
+	// readiness looks for the renderers of the pages and the end of the warm up
+	readiness *HealthChecker
+}
+
+// Close releases the resources of the engine, in the reverse order of their creation, and
+// returns the first error
+func (e *Engine) Close() error {
+	var err error
+	e.once.Do(func() {
+		for i := len(e.closers) - 1; i >= 0; i-- {
+			if cerr := e.closers[i](); cerr != nil && err == nil {
+				err = cerr
+			}
+		}
+	})
+	return err
+}
+
+// onClose adds a function to run when the engine is closed
+func (e *Engine) onClose(f func() error) {
+	e.closers = append(e.closers, f)
+}
+
+// waitReady returns once all the templates of the pages are published and the warm up is done
+// or an error if it does not happen before the timeout
+func (e *Engine) waitReady(timeout time.Duration) error {
+	if e.readiness == nil {
+		return nil
+	}
+	deadline := time.Now().Add(timeout)
+	if err := e.readiness.waitTemplates(timeout); err != nil {
+		return err
+	}
+	for e.readiness.Warmer != nil && !e.readiness.Warmer.Done() {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for the warm up")
+		}
+		time.Sleep(warmupRetryDelay)
+	}
+	return nil
+}
+
+// onStop adds a stop function, without errors, to run when the engine is closed
+func (e *Engine) onStop(stop func()) {
+	e.onClose(func() error {
+		stop()
+		return nil
+	})
+}
+
+// New creates an engine with the received config and the injected factories
+func (ef Factory) New(cfgPath string, devel bool) (*Engine, error) {
 	cfg, err := ef.Parser(cfgPath)
 	if err != nil {
 		return nil, err
 	}
+	return ef.NewFromConfig(cfg, devel)
+}
 
-	if cfg.NewRelic != nil && cfg.NewRelic.License != "" {
-		nrCfg := newrelic.NewConfig(cfg.NewRelic.AppName, cfg.NewRelic.License)
-		if devel {
-			nrCfg.Logger = newrelic.NewDebugLogger(os.Stdout)
+// NewFromConfig creates an engine with the received config and the injected factories. If it
+// fails, the resources already created are released
+func (ef Factory) NewFromConfig(cfg Config, devel bool) (_ *Engine, err error) {
+	pages, err := routePages(cfg)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Pages = pages
+
+	engine := &Engine{}
+	defer func() {
+		if err != nil {
+			engine.Close()
+		}
+	}()
+
+	instrumentation, err := NewInstrumentation(cfg, devel)
+	// the exporters enabled before a failure are released too
+	engine.onClose(instrumentation.Close)
+	if err != nil {
+		return nil, err
+	}
+
+	filesFS, err := ef.templateFS(cfg, devel)
+	if err != nil {
+		return nil, err
+	}
+	if filesFS != nil {
+		ef.StaticHandlerFactory = func(path string) (StaticHandler, error) {
+			return NewStaticHandlerFS(filesFS, path)
 		}
-		nrapp, err := newrelic.NewApplication(nrCfg)
+		ef.ErrorHandlerFactory = func(path string, code int) (ErrorHandler, error) {
+			return NewErrorHandlerFS(filesFS, path, code)
+		}
+	}
+
+	if cfg.RobotsTXT != nil {
+		robotsTXT := cfg.RobotsTXT.ForEnvironment(environment(cfg))
+		cfg.RobotsTXT = &robotsTXT
+	}
+
+	// the engine gets its own copy of the components registered in the package, extended with
+	// the ones of the factory, the plugins and the config
+	reg := registeredComponents()
+	if err := reg.addHelpers(cfg.Helpers, ef.Helpers); err != nil {
+		return nil, err
+	}
+	for name, d := range ef.Decoders {
+		reg.decoders[name] = d
+	}
+	for name, f := range ef.ResponseGenerators {
+		reg.generators[name] = f
+	}
+	for name, f := range ef.Renderers {
+		reg.renderers[name] = f
+	}
+	if err := reg.loadPlugins(cfg.Plugins); err != nil {
+		return nil, err
+	}
+
+	reg.cacheStore = ef.BackendCacheStore
+	if cfg.BackendCache != nil {
+		reg.cacheStore, err = NewCacheStoreFactory(*cfg.BackendCache)
 		if err != nil {
 			return nil, err
 		}
-		newrelicApp = &nrapp
+	}
+	reg.setOAuth2Clients(cfg.OAuth2Clients)
+	if err := reg.setPartialCaches(cfg.PartialCache); err != nil {
+		return nil, err
+	}
+	cfg.registry = reg
+
+	if cfg.AuthPages != nil {
+		authPages, err := ef.newAuthPagesHandler(*cfg.AuthPages)
+		if err != nil {
+			return nil, err
+		}
+		// the auth pages must be attached before the auth middlewares get executed
+		ef.Middlewares = append([]gin.HandlerFunc{authPages.HandlerFunc()}, ef.Middlewares...)
+	}
+
+	pageMiddlewares, err := NewPageMiddlewares(cfg.Middlewares, ef.PageMiddlewares)
+	if err != nil {
+		return nil, err
+	}
+	protected, err := NewProtectedPaths(cfg.Protect, pageMiddlewares)
+	if err != nil {
+		return nil, err
+	}
+	// the protected paths run after the auth pages, so the rejected requests get their pages
+	ef.Middlewares = append(ef.Middlewares, protected...)
+
+	if cfg.CSP != nil {
+		ef.Middlewares = append(ef.Middlewares, NewCSPMiddleware(*cfg.CSP))
+	}
+
+	if cfg.RateLimit != nil {
+		ef.Middlewares = append([]gin.HandlerFunc{NewRateLimiter(*cfg.RateLimit).HandlerFunc()}, ef.Middlewares...)
+	}
+
+	redirects, err := NewURLRedirects(cfg.Redirects)
+	if err != nil {
+		return nil, err
+	}
+	if redirects != nil {
+		// the legacy URLs are redirected before routing the requests to the pages
+		ef.Middlewares = append([]gin.HandlerFunc{redirects}, ef.Middlewares...)
+	}
+	rewriter, err := NewURLRewriter(cfg.Rewrites)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.TrapRoutes != nil {
+		trap, err := NewTrapMiddleware(*cfg.TrapRoutes)
+		if err != nil {
+			return nil, err
+		}
+		// the blocked clients must be rejected before doing anything else
+		ef.Middlewares = append([]gin.HandlerFunc{trap}, ef.Middlewares...)
+	}
+
+	if cfg.LoadShedding != nil {
+		// the requests must be shed before spending any resources on them
+		ef.Middlewares = append([]gin.HandlerFunc{NewLoadShedder(*cfg.LoadShedding).HandlerFunc()}, ef.Middlewares...)
+	}
+
+	fingerprints, err := ef.runAssetPipeline(engine, cfg, devel)
+	if err != nil {
+		return nil, err
 	}
 
+	templateFS := filesFS
+	var remoteTemplates *RemoteTemplateSource
+	if cfg.RemoteTemplates != nil {
+		remoteTemplates, err = NewRemoteTemplateSource(context.Background(), *cfg.RemoteTemplates)
+		if err != nil {
+			return nil, err
+		}
+		engine.onClose(remoteTemplates.Bucket.Close)
+		templateFS = remoteTemplates
+	}
+	var gitTemplates *GitTemplateRepository
+	if cfg.GitTemplates != nil {
+		gitTemplates, err = NewGitTemplateRepository(*cfg.GitTemplates)
+		if err != nil {
+			return nil, err
+		}
+		templateFS = os.DirFS(gitTemplates.Dir)
+	}
 	templateStore := ef.TemplateStoreFactory()
-	e := ef.newGinEngine(cfg, devel)
+	engine.onClose(templateStore.Close)
+	e, err := ef.newGinEngine(engine, cfg, devel, instrumentation, rewriter, fingerprints, filesFS)
+	if err != nil {
+		return nil, err
+	}
+	engine.Engine = e
+	if cfg.GeoIP != nil {
+		locator, err := NewMaxMindLocator(cfg.GeoIP.Database)
+		if err != nil {
+			return nil, err
+		}
+		engine.onClose(locator.Close)
+		e.Use(GeoIPMiddleware(locator))
+	}
+	if cfg.TemplateCanary != nil {
+		e.Use(CanaryMiddleware(cfg.TemplateCanary.Cookie))
+	}
+	if cfg.Admin != nil && (devel || cfg.Admin.Token != "") {
+		admin := NewAdminAPI(cfg, templateStore)
+		adminPath := cfg.Admin.Path
+		if adminPath == "" {
+			adminPath = DefaultAdminPath
+		}
+		e.Use(admin.HandlerFunc())
+		admin.Routes(adminPath, cfg.Admin.Token)(e)
+	}
+	var pageCache *OutputCache
+	if cfg.PageCache != nil {
+		pageCache, err = NewOutputCache(*cfg.PageCache)
+		if err != nil {
+			return nil, err
+		}
+		if devel || cfg.PageCache.Token != "" {
+			e.Use(pageCache.PurgeHandlerFunc(cfg.PageCache.Token))
+			pageCache.Routes(cfg.PageCache.Token)(e)
+		}
+	}
+	if devel {
+		e.Use(DebugMiddleware())
+	}
+	if cfg.I18n != nil {
+		translator, err := NewTranslator(templateFS, *cfg.I18n)
+		if err != nil {
+			return nil, err
+		}
+		reg.helpers[TranslateHelper] = translator.Helper()
+		e.Use(translator.HandlerFunc())
+	}
+	for _, wk := range cfg.WellKnown {
+		h, err := newWellKnownHandler(templateFS, wk, cfg.Extra, reg)
+		if err != nil {
+			return nil, err
+		}
+		log.Println("registering the well-known file", wk.Path)
+		e.GET(wk.Path, h.HandlerFunc())
+	}
+
+	pools := newTargetPools()
+	engine.onClose(pools.Close)
+	for i := range cfg.Pages {
+		cfg.Pages[i].StatusMapping = mergeStatusMappings(cfg.StatusMapping, cfg.Pages[i].StatusMapping)
+		types := pageMiddlewareTypes(cfg, cfg.Pages[i])
+		cfg.Pages[i].authenticated = types[JWTMiddleware] || types[BasicAuthMiddleware]
+		cfg.Pages[i].pools = pools
+		cfg.Pages[i].registry = reg
+	}
+	routed := cfg.Pages
+	if cfg.RenderProxy != nil {
+		routed = append(routed[:len(routed):len(routed)], Page{Name: renderProxyPage, URLPattern: cfg.RenderProxy.prefix() + "/*path"})
+	}
+	if err := checkRouterConflicts(routed); err != nil {
+		return nil, err
+	}
+	routes := map[string]string{}
+	for _, page := range cfg.Pages {
+		if _, err := pageHandlers(page, pageMiddlewares); err != nil {
+			return nil, err
+		}
+		route := strings.ToLower(page.Host) + page.URLPattern
+		if other, ok := routes[route]; ok {
+			return nil, fmt.Errorf("page %s: the URL pattern %s is already used by the page %s", page.Name, page.URLPattern, other)
+		}
+		routes[route] = page.Name
+		if page.Signing != nil {
+			if _, err := NewRequestSigner(*page.Signing); err != nil {
+				return nil, fmt.Errorf("page %s: %s", page.Name, err.Error())
+			}
+		}
+		if page.Sanitize != nil {
+			if _, err := NewSanitizer(*page.Sanitize); err != nil {
+				return nil, fmt.Errorf("page %s: %s", page.Name, err.Error())
+			}
+		}
+		if _, err := pageDecoder(page); err != nil {
+			return nil, fmt.Errorf("page %s: %s", page.Name, err.Error())
+		}
+		if page.DataPath != "" {
+			if _, err := jmespath.Compile(page.DataPath); err != nil {
+				return nil, fmt.Errorf("page %s: data path: %s", page.Name, err.Error())
+			}
+		}
+		if err := validErrorTemplates(page.ErrorTemplates, cfg.Templates); err != nil {
+			return nil, fmt.Errorf("page %s: %s", page.Name, err.Error())
+		}
+		if err := validStatusMapping(page.StatusMapping); err != nil {
+			return nil, fmt.Errorf("page %s: %s", page.Name, err.Error())
+		}
+		if _, err := NewRedirectFunc(page.Redirects); err != nil {
+			return nil, fmt.Errorf("page %s: %s", page.Name, err.Error())
+		}
+		if _, err := newTransformFuncs(page.Transform); err != nil {
+			return nil, fmt.Errorf("page %s: %s", page.Name, err.Error())
+		}
+		if page.Script != nil {
+			if _, err := NewScriptProcessor(*page.Script); err != nil {
+				return nil, fmt.Errorf("page %s: script: %s", page.Name, err.Error())
+			}
+		}
+		if err := page.registry.registeredPlugins(page.Generator, page.Renderer); err != nil {
+			return nil, fmt.Errorf("page %s: %s", page.Name, err.Error())
+		}
+		if page.PageCache && cfg.PageCache == nil {
+			return nil, fmt.Errorf("page %s: the page cache is not declared", page.Name)
+		}
+		if page.PageCache && page.Forward.forwardsCredentials() {
+			// the personalized pages must not be shared between the users
+			return nil, fmt.Errorf("page %s: the pages forwarding credentials can not be cached", page.Name)
+		}
+		if page.PageCache && page.authenticated {
+			// the pages of the authenticated users may be personalized (Extra.user of the jwt)
+			return nil, fmt.Errorf("page %s: the pages of the authenticated users can not be cached", page.Name)
+		}
+		if page.StaleTTL != "" && (page.Forward.forwardsCredentials() || page.authenticated) {
+			// the stale responses are kept by URL, so they would be served to other users
+			return nil, fmt.Errorf("page %s: the personalized pages can not keep stale responses", page.Name)
+		}
+		if _, err := time.ParseDuration(page.Budget); page.Budget != "" && err != nil {
+			return nil, fmt.Errorf("page %s: invalid budget: %q", page.Name, page.Budget)
+		}
+		for name, backend := range page.Backends {
+			if _, err := backendDecoder(page, backend); err != nil {
+				return nil, fmt.Errorf("page %s: backend %s: %s", page.Name, name, err.Error())
+			}
+		}
+		if _, ok := cfg.OAuth2Clients[page.OAuth2Client]; page.OAuth2Client != "" && !ok {
+			return nil, fmt.Errorf("page %s: unknown oauth2 client %s", page.Name, page.OAuth2Client)
+		}
+	}
+
 	pf := ef.MustachePageFactory(e, templateStore)
-	pf.Build(cfg)
+	pf.FS = templateFS
+	pf.Middlewares = pageMiddlewares
+	pf.PageCache = pageCache
+	if devel && cfg.LiveReload {
+		pf.LiveReload = true
+		e.GET(LiveReloadPath, NewLiveReload(templateStore).HandlerFunc())
+	}
+	if cfg.TemplateSets != nil {
+		templateSwitch, err := NewTemplateSwitch(*cfg.TemplateSets)
+		if err != nil {
+			return nil, err
+		}
+		pf.TemplateSwitch = templateSwitch
+		if devel || cfg.TemplateSets.Token != "" {
+			templateSwitch.Routes(cfg.TemplateSets.Token)(e)
+		}
+	}
+	var warmer *CacheWarmer
+	if cfg.Warmup != nil {
+		warmer = NewCacheWarmer(*cfg.Warmup, cfg.Pages, e, templateStore)
+	}
+	registerHealthChecks(e, cfg, templateStore, warmer)
 
+	var notFound gin.HandlerFunc
 	if h, err := ef.StaticHandlerFactory("./static/404"); err == nil {
-		e.NoRoute(h.HandlerFunc())
+		notFound = notFoundHandlerFunc(cfg, h)
 	} else {
 		log.Println("using the default 404 template")
-		e.NoRoute(Default404StaticHandler.HandlerFunc())
+		notFound = notFoundHandlerFunc(cfg, Default404StaticHandler)
+	}
+	pf.NotFound = notFound
+	pf.Build(cfg)
+	e.NoRoute(notFound)
+
+	var graph *TemplateGraph
+	if devel || remoteTemplates != nil || gitTemplates != nil || cfg.Refresh != nil {
+		// the Go templates are not reloaded
+		graph, err = NewTemplateGraph(templateFS, mustacheConfig(cfg))
+		if err != nil {
+			return nil, err
+		}
+		graph.History = newTemplateHistory(cfg, templateStore)
+		if cfg.TemplateCanary != nil {
+			graph.CanaryPercentage = cfg.TemplateCanary.Percentage
+		}
+	}
+
+	switch {
+	case remoteTemplates != nil:
+		engine.onStop(remoteTemplates.Watch(graph, templateStore))
+	case gitTemplates != nil:
+		engine.onStop(gitTemplates.Watch(graph, templateStore))
+		webhookPath := cfg.GitTemplates.WebhookPath
+		if webhookPath == "" {
+			webhookPath = defaultGitTemplatesWebhook
+		}
+		e.POST(webhookPath, gitTemplates.WebhookHandlerFunc(graph, templateStore))
+	case devel:
+		if stop, err := graph.Watch(templateStore); err != nil {
+			log.Println("watching the templates:", err.Error())
+		} else {
+			engine.onClose(stop)
+		}
 	}
 
 	if devel {
+
 		e.PUT("/template/:templateName", func(c *gin.Context) {
 			file, err := c.FormFile("file")
 			if err != nil {
@@ -77,65 +580,300 @@ func (ef Factory) New(cfgPath string, devel bool) (*gin.Engine, error) {
 
 			defer f.Close()
 
-			tmp, err := NewMustacheRenderer(f)
+			tmp, err := newMustacheRenderer(templateFS, f, reg)
 			if err != nil {
 				c.AbortWithError(http.StatusInternalServerError, err)
 				return
 			}
 
 			templateName := c.Param("templateName")
-			if err := templateStore.Set(templateName, tmp); err != nil {
+			if err := graph.Publish(templateStore, templateName, tmp); err != nil {
 				c.AbortWithError(http.StatusInternalServerError, err)
 				return
 			}
 
 			c.String(http.StatusOK, fmt.Sprintf("'%s' uploaded and stored as [%s]!", templateName, file.Filename))
 		})
+
+		e.GET("/template/:templateName/versions", func(c *gin.Context) {
+			c.JSON(http.StatusOK, graph.History.Versions(c.Param("templateName")))
+		})
+
+		e.POST("/template/:templateName/versions/:version/rollback", func(c *gin.Context) {
+			templateName := c.Param("templateName")
+			version, err := strconv.Atoi(c.Param("version"))
+			if err != nil {
+				c.AbortWithError(http.StatusBadRequest, err)
+				return
+			}
+			if err := graph.Rollback(templateStore, templateName, version); err == ErrUnknownTemplateVersion {
+				c.AbortWithError(http.StatusNotFound, err)
+				return
+			} else if err != nil {
+				c.AbortWithError(http.StatusInternalServerError, err)
+				return
+			}
+
+			c.String(http.StatusOK, fmt.Sprintf("'%s' rolled back to the version %d!", templateName, version))
+		})
+
+		e.GET("/template/:templateName/canary", func(c *gin.Context) {
+			c.JSON(http.StatusOK, graph.Canaries(templateStore, c.Param("templateName")))
+		})
+
+		resolveCanary := func(resolve func(*TemplateStore, string) error, result string) gin.HandlerFunc {
+			return func(c *gin.Context) {
+				templateName := c.Param("templateName")
+				if err := resolve(templateStore, templateName); err == ErrNoCanary {
+					c.AbortWithError(http.StatusNotFound, err)
+					return
+				} else if err != nil {
+					c.AbortWithError(http.StatusInternalServerError, err)
+					return
+				}
+
+				c.String(http.StatusOK, fmt.Sprintf("'%s' canary %s!", templateName, result))
+			}
+		}
+		e.POST("/template/:templateName/canary/promote", resolveCanary(graph.PromoteCanary, "promoted"))
+		e.POST("/template/:templateName/canary/abort", resolveCanary(graph.AbortCanary, "aborted"))
+
+		e.GET("/backend_cache", BackendCacheStats.HandlerFunc())
 	}
-	return e, nil
+	if cfg.Refresh != nil && (devel || cfg.Refresh.Token != "") {
+		refresher := &Refresher{
+			Graph: graph,
+			Store: templateStore,
+			Purge: []func(){reg.purgePartialCaches, BackendCacheStats.Purge, pf.fragments.Purge, pf.purgeStale},
+		}
+		if pageCache != nil {
+			refresher.Purge = append(refresher.Purge, pageCache.InvalidateAll)
+		}
+		switch {
+		case remoteTemplates != nil:
+			refresher.Sync = func() error {
+				_, err := remoteTemplates.Sync(context.Background())
+				return err
+			}
+		case gitTemplates != nil:
+			refresher.Sync = func() error {
+				_, err := gitTemplates.Pull()
+				return err
+			}
+		}
+		refreshPath := cfg.Refresh.Path
+		if refreshPath == "" {
+			refreshPath = DefaultRefreshPath
+		}
+		e.POST(refreshPath, refresher.HandlerFunc(cfg.Refresh.Token))
+	}
+	if warmer != nil {
+		go warmer.Run()
+	}
+	engine.readiness = &HealthChecker{Pages: cfg.Pages, Store: templateStore, Warmer: warmer}
+
+	return engine, nil
+}
+
+// newTemplateHistory creates a TemplateHistory with the templates and layouts loaded on start as
+// their initial versions
+func newTemplateHistory(cfg Config, templateStore *TemplateStore) *TemplateHistory {
+	history := NewTemplateHistory(cfg.TemplateVersions)
+	for _, section := range []map[string]string{cfg.Templates, cfg.Layouts} {
+		for name := range section {
+			if r, ok := templateStore.Get(name); ok {
+				if m, ok := r.(*MustacheRenderer); ok {
+					history.Add(name, InitialTemplateVersion, m)
+				}
+			}
+		}
+	}
+	return history
+}
+
+func (ef Factory) newAuthPagesHandler(cfg AuthPages) (*AuthPagesHandler, error) {
+	unauthorized, err := ef.ErrorHandlerFactory("./static/401", http.StatusUnauthorized)
+	if err != nil {
+		log.Println("using the default 401 template")
+		unauthorized = Default401StaticHandler
+	}
+	forbidden, err := ef.ErrorHandlerFactory("./static/403", http.StatusForbidden)
+	if err != nil {
+		log.Println("using the default 403 template")
+		forbidden = Default403StaticHandler
+	}
+	return NewAuthPagesHandler(cfg, unauthorized, forbidden)
+}
+
+// runAssetPipeline builds the assets, watching their sources in devel mode, and returns the
+// fingerprinted paths of the outputs, if enabled
+func (ef Factory) runAssetPipeline(engine *Engine, cfg Config, devel bool) (map[string]string, error) {
+	if cfg.AssetPipeline == nil {
+		return nil, nil
+	}
+	if err := RunAssetPipeline(*cfg.AssetPipeline); err != nil {
+		return nil, err
+	}
+	if devel {
+		stop, err := WatchAssetPipeline(*cfg.AssetPipeline)
+		if err != nil {
+			return nil, err
+		}
+		engine.onClose(stop)
+		return nil, nil
+	}
+	if !cfg.AssetPipeline.Fingerprint {
+		return nil, nil
+	}
+	if cfg.PublicFolder == nil {
+		return nil, fmt.Errorf("the asset fingerprinting requires a public folder")
+	}
+	return FingerprintAssets(*cfg.AssetPipeline, *cfg.PublicFolder)
+}
+
+// registerHealthChecks registers the liveness and the readiness endpoints, unless a page is
+// already using their paths
+func registerHealthChecks(e *gin.Engine, cfg Config, store *TemplateStore, warmer *CacheWarmer) {
+	health := HealthChecks{}
+	if cfg.Health != nil {
+		health = *cfg.Health
+	}
+	if health.Path == "" {
+		health.Path = DefaultHealthPath
+	}
+	if health.ReadinessPath == "" {
+		health.ReadinessPath = DefaultReadinessPath
+	}
+	used := map[string]bool{}
+	for _, page := range cfg.Pages {
+		used[page.URLPattern] = true
+	}
+	if !used[health.Path] {
+		e.GET(health.Path, LivenessHandlerFunc)
+	}
+	if !used[health.ReadinessPath] {
+		checker := NewHealthChecker(health, cfg.Pages, store)
+		checker.Warmer = warmer
+		e.GET(health.ReadinessPath, checker.ReadinessHandlerFunc())
+	}
+}
+
+// templateFS returns the filesystem with the embedded files and the local ones, in the order
+// selected by the file source of the config. It defaults to the local files in devel mode and
+// to the embedded ones otherwise
+func (ef Factory) templateFS(cfg Config, devel bool) (fs.FS, error) {
+	if ef.TemplateFS == nil {
+		return nil, nil
+	}
+	source := cfg.FileSource
+	if source == "" {
+		source = EmbeddedFileSource
+		if devel {
+			source = DiskFileSource
+		}
+	}
+	switch source {
+	case EmbeddedFileSource:
+		return NewOverlayFS(ef.TemplateFS, os.DirFS(".")), nil
+	case DiskFileSource:
+		return NewOverlayFS(os.DirFS("."), ef.TemplateFS), nil
+	}
+	return nil, fmt.Errorf("unknown file source: %s", source)
 }
 
-func (ef Factory) newGinEngine(cfg Config, devel bool) *gin.Engine {
+func (ef Factory) newGinEngine(engine *Engine, cfg Config, devel bool, instrumentation Instrumentation, rewriter *URLRewriter, fingerprints map[string]string, fsys fs.FS) (*gin.Engine, error) {
 	if !devel {
 		gin.SetMode(gin.ReleaseMode)
 	}
-	e := gin.Default()
+	e := gin.New()
+	// gin trusts the forwarded headers of every client by default, so they could spoof their IP
+	if err := e.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("trusted proxies: %s", err.Error())
+	}
+	if rewriter != nil {
+		// the rewritten requests are routed again, so the rest of the middlewares (the logger
+		// too) must run after the rewriter to handle them just once
+		e.Use(rewriter.HandlerFunc(e))
+	}
+	e.Use(gin.Logger(), gin.Recovery())
 	e.RedirectTrailingSlash = true
 	e.RedirectFixedPath = true
 
-	if newrelicApp != nil {
-		e.Use(nrgin.Middleware(*newrelicApp))
+	e.Use(instrumentation.Middlewares...)
+	e.Use(ef.Middlewares...)
+	ef.setStatics(engine, e, cfg, devel, fingerprints, fsys)
+
+	for _, routes := range [][]func(*gin.Engine){instrumentation.Routes, ef.Routes} {
+		for _, route := range routes {
+			route(e)
+		}
 	}
-	ef.setStatics(e, cfg)
 
-	return e
+	return e, nil
 }
 
-func (ef Factory) setStatics(e *gin.Engine, cfg Config) {
+// setStatics registers the public folder, the static files and the error pages, read from the
+// received fs.FS. If the fs.FS is nil, the local filesystem is used
+func (ef Factory) setStatics(engine *Engine, e *gin.Engine, cfg Config, devel bool, fingerprints map[string]string, fsys fs.FS) {
+	staticFile := e.StaticFile
+	if fsys != nil {
+		staticFile = func(relativePath, filepath string) gin.IRoutes {
+			return e.StaticFileFS(relativePath, fsPath(filepath), http.FS(fsys))
+		}
+	}
+
 	if cfg.PublicFolder != nil {
-		e.Use(static.Serve(cfg.PublicFolder.Prefix, static.LocalFile(cfg.PublicFolder.Path, false)))
+		e.Use(NewPublicFolderHandlerFS(fsys, *cfg.PublicFolder))
+
+		if cfg.PublicFolder.SRI || len(fingerprints) > 0 {
+			if h, err := newAssetsMiddleware(*cfg.PublicFolder, fingerprints, devel); err == nil {
+				e.Use(h)
+			} else {
+				log.Println("hashing the public folder:", err.Error())
+			}
+		}
 	}
 
-	if cfg.Robots {
+	if cfg.RobotsTXT != nil {
+		log.Println("registering the generated robots file")
+		h := NewRobotsHandler(*cfg.RobotsTXT)
+		e.GET("/robots.txt", h.HandlerFunc())
+	} else if cfg.Robots {
 		log.Println("registering the robots file")
-		e.StaticFile("/robots.txt", "./static/robots.txt")
+		staticFile("/robots.txt", "./static/robots.txt")
 	}
 
-	if cfg.Sitemap {
+	if cfg.SitemapXML != nil {
+		log.Println("registering the generated sitemap file")
+		s := NewSitemapGenerator(*cfg.SitemapXML, cfg.Pages)
+		if err := s.Generate(); err != nil {
+			log.Println("generating the sitemap:", err.Error())
+		}
+		engine.onStop(s.Run())
+		e.GET("/sitemap.xml", s.HandlerFunc())
+	} else if cfg.Sitemap {
 		log.Println("registering the sitemap file")
-		e.StaticFile("/sitemap.xml", "./static/sitemap.xml")
+		staticFile("/sitemap.xml", "./static/sitemap.xml")
 	}
 
 	for _, fileName := range cfg.StaticTXTContent {
 		log.Println("registering the static", fileName)
-		e.StaticFile(fmt.Sprintf("/%s", fileName), fmt.Sprintf("./static/%s", fileName))
+		staticFile(fmt.Sprintf("/%s", fileName), fmt.Sprintf("./static/%s", fileName))
+	}
+
+	e.Use(ResponseErrorMessageHandler())
+	for _, code := range ErrorStatusCodes {
+		if h, err := ef.ErrorHandlerFactory(fmt.Sprintf("./static/%d", code), code); err == nil {
+			log.Println("registering the", code, "template")
+			e.Use(errorHandlerFunc(cfg, h))
+		}
 	}
 
 	if h, err := ef.ErrorHandlerFactory("./static/500", http.StatusInternalServerError); err == nil {
-		e.Use(h.HandlerFunc())
+		e.Use(errorHandlerFunc(cfg, h))
 	} else {
 		log.Println("using the default 500 template")
-		e.Use(Default500StaticHandler.HandlerFunc())
+		e.Use(errorHandlerFunc(cfg, Default500StaticHandler))
 	}
 
 }
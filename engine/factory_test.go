@@ -2,6 +2,7 @@ package engine
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -83,6 +84,56 @@ func TestFactory_New_ok(t *testing.T) {
 	assertResponse(t, e, "/b", http.StatusNotFound, default404Tmpl)
 }
 
+func TestFactory_New_middlewaresAndRoutes(t *testing.T) {
+	if err := ioutil.WriteFile("test_tmpl", []byte("hi, {{Extra.name}}!"), 0644); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+	defer os.Remove("test_tmpl")
+
+	ef := DefaultFactory
+	ef.Parser = func(_ string) (Config, error) {
+		return Config{
+			Pages: []Page{
+				{
+					URLPattern: "/a",
+					Template:   "a",
+					Extra:      map[string]interface{}{"name": "stranger"},
+				},
+			},
+			Templates: map[string]string{"a": "test_tmpl"},
+		}, nil
+	}
+	ef = ef.Use(func(c *gin.Context) {
+		c.Header("X-Embedder", "yes")
+	}).Handle(func(e *gin.Engine) {
+		e.GET("/custom", func(c *gin.Context) { c.String(http.StatusOK, "custom route") })
+	})
+
+	if len(DefaultFactory.Middlewares) != 0 || len(DefaultFactory.Routes) != 0 {
+		t.Error("the default factory should not be modified")
+	}
+
+	e, err := ef.New("something", true)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	assertResponse(t, e, "/a", http.StatusOK, "hi, stranger!")
+	assertResponse(t, e, "/custom", http.StatusOK, "custom route")
+
+	for _, path := range []string{"/a", "/custom"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		e.ServeHTTP(w, req)
+		if h := w.Header().Get("X-Embedder"); h != "yes" {
+			t.Errorf("[%s] unexpected header value: %s", path, h)
+		}
+	}
+}
+
 func TestFactory_New_reloadTemplate(t *testing.T) {
 	if err := ioutil.WriteFile("test_tmpl", []byte("hi, {{Extra.name}}!"), 0644); err != nil {
 		t.Errorf("unexpected error: %s", err.Error())
@@ -152,6 +203,31 @@ func TestFactory_New_reloadTemplate(t *testing.T) {
 	time.Sleep(200 * time.Millisecond)
 	assertResponse(t, e, "/a", http.StatusOK, "Hi stranger, I'm updated.")
 
+	// Roll back to the initial version
+	resp = httptest.NewRecorder()
+	e.ServeHTTP(resp, httptest.NewRequest("GET", "/template/a/versions", nil))
+	var versions []TemplateVersion
+	if err := json.Unmarshal(resp.Body.Bytes(), &versions); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if len(versions) != 2 || versions[0].Source != InitialTemplateVersion || !versions[1].Current {
+		t.Errorf("unexpected versions: %v", versions)
+	}
+
+	for url, status := range map[string]int{
+		"/template/a/versions/9/rollback": http.StatusNotFound,
+		"/template/a/versions/x/rollback": http.StatusBadRequest,
+		"/template/a/versions/1/rollback": http.StatusOK,
+	} {
+		resp = httptest.NewRecorder()
+		e.ServeHTTP(resp, httptest.NewRequest("POST", url, nil))
+		if resp.Code != status {
+			t.Errorf("[%s] unexpected status code: %d", url, resp.Code)
+		}
+	}
+	time.Sleep(200 * time.Millisecond)
+	assertResponse(t, e, "/a", http.StatusOK, "hi, stranger!")
 }
 
 func putTemplateForm(url, tmpl string) (*http.Request, error) {
@@ -174,3 +250,533 @@ func putTemplateForm(url, tmpl string) (*http.Request, error) {
 	}
 	return req, err
 }
+
+func TestFactory_New_decoders(t *testing.T) {
+	if err := ioutil.WriteFile("test_tmpl", []byte("hi, {{Data.name}} and {{Data.users.name}}!"), 0644); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+	defer os.Remove("test_tmpl")
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("name=stranger"))
+	}))
+	defer backend.Close()
+
+	page := Page{
+		URLPattern:        "/a",
+		BackendURLPattern: backend.URL,
+		Template:          "a",
+		Encoding:          "kv",
+		Backends:          map[string]PageBackend{"users": {URLPattern: backend.URL, Encoding: "kv"}},
+	}
+	ef := DefaultFactory
+	ef.Parser = func(_ string) (Config, error) {
+		return Config{Pages: []Page{page}, Templates: map[string]string{"a": "test_tmpl"}}, nil
+	}
+
+	if _, err := ef.New("something", true); err == nil {
+		t.Error("expecting an error with an unknown encoding")
+	}
+
+	ef = ef.WithDecoder("kv", func(r io.Reader, c *ResponseContext) error {
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		parts := bytes.SplitN(data, []byte("="), 2)
+		c.Data = map[string]interface{}{string(parts[0]): string(parts[1])}
+		return nil
+	})
+	if len(DefaultFactory.Decoders) != 0 {
+		t.Error("the default factory should not be modified")
+	}
+
+	e, err := ef.New("something", true)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	assertResponse(t, e, "/a", http.StatusOK, "hi, stranger and stranger!")
+}
+
+func TestFactory_New_hosts(t *testing.T) {
+	for name, content := range map[string]string{"test_home": "home", "test_shop": "shop {{Data.name}}"} {
+		if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Errorf("unexpected error: %s", err.Error())
+		}
+		defer os.Remove(name)
+	}
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(`{"name": "stranger"}`))
+	}))
+	defer backend.Close()
+
+	cfg := Config{
+		Pages: []Page{
+			{Name: "home", URLPattern: "/", BackendURLPattern: backend.URL, Template: "home"},
+			{Name: "shop", URLPattern: "/", BackendURLPattern: backend.URL, Template: "shop", Host: "shop.example.com"},
+			{Name: "cart", URLPattern: "/cart", BackendURLPattern: backend.URL, Template: "shop", Host: "shop.example.com"},
+		},
+		Templates: map[string]string{"home": "test_home", "shop": "test_shop"},
+	}
+	ef := DefaultFactory
+	ef.Parser = func(_ string) (Config, error) { return cfg, nil }
+
+	e, err := ef.New("something", false)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+
+	time.Sleep(300 * time.Millisecond)
+
+	assertResponse(t, e, "http://www.example.com/", http.StatusOK, "home")
+	assertResponse(t, e, "http://shop.example.com/", http.StatusOK, "shop stranger")
+	assertResponse(t, e, "http://shop.example.com/cart", http.StatusOK, "shop stranger")
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "http://www.example.com/cart", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+
+	cfg.Pages[1].Host = ""
+	if _, err := ef.New("something", false); err == nil {
+		t.Error("expecting an error with a duplicated URL pattern")
+	}
+}
+
+func TestFactory_New_routePatterns(t *testing.T) {
+	if err := ioutil.WriteFile("test_post", []byte("post {{Data.path}}"), 0644); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+	defer os.Remove("test_post")
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"path": %q}`, r.URL.Path)
+	}))
+	defer backend.Close()
+
+	ef := DefaultFactory
+	ef.Parser = func(_ string) (Config, error) {
+		return Config{
+			Pages: []Page{{
+				URLPattern:        `/blog/{slug:(?P<year>\d{4})-[a-z0-9-]+}`,
+				BackendURLPattern: backend.URL + "/posts/:year/:slug",
+				Template:          "post",
+			}},
+			Templates: map[string]string{"post": "test_post"},
+		}, nil
+	}
+	e, err := ef.New("something", false)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	assertResponse(t, e, "/blog/2024-hello-world", http.StatusOK, "post /posts/2024/2024-hello-world")
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/blog/Hello_World", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+
+	ef.Parser = func(_ string) (Config, error) {
+		return Config{Pages: []Page{{URLPattern: "/blog/{slug:[a-z}"}}}, nil
+	}
+	if _, err := ef.New("something", false); err == nil {
+		t.Error("expecting an error with an invalid URL pattern")
+	}
+}
+
+func TestFactory_New_canonical(t *testing.T) {
+	if err := ioutil.WriteFile("test_canonical", []byte(`<link rel="canonical" href="{{Extra.canonical_url}}">`), 0644); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+	defer os.Remove("test_canonical")
+
+	cfg := Config{
+		Canonical: &CanonicalURL{TrailingSlash: TrailingSlashAdd, BaseURL: "https://www.example.com"},
+		Pages: []Page{
+			{Name: "about", URLPattern: "/about", Template: "canonical"},
+			{Name: "legacy", URLPattern: "/legacy", Template: "canonical", Canonical: &CanonicalURL{Disabled: true}},
+		},
+		Templates: map[string]string{"canonical": "test_canonical"},
+	}
+	ef := DefaultFactory
+	ef.Parser = func(_ string) (Config, error) { return cfg, nil }
+	e, err := ef.New("something", false)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+
+	time.Sleep(300 * time.Millisecond)
+
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/about", nil))
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/about/" {
+		t.Errorf("unexpected response: %d %s", w.Code, w.Header().Get("Location"))
+	}
+	assertResponse(t, e, "/about/", http.StatusOK, `<link rel="canonical" href="https://www.example.com/about/">`)
+	assertResponse(t, e, "/legacy", http.StatusOK, `<link rel="canonical" href="">`)
+
+	cfg.Canonical.TrailingSlash = "remove"
+	if _, err := ef.New("something", false); err == nil {
+		t.Error("expecting an error with an unknown trailing slash policy")
+	}
+}
+
+func TestFactory_New_urlRules(t *testing.T) {
+	if err := ioutil.WriteFile("test_url_rules", []byte(`article`), 0644); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+	defer os.Remove("test_url_rules")
+
+	cfg := Config{
+		Redirects: []URLRedirect{{Source: "/blog/:slug", Target: "/articles/:slug", PreserveQuery: true}},
+		Rewrites:  []URLRewrite{{Source: "/posts/:slug", Target: "/articles/:slug"}},
+		Pages: []Page{
+			{Name: "article", URLPattern: "/articles/:slug", Template: "article"},
+		},
+		Templates: map[string]string{"article": "test_url_rules"},
+	}
+	ef := DefaultFactory
+	ef.Parser = func(_ string) (Config, error) { return cfg, nil }
+	e, err := ef.New("something", false)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+
+	time.Sleep(300 * time.Millisecond)
+
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/blog/hello?page=2", nil))
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/articles/hello?page=2" {
+		t.Errorf("unexpected response: %d %s", w.Code, w.Header().Get("Location"))
+	}
+	assertResponse(t, e, "/posts/hello", http.StatusOK, "article")
+
+	cfg.Redirects[0].Status = http.StatusOK
+	if _, err := ef.New("something", false); err == nil {
+		t.Error("expecting an error with an invalid redirect status")
+	}
+}
+
+func TestFactory_New_trustedProxies(t *testing.T) {
+	if err := ioutil.WriteFile("test_trusted_proxies", []byte(`office`), 0644); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+	defer os.Remove("test_trusted_proxies")
+
+	cfg := Config{
+		Middlewares: map[string]PageMiddleware{"office": {Type: IPAllowlistMiddleware, Allow: []string{"10.0.0.0/8"}}},
+		Pages: []Page{
+			{Name: "office", URLPattern: "/office", Template: "office", Middlewares: []string{"office"}},
+		},
+		Templates: map[string]string{"office": "test_trusted_proxies"},
+	}
+	ef := DefaultFactory
+	ef.Parser = func(_ string) (Config, error) { return cfg, nil }
+
+	for _, tc := range []struct {
+		proxies     []string
+		remote, xff string
+		status      int
+	}{
+		{nil, "192.0.2.1", "10.1.2.3", http.StatusForbidden},
+		{nil, "10.1.2.3", "", http.StatusOK},
+		{nil, "10.1.2.3", "192.0.2.1", http.StatusOK},
+		{[]string{"192.0.2.0/24"}, "192.0.2.1", "10.1.2.3", http.StatusOK},
+		{[]string{"192.0.2.0/24"}, "198.51.100.1", "10.1.2.3", http.StatusForbidden},
+	} {
+		cfg.TrustedProxies = tc.proxies
+		e, err := ef.New("something", false)
+		if err != nil {
+			t.Errorf("unexpected error: %s", err.Error())
+			return
+		}
+		req := httptest.NewRequest("GET", "/office", nil)
+		req.RemoteAddr = tc.remote + ":1234"
+		if tc.xff != "" {
+			req.Header.Set("X-Forwarded-For", tc.xff)
+		}
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		if w.Code != tc.status {
+			t.Errorf("%v %s %s: unexpected status code: %d", tc.proxies, tc.remote, tc.xff, w.Code)
+		}
+	}
+
+	cfg.TrustedProxies = []string{"not a proxy"}
+	if _, err := ef.New("something", false); err == nil {
+		t.Error("expecting an error with an invalid trusted proxy")
+	}
+}
+
+func TestFactory_New_protect(t *testing.T) {
+	if err := ioutil.WriteFile("test_protect", []byte(`admin`), 0644); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+	defer os.Remove("test_protect")
+
+	cfg := Config{
+		Middlewares: map[string]PageMiddleware{"office": {Type: IPAllowlistMiddleware, Allow: []string{"10.0.0.0/8"}}},
+		Protect:     []ProtectedPath{{Prefix: "/admin", Middlewares: []string{"office"}}},
+		Pages: []Page{
+			{Name: "admin", URLPattern: "/admin/users", Template: "admin"},
+		},
+		Templates: map[string]string{"admin": "test_protect"},
+	}
+	ef := DefaultFactory
+	ef.Parser = func(_ string) (Config, error) { return cfg, nil }
+	e, err := ef.New("something", false)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+
+	for _, tc := range []struct {
+		remote, xff string
+		status      int
+	}{
+		{"10.1.2.3", "", http.StatusOK},
+		{"192.0.2.1", "", http.StatusForbidden},
+		{"192.0.2.1", "10.1.2.3", http.StatusForbidden},
+	} {
+		req := httptest.NewRequest("GET", "/admin/users", nil)
+		req.RemoteAddr = tc.remote + ":1234"
+		if tc.xff != "" {
+			req.Header.Set("X-Forwarded-For", tc.xff)
+		}
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		if w.Code != tc.status {
+			t.Errorf("%s %s: unexpected status code: %d", tc.remote, tc.xff, w.Code)
+		}
+	}
+
+	cfg.Protect[0].Middlewares = []string{"unknown"}
+	if _, err := ef.New("something", false); err == nil {
+		t.Error("expecting an error with an unknown middleware")
+	}
+}
+
+func TestFactory_New_rateLimitSpoofedIPs(t *testing.T) {
+	cfg := Config{RateLimit: &RateLimit{Rate: 0.001, Burst: 1}}
+	ef := DefaultFactory
+	ef.Parser = func(_ string) (Config, error) { return cfg, nil }
+	e, err := ef.New("something", false)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+
+	// the client rotates the forwarded IPs, but it is not a trusted proxy
+	for i, status := range []int{http.StatusNotFound, http.StatusTooManyRequests, http.StatusTooManyRequests} {
+		req := httptest.NewRequest("GET", "/unknown", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.Header.Set("X-Forwarded-For", fmt.Sprintf("10.0.0.%d", i))
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		if w.Code != status {
+			t.Errorf("#%d: unexpected status code: %d", i, w.Code)
+		}
+	}
+}
+
+func TestFactory_New_trapSpoofedIPs(t *testing.T) {
+	cfg := Config{TrapRoutes: &TrapRoutes{Paths: []string{"/wp-login.php"}, BlockDuration: "1h"}}
+	ef := DefaultFactory
+	ef.Parser = func(_ string) (Config, error) { return cfg, nil }
+	e, err := ef.New("something", false)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+
+	request := func(ip, xff, url string) int {
+		req := httptest.NewRequest("GET", url, nil)
+		req.RemoteAddr = ip + ":1234"
+		if xff != "" {
+			req.Header.Set("X-Forwarded-For", xff)
+		}
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// the attacker names the victim in the forwarded header, but it is not a trusted proxy
+	request("192.0.2.1", "10.0.0.1", "/wp-login.php")
+	if status := request("10.0.0.1", "", "/unknown"); status == http.StatusForbidden {
+		t.Error("the victim was blocked")
+	}
+	if status := request("192.0.2.1", "", "/unknown"); status != http.StatusForbidden {
+		t.Errorf("unexpected status code for the attacker: %d", status)
+	}
+}
+
+func TestFactory_New_personalizedStaleResponses(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session, _ := r.Cookie("session")
+		fmt.Fprintf(w, `{"user": %q}`, session.Value)
+	}))
+	defer ts.Close()
+
+	if err := ioutil.WriteFile("test_personalized_stale", []byte(`hello {{Data.user}}`), 0644); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+	defer os.Remove("test_personalized_stale")
+
+	cfg := Config{
+		Middlewares: map[string]PageMiddleware{
+			"members": {Type: JWTMiddleware, JWT: &JWTAuth{Secret: "secret"}},
+			"admin":   {Type: BasicAuthMiddleware, Users: map[string]string{"admin": "secret"}},
+		},
+		Pages: []Page{
+			{
+				Name:              "account",
+				URLPattern:        "/account",
+				BackendURLPattern: ts.URL,
+				Template:          "account",
+				CacheTTL:          "1ms",
+				StaleTTL:          "1h",
+				Forward:           &ForwardRequest{Cookies: []string{"session"}},
+			},
+		},
+		Templates: map[string]string{"account": "test_personalized_stale"},
+	}
+	ef := DefaultFactory
+	ef.Parser = func(_ string) (Config, error) { return cfg, nil }
+	if _, err := ef.New("something", false); err == nil {
+		t.Error("expecting an error with a stale page forwarding credentials")
+	}
+
+	cfg.Pages[0].Forward = nil
+	cfg.Pages[0].Middlewares = []string{"members"}
+	if _, err := ef.New("something", false); err == nil {
+		t.Error("expecting an error with a stale page protected by a jwt middleware")
+	}
+
+	cfg.Pages[0].Middlewares = []string{"admin"}
+	if _, err := ef.New("something", false); err == nil {
+		t.Error("expecting an error with a stale page protected by a basic auth middleware")
+	}
+
+	cfg.Pages[0].Middlewares = nil
+	cfg.Protect = []ProtectedPath{{Prefix: "/", Middlewares: []string{"members"}}}
+	if _, err := ef.New("something", false); err == nil {
+		t.Error("expecting an error with a stale page under a path protected by a jwt middleware")
+	}
+
+	// without the stale responses, every user gets its own page
+	cfg.Protect = nil
+	cfg.Pages[0].StaleTTL = ""
+	cfg.Pages[0].Forward = &ForwardRequest{Cookies: []string{"session"}}
+	e, err := ef.New("something", false)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	time.Sleep(300 * time.Millisecond)
+
+	for _, user := range []string{"alice", "bob", "alice"} {
+		req := httptest.NewRequest("GET", "/account", nil)
+		req.AddCookie(&http.Cookie{Name: "session", Value: user})
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		time.Sleep(5 * time.Millisecond)
+		if body := w.Body.String(); body != "hello "+user {
+			t.Errorf("%s: unexpected body: %s", user, body)
+		}
+	}
+}
+
+func TestFactory_New_authenticatedPageCache(t *testing.T) {
+	if err := ioutil.WriteFile("test_authenticated_page_cache", []byte(`hello {{Extra.user.sub}}`), 0644); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+	defer os.Remove("test_authenticated_page_cache")
+
+	for _, tc := range []struct {
+		name        string
+		middlewares []string
+		protect     []ProtectedPath
+		ok          bool
+	}{
+		{"public", nil, nil, true},
+		{"jwt", []string{"members"}, nil, false},
+		{"basic auth", []string{"admin"}, nil, false},
+		{"protected path", nil, []ProtectedPath{{Prefix: "/account", Middlewares: []string{"members"}}}, false},
+		{"other protected path", nil, []ProtectedPath{{Prefix: "/admin", Middlewares: []string{"admin"}}}, true},
+		{"headers", []string{"nosniff"}, nil, true},
+	} {
+		cfg := Config{
+			PageCache: &PageCache{},
+			Middlewares: map[string]PageMiddleware{
+				"members": {Type: JWTMiddleware, JWT: &JWTAuth{Secret: "secret"}},
+				"admin":   {Type: BasicAuthMiddleware, Users: map[string]string{"admin": "secret"}},
+				"nosniff": {Type: HeadersMiddleware, Headers: map[string]string{"X-Content-Type-Options": "nosniff"}},
+			},
+			Protect: tc.protect,
+			Pages: []Page{
+				{Name: "account", URLPattern: "/account", Template: "account", PageCache: true, Middlewares: tc.middlewares},
+			},
+			Templates: map[string]string{"account": "test_authenticated_page_cache"},
+		}
+		ef := DefaultFactory
+		ef.Parser = func(_ string) (Config, error) { return cfg, nil }
+		if _, err := ef.New("something", false); (err == nil) != tc.ok {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+	}
+}
+
+func TestFactory_New_authenticatedCacheControl(t *testing.T) {
+	if err := ioutil.WriteFile("test_authenticated_cache_control", []byte(`hello`), 0644); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+	defer os.Remove("test_authenticated_cache_control")
+
+	cfg := Config{
+		Middlewares: map[string]PageMiddleware{
+			"admin": {Type: BasicAuthMiddleware, Users: map[string]string{"admin": "secret"}},
+		},
+		Protect: []ProtectedPath{{Prefix: "/admin", Middlewares: []string{"admin"}}},
+		Pages: []Page{
+			{Name: "home", URLPattern: "/", Template: "hello", CacheTTL: "60s"},
+			{Name: "account", URLPattern: "/account", Template: "hello", CacheTTL: "60s", Middlewares: []string{"admin"}},
+			{Name: "dashboard", URLPattern: "/admin/dashboard", Template: "hello", CacheTTL: "60s"},
+		},
+		Templates: map[string]string{"hello": "test_authenticated_cache_control"},
+	}
+	ef := DefaultFactory
+	ef.Parser = func(_ string) (Config, error) { return cfg, nil }
+	e, err := ef.New("something", false)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+
+	for _, tc := range []struct {
+		url, cacheControl string
+	}{
+		{"/", "public, max-age=60"},
+		{"/account", "private, max-age=60"},
+		{"/admin/dashboard", "private, max-age=60"},
+	} {
+		req, _ := http.NewRequest("GET", tc.url, nil)
+		req.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		if w.Code != http.StatusOK || w.Header().Get("Cache-Control") != tc.cacheControl {
+			t.Errorf("%s: unexpected response: %d %s", tc.url, w.Code, w.Header().Get("Cache-Control"))
+		}
+	}
+}
@@ -0,0 +1,362 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultHealthCheckInterval = 10 * time.Second
+	latencyDecay               = 0.3
+
+	// PriorityPolicy sends the requests to the first healthy target
+	PriorityPolicy = "priority"
+	// RoundRobinPolicy spreads the requests across the healthy targets, according to their weights
+	RoundRobinPolicy = "round_robin"
+	// LeastLatencyPolicy sends the requests to the healthy target with the lowest latency
+	LeastLatencyPolicy = "least_latency"
+)
+
+// ErrNoBackendTargets is the error returned when a failover backend has no targets
+var ErrNoBackendTargets = fmt.Errorf("no backend targets available")
+
+// NewFailoverBackend creates a Backend sending the requests to the target of the pool selected by
+// its policy and failing over to the next ones on errors or 5xx responses
+func NewFailoverBackend(client *http.Client, URLPattern string, pool *TargetPool) Backend {
+	_, path := splitBaseURL(URLPattern)
+	pathPattern := []byte(path)
+	return func(params map[string]string, headers map[string]string, c *gin.Context) (*http.Response, error) {
+		path := string(replaceParams(pathPattern, params))
+		candidates := pool.candidates()
+		if len(candidates) == 0 {
+			return nil, ErrNoBackendTargets
+		}
+		var resp *http.Response
+		var err error
+		for _, target := range candidates {
+			if resp != nil {
+				resp.Body.Close()
+			}
+
+			var req *http.Request
+			req, err = http.NewRequestWithContext(requestContext(c), "GET", target.BaseURL+path, nil)
+			if err != nil {
+				return nil, err
+			}
+			for k, v := range headers {
+				req.Header.Add(k, v)
+			}
+			done := HooksFromContext(c).OnBackendCall(c, req)
+			start := time.Now()
+			resp, err = client.Do(req)
+			done(resp, err)
+
+			if err == nil && resp.StatusCode < http.StatusInternalServerError {
+				target.success(time.Since(start))
+				return resp, nil
+			}
+			if req.Context().Err() != nil {
+				// the cancelled requests are not failures of the target
+				return resp, err
+			}
+			pool.failure(target)
+		}
+		return resp, err
+	}
+}
+
+// NewTargetPool creates a TargetPool with the hosts and the health check policy of the received
+// config
+func NewTargetPool(cfg BackendFailover) *TargetPool {
+	interval, err := time.ParseDuration(cfg.HealthCheckInterval)
+	if err != nil || interval <= 0 {
+		interval = defaultHealthCheckInterval
+	}
+	threshold := cfg.FailureThreshold
+	if threshold < 1 {
+		threshold = 1
+	}
+	policy := cfg.Policy
+	if policy == "" {
+		policy = PriorityPolicy
+	}
+	p := &TargetPool{
+		policy:          policy,
+		weights:         cfg.Weights,
+		threshold:       threshold,
+		interval:        interval,
+		healthCheckPath: cfg.HealthCheckPath,
+	}
+	hosts := make([]DiscoveredHost, len(cfg.Hosts))
+	for i, host := range cfg.Hosts {
+		hosts[i] = DiscoveredHost{URL: host}
+	}
+	p.Update(hosts)
+	return p
+}
+
+// newTargetPools creates an empty targetPools
+func newTargetPools() *targetPools {
+	return &targetPools{pools: map[string]*TargetPool{}, stop: make(chan struct{})}
+}
+
+// targetPools keeps the pools of an engine, one per distinct failover config, so the pages and
+// the variants sharing their targets share a single watcher. The watchers run until it is closed
+type targetPools struct {
+	pools map[string]*TargetPool
+	mutex sync.Mutex
+	stop  chan struct{}
+	once  sync.Once
+}
+
+// get returns the pool of the received config, creating it and starting its health checks and
+// its discovery the first time. Without a targetPools, the pool is not watched, so its unhealthy
+// targets are only retried when none of them is healthy
+func (tp *targetPools) get(name string, cfg BackendFailover) *TargetPool {
+	if tp == nil {
+		return NewTargetPool(cfg)
+	}
+	key, _ := json.Marshal(cfg)
+	tp.mutex.Lock()
+	defer tp.mutex.Unlock()
+	if pool, ok := tp.pools[string(key)]; ok {
+		return pool
+	}
+
+	pool := NewTargetPool(cfg)
+	tp.pools[string(key)] = pool
+	// the health checks must not outlast the interval between them
+	go pool.Watch(&http.Client{Timeout: pool.interval}, tp.stop)
+	if cfg.Discovery != nil {
+		if d, err := NewDiscoverer(*cfg.Discovery); err != nil {
+			log.Println(name, err.Error())
+		} else {
+			go pool.Discover(d, tp.stop)
+		}
+	}
+	return pool
+}
+
+// Close stops the health checks and the discovery of the pools
+func (tp *targetPools) Close() error {
+	tp.once.Do(func() { close(tp.stop) })
+	return nil
+}
+
+// TargetPool tracks the health of the targets of a failover backend
+type TargetPool struct {
+	targets         []*Target
+	policy          string
+	weights         map[string]int
+	mutex           sync.Mutex
+	threshold       int
+	interval        time.Duration
+	healthCheckPath string
+}
+
+// Watch checks the unhealthy targets at every interval, marking them as healthy once they
+// recover. It blocks until the received channel is closed
+func (p *TargetPool) Watch(client *http.Client, stop <-chan struct{}) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			p.check(client)
+		}
+	}
+}
+
+// Targets returns the current targets of the pool, in priority order
+func (p *TargetPool) Targets() []*Target {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return append([]*Target{}, p.targets...)
+}
+
+// Update replaces the targets of the pool, keeping the state of the ones already known
+func (p *TargetPool) Update(hosts []DiscoveredHost) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	known := map[string]*Target{}
+	for _, target := range p.targets {
+		known[target.BaseURL] = target
+	}
+	targets := make([]*Target, 0, len(hosts))
+	for _, host := range hosts {
+		baseURL := strings.TrimSuffix(host.URL, "/")
+		weight, ok := p.weights[host.URL]
+		if !ok {
+			weight = host.Weight
+		}
+		if weight < 1 {
+			weight = 1
+		}
+		target, ok := known[baseURL]
+		if !ok {
+			target = &Target{BaseURL: baseURL, healthy: true}
+		}
+		target.Weight = weight
+		targets = append(targets, target)
+	}
+	p.targets = targets
+}
+
+func (p *TargetPool) check(client *http.Client) {
+	for _, target := range p.Targets() {
+		if target.Healthy() {
+			continue
+		}
+		if p.healthCheckPath == "" {
+			if time.Since(target.lastFailure()) >= p.interval {
+				target.recover()
+			}
+			continue
+		}
+		resp, err := client.Get(target.BaseURL + p.healthCheckPath)
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode < http.StatusBadRequest {
+			target.recover()
+		}
+	}
+}
+
+// candidates returns the healthy targets, starting with the one selected by the policy and
+// followed by the rest in priority order, or all of them if none is healthy
+func (p *TargetPool) candidates() []*Target {
+	targets := p.Targets()
+	res := make([]*Target, 0, len(targets))
+	for _, target := range targets {
+		if target.Healthy() {
+			res = append(res, target)
+		}
+	}
+	if len(res) == 0 {
+		return targets
+	}
+
+	switch p.policy {
+	case RoundRobinPolicy:
+		p.moveFirst(res, p.nextWeighted(res))
+	case LeastLatencyPolicy:
+		// the targets without measures go first, so all of them get measured
+		sort.SliceStable(res, func(i, j int) bool { return res[i].Latency() < res[j].Latency() })
+	}
+	return res
+}
+
+// nextWeighted selects a target with the smooth weighted round robin algorithm
+func (p *TargetPool) nextWeighted(targets []*Target) int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	total, selected := 0, 0
+	for i, target := range targets {
+		target.current += target.Weight
+		total += target.Weight
+		if target.current > targets[selected].current {
+			selected = i
+		}
+	}
+	targets[selected].current -= total
+	return selected
+}
+
+func (p *TargetPool) moveFirst(targets []*Target, i int) {
+	selected := targets[i]
+	copy(targets[1:i+1], targets[:i])
+	targets[0] = selected
+}
+
+func (p *TargetPool) failure(target *Target) {
+	target.mutex.Lock()
+	defer target.mutex.Unlock()
+	target.failures++
+	target.failedAt = time.Now()
+	if target.healthy && target.failures >= p.threshold {
+		target.healthy = false
+		log.Println("backend target", target.BaseURL, "marked as unhealthy")
+	}
+}
+
+// Target is a base URL of a failover backend
+type Target struct {
+	BaseURL string
+	// Weight is the round robin weight of the target, guarded by the pool
+	Weight   int
+	mutex    sync.Mutex
+	healthy  bool
+	failures int
+	failedAt time.Time
+	latency  time.Duration
+	// current is the weight accumulated by the round robin, guarded by the pool
+	current int
+}
+
+// Healthy returns true if the target accepts requests
+func (t *Target) Healthy() bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.healthy
+}
+
+// Latency returns the moving average of the response times of the target, or zero if it has
+// not been measured yet
+func (t *Target) Latency() time.Duration {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.latency
+}
+
+func (t *Target) success(latency time.Duration) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.failures = 0
+	if t.latency == 0 {
+		t.latency = latency
+		return
+	}
+	t.latency = time.Duration(latencyDecay*float64(latency) + (1-latencyDecay)*float64(t.latency))
+}
+
+func (t *Target) recover() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if !t.healthy {
+		log.Println("backend target", t.BaseURL, "marked as healthy")
+	}
+	t.healthy = true
+	t.failures = 0
+}
+
+func (t *Target) lastFailure() time.Time {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.failedAt
+}
+
+// splitBaseURL splits the received URL pattern into its base URL (scheme and host) and the rest
+func splitBaseURL(URLPattern string) (string, string) {
+	start := strings.Index(URLPattern, "://")
+	if start < 0 {
+		return "", URLPattern
+	}
+	end := strings.IndexAny(URLPattern[start+3:], "/?")
+	if end < 0 {
+		return URLPattern, ""
+	}
+	return URLPattern[:start+3+end], URLPattern[start+3+end:]
+}
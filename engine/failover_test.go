@@ -0,0 +1,218 @@
+package engine
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTargetServer(status *int32, hits *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		w.WriteHeader(int(atomic.LoadInt32(status)))
+		fmt.Fprint(w, r.URL.RequestURI())
+	}))
+}
+
+func TestNewFailoverBackend(t *testing.T) {
+	primaryStatus, secondaryStatus := int32(http.StatusInternalServerError), int32(http.StatusOK)
+	var primaryHits, secondaryHits int32
+	primary := newTargetServer(&primaryStatus, &primaryHits)
+	defer primary.Close()
+	secondary := newTargetServer(&secondaryStatus, &secondaryHits)
+	defer secondary.Close()
+
+	pool := NewTargetPool(BackendFailover{Hosts: []string{primary.URL, secondary.URL + "/"}, HealthCheckPath: "/health"})
+	backend := NewFailoverBackend(http.DefaultClient, "http://api.example.com/test/:param?a=1", pool)
+	context, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+	for i := 0; i < 2; i++ {
+		resp, err := backend(params, headers, context)
+		if err != nil {
+			t.Errorf("unexpected error: %s", err.Error())
+			return
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("unexpected status code: %d", resp.StatusCode)
+		}
+		resp.Body.Close()
+	}
+	if p, s := atomic.LoadInt32(&primaryHits), atomic.LoadInt32(&secondaryHits); p != 1 || s != 2 {
+		t.Errorf("unexpected hits. primary: %d, secondary: %d", p, s)
+	}
+	if pool.Targets()[0].Healthy() || !pool.Targets()[1].Healthy() {
+		t.Error("unexpected health status")
+	}
+
+	pool.check(http.DefaultClient)
+	if pool.Targets()[0].Healthy() {
+		t.Error("the primary target should still be unhealthy")
+	}
+
+	atomic.StoreInt32(&primaryStatus, http.StatusOK)
+	pool.check(http.DefaultClient)
+	if !pool.Targets()[0].Healthy() {
+		t.Error("the primary target should be healthy")
+	}
+
+	resp, err := backend(params, headers, context)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	resp.Body.Close()
+	if p, s := atomic.LoadInt32(&primaryHits), atomic.LoadInt32(&secondaryHits); p != 4 || s != 2 {
+		t.Errorf("unexpected hits. primary: %d, secondary: %d", p, s)
+	}
+}
+
+func TestNewFailoverBackend_allTargetsDown(t *testing.T) {
+	status := int32(http.StatusBadGateway)
+	var primaryHits, secondaryHits int32
+	primary := newTargetServer(&status, &primaryHits)
+	defer primary.Close()
+	secondary := newTargetServer(&status, &secondaryHits)
+	defer secondary.Close()
+
+	pool := NewTargetPool(BackendFailover{Hosts: []string{primary.URL, secondary.URL}, FailureThreshold: 2})
+	backend := NewFailoverBackend(http.DefaultClient, "http://api.example.com/test/:param", pool)
+	context, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+	for i := 0; i < 3; i++ {
+		resp, err := backend(params, headers, context)
+		if err != nil {
+			t.Errorf("unexpected error: %s", err.Error())
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadGateway {
+			t.Errorf("unexpected status code: %d", resp.StatusCode)
+		}
+		if healthy := pool.Targets()[0].Healthy(); healthy != (i == 0) {
+			t.Errorf("#%d: unexpected health status of the primary target: %v", i, healthy)
+		}
+	}
+	if p, s := atomic.LoadInt32(&primaryHits), atomic.LoadInt32(&secondaryHits); p != 3 || s != 3 {
+		t.Errorf("unexpected hits. primary: %d, secondary: %d", p, s)
+	}
+}
+
+func TestTargetPool_check_cooldown(t *testing.T) {
+	pool := NewTargetPool(BackendFailover{Hosts: []string{"http://127.0.0.1:1"}, HealthCheckInterval: "10ms"})
+	pool.failure(pool.Targets()[0])
+	pool.check(http.DefaultClient)
+	if pool.Targets()[0].Healthy() {
+		t.Error("the target should be unhealthy")
+	}
+	time.Sleep(20 * time.Millisecond)
+	pool.check(http.DefaultClient)
+	if !pool.Targets()[0].Healthy() {
+		t.Error("the target should be healthy")
+	}
+}
+
+func TestTargetPools(t *testing.T) {
+	status := int32(http.StatusInternalServerError)
+	var hits int32
+	target := newTargetServer(&status, &hits)
+	defer target.Close()
+
+	pools := newTargetPools()
+	cfg := BackendFailover{Hosts: []string{target.URL}, HealthCheckPath: "/health", HealthCheckInterval: "10ms"}
+	pool := pools.get("a", cfg)
+	if other := pools.get("b", cfg); other != pool {
+		t.Error("the pages with the same failover config should share the pool")
+	}
+	if other := pools.get("c", BackendFailover{Hosts: []string{target.URL}}); other == pool {
+		t.Error("the pages with different failover configs should not share the pool")
+	}
+	pool.failure(pool.Targets()[0])
+
+	time.Sleep(100 * time.Millisecond)
+	if h := atomic.LoadInt32(&hits); h < 2 || h > 20 {
+		t.Errorf("unexpected health checks: %d", h)
+	}
+
+	pools.Close()
+	time.Sleep(20 * time.Millisecond)
+	checked := atomic.LoadInt32(&hits)
+	time.Sleep(100 * time.Millisecond)
+	if h := atomic.LoadInt32(&hits); h != checked {
+		t.Errorf("the health checks should stop once the pools are closed: %d", h-checked)
+	}
+}
+
+func TestSplitBaseURL(t *testing.T) {
+	for pattern, expected := range map[string][2]string{
+		"http://api.example.com/test/:param": {"http://api.example.com", "/test/:param"},
+		"https://api.example.com:8080?a=b":   {"https://api.example.com:8080", "?a=b"},
+		"https://api.example.com":            {"https://api.example.com", ""},
+		"/test/:param":                       {"", "/test/:param"},
+	} {
+		base, rest := splitBaseURL(pattern)
+		if base != expected[0] || rest != expected[1] {
+			t.Errorf("%s: unexpected result: %s, %s", pattern, base, rest)
+		}
+	}
+}
+
+func TestTargetPool_candidates_roundRobin(t *testing.T) {
+	pool := NewTargetPool(BackendFailover{
+		Hosts:   []string{"http://a", "http://b", "http://c"},
+		Policy:  RoundRobinPolicy,
+		Weights: map[string]int{"http://a": 3, "http://b": 2},
+	})
+
+	selected := ""
+	for i := 0; i < 6; i++ {
+		candidates := pool.candidates()
+		if len(candidates) != 3 {
+			t.Errorf("unexpected candidates: %v", candidates)
+			return
+		}
+		selected += candidates[0].BaseURL[7:]
+	}
+	if selected != "abacba" {
+		t.Errorf("unexpected selection: %s", selected)
+	}
+
+	pool.failure(pool.Targets()[0])
+	for i := 0; i < 3; i++ {
+		candidates := pool.candidates()
+		if len(candidates) != 2 || candidates[0].BaseURL == "http://a" || candidates[1].BaseURL == "http://a" {
+			t.Errorf("unexpected candidates: %v", candidates)
+		}
+	}
+}
+
+func TestTargetPool_candidates_leastLatency(t *testing.T) {
+	pool := NewTargetPool(BackendFailover{Hosts: []string{"http://a", "http://b", "http://c"}, Policy: LeastLatencyPolicy})
+	pool.Targets()[0].success(30 * time.Millisecond)
+	pool.Targets()[1].success(10 * time.Millisecond)
+
+	order := ""
+	for _, target := range pool.candidates() {
+		order += target.BaseURL[7:]
+	}
+	if order != "cba" {
+		t.Errorf("unexpected order: %s", order)
+	}
+
+	pool.Targets()[1].success(110 * time.Millisecond)
+	pool.Targets()[2].success(20 * time.Millisecond)
+	if latency := pool.Targets()[1].Latency(); latency != 40*time.Millisecond {
+		t.Errorf("unexpected latency: %s", latency)
+	}
+	order = ""
+	for _, target := range pool.candidates() {
+		order += target.BaseURL[7:]
+	}
+	if order != "cab" {
+		t.Errorf("unexpected order: %s", order)
+	}
+}
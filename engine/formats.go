@@ -0,0 +1,156 @@
+package engine
+
+import (
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// JSONFormat is the format returning the decoded backend data as JSON when it has no
+	// template
+	JSONFormat  = "json"
+	formatParam = "format"
+)
+
+// page returns a copy of the received page rendered with the template and the layout of the
+// format
+func (f PageFormat) page(p Page) Page {
+	p.Template = f.Template
+	if f.Layout != "" {
+		p.Layout = f.Layout
+	}
+	p.Formats = nil
+	p.GeoVariants = nil
+	p.Locales = nil
+	p.Stream = nil
+	return p
+}
+
+// mediaType returns the media type of the format with the received name
+func (f PageFormat) mediaType(name string) string {
+	if f.MediaType != "" {
+		return f.MediaType
+	}
+	if name == JSONFormat {
+		return "application/json"
+	}
+	return "text/html"
+}
+
+func formatPages(page Page) []Page {
+	res := []Page{}
+	for _, format := range page.Formats {
+		if format.Template != "" {
+			res = append(res, format.page(page))
+		}
+	}
+	return res
+}
+
+// formatsHandlerFunc dispatches the requests to the handler of the format selected by the format
+// query param or, if absent, by the Accept header, falling back to the default handler
+func formatsHandlerFunc(page Page, h *Handler, handler gin.HandlerFunc, newHandler func(HandlerConfig) *Handler) gin.HandlerFunc {
+	handlers := map[string]gin.HandlerFunc{}
+	mediaTypes := map[string]string{}
+	for name, format := range page.Formats {
+		mediaTypes[name] = format.mediaType(name)
+		if format.Template == "" {
+			if name == JSONFormat {
+				handlers[name] = jsonHandlerFunc(h)
+			}
+			continue
+		}
+		handlers[name] = newHandler(NewHandlerConfig(format.page(page))).HandlerFunc
+	}
+
+	return func(c *gin.Context) {
+		c.Writer.Header().Add("Vary", "Accept")
+		name := c.Query(formatParam)
+		if name == "" {
+			name = negotiateFormat(c.GetHeader("Accept"), mediaTypes)
+		}
+		if f, ok := handlers[name]; ok {
+			c.Header("Content-Type", mediaTypes[name])
+			f(c)
+			return
+		}
+		handler(c)
+	}
+}
+
+// jsonHandlerFunc returns a gin handler writing the data returned by the response generator of
+// the handler as JSON
+func jsonHandlerFunc(h *Handler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		HooksFromContext(c).OnRequest(c, h.Page.Name)
+		result, err := h.ResponseGenerator(c)
+		if err != nil {
+			status, headers := ErrorResponse(err)
+			for k, v := range h.Page.Headers {
+				c.Header(k, v)
+			}
+			setHeaders(c, headers)
+			c.AbortWithError(status, err)
+			return
+		}
+		setHeaders(c, h.ResponseHeaders())
+		if result.Array != nil {
+			c.JSON(http.StatusOK, result.Array)
+			return
+		}
+		c.JSON(http.StatusOK, result.Data)
+	}
+}
+
+// negotiateFormat returns the name of the format with the media type preferred by the Accept
+// header or an empty string if the HTML page is preferred or none is acceptable
+func negotiateFormat(accept string, mediaTypes map[string]string) string {
+	if accept == "" {
+		return ""
+	}
+	type accepted struct {
+		mediaType string
+		q         float64
+	}
+	ranges := []accepted{}
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		ranges = append(ranges, accepted{mediaType, q})
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+
+	for _, r := range ranges {
+		if r.q <= 0 {
+			continue
+		}
+		switch r.mediaType {
+		case "text/html", "application/xhtml+xml", "*/*", "text/*":
+			return ""
+		}
+		names := make([]string, 0, len(mediaTypes))
+		for name := range mediaTypes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if mediaTypes[name] == r.mediaType {
+				return name
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,91 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	mediaTypes := map[string]string{
+		"json": "application/json",
+		"feed": "application/rss+xml",
+	}
+	for accept, expected := range map[string]string{
+		"": "",
+		"text/html,application/xhtml+xml,*/*;q=0.8": "",
+		"application/json":                          "json",
+		"application/json;q=0.5, text/html":         "",
+		"text/html;q=0.1, application/rss+xml":      "feed",
+		"application/json;q=0, */*;q=0.1":           "",
+		"image/png":                                 "",
+	} {
+		if name := negotiateFormat(accept, mediaTypes); name != expected {
+			t.Errorf("%q: unexpected format: %q", accept, name)
+		}
+	}
+}
+
+func TestPageFormat_page(t *testing.T) {
+	p := Page{
+		Name:     "post",
+		Template: "post",
+		Layout:   "main",
+		Formats:  map[string]PageFormat{"amp": {Template: "post_amp", Layout: "amp"}},
+	}
+	res := p.Formats["amp"].page(p)
+	if res.Template != "post_amp" || res.Layout != "amp" || res.Formats != nil {
+		t.Errorf("unexpected page: %v", res)
+	}
+	if res = (PageFormat{Template: "post_amp"}).page(p); res.Layout != "main" {
+		t.Errorf("unexpected layout: %s", res.Layout)
+	}
+	if len(formatPages(p)) != 1 || len(formatPages(Page{Formats: map[string]PageFormat{"json": {}}})) != 0 {
+		t.Error("unexpected format pages")
+	}
+}
+
+func TestFormatsHandlerFunc(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	page := Page{
+		Name:     "post",
+		CacheTTL: "10s",
+		Formats:  map[string]PageFormat{"json": {}},
+	}
+	h := &Handler{
+		Page: page,
+		ResponseGenerator: func(c *gin.Context) (ResponseContext, error) {
+			return ResponseContext{Data: map[string]interface{}{"title": "hello"}}, nil
+		},
+		CacheControl: cacheControl(page.CacheTTL),
+	}
+	e := gin.New()
+	e.GET("/", formatsHandlerFunc(page, h, func(c *gin.Context) {
+		c.String(http.StatusOK, "html")
+	}, nil))
+
+	for i, tc := range []struct {
+		url, accept, body string
+	}{
+		{"/", "text/html", "html"},
+		{"/", "application/json", `{"title":"hello"}`},
+		{"/?format=json", "text/html", `{"title":"hello"}`},
+		{"/?format=unknown", "", "html"},
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", tc.url, nil)
+		req.Header.Set("Accept", tc.accept)
+		e.ServeHTTP(w, req)
+		if body := w.Body.String(); body != tc.body {
+			t.Errorf("#%d: unexpected body: %s", i, body)
+		}
+		if vary := w.Header().Get("Vary"); vary != "Accept" {
+			t.Errorf("#%d: unexpected Vary header: %s", i, vary)
+		}
+		if tc.body != "html" && w.Header().Get("Cache-Control") != "public, max-age=10" {
+			t.Errorf("#%d: unexpected Cache-Control header: %s", i, w.Header().Get("Cache-Control"))
+		}
+	}
+}
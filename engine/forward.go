@@ -0,0 +1,52 @@
+package engine
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// backendHeaders returns the headers of the inbound request forwarded to the backends of the
+// page: its Header and the headers and cookies whitelisted by its Forward section
+func backendHeaders(page Page, c *gin.Context) map[string]string {
+	headers := map[string]string{}
+	if h := c.Request.Header.Get(page.Header); h != "" {
+		headers[page.Header] = h
+	}
+	if page.Forward == nil {
+		return headers
+	}
+	for _, name := range page.Forward.Headers {
+		if h := c.Request.Header.Get(name); h != "" {
+			headers[http.CanonicalHeaderKey(name)] = h
+		}
+	}
+	cookies := make([]string, 0, len(page.Forward.Cookies))
+	for _, name := range page.Forward.Cookies {
+		if cookie, err := c.Request.Cookie(name); err == nil {
+			cookies = append(cookies, (&http.Cookie{Name: cookie.Name, Value: cookie.Value}).String())
+		}
+	}
+	if len(cookies) > 0 {
+		headers["Cookie"] = strings.Join(cookies, "; ")
+	}
+	return headers
+}
+
+// forwardsCredentials checks if the page forwards cookies or the Authorization header, so the
+// backend responses may be personalized
+func (f *ForwardRequest) forwardsCredentials() bool {
+	if f == nil {
+		return false
+	}
+	if len(f.Cookies) > 0 {
+		return true
+	}
+	for _, name := range f.Headers {
+		if strings.EqualFold(name, "Authorization") {
+			return true
+		}
+	}
+	return false
+}
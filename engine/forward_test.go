@@ -0,0 +1,75 @@
+package engine
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNewHandlerConfig_forward(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"auth":%q,"lang":%q,"cookie":%q,"other":%q}`,
+			r.Header.Get("Authorization"),
+			r.Header.Get("Accept-Language"),
+			r.Header.Get("Cookie"),
+			r.Header.Get("X-Other"),
+		)
+	}))
+	defer backend.Close()
+
+	cfg := NewHandlerConfig(Page{
+		Name:              "account",
+		BackendURLPattern: backend.URL,
+		CacheTTL:          "1m",
+		Forward: &ForwardRequest{
+			Headers: []string{"authorization", "Accept-Language"},
+			Cookies: []string{"session", "missing"},
+		},
+	})
+	if cfg.CacheControl != "private, max-age=60" {
+		t.Errorf("unexpected Cache-Control header: %s", cfg.CacheControl)
+	}
+
+	req, _ := http.NewRequest("GET", "/account", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("Accept-Language", "es")
+	req.Header.Set("X-Other", "ignored")
+	req.AddCookie(&http.Cookie{Name: "session", Value: "abc"})
+	req.AddCookie(&http.Cookie{Name: "tracking", Value: "ignored"})
+	c := &gin.Context{Request: req}
+
+	result, err := cfg.ResponseGenerator(c)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for k, expected := range map[string]string{
+		"auth":   "Bearer token",
+		"lang":   "es",
+		"cookie": "session=abc",
+		"other":  "",
+	} {
+		if v := result.Data[k]; v != expected {
+			t.Errorf("unexpected %s: %v", k, v)
+		}
+	}
+}
+
+func TestForwardRequest_forwardsCredentials(t *testing.T) {
+	for i, tc := range []struct {
+		forward  *ForwardRequest
+		expected bool
+	}{
+		{forward: nil},
+		{forward: &ForwardRequest{Headers: []string{"Accept-Language"}}},
+		{forward: &ForwardRequest{Headers: []string{"authorization"}}, expected: true},
+		{forward: &ForwardRequest{Cookies: []string{"session"}}, expected: true},
+	} {
+		if res := tc.forward.forwardsCredentials(); res != tc.expected {
+			t.Errorf("#%d: unexpected result: %v", i, res)
+		}
+	}
+}
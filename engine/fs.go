@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// EmbeddedFileSource gives precedence to the embedded files over the local ones
+	EmbeddedFileSource = "embedded"
+	// DiskFileSource gives precedence to the local files over the embedded ones
+	DiskFileSource = "disk"
+)
+
+// NewOverlayFS returns an fs.FS looking for the requested files in the received layers, in order,
+// so the first layers override the content of the next ones
+func NewOverlayFS(layers ...fs.FS) fs.FS {
+	return overlayFS(layers)
+}
+
+type overlayFS []fs.FS
+
+// Open implements the fs.FS interface
+func (o overlayFS) Open(name string) (fs.File, error) {
+	for _, layer := range o {
+		if f, err := layer.Open(name); err == nil {
+			return f, nil
+		}
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// openFile opens the file with the received path from the fs.FS or from the local filesystem
+// if no fs.FS is defined
+func openFile(fsys fs.FS, name string) (io.ReadCloser, error) {
+	if fsys == nil {
+		return os.Open(name)
+	}
+	return fsys.Open(fsPath(name))
+}
+
+// fsPath converts a path from the config into a valid fs.FS path
+func fsPath(name string) string {
+	clean := path.Clean(filepath.ToSlash(name))
+	return strings.TrimPrefix(clean, "/")
+}
+
+// fsPartialProvider implements the mustache.PartialProvider interface by looking for the partials
+// in an fs.FS, with the same extensions as the mustache.FileProvider
+type fsPartialProvider struct {
+	fsys fs.FS
+}
+
+// Get implements the mustache.PartialProvider interface
+func (p fsPartialProvider) Get(name string) (string, error) {
+	for _, ext := range []string{"", ".mustache", ".stache"} {
+		f, err := openFile(p.fsys, name+ext)
+		if err != nil {
+			continue
+		}
+		data, err := ioutil.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+	return "", nil
+}
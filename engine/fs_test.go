@@ -0,0 +1,162 @@
+package engine
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestNewOverlayFS(t *testing.T) {
+	fsys := NewOverlayFS(
+		fstest.MapFS{"a.txt": {Data: []byte("first")}},
+		fstest.MapFS{"a.txt": {Data: []byte("second")}, "b.txt": {Data: []byte("second")}},
+	)
+	for name, expected := range map[string]string{"a.txt": "first", "b.txt": "second"} {
+		f, err := fsys.Open(name)
+		if err != nil {
+			t.Errorf("[%s] unexpected error: %s", name, err.Error())
+			continue
+		}
+		data, _ := ioutil.ReadAll(f)
+		f.Close()
+		if string(data) != expected {
+			t.Errorf("[%s] unexpected content: %s", name, string(data))
+		}
+	}
+	if _, err := fsys.Open("c.txt"); err == nil {
+		t.Error("expecting error")
+	}
+}
+
+func Test_fsPath(t *testing.T) {
+	for in, out := range map[string]string{
+		"./tmpl/a.mustache":        "tmpl/a.mustache",
+		"tmpl/../tmpl/a.mustache":  "tmpl/a.mustache",
+		"/etc/api2html/a.mustache": "etc/api2html/a.mustache",
+	} {
+		if res := fsPath(in); res != out {
+			t.Errorf("unexpected path for %s: %s", in, res)
+		}
+	}
+}
+
+func Test_fsPartialProvider(t *testing.T) {
+	p := fsPartialProvider{fstest.MapFS{"partials/footer.mustache": {Data: []byte("footer")}}}
+	if data, err := p.Get("partials/footer"); err != nil || data != "footer" {
+		t.Errorf("unexpected result: %s, %v", data, err)
+	}
+	if data, err := p.Get("unknown"); err != nil || data != "" {
+		t.Errorf("unexpected result: %s, %v", data, err)
+	}
+}
+
+func TestFactory_New_templateFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"tmpl/a.mustache":          {Data: []byte("hi, {{Extra.name}}! {{> partials/footer}}")},
+		"tmpl/b.mustache":          {Data: []byte("-{{{content}}}-")},
+		"partials/footer.mustache": {Data: []byte("embedded footer")},
+	}
+	ef := DefaultFactory
+	ef.TemplateFS = fsys
+	ef.Parser = func(_ string) (Config, error) {
+		return Config{
+			Pages: []Page{
+				{
+					URLPattern: "/a",
+					Template:   "a",
+					Layout:     "b",
+					Extra:      map[string]interface{}{"name": "stranger"},
+				},
+			},
+			Templates: map[string]string{"a": "./tmpl/a.mustache"},
+			Layouts:   map[string]string{"b": "./tmpl/b.mustache"},
+		}, nil
+	}
+
+	e, err := ef.New("something", false)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	time.Sleep(200 * time.Millisecond)
+	assertResponse(t, e, "/a", http.StatusOK, "-hi, stranger! embedded footer-")
+
+	if err := os.MkdirAll("partials", 0777); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	defer os.RemoveAll("partials")
+	if err := ioutil.WriteFile("partials/footer.mustache", []byte("local footer"), 0644); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+
+	e, err = ef.New("something", true)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	time.Sleep(200 * time.Millisecond)
+	assertResponse(t, e, "/a", http.StatusOK, "-hi, stranger! local footer-")
+}
+
+func TestFactory_New_fileSource(t *testing.T) {
+	fsys := fstest.MapFS{
+		"tmpl/a.mustache":          {Data: []byte("{{> partials/footer}}")},
+		"partials/footer.mustache": {Data: []byte("embedded footer")},
+		"static/404":               {Data: []byte("embedded 404")},
+		"static/humans.txt":        {Data: []byte("embedded humans")},
+		"public/app.js":            {Data: []byte("embedded js")},
+	}
+	ef := DefaultFactory
+	ef.TemplateFS = fsys
+	fileSource := ""
+	ef.Parser = func(_ string) (Config, error) {
+		return Config{
+			Pages:            []Page{{URLPattern: "/a", Template: "a"}},
+			Templates:        map[string]string{"a": "./tmpl/a.mustache"},
+			PublicFolder:     &PublicFolder{Path: "./public", Prefix: "/js"},
+			StaticTXTContent: []string{"humans.txt"},
+			FileSource:       fileSource,
+		}, nil
+	}
+
+	e, err := ef.New("something", false)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	time.Sleep(200 * time.Millisecond)
+	assertResponse(t, e, "/a", http.StatusOK, "embedded footer")
+	assertResponse(t, e, "/js/app.js", http.StatusOK, "embedded js")
+	assertResponse(t, e, "/humans.txt", http.StatusOK, "embedded humans")
+	assertResponse(t, e, "/unknown", http.StatusNotFound, "embedded 404")
+
+	if err := os.MkdirAll("partials", 0777); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	defer os.RemoveAll("partials")
+	if err := ioutil.WriteFile("partials/footer.mustache", []byte("local footer"), 0644); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+
+	fileSource = DiskFileSource
+	e, err = ef.New("something", false)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	time.Sleep(200 * time.Millisecond)
+	assertResponse(t, e, "/a", http.StatusOK, "local footer")
+	assertResponse(t, e, "/js/app.js", http.StatusOK, "embedded js")
+
+	fileSource = "unknown"
+	if _, err = ef.New("something", false); err == nil {
+		t.Error("error expected")
+	}
+}
@@ -0,0 +1,135 @@
+package engine
+
+import (
+	"net"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	geoip2 "github.com/oschwald/geoip2-golang"
+)
+
+const geoKey = "api2html_geo"
+
+// GeoLocation contains the location of a client
+type GeoLocation struct {
+	// Country is the ISO 3166-1 code of the country
+	Country string
+	// Region is the ISO 3166-2 code of the main subdivision of the country, if known
+	Region string
+	// City is the english name of the city, if known
+	City string
+}
+
+// GeoLocator defines the interface for locating the clients by their IP
+type GeoLocator interface {
+	Locate(ip net.IP) (*GeoLocation, error)
+}
+
+// NewMaxMindLocator creates a MaxMindLocator using the database at the received path
+func NewMaxMindLocator(path string) (*MaxMindLocator, error) {
+	db, err := geoip2.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &MaxMindLocator{db: db, city: strings.Contains(db.Metadata().DatabaseType, "City")}, nil
+}
+
+// MaxMindLocator is a GeoLocator backed by a MaxMind GeoIP2 or GeoLite2 database
+type MaxMindLocator struct {
+	db   *geoip2.Reader
+	city bool
+}
+
+// Locate implements the GeoLocator interface
+func (m *MaxMindLocator) Locate(ip net.IP) (*GeoLocation, error) {
+	if !m.city {
+		r, err := m.db.Country(ip)
+		if err != nil {
+			return nil, err
+		}
+		return &GeoLocation{Country: r.Country.IsoCode}, nil
+	}
+	r, err := m.db.City(ip)
+	if err != nil {
+		return nil, err
+	}
+	loc := &GeoLocation{Country: r.Country.IsoCode, City: r.City.Names["en"]}
+	if len(r.Subdivisions) > 0 {
+		loc.Region = r.Subdivisions[0].IsoCode
+	}
+	return loc, nil
+}
+
+// Close closes the database
+func (m *MaxMindLocator) Close() error {
+	return m.db.Close()
+}
+
+// GeoIPMiddleware returns a gin middleware attaching the location of the client to the request,
+// so it is exposed to the templates and used for selecting the page variant
+func GeoIPMiddleware(l GeoLocator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if ip := net.ParseIP(c.ClientIP()); ip != nil {
+			if loc, err := l.Locate(ip); err == nil && loc.Country != "" {
+				c.Set(geoKey, loc)
+			}
+		}
+		c.Next()
+	}
+}
+
+// GeoFromContext returns the location attached to the gin context, if any
+func GeoFromContext(c *gin.Context) *GeoLocation {
+	if c == nil {
+		return nil
+	}
+	if v, ok := c.Get(geoKey); ok {
+		if loc, ok := v.(*GeoLocation); ok {
+			return loc
+		}
+	}
+	return nil
+}
+
+// page returns a copy of the received page with the values of the variant
+func (g GeoVariant) page(p Page) Page {
+	if g.Template != "" {
+		p.Template = g.Template
+	}
+	if g.Layout != "" {
+		p.Layout = g.Layout
+	}
+	if g.BackendURLPattern != "" {
+		p.BackendURLPattern = g.BackendURLPattern
+	}
+	extra := make(map[string]interface{}, len(p.Extra)+len(g.Extra))
+	for k, v := range p.Extra {
+		extra[k] = v
+	}
+	for k, v := range g.Extra {
+		extra[k] = v
+	}
+	p.Extra = extra
+	p.GeoVariants = nil
+	return p
+}
+
+// geoHandlerFunc dispatches the requests to the handler of the variant matching the region or
+// the country of the client, falling back to the default handler
+func geoHandlerFunc(h *Handler, variants map[string]*Handler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if loc := GeoFromContext(c); loc != nil {
+			keys := []string{loc.Country}
+			if loc.Region != "" {
+				keys = []string{loc.Country + "-" + loc.Region, loc.Country}
+			}
+			for _, key := range keys {
+				if v, ok := variants[key]; ok {
+					v.HandlerFunc(c)
+					return
+				}
+			}
+		}
+		h.HandlerFunc(c)
+	}
+}
@@ -0,0 +1,140 @@
+package engine
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type fakeLocator map[string]*GeoLocation
+
+func (f fakeLocator) Locate(ip net.IP) (*GeoLocation, error) {
+	if loc, ok := f[ip.String()]; ok {
+		return loc, nil
+	}
+	return nil, fmt.Errorf("unknown ip %s", ip.String())
+}
+
+var testLocator = fakeLocator{
+	"10.0.0.1": {Country: "ES", Region: "CT", City: "Barcelona"},
+	"10.0.0.2": {Country: "ES", Region: "MD", City: "Madrid"},
+	"10.0.0.3": {Country: "US", Region: "CA"},
+	"10.0.0.4": {Country: "FR"},
+}
+
+func TestNewMaxMindLocator_ko(t *testing.T) {
+	if _, err := NewMaxMindLocator("unknown_file_not_present_in_the_fs"); err == nil {
+		t.Error("error expected")
+	}
+}
+
+func TestGeoIPMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(GeoIPMiddleware(testLocator))
+	e.GET("/", func(c *gin.Context) {
+		loc := GeoFromContext(c)
+		if loc == nil {
+			c.String(http.StatusOK, "unknown")
+			return
+		}
+		c.String(http.StatusOK, loc.Country+"-"+loc.Region+"-"+loc.City)
+	})
+
+	for ip, expected := range map[string]string{
+		"10.0.0.1": "ES-CT-Barcelona",
+		"10.0.0.4": "FR--",
+		"10.0.0.9": "unknown",
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.RemoteAddr = ip + ":1234"
+		e.ServeHTTP(w, req)
+		if body := w.Body.String(); body != expected {
+			t.Errorf("%s: unexpected body: %s", ip, body)
+		}
+	}
+}
+
+func TestGeoFromContext_empty(t *testing.T) {
+	if loc := GeoFromContext(nil); loc != nil {
+		t.Errorf("unexpected location: %v", loc)
+	}
+	if loc := GeoFromContext(&gin.Context{}); loc != nil {
+		t.Errorf("unexpected location: %v", loc)
+	}
+}
+
+func TestGeoVariant_page(t *testing.T) {
+	p := Page{
+		Name:        "home",
+		Template:    "home",
+		Layout:      "main",
+		Extra:       map[string]interface{}{"a": 1, "b": 2},
+		GeoVariants: map[string]GeoVariant{"ES": {}},
+	}
+	variant := GeoVariant{Template: "home_es", Extra: map[string]interface{}{"b": 3}}.page(p)
+
+	if variant.Name != "home" || variant.Template != "home_es" || variant.Layout != "main" {
+		t.Errorf("unexpected page: %v", variant)
+	}
+	if variant.Extra["a"] != 1 || variant.Extra["b"] != 3 || p.Extra["b"] != 2 {
+		t.Errorf("unexpected extra: %v", variant.Extra)
+	}
+	if variant.GeoVariants != nil {
+		t.Errorf("unexpected variants: %v", variant.GeoVariants)
+	}
+}
+
+func TestMustachePageFactory_Build_geoVariants(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(GeoIPMiddleware(testLocator))
+
+	pf := NewMustachePageFactory(e, NewTemplateStore())
+	pf.FS = fstest.MapFS{
+		"default.mustache": {Data: []byte("default {{Extra.footer}} {{Geo.City}}")},
+		"es.mustache":      {Data: []byte("es {{Extra.footer}} {{Geo.City}}")},
+	}
+	pf.Build(Config{
+		Pages: []Page{
+			{
+				Name:       "home",
+				URLPattern: "/",
+				Template:   "default",
+				Extra:      map[string]interface{}{"footer": "terms"},
+				GeoVariants: map[string]GeoVariant{
+					"es":    {Template: "es"},
+					"ES-CT": {Template: "es", Extra: map[string]interface{}{"footer": "termes"}},
+					"US":    {Extra: map[string]interface{}{"footer": "ccpa"}},
+				},
+			},
+		},
+		Templates: map[string]string{"default": "default.mustache", "es": "es.mustache"},
+	})
+	time.Sleep(300 * time.Millisecond)
+
+	for ip, expected := range map[string]string{
+		"10.0.0.1": "es termes Barcelona",
+		"10.0.0.2": "es terms Madrid",
+		"10.0.0.3": "default ccpa ",
+		"10.0.0.4": "default terms ",
+		"10.0.0.9": "default terms ",
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.RemoteAddr = ip + ":1234"
+		e.ServeHTTP(w, req)
+		body, _ := ioutil.ReadAll(w.Result().Body)
+		if string(body) != expected {
+			t.Errorf("%s: unexpected body: %s", ip, string(body))
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package engine
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultGitTemplatesInterval = time.Minute
+	defaultGitTemplatesWebhook  = "/__api2html/git-templates"
+)
+
+// NewGitTemplateRepository clones the repository of the config, or updates the existing clone,
+// so its files can be used as templates, layouts and partials
+func NewGitTemplateRepository(cfg GitTemplates) (*GitTemplateRepository, error) {
+	g := &GitTemplateRepository{
+		URL:      cfg.Repository,
+		Branch:   cfg.Branch,
+		Dir:      cfg.Dir,
+		Interval: parseDurationOr(cfg.Interval, defaultGitTemplatesInterval),
+		Secret:   cfg.WebhookSecret,
+	}
+	if g.Branch == "" {
+		g.Branch = "main"
+	}
+	if g.Dir == "" {
+		g.Dir = filepath.Join(os.TempDir(), "api2html-templates")
+	}
+	if _, err := os.Stat(filepath.Join(g.Dir, ".git")); os.IsNotExist(err) {
+		log.Println("cloning the templates from", g.URL)
+		if _, err := g.git("", "clone", "--branch", g.Branch, "--single-branch", g.URL, g.Dir); err != nil {
+			return nil, err
+		}
+		return g, nil
+	}
+	if _, err := g.Pull(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// GitTemplateRepository is a local clone of the git repository containing the templates, layouts
+// and partials. The paths of the config are relative to its root
+type GitTemplateRepository struct {
+	URL      string
+	Branch   string
+	Dir      string
+	Interval time.Duration
+	// Secret verifies the signature of the webhook requests (X-Hub-Signature-256). If empty, they
+	// are not verified
+	Secret string
+	mutex  sync.Mutex
+}
+
+// Pull updates the clone with the last commit of the branch and returns the paths of the changed
+// files
+func (g *GitTemplateRepository) Pull() ([]string, error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	prev, err := g.git(g.Dir, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := g.git(g.Dir, "fetch", "origin", g.Branch); err != nil {
+		return nil, err
+	}
+	if _, err := g.git(g.Dir, "reset", "--hard", "origin/"+g.Branch); err != nil {
+		return nil, err
+	}
+	out, err := g.git(g.Dir, "diff", "--name-only", prev, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(out), nil
+}
+
+// Watch pulls the repository every Interval, reloading the renderers depending on the changed
+// files. The returned function stops pulling it
+func (g *GitTemplateRepository) Watch(graph *TemplateGraph, store *TemplateStore) func() {
+	ticker := time.NewTicker(g.Interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				ticker.Stop()
+				return
+			case <-ticker.C:
+			}
+			g.update(graph, store)
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// WebhookHandlerFunc returns a gin handler pulling the repository on every push notification
+func (g *GitTemplateRepository) WebhookHandlerFunc(graph *TemplateGraph, store *TemplateStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := ioutil.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		if g.Secret != "" && !validWebhookSignature(g.Secret, body, c.GetHeader("X-Hub-Signature-256")) {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		go g.update(graph, store)
+		c.Status(http.StatusAccepted)
+	}
+}
+
+func (g *GitTemplateRepository) update(graph *TemplateGraph, store *TemplateStore) {
+	changed, err := g.Pull()
+	if err != nil {
+		log.Println("pulling the templates:", err.Error())
+		return
+	}
+	graph.ReloadPaths(store, changed)
+}
+
+func (g *GitTemplateRepository) git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %s: %s", args[0], err.Error(), strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func validWebhookSignature(secret string, body []byte, signature string) bool {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
@@ -0,0 +1,102 @@
+package engine
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGitTemplateRepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	origin, err := ioutil.TempDir("", "api2html-origin")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(origin)
+	dir, err := ioutil.TempDir("", "api2html-clone")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	os.RemoveAll(dir)
+	defer os.RemoveAll(dir)
+
+	commit := func(name, content string) {
+		ioutil.WriteFile(filepath.Join(origin, name), []byte(content), 0644)
+		for _, args := range [][]string{
+			{"add", "-A"},
+			{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-qm", "update " + name},
+		} {
+			cmd := exec.Command("git", args...)
+			cmd.Dir = origin
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Errorf("git %v: %s", args, string(out))
+			}
+		}
+	}
+	cmd := exec.Command("git", "init", "-q", "-b", "main", origin)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Skipf("git init: %s", string(out))
+	}
+	commit("home.mustache", "hi!")
+
+	repo, err := NewGitTemplateRepository(GitTemplates{Repository: origin, Dir: dir})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, "home.mustache"))
+	if err != nil || string(data) != "hi!" {
+		t.Errorf("unexpected content: %s %v", string(data), err)
+	}
+
+	commit("home.mustache", "bye!")
+	changed, err := repo.Pull()
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if len(changed) != 1 || changed[0] != "home.mustache" {
+		t.Errorf("unexpected changes: %v", changed)
+	}
+	data, _ = ioutil.ReadFile(filepath.Join(dir, "home.mustache"))
+	if string(data) != "bye!" {
+		t.Errorf("unexpected content: %s", string(data))
+	}
+}
+
+func TestGitTemplateRepository_WebhookHandlerFunc(t *testing.T) {
+	repo := &GitTemplateRepository{Secret: "secret", Dir: "unknown"}
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.POST("/hook", repo.WebhookHandlerFunc(&TemplateGraph{}, NewTemplateStore()))
+
+	body := []byte(`{"ref":"refs/heads/main"}`)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(body)
+	for signature, status := range map[string]int{
+		"sha256=" + hex.EncodeToString(mac.Sum(nil)): http.StatusAccepted,
+		"sha256=invalid": http.StatusUnauthorized,
+	} {
+		req, _ := http.NewRequest("POST", "/hook", bytes.NewReader(body))
+		req.Header.Set("X-Hub-Signature-256", signature)
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		if w.Result().StatusCode != status {
+			t.Errorf("unexpected status code: %d", w.Result().StatusCode)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package engine
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"log"
+)
+
+const (
+	// MustacheEngine is the default template engine of the pages
+	MustacheEngine = "mustache"
+	// GoTemplateEngine is the engine of the pages rendered with the html/template package
+	GoTemplateEngine = "gotemplate"
+	// goLayoutContent is the name the layouts use for including the template they compose
+	goLayoutContent = "content"
+)
+
+// NewGoTemplateRenderer returns a GoTemplateRenderer and an error if something went wrong
+func NewGoTemplateRenderer(r io.Reader) (*GoTemplateRenderer, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New("api2html").Parse(string(data))
+	if err != nil {
+		return nil, err
+	}
+	return &GoTemplateRenderer{tmpl}, nil
+}
+
+// GoTemplateRenderer is a renderer with a single html/template template
+type GoTemplateRenderer struct {
+	tmpl *template.Template
+}
+
+// Render implements the renderer interface
+func (g GoTemplateRenderer) Render(w io.Writer, v interface{}) error {
+	return g.tmpl.Execute(w, v)
+}
+
+// NewGoLayoutRenderer returns a GoTemplateRenderer composing the template with the layout. The
+// layout includes the template with {{ template "content" . }}
+func NewGoLayoutRenderer(t, l *GoTemplateRenderer) (*GoTemplateRenderer, error) {
+	layout, err := l.tmpl.Clone()
+	if err != nil {
+		return nil, err
+	}
+	// the trees are escaped in place when executed, so they can not be shared
+	if _, err := layout.AddParseTree(goLayoutContent, t.tmpl.Tree.Copy()); err != nil {
+		return nil, err
+	}
+	return &GoTemplateRenderer{layout}, nil
+}
+
+// NewGoTemplateRendererMapFS parses the received templates and layouts of the config as
+// html/template templates, reading them from the fs.FS (or from the local filesystem if nil)
+func NewGoTemplateRendererMapFS(fsys fs.FS, cfg Config, names map[string]bool) (map[string]*GoTemplateRenderer, error) {
+	result := map[string]*GoTemplateRenderer{}
+	for _, section := range []map[string]string{cfg.Templates, cfg.Layouts} {
+		for name, path := range section {
+			if !names[name] {
+				continue
+			}
+			f, err := openFile(fsys, path)
+			if err != nil {
+				log.Println("reading", path, ":", err.Error())
+				return result, err
+			}
+			renderer, err := NewGoTemplateRenderer(f)
+			f.Close()
+			if err != nil {
+				log.Println("parsing", path, ":", err.Error())
+				return result, err
+			}
+			result[name] = renderer
+		}
+	}
+	return result, nil
+}
+
+// goTemplateNames returns the templates and the layouts of the pages using the Go templates
+func goTemplateNames(cfg Config) map[string]bool {
+	names := map[string]bool{}
+	for _, page := range cfg.Pages {
+		if page.Engine != GoTemplateEngine {
+			continue
+		}
+		for _, p := range pageVariants(page) {
+			names[p.Template] = true
+			if p.Layout != "" {
+				names[p.Layout] = true
+			}
+		}
+	}
+	return names
+}
+
+// mustacheConfig returns a copy of the config without the pages using the Go templates and
+// their templates and layouts
+func mustacheConfig(cfg Config) Config {
+	names := goTemplateNames(cfg)
+	if len(names) == 0 {
+		return cfg
+	}
+	filter := func(section map[string]string) map[string]string {
+		res := map[string]string{}
+		for name, path := range section {
+			if !names[name] {
+				res[name] = path
+			}
+		}
+		return res
+	}
+	cfg.Templates = filter(cfg.Templates)
+	cfg.Layouts = filter(cfg.Layouts)
+	pages := []Page{}
+	for _, page := range cfg.Pages {
+		if page.Engine != GoTemplateEngine {
+			pages = append(pages, page)
+		}
+	}
+	cfg.Pages = pages
+	return cfg
+}
+
+// pageVariants returns the page and all its variants (geo, formats and locales)
+func pageVariants(page Page) []Page {
+	res := append([]Page{page}, geoVariantPages(page)...)
+	res = append(res, formatPages(page)...)
+	return append(res, localeVariantPages(page)...)
+}
+
+func geoVariantPages(page Page) []Page {
+	res := []Page{}
+	for _, variant := range page.GeoVariants {
+		res = append(res, variant.page(page))
+	}
+	return res
+}
+
+// setGoTemplates stores the Go template, the layout and their composition of the page
+func (m *MustachePageFactory) setGoTemplates(templates map[string]*GoTemplateRenderer, page Page) {
+	t, ok := templates[page.Template]
+	if !ok {
+		fmt.Println("handler without template", page.Name, page.Template)
+		return
+	}
+	m.TemplateStore.Set(page.Template, t)
+	if page.Layout == "" {
+		return
+	}
+	l, ok := templates[page.Layout]
+	if !ok {
+		fmt.Println("layout not defined", page.Layout)
+		return
+	}
+	m.TemplateStore.Set(page.Layout, l)
+	composed, err := NewGoLayoutRenderer(t, l)
+	if err != nil {
+		fmt.Println("composing", page.Layout, page.Template, ":", err.Error())
+		return
+	}
+	m.TemplateStore.Set(layoutTopic(page.Layout, page.Template), composed)
+}
@@ -0,0 +1,94 @@
+package engine
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestGoTemplateRenderer(t *testing.T) {
+	tmpl, err := NewGoTemplateRenderer(bytes.NewBufferString(`hi, {{ .Extra.name }}!`))
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	layout, err := NewGoTemplateRenderer(bytes.NewBufferString(`<p>{{ template "content" . }}</p>`))
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	composed, err := NewGoLayoutRenderer(tmpl, layout)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+
+	data := ResponseContext{Extra: map[string]interface{}{"name": "<stranger>"}}
+	for r, expected := range map[*GoTemplateRenderer]string{
+		tmpl:     "hi, &lt;stranger&gt;!",
+		composed: "<p>hi, &lt;stranger&gt;!</p>",
+	} {
+		buf := &bytes.Buffer{}
+		if err := r.Render(buf, data); err != nil || buf.String() != expected {
+			t.Errorf("unexpected render: %s %v", buf.String(), err)
+		}
+	}
+
+	if _, err := NewGoTemplateRenderer(bytes.NewBufferString(`{{ if }}`)); err == nil {
+		t.Error("expecting an error")
+	}
+}
+
+func TestFactory_New_goTemplates(t *testing.T) {
+	ef := DefaultFactory
+	ef.TemplateFS = fstest.MapFS{
+		"a.mustache":  {Data: []byte("hi, {{Extra.name}}!")},
+		"b.tmpl":      {Data: []byte(`{{/* a go template */}}bye, {{ .Extra.name }}!`)},
+		"layout.tmpl": {Data: []byte(`[{{ template "content" . }}]`)},
+	}
+	ef.Parser = func(_ string) (Config, error) {
+		return Config{
+			Pages: []Page{
+				{URLPattern: "/a", Template: "a", Extra: map[string]interface{}{"name": "stranger"}},
+				{URLPattern: "/b", Template: "b", Layout: "layout", Engine: GoTemplateEngine, Extra: map[string]interface{}{"name": "stranger"}},
+			},
+			Templates: map[string]string{"a": "a.mustache", "b": "b.tmpl"},
+			Layouts:   map[string]string{"layout": "layout.tmpl"},
+		}, nil
+	}
+
+	e, err := ef.New("something", true)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	assertResponse(t, e, "/a", http.StatusOK, "hi, stranger!")
+	assertResponse(t, e, "/b", http.StatusOK, "[bye, stranger!]")
+}
+
+func TestNewGoLayoutRenderer_sharedTemplate(t *testing.T) {
+	tmpl, _ := NewGoTemplateRenderer(bytes.NewBufferString(`<a href="/?q={{ .Extra.q }}">{{ .Extra.q }}</a>`))
+	data := ResponseContext{Extra: map[string]interface{}{"q": "a&b"}}
+	expected := `<a href="/?q=a%26b">a&amp;b</a>`
+
+	for _, source := range []string{`{{ template "content" . }}`, `<div>{{ template "content" . }}</div>`} {
+		layout, _ := NewGoTemplateRenderer(bytes.NewBufferString(source))
+		composed, err := NewGoLayoutRenderer(tmpl, layout)
+		if err != nil {
+			t.Errorf("unexpected error: %s", err.Error())
+			return
+		}
+		buf := &bytes.Buffer{}
+		if err := composed.Render(buf, data); err != nil || !bytes.Contains(buf.Bytes(), []byte(expected)) {
+			t.Errorf("unexpected render: %s %v", buf.String(), err)
+		}
+	}
+	buf := &bytes.Buffer{}
+	if err := tmpl.Render(buf, data); err != nil || buf.String() != expected {
+		t.Errorf("unexpected render: %s %v", buf.String(), err)
+	}
+}
@@ -0,0 +1,102 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GraphQLError is an error reported by a GraphQL endpoint
+type GraphQLError struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+// GraphQLResponseGenerator is a ResponseGenerator sending the query of the page to a GraphQL
+// endpoint and adding the returned data object to the default response values. The errors
+// returned along with a partial data object are exposed as the Errors of the response, while
+// the ones returned without data fail the request
+type GraphQLResponseGenerator struct {
+	Page   Page
+	Client *http.Client
+}
+
+type graphQLRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables,omitempty"`
+}
+
+type graphQLResponse struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []GraphQLError         `json:"errors"`
+}
+
+// ResponseGenerator implements the ResponseGenerator interface
+func (g *GraphQLResponseGenerator) ResponseGenerator(c *gin.Context) (ResponseContext, error) {
+	params := requestParams(c)
+	result := ResponseContext{
+		Extra:   g.Page.Extra,
+		Robots:  g.Page.Robots,
+		Context: c,
+		Params:  params,
+		Geo:     GeoFromContext(c),
+		Helper:  newTplHelper(c),
+	}
+
+	cfg := g.Page.GraphQL
+	variables := map[string]interface{}{}
+	for name, param := range cfg.Variables {
+		if v, ok := params[param]; ok {
+			variables[name] = v
+		}
+	}
+	body, err := json.Marshal(graphQLRequest{cfg.Query, variables})
+	if err != nil {
+		return result, err
+	}
+	req, err := http.NewRequestWithContext(requestContext(c), "POST", cfg.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return result, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range backendHeaders(g.Page, c) {
+		req.Header.Set(k, v)
+	}
+
+	done := HooksFromContext(c).OnBackendCall(c, req)
+	resp, err := g.Client.Do(req)
+	done(resp, err)
+	if err != nil {
+		return result, newBackendError(err)
+	}
+
+	var target graphQLResponse
+	decodeDone := HooksFromContext(c).OnDecode(c)
+	decoder := json.NewDecoder(resp.Body)
+	decoder.UseNumber()
+	err = decoder.Decode(&target)
+	resp.Body.Close()
+	decodeDone(err)
+	if err != nil {
+		return result, newDecodeError(resp, err)
+	}
+
+	if target.Data == nil && len(target.Errors) > 0 {
+		return result, newDecodeError(resp, graphQLErrors(target.Errors))
+	}
+	result.Data = target.Data
+	result.Errors = target.Errors
+	return result, nil
+}
+
+func graphQLErrors(errs []GraphQLError) error {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Message
+	}
+	return errors.New("graphql: " + strings.Join(msgs, "; "))
+}
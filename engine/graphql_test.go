@@ -0,0 +1,73 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGraphQLResponseGenerator(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req graphQLRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+			return
+		}
+		if r.Method != "POST" || req.Query != "query($id: ID!) { user(id: $id) { name } }" {
+			t.Errorf("unexpected request: %s %v", r.Method, req)
+		}
+		switch req.Variables["id"] {
+		case "42":
+			fmt.Fprint(w, `{"data":{"user":{"name":"Jane"}},"errors":[{"message":"orders unavailable","path":["user","orders"]}]}`)
+		default:
+			fmt.Fprint(w, `{"data":null,"errors":[{"message":"user not found"}]}`)
+		}
+	}))
+	defer ts.Close()
+
+	subject := GraphQLResponseGenerator{
+		Page: Page{GraphQL: &GraphQLQuery{
+			Endpoint:  ts.URL,
+			Query:     "query($id: ID!) { user(id: $id) { name } }",
+			Variables: map[string]string{"id": "user"},
+		}},
+		Client: http.DefaultClient,
+	}
+
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.GET("/:user", func(c *gin.Context) {
+		resp, err := subject.ResponseGenerator(c)
+		if c.Param("user") != "42" {
+			if status := ErrorStatusCode(err); status != http.StatusBadGateway {
+				t.Errorf("unexpected status code: %d (%v)", status, err)
+			}
+			c.Status(200)
+			return
+		}
+		if err != nil {
+			t.Error("unexpected error:", err.Error())
+			return
+		}
+		if user, ok := resp.Data["user"].(map[string]interface{}); !ok || user["name"] != "Jane" {
+			t.Errorf("unexpected data: %v", resp.Data)
+		}
+		if len(resp.Errors) != 1 || resp.Errors[0].Message != "orders unavailable" {
+			t.Errorf("unexpected errors: %v", resp.Errors)
+		}
+		c.Status(200)
+	})
+
+	for _, path := range []string{"/42", "/1"} {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", path, nil)
+		e.ServeHTTP(w, r)
+		if w.Result().StatusCode != 200 {
+			t.Errorf("%s: unexpected status code: %d", path, w.Result().StatusCode)
+		}
+	}
+}
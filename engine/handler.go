@@ -1,15 +1,22 @@
 package engine
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"io/fs"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	newrelic "github.com/newrelic/go-agent"
-	nrgin "github.com/newrelic/go-agent/_integrations/nrgin/v1"
 )
 
 // HandlerConfig defines a Handler
@@ -36,54 +43,116 @@ var DefaultHandlerConfig = HandlerConfig{
 }
 
 // Default404StaticHandler is the default static handler for dealing with 404 errors
-var Default404StaticHandler = StaticHandler{[]byte(default404Tmpl)}
+var Default404StaticHandler = StaticHandler{Content: []byte(default404Tmpl)}
 
 // Default500StaticHandler is the default static handler for dealing with 500 errors
 var Default500StaticHandler = ErrorHandler{[]byte(default500Tmpl), http.StatusInternalServerError}
 
+// Default401StaticHandler is the default static handler for dealing with 401 errors
+var Default401StaticHandler = ErrorHandler{[]byte(default401Tmpl), http.StatusUnauthorized}
+
+// Default403StaticHandler is the default static handler for dealing with 403 errors
+var Default403StaticHandler = ErrorHandler{[]byte(default403Tmpl), http.StatusForbidden}
+
 // NewHandlerConfig creates a HandlerConfig from the given Page definition
 func NewHandlerConfig(page Page) HandlerConfig {
-	d, err := time.ParseDuration(page.CacheTTL)
-	if err != nil {
-		d = time.Hour
+	cacheTTL := cacheControl(page.CacheTTL)
+	if page.Forward.forwardsCredentials() || page.authenticated {
+		// the personalized pages must not be kept by the shared caches
+		cacheTTL = strings.Replace(cacheTTL, "public", "private", 1)
+	}
+
+	if page.Generator != "" {
+		return HandlerConfig{
+			page,
+			DefaultHandlerConfig.Renderer,
+			withPageData(page, pluginResponseGenerator(page)),
+			cacheTTL,
+		}
+	}
+
+	if page.GraphQL != nil {
+		rg := GraphQLResponseGenerator{page, pageClient(page)}
+		return HandlerConfig{
+			page,
+			DefaultHandlerConfig.Renderer,
+			withPageData(page, rg.ResponseGenerator),
+			cacheTTL,
+		}
 	}
-	cacheTTL := fmt.Sprintf("public, max-age=%d", int(d.Seconds()))
 
 	if page.BackendURLPattern == "" {
 		rg := StaticResponseGenerator{page}
 		return HandlerConfig{
 			page,
 			DefaultHandlerConfig.Renderer,
-			rg.ResponseGenerator,
+			withPageData(page, rg.ResponseGenerator),
 			cacheTTL,
 		}
 	}
 
-	decoder := JSONDecoder
-	if page.IsArray {
-		decoder = JSONArrayDecoder
+	decoder, err := pageDecoder(page)
+	if err != nil {
+		log.Println(page.Name, err.Error())
+		decoder = JSONDecoder
+	}
+	client := pageClient(page)
+	backend := NewBackend(client, page.BackendURLPattern)
+	if page.BackendFailover != nil && (len(page.BackendFailover.Hosts) > 0 || page.BackendFailover.Discovery != nil) {
+		pool := page.pools.get(page.Name, *page.BackendFailover)
+		backend = NewFailoverBackend(client, page.BackendURLPattern, pool)
 	}
-	rg := DynamicResponseGenerator{page, CachedClient(page.BackendURLPattern), decoder}
+	rg := DynamicResponseGenerator{page, backend, decoder}
 
 	return HandlerConfig{
 		page,
 		DefaultHandlerConfig.Renderer,
-		rg.ResponseGenerator,
+		withPageData(page, rg.ResponseGenerator),
 		cacheTTL,
 	}
 }
 
+// withPageData wraps the ResponseGenerator with the processing of the backend data declared by
+// the page: the data path, the redirects, the additional backends, the transformations, the
+// script and the markdown and HTML fields, in that order. The failures are mapped with the status
+// mapping of the page
+func withPageData(page Page, rg ResponseGenerator) ResponseGenerator {
+	rg = withTransformations(page, withBackends(page, withRedirects(page, withDataPath(page, rg))))
+	rg = withSanitizer(page, withMarkdown(page, withScript(page, rg)))
+	return withStatusMapping(page, rg)
+}
+
+// withBackends wraps the ResponseGenerator with a MultiBackendResponseGenerator if the page
+// declares additional backends
+func withBackends(page Page, rg ResponseGenerator) ResponseGenerator {
+	if len(page.Backends) == 0 {
+		return rg
+	}
+	return NewMultiBackendResponseGenerator(page, rg).ResponseGenerator
+}
+
+// cacheControl returns the Cache-Control header for the received ttl (1h if it is not valid)
+func cacheControl(ttl string) string {
+	d, err := time.ParseDuration(ttl)
+	if err != nil {
+		d = time.Hour
+	}
+	return fmt.Sprintf("public, max-age=%d", int(d.Seconds()))
+}
+
 // NewHandler creates a Handler with the given configuration. The returned handler will be keeping itself
 // subscribed to the latest template updates using the given subscription channel, allowing hot
 // template reloads
 func NewHandler(cfg HandlerConfig, subscriptionChan chan Subscription) *Handler {
 	h := &Handler{
-		cfg.Page,
-		cfg.Renderer,
-		make(chan Renderer),
-		subscriptionChan,
-		cfg.ResponseGenerator,
-		cfg.CacheControl,
+		Page:              cfg.Page,
+		Renderer:          cfg.Renderer,
+		Input:             make(chan Renderer),
+		Subscribe:         subscriptionChan,
+		ResponseGenerator: cfg.ResponseGenerator,
+		CacheControl:      cfg.CacheControl,
+		Stale:             newStaleCache(cfg.Page),
+		ErrorPages:        NewErrorPages(cfg.Page, subscriptionChan),
 	}
 	go h.updateRenderer()
 	return h
@@ -102,38 +171,211 @@ type Handler struct {
 	Subscribe         chan Subscription
 	ResponseGenerator ResponseGenerator
 	CacheControl      string
+	// Stale keeps the rendered responses served while they are refreshed or when the rendering
+	// fails, if the page defines a stale TTL
+	Stale *StaleCache
+	// ErrorPages renders the failed requests with the error templates of the page, if any
+	ErrorPages *ErrorPages
+	// PageCache keeps the rendered versions of the page, if the page enables the page cache
+	PageCache *PageOutputCache
+	// LiveReload injects the script reloading the page on every template update
+	LiveReload bool
+	// Fragments composes the rendered pages with the fragments they include, if the page
+	// enables the ESI processing
+	Fragments *FragmentComposer
+	// mutex guards the Renderer, replaced while serving requests
+	mutex sync.RWMutex
+	// versions tracks the Last-Modified date of the rendered URLs
+	versions contentVersions
+	// engine dispatches the requests received through ServeHTTP
+	engine *gin.Engine
+	once   sync.Once
 }
 
 func (h *Handler) updateRenderer() {
-	topic := h.Page.Template
+	subscribeRenderer(h.Subscribe, h.topic(), h.Input, h.setRenderer)
+}
+
+// loadRenderers implements the rendererSubscriber interface
+func (h *Handler) loadRenderers(store *TemplateStore) {
+	if r, ok := store.Get(h.topic()); ok {
+		h.setRenderer(r)
+	}
+	if h.ErrorPages != nil {
+		h.ErrorPages.loadRenderers(store)
+	}
+}
+
+// topic returns the name of the renderer of the page in the template store
+func (h *Handler) topic() string {
 	if h.Page.Layout != "" {
-		topic = fmt.Sprintf("%s-:-%s", h.Page.Layout, h.Page.Template)
+		return layoutTopic(h.Page.Layout, h.Page.Template)
 	}
-	for {
-		h.Subscribe <- Subscription{topic, h.Input}
-		h.Renderer = <-h.Input
+	return h.Page.Template
+}
+
+func (h *Handler) setRenderer(r Renderer) {
+	h.mutex.Lock()
+	h.Renderer = r
+	h.mutex.Unlock()
+}
+
+// ResponseHeaders returns the headers of the successful responses of the handler. The headers of
+// the page are added to them, overriding the default ones
+func (h *Handler) ResponseHeaders() http.Header {
+	headers := http.Header{}
+	if h.Stale != nil {
+		headers.Set("Cache-Control", h.CacheControl+", "+h.Stale.cacheControl())
+	} else {
+		headers.Set("Cache-Control", h.CacheControl)
+	}
+	if h.Page.Robots != "" {
+		headers.Set("X-Robots-Tag", h.Page.Robots)
+	}
+	for k, v := range h.Page.Headers {
+		headers.Set(k, v)
+	}
+	return headers
+}
+
+// ErrorResponse returns the status code and the headers of the responses failed with the
+// received error (see ResponseError)
+func ErrorResponse(err error) (int, http.Header) {
+	status := ErrorStatusCode(err)
+	headers := http.Header{}
+	if location := errorLocation(err); location != "" {
+		headers.Set("Location", location)
 	}
+	if errorRetryable(err) {
+		// the transient failures must not be kept by the intermediate caches
+		headers.Set("Cache-Control", "no-store")
+		if status == http.StatusServiceUnavailable {
+			headers.Set("Retry-After", "1")
+		}
+	}
+	if retryAfter := errorRetryAfter(err); retryAfter > 0 {
+		headers.Set("Retry-After", strconv.Itoa(retryAfter))
+	}
+	return status, headers
+}
+
+// setHeaders replaces the response headers with all the values of the received ones, so the
+// repeated headers (Link, Set-Cookie...) are not truncated to their first value
+func setHeaders(c *gin.Context, headers http.Header) {
+	for k, values := range headers {
+		c.Writer.Header().Del(k)
+		for _, v := range values {
+			c.Writer.Header().Add(k, v)
+		}
+	}
+}
+
+// ServeHTTP implements the http.Handler interface, so the handler can be mounted into any
+// Router. The route params are taken from the RouteParams of the request
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.once.Do(func() {
+		h.engine = gin.New()
+		h.engine.Any("/*path", func(c *gin.Context) {
+			c.Params = c.Params[:0]
+			for k, v := range RouteParams(c.Request) {
+				c.Params = append(c.Params, gin.Param{Key: k, Value: v})
+			}
+			h.HandlerFunc(c)
+		})
+	})
+	h.engine.ServeHTTP(w, r)
+}
+
+func (h *Handler) renderer() Renderer {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return h.Renderer
+}
+
+func (h *Handler) render(c *gin.Context, w io.Writer, result ResponseContext) error {
+	r := h.renderer()
+	if canary, ok := r.(*CanaryRenderer); ok {
+		r = canary.Select(c)
+	}
+	if h.Page.Minify {
+		r = MinifiedRenderer{r}
+	}
+	result.Extra = requestExtra(c, result.Extra)
+	if h.Fragments == nil {
+		return r.Render(w, result)
+	}
+	buf := &bytes.Buffer{}
+	if err := r.Render(buf, result); err != nil {
+		return err
+	}
+	body, err := h.Fragments.Compose(c.Request, buf.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(body)
+	return err
 }
 
 // HandlerFunc handles a gin request rendering the data returned by the response generator.
-// If the response generator does not return an error, it adds a Cache-Control header
+// If the response generator does not return an error, it adds the Cache-Control, ETag and
+// Last-Modified headers, honoring the conditional requests. Otherwise, the request is aborted
+// with the status code of the error (see ResponseError)
 func (h *Handler) HandlerFunc(c *gin.Context) {
-	if newrelicApp != nil {
-		nrgin.Transaction(c).SetName(h.Page.Name)
+	hooks := HooksFromContext(c)
+	hooks.OnRequest(c, h.Page.Name)
+	if h.PageCache != nil {
+		if body, ok := h.PageCache.Get(c); ok {
+			setHeaders(c, h.ResponseHeaders())
+			c.Header("X-Cache", "HIT")
+			h.writeRendered(c, body)
+			return
+		}
+	}
+	if h.Stale != nil && h.serveRevalidating(c) {
+		return
 	}
 	result, err := h.ResponseGenerator(c)
+	recordDebugContext(c, h.Page, result, err)
 	if err != nil {
-		c.AbortWithError(http.StatusInternalServerError, err)
+		if h.Stale != nil && h.serveOnError(c, err) {
+			return
+		}
+		status, headers := ErrorResponse(err)
+		for k, v := range h.Page.Headers {
+			c.Header(k, v)
+		}
+		setHeaders(c, headers)
+		if !h.serveErrorPage(c, status, result, err) {
+			c.AbortWithError(status, err)
+		}
 		return
 	}
-	if newrelicApp != nil {
-		defer newrelic.StartSegment(nrgin.Transaction(c), "Render").End()
+	setHeaders(c, h.ResponseHeaders())
+	if len(result.Missing) > 0 {
+		// the partial responses must not be kept by the caches
+		c.Header("Cache-Control", "no-store")
 	}
-	c.Header("Cache-Control", h.CacheControl)
-	if err := h.Renderer.Render(c.Writer, result); err != nil {
-		c.AbortWithError(http.StatusInternalServerError, err)
+	done := hooks.OnRender(c)
+	buf := &bytes.Buffer{}
+	err = h.render(c, buf, result)
+	done(err)
+	if err != nil {
+		if h.Stale != nil && h.serveOnError(c, err) {
+			return
+		}
+		if !h.serveErrorPage(c, http.StatusInternalServerError, result, err) {
+			c.AbortWithError(http.StatusInternalServerError, err)
+		}
 		return
 	}
+	if h.Stale != nil && len(result.Missing) == 0 {
+		h.Stale.set(staleKey(c), buf.Bytes())
+	}
+	if h.PageCache != nil && len(result.Missing) == 0 {
+		h.PageCache.Set(c, buf.Bytes())
+		c.Header("X-Cache", "MISS")
+	}
+	h.writeRendered(c, buf.Bytes())
 }
 
 // NewStaticHandler creates a StaticHandler using the content of the received path
@@ -143,24 +385,69 @@ func NewStaticHandler(path string) (StaticHandler, error) {
 		log.Println("reading", path, ":", err.Error())
 		return StaticHandler{}, err
 	}
-	return StaticHandler{data}, nil
+	h := StaticHandler{Content: data, ETag: contentETag(data)}
+	if info, err := os.Stat(path); err == nil {
+		h.ModTime = info.ModTime()
+	}
+	return h, nil
 }
 
-// StaticHandler is a Handler that writes the injected content
+// NewStaticHandlerFS creates a StaticHandler using the content of the received path in the
+// received fs.FS. If the fs.FS is nil, the local filesystem is used
+func NewStaticHandlerFS(fsys fs.FS, path string) (StaticHandler, error) {
+	if fsys == nil {
+		return NewStaticHandler(path)
+	}
+	data, err := fs.ReadFile(fsys, fsPath(path))
+	if err != nil {
+		log.Println("reading", path, ":", err.Error())
+		return StaticHandler{}, err
+	}
+	h := StaticHandler{Content: data, ETag: contentETag(data)}
+	if info, err := fs.Stat(fsys, fsPath(path)); err == nil {
+		h.ModTime = info.ModTime()
+	}
+	return h, nil
+}
+
+// StaticHandler is a Handler that writes the injected content. When dispatched as a regular
+// handler, it supports the conditional and the byte-range requests
 type StaticHandler struct {
 	Content []byte
+	// ModTime is the last modification time of the content, compared with the If-Modified-Since
+	// header. It is ignored if zero
+	ModTime time.Time
+	// ETag is the entity tag of the content, compared with the If-None-Match header. It is
+	// ignored if empty
+	ETag string
+	// ContentType is the Content-Type header of the content. If empty, it is detected
+	ContentType string
 }
 
 // HandlerFunc creates a gin handler that does nothing but writing the static content
 func (e *StaticHandler) HandlerFunc() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if newrelicApp != nil {
-			nrgin.Transaction(c).SetName("StaticHandler")
+		HooksFromContext(c).OnRequest(c, "StaticHandler")
+		if e.ContentType != "" {
+			c.Header("Content-Type", e.ContentType)
 		}
-		c.Writer.Write(e.Content)
+		if c.Writer.Status() != http.StatusOK {
+			// dispatched by a gin special handler (NoRoute...), so the status must be kept
+			c.Writer.Write(e.Content)
+			return
+		}
+		if e.ETag != "" {
+			c.Header("ETag", e.ETag)
+		}
+		http.ServeContent(c.Writer, c.Request, "", e.ModTime, bytes.NewReader(e.Content))
 	}
 }
 
+func contentETag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return fmt.Sprintf(`"%s"`, hex.EncodeToString(sum[:16]))
+}
+
 // NewErrorHandler creates a ErrorHandler using the content of the received path
 func NewErrorHandler(path string, code int) (ErrorHandler, error) {
 	data, err := ioutil.ReadFile(path)
@@ -171,6 +458,20 @@ func NewErrorHandler(path string, code int) (ErrorHandler, error) {
 	return ErrorHandler{data, code}, nil
 }
 
+// NewErrorHandlerFS creates a ErrorHandler using the content of the received path in the
+// received fs.FS. If the fs.FS is nil, the local filesystem is used
+func NewErrorHandlerFS(fsys fs.FS, path string, code int) (ErrorHandler, error) {
+	if fsys == nil {
+		return NewErrorHandler(path, code)
+	}
+	data, err := fs.ReadFile(fsys, fsPath(path))
+	if err != nil {
+		log.Println("reading", path, ":", err.Error())
+		return ErrorHandler{}, err
+	}
+	return ErrorHandler{data, code}, nil
+}
+
 // ErrorHandler is a Handler that writes the injected content. It's intended to be dispatched
 // by the gin special handlers (NoRoute, NoMethod) but they can also be used as regular handlers
 type ErrorHandler struct {
@@ -183,7 +484,8 @@ func (e *ErrorHandler) HandlerFunc() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Next()
 
-		if !c.IsAborted() || c.Writer.Status() != e.ErrorCode {
+		if !c.IsAborted() || c.Writer.Status() != e.ErrorCode || c.Writer.Size() > 0 {
+			// the response may be rendered by an error template of the page
 			return
 		}
 
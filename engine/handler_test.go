@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"reflect"
 	"testing"
 	"time"
 
@@ -53,6 +54,9 @@ func TestNewStaticHandler(t *testing.T) {
 	if string(res) != string(data) {
 		t.Errorf("unexpected response content: %s", string(res))
 	}
+	if etag := w.Result().Header.Get("ETag"); etag != contentETag(data) {
+		t.Errorf("unexpected etag: %s", etag)
+	}
 }
 
 func TestNewErrorHandler(t *testing.T) {
@@ -119,6 +123,45 @@ func TestNewErrorHandler(t *testing.T) {
 	}
 }
 
+func TestStaticHandler_conditionalAndRangeRequests(t *testing.T) {
+	modTime := time.Date(2018, time.March, 1, 10, 0, 0, 0, time.UTC)
+	eh := StaticHandler{Content: []byte("0123456789"), ModTime: modTime, ETag: contentETag([]byte("0123456789"))}
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.GET("/static", eh.HandlerFunc())
+	engine.NoRoute(eh.HandlerFunc())
+
+	for _, tc := range []struct {
+		name    string
+		url     string
+		headers map[string]string
+		status  int
+		body    string
+	}{
+		{name: "plain", url: "/static", status: http.StatusOK, body: "0123456789"},
+		{name: "etag", url: "/static", headers: map[string]string{"If-None-Match": eh.ETag}, status: http.StatusNotModified},
+		{name: "stale etag", url: "/static", headers: map[string]string{"If-None-Match": `"other"`}, status: http.StatusOK, body: "0123456789"},
+		{name: "modified since", url: "/static", headers: map[string]string{"If-Modified-Since": modTime.Format(http.TimeFormat)}, status: http.StatusNotModified},
+		{name: "range", url: "/static", headers: map[string]string{"Range": "bytes=2-4"}, status: http.StatusPartialContent, body: "234"},
+		{name: "no route", url: "/unknown", headers: map[string]string{"If-None-Match": eh.ETag}, status: http.StatusNotFound, body: "0123456789"},
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", tc.url, nil)
+		for k, v := range tc.headers {
+			req.Header.Set(k, v)
+		}
+		engine.ServeHTTP(w, req)
+
+		if w.Code != tc.status {
+			t.Errorf("%s: unexpected status code: %d", tc.name, w.Code)
+		}
+		if body := w.Body.String(); body != tc.body {
+			t.Errorf("%s: unexpected response content: %s", tc.name, body)
+		}
+	}
+}
+
 func TestNewStaticHandler_ko(t *testing.T) {
 	_, err := NewStaticHandler("unknown_file_not_present_in_the_fs")
 	if err == nil {
@@ -274,3 +317,105 @@ func TestNewHandlerConfig_DynamicResponseGenerator(t *testing.T) {
 		t.Errorf("unexpected page config: %v", cfg.Page)
 	}
 }
+
+func TestNewHandlerConfig_authenticated(t *testing.T) {
+	cfg := NewHandlerConfig(Page{Name: "name", CacheTTL: "60s", authenticated: true})
+	if cfg.CacheControl != "private, max-age=60" {
+		t.Errorf("unexpected cache control: %s", cfg.CacheControl)
+	}
+}
+
+func TestHandler_ResponseHeaders(t *testing.T) {
+	h := &Handler{Page: Page{Robots: "noindex"}, CacheControl: "public, max-age=60"}
+	headers := h.ResponseHeaders()
+	if headers.Get("Cache-Control") != "public, max-age=60" || headers.Get("X-Robots-Tag") != "noindex" {
+		t.Errorf("unexpected headers: %v", headers)
+	}
+}
+
+func TestSetHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Frame-Options", "DENY")
+	setHeaders(c, http.Header{
+		"Cache-Control": {"public, max-age=60"},
+		"Link":          {"</app.css>; rel=preload; as=style", "</app.js>; rel=preload; as=script"},
+	})
+	if v := w.Header()["Cache-Control"]; len(v) != 1 || v[0] != "public, max-age=60" {
+		t.Errorf("unexpected cache control: %v", v)
+	}
+	if v := w.Header()["Link"]; len(v) != 2 || v[1] != "</app.js>; rel=preload; as=script" {
+		t.Errorf("unexpected links: %v", v)
+	}
+	if w.Header().Get("X-Frame-Options") != "DENY" {
+		t.Errorf("unexpected headers: %v", w.Header())
+	}
+}
+
+func TestHandler_ResponseHeaders_pageHeaders(t *testing.T) {
+	h := &Handler{
+		Page: Page{Headers: map[string]string{
+			"x-frame-options": "DENY",
+			"Cache-Control":   "no-cache",
+		}},
+		CacheControl: "public, max-age=60",
+	}
+	headers := h.ResponseHeaders()
+	if headers.Get("X-Frame-Options") != "DENY" || headers.Get("Cache-Control") != "no-cache" {
+		t.Errorf("unexpected headers: %v", headers)
+	}
+
+	h.Page.BackendURLPattern = "http://example.com"
+	h.ResponseGenerator = func(_ *gin.Context) (ResponseContext, error) {
+		return ResponseContext{}, &ResponseError{StatusCode: http.StatusBadGateway, Retryable: true}
+	}
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.GET("/", h.HandlerFunc)
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+	if w.Header().Get("X-Frame-Options") != "DENY" || w.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("unexpected headers of the error response: %v", w.Header())
+	}
+}
+
+func TestErrorResponse(t *testing.T) {
+	for _, tc := range []struct {
+		err     error
+		status  int
+		headers http.Header
+	}{
+		{err: ErrNoResponseGeneratorDefined, status: http.StatusInternalServerError, headers: http.Header{}},
+		{err: &ResponseError{StatusCode: http.StatusNotFound}, status: http.StatusNotFound, headers: http.Header{}},
+		{
+			err:     &ResponseError{StatusCode: http.StatusBadGateway, Retryable: true},
+			status:  http.StatusBadGateway,
+			headers: http.Header{"Cache-Control": {"no-store"}},
+		},
+		{
+			err:     newBackendError(ErrNoBackendTargets),
+			status:  http.StatusServiceUnavailable,
+			headers: http.Header{"Cache-Control": {"no-store"}, "Retry-After": {"1"}},
+		},
+		{
+			err:     &ResponseError{StatusCode: http.StatusServiceUnavailable, RetryAfter: 30},
+			status:  http.StatusServiceUnavailable,
+			headers: http.Header{"Retry-After": {"30"}},
+		},
+		{
+			err:     &ResponseError{StatusCode: http.StatusFound, Location: "/login"},
+			status:  http.StatusFound,
+			headers: http.Header{"Location": {"/login"}},
+		},
+	} {
+		status, headers := ErrorResponse(tc.err)
+		if status != tc.status || !reflect.DeepEqual(headers, tc.headers) {
+			t.Errorf("%v: unexpected response: %d %v", tc.err, status, headers)
+		}
+	}
+}
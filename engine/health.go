@@ -0,0 +1,182 @@
+package engine
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// DefaultHealthPath is the path of the liveness endpoint
+	DefaultHealthPath = "/healthz"
+
+	defaultProbeTimeout = 2 * time.Second
+	statusReady         = "ready"
+	statusNotReady      = "not ready"
+	statusUp            = "up"
+	statusDown          = "down"
+)
+
+// NewHealthChecker returns a HealthChecker probing the backends of the received config and
+// looking for the renderers of the pages in the received store
+func NewHealthChecker(cfg HealthChecks, pages []Page, store *TemplateStore) *HealthChecker {
+	timeout, err := time.ParseDuration(cfg.Timeout)
+	if err != nil || timeout <= 0 {
+		timeout = defaultProbeTimeout
+	}
+	return &HealthChecker{
+		Backends: cfg.Backends,
+		Pages:    pages,
+		Store:    store,
+		Client:   &http.Client{Timeout: timeout},
+	}
+}
+
+// HealthChecker reports whether the instance can render its pages: all their templates have been
+// parsed and published and all the probed backends are up
+type HealthChecker struct {
+	// Backends contains the URLs probed by name. A backend is up if it answers with a status
+	// code lower than 500
+	Backends map[string]string
+	Pages    []Page
+	Store    *TemplateStore
+	Client   *http.Client
+	// Warmer, if set, keeps the instance not ready until the warm up is done
+	Warmer *CacheWarmer
+}
+
+// HealthReport is the result of a readiness check
+type HealthReport struct {
+	Status string `json:"status"`
+	// MissingTemplates are the templates (or the compositions of a layout and a template) of
+	// the pages not published yet
+	MissingTemplates []string `json:"missing_templates,omitempty"`
+	// Warming is true while the warm up is running
+	Warming bool `json:"warming,omitempty"`
+	// Backends contains the result of the probe of every backend
+	Backends map[string]BackendHealth `json:"backends,omitempty"`
+}
+
+// BackendHealth is the result of the probe of a backend
+type BackendHealth struct {
+	Status     string `json:"status"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Latency    string `json:"latency"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Check checks the templates of the pages and probes the backends concurrently
+func (h *HealthChecker) Check() HealthReport {
+	report := HealthReport{Status: statusReady, MissingTemplates: h.missingTemplates()}
+	if len(report.MissingTemplates) > 0 {
+		report.Status = statusNotReady
+	}
+	if h.Warmer != nil && !h.Warmer.Done() {
+		report.Status = statusNotReady
+		report.Warming = true
+	}
+	if len(h.Backends) == 0 {
+		return report
+	}
+
+	report.Backends = make(map[string]BackendHealth, len(h.Backends))
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for name, u := range h.Backends {
+		wg.Add(1)
+		go func(name, u string) {
+			defer wg.Done()
+			res := h.probe(u)
+			mutex.Lock()
+			report.Backends[name] = res
+			if res.Status != statusUp {
+				report.Status = statusNotReady
+			}
+			mutex.Unlock()
+		}(name, u)
+	}
+	wg.Wait()
+	return report
+}
+
+func (h *HealthChecker) probe(u string) BackendHealth {
+	start := time.Now()
+	resp, err := h.Client.Get(u)
+	res := BackendHealth{Status: statusUp}
+	if err != nil {
+		res.Status = statusDown
+		res.Error = err.Error()
+	} else {
+		resp.Body.Close()
+		res.StatusCode = resp.StatusCode
+		if resp.StatusCode >= http.StatusInternalServerError {
+			res.Status = statusDown
+		}
+	}
+	res.Latency = time.Since(start).String()
+	return res
+}
+
+func (h *HealthChecker) missingTemplates() []string {
+	seen := map[string]bool{}
+	missing := []string{}
+	for _, page := range h.Pages {
+		for i, p := range pageVariants(page) {
+			topic := p.Template
+			switch {
+			case i == 0 && page.Renderer != "":
+				topic = rendererTopic(page)
+			case p.Layout != "":
+				topic = layoutTopic(p.Layout, p.Template)
+			}
+			if seen[topic] {
+				continue
+			}
+			seen[topic] = true
+			if _, ok := h.Store.Get(topic); !ok {
+				missing = append(missing, topic)
+			}
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// waitTemplates returns once all the templates of the pages are published or an error if they
+// are not published before the timeout
+func (h *HealthChecker) waitTemplates(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for missing := h.missingTemplates(); len(missing) > 0; missing = h.missingTemplates() {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for the templates %s", strings.Join(missing, ", "))
+		}
+		time.Sleep(warmupRetryDelay)
+	}
+	return nil
+}
+
+// ReadinessHandlerFunc returns a gin handler writing the health report, with a 503 status code if
+// the instance is not ready
+func (h *HealthChecker) ReadinessHandlerFunc() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := h.Check()
+		status := http.StatusOK
+		if report.Status != statusReady {
+			status = http.StatusServiceUnavailable
+		}
+		c.Header("Cache-Control", "no-store")
+		c.JSON(status, report)
+	}
+}
+
+// LivenessHandlerFunc is a gin handler answering with a 200 while the process is able to serve
+// requests
+func LivenessHandlerFunc(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.String(http.StatusOK, "ok")
+}
@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestHealthChecker(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer up.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer down.Close()
+
+	store := NewTemplateStore()
+	pages := []Page{
+		{Name: "home", URLPattern: "/", Template: "home", Layout: "main"},
+		{Name: "about", URLPattern: "/about", Template: "about"},
+	}
+	cfg := HealthChecks{Backends: map[string]string{"api": up.URL}}
+
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.GET("/readyz", NewHealthChecker(cfg, pages, store).ReadinessHandlerFunc())
+
+	var report HealthReport
+	assertReadiness(t, e, http.StatusServiceUnavailable, &report)
+	if len(report.MissingTemplates) != 2 || report.MissingTemplates[0] != "about" {
+		t.Errorf("unexpected missing templates: %v", report.MissingTemplates)
+	}
+
+	store.Set("about", EmptyRenderer)
+	store.Set(layoutTopic("main", "home"), EmptyRenderer)
+	report = HealthReport{}
+	assertReadiness(t, e, http.StatusOK, &report)
+	if report.Status != "ready" || report.Backends["api"].Status != "up" || report.Backends["api"].StatusCode != http.StatusNotFound {
+		t.Errorf("unexpected report: %+v", report)
+	}
+
+	cfg.Backends["other"] = down.URL
+	cfg.Backends["unknown"] = "http://127.0.0.1:1"
+	e = gin.New()
+	e.GET("/readyz", NewHealthChecker(cfg, pages, store).ReadinessHandlerFunc())
+	report = HealthReport{}
+	assertReadiness(t, e, http.StatusServiceUnavailable, &report)
+	if report.Backends["api"].Status != "up" || report.Backends["other"].Status != "down" ||
+		report.Backends["unknown"].Status != "down" || report.Backends["unknown"].Error == "" {
+		t.Errorf("unexpected report: %+v", report)
+	}
+}
+
+func assertReadiness(t *testing.T, e http.Handler, status int, report *HealthReport) {
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+	if w.Code != status {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), report); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRegisterHealthChecks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	registerHealthChecks(e, Config{
+		Pages:  []Page{{URLPattern: "/healthz"}},
+		Health: &HealthChecks{ReadinessPath: "/ready"},
+	}, NewTemplateStore(), nil)
+
+	routes := map[string]bool{}
+	for _, r := range e.Routes() {
+		routes[r.Path] = true
+	}
+	if routes["/healthz"] || !routes["/ready"] || routes["/readyz"] {
+		t.Errorf("unexpected routes: %v", routes)
+	}
+
+	e = gin.New()
+	registerHealthChecks(e, Config{}, NewTemplateStore(), nil)
+	assertResponse(t, e, "/healthz", http.StatusOK, "ok")
+	assertResponse(t, e, "/readyz", http.StatusOK, `{"status":"ready"}`)
+}
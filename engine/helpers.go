@@ -0,0 +1,163 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cbroglie/mustache"
+)
+
+const (
+	// DateHelper formats the rendered RFC3339 date (or unix timestamp) with the Go layout of
+	// the helper Format
+	DateHelper = "date"
+	// TruncateHelper truncates the rendered text to the number of runes of the helper Format
+	TruncateHelper = "truncate"
+	// CurrencyHelper formats the rendered number with two decimals and thousands separators,
+	// prefixed by the symbol of the helper Format
+	CurrencyHelper = "currency"
+	// UpperHelper converts the rendered text to upper case
+	UpperHelper = "upper"
+	// LowerHelper converts the rendered text to lower case
+	LowerHelper = "lower"
+)
+
+// HelperFunc is a template helper. It receives the raw text of the section calling it and a
+// function rendering a text with the current context, and returns the text to write
+type HelperFunc func(text string, render func(string) (string, error)) (string, error)
+
+var (
+	helpers      = map[string]HelperFunc{}
+	helpersMutex sync.RWMutex
+)
+
+// RegisterHelper makes the helper available to all the mustache templates as a lambda section
+// with the received name, like {{#formatDate}}{{ created_at }}{{/formatDate}}. The values of the
+// render context with the same name take precedence over the helpers
+func RegisterHelper(name string, h HelperFunc) {
+	if loadingPlugin(func(r *registry) { r.helpers[name] = h }) {
+		return
+	}
+	helpersMutex.Lock()
+	helpers[name] = h
+	helpersMutex.Unlock()
+}
+
+// NewHelper returns the built-in HelperFunc declared by the received config
+func NewHelper(cfg TemplateHelper) (HelperFunc, error) {
+	switch cfg.Type {
+	case DateHelper:
+		layout := cfg.Format
+		if layout == "" {
+			layout = "2006-01-02"
+		}
+		return renderedHelper(func(s string) (string, error) {
+			t, err := parseHelperDate(s)
+			if err != nil {
+				return "", err
+			}
+			return t.Format(layout), nil
+		}), nil
+	case TruncateHelper:
+		size, err := strconv.Atoi(cfg.Format)
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("invalid truncate size: %q", cfg.Format)
+		}
+		return renderedHelper(func(s string) (string, error) {
+			runes := []rune(s)
+			if len(runes) <= size {
+				return s, nil
+			}
+			return string(runes[:size]) + "…", nil
+		}), nil
+	case CurrencyHelper:
+		return renderedHelper(func(s string) (string, error) {
+			amount, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+			if err != nil {
+				return "", err
+			}
+			return formatCurrency(amount, cfg.Format), nil
+		}), nil
+	case UpperHelper:
+		return renderedHelper(func(s string) (string, error) { return strings.ToUpper(s), nil }), nil
+	case LowerHelper:
+		return renderedHelper(func(s string) (string, error) { return strings.ToLower(s), nil }), nil
+	}
+	return nil, fmt.Errorf("unknown helper type: %q", cfg.Type)
+}
+
+// addHelpers adds the helpers declared in the config and the received ones to the registry
+func (r *registry) addHelpers(declared map[string]TemplateHelper, custom map[string]HelperFunc) error {
+	for name, cfg := range declared {
+		h, err := NewHelper(cfg)
+		if err != nil {
+			return fmt.Errorf("helper %s: %s", name, err.Error())
+		}
+		r.helpers[name] = h
+	}
+	for name, h := range custom {
+		r.helpers[name] = h
+	}
+	return nil
+}
+
+// helperContext returns the helpers of the registry (or the ones registered in the package, if
+// it is nil) as mustache lambdas, ready to be used as the fallback render context
+func (r *registry) helperContext() map[string]interface{} {
+	if r == nil {
+		helpersMutex.RLock()
+		defer helpersMutex.RUnlock()
+		return lambdas(helpers)
+	}
+	return lambdas(r.helpers)
+}
+
+func lambdas(helpers map[string]HelperFunc) map[string]interface{} {
+	ctx := make(map[string]interface{}, len(helpers))
+	for name, h := range helpers {
+		h := h
+		ctx[name] = mustache.LambdaFunc(func(text string, render mustache.RenderFunc) (string, error) {
+			return h(text, render)
+		})
+	}
+	return ctx
+}
+
+// renderedHelper returns a HelperFunc applying the received function to the rendered text
+func renderedHelper(f func(string) (string, error)) HelperFunc {
+	return func(text string, render func(string) (string, error)) (string, error) {
+		s, err := render(text)
+		if err != nil {
+			return "", err
+		}
+		return f(strings.TrimSpace(s))
+	}
+}
+
+func parseHelperDate(s string) (time.Time, error) {
+	if ts, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(ts, 0).UTC(), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+func formatCurrency(amount float64, symbol string) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	parts := strings.SplitN(strconv.FormatFloat(amount, 'f', 2, 64), ".", 2)
+	integer := parts[0]
+	var b strings.Builder
+	for i, r := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(r)
+	}
+	return sign + symbol + b.String() + "." + parts[1]
+}
@@ -0,0 +1,67 @@
+package engine
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNewHelper(t *testing.T) {
+	for _, tc := range []struct {
+		cfg      TemplateHelper
+		text     string
+		expected string
+	}{
+		{TemplateHelper{Type: DateHelper, Format: "02 Jan 2006"}, "2018-03-15T10:00:00Z", "15 Mar 2018"},
+		{TemplateHelper{Type: DateHelper}, "1521108000", "2018-03-15"},
+		{TemplateHelper{Type: TruncateHelper, Format: "5"}, "supercalifragilistic", "super…"},
+		{TemplateHelper{Type: TruncateHelper, Format: "50"}, "short", "short"},
+		{TemplateHelper{Type: CurrencyHelper, Format: "$"}, "1234567.891", "$1,234,567.89"},
+		{TemplateHelper{Type: CurrencyHelper, Format: "€"}, "-12", "-€12.00"},
+		{TemplateHelper{Type: UpperHelper}, "abc", "ABC"},
+		{TemplateHelper{Type: LowerHelper}, "ABC", "abc"},
+	} {
+		h, err := NewHelper(tc.cfg)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tc.cfg.Type, err.Error())
+			continue
+		}
+		res, err := h(tc.text, func(s string) (string, error) { return s, nil })
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tc.cfg.Type, err.Error())
+			continue
+		}
+		if res != tc.expected {
+			t.Errorf("%s: unexpected result: %s", tc.cfg.Type, res)
+		}
+	}
+
+	for _, cfg := range []TemplateHelper{
+		{Type: "unknown"},
+		{Type: TruncateHelper, Format: "many"},
+	} {
+		if _, err := NewHelper(cfg); err == nil {
+			t.Errorf("%s: expecting an error", cfg.Type)
+		}
+	}
+}
+
+func TestMustacheRenderer_helpers(t *testing.T) {
+	reg := registeredComponents()
+	if err := reg.addHelpers(map[string]TemplateHelper{"shout": {Type: UpperHelper}}, nil); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	tmpl, err := newMustacheRenderer(nil, bytes.NewBufferString(`{{#shout}}hi, {{ Extra.name }}{{/shout}}!`), reg)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	buf := &bytes.Buffer{}
+	if err := tmpl.Render(buf, ResponseContext{Extra: map[string]interface{}{"name": "stranger"}}); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if buf.String() != "HI, STRANGER!" {
+		t.Errorf("unexpected render: %s", buf.String())
+	}
+}
@@ -0,0 +1,131 @@
+package engine
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const hooksKey = "api2html_hooks"
+
+// Hooks defines the lifecycle callbacks fired while processing a request, so instrumentation
+// adapters can observe the handlers without being hardwired into them
+type Hooks interface {
+	// OnRequest is called when a handler starts processing a request for the named page
+	OnRequest(c *gin.Context, name string)
+	// OnBackendCall is called right before sending a request to the backend. The returned
+	// function is called with the result of the call
+	OnBackendCall(c *gin.Context, req *http.Request) func(*http.Response, error)
+	// OnDecode is called right before decoding the backend response. The returned function is
+	// called with the result of the decoding
+	OnDecode(c *gin.Context) func(error)
+	// OnRender is called right before rendering the response. The returned function is called
+	// with the result of the rendering
+	OnRender(c *gin.Context) func(error)
+}
+
+// NoopHooks is a Hooks implementation that does nothing. It can be embedded by the
+// implementations interested just in some of the callbacks
+type NoopHooks struct{}
+
+// OnRequest implements the Hooks interface
+func (NoopHooks) OnRequest(_ *gin.Context, _ string) {}
+
+// OnBackendCall implements the Hooks interface
+func (NoopHooks) OnBackendCall(_ *gin.Context, _ *http.Request) func(*http.Response, error) {
+	return func(_ *http.Response, _ error) {}
+}
+
+// OnDecode implements the Hooks interface
+func (NoopHooks) OnDecode(_ *gin.Context) func(error) { return func(_ error) {} }
+
+// OnRender implements the Hooks interface
+func (NoopHooks) OnRender(_ *gin.Context) func(error) { return func(_ error) {} }
+
+// MultiHooks is a Hooks implementation that fans out every callback to all its members
+type MultiHooks []Hooks
+
+// OnRequest implements the Hooks interface
+func (m MultiHooks) OnRequest(c *gin.Context, name string) {
+	for _, h := range m {
+		h.OnRequest(c, name)
+	}
+}
+
+// OnBackendCall implements the Hooks interface
+func (m MultiHooks) OnBackendCall(c *gin.Context, req *http.Request) func(*http.Response, error) {
+	done := make([]func(*http.Response, error), len(m))
+	for i, h := range m {
+		done[i] = h.OnBackendCall(c, req)
+	}
+	return func(resp *http.Response, err error) {
+		for i := len(done) - 1; i >= 0; i-- {
+			done[i](resp, err)
+		}
+	}
+}
+
+// OnDecode implements the Hooks interface
+func (m MultiHooks) OnDecode(c *gin.Context) func(error) {
+	done := make([]func(error), len(m))
+	for i, h := range m {
+		done[i] = h.OnDecode(c)
+	}
+	return reverseCallbacks(done)
+}
+
+// OnRender implements the Hooks interface
+func (m MultiHooks) OnRender(c *gin.Context) func(error) {
+	done := make([]func(error), len(m))
+	for i, h := range m {
+		done[i] = h.OnRender(c)
+	}
+	return reverseCallbacks(done)
+}
+
+func reverseCallbacks(done []func(error)) func(error) {
+	return func(err error) {
+		for i := len(done) - 1; i >= 0; i-- {
+			done[i](err)
+		}
+	}
+}
+
+// HooksMiddleware returns a gin middleware that attaches the received hooks to the request,
+// so they are fired by the handlers processing it. It can be registered several times: the
+// hooks are accumulated
+func HooksMiddleware(h Hooks) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		addHooks(c, h)
+		c.Next()
+	}
+}
+
+// HooksFromContext returns the hooks attached to the gin context, or NoopHooks if there are none
+func HooksFromContext(c *gin.Context) Hooks {
+	if c == nil {
+		return NoopHooks{}
+	}
+	if v, ok := c.Get(hooksKey); ok {
+		if h, ok := v.(Hooks); ok {
+			return h
+		}
+	}
+	return NoopHooks{}
+}
+
+func addHooks(c *gin.Context, h Hooks) {
+	v, ok := c.Get(hooksKey)
+	if !ok {
+		c.Set(hooksKey, h)
+		return
+	}
+	switch current := v.(type) {
+	case MultiHooks:
+		c.Set(hooksKey, append(append(MultiHooks{}, current...), h))
+	case Hooks:
+		c.Set(hooksKey, MultiHooks{current, h})
+	default:
+		c.Set(hooksKey, h)
+	}
+}
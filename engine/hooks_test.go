@@ -0,0 +1,94 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestHooksFromContext_default(t *testing.T) {
+	if _, ok := HooksFromContext(nil).(NoopHooks); !ok {
+		t.Error("a nil context should return the noop hooks")
+	}
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	if _, ok := HooksFromContext(c).(NoopHooks); !ok {
+		t.Error("a context without hooks should return the noop hooks")
+	}
+}
+
+func TestHooksMiddleware(t *testing.T) {
+	events := []string{}
+	first := &spyHooks{name: "first", events: &events}
+	second := &spyHooks{name: "second", events: &events}
+
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"a":1}`)
+	}))
+	defer mockServer.Close()
+
+	rg := DynamicResponseGenerator{Page{}, DefaultClient(mockServer.URL), JSONDecoder}
+	h := &Handler{
+		Page: Page{Name: "page"},
+		Renderer: RendererFunc(func(w io.Writer, _ interface{}) error {
+			_, err := w.Write([]byte("ok"))
+			return err
+		}),
+		ResponseGenerator: rg.ResponseGenerator,
+	}
+
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(HooksMiddleware(first), HooksMiddleware(second))
+	e.GET("/", h.HandlerFunc)
+
+	assertResponse(t, e, "/", http.StatusOK, "ok")
+
+	expected := []string{
+		"first:request:page",
+		"second:request:page",
+		"first:backend",
+		"second:backend",
+		"second:backend:done:200",
+		"first:backend:done:200",
+		"first:decode",
+		"second:decode",
+		"second:decode:done:<nil>",
+		"first:decode:done:<nil>",
+		"first:render",
+		"second:render",
+		"second:render:done:<nil>",
+		"first:render:done:<nil>",
+	}
+	if strings.Join(events, ",") != strings.Join(expected, ",") {
+		t.Errorf("unexpected events.\nhave: %v\nwant: %v", events, expected)
+	}
+}
+
+type spyHooks struct {
+	name   string
+	events *[]string
+}
+
+func (s *spyHooks) add(event string) { *s.events = append(*s.events, s.name+":"+event) }
+
+func (s *spyHooks) OnRequest(_ *gin.Context, name string) { s.add("request:" + name) }
+
+func (s *spyHooks) OnBackendCall(_ *gin.Context, _ *http.Request) func(*http.Response, error) {
+	s.add("backend")
+	return func(resp *http.Response, _ error) { s.add(fmt.Sprintf("backend:done:%d", resp.StatusCode)) }
+}
+
+func (s *spyHooks) OnDecode(_ *gin.Context) func(error) {
+	s.add("decode")
+	return func(err error) { s.add(fmt.Sprintf("decode:done:%v", err)) }
+}
+
+func (s *spyHooks) OnRender(_ *gin.Context) func(error) {
+	s.add("render")
+	return func(err error) { s.add(fmt.Sprintf("render:done:%v", err)) }
+}
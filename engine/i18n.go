@@ -0,0 +1,337 @@
+package engine
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// TranslateHelper is the name of the lambda section translating its key, like
+	// {{#t}}home.title{{/t}}
+	TranslateHelper = "t"
+	// LangParam is the param of the backend URL patterns replaced by the locale of the request,
+	// like http://api.company.com/:lang/products
+	LangParam = "lang"
+	localeKey = "api2html_locale"
+)
+
+// NewTranslator returns a Translator with the bundles of the received config, read from the
+// received fs.FS. If the fs.FS is nil, the local filesystem is used
+func NewTranslator(fsys fs.FS, cfg I18n) (*Translator, error) {
+	if len(cfg.Locales) == 0 {
+		return nil, fmt.Errorf("the i18n section requires at least one locale")
+	}
+	t := &Translator{
+		Locales:    cfg.Locales,
+		Cookie:     cfg.Cookie,
+		PathPrefix: cfg.PathPrefix,
+		Messages:   map[string]map[string]string{},
+	}
+	for locale, path := range cfg.Bundles {
+		f, err := openFile(fsys, path)
+		if err != nil {
+			return nil, err
+		}
+		var messages map[string]string
+		if strings.ToLower(filepath.Ext(path)) == ".po" {
+			messages, err = parsePO(f)
+		} else {
+			messages, err = parseJSONBundle(f)
+		}
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %s", path, err.Error())
+		}
+		t.Messages[canonicalLocale(locale)] = messages
+	}
+	return t, nil
+}
+
+// Translator detects the locale of the requests and translates the message keys with the
+// bundle of the locale
+type Translator struct {
+	// Locales are the supported locales. The first one is the default
+	Locales []string
+	// Cookie is the name of the cookie selecting the locale. It is ignored if empty
+	Cookie string
+	// PathPrefix selects the locale with the first segment of the path (/es/products)
+	PathPrefix bool
+	// Messages contains the translations of every locale by key
+	Messages map[string]map[string]string
+}
+
+// HandlerFunc returns a gin middleware storing the locale of every request, taken from the
+// path prefix, the cookie or the Accept-Language header, in that order, or the default one
+func (t *Translator) HandlerFunc() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(localeKey, t.detect(c))
+		c.Writer.Header().Add("Vary", "Accept-Language")
+		c.Next()
+	}
+}
+
+func (t *Translator) detect(c *gin.Context) string {
+	if t.PathPrefix {
+		segment := strings.SplitN(strings.TrimPrefix(c.Request.URL.Path, "/"), "/", 2)[0]
+		if locale, ok := t.supported(segment); ok {
+			return locale
+		}
+	}
+	if t.Cookie != "" {
+		if cookie, err := c.Cookie(t.Cookie); err == nil {
+			if locale, ok := t.supported(cookie); ok {
+				return locale
+			}
+		}
+	}
+	for _, tag := range acceptedLanguages(c.GetHeader("Accept-Language")) {
+		if locale, ok := t.match(tag); ok {
+			return locale
+		}
+	}
+	return t.Locales[0]
+}
+
+// supported returns the supported locale equal to the received one
+func (t *Translator) supported(locale string) (string, bool) {
+	locale = canonicalLocale(locale)
+	for _, l := range t.Locales {
+		if canonicalLocale(l) == locale {
+			return l, true
+		}
+	}
+	return "", false
+}
+
+// match returns the supported locale equal to the received one or, if there is none, the first
+// one with the same language
+func (t *Translator) match(locale string) (string, bool) {
+	if l, ok := t.supported(locale); ok {
+		return l, true
+	}
+	language := localeLanguage(locale)
+	for _, l := range t.Locales {
+		if localeLanguage(l) == language {
+			return l, true
+		}
+	}
+	return "", false
+}
+
+// Translate returns the message of the key in the bundle of the locale, falling back to the
+// bundle of its language, then to the one of the default locale and finally to the key itself
+func (t *Translator) Translate(locale, key string) string {
+	for _, l := range localeFallbacks(locale, t.Locales[0]) {
+		if msg, ok := t.Messages[l][key]; ok && msg != "" {
+			return msg
+		}
+	}
+	return key
+}
+
+// Helper returns the HelperFunc translating the rendered key of the section with the locale of
+// the request, exposed to the templates as Extra.locale
+func (t *Translator) Helper() HelperFunc {
+	return func(text string, render func(string) (string, error)) (string, error) {
+		key, err := render(text)
+		if err != nil {
+			return "", err
+		}
+		locale, err := render("{{Extra.locale}}")
+		if err != nil {
+			return "", err
+		}
+		return t.Translate(locale, strings.TrimSpace(key)), nil
+	}
+}
+
+// page returns a copy of the received page rendered with the template and the layout of the
+// variant
+func (v LocaleVariant) page(p Page) Page {
+	if v.Template != "" {
+		p.Template = v.Template
+	}
+	if v.Layout != "" {
+		p.Layout = v.Layout
+	}
+	p.Locales = nil
+	p.GeoVariants = nil
+	p.Formats = nil
+	return p
+}
+
+func localeVariantPages(page Page) []Page {
+	res := []Page{}
+	for _, variant := range page.Locales {
+		res = append(res, variant.page(page))
+	}
+	return res
+}
+
+// localeHandlerFunc dispatches the requests to the handler of the variant matching the locale of
+// the request or its language, falling back to the default handler
+func localeHandlerFunc(handler gin.HandlerFunc, variants map[string]gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if locale := LocaleFromContext(c); locale != "" {
+			for _, key := range []string{canonicalLocale(locale), localeLanguage(locale)} {
+				if v, ok := variants[key]; ok {
+					v(c)
+					return
+				}
+			}
+		}
+		handler(c)
+	}
+}
+
+// LocaleFromContext returns the locale of the request, if any
+func LocaleFromContext(c *gin.Context) string {
+	if c == nil {
+		return ""
+	}
+	return c.GetString(localeKey)
+}
+
+// requestVariant returns the locale and the location negotiated for the request, the parts of the
+// rendered response not found in its URL
+func requestVariant(c *gin.Context) string {
+	variant := LocaleFromContext(c)
+	if loc := GeoFromContext(c); loc != nil {
+		variant += "|" + loc.Country + "|" + loc.Region + "|" + loc.City
+	}
+	return variant
+}
+
+// localeFallbacks returns the canonical locale, its language and the default locale, without
+// repetitions
+func localeFallbacks(locale, defaultLocale string) []string {
+	res := []string{}
+	for _, l := range []string{canonicalLocale(locale), localeLanguage(locale), canonicalLocale(defaultLocale), localeLanguage(defaultLocale)} {
+		if l != "" && (len(res) == 0 || res[len(res)-1] != l) {
+			res = append(res, l)
+		}
+	}
+	return res
+}
+
+// canonicalLocale returns the locale in lower case, using - as separator (pt_BR -> pt-br)
+func canonicalLocale(locale string) string {
+	return strings.ToLower(strings.Replace(strings.TrimSpace(locale), "_", "-", -1))
+}
+
+func localeLanguage(locale string) string {
+	return strings.SplitN(canonicalLocale(locale), "-", 2)[0]
+}
+
+// acceptedLanguages returns the language tags of the Accept-Language header sorted by weight
+func acceptedLanguages(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	tags := []weighted{}
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if f, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = f
+				}
+			}
+		}
+		if q > 0 {
+			tags = append(tags, weighted{tag, q})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+	res := make([]string, len(tags))
+	for i, t := range tags {
+		res[i] = t.tag
+	}
+	return res
+}
+
+// parseJSONBundle decodes a JSON bundle, flattening the nested objects into keys joined by dots
+// ({"home": {"title": "Hi"}} -> home.title)
+func parseJSONBundle(r io.Reader) (map[string]string, error) {
+	var data map[string]interface{}
+	if err := json.NewDecoder(r).Decode(&data); err != nil {
+		return nil, err
+	}
+	messages := map[string]string{}
+	flattenBundle(messages, "", data)
+	return messages, nil
+}
+
+func flattenBundle(messages map[string]string, prefix string, data map[string]interface{}) {
+	for k, v := range data {
+		switch v := v.(type) {
+		case string:
+			messages[prefix+k] = v
+		case map[string]interface{}:
+			flattenBundle(messages, prefix+k+".", v)
+		default:
+			messages[prefix+k] = fmt.Sprint(v)
+		}
+	}
+}
+
+// parsePO decodes the msgid and msgstr entries of a gettext PO file, supporting the multiline
+// strings. The comments, the header, the contexts and the plural forms are ignored
+func parsePO(r io.Reader) (map[string]string, error) {
+	messages := map[string]string{}
+	var msgid, msgstr string
+	// current is the string receiving the continuation lines, if any
+	var current *string
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "msgid "):
+			if msgid != "" {
+				messages[msgid] = msgstr
+			}
+			msgid, msgstr = "", ""
+			line, current = strings.TrimPrefix(line, "msgid "), &msgid
+		case strings.HasPrefix(line, "msgstr "):
+			line, current = strings.TrimPrefix(line, "msgstr "), &msgstr
+		case strings.HasPrefix(line, `"`):
+			if current == nil {
+				continue
+			}
+		default:
+			// msgctxt, msgid_plural, msgstr[n]...
+			current = nil
+			continue
+		}
+		s, err := strconv.Unquote(strings.TrimSpace(line))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", n, err.Error())
+		}
+		*current += s
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if msgid != "" {
+		messages[msgid] = msgstr
+	}
+	return messages, nil
+}
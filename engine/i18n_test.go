@@ -0,0 +1,169 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gin-gonic/gin"
+)
+
+var testBundles = fstest.MapFS{
+	"i18n/en.json": {Data: []byte(`{"home": {"title": "Welcome", "items": 3}, "bye": "Bye"}`)},
+	"i18n/es.po": {Data: []byte(`# Spanish translations
+msgid ""
+msgstr ""
+"Language: es\n"
+
+#: home.mustache:1
+msgid "home.title"
+msgstr "Bienvenido"
+
+msgid "home.long"
+msgstr ""
+"Una frase "
+"larga"
+
+msgctxt "menu"
+msgid "home.plural"
+msgid_plural "home.plurals"
+msgstr[0] "uno"
+msgstr[1] "varios"
+
+msgid "untranslated"
+msgstr ""
+`)},
+}
+
+func TestNewTranslator(t *testing.T) {
+	tr, err := NewTranslator(testBundles, I18n{
+		Locales: []string{"en", "es", "pt-BR"},
+		Bundles: map[string]string{"en": "i18n/en.json", "es": "/i18n/es.po"},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for _, tc := range []struct {
+		locale, key, expected string
+	}{
+		{"en", "home.title", "Welcome"},
+		{"en", "home.items", "3"},
+		{"es", "home.title", "Bienvenido"},
+		{"es", "home.long", "Una frase larga"},
+		{"es-MX", "home.title", "Bienvenido"},
+		{"es", "bye", "Bye"},
+		{"es", "untranslated", "untranslated"},
+		{"es", "home.plural", "home.plural"},
+		{"pt-BR", "home.title", "Welcome"},
+		{"en", "unknown", "unknown"},
+	} {
+		if res := tr.Translate(tc.locale, tc.key); res != tc.expected {
+			t.Errorf("%s %s: unexpected translation: %s", tc.locale, tc.key, res)
+		}
+	}
+
+	render := func(s string) (string, error) {
+		return strings.Replace(s, "{{Extra.locale}}", "es", -1), nil
+	}
+	if res, err := tr.Helper()(" home.title ", render); err != nil || res != "Bienvenido" {
+		t.Errorf("unexpected helper result: %s, %v", res, err)
+	}
+}
+
+func TestNewTranslator_ko(t *testing.T) {
+	for i, cfg := range []I18n{
+		{},
+		{Locales: []string{"en"}, Bundles: map[string]string{"en": "i18n/unknown.json"}},
+	} {
+		if _, err := NewTranslator(testBundles, cfg); err == nil {
+			t.Errorf("#%d: error expected", i)
+		}
+	}
+	fsys := fstest.MapFS{"bad.po": {Data: []byte("msgid \"unterminated\n")}}
+	if _, err := NewTranslator(fsys, I18n{Locales: []string{"en"}, Bundles: map[string]string{"en": "bad.po"}}); err == nil {
+		t.Error("error expected")
+	}
+}
+
+func TestTranslator_HandlerFunc(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tr := &Translator{Locales: []string{"en", "es", "pt-BR"}, Cookie: "lang", PathPrefix: true}
+	e := gin.New()
+	e.Use(tr.HandlerFunc())
+	e.GET("/*path", func(c *gin.Context) {
+		c.String(http.StatusOK, LocaleFromContext(c))
+	})
+
+	for i, tc := range []struct {
+		path, cookie, accept, expected string
+	}{
+		{"/products", "", "", "en"},
+		{"/es/products", "lang=pt-BR", "", "es"},
+		{"/products", "lang=pt_br", "es", "pt-BR"},
+		{"/products", "lang=fr", "fr, es;q=0.8", "es"},
+		{"/products", "", "pt-PT, en;q=0.5", "pt-BR"},
+		{"/products", "", "es;q=0, fr", "en"},
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", tc.path, nil)
+		if tc.cookie != "" {
+			req.Header.Set("Cookie", tc.cookie)
+		}
+		req.Header.Set("Accept-Language", tc.accept)
+		e.ServeHTTP(w, req)
+		if body := w.Body.String(); body != tc.expected {
+			t.Errorf("#%d: unexpected locale: %s", i, body)
+		}
+		if vary := w.Header().Get("Vary"); vary != "Accept-Language" {
+			t.Errorf("#%d: unexpected Vary header: %s", i, vary)
+		}
+	}
+}
+
+func TestLocaleHandlerFunc(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tr := &Translator{Locales: []string{"en", "es-ES", "es-MX", "fr"}}
+	handler := func(name string) gin.HandlerFunc {
+		return func(c *gin.Context) { c.String(http.StatusOK, name) }
+	}
+	e := gin.New()
+	e.Use(tr.HandlerFunc())
+	e.GET("/", localeHandlerFunc(handler("default"), map[string]gin.HandlerFunc{
+		"es":    handler("es"),
+		"es-mx": handler("es-mx"),
+	}))
+
+	for accept, expected := range map[string]string{
+		"en":    "default",
+		"es-ES": "es",
+		"es-MX": "es-mx",
+		"fr":    "default",
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Language", accept)
+		e.ServeHTTP(w, req)
+		if body := w.Body.String(); body != expected {
+			t.Errorf("%s: unexpected body: %s", accept, body)
+		}
+	}
+}
+
+func TestLocaleVariant_page(t *testing.T) {
+	p := Page{
+		Name:     "home",
+		Template: "home",
+		Layout:   "main",
+		Locales:  map[string]LocaleVariant{"es": {Template: "home_es"}},
+	}
+	res := p.Locales["es"].page(p)
+	if res.Template != "home_es" || res.Layout != "main" || res.Locales != nil {
+		t.Errorf("unexpected page: %v", res)
+	}
+	if pages := pageVariants(p); len(pages) != 2 || pages[1].Template != "home_es" {
+		t.Errorf("unexpected variants: %v", pages)
+	}
+}
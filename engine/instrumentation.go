@@ -0,0 +1,82 @@
+package engine
+
+import (
+	"log"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Instrumentation groups the gin middlewares and routes an observability exporter requires.
+// The middlewares are expected to attach the exporter hooks to the requests
+type Instrumentation struct {
+	Middlewares []gin.HandlerFunc
+	Routes      []func(*gin.Engine)
+	// Closers flush and release the exporters. They are called when the engine is closed
+	Closers []func() error
+}
+
+// Merge returns a new Instrumentation with the middlewares, the routes and the closers of both
+// instances
+func (i Instrumentation) Merge(other Instrumentation) Instrumentation {
+	return Instrumentation{
+		Middlewares: append(append([]gin.HandlerFunc{}, i.Middlewares...), other.Middlewares...),
+		Routes:      append(append([]func(*gin.Engine){}, i.Routes...), other.Routes...),
+		Closers:     append(append([]func() error{}, i.Closers...), other.Closers...),
+	}
+}
+
+// Close calls all the closers of the instrumentation, returning the first error
+func (i Instrumentation) Close() error {
+	var res error
+	for _, f := range i.Closers {
+		if err := f(); err != nil && res == nil {
+			res = err
+		}
+	}
+	return res
+}
+
+// NewInstrumentation returns the merged instrumentation of all the exporters enabled in the
+// received config, so several of them can observe the same requests
+func NewInstrumentation(cfg Config, devel bool) (Instrumentation, error) {
+	result := Instrumentation{}
+
+	if cfg.NewRelic != nil && cfg.NewRelic.License != "" {
+		log.Println("enabling the New Relic exporter")
+		i, err := NewNewRelicInstrumentation(*cfg.NewRelic, devel)
+		if err != nil {
+			return result, err
+		}
+		result = result.Merge(i)
+	}
+
+	if cfg.TracePropagation != nil {
+		log.Println("enabling the trace propagation")
+		result = result.Merge(NewTracePropagationInstrumentation(*cfg.TracePropagation))
+	}
+
+	if cfg.Prometheus != nil {
+		log.Println("enabling the Prometheus exporter")
+		result = result.Merge(NewPrometheusInstrumentation(*cfg.Prometheus))
+	}
+
+	if cfg.OpenTelemetry != nil {
+		log.Println("enabling the OpenTelemetry exporter")
+		i, err := NewOpenTelemetryInstrumentation(*cfg.OpenTelemetry)
+		if err != nil {
+			return result, err
+		}
+		result = result.Merge(i)
+	}
+
+	if cfg.AccessLog != nil {
+		log.Println("enabling the access logs")
+		i, err := NewAccessLogInstrumentation(*cfg.AccessLog)
+		if err != nil {
+			return result, err
+		}
+		result = result.Merge(i)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,41 @@
+package engine
+
+import "testing"
+
+func TestNewInstrumentation(t *testing.T) {
+	i, err := NewInstrumentation(Config{}, false)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if len(i.Middlewares) != 0 || len(i.Routes) != 0 {
+		t.Errorf("unexpected instrumentation: %v", i)
+	}
+
+	i, err = NewInstrumentation(Config{
+		Prometheus:    &Prometheus{},
+		OpenTelemetry: &OpenTelemetry{Endpoint: "localhost:4318", Insecure: true},
+	}, false)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if len(i.Middlewares) != 2 {
+		t.Errorf("unexpected number of middlewares: %d", len(i.Middlewares))
+	}
+	if len(i.Routes) != 1 {
+		t.Errorf("unexpected number of routes: %d", len(i.Routes))
+	}
+	if len(i.Closers) != 1 {
+		t.Errorf("unexpected number of closers: %d", len(i.Closers))
+	}
+	if err := i.Close(); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+}
+
+func TestNewInstrumentation_koNewRelic(t *testing.T) {
+	if _, err := NewInstrumentation(Config{NewRelic: &NewRelic{AppName: "a", License: "b"}}, false); err == nil {
+		t.Error("expecting error")
+	}
+}
@@ -0,0 +1,359 @@
+package engine
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	jwtUserKey = "api2html_jwt_user"
+	// jwksMinRefresh is the min time between the refreshes of the keys triggered by unknown key ids
+	jwksMinRefresh          = 30 * time.Second
+	defaultJWKSRefresh      = time.Hour
+	defaultJWTLeeway        = time.Minute
+	jwtUnauthorizedResponse = `Bearer error="invalid_token"`
+)
+
+var (
+	// ErrInvalidToken is returned when the token is malformed or its signature is not valid
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrExpiredToken is returned when the token is expired or not valid yet
+	ErrExpiredToken = errors.New("expired token")
+	// ErrUnknownKey is returned when the key id of the token is not in the key set
+	ErrUnknownKey = errors.New("unknown key")
+)
+
+// NewJWTValidator creates a JWTValidator with the received config
+func NewJWTValidator(cfg JWTAuth) (*JWTValidator, error) {
+	if cfg.Secret == "" && cfg.JWKSURL == "" {
+		return nil, errors.New("the jwt auth requires a secret or a jwks url")
+	}
+	v := &JWTValidator{
+		Secret:   []byte(cfg.Secret),
+		Issuer:   cfg.Issuer,
+		Audience: cfg.Audience,
+		Cookie:   cfg.Cookie,
+		Claims:   cfg.Claims,
+		Leeway:   parseDurationOr(cfg.Leeway, defaultJWTLeeway),
+		Now:      time.Now,
+	}
+	if cfg.JWKSURL != "" {
+		v.JWKS = &JWKS{
+			URL:      cfg.JWKSURL,
+			Client:   http.DefaultClient,
+			Interval: parseDurationOr(cfg.JWKSRefresh, defaultJWKSRefresh),
+		}
+	}
+	return v, nil
+}
+
+// JWTValidator validates the JWT of the requests, sent as a bearer token or in a cookie. The
+// tokens are signed with the Secret (HS256, HS384 and HS512) or with the keys of the JWKS (RS256,
+// RS384, RS512, ES256, ES384 and ES512)
+type JWTValidator struct {
+	Secret   []byte
+	JWKS     *JWKS
+	Issuer   string
+	Audience string
+	// Cookie is the name of the cookie with the token, checked if there is no bearer token
+	Cookie string
+	// Claims are the claims exposed to the templates. If empty, all of them are exposed
+	Claims []string
+	// Leeway is the clock skew tolerated checking the expiration of the tokens
+	Leeway time.Duration
+	// Now returns the current time
+	Now func() time.Time
+}
+
+// HandlerFunc returns a gin middleware rejecting the requests without a valid token and exposing
+// the claims of the valid ones to the templates as Extra.user
+func (v *JWTValidator) HandlerFunc() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := v.token(c)
+		if token == "" {
+			c.Header("WWW-Authenticate", "Bearer")
+			Unauthorized(c)
+			return
+		}
+		claims, err := v.Validate(token)
+		if err != nil {
+			c.Header("WWW-Authenticate", jwtUnauthorizedResponse)
+			Unauthorized(c)
+			return
+		}
+		user := claims
+		if len(v.Claims) > 0 {
+			user = make(map[string]interface{}, len(v.Claims))
+			for _, name := range v.Claims {
+				if value, ok := claims[name]; ok {
+					user[name] = value
+				}
+			}
+		}
+		c.Set(jwtUserKey, user)
+		c.Next()
+	}
+}
+
+func (v *JWTValidator) token(c *gin.Context) string {
+	if h := c.GetHeader("Authorization"); len(h) > 7 && strings.EqualFold(h[:7], "bearer ") {
+		return strings.TrimSpace(h[7:])
+	}
+	if v.Cookie != "" {
+		if cookie, err := c.Cookie(v.Cookie); err == nil {
+			return cookie
+		}
+	}
+	return ""
+}
+
+// Validate checks the signature, the expiration, the issuer and the audience of the token and
+// returns its claims
+func (v *JWTValidator) Validate(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+	header := struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}{}
+	if err := decodeJWTSegment(parts[0], &header); err != nil {
+		return nil, ErrInvalidToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if err := v.verify(header.Alg, header.Kid, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+
+	claims := map[string]interface{}{}
+	if err := decodeJWTSegment(parts[1], &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	now := v.Now()
+	if exp, ok := claims["exp"].(float64); ok && now.After(time.Unix(int64(exp), 0).Add(v.Leeway)) {
+		return nil, ErrExpiredToken
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(v.Leeway).Before(time.Unix(int64(nbf), 0)) {
+		return nil, ErrExpiredToken
+	}
+	if v.Issuer != "" && claims["iss"] != v.Issuer {
+		return nil, ErrInvalidToken
+	}
+	if v.Audience != "" && !jwtAudience(claims["aud"], v.Audience) {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+func (v *JWTValidator) verify(alg, kid string, input, signature []byte) error {
+	if len(alg) != 5 {
+		return ErrInvalidToken
+	}
+	var h crypto.Hash
+	switch alg[2:] {
+	case "256":
+		h = crypto.SHA256
+	case "384":
+		h = crypto.SHA384
+	case "512":
+		h = crypto.SHA512
+	default:
+		return ErrInvalidToken
+	}
+
+	if alg[:2] == "HS" {
+		if len(v.Secret) == 0 {
+			return ErrInvalidToken
+		}
+		mac := hmac.New(hashFunc(h), v.Secret)
+		mac.Write(input)
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return ErrInvalidToken
+		}
+		return nil
+	}
+
+	if v.JWKS == nil {
+		return ErrInvalidToken
+	}
+	key, err := v.JWKS.Key(kid)
+	if err != nil {
+		return err
+	}
+	hasher := hashFunc(h)()
+	hasher.Write(input)
+	digest := hasher.Sum(nil)
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if alg[:2] != "RS" || rsa.VerifyPKCS1v15(k, h, digest, signature) != nil {
+			return ErrInvalidToken
+		}
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if alg[:2] != "ES" || len(signature) != 2*size {
+			return ErrInvalidToken
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return ErrInvalidToken
+		}
+	default:
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// JWKS is a JSON Web Key Set fetched from the URL, refreshed every Interval and when a token
+// is signed with an unknown key
+type JWKS struct {
+	URL      string
+	Client   *http.Client
+	Interval time.Duration
+	mutex    sync.RWMutex
+	keys     map[string]crypto.PublicKey
+	fetched  time.Time
+}
+
+// Key returns the public key with the received id
+func (j *JWKS) Key(kid string) (crypto.PublicKey, error) {
+	j.mutex.RLock()
+	key, ok := j.keys[kid]
+	age := time.Since(j.fetched)
+	j.mutex.RUnlock()
+	if ok && age < j.Interval {
+		return key, nil
+	}
+	if !ok && age < jwksMinRefresh {
+		return nil, ErrUnknownKey
+	}
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	if time.Since(j.fetched) >= jwksMinRefresh {
+		keys, err := j.fetch()
+		j.fetched = time.Now()
+		if err != nil {
+			// the known keys are kept while the endpoint fails
+			if ok {
+				return key, nil
+			}
+			return nil, err
+		}
+		j.keys = keys
+	}
+	if key, ok := j.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+func (j *JWKS) fetch() (map[string]crypto.PublicKey, error) {
+	resp, err := j.Client.Get(j.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching the jwks: unexpected status code %d", resp.StatusCode)
+	}
+	set := struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]crypto.PublicKey{}
+	for _, k := range set.Keys {
+		switch k.Kty {
+		case "RSA":
+			n, err1 := base64.RawURLEncoding.DecodeString(k.N)
+			e, err2 := base64.RawURLEncoding.DecodeString(k.E)
+			if err1 != nil || err2 != nil {
+				continue
+			}
+			keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "EC":
+			var curve elliptic.Curve
+			switch k.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				continue
+			}
+			x, err1 := base64.RawURLEncoding.DecodeString(k.X)
+			y, err2 := base64.RawURLEncoding.DecodeString(k.Y)
+			if err1 != nil || err2 != nil {
+				continue
+			}
+			keys[k.Kid] = &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		}
+	}
+	return keys, nil
+}
+
+func decodeJWTSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func jwtAudience(aud interface{}, expected string) bool {
+	switch a := aud.(type) {
+	case string:
+		return a == expected
+	case []interface{}:
+		for _, v := range a {
+			if v == expected {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hashFunc(h crypto.Hash) func() hash.Hash {
+	switch h {
+	case crypto.SHA384:
+		return sha512.New384
+	case crypto.SHA512:
+		return sha512.New
+	}
+	return sha256.New
+}
@@ -0,0 +1,176 @@
+package engine
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestJWTValidator_Validate(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{
+				{
+					"kty": "RSA",
+					"kid": "rsa",
+					"n":   base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()),
+					"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes()),
+				},
+				{
+					"kty": "EC",
+					"kid": "ec",
+					"crv": "P-256",
+					"x":   base64.RawURLEncoding.EncodeToString(ecKey.X.Bytes()),
+					"y":   base64.RawURLEncoding.EncodeToString(ecKey.Y.Bytes()),
+				},
+			},
+		})
+	}))
+	defer jwks.Close()
+
+	v, err := NewJWTValidator(JWTAuth{
+		Secret:   "secret",
+		JWKSURL:  jwks.URL,
+		Issuer:   "https://auth.example.com",
+		Audience: "api2html",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	now := time.Now().Unix()
+	valid := map[string]interface{}{
+		"sub": "user-1",
+		"iss": "https://auth.example.com",
+		"aud": []string{"other", "api2html"},
+		"exp": now + 60,
+	}
+	for i, tc := range []struct {
+		token string
+		err   error
+	}{
+		{token: signHS256("secret", valid)},
+		{token: signRS256(rsaKey, "rsa", valid)},
+		{token: signES256(ecKey, "ec", valid)},
+		{token: signHS256("wrong", valid), err: ErrInvalidToken},
+		{token: signRS256(rsaKey, "unknown", valid), err: ErrUnknownKey},
+		{token: signRS256(rsaKey, "ec", valid), err: ErrInvalidToken},
+		{token: signHS256("secret", map[string]interface{}{"iss": "https://auth.example.com", "aud": "api2html", "exp": now - 120}), err: ErrExpiredToken},
+		{token: signHS256("secret", map[string]interface{}{"iss": "https://auth.example.com", "aud": "api2html", "nbf": now + 120}), err: ErrExpiredToken},
+		{token: signHS256("secret", map[string]interface{}{"iss": "https://evil.example.com", "aud": "api2html"}), err: ErrInvalidToken},
+		{token: signHS256("secret", map[string]interface{}{"iss": "https://auth.example.com", "aud": "other"}), err: ErrInvalidToken},
+		{token: "eyJhbGciOiJub25lIn0.eyJzdWIiOiJ1c2VyLTEifQ.", err: ErrInvalidToken},
+		{token: "not a token", err: ErrInvalidToken},
+	} {
+		claims, err := v.Validate(tc.token)
+		if err != tc.err {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		if err == nil && claims["sub"] != "user-1" {
+			t.Errorf("#%d: unexpected claims: %v", i, claims)
+		}
+	}
+}
+
+func TestJWTValidator_HandlerFunc(t *testing.T) {
+	v, _ := NewJWTValidator(JWTAuth{Secret: "secret", Cookie: "session", Claims: []string{"sub", "name"}})
+
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.GET("/", v.HandlerFunc(), func(c *gin.Context) {
+		extra := requestExtra(c, map[string]interface{}{"site": "example"})
+		c.String(http.StatusOK, fmt.Sprint(extra["site"], " ", extra["user"]))
+	})
+
+	token := signHS256("secret", map[string]interface{}{"sub": "user-1", "name": "Jane", "role": "admin"})
+	for i, tc := range []struct {
+		header string
+		cookie string
+		status int
+		body   string
+	}{
+		{header: "Bearer " + token, status: http.StatusOK, body: "example map[name:Jane sub:user-1]"},
+		{cookie: token, status: http.StatusOK, body: "example map[name:Jane sub:user-1]"},
+		{header: "Bearer " + signHS256("wrong", map[string]interface{}{}), status: http.StatusUnauthorized},
+		{status: http.StatusUnauthorized},
+	} {
+		req, _ := http.NewRequest("GET", "/", nil)
+		if tc.header != "" {
+			req.Header.Set("Authorization", tc.header)
+		}
+		if tc.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: "session", Value: tc.cookie})
+		}
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		if w.Code != tc.status {
+			t.Errorf("#%d: unexpected status code: %d", i, w.Code)
+		}
+		if w.Body.String() != tc.body {
+			t.Errorf("#%d: unexpected body: %s", i, w.Body.String())
+		}
+		if tc.status == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("#%d: the WWW-Authenticate header is missing", i)
+		}
+	}
+}
+
+func TestNewPageMiddleware_jwt(t *testing.T) {
+	if _, err := NewPageMiddleware(PageMiddleware{Type: JWTMiddleware}); err == nil {
+		t.Error("error expected without the jwt section")
+	}
+	if _, err := NewPageMiddleware(PageMiddleware{Type: JWTMiddleware, JWT: &JWTAuth{}}); err == nil {
+		t.Error("error expected without keys")
+	}
+	if _, err := NewPageMiddleware(PageMiddleware{Type: JWTMiddleware, JWT: &JWTAuth{Secret: "secret"}}); err != nil {
+		t.Error(err)
+	}
+}
+
+func jwtSigningInput(alg, kid string, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	return base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+}
+
+func signHS256(secret string, claims map[string]interface{}) string {
+	input := jwtSigningInput("HS256", "", claims)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(input))
+	return input + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func signRS256(key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	input := jwtSigningInput("RS256", kid, claims)
+	digest := sha256.Sum256([]byte(input))
+	sig, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func signES256(key *ecdsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	input := jwtSigningInput("ES256", kid, claims)
+	digest := sha256.Sum256([]byte(input))
+	r, s, _ := ecdsa.Sign(rand.Reader, key, digest[:])
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
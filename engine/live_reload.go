@@ -0,0 +1,79 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LiveReloadPath is the path of the event stream notifying the template updates to the browsers
+const LiveReloadPath = "/__api2html/live-reload"
+
+// defaultLiveReloadInterval is the time between the checks of the template updates
+const defaultLiveReloadInterval = 250 * time.Millisecond
+
+// liveReloadScript reloads the page once the event stream notifies a template update
+const liveReloadScript = `<script%s>(function(){var s=new EventSource(%q);` +
+	`s.addEventListener("reload",function(){s.close();location.reload();});})();</script>`
+
+// NewLiveReload creates a LiveReload watching the received store
+func NewLiveReload(store *TemplateStore) *LiveReload {
+	return &LiveReload{Store: store, Interval: defaultLiveReloadInterval}
+}
+
+// LiveReload notifies the browsers displaying the pages about the updates of the renderers of the
+// store, so they reload the pages as soon as the hot reloaded templates are published
+type LiveReload struct {
+	Store    *TemplateStore
+	Interval time.Duration
+}
+
+// HandlerFunc returns a gin handler streaming a reload event once the store gets updated after
+// the connection
+func (l *LiveReload) HandlerFunc() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		since := l.Store.UpdatedAt()
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-store")
+		c.Status(http.StatusOK)
+		c.Writer.WriteString(": connected\n\n")
+		c.Writer.Flush()
+
+		ticker := time.NewTicker(l.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.Request.Context().Done():
+				return
+			case <-ticker.C:
+			}
+			if l.Store.UpdatedAt().After(since) {
+				c.Writer.WriteString("event: reload\ndata: {}\n\n")
+				c.Writer.Flush()
+				return
+			}
+		}
+	}
+}
+
+// injectLiveReload returns the rendered page with the live reload script added before the end
+// of its body, or at the end of the page if it has no body
+func injectLiveReload(c *gin.Context, body []byte) []byte {
+	nonce := ""
+	if n := CSPNonce(c); n != "" {
+		nonce = ` nonce="` + html.EscapeString(n) + `"`
+	}
+	script := []byte(fmt.Sprintf(liveReloadScript, nonce, LiveReloadPath))
+	i := bytes.LastIndex(bytes.ToLower(body), []byte("</body>"))
+	if i < 0 {
+		return append(append([]byte{}, body...), script...)
+	}
+	res := make([]byte, 0, len(body)+len(script))
+	res = append(res, body[:i]...)
+	res = append(res, script...)
+	return append(res, body[i:]...)
+}
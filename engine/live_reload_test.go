@@ -0,0 +1,56 @@
+package engine
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestInjectLiveReload(t *testing.T) {
+	script := `<script>(function(){var s=new EventSource("` + LiveReloadPath + `");`
+	for _, tc := range []struct {
+		body, prefix, suffix string
+	}{
+		{"<html><body>hi</body></html>", "<html><body>hi" + script, "</script></body></html>"},
+		{"<HTML><BODY>hi</BODY></HTML>", "<HTML><BODY>hi" + script, "</script></BODY></HTML>"},
+		{"hi", "hi" + script, "</script>"},
+	} {
+		res := string(injectLiveReload(nil, []byte(tc.body)))
+		if !strings.HasPrefix(res, tc.prefix) || !strings.HasSuffix(res, tc.suffix) {
+			t.Errorf("unexpected result: %s", res)
+		}
+	}
+}
+
+func TestLiveReload(t *testing.T) {
+	store := NewTemplateStore()
+	l := NewLiveReload(store)
+	l.Interval = 10 * time.Millisecond
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.GET(LiveReloadPath, l.HandlerFunc())
+	ts := httptest.NewServer(e)
+	defer ts.Close()
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		store.Set("home", EmptyRenderer)
+	}()
+	resp, err := http.Get(ts.URL + LiveReloadPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Errorf("unexpected content type: %s", resp.Header.Get("Content-Type"))
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	if !strings.HasSuffix(string(body), "event: reload\ndata: {}\n\n") {
+		t.Errorf("unexpected body: %s", body)
+	}
+}
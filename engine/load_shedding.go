@@ -0,0 +1,85 @@
+package engine
+
+import (
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const heapSampleInterval = time.Second
+
+// NewLoadShedder creates a LoadShedder with the received config
+func NewLoadShedder(cfg LoadShedding) *LoadShedder {
+	s := &LoadShedder{
+		MaxHeapBytes: uint64(cfg.MaxHeapMB) << 20,
+		ReadHeap:     readHeapAlloc,
+	}
+	if cfg.MaxInFlight > 0 {
+		s.limiter = NewConcurrencyLimiter(PageConcurrency{MaxInFlight: cfg.MaxInFlight, QueueTimeout: cfg.QueueTimeout})
+	}
+	return s
+}
+
+// LoadShedder rejects the requests with a lightweight 503 response when the server is
+// saturated, so the latency stays bounded during the traffic spikes. The server is saturated
+// when the in-flight requests can not get a slot during the queue timeout or when the heap
+// exceeds the MaxHeapBytes
+type LoadShedder struct {
+	// MaxHeapBytes is the max size of the allocated heap. If zero, the memory is not checked
+	MaxHeapBytes uint64
+	// ReadHeap returns the size of the allocated heap
+	ReadHeap func() uint64
+	limiter  *ConcurrencyLimiter
+	mutex    sync.Mutex
+	heap     uint64
+	sampled  time.Time
+}
+
+// HandlerFunc returns a gin middleware shedding the requests received while saturated
+func (s *LoadShedder) HandlerFunc() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.overHeap() {
+			shed(c)
+			return
+		}
+		if s.limiter == nil {
+			c.Next()
+			return
+		}
+		if !s.limiter.acquire(c) {
+			shed(c)
+			return
+		}
+		defer s.limiter.release()
+		c.Next()
+	}
+}
+
+// overHeap checks the heap size, sampled at most once per second because reading the memory
+// stats stops the world
+func (s *LoadShedder) overHeap() bool {
+	if s.MaxHeapBytes == 0 {
+		return false
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if now := time.Now(); now.Sub(s.sampled) >= heapSampleInterval {
+		s.heap = s.ReadHeap()
+		s.sampled = now
+	}
+	return s.heap > s.MaxHeapBytes
+}
+
+func shed(c *gin.Context) {
+	c.Header("Retry-After", "1")
+	c.AbortWithStatus(http.StatusServiceUnavailable)
+}
+
+func readHeapAlloc() uint64 {
+	stats := runtime.MemStats{}
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
@@ -0,0 +1,61 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestLoadShedder_heap(t *testing.T) {
+	heap := uint64(0)
+	s := NewLoadShedder(LoadShedding{MaxHeapMB: 1})
+	s.ReadHeap = func() uint64 { return heap }
+
+	e := gin.New()
+	e.Use(s.HandlerFunc())
+	e.GET("/", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+
+	assertResponse(t, e, "/", http.StatusOK, "ok")
+
+	// the heap is not sampled again until the interval expires
+	heap = 2 << 20
+	assertResponse(t, e, "/", http.StatusOK, "ok")
+
+	s.sampled = s.sampled.Add(-heapSampleInterval)
+	assertResponse(t, e, "/", http.StatusServiceUnavailable, "")
+
+	heap = 1 << 20
+	s.sampled = s.sampled.Add(-heapSampleInterval)
+	assertResponse(t, e, "/", http.StatusOK, "ok")
+}
+
+func TestLoadShedder_inFlight(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	e := gin.New()
+	e.Use(NewLoadShedder(LoadShedding{MaxInFlight: 1, QueueTimeout: "10ms"}).HandlerFunc())
+	e.GET("/slow", func(c *gin.Context) {
+		close(started)
+		<-release
+		c.String(http.StatusOK, "slow")
+	})
+	e.GET("/fast", func(c *gin.Context) { c.String(http.StatusOK, "fast") })
+
+	done := make(chan string)
+	go func() {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/slow", nil)
+		e.ServeHTTP(w, req)
+		done <- w.Body.String()
+	}()
+	<-started
+
+	assertResponse(t, e, "/fast", http.StatusServiceUnavailable, "")
+	close(release)
+	if body := <-done; body != "slow" {
+		t.Errorf("unexpected body: %s", body)
+	}
+	assertResponse(t, e, "/fast", http.StatusOK, "fast")
+}
@@ -0,0 +1,101 @@
+package engine
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+var (
+	markdown       = goldmark.New(goldmark.WithExtensions(extension.GFM))
+	markdownPolicy = bluemonday.UGCPolicy()
+)
+
+// MarkdownToHTML converts the received markdown into HTML, removing the elements and attributes
+// that are not safe for the user generated content (scripts, event handlers, javascript links...)
+func MarkdownToHTML(src string) (string, error) {
+	buf := &bytes.Buffer{}
+	if err := markdown.Convert([]byte(src), buf); err != nil {
+		return "", err
+	}
+	return markdownPolicy.Sanitize(buf.String()), nil
+}
+
+// withMarkdown wraps the ResponseGenerator converting the markdown fields of the page into HTML
+func withMarkdown(page Page, rg ResponseGenerator) ResponseGenerator {
+	if len(page.Markdown) == 0 {
+		return rg
+	}
+	return func(c *gin.Context) (ResponseContext, error) {
+		result, err := rg(c)
+		if err != nil {
+			return result, err
+		}
+		for _, path := range page.Markdown {
+			if err = transformFields(result, path, MarkdownToHTML); err != nil {
+				return result, err
+			}
+		}
+		return result, nil
+	}
+}
+
+// transformFields replaces the string fields of the data of the response context found at the
+// received path (author.bio) with the result of the transformation. The arrays found along the
+// path are traversed, so the path is applied to every element of the Array of the response too
+func transformFields(result ResponseContext, path string, f func(string) (string, error)) error {
+	keys := strings.Split(path, ".")
+	if result.Data != nil {
+		if err := transformField(result.Data, keys, f); err != nil {
+			return err
+		}
+	}
+	for _, item := range result.Array {
+		if err := transformField(item, keys, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func transformField(v interface{}, keys []string, f func(string) (string, error)) error {
+	switch v := v.(type) {
+	case []interface{}:
+		for _, item := range v {
+			if err := transformField(item, keys, f); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		value, ok := v[keys[0]]
+		if !ok {
+			return nil
+		}
+		if len(keys) > 1 {
+			return transformField(value, keys[1:], f)
+		}
+		switch value := value.(type) {
+		case string:
+			res, err := f(value)
+			if err != nil {
+				return err
+			}
+			v[keys[0]] = res
+		case []interface{}:
+			for i, item := range value {
+				if s, ok := item.(string); ok {
+					res, err := f(s)
+					if err != nil {
+						return err
+					}
+					value[i] = res
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMarkdownToHTML(t *testing.T) {
+	for src, expected := range map[string]string{
+		"# Title":                                "<h1>Title</h1>\n",
+		"some **bold** text":                     "<p>some <strong>bold</strong> text</p>\n",
+		"[link](http://example.com)":             "<p><a href=\"http://example.com\" rel=\"nofollow\">link</a></p>\n",
+		"[xss](javascript:alert(1))":             "<p>xss</p>\n",
+		"<script>alert(1)</script>":              "\n",
+		"hello <img src=x onerror=alert(1)> bye": "<p>hello  bye</p>\n",
+	} {
+		res, err := MarkdownToHTML(src)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if res != expected {
+			t.Errorf("%q: unexpected html: %q", src, res)
+		}
+	}
+}
+
+func TestWithMarkdown(t *testing.T) {
+	page := Page{Markdown: []string{"body", "author.bio", "comments.text", "tags", "missing.field"}}
+	rg := withMarkdown(page, func(_ *gin.Context) (ResponseContext, error) {
+		return ResponseContext{
+			Data: map[string]interface{}{
+				"title":  "*title*",
+				"body":   "*body*",
+				"author": map[string]interface{}{"bio": "*bio*"},
+				"comments": []interface{}{
+					map[string]interface{}{"text": "*a*"},
+					map[string]interface{}{"text": "*b*"},
+				},
+				"tags": []interface{}{"*tag*", 1},
+			},
+			Array: []map[string]interface{}{{"body": "*item*"}},
+		}, nil
+	})
+	result, err := rg(nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for _, tc := range []struct {
+		value    interface{}
+		expected string
+	}{
+		{result.Data["title"], "*title*"},
+		{result.Data["body"], "<p><em>body</em></p>\n"},
+		{result.Data["author"].(map[string]interface{})["bio"], "<p><em>bio</em></p>\n"},
+		{result.Data["comments"].([]interface{})[1].(map[string]interface{})["text"], "<p><em>b</em></p>\n"},
+		{result.Data["tags"].([]interface{})[0], "<p><em>tag</em></p>\n"},
+		{result.Array[0]["body"], "<p><em>item</em></p>\n"},
+	} {
+		if tc.value != tc.expected {
+			t.Errorf("unexpected value: %v", tc.value)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package engine
+
+import (
+	"bytes"
+	"io"
+)
+
+// rawHTMLElements are the elements whose content is kept as it is by the minifier
+var rawHTMLElements = [][]byte{[]byte("pre"), []byte("textarea"), []byte("script"), []byte("style")}
+
+// MinifiedRenderer is a Renderer decorator minifying the HTML rendered by the decorated one
+type MinifiedRenderer struct {
+	Renderer Renderer
+}
+
+// Render implements the Renderer interface
+func (m MinifiedRenderer) Render(w io.Writer, v interface{}) error {
+	buf := &bytes.Buffer{}
+	if err := m.Renderer.Render(buf, v); err != nil {
+		return err
+	}
+	_, err := w.Write(MinifyHTML(buf.Bytes()))
+	return err
+}
+
+// MinifyHTML collapses the runs of whitespace into a single space and strips the comments of the
+// received HTML. The conditional comments and the content of the pre, textarea, script and style
+// elements are kept as they are
+func MinifyHTML(src []byte) []byte {
+	out := make([]byte, 0, len(src))
+	for i := 0; i < len(src); {
+		switch {
+		case bytes.HasPrefix(src[i:], []byte("<!--")) && !bytes.HasPrefix(src[i+4:], []byte("[if")):
+			end := bytes.Index(src[i+4:], []byte("-->"))
+			if end < 0 {
+				return out
+			}
+			i += 4 + end + 3
+
+		case src[i] == '<':
+			if name := rawHTMLElement(src[i+1:]); name != nil {
+				end := indexFold(src[i:], append([]byte("</"), name...))
+				if end < 0 {
+					return append(out, src[i:]...)
+				}
+				out = append(out, src[i:i+end]...)
+				i += end
+				continue
+			}
+			out = append(out, src[i])
+			i++
+
+		case isHTMLSpace(src[i]):
+			for i < len(src) && isHTMLSpace(src[i]) {
+				i++
+			}
+			if len(out) > 0 && out[len(out)-1] != ' ' && i < len(src) {
+				out = append(out, ' ')
+			}
+
+		default:
+			out = append(out, src[i])
+			i++
+		}
+	}
+	return out
+}
+
+// rawHTMLElement returns the name of the raw element opened by the tag starting at b, if any
+func rawHTMLElement(b []byte) []byte {
+	for _, name := range rawHTMLElements {
+		if len(b) > len(name) && bytes.EqualFold(b[:len(name)], name) {
+			if c := b[len(name)]; c == '>' || c == '/' || isHTMLSpace(c) {
+				return name
+			}
+		}
+	}
+	return nil
+}
+
+func indexFold(b, sep []byte) int {
+	return bytes.Index(bytes.ToLower(b), sep)
+}
+
+func isHTMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
@@ -0,0 +1,72 @@
+package engine
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestMinifyHTML(t *testing.T) {
+	for i, tc := range []struct {
+		in       string
+		expected string
+	}{
+		{
+			in:       "\n<html>\n  <body>\n    <p>hello   world</p>\n  </body>\n</html>\n",
+			expected: "<html> <body> <p>hello world</p> </body> </html>",
+		},
+		{
+			in:       "<p>a <!-- a comment --> b</p><!--[if IE]><p>IE</p><![endif]-->",
+			expected: "<p>a b</p><!--[if IE]><p>IE</p><![endif]-->",
+		},
+		{
+			in:       "<div>\n  <pre>  keep\n   this </pre>\n  <textarea>  and\n this</textarea>\n</div>",
+			expected: "<div> <pre>  keep\n   this </pre> <textarea>  and\n this</textarea> </div>",
+		},
+		{
+			in:       "<script>\n  var a = 1; // <!-- not a comment -->\n</SCRIPT>\n<style> p  { } </style>",
+			expected: "<script>\n  var a = 1; // <!-- not a comment -->\n</SCRIPT> <style> p  { } </style>",
+		},
+		{
+			in:       "<p>a</p>\n<preview>  b  </preview>",
+			expected: "<p>a</p> <preview> b </preview>",
+		},
+		{
+			in:       "<p>a</p><!-- unclosed",
+			expected: "<p>a</p>",
+		},
+	} {
+		if res := string(MinifyHTML([]byte(tc.in))); res != tc.expected {
+			t.Errorf("#%d: unexpected result: %q", i, res)
+		}
+	}
+}
+
+func TestMinifiedRenderer(t *testing.T) {
+	r := MinifiedRenderer{RendererFunc(func(w io.Writer, _ interface{}) error {
+		_, err := w.Write([]byte("<p>\n  a\n</p>"))
+		return err
+	})}
+	buf := &bytes.Buffer{}
+	if err := r.Render(buf, nil); err != nil {
+		t.Error(err)
+		return
+	}
+	if buf.String() != "<p> a </p>" {
+		t.Errorf("unexpected result: %q", buf.String())
+	}
+
+	expectedErr := errors.New("expect me")
+	r = MinifiedRenderer{RendererFunc(func(w io.Writer, _ interface{}) error {
+		w.Write([]byte("<p>partial"))
+		return expectedErr
+	})}
+	buf.Reset()
+	if err := r.Render(buf, nil); err != expectedErr {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("unexpected content: %q", buf.String())
+	}
+}
@@ -0,0 +1,37 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// MsgpackEncoding is the encoding of the pages whose backends return msgpack payloads
+const MsgpackEncoding = "msgpack"
+
+// MsgpackDecoder decodes the msgpack reader content and puts it into the Data property of the
+// injected ResponseContext if it is a map or into its Array property if it is an array of maps
+func MsgpackDecoder(r io.Reader, c *ResponseContext) error {
+	var target interface{}
+	if err := msgpack.NewDecoder(r).Decode(&target); err != nil {
+		return err
+	}
+	switch v := target.(type) {
+	case map[string]interface{}:
+		c.Data = v
+		return nil
+	case []interface{}:
+		array := make([]map[string]interface{}, len(v))
+		for i, item := range v {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("unexpected msgpack array item: %T", item)
+			}
+			array[i] = m
+		}
+		c.Array = array
+		return nil
+	}
+	return fmt.Errorf("unexpected msgpack payload: %T", target)
+}
@@ -0,0 +1,37 @@
+package engine
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestMsgpackDecoder(t *testing.T) {
+	data, _ := msgpack.Marshal(map[string]interface{}{"a": map[string]interface{}{"b": 1}})
+	c := &ResponseContext{}
+	if err := MsgpackDecoder(bytes.NewReader(data), c); err != nil {
+		t.Error(err)
+		return
+	}
+	if a, ok := c.Data["a"].(map[string]interface{}); !ok || a["b"] == nil {
+		t.Errorf("unexpected data: %v", c.Data)
+	}
+
+	data, _ = msgpack.Marshal([]interface{}{map[string]interface{}{"a": "b"}, map[string]interface{}{"a": "c"}})
+	c = &ResponseContext{}
+	if err := MsgpackDecoder(bytes.NewReader(data), c); err != nil {
+		t.Error(err)
+		return
+	}
+	if len(c.Array) != 2 || c.Array[1]["a"] != "c" {
+		t.Errorf("unexpected array: %v", c.Array)
+	}
+
+	for _, v := range []interface{}{[]interface{}{1, 2}, "a"} {
+		data, _ = msgpack.Marshal(v)
+		if err := MsgpackDecoder(bytes.NewReader(data), &ResponseContext{}); err == nil {
+			t.Errorf("expecting an error decoding %v", v)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package engine
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NewMultiBackendResponseGenerator returns a MultiBackendResponseGenerator wrapping the received
+// ResponseGenerator with the backends declared by the page
+func NewMultiBackendResponseGenerator(page Page, base ResponseGenerator) *MultiBackendResponseGenerator {
+	backends := make(map[string]Backend, len(page.Backends))
+	decoders := make(map[string]Decoder, len(page.Backends))
+	for name, cfg := range page.Backends {
+		client := pageClient(page)
+		if d, err := time.ParseDuration(cfg.Timeout); err == nil && d > 0 {
+			client = &http.Client{Transport: client.Transport, Timeout: d}
+		}
+		backends[name] = NewBackend(client, cfg.URLPattern)
+		decoder, err := backendDecoder(page, cfg)
+		if err != nil {
+			log.Println(page.Name, name, err.Error())
+			decoder = JSONAutoDecoder
+		}
+		decoders[name] = decoder
+	}
+	budget, _ := time.ParseDuration(page.Budget)
+	return &MultiBackendResponseGenerator{
+		Page:     page,
+		Base:     base,
+		Backends: backends,
+		Decoders: decoders,
+		Budget:   budget,
+	}
+}
+
+// MultiBackendResponseGenerator is a ResponseGenerator adding the decoded responses of several
+// named backends, requested concurrently, to the Data of the response created by the Base one.
+// The objects and arrays returned by every backend are stored under its name.
+//
+// If the Budget is set, the backends not responding before it expires, counted from the start of
+// the request, are flagged as Missing and the response is returned without them
+type MultiBackendResponseGenerator struct {
+	Page     Page
+	Base     ResponseGenerator
+	Backends map[string]Backend
+	Decoders map[string]Decoder
+	Budget   time.Duration
+}
+
+type namedBackendResult struct {
+	name  string
+	value interface{}
+	err   error
+}
+
+// ResponseGenerator implements the ResponseGenerator interface
+func (m *MultiBackendResponseGenerator) ResponseGenerator(c *gin.Context) (ResponseContext, error) {
+	start := time.Now()
+	result, err := m.Base(c)
+	if err != nil {
+		return result, err
+	}
+
+	params := requestParams(c)
+	headers := backendHeaders(m.Page, c)
+
+	// the backends exceeding the budget are cancelled, and they get a copy of the context
+	// because they can outlive the request
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	if m.Budget > 0 {
+		ctx, cancel = context.WithTimeout(c.Request.Context(), m.Budget-time.Since(start))
+	}
+	defer cancel()
+	backendContext := c.Copy()
+	backendContext.Request = c.Request.WithContext(ctx)
+
+	// the channel is buffered, so the backends exceeding the budget do not block
+	results := make(chan namedBackendResult, len(m.Backends))
+	pending := make(map[string]bool, len(m.Backends))
+	for name, backend := range m.Backends {
+		pending[name] = true
+		go func(name string, backend Backend, decoder Decoder) {
+			value, err := m.fetch(backend, decoder, params, headers, backendContext)
+			results <- namedBackendResult{name, value, err}
+		}(name, backend, m.Decoders[name])
+	}
+
+	if result.Data == nil {
+		result.Data = map[string]interface{}{}
+	}
+	for len(pending) > 0 {
+		select {
+		case r := <-results:
+			if r.err != nil && ctx.Err() == nil {
+				return result, r.err
+			}
+			if r.err != nil {
+				// the backend was cancelled, so the expiration is handled by the next case
+				continue
+			}
+			delete(pending, r.name)
+			result.Data[r.name] = r.value
+		case <-ctx.Done():
+			if ctx.Err() != context.DeadlineExceeded {
+				return result, ctx.Err()
+			}
+			result.Missing = pending
+			for name := range pending {
+				log.Println(m.Page.Name, "the backend", name, "exceeded the budget of", m.Budget)
+			}
+			return result, nil
+		}
+	}
+	return result, nil
+}
+
+func (m *MultiBackendResponseGenerator) fetch(backend Backend, decoder Decoder, params, headers map[string]string, c *gin.Context) (interface{}, error) {
+	resp, err := backend(params, headers, c)
+	if err != nil {
+		return nil, newBackendError(err)
+	}
+	var target ResponseContext
+	err = decoder(resp.Body, &target)
+	resp.Body.Close()
+	if err != nil {
+		return nil, newDecodeError(resp, err)
+	}
+	if target.Array != nil {
+		return target.Array, nil
+	}
+	return target.Data, nil
+}
@@ -0,0 +1,188 @@
+package engine
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMultiBackendResponseGenerator(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/users/42":
+			fmt.Fprint(w, `{"name":"Jane"}`)
+		case "/users/42/orders":
+			fmt.Fprint(w, `[{"id":1},{"id":2}]`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	page := Page{
+		Extra: map[string]interface{}{"a": 42.0},
+		Backends: map[string]PageBackend{
+			"user":   {URLPattern: ts.URL + "/users/:first"},
+			"orders": {URLPattern: ts.URL + "/users/:first/orders", Timeout: "1s"},
+		},
+	}
+	static := StaticResponseGenerator{page}
+	subject := NewMultiBackendResponseGenerator(page, static.ResponseGenerator)
+
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.GET("/:first/:second", func(c *gin.Context) {
+		resp, err := subject.ResponseGenerator(c)
+		if err != nil {
+			t.Error("unexpected error:", err.Error())
+			return
+		}
+		if user, ok := resp.Data["user"].(map[string]interface{}); !ok || user["name"] != "Jane" {
+			t.Errorf("unexpected user: %v", resp.Data["user"])
+		}
+		if orders, ok := resp.Data["orders"].([]map[string]interface{}); !ok || len(orders) != 2 {
+			t.Errorf("unexpected orders: %v", resp.Data["orders"])
+		}
+		c.Status(200)
+	})
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/42/bar", nil)
+	e.ServeHTTP(w, r)
+	if w.Result().StatusCode != 200 {
+		t.Errorf("unexpected status code: %d", w.Result().StatusCode)
+	}
+}
+
+func TestMultiBackendResponseGenerator_timeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		fmt.Fprint(w, `{}`)
+	}))
+	defer ts.Close()
+
+	page := Page{Backends: map[string]PageBackend{"slow": {URLPattern: ts.URL + "/slow", Timeout: "10ms"}}}
+	static := StaticResponseGenerator{page}
+	subject := NewMultiBackendResponseGenerator(page, static.ResponseGenerator)
+
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.GET("/", func(c *gin.Context) {
+		_, err := subject.ResponseGenerator(c)
+		if status := ErrorStatusCode(err); status != http.StatusGatewayTimeout {
+			t.Errorf("unexpected status code: %d (%v)", status, err)
+		}
+		c.Status(200)
+	})
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+	e.ServeHTTP(w, r)
+}
+
+func TestMultiBackendResponseGenerator_budget(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		fmt.Fprint(w, `{"a": 1}`)
+	}))
+	defer ts.Close()
+
+	page := Page{
+		Budget: "50ms",
+		Backends: map[string]PageBackend{
+			"fast": {URLPattern: ts.URL + "/fast"},
+			"slow": {URLPattern: ts.URL + "/slow"},
+		},
+	}
+	static := StaticResponseGenerator{page}
+	subject := NewMultiBackendResponseGenerator(page, static.ResponseGenerator)
+
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.GET("/", func(c *gin.Context) {
+		start := time.Now()
+		result, err := subject.ResponseGenerator(c)
+		if err != nil {
+			t.Error(err)
+		}
+		if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+			t.Errorf("the budget was not honored: %s", elapsed)
+		}
+		if result.Data["fast"] == nil || result.Data["slow"] != nil {
+			t.Errorf("unexpected data: %v", result.Data)
+		}
+		if len(result.Missing) != 1 || !result.Missing["slow"] {
+			t.Errorf("unexpected missing backends: %v", result.Missing)
+		}
+		c.Status(200)
+	})
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+	e.ServeHTTP(w, r)
+}
+
+func TestMultiBackendResponseGenerator_budgetCancellation(t *testing.T) {
+	cancelled := make(chan struct{}, 5)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			select {
+			case <-r.Context().Done():
+				cancelled <- struct{}{}
+				return
+			case <-time.After(time.Second):
+			}
+		}
+		fmt.Fprint(w, `{"a": 1}`)
+	}))
+	defer ts.Close()
+
+	page := Page{
+		Budget: "20ms",
+		Backends: map[string]PageBackend{
+			"fast": {URLPattern: ts.URL + "/fast"},
+			"slow": {URLPattern: ts.URL + "/slow"},
+		},
+	}
+	static := StaticResponseGenerator{page}
+	subject := NewMultiBackendResponseGenerator(page, static.ResponseGenerator)
+
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(func(c *gin.Context) {
+		c.Set("user", "someone")
+		c.Next()
+	})
+	e.GET("/", func(c *gin.Context) {
+		result, err := subject.ResponseGenerator(c)
+		if err != nil {
+			t.Error(err)
+		}
+		if !result.Missing["slow"] {
+			t.Errorf("unexpected missing backends: %v", result.Missing)
+		}
+		c.Status(200)
+	})
+
+	// the contexts of the finished requests are recycled by the next ones while the slow
+	// backends are still running
+	for i := 0; i < cap(cancelled); i++ {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/", nil)
+		e.ServeHTTP(w, r)
+	}
+	for i := 0; i < cap(cancelled); i++ {
+		select {
+		case <-cancelled:
+		case <-time.After(500 * time.Millisecond):
+			t.Error("the slow backend was not cancelled when the budget expired")
+			return
+		}
+	}
+}
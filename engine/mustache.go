@@ -2,9 +2,9 @@ package engine
 
 import (
 	"io"
+	"io/fs"
 	"io/ioutil"
 	"log"
-	"os"
 
 	"github.com/cbroglie/mustache"
 )
@@ -12,15 +12,23 @@ import (
 // NewMustacheRendererMap returns a map with all renderers for the declared templates and layouts
 // and an error if something went wrong
 func NewMustacheRendererMap(cfg Config) (map[string]*MustacheRenderer, error) {
+	return NewMustacheRendererMapFS(nil, cfg)
+}
+
+// NewMustacheRendererMapFS returns a map with all renderers for the declared templates and layouts,
+// reading them and their partials from the received fs.FS, and an error if something went wrong.
+// If the fs.FS is nil, the local filesystem is used. The renderers get the helpers and the
+// partial caches of the engine of the config
+func NewMustacheRendererMapFS(fsys fs.FS, cfg Config) (map[string]*MustacheRenderer, error) {
 	result := map[string]*MustacheRenderer{}
 	for _, section := range []map[string]string{cfg.Templates, cfg.Layouts} {
 		for name, path := range section {
-			templateFile, err := os.Open(path)
+			templateFile, err := openFile(fsys, path)
 			if err != nil {
 				log.Println("reading", path, ":", err.Error())
 				return result, err
 			}
-			renderer, err := NewMustacheRenderer(templateFile)
+			renderer, err := newMustacheRenderer(fsys, templateFile, cfg.registry)
 			templateFile.Close()
 			if err != nil {
 				log.Println("parsing", path, ":", err.Error())
@@ -34,61 +42,103 @@ func NewMustacheRendererMap(cfg Config) (map[string]*MustacheRenderer, error) {
 
 // NewMustacheRenderer returns a MustacheRenderer and an error if something went wrong
 func NewMustacheRenderer(r io.Reader) (*MustacheRenderer, error) {
-	tmpl, err := newMustacheTemplate(r)
+	return NewMustacheRendererFS(nil, r)
+}
+
+// NewMustacheRendererFS returns a MustacheRenderer resolving its partials from the received
+// fs.FS and an error if something went wrong. If the fs.FS is nil, the local filesystem is used
+func NewMustacheRendererFS(fsys fs.FS, r io.Reader) (*MustacheRenderer, error) {
+	return newMustacheRenderer(fsys, r, nil)
+}
+
+// newMustacheRenderer returns a MustacheRenderer with the helpers and the partial caches of the
+// received registry
+func newMustacheRenderer(fsys fs.FS, r io.Reader, reg *registry) (*MustacheRenderer, error) {
+	tmpl, err := newMustacheTemplate(r, newPartialProvider(fsys, reg))
 	if err != nil {
 		return nil, err
 	}
-	return &MustacheRenderer{tmpl}, nil
+	return &MustacheRenderer{tmpl, reg}, nil
 }
 
 // MustacheRenderer is a simple mustache renderer with a single mustache template
 type MustacheRenderer struct {
-	tmpl *mustache.Template
+	tmpl     *mustache.Template
+	registry *registry
 }
 
-// Render implements the renderer interface
+// Render implements the renderer interface. The registered helpers are available as lambdas
 func (m MustacheRenderer) Render(w io.Writer, v interface{}) error {
-	return m.tmpl.FRender(w, v)
+	return m.tmpl.FRender(w, v, m.registry.helperContext())
 }
 
 // NewLayoutMustacheRenderer returns a LayoutMustacheRenderer and an error if something went wrong
 func NewLayoutMustacheRenderer(t, l io.Reader) (*LayoutMustacheRenderer, error) {
-	tmpl, err := newMustacheTemplate(t)
+	tmpl, err := newMustacheTemplate(t, customPartialProvider)
 	if err != nil {
 		return nil, err
 	}
-	layout, err := newMustacheTemplate(l)
+	layout, err := newMustacheTemplate(l, customPartialProvider)
 	if err != nil {
 		return nil, err
 	}
-	return &LayoutMustacheRenderer{tmpl, layout}, nil
+	return &LayoutMustacheRenderer{tmpl, layout, nil}, nil
 }
 
 // LayoutMustacheRenderer is a mustache renderer composing a mustache template with a layout
 type LayoutMustacheRenderer struct {
-	tmpl   *mustache.Template
-	layout *mustache.Template
+	tmpl     *mustache.Template
+	layout   *mustache.Template
+	registry *registry
 }
 
-// Render implements the renderer interface
+// Render implements the renderer interface. The registered helpers are available as lambdas
 func (m LayoutMustacheRenderer) Render(w io.Writer, v interface{}) error {
-	return m.tmpl.FRenderInLayout(w, m.layout, v)
+	return m.tmpl.FRenderInLayout(w, m.layout, v, m.registry.helperContext())
+}
+
+// newLayoutRenderer returns the composition of the received template and layout renderers
+func newLayoutRenderer(t, l *MustacheRenderer) *LayoutMustacheRenderer {
+	return &LayoutMustacheRenderer{t.tmpl, l.tmpl, t.registry}
 }
 
-func newMustacheTemplate(r io.Reader) (*mustache.Template, error) {
+func newMustacheTemplate(r io.Reader, provider mustache.PartialProvider) (*mustache.Template, error) {
 	data, err := ioutil.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
-	return mustache.ParseStringPartials(string(data), customPartialProvider)
+	return mustache.ParseStringPartials(string(data), provider)
+}
+
+func newPartialProvider(fsys fs.FS, reg *registry) mustache.PartialProvider {
+	dynamc := customPartialProvider.dynamc
+	if fsys != nil {
+		dynamc = fsPartialProvider{fsys}
+	}
+	return &partialProvider{
+		dynamc:   dynamc,
+		statics:  customPartialProvider.statics,
+		registry: reg,
+	}
 }
 
 type partialProvider struct {
-	statics mustache.PartialProvider
-	dynamc  mustache.PartialProvider
+	statics  mustache.PartialProvider
+	dynamc   mustache.PartialProvider
+	registry *registry
 }
 
+// Get implements the mustache.PartialProvider interface. The cached partials are wrapped by the
+// section of their cache helper
 func (sp *partialProvider) Get(name string) (string, error) {
+	data, err := sp.get(name)
+	if err != nil {
+		return data, err
+	}
+	return sp.registry.cachedPartialText(name, data), nil
+}
+
+func (sp *partialProvider) get(name string) (string, error) {
 	if data, err := sp.statics.Get(name); err == nil && data != "" {
 		return data, nil
 	}
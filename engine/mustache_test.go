@@ -123,7 +123,7 @@ func TestNewMustacheRendererMap_koNoFile(t *testing.T) {
 func Test_newMustacheTemplate(t *testing.T) {
 	b := make([]byte, 1024)
 	rand.Read(b)
-	if _, err := newMustacheTemplate(iotest.TimeoutReader(bytes.NewBuffer(b))); err == nil {
+	if _, err := newMustacheTemplate(iotest.TimeoutReader(bytes.NewBuffer(b)), customPartialProvider); err == nil {
 		t.Error("expecting error!")
 	}
 }
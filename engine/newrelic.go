@@ -0,0 +1,58 @@
+package engine
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	newrelic "github.com/newrelic/go-agent"
+	nrgin "github.com/newrelic/go-agent/_integrations/nrgin/v1"
+)
+
+// NewNewRelicInstrumentation creates the New Relic application defined in the received config
+// and returns the gin middlewares starting the transactions and reporting the lifecycle hooks
+func NewNewRelicInstrumentation(cfg NewRelic, devel bool) (Instrumentation, error) {
+	nrCfg := newrelic.NewConfig(cfg.AppName, cfg.License)
+	if devel {
+		nrCfg.Logger = newrelic.NewDebugLogger(os.Stdout)
+	}
+	app, err := newrelic.NewApplication(nrCfg)
+	if err != nil {
+		return Instrumentation{}, err
+	}
+	return Instrumentation{
+		Middlewares: []gin.HandlerFunc{nrgin.Middleware(app), HooksMiddleware(NewRelicHooks{})},
+	}, nil
+}
+
+// NewRelicHooks is a Hooks implementation reporting to the New Relic transaction started by
+// the nrgin middleware
+type NewRelicHooks struct{}
+
+// OnRequest implements the Hooks interface by naming the transaction after the page
+func (NewRelicHooks) OnRequest(c *gin.Context, name string) {
+	if txn := nrgin.Transaction(c); txn != nil {
+		txn.SetName(name)
+	}
+}
+
+// OnBackendCall implements the Hooks interface by starting an external segment
+func (NewRelicHooks) OnBackendCall(c *gin.Context, req *http.Request) func(*http.Response, error) {
+	segment := newrelic.StartExternalSegment(nrgin.Transaction(c), req)
+	return func(resp *http.Response, _ error) {
+		segment.Response = resp
+		segment.End()
+	}
+}
+
+// OnDecode implements the Hooks interface by starting a Decoder segment
+func (NewRelicHooks) OnDecode(c *gin.Context) func(error) {
+	segment := newrelic.StartSegment(nrgin.Transaction(c), "Decoder")
+	return func(_ error) { segment.End() }
+}
+
+// OnRender implements the Hooks interface by starting a Render segment
+func (NewRelicHooks) OnRender(c *gin.Context) func(error) {
+	segment := newrelic.StartSegment(nrgin.Transaction(c), "Render")
+	return func(_ error) { segment.End() }
+}
@@ -0,0 +1,30 @@
+package engine
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNewRelicHooks_withoutTransaction(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.GET("/", HooksMiddleware(NewRelicHooks{}), func(c *gin.Context) {
+		hooks := HooksFromContext(c)
+		hooks.OnRequest(c, "name")
+		req, _ := http.NewRequest("GET", "http://example.com", nil)
+		hooks.OnBackendCall(c, req)(nil, nil)
+		hooks.OnDecode(c)(nil)
+		hooks.OnRender(c)(nil)
+		c.String(http.StatusOK, "ok")
+	})
+
+	assertResponse(t, e, "/", http.StatusOK, "ok")
+}
+
+func TestNewNewRelicInstrumentation_ko(t *testing.T) {
+	if _, err := NewNewRelicInstrumentation(NewRelic{AppName: "test", License: "invalid"}, false); err == nil {
+		t.Error("expecting error")
+	}
+}
@@ -0,0 +1,48 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// setOAuth2Clients sets the OAuth2 clients available to the pages of the registry. Every client
+// obtains its tokens with the client credentials flow, caching and refreshing them before they
+// expire
+func (r *registry) setOAuth2Clients(clients map[string]OAuth2Client) {
+	for name, cfg := range clients {
+		r.oauth2Sources[name] = NewOAuth2TokenSource(cfg)
+	}
+}
+
+// NewOAuth2TokenSource returns a TokenSource obtaining and refreshing the client credentials
+// tokens of the received client
+func NewOAuth2TokenSource(cfg OAuth2Client) oauth2.TokenSource {
+	c := clientcredentials.Config{
+		ClientID:       cfg.ClientID,
+		ClientSecret:   cfg.ClientSecret,
+		TokenURL:       cfg.TokenURL,
+		Scopes:         cfg.Scopes,
+		EndpointParams: map[string][]string{},
+	}
+	for k, v := range cfg.Params {
+		c.EndpointParams.Set(k, v)
+	}
+	return c.TokenSource(context.Background())
+}
+
+// oauth2Transport returns a RoundTripper adding the bearer tokens of the named client to the
+// requests sent through the received one
+func (r *registry) oauth2Transport(next http.RoundTripper, client string) (http.RoundTripper, error) {
+	var source oauth2.TokenSource
+	if r != nil {
+		source = r.oauth2Sources[client]
+	}
+	if source == nil {
+		return nil, fmt.Errorf("unknown oauth2 client: %s", client)
+	}
+	return &oauth2.Transport{Source: source, Base: next}, nil
+}
@@ -0,0 +1,68 @@
+package engine
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRegistry_setOAuth2Clients(t *testing.T) {
+	var tokenRequests int32
+	tokens := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&tokenRequests, 1)
+		r.ParseForm()
+		user, password, _ := r.BasicAuth()
+		if r.Form.Get("grant_type") != "client_credentials" || user != "id" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Form.Get("scope") != "read write" || r.Form.Get("audience") != "api" {
+			t.Errorf("unexpected token request: %v", r.Form)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"t0k3n","token_type":"bearer","expires_in":3600}`)
+	}))
+	defer tokens.Close()
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.Header.Get("Authorization"))
+	}))
+	defer backend.Close()
+
+	reg := newRegistry()
+	reg.setOAuth2Clients(map[string]OAuth2Client{
+		"internal": {
+			TokenURL:     tokens.URL,
+			ClientID:     "id",
+			ClientSecret: "secret",
+			Scopes:       []string{"read", "write"},
+			Params:       map[string]string{"audience": "api"},
+		},
+	})
+
+	client := pageClient(Page{Name: "a", OAuth2Client: "internal", registry: reg})
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(backend.URL)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != "Bearer t0k3n" {
+			t.Errorf("#%d: unexpected Authorization header: %s", i, body)
+		}
+	}
+	if n := atomic.LoadInt32(&tokenRequests); n != 1 {
+		t.Errorf("unexpected number of token requests: %d", n)
+	}
+
+	for _, r := range []*registry{nil, reg} {
+		if _, err := r.oauth2Transport(http.DefaultTransport, "unknown"); err == nil {
+			t.Error("error expected")
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/jmespath/go-jmespath"
+)
+
+// RenderPage writes the named page of the config rendered with the received backend data, as
+// its handler would render the backend response, but without requesting the backend nor
+// serving any request. The data path of the page is applied to the data and the helpers
+// declared in the config are available to the templates, so template authors can iterate over
+// a local fixture and the CI can snapshot the output
+func RenderPage(w io.Writer, cfg Config, name string, data []byte, params map[string]string) error {
+	page, ok := configPage(cfg, name)
+	if !ok {
+		return fmt.Errorf("unknown page: %s", name)
+	}
+	reg := registeredComponents()
+	if err := reg.addHelpers(cfg.Helpers, nil); err != nil {
+		return err
+	}
+	cfg.registry = reg
+
+	result, err := fixtureContext(page, data)
+	if err != nil {
+		return err
+	}
+	result.Extra = page.Extra
+	result.Params = params
+	result.Robots = page.Robots
+	result.Helper = newTplHelper(nil)
+
+	r, err := offlineRenderer(cfg, page)
+	if err != nil {
+		return err
+	}
+	if page.Minify {
+		r = MinifiedRenderer{r}
+	}
+	return r.Render(w, result)
+}
+
+// configPage returns the page of the config with the received name
+func configPage(cfg Config, name string) (Page, bool) {
+	for _, page := range cfg.Pages {
+		if page.Name == name {
+			return page, true
+		}
+	}
+	return Page{}, false
+}
+
+// fixtureContext returns the ResponseContext with the JSON fixture decoded as the backend data
+// of the page
+func fixtureContext(page Page, data []byte) (ResponseContext, error) {
+	var v interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&v); err != nil {
+		return ResponseContext{}, fmt.Errorf("decoding the data: %s", err.Error())
+	}
+	if page.DataPath != "" {
+		res, err := jmespath.Search(page.DataPath, v)
+		if err != nil {
+			return ResponseContext{}, fmt.Errorf("applying the data path: %s", err.Error())
+		}
+		v = res
+	}
+	if _, isArray := v.([]interface{}); page.IsArray && !isArray && page.DataPath == "" {
+		return ResponseContext{}, fmt.Errorf("the page expects an array but the data is a %T", v)
+	}
+	result := ResponseContext{}
+	var err error
+	result.Data, result.Array, err = extractedData(v)
+	return result, err
+}
+
+// offlineRenderer parses just the template and the layout of the page, with the engine of the
+// page, and returns their composition
+func offlineRenderer(cfg Config, page Page) (Renderer, error) {
+	path, ok := cfg.Templates[page.Template]
+	if !ok {
+		return nil, fmt.Errorf("unknown template: %s", page.Template)
+	}
+	pageCfg := Config{
+		Templates: map[string]string{page.Template: path},
+		Layouts:   map[string]string{},
+		registry:  cfg.registry,
+	}
+	if page.Layout != "" {
+		path, ok := cfg.Layouts[page.Layout]
+		if !ok {
+			return nil, fmt.Errorf("unknown layout: %s", page.Layout)
+		}
+		pageCfg.Layouts[page.Layout] = path
+	}
+
+	if page.Engine == GoTemplateEngine {
+		renderers, err := NewGoTemplateRendererMapFS(nil, pageCfg, map[string]bool{page.Template: true, page.Layout: true})
+		if err != nil {
+			return nil, err
+		}
+		if page.Layout == "" {
+			return renderers[page.Template], nil
+		}
+		return NewGoLayoutRenderer(renderers[page.Template], renderers[page.Layout])
+	}
+
+	renderers, err := NewMustacheRendererMap(pageCfg)
+	if err != nil {
+		return nil, err
+	}
+	if page.Layout == "" {
+		return renderers[page.Template], nil
+	}
+	return newLayoutRenderer(renderers[page.Template], renderers[page.Layout]), nil
+}
@@ -0,0 +1,79 @@
+package engine
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestRenderPage(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"home.mustache":   "<h1>{{Extra.title}}</h1>{{#Array}}<p>{{name}} {{price}}</p>{{/Array}}{{Params.lang}}",
+		"layout.mustache": "<body>{{{content}}}</body>",
+		"item.tmpl":       `<p>{{ index .Data "name" }}</p>`,
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := Config{
+		Templates: map[string]string{
+			"home": filepath.Join(dir, "home.mustache"),
+			"item": filepath.Join(dir, "item.tmpl"),
+		},
+		Layouts: map[string]string{"main": filepath.Join(dir, "layout.mustache")},
+		Pages: []Page{
+			{Name: "home", Template: "home", Layout: "main", IsArray: true, Extra: map[string]interface{}{"title": "Shop"}},
+			{Name: "nested", Template: "home", IsArray: true, DataPath: "data.items", Extra: map[string]interface{}{"title": "Nested"}},
+			{Name: "item", Template: "item", Engine: GoTemplateEngine},
+			{Name: "broken", Template: "unknown"},
+		},
+	}
+
+	for _, tc := range []struct {
+		page     string
+		data     string
+		params   map[string]string
+		expected string
+	}{
+		{
+			page:     "home",
+			data:     `[{"name":"a","price":10.50},{"name":"b","price":3}]`,
+			params:   map[string]string{"lang": "en"},
+			expected: "<body><h1>Shop</h1><p>a 10.50</p><p>b 3</p>en</body>",
+		},
+		{
+			page:     "nested",
+			data:     `{"data":{"items":[{"name":"c","price":1}]}}`,
+			expected: "<h1>Nested</h1><p>c 1</p>",
+		},
+		{
+			page:     "item",
+			data:     `{"name":"<d>"}`,
+			expected: "<p>&lt;d&gt;</p>",
+		},
+	} {
+		buf := &bytes.Buffer{}
+		if err := RenderPage(buf, cfg, tc.page, []byte(tc.data), tc.params); err != nil {
+			t.Errorf("%s: unexpected error: %s", tc.page, err.Error())
+			continue
+		}
+		if buf.String() != tc.expected {
+			t.Errorf("%s: unexpected output: %s", tc.page, buf.String())
+		}
+	}
+
+	for page, data := range map[string]string{
+		"unknown": `{}`,
+		"broken":  `{}`,
+		"home":    `{"name":"a"}`,
+		"item":    `{`,
+	} {
+		if err := RenderPage(&bytes.Buffer{}, cfg, page, []byte(data), nil); err == nil {
+			t.Errorf("%s: expecting an error", page)
+		}
+	}
+}
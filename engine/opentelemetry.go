@@ -0,0 +1,145 @@
+package engine
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	defaultServiceName  = "api2html"
+	instrumentationName = "github.com/devopsfaith/api2html/engine"
+	// otelShutdownTimeout is the max time the pending spans have to be exported on close
+	otelShutdownTimeout = 5 * time.Second
+)
+
+// NewOpenTelemetryInstrumentation creates a tracer provider exporting the spans to the OTLP
+// collector defined in the received config and returns the gin middleware tracing the requests.
+// The provider is shut down, exporting the pending spans, by the closer of the instrumentation
+func NewOpenTelemetryInstrumentation(cfg OpenTelemetry) (Instrumentation, error) {
+	opts := []otlptracehttp.Option{}
+	if cfg.Endpoint != "" {
+		opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+	}
+	if cfg.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		return Instrumentation{}, err
+	}
+
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
+	sampler := sdktrace.AlwaysSample()
+	if cfg.SampleRatio > 0 && cfg.SampleRatio < 1 {
+		sampler = sdktrace.TraceIDRatioBased(cfg.SampleRatio)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		// the sampling decision of the inbound traces is kept
+		sdktrace.WithSampler(sdktrace.ParentBased(sampler)),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	)
+
+	hooks := NewOpenTelemetryHooks(provider)
+	shutdown := func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), otelShutdownTimeout)
+		defer cancel()
+		return provider.Shutdown(ctx)
+	}
+	return Instrumentation{Middlewares: []gin.HandlerFunc{hooks.HandlerFunc()}, Closers: []func() error{shutdown}}, nil
+}
+
+// NewOpenTelemetryHooks creates an OpenTelemetryHooks using the received tracer provider and
+// the W3C Trace Context and Baggage propagators
+func NewOpenTelemetryHooks(provider trace.TracerProvider) *OpenTelemetryHooks {
+	return &OpenTelemetryHooks{
+		tracer:     provider.Tracer(instrumentationName),
+		propagator: propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}),
+	}
+}
+
+// OpenTelemetryHooks is a Hooks implementation creating a span for every request and child
+// spans for the backend call, the decoding and the rendering steps. The request spans continue
+// the inbound traces and the backend calls carry their spans in the traceparent header
+type OpenTelemetryHooks struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+// HandlerFunc returns a gin middleware starting the request span and attaching the hooks
+func (o *OpenTelemetryHooks) HandlerFunc() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := o.propagator.Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		ctx, span := o.tracer.Start(ctx, c.Request.URL.Path, trace.WithSpanKind(trace.SpanKindServer))
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+		addHooks(c, o)
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(
+			attribute.String("http.request.method", c.Request.Method),
+			attribute.Int("http.response.status_code", status),
+		)
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
+
+// OnRequest implements the Hooks interface by naming the request span after the page
+func (o *OpenTelemetryHooks) OnRequest(c *gin.Context, name string) {
+	trace.SpanFromContext(c.Request.Context()).SetName(name)
+}
+
+// OnBackendCall implements the Hooks interface by starting a client span and propagating it to
+// the backend
+func (o *OpenTelemetryHooks) OnBackendCall(c *gin.Context, req *http.Request) func(*http.Response, error) {
+	ctx, span := o.tracer.Start(c.Request.Context(), "Backend", trace.WithSpanKind(trace.SpanKindClient))
+	span.SetAttributes(attribute.String("url.full", req.URL.String()))
+	o.propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+	return func(resp *http.Response, err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		} else if resp != nil {
+			span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+		}
+		span.End()
+	}
+}
+
+// OnDecode implements the Hooks interface by starting a Decoder span
+func (o *OpenTelemetryHooks) OnDecode(c *gin.Context) func(error) {
+	return o.startSpan(c, "Decoder")
+}
+
+// OnRender implements the Hooks interface by starting a Render span
+func (o *OpenTelemetryHooks) OnRender(c *gin.Context) func(error) {
+	return o.startSpan(c, "Render")
+}
+
+func (o *OpenTelemetryHooks) startSpan(c *gin.Context, name string) func(error) {
+	_, span := o.tracer.Start(c.Request.Context(), name)
+	return func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
@@ -0,0 +1,139 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestOpenTelemetryHooks(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	hooks := NewOpenTelemetryHooks(provider)
+
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"a":1}`)
+	}))
+	defer mockServer.Close()
+
+	rg := DynamicResponseGenerator{Page{}, DefaultClient(mockServer.URL), JSONDecoder}
+	h := &Handler{
+		Page: Page{Name: "page"},
+		Renderer: RendererFunc(func(w io.Writer, _ interface{}) error {
+			_, err := w.Write([]byte("ok"))
+			return err
+		}),
+		ResponseGenerator: rg.ResponseGenerator,
+	}
+
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(hooks.HandlerFunc())
+	e.GET("/", h.HandlerFunc)
+
+	assertResponse(t, e, "/", http.StatusOK, "ok")
+
+	spans := recorder.Ended()
+	if len(spans) != 4 {
+		t.Errorf("unexpected number of spans: %d", len(spans))
+		return
+	}
+	names := []string{}
+	var root sdktrace.ReadOnlySpan
+	for _, span := range spans {
+		names = append(names, span.Name())
+		if span.Name() == "page" {
+			root = span
+		}
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != "Backend,Decoder,Render,page" {
+		t.Errorf("unexpected spans: %v", names)
+	}
+	if root == nil {
+		t.Error("the request span has not been renamed after the page")
+		return
+	}
+	for _, span := range spans {
+		if span == root {
+			continue
+		}
+		if span.Parent().SpanID() != root.SpanContext().SpanID() {
+			t.Errorf("the span %s is not a child of the request span", span.Name())
+		}
+	}
+}
+
+func TestOpenTelemetryHooks_propagation(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	hooks := NewOpenTelemetryHooks(provider)
+
+	var traceparent string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		fmt.Fprint(w, `{"a":1}`)
+	}))
+	defer mockServer.Close()
+
+	rg := DynamicResponseGenerator{Page{}, DefaultClient(mockServer.URL), JSONDecoder}
+	h := &Handler{
+		Page: Page{Name: "page"},
+		Renderer: RendererFunc(func(w io.Writer, _ interface{}) error {
+			_, err := w.Write([]byte("ok"))
+			return err
+		}),
+		ResponseGenerator: rg.ResponseGenerator,
+	}
+
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(hooks.HandlerFunc())
+	e.GET("/", h.HandlerFunc)
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+
+	var backend sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("the span %s does not continue the inbound trace", span.Name())
+		}
+		if span.Name() == "Backend" {
+			backend = span
+		}
+	}
+	if backend == nil {
+		t.Error("backend span not found")
+		return
+	}
+	expected := "00-4bf92f3577b34da6a3ce929d0e0e4736-" + backend.SpanContext().SpanID().String() + "-01"
+	if traceparent != expected {
+		t.Errorf("unexpected traceparent: %s", traceparent)
+	}
+}
+
+func TestNewOpenTelemetryInstrumentation(t *testing.T) {
+	i, err := NewOpenTelemetryInstrumentation(OpenTelemetry{Endpoint: "localhost:4318", Insecure: true})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if len(i.Middlewares) != 1 || len(i.Closers) != 1 {
+		t.Errorf("unexpected instrumentation: %v", i)
+		return
+	}
+	if err := i.Close(); err != nil {
+		t.Errorf("unexpected error shutting down the provider: %s", err.Error())
+	}
+}
@@ -0,0 +1,77 @@
+package engine
+
+import (
+	"io/fs"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NewFromConfig creates a handler serving the pages declared in the received config, so it can
+// be mounted inside other go servers and tests without the CLI. By default, it uses the
+// DefaultFactory in production mode. The handler is ready to serve once it is returned. It is
+// an *Engine, so it implements io.Closer and must be closed once it is no longer served
+func NewFromConfig(cfg Config, opts ...Option) (http.Handler, error) {
+	o := &options{factory: DefaultFactory}
+	for _, opt := range opts {
+		opt(o)
+	}
+	f := o.factory
+	for _, customize := range o.customizations {
+		f = customize(f)
+	}
+	e, err := f.NewFromConfig(cfg, o.devel)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Option is a function customizing the engines created with NewFromConfig
+type Option func(*options)
+
+// WithFactory sets the factory to use for building the engine. The rest of the options modify it
+// wherever they are placed
+func WithFactory(f Factory) Option {
+	return func(o *options) { o.factory = f }
+}
+
+// WithDevel enables the devel mode, exposing the template hot reload endpoint
+func WithDevel() Option {
+	return func(o *options) { o.devel = true }
+}
+
+// WithMiddlewares registers the received middlewares before mounting the pages
+func WithMiddlewares(middlewares ...gin.HandlerFunc) Option {
+	return customize(func(f Factory) Factory { return f.Use(middlewares...) })
+}
+
+// WithRoutes registers the received route setters before mounting the pages
+func WithRoutes(routes ...func(*gin.Engine)) Option {
+	return customize(func(f Factory) Factory { return f.Handle(routes...) })
+}
+
+// WithHooks attaches the received lifecycle hooks to every request
+func WithHooks(hooks ...Hooks) Option {
+	return customize(func(f Factory) Factory { return f.Use(HooksMiddleware(MultiHooks(hooks))) })
+}
+
+// WithTemplateFS sets the filesystem containing the templates, layouts, partials, static files
+// and public folder
+func WithTemplateFS(fsys fs.FS) Option {
+	return customize(func(f Factory) Factory {
+		f.TemplateFS = fsys
+		return f
+	})
+}
+
+// customize returns an option modifying the factory once it is selected
+func customize(c func(Factory) Factory) Option {
+	return func(o *options) { o.customizations = append(o.customizations, c) }
+}
+
+type options struct {
+	factory        Factory
+	devel          bool
+	customizations []func(Factory) Factory
+}
@@ -0,0 +1,72 @@
+package engine
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNewFromConfig(t *testing.T) {
+	if err := ioutil.WriteFile("test_tmpl", []byte("hi, {{Extra.name}}!"), 0644); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+	defer os.Remove("test_tmpl")
+
+	cfg := Config{
+		Pages: []Page{
+			{
+				URLPattern: "/a",
+				Template:   "a",
+				Extra:      map[string]interface{}{"name": "stranger"},
+			},
+		},
+		Templates: map[string]string{"a": "test_tmpl"},
+	}
+
+	h, err := NewFromConfig(
+		cfg,
+		WithMiddlewares(func(c *gin.Context) { c.Header("X-Embedded", "true") }),
+		WithFactory(DefaultFactory),
+		WithDevel(),
+		WithRoutes(func(e *gin.Engine) {
+			e.GET("/custom", func(c *gin.Context) { c.String(http.StatusOK, "custom") })
+		}),
+	)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	defer h.(io.Closer).Close()
+
+	// the handler serves the pages as soon as it is returned
+	assertResponse(t, h, "/a", http.StatusOK, "hi, stranger!")
+	assertResponse(t, h, "/custom", http.StatusOK, "custom")
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/a", nil)
+	h.ServeHTTP(w, req)
+	if v := w.Header().Get("X-Embedded"); v != "true" {
+		t.Errorf("unexpected header value: %s", v)
+	}
+
+	req, err = putTemplateForm("/template/a", "bye, {{Extra.name}}!")
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Result().StatusCode != http.StatusOK {
+		t.Errorf("unexpected status code: %d", w.Result().StatusCode)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	assertResponse(t, h, "/a", http.StatusOK, "bye, stranger!")
+}
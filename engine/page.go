@@ -2,53 +2,275 @@ package engine
 
 import (
 	"fmt"
-	"time"
+	"io/fs"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
 // NewMustachePageFactory creates a MustachePageFactory with the injected params
 func NewMustachePageFactory(e *gin.Engine, ts *TemplateStore) MustachePageFactory {
-	return MustachePageFactory{e, ts}
+	return MustachePageFactory{Engine: e, TemplateStore: ts}
 }
 
 // MustachePageFactory is a component that sets up the gin engine and the template store
 type MustachePageFactory struct {
 	Engine        *gin.Engine
 	TemplateStore *TemplateStore
+	// FS is the filesystem containing the templates, layouts and partials. If nil, the local
+	// filesystem is used
+	FS fs.FS
+	// TemplateSwitch, if set, selects the template set rendering the pages
+	TemplateSwitch *TemplateSwitch
+	// Middlewares are the named middlewares the pages can add to their routes
+	Middlewares map[string]PageMiddlewareFunc
+	// PageCache, if set, keeps the rendered versions of the pages enabling the page cache
+	PageCache *OutputCache
+	// LiveReload injects the live reload script into the rendered pages
+	LiveReload bool
+	// NotFound handles the requests of the hosts without a page for a URL pattern only declared
+	// by pages restricted to other hosts
+	NotFound gin.HandlerFunc
+	// fragments composes the pages enabling the ESI processing
+	fragments *FragmentComposer
+	// handlers are the handlers of the pages, so their caches can be purged
+	handlers []*Handler
+	// subscribers are the components subscribed to the renderers of the template store, so
+	// they get the renderers stored by the build before it returns
+	subscribers []rendererSubscriber
 }
 
 // Build sets up the injected gin engine and template store depending on the contents of
 // the received configuration
 func (m *MustachePageFactory) Build(cfg Config) {
-	templates, err := NewMustacheRendererMap(cfg)
+	// the pages using the Go templates are not affected by the template sets
+	mustacheCfg := mustacheConfig(cfg)
+	configs := map[string]Config{"": mustacheCfg}
+	if m.TemplateSwitch != nil {
+		configs = templateSetConfigs(mustacheCfg)
+	}
+	templates := map[string]map[string]*MustacheRenderer{}
+	for name, setCfg := range configs {
+		renderers, err := NewMustacheRendererMapFS(m.FS, setCfg)
+		if err != nil {
+			panic(err)
+		}
+		templates[name] = renderers
+	}
+	goTemplates, err := NewGoTemplateRendererMapFS(m.FS, cfg, goTemplateNames(cfg))
 	if err != nil {
 		panic(err)
 	}
 
+	m.fragments = NewFragmentComposer(m.Engine, cfg.Pages)
+
+	var bodyLogger *BodyLogger
+	if cfg.RequestLogging != nil {
+		bodyLogger = NewBodyLogger(*cfg.RequestLogging)
+	}
+
+	routes := newHostRoutes()
 	for _, page := range cfg.Pages {
-		h := NewHandler(NewHandlerConfig(page), m.TemplateStore.Subscribe)
-		m.Engine.GET(page.URLPattern, h.HandlerFunc)
+		if cfg.RobotsTXT != nil && cfg.RobotsTXT.NoIndex {
+			page.Robots = noIndexDirectives
+		}
+		variants := pageVariants(page)
+		if page.Renderer != "" {
+			r, err := NewPluginRenderer(page)
+			if err != nil {
+				panic(err)
+			}
+			// the handler of the page gets the plugin renderer instead of the template
+			variants = variants[1:]
+			page.Template, page.Layout = rendererTopic(page), ""
+			m.TemplateStore.Set(page.Template, r)
+		}
+		h := m.newHandler(page)
+		handler := h.HandlerFunc
+		if page.Stream != nil && page.IsArray {
+			s := NewStreamHandler(h, m.TemplateStore.Subscribe)
+			m.subscribers = append(m.subscribers, s)
+			handler = s.HandlerFunc
+		}
+		if len(page.GeoVariants) > 0 {
+			variants := map[string]*Handler{}
+			for location, variant := range page.GeoVariants {
+				variants[strings.ToUpper(location)] = m.newHandler(variant.page(page))
+			}
+			handler = geoHandlerFunc(h, variants)
+		}
+		if len(page.Locales) > 0 {
+			variants := map[string]gin.HandlerFunc{}
+			for locale, variant := range page.Locales {
+				variants[canonicalLocale(locale)] = m.newHandler(variant.page(page)).HandlerFunc
+			}
+			handler = localeHandlerFunc(handler, variants)
+		}
+		if len(page.Formats) > 0 {
+			handler = formatsHandlerFunc(page, h, handler, m.newSubscribedHandler)
+		}
+		// the named middlewares (auth...) run before taking a concurrency slot
+		handlers, err := pageHandlers(page, m.Middlewares)
+		if err != nil {
+			panic(err)
+		}
+		if h := page.route.handlerFunc(m.NotFound); h != nil {
+			// the requests not matching the constraints of the params are not found
+			handlers = append([]gin.HandlerFunc{h}, handlers...)
+		}
+		if h := page.Canonical.handlerFunc(); h != nil {
+			handlers = append([]gin.HandlerFunc{h}, handlers...)
+		}
+		if page.CSP != nil {
+			handlers = append(handlers, NewCSPMiddleware(*page.CSP))
+		}
+		if page.RateLimit != nil {
+			handlers = append(handlers, NewRateLimiter(*page.RateLimit).HandlerFunc())
+		}
+		if page.Concurrency != nil && page.Concurrency.MaxInFlight > 0 {
+			handlers = append(handlers, NewConcurrencyLimiter(*page.Concurrency).HandlerFunc())
+		}
+		if page.EarlyHints != nil && len(page.EarlyHints.Assets) > 0 {
+			handlers = append(handlers, NewEarlyHintsMiddleware(*page.EarlyHints))
+		}
+		if bodyLogger != nil && bodyLogger.Logs(page.Name) {
+			handlers = append(handlers, bodyLogger.HandlerFunc(page.Name))
+		}
+		routes.add(page.URLPattern, page.Host, append(handlers, handler)...)
+		if cfg.I18n != nil && cfg.I18n.PathPrefix {
+			for _, locale := range cfg.I18n.Locales {
+				routes.add("/"+canonicalLocale(locale)+page.URLPattern, page.Host, append(handlers, handler)...)
+			}
+		}
 
-		time.Sleep(100 * time.Millisecond)
+		for _, p := range variants {
+			if page.Engine == GoTemplateEngine {
+				m.setGoTemplates(goTemplates, p)
+			} else {
+				m.setTemplates(templates, p)
+			}
+		}
+		if page.Stream != nil && page.IsArray {
+			m.setItemTemplate(templates, page.Stream.ItemTemplate)
+		}
+		for _, tmpl := range page.ErrorTemplates {
+			p := page
+			p.Template = tmpl
+			m.setTemplates(templates, p)
+		}
+	}
+	routes.register(m.Engine, m.NotFound)
 
-		r, ok := templates[page.Template]
-		if !ok {
-			fmt.Println("handler without template", page.Name, page.Template)
-			continue
+	if cfg.RenderProxy != nil {
+		m.buildRenderProxy(*cfg.RenderProxy, cfg.registry, templates)
+	}
+
+	// the subscriptions deliver the renderers asynchronously, so the engine would answer the
+	// first requests without them
+	for _, s := range m.subscribers {
+		s.loadRenderers(m.TemplateStore)
+	}
+}
+
+// newHandler creates the Handler of the page, composing its fragments if it enables the ESI
+// processing and caching its rendered versions if it enables the page cache
+func (m *MustachePageFactory) newHandler(page Page) *Handler {
+	h := m.newSubscribedHandler(NewHandlerConfig(page))
+	if page.ESI {
+		h.Fragments = m.fragments
+	}
+	if page.PageCache && m.PageCache != nil {
+		h.PageCache = m.PageCache.Page(page)
+	}
+	h.LiveReload = m.LiveReload
+	m.handlers = append(m.handlers, h)
+	return h
+}
+
+// newSubscribedHandler creates a Handler subscribed to the renderers of the template store
+func (m *MustachePageFactory) newSubscribedHandler(cfg HandlerConfig) *Handler {
+	h := NewHandler(cfg, m.TemplateStore.Subscribe)
+	m.subscribers = append(m.subscribers, h)
+	return h
+}
+
+// purgeStale discards the rendered versions kept by the handlers of the pages defining a stale
+// TTL
+func (m *MustachePageFactory) purgeStale() {
+	for _, h := range m.handlers {
+		if h.Stale != nil {
+			h.Stale.purge()
 		}
-		m.TemplateStore.Set(page.Template, r)
-		if page.Layout == "" {
-			fmt.Println("handler without layout", page.Name, page.Layout)
-			continue
+	}
+}
+
+func (m *MustachePageFactory) buildRenderProxy(cfg RenderProxy, reg *registry, templates map[string]map[string]*MustacheRenderer) {
+	pages := cfg.pages()
+	handlers := make([]*Handler, len(pages))
+	for i, page := range pages {
+		// the backend requests share the caches and the clients of the rest of the pages
+		page.registry = reg
+		handlers[i] = m.newSubscribedHandler(NewRenderProxyHandlerConfig(cfg, page))
+	}
+	m.Engine.GET(pages[0].URLPattern, renderProxyHandlerFunc(cfg, handlers))
+
+	for _, page := range pages {
+		m.setTemplates(templates, page)
+	}
+}
+
+func (m *MustachePageFactory) setTemplates(templates map[string]map[string]*MustacheRenderer, page Page) {
+	for _, renderers := range templates {
+		if _, ok := renderers[page.Template]; !ok {
+			fmt.Println("handler without template", page.Name, page.Template)
+			return
 		}
-		l, ok := templates[page.Layout]
-		if !ok {
+	}
+	m.set(page.Template, templates, func(renderers map[string]*MustacheRenderer) Renderer {
+		return renderers[page.Template]
+	})
+	if page.Layout == "" {
+		fmt.Println("handler without layout", page.Name, page.Layout)
+		return
+	}
+	for _, renderers := range templates {
+		if _, ok := renderers[page.Layout]; !ok {
 			fmt.Println("layout not defined", page.Layout)
-			continue
+			return
+		}
+	}
+	m.set(page.Layout, templates, func(renderers map[string]*MustacheRenderer) Renderer {
+		return renderers[page.Layout]
+	})
+
+	m.set(layoutTopic(page.Layout, page.Template), templates, func(renderers map[string]*MustacheRenderer) Renderer {
+		return newLayoutRenderer(renderers[page.Template], renderers[page.Layout])
+	})
+}
+
+// setItemTemplate stores the renderer of the item template of a streamed page
+func (m *MustachePageFactory) setItemTemplate(templates map[string]map[string]*MustacheRenderer, name string) {
+	for _, renderers := range templates {
+		if _, ok := renderers[name]; !ok {
+			fmt.Println("item template not defined", name)
+			return
 		}
-		m.TemplateStore.Set(page.Layout, l)
+	}
+	m.set(name, templates, func(renderers map[string]*MustacheRenderer) Renderer {
+		return renderers[name]
+	})
+}
 
-		m.TemplateStore.Set(fmt.Sprintf("%s-:-%s", h.Page.Layout, h.Page.Template), &LayoutMustacheRenderer{r.tmpl, l.tmpl})
+// set stores the renderer of the topic or, if there is a template switch, a TemplateSetRenderer
+// with the renderers of the topic in every template set
+func (m *MustachePageFactory) set(topic string, templates map[string]map[string]*MustacheRenderer, renderer func(map[string]*MustacheRenderer) Renderer) {
+	if m.TemplateSwitch == nil {
+		m.TemplateStore.Set(topic, renderer(templates[""]))
+		return
+	}
+	renderers := map[string]Renderer{}
+	for name, set := range templates {
+		renderers[name] = renderer(set)
 	}
+	m.TemplateStore.Set(topic, &TemplateSetRenderer{m.TemplateSwitch, renderers})
 }
@@ -0,0 +1,142 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PurgeMethod is the method of the requests invalidating the cached versions of their paths
+const PurgeMethod = "PURGE"
+
+// PageCacheInvalidatePath is the path of the endpoint invalidating the cached versions of a list
+// of paths
+const PageCacheInvalidatePath = "/admin/cache/invalidate"
+
+// NewOutputCache creates an OutputCache keeping the rendered pages in the store of the config
+func NewOutputCache(cfg PageCache) (*OutputCache, error) {
+	var f CacheStoreFactory
+	switch cfg.Store {
+	case "", MemoryCacheStore:
+		f = NewMemoryCacheStoreFactory()
+	case RedisCacheStore:
+		f = NewRedisCacheStoreFactory(cfg.BackendCache)
+	default:
+		return nil, fmt.Errorf("unknown page cache store: %q", cfg.Store)
+	}
+	vary := make([]string, len(cfg.Vary))
+	for i, h := range cfg.Vary {
+		vary[i] = http.CanonicalHeaderKey(h)
+	}
+	return &OutputCache{Store: f, Vary: vary, versions: f(0)}, nil
+}
+
+// OutputCache keeps the rendered pages by URL and the values of the Vary headers. Every path has a
+// version, part of the keys of its entries, so invalidating a path discards all its cached
+// versions at once, even if the store is shared by several instances
+type OutputCache struct {
+	Store    CacheStoreFactory
+	Vary     []string
+	versions CacheStore
+}
+
+// Page returns the PageOutputCache of the page, expiring the rendered versions after its CacheTTL
+// (1h if it is not valid)
+func (o *OutputCache) Page(page Page) *PageOutputCache {
+	ttl, err := time.ParseDuration(page.CacheTTL)
+	if err != nil {
+		ttl = time.Hour
+	}
+	// the geo and locale variants of the page share its URLs, and the locale and the location
+	// negotiated for the requests are added to the keys
+	variant := page.Name + "|" + page.Template + "|" + page.Layout + "|" + page.BackendURLPattern
+	return &PageOutputCache{Cache: o, variant: variant, store: o.Store(ttl)}
+}
+
+// Invalidate discards the cached versions of the path, whatever their query strings and headers
+func (o *OutputCache) Invalidate(path string) {
+	o.versions.Set("version:"+path, []byte(strconv.FormatInt(time.Now().UnixNano(), 36)))
+}
+
+// InvalidateAll discards the cached versions of all the paths
+func (o *OutputCache) InvalidateAll() {
+	o.versions.Set("generation", []byte(strconv.FormatInt(time.Now().UnixNano(), 36)))
+}
+
+// key returns the key of the cached version of the request rendered by the page variant, with
+// the locale and the location negotiated for it
+func (o *OutputCache) key(variant string, c *gin.Context) string {
+	r := c.Request
+	generation, _ := o.versions.Get("generation")
+	version, _ := o.versions.Get("version:" + r.URL.Path)
+	key := "page:" + string(generation) + ":" + string(version) + ":" + variant + ":" + requestVariant(c) + ":" + r.URL.RequestURI()
+	for _, h := range o.Vary {
+		key += "\n" + h + ":" + r.Header.Get(h)
+	}
+	return key
+}
+
+// PurgeHandlerFunc returns a gin middleware invalidating the paths of the PURGE requests. If the
+// token is not empty, it is required as a bearer token
+func (o *OutputCache) PurgeHandlerFunc(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != PurgeMethod {
+			c.Next()
+			return
+		}
+		if !validBearerToken(c, token) {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		o.Invalidate(c.Request.URL.Path)
+		c.JSON(http.StatusOK, gin.H{"invalidated": []string{c.Request.URL.Path}})
+		c.Abort()
+	}
+}
+
+// Routes returns a route setter registering the endpoint invalidating the paths of the request
+// body ({"paths": ["/products/42"]}). If the token is not empty, it is required as a bearer token
+func (o *OutputCache) Routes(token string) func(*gin.Engine) {
+	return func(e *gin.Engine) {
+		e.POST(PageCacheInvalidatePath, bearerTokenAuth(token), func(c *gin.Context) {
+			var body struct {
+				Paths []string `json:"paths"`
+			}
+			if err := json.NewDecoder(c.Request.Body).Decode(&body); err != nil {
+				c.AbortWithError(http.StatusBadRequest, err)
+				return
+			}
+			for _, path := range body.Paths {
+				o.Invalidate(path)
+			}
+			c.JSON(http.StatusOK, gin.H{"invalidated": body.Paths})
+		})
+	}
+}
+
+// PageOutputCache keeps the rendered versions of the GET and HEAD requests of a page
+type PageOutputCache struct {
+	Cache   *OutputCache
+	variant string
+	store   CacheStore
+}
+
+// Get returns the cached version of the request, if any
+func (p *PageOutputCache) Get(c *gin.Context) ([]byte, bool) {
+	if m := c.Request.Method; m != http.MethodGet && m != http.MethodHead {
+		return nil, false
+	}
+	return p.store.Get(p.Cache.key(p.variant, c))
+}
+
+// Set keeps the rendered version of the request
+func (p *PageOutputCache) Set(c *gin.Context, body []byte) {
+	if m := c.Request.Method; m != http.MethodGet && m != http.MethodHead {
+		return
+	}
+	p.store.Set(p.Cache.key(p.variant, c), body)
+}
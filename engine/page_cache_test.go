@@ -0,0 +1,126 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestOutputCache(t *testing.T) {
+	o, err := NewOutputCache(PageCache{Vary: []string{"accept-language"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := o.Page(Page{Name: "product", CacheTTL: "1m"})
+
+	req, _ := http.NewRequest("GET", "/products/42?color=red", nil)
+	req.Header.Set("Accept-Language", "en")
+	if _, ok := p.Get(pageCacheContext(req)); ok {
+		t.Error("unexpected cached version")
+	}
+	p.Set(pageCacheContext(req), []byte("red"))
+	if body, ok := p.Get(pageCacheContext(req)); !ok || string(body) != "red" {
+		t.Errorf("unexpected cached version: %s", body)
+	}
+
+	other, _ := http.NewRequest("GET", "/products/42?color=red", nil)
+	other.Header.Set("Accept-Language", "es")
+	if _, ok := p.Get(pageCacheContext(other)); ok {
+		t.Error("unexpected cached version of another language")
+	}
+	if _, ok := o.Page(Page{Name: "product", Template: "geo"}).Get(pageCacheContext(req)); ok {
+		t.Error("unexpected cached version of another variant")
+	}
+
+	post, _ := http.NewRequest("POST", "/products/42?color=red", nil)
+	post.Header.Set("Accept-Language", "en")
+	if _, ok := p.Get(pageCacheContext(post)); ok {
+		t.Error("unexpected cached version of a POST request")
+	}
+
+	// the negotiated locales and locations get their own versions
+	for _, set := range []func(*gin.Context){
+		func(c *gin.Context) { c.Set(localeKey, "es") },
+		func(c *gin.Context) { c.Set(geoKey, &GeoLocation{Country: "ES"}) },
+	} {
+		c := pageCacheContext(req)
+		set(c)
+		if _, ok := p.Get(c); ok {
+			t.Error("unexpected cached version of another negotiated variant")
+		}
+	}
+	es := pageCacheContext(req)
+	es.Set(localeKey, "es")
+	p.Set(es, []byte("rojo"))
+	if body, ok := p.Get(es); !ok || string(body) != "rojo" {
+		t.Errorf("unexpected cached version: %s", body)
+	}
+	if body, ok := p.Get(pageCacheContext(req)); !ok || string(body) != "red" {
+		t.Errorf("unexpected cached version: %s", body)
+	}
+
+	o.Invalidate("/products/42")
+	if _, ok := p.Get(pageCacheContext(req)); ok {
+		t.Error("unexpected cached version after the invalidation")
+	}
+
+	if _, err := NewOutputCache(PageCache{BackendCache: BackendCache{Store: "unknown"}}); err == nil {
+		t.Error("expecting an error")
+	}
+}
+
+func TestOutputCache_endpoints(t *testing.T) {
+	o, err := NewOutputCache(PageCache{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := o.Page(Page{Name: "product"})
+	for _, path := range []string{"/a", "/b"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		p.Set(pageCacheContext(req), []byte(path))
+	}
+
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(o.PurgeHandlerFunc("secret"))
+	o.Routes("secret")(e)
+	e.GET("/a", func(c *gin.Context) { c.String(http.StatusOK, "a") })
+
+	for _, tc := range []struct {
+		method, url, body, token string
+		status                   int
+	}{
+		{method: PurgeMethod, url: "/a", status: http.StatusUnauthorized},
+		{method: "POST", url: PageCacheInvalidatePath, body: `{"paths":["/b"]}`, token: "wrong", status: http.StatusUnauthorized},
+		{method: PurgeMethod, url: "/a", token: "secret", status: http.StatusOK},
+		{method: "POST", url: PageCacheInvalidatePath, body: `{"paths":["/b"]}`, token: "secret", status: http.StatusOK},
+		{method: "POST", url: PageCacheInvalidatePath, body: `paths`, token: "secret", status: http.StatusBadRequest},
+	} {
+		req, _ := http.NewRequest(tc.method, tc.url, strings.NewReader(tc.body))
+		if tc.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		if w.Code != tc.status {
+			t.Errorf("[%s %s] unexpected status code: %d", tc.method, tc.url, w.Code)
+		}
+	}
+
+	for _, path := range []string{"/a", "/b"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		if _, ok := p.Get(pageCacheContext(req)); ok {
+			t.Errorf("%s: unexpected cached version", path)
+		}
+	}
+}
+
+// pageCacheContext returns a gin context of the request, as received by the page handlers
+func pageCacheContext(r *http.Request) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = r
+	return c
+}
@@ -0,0 +1,207 @@
+package engine
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// BasicAuthMiddleware requires the credentials of one of the users of the middleware
+	BasicAuthMiddleware = "basic_auth"
+	// IPAllowlistMiddleware rejects the clients out of the allowed IPs and CIDRs
+	IPAllowlistMiddleware = "ip_allowlist"
+	// RequestLoggingMiddleware logs the inbound requests and the backend responses
+	RequestLoggingMiddleware = "request_logging"
+	// HeadersMiddleware adds the headers of the middleware to the responses
+	HeadersMiddleware = "headers"
+	// JWTMiddleware requires a valid JWT, exposing its claims to the templates as Extra.user
+	JWTMiddleware = "jwt"
+)
+
+// PageMiddlewareFunc returns the gin middleware of a named middleware for the received page
+type PageMiddlewareFunc func(page Page) gin.HandlerFunc
+
+// NewPageMiddlewares returns the named middlewares declared in the config, along with the
+// received custom ones
+func NewPageMiddlewares(declared map[string]PageMiddleware, custom map[string]PageMiddlewareFunc) (map[string]PageMiddlewareFunc, error) {
+	result := map[string]PageMiddlewareFunc{}
+	for name, cfg := range declared {
+		mw, err := NewPageMiddleware(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("middleware %s: %s", name, err.Error())
+		}
+		result[name] = mw
+	}
+	for name, mw := range custom {
+		result[name] = mw
+	}
+	return result, nil
+}
+
+// NewPageMiddleware returns the built-in PageMiddlewareFunc declared by the received config
+func NewPageMiddleware(cfg PageMiddleware) (PageMiddlewareFunc, error) {
+	switch cfg.Type {
+	case BasicAuthMiddleware:
+		if len(cfg.Users) == 0 {
+			return nil, fmt.Errorf("the basic auth requires at least one user")
+		}
+		h := basicAuth(cfg.Users, cfg.Realm)
+		return func(_ Page) gin.HandlerFunc { return h }, nil
+	case IPAllowlistMiddleware:
+		nets, err := parseIPNets(cfg.Allow)
+		if err != nil {
+			return nil, err
+		}
+		h := ipAllowlist(nets)
+		return func(_ Page) gin.HandlerFunc { return h }, nil
+	case RequestLoggingMiddleware:
+		logger := NewBodyLogger(RequestLogging{MaxBodySize: cfg.MaxBodySize, RedactedFields: cfg.RedactedFields})
+		return func(page Page) gin.HandlerFunc { return logger.HandlerFunc(page.Name) }, nil
+	case HeadersMiddleware:
+		h := func(c *gin.Context) {
+			for k, v := range cfg.Headers {
+				c.Header(k, v)
+			}
+			c.Next()
+		}
+		return func(_ Page) gin.HandlerFunc { return h }, nil
+	case JWTMiddleware:
+		if cfg.JWT == nil {
+			return nil, fmt.Errorf("the jwt auth requires the jwt section")
+		}
+		v, err := NewJWTValidator(*cfg.JWT)
+		if err != nil {
+			return nil, err
+		}
+		h := v.HandlerFunc()
+		return func(_ Page) gin.HandlerFunc { return h }, nil
+	}
+	return nil, fmt.Errorf("unknown middleware type: %q", cfg.Type)
+}
+
+// pageHandlers returns the named middlewares of the page, in order
+func pageHandlers(page Page, middlewares map[string]PageMiddlewareFunc) ([]gin.HandlerFunc, error) {
+	handlers := make([]gin.HandlerFunc, 0, len(page.Middlewares))
+	for _, name := range page.Middlewares {
+		mw, ok := middlewares[name]
+		if !ok {
+			return nil, fmt.Errorf("page %s: unknown middleware %s", page.Name, name)
+		}
+		handlers = append(handlers, mw(page))
+	}
+	return handlers, nil
+}
+
+// NewProtectedPaths returns the named middlewares of the protected paths as global middlewares,
+// each one running just for the requests under its prefix. The middlewares receive a page named
+// after the prefix
+func NewProtectedPaths(protected []ProtectedPath, middlewares map[string]PageMiddlewareFunc) ([]gin.HandlerFunc, error) {
+	handlers := []gin.HandlerFunc{}
+	for i, p := range protected {
+		if !strings.HasPrefix(p.Prefix, "/") {
+			return nil, fmt.Errorf("protect #%d: the prefix %q must start with /", i, p.Prefix)
+		}
+		page := Page{Name: p.Prefix, URLPattern: p.Prefix}
+		prefix := p.Prefix
+		// the handlers are kept apart so the ones calling c.Next() wrap the rest of the chain
+		for _, name := range p.Middlewares {
+			mw, ok := middlewares[name]
+			if !ok {
+				return nil, fmt.Errorf("protect #%d: unknown middleware %s", i, name)
+			}
+			h := mw(page)
+			handlers = append(handlers, func(c *gin.Context) {
+				if underPrefix(c.Request.URL.Path, prefix) {
+					h(c)
+				}
+			})
+		}
+	}
+	return handlers, nil
+}
+
+// underPrefix returns true if the path is the prefix or one of the paths under it, matching
+// whole segments
+func underPrefix(path, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/") + "/"
+	return path+"/" == prefix || strings.HasPrefix(path, prefix)
+}
+
+// pageMiddlewareTypes returns the types of the declared middlewares running for the page: its
+// own ones and the ones of the protected paths covering its URL pattern
+func pageMiddlewareTypes(cfg Config, page Page) map[string]bool {
+	names := append([]string{}, page.Middlewares...)
+	for _, p := range cfg.Protect {
+		if underPrefix(page.URLPattern, p.Prefix) {
+			names = append(names, p.Middlewares...)
+		}
+	}
+	types := map[string]bool{}
+	for _, name := range names {
+		if mw, ok := cfg.Middlewares[name]; ok {
+			types[mw.Type] = true
+		}
+	}
+	return types
+}
+
+func basicAuth(users map[string]string, realm string) gin.HandlerFunc {
+	if realm == "" {
+		realm = "Authorization Required"
+	}
+	challenge := fmt.Sprintf("Basic realm=%q", realm)
+	return func(c *gin.Context) {
+		user, password, ok := c.Request.BasicAuth()
+		expected, found := users[user]
+		if !ok || !found || subtle.ConstantTimeCompare([]byte(password), []byte(expected)) != 1 {
+			c.Header("WWW-Authenticate", challenge)
+			Unauthorized(c)
+			return
+		}
+		c.Set(gin.AuthUserKey, user)
+		c.Next()
+	}
+}
+
+func ipAllowlist(nets []*net.IPNet) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ip := net.ParseIP(c.ClientIP())
+		for _, n := range nets {
+			if ip != nil && n.Contains(ip) {
+				c.Next()
+				return
+			}
+		}
+		Forbidden(c)
+	}
+}
+
+// parseIPNets parses the received CIDRs and IPs, the latter as single address networks
+func parseIPNets(values []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(values))
+	for _, v := range values {
+		if !strings.Contains(v, "/") {
+			ip := net.ParseIP(v)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP: %q", v)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(v)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
@@ -0,0 +1,146 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNewPageMiddlewares(t *testing.T) {
+	middlewares, err := NewPageMiddlewares(map[string]PageMiddleware{
+		"admin":   {Type: BasicAuthMiddleware, Users: map[string]string{"admin": "secret"}},
+		"office":  {Type: IPAllowlistMiddleware, Allow: []string{"10.0.0.0/8", "192.168.1.10"}},
+		"nosniff": {Type: HeadersMiddleware, Headers: map[string]string{"X-Content-Type-Options": "nosniff"}},
+	}, map[string]PageMiddlewareFunc{
+		"custom": func(page Page) gin.HandlerFunc {
+			return func(c *gin.Context) {
+				c.Header("X-Page", page.Name)
+				c.Next()
+			}
+		},
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+
+	page := Page{Name: "admin", Middlewares: []string{"office", "admin", "nosniff", "custom"}}
+	handlers, err := pageHandlers(page, middlewares)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.GET("/", append(handlers, func(c *gin.Context) { c.String(http.StatusOK, "ok") })...)
+
+	for _, tc := range []struct {
+		ip       string
+		user     string
+		password string
+		status   int
+	}{
+		{"10.1.2.3", "admin", "secret", http.StatusOK},
+		{"192.168.1.10", "admin", "secret", http.StatusOK},
+		{"192.168.1.11", "admin", "secret", http.StatusForbidden},
+		{"10.1.2.3", "admin", "wrong", http.StatusUnauthorized},
+		{"10.1.2.3", "", "", http.StatusUnauthorized},
+	} {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.RemoteAddr = tc.ip + ":1234"
+		if tc.user != "" {
+			req.SetBasicAuth(tc.user, tc.password)
+		}
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		if w.Result().StatusCode != tc.status {
+			t.Errorf("%s %s: unexpected status code: %d", tc.ip, tc.user, w.Result().StatusCode)
+		}
+		if tc.status == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+			t.Error("expecting a basic auth challenge")
+		}
+		if tc.status == http.StatusOK && (w.Header().Get("X-Content-Type-Options") != "nosniff" || w.Header().Get("X-Page") != "admin") {
+			t.Errorf("unexpected headers: %v", w.Header())
+		}
+	}
+
+	if _, err := pageHandlers(Page{Middlewares: []string{"unknown"}}, middlewares); err == nil {
+		t.Error("expecting an error")
+	}
+	for _, cfg := range []PageMiddleware{
+		{Type: "unknown"},
+		{Type: BasicAuthMiddleware},
+		{Type: IPAllowlistMiddleware, Allow: []string{"not an ip"}},
+	} {
+		if _, err := NewPageMiddleware(cfg); err == nil {
+			t.Errorf("%s: expecting an error", cfg.Type)
+		}
+	}
+}
+
+func TestNewProtectedPaths(t *testing.T) {
+	middlewares, err := NewPageMiddlewares(map[string]PageMiddleware{
+		"staging": {Type: BasicAuthMiddleware, Users: map[string]string{"qa": "secret"}},
+		"office":  {Type: IPAllowlistMiddleware, Allow: []string{"10.0.0.0/8"}},
+	}, nil)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+
+	for _, protected := range [][]ProtectedPath{
+		{{Prefix: "admin", Middlewares: []string{"office"}}},
+		{{Prefix: "/admin", Middlewares: []string{"unknown"}}},
+	} {
+		if _, err := NewProtectedPaths(protected, middlewares); err == nil {
+			t.Errorf("%+v: expecting an error", protected)
+		}
+	}
+
+	handlers, err := NewProtectedPaths([]ProtectedPath{
+		{Prefix: "/", Middlewares: []string{"staging"}},
+		{Prefix: "/admin/", Middlewares: []string{"office"}},
+	}, middlewares)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(handlers...)
+	ok := func(c *gin.Context) { c.String(http.StatusOK, "ok") }
+	e.GET("/", ok)
+	e.GET("/admin", ok)
+	e.GET("/admin/users", ok)
+	e.GET("/administrators", ok)
+
+	for _, tc := range []struct {
+		path   string
+		ip     string
+		auth   bool
+		status int
+	}{
+		{"/", "192.168.1.1", true, http.StatusOK},
+		{"/", "192.168.1.1", false, http.StatusUnauthorized},
+		{"/administrators", "192.168.1.1", true, http.StatusOK},
+		{"/admin", "192.168.1.1", true, http.StatusForbidden},
+		{"/admin/users", "192.168.1.1", true, http.StatusForbidden},
+		{"/admin/users", "10.1.2.3", false, http.StatusUnauthorized},
+		{"/admin/users", "10.1.2.3", true, http.StatusOK},
+	} {
+		req, _ := http.NewRequest("GET", tc.path, nil)
+		req.RemoteAddr = tc.ip + ":1234"
+		if tc.auth {
+			req.SetBasicAuth("qa", "secret")
+		}
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		if w.Result().StatusCode != tc.status {
+			t.Errorf("%s %s: unexpected status code: %d", tc.path, tc.ip, w.Result().StatusCode)
+		}
+	}
+}
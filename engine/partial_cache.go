@@ -0,0 +1,103 @@
+package engine
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// setPartialCaches sets the caches of the rendered partials of the registry, using the received
+// partial names as keys. Every cached partial is wrapped in a section calling a helper that
+// renders it just when its cached version is missing or expired
+func (r *registry) setPartialCaches(cfg map[string]CachedPartial) error {
+	for name, c := range cfg {
+		cache, err := NewPartialCache(c)
+		if err != nil {
+			return fmt.Errorf("partial cache %s: %s", name, err.Error())
+		}
+		r.partialCaches[name] = cache
+		r.helpers[partialCacheHelper(name)] = cache.Helper
+	}
+	return nil
+}
+
+// purgePartialCaches discards the rendered versions of all the cached partials of the registry
+func (r *registry) purgePartialCaches() {
+	for _, cache := range r.partialCaches {
+		cache.mutex.Lock()
+		cache.entries = map[string]fragmentEntry{}
+		cache.mutex.Unlock()
+	}
+}
+
+// NewPartialCache returns an empty PartialCache with the received TTL and key
+func NewPartialCache(cfg CachedPartial) (*PartialCache, error) {
+	ttl, err := time.ParseDuration(cfg.TTL)
+	if err != nil || ttl <= 0 {
+		return nil, fmt.Errorf("invalid ttl: %q", cfg.TTL)
+	}
+	return &PartialCache{TTL: ttl, Key: cfg.Key, entries: map[string]fragmentEntry{}}, nil
+}
+
+// PartialCache keeps the rendered versions of a partial during the TTL. The versions are
+// identified by the Key template, rendered with the context of the partial
+type PartialCache struct {
+	TTL     time.Duration
+	Key     string
+	mutex   sync.Mutex
+	entries map[string]fragmentEntry
+}
+
+// Helper is the HelperFunc rendering the partial wrapped by its section, or returning its cached
+// version
+func (p *PartialCache) Helper(text string, render func(string) (string, error)) (string, error) {
+	key := ""
+	if p.Key != "" {
+		k, err := render(p.Key)
+		if err != nil {
+			return "", err
+		}
+		key = k
+	}
+
+	p.mutex.Lock()
+	e, ok := p.entries[key]
+	p.mutex.Unlock()
+	if ok && time.Now().Before(e.expires) {
+		return string(e.body), nil
+	}
+
+	rendered, err := render(text)
+	if err != nil {
+		return "", err
+	}
+	p.mutex.Lock()
+	if len(p.entries) >= staleMaxEntries {
+		now := time.Now()
+		for k, e := range p.entries {
+			if now.After(e.expires) {
+				delete(p.entries, k)
+			}
+		}
+	}
+	p.entries[key] = fragmentEntry{body: []byte(rendered), expires: time.Now().Add(p.TTL)}
+	p.mutex.Unlock()
+	return rendered, nil
+}
+
+// cachedPartialText returns the content of the partial wrapped by the section of its cache
+// helper, if the partial is cached by the registry
+func (r *registry) cachedPartialText(name, data string) string {
+	if r == nil || r.partialCaches[name] == nil {
+		return data
+	}
+	helper := partialCacheHelper(name)
+	return "{{#" + helper + "}}" + data + "{{/" + helper + "}}"
+}
+
+// partialCacheHelper returns the name of the cache helper of the partial, without the dots and
+// slashes of the partial names
+func partialCacheHelper(name string) string {
+	return "api2html_partial_cache_" + hex.EncodeToString([]byte(name))
+}
@@ -0,0 +1,49 @@
+package engine
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestPartialCache(t *testing.T) {
+	reg := newRegistry()
+	if err := reg.setPartialCaches(map[string]CachedPartial{"partials/nav": {TTL: "1m", Key: "{{Extra.version}}"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys := fstest.MapFS{"partials/nav.mustache": {Data: []byte("<nav>{{Data.title}}</nav>")}}
+	r, err := newMustacheRenderer(fsys, strings.NewReader("{{> partials/nav}}!"), reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		version  string
+		title    string
+		expected string
+	}{
+		{"1", "a", "<nav>a</nav>!"},
+		{"1", "b", "<nav>a</nav>!"},
+		{"2", "b", "<nav>b</nav>!"},
+	} {
+		buf := &bytes.Buffer{}
+		ctx := ResponseContext{
+			Data:  map[string]interface{}{"title": tc.title},
+			Extra: map[string]interface{}{"version": tc.version},
+		}
+		if err := r.Render(buf, ctx); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != tc.expected {
+			t.Errorf("version %s: unexpected result: %s", tc.version, buf.String())
+		}
+	}
+}
+
+func TestRegistry_setPartialCaches_invalidTTL(t *testing.T) {
+	if err := newRegistry().setPartialCaches(map[string]CachedPartial{"partials/nav": {TTL: "soon"}}); err == nil {
+		t.Error("expecting an error")
+	}
+}
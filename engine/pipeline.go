@@ -0,0 +1,160 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+var fingerprintedFile = regexp.MustCompile(`\.[0-9a-f]{8}(\.[^./]+)?$`)
+
+// RunAssetPipeline runs all the steps of the pipeline, in order
+func RunAssetPipeline(cfg AssetPipeline) error {
+	for _, step := range cfg.Steps {
+		if err := RunAssetStep(step); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunAssetStep runs the command of the received step, forwarding its output to the stdout and
+// the stderr of the process
+func RunAssetStep(step AssetStep) error {
+	if len(step.Command) == 0 {
+		return fmt.Errorf("asset step %s: empty command", step.Name)
+	}
+	log.Println("running the asset step", step.Name)
+	cmd := exec.Command(step.Command[0], step.Command[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("asset step %s: %s", step.Name, err.Error())
+	}
+	return nil
+}
+
+// WatchAssetPipeline reruns every step of the pipeline when one of its sources changes. The
+// returned function stops watching the sources
+func WatchAssetPipeline(cfg AssetPipeline) (func() error, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	steps := map[string][]int{}
+	for i, step := range cfg.Steps {
+		for _, source := range step.Sources {
+			err := filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
+				if err != nil || !info.IsDir() && path != source {
+					return err
+				}
+				steps[filepath.Clean(path)] = append(steps[filepath.Clean(path)], i)
+				return watcher.Add(path)
+			})
+			if err != nil {
+				watcher.Close()
+				return nil, err
+			}
+		}
+	}
+
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Op == fsnotify.Chmod {
+					continue
+				}
+				for _, i := range changedSteps(steps, event.Name) {
+					if err := RunAssetStep(cfg.Steps[i]); err != nil {
+						log.Println(err.Error())
+					}
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Println("watching the asset sources:", err.Error())
+			}
+		}
+	}()
+
+	return watcher.Close, nil
+}
+
+func changedSteps(steps map[string][]int, name string) []int {
+	seen := map[int]bool{}
+	res := []int{}
+	for _, path := range []string{filepath.Clean(name), filepath.Dir(name)} {
+		for _, i := range steps[path] {
+			if !seen[i] {
+				seen[i] = true
+				res = append(res, i)
+			}
+		}
+	}
+	return res
+}
+
+// FingerprintAssets copies the outputs of the pipeline adding the hash of their content to their
+// names (js/app.js -> js/app.5f2b1a3c.js) and returns the fingerprinted paths indexed by the
+// original ones, both relative to the public folder
+func FingerprintAssets(cfg AssetPipeline, public PublicFolder) (map[string]string, error) {
+	fingerprints := map[string]string{}
+	for _, step := range cfg.Steps {
+		if step.Output == "" {
+			continue
+		}
+		if rel, err := filepath.Rel(public.Path, step.Output); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return fingerprints, fmt.Errorf("asset step %s: the output %s is not inside the public folder", step.Name, step.Output)
+		}
+
+		err := filepath.Walk(step.Output, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() || fingerprintedFile.MatchString(path) {
+				return err
+			}
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			target := fingerprintedName(path, data)
+			if err := ioutil.WriteFile(target, data, info.Mode()); err != nil {
+				return err
+			}
+
+			original, err := filepath.Rel(public.Path, path)
+			if err != nil {
+				return err
+			}
+			fingerprinted, err := filepath.Rel(public.Path, target)
+			if err != nil {
+				return err
+			}
+			fingerprints[filepath.ToSlash(original)] = filepath.ToSlash(fingerprinted)
+			return nil
+		})
+		if err != nil {
+			return fingerprints, err
+		}
+	}
+	return fingerprints, nil
+}
+
+func fingerprintedName(path string, data []byte) string {
+	sum := sha256.Sum256(data)
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s.%s%s", strings.TrimSuffix(path, ext), hex.EncodeToString(sum[:4]), ext)
+}
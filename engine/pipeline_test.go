@@ -0,0 +1,164 @@
+package engine
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestRunAssetPipeline(t *testing.T) {
+	dir, err := ioutil.TempDir(".", "pipeline")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(dir, "app.js")
+	cfg := AssetPipeline{Steps: []AssetStep{
+		{Name: "first", Command: []string{"sh", "-c", "echo -n first > " + output}},
+		{Name: "second", Command: []string{"sh", "-c", "echo -n second >> " + output}},
+	}}
+	if err := RunAssetPipeline(cfg); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if data, _ := ioutil.ReadFile(output); string(data) != "firstsecond" {
+		t.Errorf("unexpected output: %s", string(data))
+	}
+}
+
+func TestRunAssetStep_ko(t *testing.T) {
+	for _, step := range []AssetStep{
+		{Name: "empty"},
+		{Name: "failing", Command: []string{"false"}},
+		{Name: "unknown", Command: []string{"unknown-command-for-the-asset-pipeline"}},
+	} {
+		if err := RunAssetStep(step); err == nil {
+			t.Errorf("%s: expecting error", step.Name)
+		}
+	}
+}
+
+func TestWatchAssetPipeline(t *testing.T) {
+	dir, err := ioutil.TempDir(".", "pipeline")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	sources := filepath.Join(dir, "src")
+	if err := os.MkdirAll(sources, 0755); err != nil {
+		t.Error(err)
+		return
+	}
+	output := filepath.Join(dir, "app.js")
+	stop, err := WatchAssetPipeline(AssetPipeline{Steps: []AssetStep{
+		{Name: "concat", Command: []string{"sh", "-c", "cat " + sources + "/* > " + output}, Sources: []string{sources}},
+	}})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	defer stop()
+
+	if err := ioutil.WriteFile(filepath.Join(sources, "a.js"), []byte("alert(1)"), 0644); err != nil {
+		t.Error(err)
+		return
+	}
+
+	for i := 0; i < 20; i++ {
+		time.Sleep(100 * time.Millisecond)
+		if data, _ := ioutil.ReadFile(output); string(data) == "alert(1)" {
+			return
+		}
+	}
+	t.Error("the step has not been rerun")
+}
+
+func TestFingerprintAssets(t *testing.T) {
+	dir, err := ioutil.TempDir(".", "pipeline")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	if err := os.MkdirAll(filepath.Join(dir, "js"), 0755); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "js", "app.js"), []byte("alert(1)"), 0644); err != nil {
+		t.Error(err)
+		return
+	}
+
+	cfg := AssetPipeline{Steps: []AssetStep{{Name: "js", Output: filepath.Join(dir, "js")}}}
+	for i := 0; i < 2; i++ {
+		fingerprints, err := FingerprintAssets(cfg, PublicFolder{Path: dir})
+		if err != nil {
+			t.Errorf("unexpected error: %s", err.Error())
+			return
+		}
+		if len(fingerprints) != 1 || fingerprints["js/app.js"] != "js/app.6e11c72f.js" {
+			t.Errorf("unexpected fingerprints: %v", fingerprints)
+			return
+		}
+	}
+	if data, _ := ioutil.ReadFile(filepath.Join(dir, "js", "app.6e11c72f.js")); string(data) != "alert(1)" {
+		t.Errorf("unexpected fingerprinted file: %s", string(data))
+	}
+}
+
+func TestFingerprintAssets_koOutsideThePublicFolder(t *testing.T) {
+	cfg := AssetPipeline{Steps: []AssetStep{{Name: "js", Output: "../js"}}}
+	if _, err := FingerprintAssets(cfg, PublicFolder{Path: "public"}); err == nil {
+		t.Error("expecting error")
+	}
+}
+
+func TestFactory_New_assetPipeline(t *testing.T) {
+	dir, err := ioutil.TempDir(".", "public")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	ef := DefaultFactory
+	ef.TemplateFS = fstest.MapFS{
+		"a.mustache": {Data: []byte("{{Helper.Assets.app_js.URL}}")},
+	}
+	ef.Parser = func(_ string) (Config, error) {
+		return Config{
+			Pages:        []Page{{URLPattern: "/a", Template: "a"}},
+			Templates:    map[string]string{"a": "a.mustache"},
+			PublicFolder: &PublicFolder{Path: dir, Prefix: "/js"},
+			AssetPipeline: &AssetPipeline{
+				Steps: []AssetStep{
+					{
+						Name:    "js",
+						Command: []string{"sh", "-c", "echo -n 'alert(1)' > " + filepath.Join(dir, "app.js")},
+						Output:  filepath.Join(dir, "app.js"),
+					},
+				},
+				Fingerprint: true,
+			},
+		}, nil
+	}
+
+	e, err := ef.New("something", false)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	assertResponse(t, e, "/a", http.StatusOK, "/js/app.6e11c72f.js")
+	assertResponse(t, e, "/js/app.6e11c72f.js", http.StatusOK, "alert(1)")
+}
@@ -0,0 +1,210 @@
+package engine
+
+import (
+	"fmt"
+	"log"
+	"plugin"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ResponseGeneratorFactory creates the ResponseGenerator of a page
+type ResponseGeneratorFactory func(Page) (ResponseGenerator, error)
+
+// RendererFactory creates the Renderer of a page
+type RendererFactory func(Page) (Renderer, error)
+
+var (
+	responseGenerators = map[string]ResponseGeneratorFactory{}
+	renderers          = map[string]RendererFactory{}
+	// loading gets the components registered while a plugin is being opened, so they are kept
+	// apart from the ones of the package
+	loading      *registry
+	pluginsMutex sync.RWMutex
+	// loadedPlugins are the components registered by the plugins already opened. Go can not
+	// unload the plugins nor run their init functions again, so they are opened just once
+	loadedPlugins      = map[string]*registry{}
+	loadedPluginsMutex sync.Mutex
+)
+
+// RegisterResponseGenerator makes the factory available to the pages declaring the received
+// name as their generator. The created ResponseGenerator replaces the backend of the page
+func RegisterResponseGenerator(name string, f ResponseGeneratorFactory) {
+	if loadingPlugin(func(r *registry) { r.generators[name] = f }) {
+		return
+	}
+	pluginsMutex.Lock()
+	responseGenerators[name] = f
+	pluginsMutex.Unlock()
+}
+
+// RegisterRenderer makes the factory available to the pages declaring the received name as
+// their renderer. The created Renderer replaces the template and the layout of the page
+func RegisterRenderer(name string, f RendererFactory) {
+	if loadingPlugin(func(r *registry) { r.renderers[name] = f }) {
+		return
+	}
+	pluginsMutex.Lock()
+	renderers[name] = f
+	pluginsMutex.Unlock()
+}
+
+// loadingPlugin adds a component to the registry of the plugin being opened and returns true,
+// or returns false if there is none
+func loadingPlugin(register func(*registry)) bool {
+	pluginsMutex.Lock()
+	defer pluginsMutex.Unlock()
+	if loading == nil {
+		return false
+	}
+	register(loading)
+	return true
+}
+
+// LoadPlugin opens the Go plugin at the received path and registers its components in the
+// package. The plugins register their response generators and renderers from their init
+// functions or from an exported Register function, called once the plugin is opened. They must
+// be built with the same version of Go and of the engine package as the binary loading them
+func LoadPlugin(path string) error {
+	p, err := loadPlugin(path)
+	if err != nil {
+		return err
+	}
+	for name, h := range p.helpers {
+		RegisterHelper(name, h)
+	}
+	for name, d := range p.decoders {
+		RegisterDecoder(name, d)
+	}
+	for name, f := range p.generators {
+		RegisterResponseGenerator(name, f)
+	}
+	for name, f := range p.renderers {
+		RegisterRenderer(name, f)
+	}
+	return nil
+}
+
+// loadPlugin opens the Go plugin at the received path, the first time, and returns the
+// components it registers
+func loadPlugin(path string) (*registry, error) {
+	loadedPluginsMutex.Lock()
+	defer loadedPluginsMutex.Unlock()
+	if r, ok := loadedPlugins[path]; ok {
+		return r, nil
+	}
+
+	r := newRegistry()
+	pluginsMutex.Lock()
+	loading = r
+	pluginsMutex.Unlock()
+	defer func() {
+		pluginsMutex.Lock()
+		loading = nil
+		pluginsMutex.Unlock()
+	}()
+
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if sym, err := p.Lookup("Register"); err == nil {
+		register, ok := sym.(func() error)
+		if !ok {
+			return nil, fmt.Errorf("the Register function of the plugin %s must be a func() error", path)
+		}
+		if err := register(); err != nil {
+			return nil, err
+		}
+	}
+	// without a Register function, the plugin registers its components from its init functions
+	loadedPlugins[path] = r
+	return r, nil
+}
+
+// loadPlugins opens the plugins of the config and adds their components to the registry
+func (r *registry) loadPlugins(paths []string) error {
+	for _, path := range paths {
+		log.Println("loading the plugin", path)
+		p, err := loadPlugin(path)
+		if err != nil {
+			return fmt.Errorf("plugin %s: %s", path, err.Error())
+		}
+		r.merge(p)
+	}
+	return nil
+}
+
+// registeredPlugins returns an error if there is no factory registered with the received
+// generator or renderer names. The empty names are ignored
+func (r *registry) registeredPlugins(generator, renderer string) error {
+	if _, ok := r.responseGenerator(generator); generator != "" && !ok {
+		return fmt.Errorf("unknown response generator: %s", generator)
+	}
+	if _, ok := r.renderer(renderer); renderer != "" && !ok {
+		return fmt.Errorf("unknown renderer: %s", renderer)
+	}
+	return nil
+}
+
+// responseGenerator returns the response generator factory registered with the received name
+func (r *registry) responseGenerator(name string) (ResponseGeneratorFactory, bool) {
+	if r == nil {
+		pluginsMutex.RLock()
+		defer pluginsMutex.RUnlock()
+		f, ok := responseGenerators[name]
+		return f, ok
+	}
+	f, ok := r.generators[name]
+	return f, ok
+}
+
+// renderer returns the renderer factory registered with the received name
+func (r *registry) renderer(name string) (RendererFactory, bool) {
+	if r == nil {
+		pluginsMutex.RLock()
+		defer pluginsMutex.RUnlock()
+		f, ok := renderers[name]
+		return f, ok
+	}
+	f, ok := r.renderers[name]
+	return f, ok
+}
+
+// NewPluginResponseGenerator returns the ResponseGenerator of the page created by the factory
+// registered with the name of its generator
+func NewPluginResponseGenerator(page Page) (ResponseGenerator, error) {
+	f, ok := page.registry.responseGenerator(page.Generator)
+	if !ok {
+		return nil, fmt.Errorf("unknown response generator: %s", page.Generator)
+	}
+	return f(page)
+}
+
+// NewPluginRenderer returns the Renderer of the page created by the factory registered with the
+// name of its renderer
+func NewPluginRenderer(page Page) (Renderer, error) {
+	f, ok := page.registry.renderer(page.Renderer)
+	if !ok {
+		return nil, fmt.Errorf("unknown renderer: %s", page.Renderer)
+	}
+	return f(page)
+}
+
+// pluginResponseGenerator returns the ResponseGenerator of the page or, if it can not be created,
+// one failing with the error
+func pluginResponseGenerator(page Page) ResponseGenerator {
+	rg, err := NewPluginResponseGenerator(page)
+	if err != nil {
+		log.Println(page.Name, err.Error())
+		return func(_ *gin.Context) (ResponseContext, error) { return ResponseContext{}, err }
+	}
+	return rg
+}
+
+// rendererTopic returns the topic of the template store publishing the plugin renderer of the
+// page
+func rendererTopic(page Page) string {
+	return "renderer:" + page.Renderer + ":" + page.URLPattern
+}
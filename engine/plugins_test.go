@@ -0,0 +1,57 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestFactory_New_plugins(t *testing.T) {
+	page := Page{URLPattern: "/a", Generator: "greeting", Renderer: "text", Extra: map[string]interface{}{"name": "stranger"}}
+	ef := DefaultFactory
+	ef.Parser = func(_ string) (Config, error) {
+		return Config{Pages: []Page{page}}, nil
+	}
+
+	if _, err := ef.New("something", true); err == nil {
+		t.Error("expecting an error with unknown plugins")
+	}
+
+	ef = ef.WithResponseGenerator("greeting", func(p Page) (ResponseGenerator, error) {
+		return func(c *gin.Context) (ResponseContext, error) {
+			return ResponseContext{Data: map[string]interface{}{"greeting": "hi, " + p.Extra["name"].(string)}}, nil
+		}, nil
+	}).WithRenderer("text", func(_ Page) (Renderer, error) {
+		return RendererFunc(func(w io.Writer, v interface{}) error {
+			_, err := fmt.Fprintf(w, "%s!", v.(ResponseContext).Data["greeting"])
+			return err
+		}), nil
+	})
+	if len(DefaultFactory.ResponseGenerators) != 0 || len(DefaultFactory.Renderers) != 0 {
+		t.Error("the default factory should not be modified")
+	}
+
+	e, err := ef.New("something", true)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	defer e.Close()
+	if reg := registeredComponents(); reg.generators["greeting"] != nil || reg.renderers["text"] != nil {
+		t.Error("the components of the factory should not be registered in the package")
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	assertResponse(t, e, "/a", http.StatusOK, "hi, stranger!")
+}
+
+func TestLoadPlugin_ko(t *testing.T) {
+	if err := newRegistry().loadPlugins([]string{"unknown.so"}); err == nil {
+		t.Error("expecting an error loading an unknown plugin")
+	}
+}
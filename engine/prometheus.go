@@ -0,0 +1,253 @@
+package engine
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	defaultPrometheusPath      = "/metrics"
+	defaultPrometheusNamespace = "api2html"
+	prometheusPageKey          = "api2html_prometheus_page"
+	unknownPage                = "unknown"
+)
+
+// NewPrometheusInstrumentation returns the gin middlewares collecting the request metrics and
+// the route exposing them, using a dedicated registry
+func NewPrometheusInstrumentation(cfg Prometheus) Instrumentation {
+	path := cfg.Path
+	if path == "" {
+		path = defaultPrometheusPath
+	}
+
+	registry := prometheus.NewRegistry()
+	hooks := NewPrometheusHooks(cfg, registry)
+	registry.MustRegister(NewCacheCollector(cfg.Namespace, BackendCacheStats))
+	handler := promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+
+	return Instrumentation{
+		Middlewares: []gin.HandlerFunc{hooks.HandlerFunc()},
+		Routes: []func(*gin.Engine){
+			func(e *gin.Engine) { e.GET(path, gin.WrapH(handler)) },
+		},
+	}
+}
+
+// NewPrometheusHooks creates a PrometheusHooks and registers its collectors into the received
+// registerer
+func NewPrometheusHooks(cfg Prometheus, registerer prometheus.Registerer) *PrometheusHooks {
+	namespace := cfg.Namespace
+	if namespace == "" {
+		namespace = defaultPrometheusNamespace
+	}
+
+	p := &PrometheusHooks{labels: newPrometheusLabels(cfg.Labels)}
+	labelNames := func(names ...string) []string {
+		return append(names, p.labels.names...)
+	}
+
+	p.requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "requests_total",
+		Help:      "Number of handled requests.",
+	}, labelNames("page", "status"))
+	p.requestDuration = newPageHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "request_duration_seconds",
+		Help:      "Time spent handling the requests.",
+	}, labelNames("page"), cfg)
+	p.backendDuration = newPageHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "backend_duration_seconds",
+		Help:      "Time spent waiting for the backend responses.",
+	}, labelNames("page", "target", "status"), cfg)
+	p.decodeDuration = newPageHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "decode_duration_seconds",
+		Help:      "Time spent decoding the backend responses.",
+	}, labelNames("page"), cfg)
+	p.renderDuration = newPageHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "render_duration_seconds",
+		Help:      "Time spent rendering the templates.",
+	}, labelNames("page"), cfg)
+
+	registerer.MustRegister(p.requests, p.requestDuration, p.backendDuration, p.decodeDuration, p.renderDuration)
+
+	return p
+}
+
+// PrometheusHooks is a Hooks implementation recording the lifecycle of the requests as
+// prometheus metrics
+type PrometheusHooks struct {
+	requests        *prometheus.CounterVec
+	requestDuration *pageHistogram
+	backendDuration *pageHistogram
+	decodeDuration  *pageHistogram
+	renderDuration  *pageHistogram
+	labels          prometheusLabels
+}
+
+// HandlerFunc returns a gin middleware attaching the hooks to the request and recording its
+// status and duration once it is completed
+func (p *PrometheusHooks) HandlerFunc() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		addHooks(c, p)
+		c.Next()
+
+		page := pageFromContext(c)
+		p.requests.WithLabelValues(p.labels.values(c, page, strconv.Itoa(c.Writer.Status()))...).Inc()
+		p.requestDuration.WithLabelValues(p.labels.values(c, page)...).Observe(time.Since(start).Seconds())
+	}
+}
+
+// OnRequest implements the Hooks interface by storing the page name in the context
+func (p *PrometheusHooks) OnRequest(c *gin.Context, name string) {
+	c.Set(prometheusPageKey, name)
+}
+
+// OnBackendCall implements the Hooks interface
+func (p *PrometheusHooks) OnBackendCall(c *gin.Context, req *http.Request) func(*http.Response, error) {
+	start := time.Now()
+	return func(resp *http.Response, err error) {
+		status := "error"
+		if err == nil && resp != nil {
+			status = strconv.Itoa(resp.StatusCode)
+		}
+		p.backendDuration.WithLabelValues(p.labels.values(c, pageFromContext(c), req.URL.Host, status)...).Observe(time.Since(start).Seconds())
+	}
+}
+
+// OnDecode implements the Hooks interface
+func (p *PrometheusHooks) OnDecode(c *gin.Context) func(error) {
+	return p.observeDuration(p.decodeDuration, c)
+}
+
+// OnRender implements the Hooks interface
+func (p *PrometheusHooks) OnRender(c *gin.Context) func(error) {
+	return p.observeDuration(p.renderDuration, c)
+}
+
+func (p *PrometheusHooks) observeDuration(h *pageHistogram, c *gin.Context) func(error) {
+	start := time.Now()
+	return func(_ error) {
+		h.WithLabelValues(p.labels.values(c, pageFromContext(c))...).Observe(time.Since(start).Seconds())
+	}
+}
+
+func pageFromContext(c *gin.Context) string {
+	if v, ok := c.Get(prometheusPageKey); ok {
+		if name, ok := v.(string); ok && name != "" {
+			return name
+		}
+	}
+	return unknownPage
+}
+
+// newPageHistogram creates a pageHistogram with the buckets of the config. The first label must
+// be the page
+func newPageHistogram(opts prometheus.HistogramOpts, labels []string, cfg Prometheus) *pageHistogram {
+	opts.Buckets = cfg.Buckets
+	h := &pageHistogram{
+		all:   prometheus.NewHistogramVec(opts, labels),
+		pages: map[string]*prometheus.HistogramVec{},
+	}
+	for page, buckets := range cfg.PageBuckets {
+		pageOpts := opts
+		pageOpts.Buckets = buckets
+		h.pages[page] = prometheus.NewHistogramVec(pageOpts, labels)
+	}
+	return h
+}
+
+// pageHistogram is a histogram collector with custom buckets for some pages. The buckets are
+// not part of the metric descriptor, so all the pages are exposed as a single metric
+type pageHistogram struct {
+	all   *prometheus.HistogramVec
+	pages map[string]*prometheus.HistogramVec
+}
+
+// WithLabelValues returns the observer of the received label values, starting with the page
+func (h *pageHistogram) WithLabelValues(values ...string) prometheus.Observer {
+	if v, ok := h.pages[values[0]]; ok {
+		return v.WithLabelValues(values...)
+	}
+	return h.all.WithLabelValues(values...)
+}
+
+// Describe implements the prometheus.Collector interface
+func (h *pageHistogram) Describe(ch chan<- *prometheus.Desc) {
+	h.all.Describe(ch)
+}
+
+// Collect implements the prometheus.Collector interface
+func (h *pageHistogram) Collect(ch chan<- prometheus.Metric) {
+	h.all.Collect(ch)
+	for _, v := range h.pages {
+		v.Collect(ch)
+	}
+}
+
+// newPrometheusLabels parses the sources of the extra labels, ignoring the invalid ones
+func newPrometheusLabels(sources map[string]string) prometheusLabels {
+	l := prometheusLabels{}
+	for name := range sources {
+		l.names = append(l.names, name)
+	}
+	sort.Strings(l.names)
+	valid := l.names[:0]
+	for _, name := range l.names {
+		kind := strings.SplitN(sources[name], ":", 2)
+		switch {
+		case name == "page" || name == "status" || name == "target":
+			log.Println("prometheus label", name, "is reserved")
+		case len(kind) != 2 || kind[1] == "":
+			log.Println("prometheus label", name, "has an invalid source:", sources[name])
+		case kind[0] != "header" && kind[0] != "cookie" && kind[0] != "query" && kind[0] != "param":
+			log.Println("prometheus label", name, "has an unknown source:", sources[name])
+		default:
+			valid = append(valid, name)
+			l.sources = append(l.sources, kind)
+		}
+	}
+	l.names = valid
+	return l
+}
+
+// prometheusLabels contains the extra labels of the metrics and the request values used for them
+type prometheusLabels struct {
+	names   []string
+	sources [][]string
+}
+
+// values appends the values of the extra labels to the received ones
+func (l prometheusLabels) values(c *gin.Context, values ...string) []string {
+	for _, source := range l.sources {
+		v := ""
+		if c.Request == nil {
+			values = append(values, v)
+			continue
+		}
+		switch source[0] {
+		case "header":
+			v = c.Request.Header.Get(source[1])
+		case "cookie":
+			v, _ = c.Cookie(source[1])
+		case "query":
+			v = c.Query(source[1])
+		case "param":
+			v = c.Param(source[1])
+		}
+		values = append(values, v)
+	}
+	return values
+}
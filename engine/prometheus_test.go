@@ -0,0 +1,149 @@
+package engine
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestNewPrometheusInstrumentation(t *testing.T) {
+	i := NewPrometheusInstrumentation(Prometheus{Path: "/__metrics", Namespace: "test"})
+
+	h := &Handler{
+		Page: Page{Name: "page"},
+		Renderer: RendererFunc(func(w io.Writer, _ interface{}) error {
+			_, err := w.Write([]byte("ok"))
+			return err
+		}),
+		ResponseGenerator: func(_ *gin.Context) (ResponseContext, error) { return ResponseContext{}, nil },
+	}
+
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(i.Middlewares...)
+	for _, route := range i.Routes {
+		route(e)
+	}
+	e.GET("/", h.HandlerFunc)
+
+	assertResponse(t, e, "/", http.StatusOK, "ok")
+	assertResponse(t, e, "/", http.StatusOK, "ok")
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/__metrics", nil)
+	e.ServeHTTP(w, req)
+	if w.Result().StatusCode != http.StatusOK {
+		t.Errorf("unexpected status code: %d", w.Result().StatusCode)
+	}
+	data, _ := ioutil.ReadAll(w.Result().Body)
+	w.Result().Body.Close()
+
+	for _, expected := range []string{
+		`test_requests_total{page="page",status="200"} 2`,
+		`test_request_duration_seconds_count{page="page"} 2`,
+		`test_render_duration_seconds_count{page="page"} 2`,
+	} {
+		if !strings.Contains(string(data), expected) {
+			t.Errorf("the metrics do not contain %s:\n%s", expected, string(data))
+		}
+	}
+}
+
+func TestPrometheusHooks_OnBackendCall(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	p := NewPrometheusHooks(Prometheus{}, registry)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	p.OnRequest(c, "page")
+	req, _ := http.NewRequest("GET", "http://eu.api.example.com/a", nil)
+	p.OnBackendCall(c, req)(&http.Response{StatusCode: http.StatusOK}, nil)
+	p.OnBackendCall(c, req)(nil, http.ErrHandlerTimeout)
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	for _, family := range families {
+		if family.GetName() != "api2html_backend_duration_seconds" {
+			continue
+		}
+		if len(family.GetMetric()) != 2 {
+			t.Errorf("unexpected metrics: %v", family.GetMetric())
+		}
+		for _, m := range family.GetMetric() {
+			for _, label := range m.GetLabel() {
+				if label.GetName() == "target" && label.GetValue() != "eu.api.example.com" {
+					t.Errorf("unexpected target: %s", label.GetValue())
+				}
+			}
+		}
+		return
+	}
+	t.Error("backend metrics not found")
+}
+
+func TestNewPrometheusInstrumentation_bucketsAndLabels(t *testing.T) {
+	i := NewPrometheusInstrumentation(Prometheus{
+		Buckets:     []float64{0.5, 2},
+		PageBuckets: map[string][]float64{"report": {1, 5, 10}},
+		Labels: map[string]string{
+			"tenant":  "header:X-Tenant",
+			"variant": "query:variant",
+			"page":    "header:X-Page",
+			"broken":  "body:tenant",
+		},
+	})
+
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(i.Middlewares...)
+	for _, route := range i.Routes {
+		route(e)
+	}
+	for _, name := range []string{"home", "report"} {
+		h := &Handler{
+			Page:              Page{Name: name},
+			Renderer:          RendererFunc(func(_ io.Writer, _ interface{}) error { return nil }),
+			ResponseGenerator: func(_ *gin.Context) (ResponseContext, error) { return ResponseContext{}, nil },
+		}
+		e.GET("/"+name, h.HandlerFunc)
+	}
+
+	req, _ := http.NewRequest("GET", "/home?variant=b", nil)
+	req.Header.Set("X-Tenant", "acme")
+	e.ServeHTTP(httptest.NewRecorder(), req)
+	assertResponse(t, e, "/report", http.StatusOK, "")
+
+	w := httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/metrics", nil)
+	e.ServeHTTP(w, req)
+	data, _ := ioutil.ReadAll(w.Result().Body)
+	w.Result().Body.Close()
+
+	for _, expected := range []string{
+		`api2html_requests_total{page="home",status="200",tenant="acme",variant="b"} 1`,
+		`api2html_requests_total{page="report",status="200",tenant="",variant=""} 1`,
+		`api2html_request_duration_seconds_bucket{page="home",tenant="acme",variant="b",le="2"} 1`,
+		`api2html_request_duration_seconds_bucket{page="report",tenant="",variant="",le="10"} 1`,
+	} {
+		if !strings.Contains(string(data), expected) {
+			t.Errorf("the metrics do not contain %s:\n%s", expected, string(data))
+		}
+	}
+	for _, unexpected := range []string{
+		`le="0.005"`,
+		`api2html_request_duration_seconds_bucket{page="home",tenant="acme",variant="b",le="10"}`,
+		`broken`,
+	} {
+		if strings.Contains(string(data), unexpected) {
+			t.Errorf("the metrics contain %s:\n%s", unexpected, string(data))
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// ProtobufEncoding is the encoding of the pages whose backends return protobuf messages. It
+// requires the descriptor of the message
+const ProtobufEncoding = "protobuf"
+
+// NewProtobufDecoder returns a decoder unmarshalling the protobuf messages of the type declared
+// by the received config and putting them into the Data property of the ResponseContext, with
+// the names of the fields in the proto files as keys
+func NewProtobufDecoder(cfg Protobuf) (Decoder, error) {
+	if cfg.Descriptor == "" || cfg.Message == "" {
+		return nil, fmt.Errorf("the protobuf encoding requires a descriptor and a message type")
+	}
+	data, err := ioutil.ReadFile(cfg.Descriptor)
+	if err != nil {
+		return nil, err
+	}
+	set := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(data, set); err != nil {
+		return nil, fmt.Errorf("parsing the descriptor %s: %s", cfg.Descriptor, err.Error())
+	}
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, fmt.Errorf("parsing the descriptor %s: %s", cfg.Descriptor, err.Error())
+	}
+	d, err := files.FindDescriptorByName(protoreflect.FullName(cfg.Message))
+	if err != nil {
+		return nil, fmt.Errorf("finding the message type %s: %s", cfg.Message, err.Error())
+	}
+	md, ok := d.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a message type", cfg.Message)
+	}
+
+	// the messages are converted into their JSON representation, so the int64 values are kept
+	// as strings and the enums as names
+	marshaler := protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}
+	return func(r io.Reader, c *ResponseContext) error {
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		msg := dynamicpb.NewMessage(md)
+		if err := proto.Unmarshal(data, msg); err != nil {
+			return err
+		}
+		data, err = marshaler.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		return JSONDecoder(bytes.NewReader(data), c)
+	}, nil
+}
@@ -0,0 +1,102 @@
+package engine
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func TestNewProtobufDecoder(t *testing.T) {
+	file := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("catalog.proto"),
+		Package: proto.String("catalog.v1"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Product"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				{
+					Name:     proto.String("display_name"),
+					JsonName: proto.String("displayName"),
+					Number:   proto.Int32(1),
+					Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+					Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				},
+				{
+					Name:     proto.String("tags"),
+					JsonName: proto.String("tags"),
+					Number:   proto.Int32(2),
+					Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+					Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+				},
+			},
+		}},
+	}
+	data, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{file}})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	dir, err := ioutil.TempDir("", "protobuf")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	descriptor := filepath.Join(dir, "catalog.pb")
+	if err := ioutil.WriteFile(descriptor, data, 0644); err != nil {
+		t.Error(err)
+		return
+	}
+
+	for _, cfg := range []Protobuf{
+		{Descriptor: descriptor},
+		{Descriptor: filepath.Join(dir, "unknown.pb"), Message: "catalog.v1.Product"},
+		{Descriptor: descriptor, Message: "catalog.v1.Unknown"},
+		{Descriptor: descriptor, Message: "catalog.v1.Product.display_name"},
+	} {
+		if _, err := NewProtobufDecoder(cfg); err == nil {
+			t.Errorf("expecting an error with %v", cfg)
+		}
+	}
+	if _, err := pageDecoder(Page{Encoding: ProtobufEncoding}); err == nil {
+		t.Error("expecting an error without message type")
+	}
+
+	decoder, err := backendDecoder(Page{}, PageBackend{
+		Encoding: ProtobufEncoding,
+		Protobuf: &Protobuf{Descriptor: descriptor, Message: "catalog.v1.Product"},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	fd, err := protodesc.NewFile(file, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	msg := dynamicpb.NewMessage(fd.Messages().Get(0))
+	msg.Set(fd.Messages().Get(0).Fields().ByName("display_name"), protoreflect.ValueOfString("laptop"))
+	data, _ = proto.Marshal(msg)
+
+	c := &ResponseContext{}
+	if err := decoder(bytes.NewReader(data), c); err != nil {
+		t.Error(err)
+		return
+	}
+	if c.Data["display_name"] != "laptop" {
+		t.Errorf("unexpected data: %v", c.Data)
+	}
+	if tags, ok := c.Data["tags"].([]interface{}); !ok || len(tags) != 0 {
+		t.Errorf("unexpected tags: %v", c.Data["tags"])
+	}
+}
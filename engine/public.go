@@ -0,0 +1,88 @@
+package engine
+
+import (
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-contrib/static"
+	"github.com/gin-gonic/gin"
+)
+
+// NewPublicFolderHandler returns a gin middleware serving the files of the public folder with
+// the Cache-Control header defined by their cache policy
+func NewPublicFolderHandler(cfg PublicFolder) gin.HandlerFunc {
+	fs := static.LocalFile(cfg.Path, false)
+	fileserver := http.FileServer(fs)
+	if cfg.Prefix != "" {
+		fileserver = http.StripPrefix(cfg.Prefix, fileserver)
+	}
+	return func(c *gin.Context) {
+		if !fs.Exists(cfg.Prefix, c.Request.URL.Path) {
+			return
+		}
+		if cacheControl := cfg.cacheControl(strings.TrimPrefix(c.Request.URL.Path, cfg.Prefix)); cacheControl != "" {
+			c.Header("Cache-Control", cacheControl)
+		}
+		fileserver.ServeHTTP(c.Writer, c.Request)
+		c.Abort()
+	}
+}
+
+// NewPublicFolderHandlerFS returns a gin middleware serving the files of the public folder found
+// in the received fs.FS. If the fs.FS is nil, the local filesystem is used
+func NewPublicFolderHandlerFS(fsys fs.FS, cfg PublicFolder) gin.HandlerFunc {
+	if fsys == nil {
+		return NewPublicFolderHandler(cfg)
+	}
+	root, err := fs.Sub(fsys, fsPath(cfg.Path))
+	if err != nil {
+		return NewPublicFolderHandler(cfg)
+	}
+	fileserver := http.FileServer(http.FS(root))
+	if cfg.Prefix != "" {
+		fileserver = http.StripPrefix(cfg.Prefix, fileserver)
+	}
+	return func(c *gin.Context) {
+		if !strings.HasPrefix(c.Request.URL.Path, cfg.Prefix) || !fsFileExists(root, strings.TrimPrefix(c.Request.URL.Path, cfg.Prefix)) {
+			return
+		}
+		if cacheControl := cfg.cacheControl(strings.TrimPrefix(c.Request.URL.Path, cfg.Prefix)); cacheControl != "" {
+			c.Header("Cache-Control", cacheControl)
+		}
+		fileserver.ServeHTTP(c.Writer, c.Request)
+		c.Abort()
+	}
+}
+
+// fsFileExists reports whether the file or the index of the directory exists in the fs.FS
+func fsFileExists(fsys fs.FS, name string) bool {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		name = "."
+	}
+	info, err := fs.Stat(fsys, name)
+	if err != nil {
+		return false
+	}
+	if info.IsDir() {
+		_, err = fs.Stat(fsys, path.Join(name, "index.html"))
+		return err == nil
+	}
+	return true
+}
+
+func (p PublicFolder) cacheControl(name string) string {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	for _, policy := range p.CachePolicies {
+		target := name
+		if !strings.Contains(policy.Pattern, "/") {
+			target = path.Base(name)
+		}
+		if ok, _ := path.Match(policy.Pattern, target); ok {
+			return policy.CacheControl
+		}
+	}
+	return p.CacheControl
+}
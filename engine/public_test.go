@@ -0,0 +1,75 @@
+package engine
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestPublicFolder_cacheControl(t *testing.T) {
+	cfg := PublicFolder{
+		CacheControl: "public, max-age=3600",
+		CachePolicies: []CachePolicy{
+			{Pattern: "*.????????.js", CacheControl: "public, max-age=31536000, immutable"},
+			{Pattern: "snapshots/*.html", CacheControl: "public, max-age=60"},
+			{Pattern: "*.html", CacheControl: "no-cache"},
+		},
+	}
+	for name, expected := range map[string]string{
+		"/js/app.6e11c72f.js":   "public, max-age=31536000, immutable",
+		"/js/app.js":            "public, max-age=3600",
+		"/snapshots/home.html":  "public, max-age=60",
+		"/index.html":           "no-cache",
+		"/other/snapshots.html": "no-cache",
+		"/css/main.css":         "public, max-age=3600",
+	} {
+		if cacheControl := cfg.cacheControl(name); cacheControl != expected {
+			t.Errorf("%s: unexpected cache control: %s", name, cacheControl)
+		}
+	}
+}
+
+func TestNewPublicFolderHandler(t *testing.T) {
+	dir, err := ioutil.TempDir(".", "public")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"app.6e11c72f.js", "app.js"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("alert(1)"), 0644); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	e := gin.New()
+	e.Use(NewPublicFolderHandler(PublicFolder{
+		Path:          dir,
+		Prefix:        "/js",
+		CachePolicies: []CachePolicy{{Pattern: "*.????????.js", CacheControl: "immutable"}},
+	}))
+	e.GET("/js/page", func(c *gin.Context) { c.String(http.StatusOK, "page") })
+
+	for url, expected := range map[string]string{
+		"/js/app.6e11c72f.js": "immutable",
+		"/js/app.js":          "",
+		"/js/page":            "",
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", url, nil)
+		e.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: unexpected status code: %d", url, w.Code)
+		}
+		if cacheControl := w.Header().Get("Cache-Control"); cacheControl != expected {
+			t.Errorf("%s: unexpected cache control: %s", url, cacheControl)
+		}
+	}
+}
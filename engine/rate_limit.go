@@ -0,0 +1,98 @@
+package engine
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// RateLimitByIP keeps a bucket per client IP
+	RateLimitByIP = "ip"
+	// RateLimitGlobal keeps a single bucket shared by all the clients
+	RateLimitGlobal = "global"
+
+	// rateLimitMaxBuckets is the number of client buckets kept before forgetting them
+	rateLimitMaxBuckets = 100000
+)
+
+// NewRateLimiter creates a RateLimiter with the received config
+func NewRateLimiter(cfg RateLimit) *RateLimiter {
+	burst := float64(cfg.Burst)
+	if burst <= 0 {
+		burst = math.Max(1, math.Ceil(cfg.Rate))
+	}
+	return &RateLimiter{
+		Rate:    cfg.Rate,
+		Burst:   burst,
+		Global:  cfg.Key == RateLimitGlobal,
+		Now:     time.Now,
+		buckets: map[string]*tokenBucket{},
+	}
+}
+
+// RateLimiter limits the requests with token buckets refilled at Rate tokens per second, up to
+// Burst tokens. Every request takes a token from the bucket of its client (or from the global
+// one) and gets a 429 response, rendered with the static/429 template, if it is empty
+type RateLimiter struct {
+	Rate   float64
+	Burst  float64
+	Global bool
+	// Now returns the current time
+	Now     func() time.Time
+	mutex   sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// HandlerFunc returns a gin middleware rejecting the requests exceeding the rate
+func (l *RateLimiter) HandlerFunc() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := ""
+		if !l.Global {
+			// the engine only takes the client IP from the forwarded headers of the trusted
+			// proxies, so the clients can not get new buckets by rotating them
+			key = c.ClientIP()
+		}
+		if wait, ok := l.take(key); !ok {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			c.AbortWithStatus(http.StatusTooManyRequests)
+			return
+		}
+		c.Next()
+	}
+}
+
+// take takes a token from the bucket of the key. If it is empty, it returns the time until the
+// next token
+func (l *RateLimiter) take(key string) (time.Duration, bool) {
+	now := l.Now()
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= rateLimitMaxBuckets {
+			l.buckets = map[string]*tokenBucket{}
+		}
+		b = &tokenBucket{tokens: l.Burst, updated: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.Burst, b.tokens+now.Sub(b.updated).Seconds()*l.Rate)
+	b.updated = now
+	if b.tokens < 1 {
+		if l.Rate <= 0 {
+			return time.Second, false
+		}
+		return time.Duration((1 - b.tokens) / l.Rate * float64(time.Second)), false
+	}
+	b.tokens--
+	return 0, true
+}
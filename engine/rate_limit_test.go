@@ -0,0 +1,77 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRateLimiter(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		cfg      RateLimit
+		expected []int
+	}{
+		{
+			name:     "per ip",
+			cfg:      RateLimit{Rate: 1, Burst: 2},
+			expected: []int{http.StatusOK, http.StatusOK, http.StatusOK, http.StatusOK, http.StatusTooManyRequests, http.StatusTooManyRequests},
+		},
+		{
+			name:     "global",
+			cfg:      RateLimit{Rate: 1, Burst: 2, Key: RateLimitGlobal},
+			expected: []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusTooManyRequests},
+		},
+	} {
+		now := time.Now()
+		l := NewRateLimiter(tc.cfg)
+		l.Now = func() time.Time { return now }
+
+		gin.SetMode(gin.TestMode)
+		e := gin.New()
+		e.GET("/", l.HandlerFunc(), func(c *gin.Context) {
+			c.String(http.StatusOK, "ok")
+		})
+
+		for i, status := range tc.expected {
+			req, _ := http.NewRequest("GET", "/", nil)
+			// every client sends 3 requests
+			req.RemoteAddr = []string{"1.1.1.1:1234", "2.2.2.2:1234"}[i%2]
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, req)
+			if w.Code != status {
+				t.Errorf("%s #%d: unexpected status code: %d", tc.name, i, w.Code)
+			}
+			if status == http.StatusTooManyRequests && w.Header().Get("Retry-After") != "1" {
+				t.Errorf("%s #%d: unexpected Retry-After header: %s", tc.name, i, w.Header().Get("Retry-After"))
+			}
+		}
+
+		now = now.Add(time.Second)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "1.1.1.1:1234"
+		e.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: unexpected status code after refilling the bucket: %d", tc.name, w.Code)
+		}
+	}
+}
+
+func TestNewRateLimiter_defaultBurst(t *testing.T) {
+	for _, tc := range []struct {
+		rate  float64
+		burst float64
+	}{
+		{rate: 0.5, burst: 1},
+		{rate: 10, burst: 10},
+		{rate: 2.5, burst: 3},
+	} {
+		if l := NewRateLimiter(RateLimit{Rate: tc.rate}); l.Burst != tc.burst {
+			t.Errorf("unexpected burst for the rate %v: %v", tc.rate, l.Burst)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package engine
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/cbroglie/mustache"
+	"github.com/gin-gonic/gin"
+	"github.com/jmespath/go-jmespath"
+)
+
+// RedirectFunc returns the status code and the location of the redirection of the received
+// backend data, if any
+type RedirectFunc func(interface{}) (int, string, error)
+
+// NewRedirectFunc returns the RedirectFunc checking the received rules in order. The first
+// matching rule redirects to its target, rendered with the backend data
+func NewRedirectFunc(rules []RedirectRule) (RedirectFunc, error) {
+	type redirect struct {
+		when   *jmespath.JMESPath
+		target *mustache.Template
+		status int
+	}
+	redirects := make([]redirect, len(rules))
+	for i, rule := range rules {
+		when, err := jmespath.Compile(rule.When)
+		if err != nil {
+			return nil, fmt.Errorf("redirect #%d: %s", i, err.Error())
+		}
+		target, err := mustache.ParseStringRaw(rule.Target, true)
+		if err != nil {
+			return nil, fmt.Errorf("redirect #%d: %s", i, err.Error())
+		}
+		status := rule.Status
+		switch status {
+		case 0:
+			status = http.StatusFound
+		case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+			http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		default:
+			return nil, fmt.Errorf("redirect #%d: invalid status %d", i, status)
+		}
+		redirects[i] = redirect{when, target, status}
+	}
+
+	return func(v interface{}) (int, string, error) {
+		input := floatNumbers(v)
+		for _, r := range redirects {
+			matched, err := r.when.Search(input)
+			if err != nil {
+				return 0, "", err
+			}
+			if !jmespathTruthy(matched) {
+				continue
+			}
+			location, err := r.target.Render(v)
+			if err != nil {
+				return 0, "", err
+			}
+			return r.status, location, nil
+		}
+		return 0, "", nil
+	}, nil
+}
+
+// withRedirects wraps the ResponseGenerator redirecting the requests whose backend data matches
+// the redirect rules of the page. They are checked before adding the additional backends, so the
+// redirected requests do not call them
+func withRedirects(page Page, rg ResponseGenerator) ResponseGenerator {
+	if len(page.Redirects) == 0 {
+		return rg
+	}
+	redirect, err := NewRedirectFunc(page.Redirects)
+	if err != nil {
+		return func(_ *gin.Context) (ResponseContext, error) {
+			return ResponseContext{}, err
+		}
+	}
+	return func(c *gin.Context) (ResponseContext, error) {
+		result, err := rg(c)
+		if err != nil {
+			return result, err
+		}
+		var data interface{} = result.Data
+		if result.Array != nil {
+			array := make([]interface{}, len(result.Array))
+			for i, item := range result.Array {
+				array[i] = item
+			}
+			data = array
+		}
+		status, location, err := redirect(data)
+		if err != nil {
+			return result, err
+		}
+		if location != "" {
+			return result, &ResponseError{StatusCode: status, Location: location}
+		}
+		return result, nil
+	}
+}
+
+// jmespathTruthy returns false for the false, null and empty values, as the JMESPath
+// expressions do
+func jmespathTruthy(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case []interface{}:
+		return len(v) > 0
+	case map[string]interface{}:
+		return len(v) > 0
+	}
+	return true
+}
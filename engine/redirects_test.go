@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func Test_withRedirects(t *testing.T) {
+	page := Page{Redirects: []RedirectRule{
+		{When: "status == 'moved'", Target: "/products/{{replaced_by}}", Status: http.StatusMovedPermanently},
+		{When: "canonical_url", Target: "{{canonical_url}}"},
+	}}
+	var data map[string]interface{}
+	rg := withRedirects(page, func(_ *gin.Context) (ResponseContext, error) {
+		return ResponseContext{Data: data}, nil
+	})
+
+	for _, tc := range []struct {
+		data     map[string]interface{}
+		status   int
+		location string
+	}{
+		{
+			data:     map[string]interface{}{"status": "moved", "replaced_by": json.Number("1234567"), "canonical_url": "/x"},
+			status:   http.StatusMovedPermanently,
+			location: "/products/1234567",
+		},
+		{
+			data:     map[string]interface{}{"status": "active", "canonical_url": "https://example.com/a?b=c&d=e"},
+			status:   http.StatusFound,
+			location: "https://example.com/a?b=c&d=e",
+		},
+		{
+			data:   map[string]interface{}{"status": "active", "canonical_url": ""},
+			status: http.StatusOK,
+		},
+		{
+			data:   map[string]interface{}{"status": "active"},
+			status: http.StatusOK,
+		},
+	} {
+		data = tc.data
+		result, err := rg(nil)
+		if tc.location == "" {
+			if err != nil || result.Data["status"] != "active" {
+				t.Errorf("unexpected result: %v %v", result, err)
+			}
+			continue
+		}
+		status, headers := ErrorResponse(err)
+		if status != tc.status || headers.Get("Location") != tc.location {
+			t.Errorf("unexpected redirection: %d %v", status, headers)
+		}
+	}
+}
+
+func TestNewRedirectFunc_ko(t *testing.T) {
+	for _, rule := range []RedirectRule{
+		{When: "status ==", Target: "/a"},
+		{When: "moved", Target: "/{{#a}}"},
+		{When: "moved", Target: "/a", Status: http.StatusOK},
+	} {
+		if _, err := NewRedirectFunc([]RedirectRule{rule}); err == nil {
+			t.Errorf("expecting an error with the rule %v", rule)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package engine
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultRefreshPath is the path of the refresh endpoint
+const DefaultRefreshPath = "/admin/refresh"
+
+const (
+	// RefreshTemplates is the scope of the refresh requests just reloading the templates
+	RefreshTemplates = "templates"
+	// RefreshCaches is the scope of the refresh requests just purging the caches
+	RefreshCaches = "caches"
+)
+
+// ErrUnknownRefreshScope is the error returned when refreshing an unknown scope
+var ErrUnknownRefreshScope = fmt.Errorf("unknown refresh scope")
+
+// Refresher reloads the templates from their source and purges the caches on demand, so the CMS
+// and the deployment pipelines can publish their changes without waiting for the watchers
+type Refresher struct {
+	Graph *TemplateGraph
+	Store *TemplateStore
+	// Sync, if set, updates the source of the templates (the remote bucket, the git clone) before
+	// reloading them
+	Sync func() error
+	// Purge empties every cache
+	Purge []func()
+}
+
+// Refresh reloads the templates, if the scope is empty or RefreshTemplates, and purges the
+// caches, if the scope is empty or RefreshCaches
+func (r *Refresher) Refresh(scope string) error {
+	switch scope {
+	case "", RefreshTemplates, RefreshCaches:
+	default:
+		return ErrUnknownRefreshScope
+	}
+	if scope != RefreshCaches && r.Graph != nil {
+		if r.Sync != nil {
+			if err := r.Sync(); err != nil {
+				return err
+			}
+		}
+		if err := r.Graph.ReloadAll(r.Store); err != nil {
+			return err
+		}
+	}
+	if scope != RefreshTemplates {
+		for _, purge := range r.Purge {
+			purge()
+		}
+	}
+	return nil
+}
+
+// HandlerFunc returns a gin handler refreshing the scope of the scope query param (templates,
+// caches or both if it is empty). If the token is not empty, it is required as a bearer token
+func (r *Refresher) HandlerFunc(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !validBearerToken(c, token) {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		scope := c.Query("scope")
+		if err := r.Refresh(scope); err == ErrUnknownRefreshScope {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		} else if err != nil {
+			log.Println("refreshing:", err.Error())
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"templates": scope != RefreshCaches && r.Graph != nil,
+			"caches":    scope != RefreshTemplates,
+		})
+	}
+}
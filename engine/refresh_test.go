@@ -0,0 +1,91 @@
+package engine
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRefresher(t *testing.T) {
+	g, fsys := newTestTemplateGraph(t)
+	store := NewTemplateStore()
+	synced, purged := 0, 0
+	r := &Refresher{
+		Graph: g,
+		Store: store,
+		Sync:  func() error { synced++; return nil },
+		Purge: []func(){func() { purged++ }},
+	}
+
+	fsys["tmpl/about.mustache"] = &fstest.MapFile{Data: []byte(`about v2`)}
+	if err := r.Refresh(RefreshTemplates); err != nil {
+		t.Fatal(err)
+	}
+	if synced != 1 || purged != 0 {
+		t.Errorf("unexpected refresh: %d syncs, %d purges", synced, purged)
+	}
+	about, ok := store.Get("main-:-about")
+	if !ok {
+		t.Fatal("the composition was not published")
+	}
+	buf := &bytes.Buffer{}
+	if err := about.Render(buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "-about v2-" {
+		t.Errorf("unexpected render: %s", buf.String())
+	}
+
+	if err := r.Refresh(RefreshCaches); err != nil {
+		t.Fatal(err)
+	}
+	if synced != 1 || purged != 1 {
+		t.Errorf("unexpected refresh: %d syncs, %d purges", synced, purged)
+	}
+	if err := r.Refresh("unknown"); err != ErrUnknownRefreshScope {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	fsys["tmpl/about.mustache"] = &fstest.MapFile{Data: []byte(`{{ about`)}
+	if err := r.Refresh(""); err == nil {
+		t.Error("expecting an error")
+	}
+}
+
+func TestRefresher_HandlerFunc(t *testing.T) {
+	purged := 0
+	r := &Refresher{Purge: []func(){func() { purged++ }}}
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.POST(DefaultRefreshPath, r.HandlerFunc("secret"))
+
+	for _, tc := range []struct {
+		url, token string
+		status     int
+		body       string
+	}{
+		{url: DefaultRefreshPath, status: http.StatusUnauthorized},
+		{url: DefaultRefreshPath + "?scope=unknown", token: "secret", status: http.StatusBadRequest},
+		{url: DefaultRefreshPath, token: "secret", status: http.StatusOK, body: `{"caches":true,"templates":false}`},
+	} {
+		req, _ := http.NewRequest("POST", tc.url, nil)
+		if tc.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		if w.Code != tc.status {
+			t.Errorf("[%s] unexpected status code: %d", tc.url, w.Code)
+		}
+		if tc.body != "" && w.Body.String() != tc.body {
+			t.Errorf("[%s] unexpected body: %s", tc.url, w.Body.String())
+		}
+	}
+	if purged != 1 {
+		t.Errorf("unexpected purges: %d", purged)
+	}
+}
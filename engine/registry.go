@@ -0,0 +1,80 @@
+package engine
+
+import (
+	"golang.org/x/oauth2"
+)
+
+// newRegistry returns an empty registry
+func newRegistry() *registry {
+	return &registry{
+		helpers:       map[string]HelperFunc{},
+		decoders:      map[string]Decoder{},
+		generators:    map[string]ResponseGeneratorFactory{},
+		renderers:     map[string]RendererFactory{},
+		oauth2Sources: map[string]oauth2.TokenSource{},
+		partialCaches: map[string]*PartialCache{},
+	}
+}
+
+// registeredComponents returns a registry with a copy of the helpers, the decoders, the
+// response generators and the renderers registered in the package
+func registeredComponents() *registry {
+	r := newRegistry()
+
+	helpersMutex.RLock()
+	for name, h := range helpers {
+		r.helpers[name] = h
+	}
+	helpersMutex.RUnlock()
+
+	decodersMutex.RLock()
+	for name, d := range decoders {
+		r.decoders[name] = d
+	}
+	decodersMutex.RUnlock()
+
+	pluginsMutex.RLock()
+	for name, f := range responseGenerators {
+		r.generators[name] = f
+	}
+	for name, f := range renderers {
+		r.renderers[name] = f
+	}
+	pluginsMutex.RUnlock()
+
+	return r
+}
+
+// registry holds the components the pages and the templates of an engine are built with: the
+// template helpers, the decoders, the response generators and renderers, the store of the
+// cached backend responses, the OAuth2 clients and the partial caches. Every engine gets its
+// own one, so the engines of a process (like the ones replaced by a reload) do not share them.
+// It is not modified once the engine is built.
+//
+// The components built without a registry (nil) use the ones registered in the package
+type registry struct {
+	helpers       map[string]HelperFunc
+	decoders      map[string]Decoder
+	generators    map[string]ResponseGeneratorFactory
+	renderers     map[string]RendererFactory
+	cacheStore    CacheStoreFactory
+	oauth2Sources map[string]oauth2.TokenSource
+	partialCaches map[string]*PartialCache
+}
+
+// merge adds the helpers, the decoders, the response generators and the renderers of the other
+// registry, replacing the ones with the same names
+func (r *registry) merge(other *registry) {
+	for name, h := range other.helpers {
+		r.helpers[name] = h
+	}
+	for name, d := range other.decoders {
+		r.decoders[name] = d
+	}
+	for name, f := range other.generators {
+		r.generators[name] = f
+	}
+	for name, f := range other.renderers {
+		r.renderers[name] = f
+	}
+}
@@ -0,0 +1,48 @@
+package engine
+
+import (
+	"net/http"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestFactory_New_registries(t *testing.T) {
+	ef := DefaultFactory
+	ef.TemplateFS = fstest.MapFS{"tmpl/a.mustache": {Data: []byte("{{#shout}}Hi, {{Extra.name}}{{/shout}}!")}}
+
+	engines := map[string]*Engine{}
+	for helper, expected := range map[string]string{UpperHelper: "HI, STRANGER!", LowerHelper: "hi, stranger!"} {
+		helper := helper
+		ef.Parser = func(_ string) (Config, error) {
+			return Config{
+				Pages: []Page{
+					{
+						URLPattern: "/a",
+						Template:   "a",
+						Extra:      map[string]interface{}{"name": "Stranger"},
+					},
+				},
+				Templates: map[string]string{"a": "./tmpl/a.mustache"},
+				Helpers:   map[string]TemplateHelper{"shout": {Type: helper}},
+			}, nil
+		}
+		e, err := ef.New("something", false)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", helper, err.Error())
+			return
+		}
+		defer e.Close()
+		engines[expected] = e
+	}
+
+	if _, ok := registeredComponents().helpers["shout"]; ok {
+		t.Error("the helpers of the config should not be registered in the package")
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	for expected, e := range engines {
+		assertResponse(t, e, "/a", http.StatusOK, expected)
+	}
+}
@@ -0,0 +1,322 @@
+package engine
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	// DefaultReadinessPath is the path of the readiness endpoint of the Reloader
+	DefaultReadinessPath = "/readyz"
+
+	defaultReloadDebounce = 500 * time.Millisecond
+	defaultReloadRetry    = 5 * time.Second
+	defaultReadyTimeout   = 30 * time.Second
+)
+
+// NewReloader creates a Reloader building the engines with the received factory and the config
+// at cfgPath
+func NewReloader(ef Factory, cfgPath string, devel bool) *Reloader {
+	return &Reloader{
+		Factory:         ef,
+		ConfigPath:      cfgPath,
+		Devel:           devel,
+		HealthPath:      DefaultHealthPath,
+		ReadinessPath:   DefaultReadinessPath,
+		Debounce:        defaultReloadDebounce,
+		RetryInterval:   defaultReloadRetry,
+		ShutdownTimeout: DefaultShutdownTimeout,
+		RemoteInterval:  defaultRemoteConfigInterval,
+		ReadyTimeout:    defaultReadyTimeout,
+	}
+}
+
+// Reloader is an http.Handler rebuilding the engine every time the config, the templates or the
+// layouts change, so the mounted ConfigMaps and Secrets can be updated without restarting the
+// pods. The ConfigPath can also be the URL of a remote config (see IsRemoteConfig). Until the
+// first successful load, every request gets a 503 response
+type Reloader struct {
+	Factory    Factory
+	ConfigPath string
+	Devel      bool
+	// HealthPath always answers with a 200, so the process is not restarted while loading
+	HealthPath string
+	// ReadinessPath answers with a 503 until the engine is loaded. Then, the requests are
+	// handled by the readiness endpoint of the engine
+	ReadinessPath string
+	// Debounce is the time to wait for the burst of events of an update to end
+	Debounce time.Duration
+	// RetryInterval is the time between load attempts until the first one succeeds
+	RetryInterval time.Duration
+	// ShutdownTimeout is the max time the in-flight requests have to finish on shutdown
+	ShutdownTimeout time.Duration
+	// RemoteInterval is the time between polls of the remote configs and the max wait of the
+	// Consul blocking queries
+	RemoteInterval time.Duration
+	// ReadyTimeout is the max time a new engine has to publish its templates and finish its
+	// warm up before replacing the served one
+	ReadyTimeout time.Duration
+	mutex        sync.RWMutex
+	engine       *Engine
+	dirs         []string
+}
+
+// Load builds a new engine with the current contents of the config and replaces the served one,
+// closing it, once the new one is ready. If the build fails or the new engine is not ready before
+// the ReadyTimeout, the current engine is kept
+func (r *Reloader) Load() error {
+	cfg, err := r.Factory.Parser(r.ConfigPath)
+	if err != nil {
+		return err
+	}
+	return r.load(cfg)
+}
+
+func (r *Reloader) load(cfg Config) error {
+	e, err := r.Factory.NewFromConfig(cfg, r.Devel)
+	if err != nil {
+		return err
+	}
+	// the served engine keeps answering while the new one loads its templates and warms up
+	if err := e.waitReady(r.ReadyTimeout); err != nil {
+		e.Close()
+		return err
+	}
+
+	r.mutex.Lock()
+	replaced := r.engine
+	r.engine = e
+	r.dirs = reloaderDirs(r.ConfigPath, cfg)
+	r.mutex.Unlock()
+
+	if replaced != nil {
+		// the watchers and the subscriptions of the replaced engine would run forever
+		if err := replaced.Close(); err != nil {
+			log.Println("closing the replaced engine:", err.Error())
+		}
+	}
+	return nil
+}
+
+// Ready returns true if the engine has been loaded
+func (r *Reloader) Ready() bool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.engine != nil
+}
+
+// ServeHTTP implements the http.Handler interface
+func (r *Reloader) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mutex.RLock()
+	h := r.engine
+	r.mutex.RUnlock()
+
+	if h == nil {
+		switch req.URL.Path {
+		case r.HealthPath:
+			w.Write([]byte("ok"))
+			return
+		case r.ReadinessPath:
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+			return
+		}
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	h.ServeHTTP(w, req)
+}
+
+// Run loads the engine in the background, retrying until the first load succeeds, watches
+// its files and starts listening at the received address (:8080 by default) (see Serve)
+func (r *Reloader) Run(addr ...string) error {
+	go func() {
+		for {
+			err := r.Load()
+			if err == nil {
+				break
+			}
+			log.Println("loading the config:", err.Error())
+			time.Sleep(r.RetryInterval)
+		}
+		if err := r.Watch(nil); err != nil {
+			log.Println("watching the config:", err.Error())
+		}
+	}()
+	return r.Serve(addr...)
+}
+
+// Serve listens at the received address (:8080 by default) until the process gets a SIGINT or a
+// SIGTERM, letting the in-flight requests finish, and closes the engine. Every SIGHUP reloads the
+// engine, so the routes and the renderers are replaced without dropping the connections. If the
+// config has a TLS section, the requests are served over HTTPS (see ServeGracefullyTLS)
+func (r *Reloader) Serve(addr ...string) error {
+	address := ":8080"
+	if len(addr) > 0 {
+		address = addr[0]
+	}
+	defer func() {
+		if err := r.Close(); err != nil {
+			log.Println("closing the engine:", err.Error())
+		}
+	}()
+	if cfg, err := r.Factory.Parser(r.ConfigPath); err == nil && cfg.TLS != nil {
+		return ServeGracefullyTLS(address, r, *cfg.TLS, r.ShutdownTimeout, r.reload)
+	}
+	return ServeGracefully(address, r, r.ShutdownTimeout, r.reload)
+}
+
+// reload loads the engine again on SIGHUP, closing the replaced one (see Load)
+func (r *Reloader) reload() {
+	if err := r.Load(); err != nil {
+		log.Println("reloading the config:", err.Error())
+		return
+	}
+	log.Println("config reloaded")
+}
+
+// Close closes the served engine, if any. The Reloader is not ready anymore
+func (r *Reloader) Close() error {
+	r.mutex.Lock()
+	e := r.engine
+	r.engine = nil
+	r.mutex.Unlock()
+	if e == nil {
+		return nil
+	}
+	return e.Close()
+}
+
+// Watch reloads the engine when the contents of the folders of the config, the templates or the
+// layouts change. Since the folders are watched instead of the files, it detects the atomic
+// symlink swap done by the kubelet when a ConfigMap or a Secret is updated. The remote configs
+// are polled instead (see WatchSource). It blocks until the received channel is closed
+func (r *Reloader) Watch(stop <-chan struct{}) error {
+	if IsRemoteConfig(r.ConfigPath) {
+		source, err := NewConfigSource(r.ConfigPath, r.RemoteInterval)
+		if err != nil {
+			return err
+		}
+		r.WatchSource(source, stop)
+		return nil
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	r.addWatches(watcher)
+
+	var reload <-chan time.Time
+	for {
+		select {
+		case <-stop:
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			reload = time.After(r.Debounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Println("watching the config:", err.Error())
+		case <-reload:
+			reload = nil
+			if err := r.Load(); err != nil {
+				log.Println("reloading the config:", err.Error())
+				continue
+			}
+			log.Println("config reloaded")
+			r.addWatches(watcher)
+		}
+	}
+}
+
+// WatchSource reloads the engine every time the contents returned by the source change. The
+// first contents fetched are taken as the ones already loaded. It blocks until the received
+// channel is closed
+func (r *Reloader) WatchSource(source ConfigSource, stop <-chan struct{}) {
+	format := remoteConfigFormat(r.ConfigPath)
+	var current []byte
+	fetched := false
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		data, err := source.Fetch()
+		if err != nil {
+			log.Println("watching the config:", err.Error())
+			select {
+			case <-stop:
+				return
+			case <-time.After(r.RetryInterval):
+			}
+			continue
+		}
+		if !fetched || bytes.Equal(data, current) {
+			current, fetched = data, true
+			continue
+		}
+		current = data
+
+		cfg, err := ParseConfigFormat(bytes.NewReader(data), format)
+		if err == nil {
+			err = r.load(cfg)
+		}
+		if err != nil {
+			log.Println("reloading the config:", err.Error())
+			continue
+		}
+		log.Println("config reloaded")
+	}
+}
+
+func (r *Reloader) addWatches(watcher *fsnotify.Watcher) {
+	r.mutex.RLock()
+	dirs := r.dirs
+	r.mutex.RUnlock()
+	for _, dir := range dirs {
+		if err := watcher.Add(dir); err != nil && !os.IsNotExist(err) {
+			log.Println("watching", dir, ":", err.Error())
+		}
+	}
+}
+
+// reloaderDirs returns the folders containing the config, the included config files, the
+// templates, the layouts and the static pages
+func reloaderDirs(cfgPath string, cfg Config) []string {
+	seen := map[string]bool{}
+	dirs := []string{}
+	add := func(dir string) {
+		dir = filepath.Clean(dir)
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	add(filepath.Dir(cfgPath))
+	for _, dir := range cfg.includeDirs {
+		add(dir)
+	}
+	for _, section := range []map[string]string{cfg.Templates, cfg.Layouts} {
+		for _, path := range section {
+			add(filepath.Dir(path))
+		}
+	}
+	add("static")
+	return dirs
+}
@@ -0,0 +1,212 @@
+package engine
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// writeConfigMap mimics the kubelet updates of a mounted ConfigMap: the files are written into
+// a new folder and the ..data symlink is atomically swapped to point to it
+func writeConfigMap(t *testing.T, dir, version, content string) {
+	if err := os.Mkdir(filepath.Join(dir, version), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, version, "config.json"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tmp := filepath.Join(dir, "..data_tmp")
+	if err := os.Symlink(version, tmp); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "api2html-reload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfgPath := filepath.Join(dir, "config.json")
+	r := NewReloader(DefaultFactory, cfgPath, false)
+	r.Debounce = 10 * time.Millisecond
+
+	assertReloader(t, r, DefaultReadinessPath, http.StatusServiceUnavailable, "not ready")
+	assertReloader(t, r, DefaultHealthPath, http.StatusOK, "ok")
+	assertReloader(t, r, "/robots.txt", http.StatusServiceUnavailable, "Service Unavailable")
+	if err := r.Load(); err == nil {
+		t.Error("expecting an error")
+	}
+
+	robots := `{"robots_txt":{"rules":[{"disallow":["%s"]}]}}`
+	writeConfigMap(t, dir, "..2026_01_01", fmt.Sprintf(robots, "/a"))
+	if err := os.Symlink(filepath.Join("..data", "config.json"), cfgPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Load(); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	assertReloader(t, r, DefaultReadinessPath, http.StatusOK, "ready")
+	assertReloader(t, r, "/robots.txt", http.StatusOK, "Disallow: /a")
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go r.Watch(stop)
+	time.Sleep(200 * time.Millisecond)
+
+	writeConfigMap(t, dir, "..2026_01_02", fmt.Sprintf(robots, "/b"))
+	waitForReload(t, r, "Disallow: /b")
+
+	writeConfigMap(t, dir, "..2026_01_03", "{")
+	time.Sleep(200 * time.Millisecond)
+	assertReloader(t, r, "/robots.txt", http.StatusOK, "Disallow: /b")
+}
+
+func waitForReload(t *testing.T, r *Reloader, expected string) {
+	for i := 0; i < 50; i++ {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/robots.txt", nil))
+		if strings.Contains(w.Body.String(), expected) {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Errorf("the config was not reloaded. expecting: %s", expected)
+}
+
+func assertReloader(t *testing.T, r *Reloader, path string, status int, body string) {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+	if w.Code != status {
+		t.Errorf("%s: unexpected status code: %d", path, w.Code)
+	}
+	if !strings.Contains(w.Body.String(), body) {
+		t.Errorf("%s: unexpected body: %s", path, w.Body.String())
+	}
+}
+
+func TestReloader_closesReplacedEngines(t *testing.T) {
+	stores := []*TemplateStore{}
+	ef := DefaultFactory
+	ef.TemplateStoreFactory = func() *TemplateStore {
+		store := NewTemplateStore()
+		stores = append(stores, store)
+		return store
+	}
+	r := NewReloader(ef, "config.json", false)
+
+	for i := 0; i < 2; i++ {
+		if err := r.load(Config{}); err != nil {
+			t.Errorf("unexpected error: %s", err.Error())
+			return
+		}
+	}
+	if len(stores) != 2 {
+		t.Errorf("unexpected number of engines: %d", len(stores))
+		return
+	}
+	if !stores[0].closed() {
+		t.Error("the replaced engine was not closed")
+	}
+	if stores[1].closed() {
+		t.Error("the served engine was closed")
+	}
+	assertReloader(t, r, DefaultReadinessPath, http.StatusOK, "ready")
+}
+
+func TestReloader_closesEnginesOnSignals(t *testing.T) {
+	stores := []*TemplateStore{}
+	ef := DefaultFactory
+	ef.Parser = func(_ string) (Config, error) { return Config{}, nil }
+	ef.TemplateStoreFactory = func() *TemplateStore {
+		store := NewTemplateStore()
+		stores = append(stores, store)
+		return store
+	}
+	r := NewReloader(ef, "config.json", false)
+	if err := r.Load(); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	sigs := make(chan os.Signal)
+	served := make(chan error, 1)
+	go func() {
+		served <- serveGracefully([]listener{{&http.Server{Handler: r}, ln}}, sigs, time.Second, r.reload)
+	}()
+
+	sigs <- syscall.SIGHUP
+	sigs <- syscall.SIGTERM
+	if err := <-served; err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+	if len(stores) != 2 {
+		t.Errorf("unexpected number of engines: %d", len(stores))
+		return
+	}
+	if !stores[0].closed() {
+		t.Error("the engine replaced on SIGHUP was not closed")
+	}
+
+	if err := r.Close(); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+	if !stores[1].closed() {
+		t.Error("the served engine was not closed")
+	}
+	if r.Ready() {
+		t.Error("the reloader is still ready")
+	}
+}
+
+func TestReloader_keepsEngineUntilReady(t *testing.T) {
+	stores := []*TemplateStore{}
+	ef := DefaultFactory
+	ef.TemplateStoreFactory = func() *TemplateStore {
+		store := NewTemplateStore()
+		stores = append(stores, store)
+		return store
+	}
+	r := NewReloader(ef, "config.json", false)
+	r.ReadyTimeout = 50 * time.Millisecond
+
+	if err := r.load(Config{RobotsTXT: &RobotsTXT{Rules: []RobotsRule{{Disallow: []string{"/a"}}}}}); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+
+	// the template of the page is never published, so the new engine is never ready
+	cfg := Config{Pages: []Page{{URLPattern: "/a", Template: "missing"}}}
+	if err := r.load(cfg); err == nil {
+		t.Error("expecting an error")
+	}
+	if len(stores) != 2 {
+		t.Errorf("unexpected number of engines: %d", len(stores))
+		return
+	}
+	if stores[0].closed() {
+		t.Error("the served engine was closed")
+	}
+	if !stores[1].closed() {
+		t.Error("the engine not ready was not closed")
+	}
+	assertReloader(t, r, "/robots.txt", http.StatusOK, "Disallow: /a")
+}
@@ -0,0 +1,257 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// ConsulTokenEnvVar is the env var with the ACL token of the Consul KV requests
+	ConsulTokenEnvVar = "CONSUL_HTTP_TOKEN"
+
+	defaultRemoteConfigInterval = 30 * time.Second
+	defaultConsulKVAddress      = "127.0.0.1:8500"
+	defaultEtcdAddress          = "127.0.0.1:2379"
+)
+
+// ConfigSource defines the interface for loading the contents of a remote config
+type ConfigSource interface {
+	// Fetch returns the current contents of the config. It may block until they change
+	Fetch() ([]byte, error)
+}
+
+// IsRemoteConfig returns true if the config path is the URL of a remote config: an http or https
+// URL, a Consul key (consul://host:port/key) or an etcd key (etcd://host:port/key)
+func IsRemoteConfig(path string) bool {
+	for _, prefix := range []string{"http://", "https://", "consul://", "etcd://"} {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseConfigFromSource creates a Config with the contents of the remote config of the received
+// URL or, if it is not a remote one, with the contents of the local file (see
+// ParseConfigFromFile)
+func ParseConfigFromSource(path string) (Config, error) {
+	if !IsRemoteConfig(path) {
+		return ParseConfigFromFile(path)
+	}
+	source, err := NewConfigSource(path, defaultRemoteConfigInterval)
+	if err != nil {
+		return Config{}, err
+	}
+	data, err := source.Fetch()
+	if err != nil {
+		return Config{}, err
+	}
+	return ParseConfigFormat(bytes.NewReader(data), remoteConfigFormat(path))
+}
+
+// NewConfigSource creates the ConfigSource of the remote config URL. The Consul and etcd APIs
+// are requested over http unless the URL has a scheme=https query param. The interval is the
+// time between polls of the http and etcd sources and the max wait of the Consul blocking
+// queries
+func NewConfigSource(rawURL string, interval time.Duration) (ConfigSource, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if interval <= 0 {
+		interval = defaultRemoteConfigInterval
+	}
+	scheme := u.Query().Get("scheme")
+	if scheme == "" {
+		scheme = "http"
+	}
+	key := strings.TrimPrefix(u.Path, "/")
+
+	switch u.Scheme {
+	case "http", "https":
+		return &HTTPConfigSource{URL: rawURL, Interval: interval, Client: http.DefaultClient}, nil
+	case "consul":
+		host := u.Host
+		if host == "" {
+			host = defaultConsulKVAddress
+		}
+		return &ConsulConfigSource{
+			Address: scheme + "://" + host,
+			Key:     key,
+			Token:   os.Getenv(ConsulTokenEnvVar),
+			Wait:    interval,
+			Client:  http.DefaultClient,
+		}, nil
+	case "etcd":
+		host := u.Host
+		if host == "" {
+			host = defaultEtcdAddress
+		}
+		return &EtcdConfigSource{
+			Address:  scheme + "://" + host,
+			Key:      key,
+			Interval: interval,
+			Client:   http.DefaultClient,
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown config source: %s", u.Scheme)
+}
+
+// remoteConfigFormat returns the format selected by the extension of the path of the URL or an
+// empty string, so the contents are sniffed
+func remoteConfigFormat(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return ConfigFormat(u.Path)
+}
+
+// HTTPConfigSource is a ConfigSource polling an http or https URL
+type HTTPConfigSource struct {
+	URL      string
+	Interval time.Duration
+	Client   *http.Client
+	// the ETag and the contents of the last response, for the conditional requests
+	etag      string
+	data      []byte
+	lastFetch time.Time
+}
+
+// Fetch implements the ConfigSource interface. Every call after the first one waits for the
+// interval to pass
+func (s *HTTPConfigSource) Fetch() ([]byte, error) {
+	if !s.lastFetch.IsZero() {
+		time.Sleep(time.Until(s.lastFetch.Add(s.Interval)))
+	}
+	s.lastFetch = time.Now()
+
+	req, err := http.NewRequest(http.MethodGet, s.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.etag != "" {
+		req.Header.Set("If-None-Match", s.etag)
+	}
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return s.data, nil
+	case http.StatusOK:
+	default:
+		return nil, fmt.Errorf("config source: unexpected status code %d", resp.StatusCode)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	s.etag = resp.Header.Get("ETag")
+	s.data = data
+	return data, nil
+}
+
+// ConsulConfigSource is a ConfigSource watching a key of the Consul KV store with blocking
+// queries
+type ConsulConfigSource struct {
+	Address string
+	Key     string
+	// Token is the ACL token of the requests
+	Token string
+	// Wait is the max time a blocking query waits for a change
+	Wait   time.Duration
+	Client *http.Client
+	index  string
+}
+
+// Fetch implements the ConfigSource interface. Every call after the first one blocks until the
+// key changes or the wait time expires
+func (s *ConsulConfigSource) Fetch() ([]byte, error) {
+	query := url.Values{}
+	query.Set("raw", "")
+	query.Set("wait", fmt.Sprintf("%ds", int(s.Wait.Seconds())))
+	if s.index != "" {
+		query.Set("index", s.index)
+	}
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/v1/kv/%s?%s", strings.TrimSuffix(s.Address, "/"), s.Key, query.Encode()), nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.Token != "" {
+		req.Header.Set("X-Consul-Token", s.Token)
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("consul: unexpected status code %d", resp.StatusCode)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	s.index = resp.Header.Get("X-Consul-Index")
+	return data, nil
+}
+
+// EtcdConfigSource is a ConfigSource polling a key of etcd through the JSON gateway of its v3
+// API
+type EtcdConfigSource struct {
+	Address   string
+	Key       string
+	Interval  time.Duration
+	Client    *http.Client
+	lastFetch time.Time
+}
+
+type etcdRangeResponse struct {
+	Kvs []struct {
+		Value string `json:"value"`
+	} `json:"kvs"`
+}
+
+// Fetch implements the ConfigSource interface. Every call after the first one waits for the
+// interval to pass
+func (s *EtcdConfigSource) Fetch() ([]byte, error) {
+	if !s.lastFetch.IsZero() {
+		time.Sleep(time.Until(s.lastFetch.Add(s.Interval)))
+	}
+	s.lastFetch = time.Now()
+
+	body, err := json.Marshal(map[string]string{"key": base64.StdEncoding.EncodeToString([]byte(s.Key))})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.Client.Post(strings.TrimSuffix(s.Address, "/")+"/v3/kv/range", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("etcd: unexpected status code %d", resp.StatusCode)
+	}
+
+	var r etcdRangeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, err
+	}
+	if len(r.Kvs) == 0 {
+		return nil, fmt.Errorf("etcd: key not found: %s", s.Key)
+	}
+	return base64.StdEncoding.DecodeString(r.Kvs[0].Value)
+}
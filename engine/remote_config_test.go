@@ -0,0 +1,206 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestIsRemoteConfig(t *testing.T) {
+	for path, expected := range map[string]bool{
+		"config.json":                     false,
+		"/etc/api2html/config.yml":        false,
+		"https://example.com/config.json": true,
+		"http://example.com/config":       true,
+		"consul://localhost:8500/a/b":     true,
+		"etcd:///api2html/config.toml":    true,
+	} {
+		if IsRemoteConfig(path) != expected {
+			t.Errorf("%s: expecting %v", path, expected)
+		}
+	}
+}
+
+func TestNewConfigSource(t *testing.T) {
+	s, err := NewConfigSource("consul://consul:8500/api2html/config.json?scheme=https", time.Second)
+	if c, ok := s.(*ConsulConfigSource); err != nil || !ok || c.Address != "https://consul:8500" || c.Key != "api2html/config.json" || c.Wait != time.Second {
+		t.Errorf("unexpected source: %+v %v", s, err)
+	}
+	s, err = NewConfigSource("etcd:///api2html/config.yml", 0)
+	if e, ok := s.(*EtcdConfigSource); err != nil || !ok || e.Address != "http://"+defaultEtcdAddress || e.Key != "api2html/config.yml" || e.Interval != defaultRemoteConfigInterval {
+		t.Errorf("unexpected source: %+v %v", s, err)
+	}
+	s, err = NewConfigSource("https://example.com/config.json", time.Second)
+	if h, ok := s.(*HTTPConfigSource); err != nil || !ok || h.URL != "https://example.com/config.json" {
+		t.Errorf("unexpected source: %+v %v", s, err)
+	}
+	if _, err := NewConfigSource("zookeeper://localhost/config.json", time.Second); err == nil {
+		t.Error("expecting an error")
+	}
+}
+
+func TestHTTPConfigSource(t *testing.T) {
+	conditional := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/config.json" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			conditional++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, `{"pages": []}`)
+	}))
+	defer ts.Close()
+
+	s := &HTTPConfigSource{URL: ts.URL + "/config.json", Interval: 10 * time.Millisecond, Client: http.DefaultClient}
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		data, err := s.Fetch()
+		if err != nil {
+			t.Errorf("unexpected error: %s", err.Error())
+			return
+		}
+		if string(data) != `{"pages": []}` {
+			t.Errorf("unexpected contents: %s", data)
+		}
+	}
+	if conditional != 1 || time.Since(start) < 10*time.Millisecond {
+		t.Errorf("unexpected polling: %d conditional requests in %s", conditional, time.Since(start))
+	}
+
+	s = &HTTPConfigSource{URL: ts.URL + "/unknown.json", Client: http.DefaultClient}
+	if _, err := s.Fetch(); err == nil {
+		t.Error("expecting an error")
+	}
+}
+
+func TestConsulConfigSource(t *testing.T) {
+	queries := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/kv/api2html/config.json" || r.Header.Get("X-Consul-Token") != "secret" {
+			http.NotFound(w, r)
+			return
+		}
+		if _, ok := r.URL.Query()["raw"]; !ok {
+			t.Error("the raw value was not requested")
+		}
+		queries = append(queries, r.URL.Query().Get("index"))
+		w.Header().Set("X-Consul-Index", "42")
+		fmt.Fprint(w, `{"pages": []}`)
+	}))
+	defer ts.Close()
+
+	s := &ConsulConfigSource{Address: ts.URL, Key: "api2html/config.json", Token: "secret", Wait: time.Second, Client: http.DefaultClient}
+	for i := 0; i < 2; i++ {
+		data, err := s.Fetch()
+		if err != nil {
+			t.Errorf("unexpected error: %s", err.Error())
+			return
+		}
+		if string(data) != `{"pages": []}` {
+			t.Errorf("unexpected contents: %s", data)
+		}
+	}
+	if len(queries) != 2 || queries[0] != "" || queries[1] != "42" {
+		t.Errorf("unexpected blocking queries: %v", queries)
+	}
+
+	s.Key = "unknown"
+	if _, err := s.Fetch(); err == nil {
+		t.Error("expecting an error")
+	}
+}
+
+func TestEtcdConfigSource(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Key string `json:"key"`
+		}
+		if r.URL.Path != "/v3/kv/range" || json.NewDecoder(r.Body).Decode(&req) != nil {
+			http.NotFound(w, r)
+			return
+		}
+		if key, _ := base64.StdEncoding.DecodeString(req.Key); string(key) != "api2html/config.json" {
+			fmt.Fprint(w, `{"header": {}}`)
+			return
+		}
+		fmt.Fprintf(w, `{"kvs": [{"value": %q}]}`, base64.StdEncoding.EncodeToString([]byte(`{"pages": []}`)))
+	}))
+	defer ts.Close()
+
+	s := &EtcdConfigSource{Address: ts.URL, Key: "api2html/config.json", Interval: time.Millisecond, Client: http.DefaultClient}
+	data, err := s.Fetch()
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if string(data) != `{"pages": []}` {
+		t.Errorf("unexpected contents: %s", data)
+	}
+
+	s.Key = "unknown"
+	if _, err := s.Fetch(); err == nil {
+		t.Error("expecting an error")
+	}
+}
+
+func TestParseConfigFromSource(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "pages:\n  - name: home\n    URLPattern: /\n")
+	}))
+	defer ts.Close()
+
+	cfg, err := ParseConfigFromSource(ts.URL + "/config.yml")
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if len(cfg.Pages) != 1 || cfg.Pages[0].URLPattern != "/" {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+
+	if _, err := ParseConfigFromSource("unknown.json"); err == nil {
+		t.Error("expecting an error")
+	}
+}
+
+type fakeConfigSource chan []byte
+
+func (f fakeConfigSource) Fetch() ([]byte, error) {
+	return <-f, nil
+}
+
+func TestReloader_WatchSource(t *testing.T) {
+	r := NewReloader(DefaultFactory, "https://example.com/config.json", false)
+	robots := `{"robots_txt":{"rules":[{"disallow":["%s"]}]}}`
+	cfg, err := ParseConfigFormat(bytes.NewBufferString(fmt.Sprintf(robots, "/a")), ConfigFormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.load(cfg); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+
+	source := make(fakeConfigSource)
+	stop := make(chan struct{})
+	defer close(stop)
+	go r.WatchSource(source, stop)
+
+	source <- []byte(fmt.Sprintf(robots, "/a"))
+	source <- []byte(fmt.Sprintf(robots, "/b"))
+	waitForReload(t, r, "Disallow: /b")
+
+	source <- []byte("{")
+	source <- []byte("{")
+	assertReloader(t, r, "/robots.txt", http.StatusOK, "Disallow: /b")
+}
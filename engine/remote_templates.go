@@ -0,0 +1,174 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"gocloud.dev/blob"
+	// the drivers of the supported buckets
+	_ "gocloud.dev/blob/fileblob"
+	_ "gocloud.dev/blob/gcsblob"
+	_ "gocloud.dev/blob/s3blob"
+)
+
+const defaultRemoteTemplatesInterval = 30 * time.Second
+
+// NewRemoteTemplateSource opens the bucket of the config and downloads its templates, layouts and
+// partials
+func NewRemoteTemplateSource(ctx context.Context, cfg RemoteTemplates) (*RemoteTemplateSource, error) {
+	bucket, err := blob.OpenBucket(ctx, cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	s := NewRemoteTemplateSourceFromBucket(bucket, cfg.Prefix, parseDurationOr(cfg.Interval, defaultRemoteTemplatesInterval))
+	if _, err := s.Sync(ctx); err != nil {
+		bucket.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// NewRemoteTemplateSourceFromBucket creates an empty RemoteTemplateSource with the received bucket
+func NewRemoteTemplateSourceFromBucket(bucket *blob.Bucket, prefix string, interval time.Duration) *RemoteTemplateSource {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return &RemoteTemplateSource{
+		Bucket:   bucket,
+		Prefix:   prefix,
+		Interval: interval,
+		files:    map[string]remoteFile{},
+	}
+}
+
+// RemoteTemplateSource is an fs.FS with the files under the prefix of a S3 or GCS bucket,
+// downloaded into memory. The paths of the templates, layouts and partials in the config are
+// relative to the prefix
+type RemoteTemplateSource struct {
+	Bucket   *blob.Bucket
+	Prefix   string
+	Interval time.Duration
+	mutex    sync.RWMutex
+	files    map[string]remoteFile
+}
+
+type remoteFile struct {
+	data    []byte
+	modTime time.Time
+	version string
+}
+
+// Open implements the fs.FS interface
+func (s *RemoteTemplateSource) Open(name string) (fs.File, error) {
+	s.mutex.RLock()
+	f, ok := s.files[name]
+	s.mutex.RUnlock()
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return &memFile{bytes.NewReader(f.data), memFileInfo{name, f}}, nil
+}
+
+// Sync downloads the new and updated files of the bucket, forgets the deleted ones and returns
+// the paths of all of them
+func (s *RemoteTemplateSource) Sync(ctx context.Context) ([]string, error) {
+	listed := map[string]remoteFile{}
+	it := s.Bucket.List(&blob.ListOptions{Prefix: s.Prefix})
+	for {
+		obj, err := it.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if obj.IsDir {
+			continue
+		}
+		listed[strings.TrimPrefix(obj.Key, s.Prefix)] = remoteFile{
+			modTime: obj.ModTime,
+			version: obj.ModTime.String() + string(obj.MD5),
+		}
+	}
+
+	s.mutex.RLock()
+	current := s.files
+	s.mutex.RUnlock()
+
+	changed := []string{}
+	files := make(map[string]remoteFile, len(listed))
+	for name, f := range listed {
+		if prev, ok := current[name]; ok && prev.version == f.version {
+			files[name] = prev
+			continue
+		}
+		data, err := s.Bucket.ReadAll(ctx, s.Prefix+name)
+		if err != nil {
+			return nil, err
+		}
+		f.data = data
+		files[name] = f
+		changed = append(changed, name)
+	}
+	for name := range current {
+		if _, ok := listed[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+
+	s.mutex.Lock()
+	s.files = files
+	s.mutex.Unlock()
+	return changed, nil
+}
+
+// Watch polls the bucket every Interval, reloading the renderers depending on the changed files.
+// The returned function stops polling it
+func (s *RemoteTemplateSource) Watch(g *TemplateGraph, store *TemplateStore) func() {
+	ticker := time.NewTicker(s.Interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				ticker.Stop()
+				return
+			case <-ticker.C:
+			}
+			changed, err := s.Sync(context.Background())
+			if err != nil {
+				log.Println("syncing the remote templates:", err.Error())
+				continue
+			}
+			g.ReloadPaths(store, changed)
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+type memFile struct {
+	*bytes.Reader
+	info memFileInfo
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *memFile) Close() error               { return nil }
+
+type memFileInfo struct {
+	name string
+	file remoteFile
+}
+
+func (i memFileInfo) Name() string       { return i.name[strings.LastIndex(i.name, "/")+1:] }
+func (i memFileInfo) Size() int64        { return int64(len(i.file.data)) }
+func (i memFileInfo) Mode() fs.FileMode  { return 0444 }
+func (i memFileInfo) ModTime() time.Time { return i.file.modTime }
+func (i memFileInfo) IsDir() bool        { return false }
+func (i memFileInfo) Sys() interface{}   { return nil }
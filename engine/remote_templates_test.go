@@ -0,0 +1,79 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"gocloud.dev/blob/memblob"
+)
+
+func TestRemoteTemplateSource(t *testing.T) {
+	ctx := context.Background()
+	bucket := memblob.OpenBucket(nil)
+	defer bucket.Close()
+	for key, content := range map[string]string{
+		"site/home.mustache":          "hi, {{> partials/name }}!",
+		"site/partials/name.mustache": "stranger",
+		"other/ignored.mustache":      "ignored",
+	} {
+		if err := bucket.WriteAll(ctx, key, []byte(content), nil); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	src := NewRemoteTemplateSourceFromBucket(bucket, "site", 10*time.Millisecond)
+	changed, err := src.Sync(ctx)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if len(changed) != 2 {
+		t.Errorf("unexpected changes: %v", changed)
+	}
+	f, err := src.Open("home.mustache")
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	data, _ := ioutil.ReadAll(f)
+	f.Close()
+	if string(data) != "hi, {{> partials/name }}!" {
+		t.Errorf("unexpected content: %s", string(data))
+	}
+	if _, err := src.Open("ignored.mustache"); err == nil {
+		t.Error("expecting an error")
+	}
+
+	cfg := Config{
+		Templates: map[string]string{"home": "home.mustache"},
+		Pages:     []Page{{Name: "home", Template: "home"}},
+	}
+	graph, err := NewTemplateGraph(src, cfg)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	store := NewTemplateStore()
+	stop := src.Watch(graph, store)
+	defer stop()
+
+	if err := bucket.WriteAll(ctx, "site/partials/name.mustache", []byte("Jane"), nil); err != nil {
+		t.Error(err)
+		return
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	r, ok := store.Get("home")
+	if !ok {
+		t.Error("the template has not been reloaded")
+		return
+	}
+	buf := &bytes.Buffer{}
+	if err := r.Render(buf, nil); err != nil || buf.String() != "hi, Jane!" {
+		t.Errorf("unexpected render: %s %v", buf.String(), err)
+	}
+}
@@ -0,0 +1,71 @@
+package engine
+
+import (
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const renderProxyPage = "render_proxy"
+
+// pages returns the pages rendering the routes and the unmatched paths, in matching order
+func (r RenderProxy) pages() []Page {
+	base := Page{
+		Name:              renderProxyPage,
+		URLPattern:        r.prefix() + "/*path",
+		BackendURLPattern: r.Backend,
+		Template:          r.Template,
+		Layout:            r.Layout,
+		CacheTTL:          r.CacheTTL,
+	}
+	pages := []Page{}
+	for _, route := range r.Routes {
+		page := base
+		page.Name += ":" + route.Pattern
+		if route.Template != "" {
+			page.Template = route.Template
+		}
+		if route.Layout != "" {
+			page.Layout = route.Layout
+		}
+		pages = append(pages, page)
+	}
+	return append(pages, base)
+}
+
+func (r RenderProxy) prefix() string {
+	return strings.TrimSuffix(r.Prefix, "/")
+}
+
+// NewRenderProxyHandlerConfig creates the HandlerConfig of a render proxy page, requesting the
+// proxied path to the backend with the client of the page and decoding both objects and arrays
+func NewRenderProxyHandlerConfig(cfg RenderProxy, page Page) HandlerConfig {
+	rg := DynamicResponseGenerator{
+		Page:    page,
+		Backend: NewProxyBackend(pageClient(page), cfg.Backend, cfg.prefix()),
+		Decoder: JSONAutoDecoder,
+	}
+	return HandlerConfig{
+		page,
+		DefaultHandlerConfig.Renderer,
+		rg.ResponseGenerator,
+		cacheControl(page.CacheTTL),
+	}
+}
+
+// renderProxyHandlerFunc dispatches the requests to the handler of the first route matching
+// their path or to the last handler
+func renderProxyHandlerFunc(cfg RenderProxy, handlers []*Handler) gin.HandlerFunc {
+	prefix := cfg.prefix()
+	return func(c *gin.Context) {
+		p := strings.TrimPrefix(c.Request.URL.Path, prefix)
+		for i, route := range cfg.Routes {
+			if ok, _ := path.Match(route.Pattern, p); ok {
+				handlers[i].HandlerFunc(c)
+				return
+			}
+		}
+		handlers[len(handlers)-1].HandlerFunc(c)
+	}
+}
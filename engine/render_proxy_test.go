@@ -0,0 +1,69 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestFactory_New_renderProxy(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/products":
+			w.Write([]byte(`[{"name":"a"},{"name":"b"}]`))
+		case "/products/1":
+			w.Write([]byte(`{"name":"a"}`))
+		case "/about":
+			w.Write([]byte(`{"lang":"` + r.URL.Query().Get("lang") + `"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer backend.Close()
+
+	ef := DefaultFactory
+	ef.TemplateFS = fstest.MapFS{
+		"list.mustache":    {Data: []byte("{{#Array}}{{name}},{{/Array}}")},
+		"item.mustache":    {Data: []byte("item {{Data.name}}")},
+		"default.mustache": {Data: []byte("{{Params.path}} {{Data}}")},
+		"layout.mustache":  {Data: []byte("[{{{ content }}}]")},
+	}
+	ef.Parser = func(_ string) (Config, error) {
+		return Config{
+			Templates: map[string]string{"list": "list.mustache", "item": "item.mustache", "default": "default.mustache"},
+			Layouts:   map[string]string{"layout": "layout.mustache"},
+			RenderProxy: &RenderProxy{
+				Prefix:   "/api/",
+				Backend:  backend.URL,
+				Template: "default",
+				Routes: []RenderProxyRoute{
+					{Pattern: "/products", Template: "list"},
+					{Pattern: "/products/*", Template: "item", Layout: "layout"},
+				},
+				CacheTTL: "1m",
+			},
+		}, nil
+	}
+
+	e, err := ef.New("something", false)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	assertResponse(t, e, "/api/products", http.StatusOK, "a,b,")
+	assertResponse(t, e, "/api/products/1", http.StatusOK, "[item a]")
+	assertResponse(t, e, "/api/about?lang=en", http.StatusOK, "/about map[lang:en]")
+	assertResponse(t, e, "/api/missing", http.StatusNotFound, "Not Found")
+	assertResponse(t, e, "/api/../admin", http.StatusNotFound, "Not Found")
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/products", nil)
+	e.ServeHTTP(w, req)
+	if cc := w.Header().Get("Cache-Control"); cc != "public, max-age=60" {
+		t.Errorf("unexpected Cache-Control header: %s", cc)
+	}
+}
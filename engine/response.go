@@ -2,12 +2,11 @@ package engine
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	newrelic "github.com/newrelic/go-agent"
-	nrgin "github.com/newrelic/go-agent/_integrations/nrgin/v1"
 )
 
 // ResponseContext is the struct ready to rendered and returned to the Handler
@@ -20,6 +19,18 @@ type ResponseContext struct {
 	Extra map[string]interface{}
 	// Params stores the params of the request
 	Params map[string]string
+	// Robots contains the robots directives of the page
+	Robots string `json:",omitempty"`
+	// Geo contains the location of the client, if the GeoIP is enabled
+	Geo *GeoLocation `json:",omitempty"`
+	// Errors contains the errors returned by a GraphQL backend along with a partial response
+	Errors []GraphQLError `json:",omitempty"`
+	// Missing flags the additional backends that exceeded the budget of the page
+	Missing map[string]bool `json:",omitempty"`
+	// Error describes the failure rendered by the error templates
+	Error *ErrorContext `json:",omitempty"`
+	// Stream marks the position of the streamed items in the pages with a stream
+	Stream string `json:"-"`
 	// Helper is a struct containing a few basic template helpers
 	Helper interface{} `json:"-"`
 	// 	Context is a reference to the gin context for the request
@@ -36,6 +47,54 @@ func (r *ResponseContext) String() string {
 	return string(d)
 }
 
+// requestExtra returns the Extra of the response context of the request, adding the claims of
+// the user authenticated by the jwt middleware as user, the CSP nonce as csp_nonce, the locale
+// of the request as locale and its canonical URL as canonical_url
+func requestExtra(c *gin.Context, extra map[string]interface{}) map[string]interface{} {
+	if c == nil {
+		return extra
+	}
+	user, hasUser := c.Get(jwtUserKey)
+	nonce := CSPNonce(c)
+	locale := LocaleFromContext(c)
+	canonical := CanonicalURLFromContext(c)
+	if !hasUser && nonce == "" && locale == "" && canonical == "" {
+		return extra
+	}
+	result := make(map[string]interface{}, len(extra)+4)
+	for k, v := range extra {
+		result[k] = v
+	}
+	if hasUser {
+		result["user"] = user
+	}
+	if nonce != "" {
+		result["csp_nonce"] = nonce
+	}
+	if locale != "" {
+		result["locale"] = locale
+	}
+	if canonical != "" {
+		result["canonical_url"] = canonical
+	}
+	return result
+}
+
+// requestParams returns the route params of the request. The locale of the request, if any, is
+// added as the lang param unless the route already has one
+func requestParams(c *gin.Context) map[string]string {
+	params := make(map[string]string, len(c.Params)+1)
+	for _, v := range c.Params {
+		params[v.Key] = v.Value
+	}
+	if _, ok := params[LangParam]; !ok {
+		if locale := LocaleFromContext(c); locale != "" {
+			params[LangParam] = locale
+		}
+	}
+	return params
+}
+
 // ResponseGenerator is a function that, given a gin request, returns a response struc and an error
 type ResponseGenerator func(*gin.Context) (ResponseContext, error)
 
@@ -53,18 +112,14 @@ type StaticResponseGenerator struct {
 
 // ResponseGenerator implements the ResponseGenerator interface
 func (s *StaticResponseGenerator) ResponseGenerator(c *gin.Context) (ResponseContext, error) {
-	if newrelicApp != nil {
-		defer newrelic.StartSegment(nrgin.Transaction(c), "Request manipulation").End()
-	}
-	params := map[string]string{}
-	for _, v := range c.Params {
-		params[v.Key] = v.Value
-	}
+	params := requestParams(c)
 	target := ResponseContext{
 		Extra:   s.Page.Extra,
+		Robots:  s.Page.Robots,
 		Context: c,
 		Params:  params,
-		Helper:  &tplHelper{},
+		Geo:     GeoFromContext(c),
+		Helper:  newTplHelper(c),
 	}
 	return target, nil
 }
@@ -80,44 +135,44 @@ type DynamicResponseGenerator struct {
 
 // ResponseGenerator implements the ResponseGenerator interface
 func (drg *DynamicResponseGenerator) ResponseGenerator(c *gin.Context) (ResponseContext, error) {
-	var segment newrelic.Segment
-	if newrelicApp != nil {
-		segment = newrelic.StartSegment(nrgin.Transaction(c), "Request manipulation")
-	}
-
-	params := map[string]string{}
-	for _, v := range c.Params {
-		params[v.Key] = v.Value
-	}
-	headers := map[string]string{}
-	h := c.Request.Header.Get(drg.Page.Header)
-	if h != "" {
-		headers[drg.Page.Header] = h
-	}
+	params := requestParams(c)
+	headers := backendHeaders(drg.Page, c)
 	result := ResponseContext{
 		Extra:   drg.Page.Extra,
+		Robots:  drg.Page.Robots,
 		Context: c,
 		Params:  params,
-		Helper:  &tplHelper{},
+		Geo:     GeoFromContext(c),
+		Helper:  newTplHelper(c),
 	}
-	segment.End()
 
 	resp, err := drg.Backend(params, headers, c)
 	if err != nil {
-		return result, err
+		return result, newBackendError(err)
 	}
-
-	if newrelicApp != nil {
-		segment = newrelic.StartSegment(nrgin.Transaction(c), "Decoder")
+	if _, ok := drg.Page.StatusMapping[resp.StatusCode]; ok {
+		resp.Body.Close()
+		return result, newDecodeError(resp, fmt.Errorf("unexpected status code %d", resp.StatusCode))
 	}
+
+	done := HooksFromContext(c).OnDecode(c)
 	err = drg.Decoder(resp.Body, &result)
 	resp.Body.Close()
-	segment.End()
+	done(err)
+	if err != nil {
+		return result, newDecodeError(resp, err)
+	}
+
+	return result, nil
+}
 
-	return result, err
+func newTplHelper(c *gin.Context) *tplHelper {
+	return &tplHelper{Assets: AssetsFromContext(c)}
 }
 
 type tplHelper struct {
+	// Assets contains the assets of the public folder, if their hashing is enabled
+	Assets AssetManifest
 }
 
 func (tplHelper) Now() string {
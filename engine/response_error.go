@@ -0,0 +1,228 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorStatusCodes are the status codes with a custom error template, loaded from the static
+// folder (static/502...), besides the 500 one
+var ErrorStatusCodes = []int{
+	http.StatusNotFound,
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// ResponseError is the error returned by the ResponseGenerators in order to choose the status
+// code of the response and its error template
+type ResponseError struct {
+	// StatusCode is the status code of the response
+	StatusCode int
+	// BackendURL is the URL of the failed backend request, if any
+	BackendURL string
+	// BackendStatus is the status code of the failed backend response, if any
+	BackendStatus int
+	// Retryable flags the errors that may not happen again, like the timeouts
+	Retryable bool
+	// Message is the description of the error safe to show to the users. Defaults to the
+	// status text
+	Message string
+	// Location is the target of the redirections
+	Location string
+	// RetryAfter is the number of seconds the clients should wait before retrying the request
+	RetryAfter int
+	// Err is the wrapped error
+	Err error
+}
+
+// Error implements the error interface
+func (e *ResponseError) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = http.StatusText(e.StatusCode)
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	if e.BackendURL != "" {
+		msg += " (" + e.BackendURL + ")"
+	}
+	return msg
+}
+
+// Unwrap returns the wrapped error
+func (e *ResponseError) Unwrap() error {
+	return e.Err
+}
+
+// UserMessage returns the Message or, if empty, the status text
+func (e *ResponseError) UserMessage() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return http.StatusText(e.StatusCode)
+}
+
+// ErrorStatusCode returns the status code of the received error: the one of the ResponseError it
+// wraps or 500
+func ErrorStatusCode(err error) int {
+	var re *ResponseError
+	if errors.As(err, &re) && re.StatusCode != 0 {
+		return re.StatusCode
+	}
+	return http.StatusInternalServerError
+}
+
+// errorLocation returns the target of the redirection of the received error, if any
+func errorLocation(err error) string {
+	var re *ResponseError
+	if errors.As(err, &re) {
+		return re.Location
+	}
+	return ""
+}
+
+// errorRetryAfter returns the seconds to wait before retrying the request failed with the
+// received error, if known
+func errorRetryAfter(err error) int {
+	var re *ResponseError
+	if errors.As(err, &re) {
+		return re.RetryAfter
+	}
+	return 0
+}
+
+func errorRetryable(err error) bool {
+	var re *ResponseError
+	return errors.As(err, &re) && re.Retryable
+}
+
+// newBackendError returns the ResponseError of a failed backend call
+func newBackendError(err error) *ResponseError {
+	re := &ResponseError{StatusCode: http.StatusBadGateway, Retryable: true, Err: err}
+	var uerr *url.Error
+	if errors.As(err, &uerr) {
+		re.BackendURL = uerr.URL
+	}
+	var nerr net.Error
+	switch {
+	case err == ErrNoBackendTargets:
+		re.StatusCode = http.StatusServiceUnavailable
+	case err == ErrPathOutsidePrefix:
+		re.StatusCode = http.StatusNotFound
+		re.Retryable = false
+	case errors.As(err, &nerr) && nerr.Timeout():
+		re.StatusCode = http.StatusGatewayTimeout
+	}
+	return re
+}
+
+// newDecodeError returns the ResponseError of a backend response that could not be decoded,
+// keeping the not found responses as such
+func newDecodeError(resp *http.Response, err error) *ResponseError {
+	re := &ResponseError{StatusCode: http.StatusBadGateway, BackendStatus: resp.StatusCode, Err: err}
+	if resp.Request != nil && resp.Request.URL != nil {
+		re.BackendURL = resp.Request.URL.String()
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		re.StatusCode = http.StatusNotFound
+	case resp.StatusCode >= http.StatusInternalServerError:
+		re.Retryable = true
+	}
+	return re
+}
+
+// withStatusMapping wraps the ResponseGenerator replacing the ResponseErrors of the failed
+// backend responses with the responses declared by the status mapping of the page
+func withStatusMapping(page Page, rg ResponseGenerator) ResponseGenerator {
+	if len(page.StatusMapping) == 0 {
+		return rg
+	}
+	return func(c *gin.Context) (ResponseContext, error) {
+		result, err := rg(c)
+		var re *ResponseError
+		if err == nil || !errors.As(err, &re) {
+			return result, err
+		}
+		m, ok := page.StatusMapping[re.BackendStatus]
+		if !ok {
+			return result, err
+		}
+		mapped := *re
+		if m.Status != 0 {
+			mapped.StatusCode = m.Status
+		}
+		if m.Redirect != "" {
+			mapped.Location = m.Redirect
+			if m.ReturnParam != "" && c != nil && c.Request != nil {
+				sep := "?"
+				if strings.Contains(m.Redirect, "?") {
+					sep = "&"
+				}
+				mapped.Location += sep + m.ReturnParam + "=" + url.QueryEscape(c.Request.URL.RequestURI())
+			}
+			if m.Status == 0 {
+				mapped.StatusCode = http.StatusFound
+			}
+		}
+		if m.RetryAfter > 0 {
+			mapped.RetryAfter = m.RetryAfter
+		}
+		return result, &mapped
+	}
+}
+
+// mergeStatusMappings returns the status mapping of a page, with the mappings of the page taking
+// precedence over the global ones
+func mergeStatusMappings(global, page map[int]StatusMapping) map[int]StatusMapping {
+	if len(global) == 0 {
+		return page
+	}
+	merged := make(map[int]StatusMapping, len(global)+len(page))
+	for status, m := range global {
+		merged[status] = m
+	}
+	for status, m := range page {
+		merged[status] = m
+	}
+	return merged
+}
+
+// validStatusMapping returns an error if a mapping has an invalid status code
+func validStatusMapping(mapping map[int]StatusMapping) error {
+	for backend, m := range mapping {
+		switch {
+		case m.Status == 0:
+		case m.Redirect != "" && (m.Status < 300 || m.Status > 399):
+			return fmt.Errorf("status mapping %d: invalid redirection status %d", backend, m.Status)
+		case m.Status < 300 || m.Status > 599:
+			return fmt.Errorf("status mapping %d: invalid status %d", backend, m.Status)
+		}
+	}
+	return nil
+}
+
+// ResponseErrorMessageHandler returns a gin middleware writing the user message of the
+// ResponseErrors aborting the requests when no error template has written the response
+func ResponseErrorMessageHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if !c.IsAborted() || c.Writer.Size() > 0 || len(c.Errors) == 0 {
+			return
+		}
+		var re *ResponseError
+		if errors.As(c.Errors.Last().Err, &re) {
+			c.Writer.Write([]byte(re.UserMessage()))
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestErrorStatusCode(t *testing.T) {
+	for _, tc := range []struct {
+		err    error
+		status int
+	}{
+		{err: fmt.Errorf("boom"), status: http.StatusInternalServerError},
+		{err: &ResponseError{StatusCode: http.StatusNotFound}, status: http.StatusNotFound},
+		{err: &ResponseError{}, status: http.StatusInternalServerError},
+		{err: newBackendError(ErrNoBackendTargets), status: http.StatusServiceUnavailable},
+		{err: newBackendError(&url.Error{Op: "Get", URL: "http://a", Err: context.DeadlineExceeded}), status: http.StatusGatewayTimeout},
+		{err: newBackendError(&url.Error{Op: "Get", URL: "http://a", Err: fmt.Errorf("refused")}), status: http.StatusBadGateway},
+		{err: newDecodeError(&http.Response{StatusCode: http.StatusNotFound}, io.EOF), status: http.StatusNotFound},
+		{err: newDecodeError(&http.Response{StatusCode: http.StatusOK}, io.EOF), status: http.StatusBadGateway},
+	} {
+		if status := ErrorStatusCode(tc.err); status != tc.status {
+			t.Errorf("%v: unexpected status code: %d", tc.err, status)
+		}
+	}
+
+	err := newBackendError(&url.Error{Op: "Get", URL: "http://api.example.com/a", Err: fmt.Errorf("refused")})
+	if err.BackendURL != "http://api.example.com/a" || !err.Retryable {
+		t.Errorf("unexpected error: %+v", err)
+	}
+	if err.Error() != `Bad Gateway: Get "http://api.example.com/a": refused (http://api.example.com/a)` {
+		t.Errorf("unexpected message: %s", err.Error())
+	}
+}
+
+func TestHandler_responseError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(ResponseErrorMessageHandler())
+	notFound := ErrorHandler{[]byte("not found template"), http.StatusNotFound}
+	e.Use(notFound.HandlerFunc())
+
+	for path, err := range map[string]error{
+		"/missing":     &ResponseError{StatusCode: http.StatusNotFound},
+		"/unavailable": &ResponseError{StatusCode: http.StatusServiceUnavailable, Retryable: true, Message: "try again later"},
+		"/broken":      fmt.Errorf("boom"),
+	} {
+		err := err
+		h := &Handler{
+			Renderer:          EmptyRenderer,
+			ResponseGenerator: func(_ *gin.Context) (ResponseContext, error) { return ResponseContext{}, err },
+		}
+		e.GET(path, h.HandlerFunc)
+	}
+
+	assertResponse(t, e, "/missing", http.StatusNotFound, "not found template")
+	assertResponse(t, e, "/unavailable", http.StatusServiceUnavailable, "try again later")
+	assertResponse(t, e, "/broken", http.StatusInternalServerError, "")
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/unavailable", nil)
+	e.ServeHTTP(w, req)
+	if w.Header().Get("Retry-After") != "1" || w.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("unexpected headers: %v", w.Header())
+	}
+}
+
+func TestDynamicResponseGenerator_errors(t *testing.T) {
+	notFound := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	}))
+	defer notFound.Close()
+	slow := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer slow.Close()
+
+	client := &http.Client{Timeout: 10 * time.Millisecond}
+	for _, tc := range []struct {
+		url    string
+		status int
+	}{
+		{url: notFound.URL, status: http.StatusNotFound},
+		{url: slow.URL, status: http.StatusGatewayTimeout},
+	} {
+		drg := DynamicResponseGenerator{Page: Page{}, Backend: NewBackend(client, tc.url), Decoder: JSONDecoder}
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request, _ = http.NewRequest("GET", "/", nil)
+		_, err := drg.ResponseGenerator(c)
+		if status := ErrorStatusCode(err); status != tc.status {
+			t.Errorf("%s: unexpected status code: %d (%v)", tc.url, status, err)
+		}
+	}
+}
+
+func Test_withStatusMapping(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := http.StatusOK
+		fmt.Sscan(r.URL.Query().Get("status"), &status)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(`{"error": "something"}`))
+	}))
+	defer backend.Close()
+
+	page := Page{
+		BackendURLPattern: backend.URL + "/?status=:status",
+		StatusMapping: mergeStatusMappings(
+			map[int]StatusMapping{
+				http.StatusUnauthorized: {Redirect: "/login", ReturnParam: "next"},
+				http.StatusForbidden:    {Status: http.StatusNotFound},
+			},
+			map[int]StatusMapping{
+				http.StatusForbidden:       {Status: http.StatusForbidden},
+				http.StatusTooManyRequests: {Status: http.StatusServiceUnavailable, RetryAfter: 30},
+			},
+		),
+	}
+	drg := DynamicResponseGenerator{Page: page, Backend: NewBackend(http.DefaultClient, page.BackendURLPattern), Decoder: JSONDecoder}
+	rg := withStatusMapping(page, drg.ResponseGenerator)
+
+	for _, tc := range []struct {
+		status  int
+		code    int
+		headers http.Header
+	}{
+		{status: http.StatusOK, code: http.StatusOK},
+		{status: http.StatusBadRequest, code: http.StatusOK},
+		{status: http.StatusUnauthorized, code: http.StatusFound, headers: http.Header{"Location": {"/login?next=%2Fpage%3Fa%3Db"}}},
+		{status: http.StatusForbidden, code: http.StatusForbidden, headers: http.Header{}},
+		{status: http.StatusTooManyRequests, code: http.StatusServiceUnavailable, headers: http.Header{"Retry-After": {"30"}}},
+	} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request, _ = http.NewRequest("GET", "/page?a=b", nil)
+		c.Params = []gin.Param{{Key: "status", Value: fmt.Sprint(tc.status)}}
+		_, err := rg(c)
+		if tc.code == http.StatusOK {
+			if err != nil {
+				t.Errorf("%d: unexpected error: %s", tc.status, err.Error())
+			}
+			continue
+		}
+		code, headers := ErrorResponse(err)
+		if code != tc.code || fmt.Sprint(headers) != fmt.Sprint(tc.headers) {
+			t.Errorf("%d: unexpected response: %d %v", tc.status, code, headers)
+		}
+	}
+
+	if err := validStatusMapping(map[int]StatusMapping{http.StatusUnauthorized: {Redirect: "/login", Status: http.StatusNotFound}}); err == nil {
+		t.Error("expecting an error with a redirection without redirection status")
+	}
+}
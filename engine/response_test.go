@@ -2,6 +2,7 @@ package engine
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -112,7 +113,7 @@ func TestDynamicResponseGenerator_koBackend(t *testing.T) {
 	e := gin.New()
 	e.GET("/:first/:second", func(c *gin.Context) {
 		_, err := subject.ResponseGenerator(c)
-		if err != backendErr {
+		if !errors.Is(err, backendErr) {
 			t.Error("unexpected error:", err)
 			return
 		}
@@ -149,7 +150,7 @@ func TestDynamicResponseGenerator_koDecoder(t *testing.T) {
 	e := gin.New()
 	e.GET("/:first/:second", func(c *gin.Context) {
 		_, err := subject.ResponseGenerator(c)
-		if err != decoderErr {
+		if !errors.Is(err, decoderErr) {
 			t.Error("unexpected error:", err)
 			return
 		}
@@ -228,3 +229,41 @@ func checkCommonResponseProperties(t *testing.T, resp ResponseContext) {
 		return
 	}
 }
+
+func TestRequestParams_lang(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.URL.Path)
+	}))
+	defer backend.Close()
+
+	gin.SetMode(gin.TestMode)
+	tr := &Translator{Locales: []string{"en", "es"}, PathPrefix: true}
+	b := NewBackend(http.DefaultClient, backend.URL+"/:lang/products/:id")
+	e := gin.New()
+	e.Use(tr.HandlerFunc())
+	h := func(c *gin.Context) {
+		resp, err := b(requestParams(c), nil, c)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer resp.Body.Close()
+		io.Copy(c.Writer, resp.Body)
+	}
+	e.GET("/products/:id", h)
+	e.GET("/es/products/:id", h)
+	e.GET("/by-lang/:lang/:id", h)
+
+	for path, expected := range map[string]string{
+		"/products/1":    "/en/products/1",
+		"/es/products/1": "/es/products/1",
+		"/by-lang/fr/1":  "/fr/products/1",
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		e.ServeHTTP(w, req)
+		if body := w.Body.String(); body != expected {
+			t.Errorf("%s: unexpected backend path: %s", path, body)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package engine
+
+import (
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultRetryInitialBackoff = 100 * time.Millisecond
+	defaultRetryMaxBackoff     = 2 * time.Second
+)
+
+// NewRetryTransport returns a RoundTripper retrying the failed requests (errors and 502, 503 and
+// 504 responses) sent through the received one with exponential backoff and full jitter
+func NewRetryTransport(next http.RoundTripper, cfg BackendRetry) http.RoundTripper {
+	t := &retryTransport{
+		next:           next,
+		attempts:       cfg.MaxAttempts,
+		initialBackoff: parseDurationOr(cfg.InitialBackoff, defaultRetryInitialBackoff),
+		maxBackoff:     parseDurationOr(cfg.MaxBackoff, defaultRetryMaxBackoff),
+		budget:         parseDurationOr(cfg.Budget, 0),
+		idempotentOnly: cfg.IdempotentOnly,
+	}
+	if t.attempts <= 0 {
+		t.attempts = 3
+	}
+	return t
+}
+
+type retryTransport struct {
+	next           http.RoundTripper
+	attempts       int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	budget         time.Duration
+	idempotentOnly bool
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.idempotentOnly && req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return t.next.RoundTrip(req)
+	}
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		resp, err := t.next.RoundTrip(req)
+		if attempt >= t.attempts || !retryable(resp, err) {
+			return resp, err
+		}
+		wait := t.backoff(attempt)
+		if t.budget > 0 && time.Since(start)+wait > t.budget {
+			return resp, err
+		}
+		if req.Body != nil {
+			if req.GetBody == nil {
+				return resp, err
+			}
+			body, gerr := req.GetBody()
+			if gerr != nil {
+				return resp, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// backoff returns a random wait between zero and the exponential backoff of the attempt
+func (t *retryTransport) backoff(attempt int) time.Duration {
+	d := t.initialBackoff << uint(attempt-1)
+	if d <= 0 || d > t.maxBackoff {
+		d = t.maxBackoff
+	}
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func parseDurationOr(s string, d time.Duration) time.Duration {
+	if v, err := time.ParseDuration(s); err == nil {
+		return v
+	}
+	return d
+}
@@ -0,0 +1,55 @@
+package engine
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestNewRetryTransport(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Method == "POST" && string(body) != "payload" {
+			t.Errorf("unexpected body: %s", string(body))
+		}
+		if atomic.AddInt32(&calls, 1)%3 != 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	for _, tc := range []struct {
+		cfg    BackendRetry
+		method string
+		status int
+		calls  int32
+	}{
+		{BackendRetry{InitialBackoff: "1ms"}, "GET", http.StatusOK, 3},
+		{BackendRetry{MaxAttempts: 2, InitialBackoff: "1ms"}, "GET", http.StatusServiceUnavailable, 2},
+		{BackendRetry{InitialBackoff: "1ms"}, "POST", http.StatusOK, 3},
+		{BackendRetry{InitialBackoff: "1ms", IdempotentOnly: true}, "POST", http.StatusServiceUnavailable, 1},
+		{BackendRetry{InitialBackoff: "1s", MaxBackoff: "1s", Budget: "1ns"}, "GET", http.StatusServiceUnavailable, 1},
+	} {
+		atomic.StoreInt32(&calls, 0)
+		client := &http.Client{Transport: NewRetryTransport(http.DefaultTransport, tc.cfg)}
+		req, _ := http.NewRequest(tc.method, ts.URL, bytes.NewBufferString("payload"))
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Errorf("unexpected error: %s", err.Error())
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.status {
+			t.Errorf("%v: unexpected status code: %d", tc.cfg, resp.StatusCode)
+		}
+		if c := atomic.LoadInt32(&calls); c != tc.calls {
+			t.Errorf("%v: unexpected number of calls: %d", tc.cfg, c)
+		}
+	}
+}
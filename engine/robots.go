@@ -0,0 +1,75 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+)
+
+const (
+	noIndexDirectives = "noindex, nofollow"
+	// EnvironmentEnvVar is the env var overriding the environment of the config
+	EnvironmentEnvVar = "API2HTML_ENVIRONMENT"
+)
+
+// environment returns the name of the environment the engine runs in
+func environment(cfg Config) string {
+	if env := os.Getenv(EnvironmentEnvVar); env != "" {
+		return env
+	}
+	return cfg.Environment
+}
+
+// ForEnvironment returns the robots.txt config of the received environment. If the environment
+// is not declared, the default one is returned
+func (r RobotsTXT) ForEnvironment(env string) RobotsTXT {
+	envCfg, ok := r.Environments[env]
+	if !ok {
+		envCfg = r
+	}
+	envCfg.Environments = nil
+	return envCfg
+}
+
+// NewRobotsHandler creates a StaticHandler serving the robots.txt file generated with the
+// received config
+func NewRobotsHandler(cfg RobotsTXT) StaticHandler {
+	content := []byte(cfg.String())
+	return StaticHandler{Content: content, ETag: contentETag(content)}
+}
+
+// String returns the content of the robots.txt file
+func (r RobotsTXT) String() string {
+	buf := &bytes.Buffer{}
+	rules := r.Rules
+	if r.NoIndex {
+		rules = []RobotsRule{{Disallow: []string{"/"}}}
+	} else if len(rules) == 0 {
+		rules = []RobotsRule{{Disallow: []string{""}}}
+	}
+
+	for i, rule := range rules {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		userAgent := rule.UserAgent
+		if userAgent == "" {
+			userAgent = "*"
+		}
+		fmt.Fprintf(buf, "User-agent: %s\n", userAgent)
+		for _, path := range rule.Allow {
+			fmt.Fprintf(buf, "Allow: %s\n", path)
+		}
+		for _, path := range rule.Disallow {
+			fmt.Fprintf(buf, "Disallow: %s\n", path)
+		}
+	}
+
+	if len(r.Sitemaps) > 0 {
+		buf.WriteString("\n")
+	}
+	for _, sitemap := range r.Sitemaps {
+		fmt.Fprintf(buf, "Sitemap: %s\n", sitemap)
+	}
+	return buf.String()
+}
@@ -0,0 +1,132 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestRobotsTXT_String(t *testing.T) {
+	for i, tc := range []struct {
+		cfg      RobotsTXT
+		expected string
+	}{
+		{
+			cfg:      RobotsTXT{},
+			expected: "User-agent: *\nDisallow: \n",
+		},
+		{
+			cfg: RobotsTXT{
+				Rules: []RobotsRule{
+					{Allow: []string{"/products"}, Disallow: []string{"/admin", "/cart"}},
+					{UserAgent: "BadBot", Disallow: []string{"/"}},
+				},
+				Sitemaps: []string{"https://example.com/sitemap.xml"},
+			},
+			expected: "User-agent: *\nAllow: /products\nDisallow: /admin\nDisallow: /cart\n\n" +
+				"User-agent: BadBot\nDisallow: /\n\nSitemap: https://example.com/sitemap.xml\n",
+		},
+		{
+			cfg: RobotsTXT{
+				Rules:   []RobotsRule{{Allow: []string{"/products"}}},
+				NoIndex: true,
+			},
+			expected: "User-agent: *\nDisallow: /\n",
+		},
+	} {
+		if res := tc.cfg.String(); res != tc.expected {
+			t.Errorf("#%d: unexpected robots.txt:\n%s", i, res)
+		}
+	}
+}
+
+func TestRobotsTXT_ForEnvironment(t *testing.T) {
+	cfg := RobotsTXT{
+		Rules:    []RobotsRule{{Disallow: []string{"/cart"}}},
+		Sitemaps: []string{"https://example.com/sitemap.xml"},
+		Environments: map[string]RobotsTXT{
+			"staging": {NoIndex: true},
+		},
+	}
+
+	if res := cfg.ForEnvironment("staging").String(); res != "User-agent: *\nDisallow: /\n" {
+		t.Errorf("unexpected robots.txt of the staging environment:\n%s", res)
+	}
+	expected := "User-agent: *\nDisallow: /cart\n\nSitemap: https://example.com/sitemap.xml\n"
+	for _, env := range []string{"production", ""} {
+		res := cfg.ForEnvironment(env)
+		if res.String() != expected {
+			t.Errorf("unexpected robots.txt of the %q environment:\n%s", env, res.String())
+		}
+		if res.Environments != nil {
+			t.Errorf("unexpected environments in the %q environment", env)
+		}
+	}
+}
+
+func TestFactory_New_robots(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		robotsTXT *RobotsTXT
+		env       string
+		robots    string
+		body      string
+	}{
+		{
+			name:      "page directives",
+			robotsTXT: &RobotsTXT{Rules: []RobotsRule{{Disallow: []string{"/private"}}}},
+			robots:    "noarchive",
+			body:      "User-agent: *\nDisallow: /private\n",
+		},
+		{
+			name:      "noindex",
+			robotsTXT: &RobotsTXT{NoIndex: true},
+			robots:    noIndexDirectives,
+			body:      "User-agent: *\nDisallow: /\n",
+		},
+		{
+			name: "staging environment",
+			robotsTXT: &RobotsTXT{
+				Rules:        []RobotsRule{{Disallow: []string{"/private"}}},
+				Environments: map[string]RobotsTXT{"staging": {NoIndex: true}},
+			},
+			env:    "staging",
+			robots: noIndexDirectives,
+			body:   "User-agent: *\nDisallow: /\n",
+		},
+	} {
+		robotsTXT := tc.robotsTXT
+		env := tc.env
+		ef := DefaultFactory
+		ef.TemplateFS = fstest.MapFS{
+			"a.mustache": {Data: []byte(`<meta name="robots" content="{{Robots}}">`)},
+		}
+		ef.Parser = func(_ string) (Config, error) {
+			return Config{
+				Pages:       []Page{{URLPattern: "/a", Template: "a", Robots: "noarchive"}},
+				Templates:   map[string]string{"a": "a.mustache"},
+				RobotsTXT:   robotsTXT,
+				Environment: env,
+			}, nil
+		}
+
+		e, err := ef.New("something", false)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tc.name, err.Error())
+			continue
+		}
+		time.Sleep(200 * time.Millisecond)
+
+		assertResponse(t, e, "/robots.txt", http.StatusOK, tc.body)
+		assertResponse(t, e, "/a", http.StatusOK, `<meta name="robots" content="`+tc.robots+`">`)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/a", nil)
+		e.ServeHTTP(w, req)
+		if header := w.Header().Get("X-Robots-Tag"); header != tc.robots {
+			t.Errorf("%s: unexpected X-Robots-Tag header: %s", tc.name, header)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package engine
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// routeParamRegexp matches the segments of the URL patterns declaring a param with braces
+var routeParamRegexp = regexp.MustCompile(`^\{([A-Za-z_]\w*)(?::(.+))?\}$`)
+
+// RoutePattern is the URL pattern of a page converted to the syntax of the router
+type RoutePattern struct {
+	// Path is the pattern with the gin syntax (/blog/:slug or /files/*path)
+	Path string
+	// Constraints are the regular expressions the values of the params must match
+	Constraints map[string]*regexp.Regexp
+	// CatchAll is the name of the catch-all param declared with braces, which value does not
+	// keep the leading slash
+	CatchAll string
+}
+
+// ParseRoutePattern converts the URL pattern of a page into a RoutePattern. Besides the gin
+// syntax, the segments of the pattern can declare their params with braces: {slug} is the same
+// as :slug, {slug:[a-z0-9-]+} only matches the values of the regular expression and {path:*}
+// matches the rest of the path, like *path. The named groups of the regular expressions
+// ({id:(?P<year>\d{4})-\d+}) are also params of the requests
+func ParseRoutePattern(pattern string) (RoutePattern, error) {
+	route := RoutePattern{Constraints: map[string]*regexp.Regexp{}}
+	segments := strings.Split(pattern, "/")
+	for i, segment := range segments {
+		if !strings.ContainsAny(segment, "{}") {
+			continue
+		}
+		m := routeParamRegexp.FindStringSubmatch(segment)
+		if m == nil {
+			return RoutePattern{}, fmt.Errorf("invalid param %q, the params with braces must take the whole segment", segment)
+		}
+		name, expr := m[1], m[2]
+		if expr == "*" {
+			if i != len(segments)-1 {
+				return RoutePattern{}, fmt.Errorf("the catch-all param %s must be the last segment", name)
+			}
+			segments[i] = "*" + name
+			route.CatchAll = name
+			continue
+		}
+		segments[i] = ":" + name
+		if expr == "" {
+			continue
+		}
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return RoutePattern{}, fmt.Errorf("param %s: %s", name, err.Error())
+		}
+		route.Constraints[name] = re
+	}
+	route.Path = strings.Join(segments, "/")
+	return route, nil
+}
+
+// handlerFunc returns a middleware answering with the not found handler when the params of the
+// request do not match their constraints, adding the named groups of the constraints as params
+// and trimming the leading slash of the catch-all param. It returns nil if the route has
+// neither constraints nor a catch-all param
+func (r RoutePattern) handlerFunc(notFound gin.HandlerFunc) gin.HandlerFunc {
+	if len(r.Constraints) == 0 && r.CatchAll == "" {
+		return nil
+	}
+	if notFound == nil {
+		notFound = func(c *gin.Context) { c.Status(http.StatusNotFound) }
+	}
+	return func(c *gin.Context) {
+		for i, p := range c.Params {
+			if p.Key == r.CatchAll {
+				c.Params[i].Value = strings.TrimPrefix(p.Value, "/")
+			}
+		}
+		for name, re := range r.Constraints {
+			m := re.FindStringSubmatch(c.Param(name))
+			if m == nil {
+				// the not found handlers keep the status of the special handlers of gin
+				c.Status(http.StatusNotFound)
+				notFound(c)
+				c.Abort()
+				return
+			}
+			for i, group := range re.SubexpNames() {
+				if group != "" {
+					c.Params = append(c.Params, gin.Param{Key: group, Value: m[i]})
+				}
+			}
+		}
+	}
+}
+
+// routePages returns a copy of the pages of the config with their URL patterns converted to the
+// syntax of the router and with the trailing slash of their canonical URLs
+func routePages(cfg Config) ([]Page, error) {
+	routed := make([]Page, len(cfg.Pages))
+	for i, page := range cfg.Pages {
+		route, err := ParseRoutePattern(page.URLPattern)
+		if err != nil {
+			return nil, fmt.Errorf("page %s: %s", page.Name, err.Error())
+		}
+		if page.Canonical == nil {
+			page.Canonical = cfg.Canonical
+		}
+		if err := page.Canonical.validate(); err != nil {
+			return nil, fmt.Errorf("page %s: %s", page.Name, err.Error())
+		}
+		page.URLPattern = page.Canonical.routePattern(route.Path)
+		route.Path = page.URLPattern
+		page.route = route
+		routed[i] = page
+	}
+	return routed, nil
+}
+
+// routerConflict returns true if the router panics registering both URL patterns, already
+// converted to its syntax: after a common prefix, they have params with different names, like
+// /blog/:id and /blog/:slug, or one of them has a catch-all, like /files/*path and /files/new.
+// The equal patterns do not conflict, since the pages of different hosts share their route
+func routerConflict(a, b string) bool {
+	if a == b {
+		return false
+	}
+	sa, sb := strings.Split(a, "/"), strings.Split(b, "/")
+	for i := 0; i < len(sa) && i < len(sb); i++ {
+		if sa[i] == sb[i] {
+			continue
+		}
+		if strings.HasPrefix(sa[i], "*") || strings.HasPrefix(sb[i], "*") {
+			return true
+		}
+		return strings.HasPrefix(sa[i], ":") && strings.HasPrefix(sb[i], ":")
+	}
+	return false
+}
+
+// checkRouterConflicts returns an error for the first pair of URL patterns the router can not
+// register together, so the config is rejected instead of making the router panic
+func checkRouterConflicts(pages []Page) error {
+	for i, a := range pages {
+		for _, b := range pages[i+1:] {
+			if routerConflict(a.URLPattern, b.URLPattern) {
+				return fmt.Errorf("pages %s and %s: the URL patterns %s and %s can not be registered together", a.Name, b.Name, a.URLPattern, b.URLPattern)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseRoutePattern(t *testing.T) {
+	for pattern, expected := range map[string]string{
+		"/":                            "/",
+		"/products/:id":                "/products/:id",
+		"/blog/{slug}":                 "/blog/:slug",
+		"/blog/{slug:[a-z0-9-]+}":      "/blog/:slug",
+		"/posts/{id:[0-9]{4}}/reviews": "/posts/:id/reviews",
+		"/files/{path:*}":              "/files/*path",
+		"/files/*path":                 "/files/*path",
+	} {
+		route, err := ParseRoutePattern(pattern)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", pattern, err.Error())
+			continue
+		}
+		if route.Path != expected {
+			t.Errorf("%s: unexpected path: %s", pattern, route.Path)
+		}
+	}
+
+	for _, pattern := range []string{
+		"/blog/post-{slug}",
+		"/blog/{slug",
+		"/blog/{1slug}",
+		"/blog/{slug:[a-z}",
+		"/files/{path:*}/raw",
+	} {
+		if _, err := ParseRoutePattern(pattern); err == nil {
+			t.Errorf("%s: expecting an error", pattern)
+		}
+	}
+}
+
+func TestRoutePattern_handlerFunc(t *testing.T) {
+	if h := (RoutePattern{Path: "/products/:id"}).handlerFunc(nil); h != nil {
+		t.Error("unexpected middleware")
+	}
+
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	notFound := func(c *gin.Context) { c.String(http.StatusNotFound, "not found") }
+	params := func(c *gin.Context) {
+		c.String(http.StatusOK, c.Param("slug")+"|"+c.Param("year")+"|"+c.Param("path"))
+	}
+	for _, pattern := range []string{`/blog/{slug:(?P<year>\d{4})-[a-z-]+}`, "/files/{path:*}"} {
+		route, err := ParseRoutePattern(pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e.GET(route.Path, route.handlerFunc(notFound), params)
+	}
+
+	for path, expected := range map[string]string{
+		"/blog/2024-hello-world": "2024-hello-world|2024|",
+		"/blog/hello-world":      "not found",
+		"/blog/2024-Hello":       "not found",
+		"/files/a/b.txt":         "||a/b.txt",
+		"/files/":                "||",
+	} {
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Body.String() != expected {
+			t.Errorf("%s: unexpected response: %d %s", path, w.Code, w.Body.String())
+		}
+	}
+}
+
+func Test_routerConflict(t *testing.T) {
+	for _, tc := range []struct {
+		a, b     string
+		conflict bool
+	}{
+		{"/blog/:id", "/blog/:id", false},
+		{"/blog/:id", "/blog/:slug", true},
+		{"/blog/:id/comments", "/blog/:slug", true},
+		{"/blog/:id", "/blog/new", false},
+		{"/blog/:id", "/users/:name", false},
+		{"/files/:name", "/files/*path", true},
+		{"/files/", "/files/*path", true},
+		{"/files", "/files/*path", false},
+	} {
+		if conflict := routerConflict(tc.a, tc.b); conflict != tc.conflict {
+			t.Errorf("%s %s: unexpected result %v", tc.a, tc.b, conflict)
+		}
+	}
+}
+
+func TestFactory_New_routerConflicts(t *testing.T) {
+	for _, patterns := range [][]string{
+		{"/blog/{id}", "/blog/{slug}"},
+		{"/files/{name:[a-z]+}", "/files/{path:*}"},
+		{"/blog/:id", "/blog/*path"},
+	} {
+		ef := DefaultFactory
+		ef.Parser = func(_ string) (Config, error) {
+			return Config{Pages: []Page{
+				{Name: "a", URLPattern: patterns[0]},
+				{Name: "b", URLPattern: patterns[1], Host: "b.example.com"},
+			}}, nil
+		}
+		if _, err := ef.New("something", false); err == nil {
+			t.Errorf("%v: expecting an error", patterns)
+		}
+	}
+}
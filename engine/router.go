@@ -0,0 +1,96 @@
+package engine
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-chi/chi/v5"
+)
+
+// Router is the interface of the http routers the api2html handlers can be mounted into, so the
+// embedders not using gin can serve the pages with their own router
+type Router interface {
+	// Handle registers the handler for the method and the path. The path uses the gin syntax
+	// (/products/:category or /files/*path) whatever the implementation is
+	Handle(method, path string, h http.Handler)
+}
+
+type routeParamsKey struct{}
+
+// RouteParams returns the params of the route matched by a Router
+func RouteParams(r *http.Request) map[string]string {
+	if params, ok := r.Context().Value(routeParamsKey{}).(map[string]string); ok {
+		return params
+	}
+	return map[string]string{}
+}
+
+func withRouteParams(r *http.Request, params map[string]string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), routeParamsKey{}, params))
+}
+
+// NewGinRouter returns a Router mounting the handlers into the received gin routes
+func NewGinRouter(routes gin.IRoutes) Router {
+	return ginRouter{routes}
+}
+
+type ginRouter struct {
+	routes gin.IRoutes
+}
+
+// Handle implements the Router interface
+func (g ginRouter) Handle(method, path string, h http.Handler) {
+	g.routes.Handle(method, path, func(c *gin.Context) {
+		params := map[string]string{}
+		for _, p := range c.Params {
+			params[p.Key] = p.Value
+		}
+		h.ServeHTTP(c.Writer, withRouteParams(c.Request, params))
+	})
+}
+
+// NewChiRouter returns a Router mounting the handlers into the received chi router
+func NewChiRouter(router chi.Router) Router {
+	return chiRouter{router}
+}
+
+type chiRouter struct {
+	router chi.Router
+}
+
+// Handle implements the Router interface
+func (r chiRouter) Handle(method, path string, h http.Handler) {
+	pattern, wildcard := chiPattern(path)
+	r.router.Method(method, pattern, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		params := map[string]string{}
+		if rctx := chi.RouteContext(req.Context()); rctx != nil {
+			for i, k := range rctx.URLParams.Keys {
+				v := rctx.URLParams.Values[i]
+				if k == "*" {
+					// the gin wildcards keep the leading slash
+					k, v = wildcard, "/"+v
+				}
+				params[k] = v
+			}
+		}
+		h.ServeHTTP(w, withRouteParams(req, params))
+	}))
+}
+
+// chiPattern translates the gin path to the chi syntax, returning the name of the wildcard param
+func chiPattern(path string) (string, string) {
+	segments := strings.Split(path, "/")
+	wildcard := ""
+	for i, segment := range segments {
+		switch {
+		case strings.HasPrefix(segment, ":"):
+			segments[i] = "{" + segment[1:] + "}"
+		case strings.HasPrefix(segment, "*"):
+			wildcard = segment[1:]
+			segments[i] = "*"
+		}
+	}
+	return strings.Join(segments, "/"), wildcard
+}
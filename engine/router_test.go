@@ -0,0 +1,50 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-chi/chi/v5"
+)
+
+func TestRouter(t *testing.T) {
+	h := &Handler{
+		Page:         Page{Name: "product"},
+		CacheControl: "public, max-age=60",
+		Renderer: RendererFunc(func(w io.Writer, v interface{}) error {
+			_, err := fmt.Fprintf(w, "%s %s", v.(ResponseContext).Params["id"], v.(ResponseContext).Params["path"])
+			return err
+		}),
+		ResponseGenerator: (&StaticResponseGenerator{}).ResponseGenerator,
+	}
+
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	mux := chi.NewRouter()
+	for _, router := range []Router{NewGinRouter(e), NewChiRouter(mux)} {
+		router.Handle("GET", "/products/:id", h)
+		router.Handle("GET", "/files/*path", h)
+		router.Handle("GET", "/missing", &Handler{ResponseGenerator: NoopResponse})
+	}
+
+	for _, router := range []http.Handler{e, mux} {
+		assertResponse(t, router, "/products/42", http.StatusOK, "42 ")
+		assertResponse(t, router, "/files/a/b.txt", http.StatusOK, " /a/b.txt")
+		assertResponse(t, router, "/missing", http.StatusInternalServerError, "")
+	}
+}
+
+func TestChiPattern(t *testing.T) {
+	for path, expected := range map[string][2]string{
+		"/":                   {"/", ""},
+		"/products/:category": {"/products/{category}", ""},
+		"/a/:b/c/*rest":       {"/a/{b}/c/*", "rest"},
+	} {
+		if pattern, wildcard := chiPattern(path); pattern != expected[0] || wildcard != expected[1] {
+			t.Errorf("%s: unexpected pattern: %s %s", path, pattern, wildcard)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package engine
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/gin-gonic/gin"
+	"github.com/microcosm-cc/bluemonday"
+)
+
+const (
+	// UGCPolicy keeps the elements and attributes of the user generated rich text (formatting,
+	// links, images, tables...)
+	UGCPolicy = "ugc"
+	// StrictPolicy removes all the elements, keeping only their text
+	StrictPolicy = "strict"
+)
+
+// NewSanitizer returns the policy sanitizing the fields of the received config
+func NewSanitizer(cfg FieldSanitizer) (*bluemonday.Policy, error) {
+	var p *bluemonday.Policy
+	switch cfg.Policy {
+	case "", UGCPolicy:
+		p = bluemonday.UGCPolicy()
+	case StrictPolicy:
+		p = bluemonday.StrictPolicy()
+	default:
+		return nil, fmt.Errorf("unknown sanitization policy: %s", cfg.Policy)
+	}
+	for element, attrs := range cfg.AllowElements {
+		p.AllowElements(element)
+		if len(attrs) > 0 {
+			p.AllowAttrs(attrs...).OnElements(element)
+		}
+	}
+	return p, nil
+}
+
+// withSanitizer wraps the ResponseGenerator sanitizing the HTML fields of the page
+func withSanitizer(page Page, rg ResponseGenerator) ResponseGenerator {
+	if page.Sanitize == nil || len(page.Sanitize.Fields) == 0 {
+		return rg
+	}
+	p, err := NewSanitizer(*page.Sanitize)
+	if err != nil {
+		// the fields are never rendered unsanitized
+		log.Println(page.Name, err.Error())
+		p = bluemonday.StrictPolicy()
+	}
+	sanitize := func(s string) (string, error) { return p.Sanitize(s), nil }
+	return func(c *gin.Context) (ResponseContext, error) {
+		result, err := rg(c)
+		if err != nil {
+			return result, err
+		}
+		for _, path := range page.Sanitize.Fields {
+			if err = transformFields(result, path, sanitize); err != nil {
+				return result, err
+			}
+		}
+		return result, nil
+	}
+}
@@ -0,0 +1,62 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNewSanitizer(t *testing.T) {
+	src := `<p onclick="x()">Hi <a href="javascript:alert(1)">there</a><script>alert(1)</script><iframe src="https://video.example.com/1"></iframe></p>`
+	for i, tc := range []struct {
+		cfg      FieldSanitizer
+		expected string
+	}{
+		{FieldSanitizer{}, `<p>Hi there</p>`},
+		{FieldSanitizer{Policy: StrictPolicy}, `Hi there`},
+		{
+			FieldSanitizer{AllowElements: map[string][]string{"iframe": {"src"}}},
+			`<p>Hi there<iframe src="https://video.example.com/1"></iframe></p>`,
+		},
+	} {
+		p, err := NewSanitizer(tc.cfg)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if res := p.Sanitize(src); res != tc.expected {
+			t.Errorf("#%d: unexpected result: %s", i, res)
+		}
+	}
+
+	if _, err := NewSanitizer(FieldSanitizer{Policy: "unknown"}); err == nil {
+		t.Error("error expected")
+	}
+}
+
+func TestWithSanitizer(t *testing.T) {
+	page := Page{Sanitize: &FieldSanitizer{Fields: []string{"body", "comments.text"}}}
+	rg := withSanitizer(page, func(_ *gin.Context) (ResponseContext, error) {
+		return ResponseContext{
+			Data: map[string]interface{}{
+				"title":    "<b>title</b><script></script>",
+				"body":     "<b>body</b><script></script>",
+				"comments": []interface{}{map[string]interface{}{"text": `<i onmouseover="x()">a</i>`}},
+			},
+		}, nil
+	})
+	result, err := rg(nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if v := result.Data["title"]; v != "<b>title</b><script></script>" {
+		t.Errorf("unexpected title: %v", v)
+	}
+	if v := result.Data["body"]; v != "<b>body</b>" {
+		t.Errorf("unexpected body: %v", v)
+	}
+	if v := result.Data["comments"].([]interface{})[0].(map[string]interface{})["text"]; v != "<i>a</i>" {
+		t.Errorf("unexpected comment: %v", v)
+	}
+}
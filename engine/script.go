@@ -0,0 +1,184 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/gin-gonic/gin"
+)
+
+// defaultScriptTimeout is the maximum duration of the script calls of the pages without timeout
+const defaultScriptTimeout = 100 * time.Millisecond
+
+// ScriptProcessor runs the process function of a page script with the backend data of the
+// requests. The script is compiled once and evaluated in a pool of runtimes, so its global state
+// is not shared between the requests
+type ScriptProcessor struct {
+	program  *goja.Program
+	timeout  time.Duration
+	runtimes sync.Pool
+}
+
+// scriptRuntime is a runtime with the script evaluated
+type scriptRuntime struct {
+	vm       *goja.Runtime
+	process  goja.Callable
+	location string
+	status   int
+}
+
+// NewScriptProcessor compiles the script and checks it defines the process function
+func NewScriptProcessor(cfg PageScript) (*ScriptProcessor, error) {
+	if cfg.Path == "" {
+		return nil, errors.New("the script requires a path")
+	}
+	timeout := defaultScriptTimeout
+	if cfg.Timeout != "" {
+		d, err := time.ParseDuration(cfg.Timeout)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid timeout: %q", cfg.Timeout)
+		}
+		timeout = d
+	}
+	src, err := ioutil.ReadFile(cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	program, err := goja.Compile(cfg.Path, string(src), true)
+	if err != nil {
+		return nil, err
+	}
+	s := &ScriptProcessor{program: program, timeout: timeout}
+	rt, err := s.newRuntime()
+	if err != nil {
+		return nil, err
+	}
+	s.runtimes.Put(rt)
+	return s, nil
+}
+
+func (s *ScriptProcessor) newRuntime() (*scriptRuntime, error) {
+	rt := &scriptRuntime{vm: goja.New()}
+	rt.vm.Set("redirect", func(call goja.FunctionCall) goja.Value {
+		status := http.StatusFound
+		if arg := call.Argument(1); !goja.IsUndefined(arg) {
+			status = int(arg.ToInteger())
+		}
+		if status < 300 || status > 399 {
+			panic(rt.vm.NewTypeError("invalid redirection status: %d", status))
+		}
+		rt.location = call.Argument(0).String()
+		rt.status = status
+		return goja.Undefined()
+	})
+	if _, err := rt.vm.RunProgram(s.program); err != nil {
+		return nil, err
+	}
+	process, ok := goja.AssertFunction(rt.vm.Get("process"))
+	if !ok {
+		return nil, errors.New("the script must define a process function")
+	}
+	rt.process = process
+	return rt, nil
+}
+
+// Process calls the process function of the script with the data and the request of the
+// response. The function replaces the data with the object or the array it returns, or keeps
+// the data it received, modified in place, if it returns nothing. If it calls redirect, the
+// returned error is a ResponseError with the location of the redirection
+func (s *ScriptProcessor) Process(c *gin.Context, result ResponseContext) (ResponseContext, error) {
+	rt, ok := s.runtimes.Get().(*scriptRuntime)
+	if !ok {
+		var err error
+		if rt, err = s.newRuntime(); err != nil {
+			return result, err
+		}
+	}
+	defer s.runtimes.Put(rt)
+	rt.location, rt.status = "", 0
+
+	var data interface{} = result.Data
+	if result.Array != nil {
+		array := make([]interface{}, len(result.Array))
+		for i, item := range result.Array {
+			array[i] = item
+		}
+		data = array
+	}
+	arg := rt.vm.ToValue(floatNumbers(data))
+
+	timer := time.AfterFunc(s.timeout, func() {
+		rt.vm.Interrupt(fmt.Sprintf("the script exceeded the timeout of %s", s.timeout))
+	})
+	v, err := rt.process(goja.Undefined(), arg, rt.vm.ToValue(scriptRequest(c)))
+	timer.Stop()
+	rt.vm.ClearInterrupt()
+	if err != nil {
+		return result, err
+	}
+	if rt.location != "" {
+		return result, &ResponseError{StatusCode: rt.status, Location: rt.location}
+	}
+
+	if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+		v = arg
+	}
+	result.Data, result.Array, err = extractedData(v.Export())
+	return result, err
+}
+
+// scriptRequest returns the description of the request received by the scripts: its path and
+// its route params, query params and headers (with lowercase names). Just the first value of the
+// repeated params and headers is kept
+func scriptRequest(c *gin.Context) map[string]interface{} {
+	req := map[string]interface{}{
+		"path":    "",
+		"params":  map[string]interface{}{},
+		"query":   map[string]interface{}{},
+		"headers": map[string]interface{}{},
+	}
+	if c == nil || c.Request == nil {
+		return req
+	}
+	req["path"] = c.Request.URL.Path
+	params := req["params"].(map[string]interface{})
+	for k, v := range requestParams(c) {
+		params[k] = v
+	}
+	query := req["query"].(map[string]interface{})
+	for k, v := range c.Request.URL.Query() {
+		query[k] = v[0]
+	}
+	headers := req["headers"].(map[string]interface{})
+	for k, v := range c.Request.Header {
+		headers[strings.ToLower(k)] = v[0]
+	}
+	return req
+}
+
+// withScript wraps the ResponseGenerator post-processing the backend data with the script of
+// the page
+func withScript(page Page, rg ResponseGenerator) ResponseGenerator {
+	if page.Script == nil {
+		return rg
+	}
+	s, err := NewScriptProcessor(*page.Script)
+	if err != nil {
+		return func(_ *gin.Context) (ResponseContext, error) {
+			return ResponseContext{}, err
+		}
+	}
+	return func(c *gin.Context) (ResponseContext, error) {
+		result, err := rg(c)
+		if err != nil {
+			return result, err
+		}
+		return s.Process(c, result)
+	}
+}
@@ -0,0 +1,126 @@
+package engine
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestScript(t *testing.T, src string) (PageScript, func()) {
+	dir, err := ioutil.TempDir("", "scripts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "page.js")
+	if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return PageScript{Path: path}, func() { os.RemoveAll(dir) }
+}
+
+func Test_withScript(t *testing.T) {
+	script, cleanup := newTestScript(t, `
+function process(data, request) {
+	if (data.status === "moved") {
+		redirect(data.canonical_url, 301);
+		return;
+	}
+	data.items = data.items.filter(function(item) { return item.stock > 0; });
+	data.total = data.items.reduce(function(sum, item) { return sum + item.price; }, 0);
+	data.lang = request.query.lang || "en";
+	data.id = request.params.id;
+}`)
+	defer cleanup()
+
+	var backend map[string]interface{}
+	rg := func(_ *gin.Context) (ResponseContext, error) {
+		return ResponseContext{Data: backend, Extra: map[string]interface{}{"a": "b"}}, nil
+	}
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request, _ = http.NewRequest("GET", "/products/42?lang=es", nil)
+	c.Params = []gin.Param{{Key: "id", Value: "42"}}
+
+	backend = map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"name": "laptop", "price": json.Number("1000"), "stock": json.Number("3")},
+			map[string]interface{}{"name": "phone", "price": json.Number("500"), "stock": json.Number("0")},
+			map[string]interface{}{"name": "tablet", "price": json.Number("250.5"), "stock": json.Number("1")},
+		},
+	}
+	result, err := withScript(Page{Script: &script}, rg)(c)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if items := result.Data["items"].([]interface{}); len(items) != 2 {
+		t.Errorf("unexpected items: %v", items)
+	}
+	if result.Data["total"] != 1250.5 || result.Data["lang"] != "es" || result.Data["id"] != "42" || result.Extra["a"] != "b" {
+		t.Errorf("unexpected result: %v", result)
+	}
+
+	backend = map[string]interface{}{"status": "moved", "canonical_url": "/products/43"}
+	_, err = withScript(Page{Script: &script}, rg)(c)
+	status, headers := ErrorResponse(err)
+	if status != http.StatusMovedPermanently || headers.Get("Location") != "/products/43" {
+		t.Errorf("unexpected redirection: %d %v", status, headers)
+	}
+}
+
+func Test_withScript_array(t *testing.T) {
+	script, cleanup := newTestScript(t, `
+function process(data) {
+	return data.filter(function(item) { return item.name !== "phone"; });
+}`)
+	defer cleanup()
+
+	rg := func(_ *gin.Context) (ResponseContext, error) {
+		return ResponseContext{Array: []map[string]interface{}{{"name": "laptop"}, {"name": "phone"}}}, nil
+	}
+	result, err := withScript(Page{Script: &script}, rg)(nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if result.Data != nil || len(result.Array) != 1 || result.Array[0]["name"] != "laptop" {
+		t.Errorf("unexpected result: %v", result)
+	}
+}
+
+func Test_withScript_ko(t *testing.T) {
+	rg := func(_ *gin.Context) (ResponseContext, error) {
+		return ResponseContext{Data: map[string]interface{}{}}, nil
+	}
+	for _, src := range []string{
+		`function process(data) { throw new Error("boom"); }`,
+		`function process(data) { while (true) {} }`,
+		`function process(data) { return "text"; }`,
+		`function process(data) { redirect("/somewhere", 200); }`,
+	} {
+		script, cleanup := newTestScript(t, src)
+		script.Timeout = "10ms"
+		if _, err := withScript(Page{Script: &script}, rg)(nil); err == nil {
+			t.Errorf("expecting an error with the script %s", src)
+		}
+		cleanup()
+	}
+	for _, src := range []string{
+		`var process = 42;`,
+		`function process(data) {`,
+	} {
+		script, cleanup := newTestScript(t, src)
+		if _, err := NewScriptProcessor(script); err == nil {
+			t.Errorf("expecting an error with the script %s", src)
+		}
+		cleanup()
+	}
+	if _, err := NewScriptProcessor(PageScript{Path: "unknown.js"}); err == nil {
+		t.Error("expecting an error with an unknown script")
+	}
+}
@@ -0,0 +1,116 @@
+package engine
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultShutdownTimeout is the max time the in-flight requests have to finish on shutdown
+const DefaultShutdownTimeout = 30 * time.Second
+
+// ServeGracefully serves the handler at the received address until the process gets a SIGINT or
+// a SIGTERM. Then, it stops accepting connections and waits for the in-flight requests to finish,
+// up to the timeout. Every SIGHUP calls the reload function, if any
+func ServeGracefully(addr string, h http.Handler, timeout time.Duration, reload func()) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return serveWithSignals([]listener{{&http.Server{Handler: h}, ln}}, timeout, reload)
+}
+
+// ServeGracefullyTLS serves the handler over HTTPS at the received address, like ServeGracefully.
+// If the TLS config has a redirect address, the plain HTTP requests received there are
+// redirected to HTTPS
+func ServeGracefullyTLS(addr string, h http.Handler, cfg TLS, timeout time.Duration, reload func()) error {
+	tlsConfig, redirect, err := NewTLSConfig(cfg, addr)
+	if err != nil {
+		return err
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	listeners := []listener{{&http.Server{Handler: h, TLSConfig: tlsConfig}, ln}}
+	if cfg.RedirectAddress != "" {
+		redirectLn, err := net.Listen("tcp", cfg.RedirectAddress)
+		if err != nil {
+			ln.Close()
+			return err
+		}
+		listeners = append(listeners, listener{&http.Server{Handler: redirect}, redirectLn})
+	}
+	return serveWithSignals(listeners, timeout, reload)
+}
+
+func serveWithSignals(listeners []listener, timeout time.Duration, reload func()) error {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(sigs)
+	return serveGracefully(listeners, sigs, timeout, reload)
+}
+
+// listener is a server with the listener it accepts the connections from. The servers with a
+// TLS config serve HTTPS
+type listener struct {
+	srv *http.Server
+	ln  net.Listener
+}
+
+func (l listener) serve() error {
+	if l.srv.TLSConfig != nil {
+		return l.srv.ServeTLS(l.ln, "", "")
+	}
+	return l.srv.Serve(l.ln)
+}
+
+func serveGracefully(listeners []listener, sigs <-chan os.Signal, timeout time.Duration, reload func()) error {
+	errs := make(chan error, len(listeners))
+	for _, l := range listeners {
+		go func(l listener) { errs <- l.serve() }(l)
+	}
+
+	for {
+		select {
+		case err := <-errs:
+			shutdown(listeners, 0)
+			return err
+		case sig := <-sigs:
+			if sig == syscall.SIGHUP {
+				if reload != nil {
+					reload()
+				}
+				continue
+			}
+			log.Println("shutting down on", sig.String())
+			return shutdown(listeners, timeout)
+		}
+	}
+}
+
+// shutdown stops all the servers, waiting for their in-flight requests up to the timeout
+func shutdown(listeners []listener, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var wg sync.WaitGroup
+	errs := make(chan error, len(listeners))
+	for _, l := range listeners {
+		wg.Add(1)
+		go func(srv *http.Server) {
+			defer wg.Done()
+			if err := srv.Shutdown(ctx); err != nil {
+				errs <- err
+			}
+		}(l.srv)
+	}
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
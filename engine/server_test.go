@@ -0,0 +1,68 @@
+package engine
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func Test_serveGracefully(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	started := make(chan struct{})
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte("done"))
+	})}
+
+	sigs := make(chan os.Signal, 1)
+	reloads := make(chan struct{}, 1)
+	served := make(chan error, 1)
+	go func() {
+		served <- serveGracefully([]listener{{srv, ln}}, sigs, time.Second, func() { reloads <- struct{}{} })
+	}()
+
+	sigs <- syscall.SIGHUP
+	select {
+	case <-reloads:
+	case <-time.After(time.Second):
+		t.Error("the engine has not been reloaded")
+	}
+
+	responses := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			responses <- err.Error()
+			return
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		responses <- string(body)
+	}()
+	<-started
+	sigs <- syscall.SIGTERM
+
+	if body := <-responses; body != "done" {
+		t.Errorf("the in-flight request has been dropped: %s", body)
+	}
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("unexpected error: %s", err.Error())
+		}
+	case <-time.After(time.Second):
+		t.Error("the server has not been shut down")
+	}
+	if _, err := http.Get("http://" + ln.Addr().String()); err == nil {
+		t.Error("the server is still accepting connections")
+	}
+}
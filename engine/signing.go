@@ -0,0 +1,240 @@
+package engine
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// HMACSigning signs the backend requests with a shared secret
+	HMACSigning = "hmac"
+	// AWSSigV4Signing signs the backend requests with the AWS Signature Version 4
+	AWSSigV4Signing = "aws_sigv4"
+
+	awsTimeFormat = "20060102T150405Z"
+)
+
+// RequestSigner signs the outbound requests. The payload is the body of the request, already
+// read
+type RequestSigner interface {
+	Sign(req *http.Request, payload []byte) error
+}
+
+// NewRequestSigner returns the RequestSigner declared by the config
+func NewRequestSigner(cfg BackendSigning) (RequestSigner, error) {
+	switch cfg.Type {
+	case HMACSigning:
+		if cfg.Secret == "" {
+			return nil, fmt.Errorf("the hmac signing requires a secret")
+		}
+		return &HMACSigner{KeyID: cfg.KeyID, Secret: []byte(cfg.Secret), Now: time.Now}, nil
+	case AWSSigV4Signing:
+		s := &AWSSigV4Signer{
+			Region:          cfg.Region,
+			Service:         cfg.Service,
+			AccessKeyID:     cfg.AccessKeyID,
+			SecretAccessKey: cfg.SecretAccessKey,
+			SessionToken:    cfg.SessionToken,
+			Now:             time.Now,
+		}
+		if s.AccessKeyID == "" {
+			s.AccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+			s.SecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+			s.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+		}
+		if s.Service == "" {
+			s.Service = "execute-api"
+		}
+		if s.Region == "" || s.AccessKeyID == "" || s.SecretAccessKey == "" {
+			return nil, fmt.Errorf("the aws signing requires a region and the credentials")
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("unknown signing type: %q", cfg.Type)
+}
+
+// NewSigningTransport returns a RoundTripper signing the requests with the received signer
+// before sending them through the received one
+func NewSigningTransport(next http.RoundTripper, signer RequestSigner) http.RoundTripper {
+	return &signingTransport{next, signer}
+}
+
+type signingTransport struct {
+	next   http.RoundTripper
+	signer RequestSigner
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (t *signingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var payload []byte
+	if req.Body != nil {
+		data, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		payload = data
+	}
+	signed := req.Clone(req.Context())
+	if payload != nil {
+		signed.Body = ioutil.NopCloser(bytes.NewReader(payload))
+	}
+	if err := t.signer.Sign(signed, payload); err != nil {
+		return nil, err
+	}
+	return t.next.RoundTrip(signed)
+}
+
+// HMACSigner signs the requests with a shared secret. The X-Signature header contains the hex
+// encoded HMAC-SHA256 of the method, the request URI, the X-Signature-Timestamp header (unix
+// seconds) and the hex encoded SHA256 of the body, separated by new lines
+type HMACSigner struct {
+	// KeyID, if not empty, is sent as the X-Signature-Key-Id header, so the backend can select
+	// the secret
+	KeyID  string
+	Secret []byte
+	// Now returns the current time
+	Now func() time.Time
+}
+
+// Sign implements the RequestSigner interface
+func (s *HMACSigner) Sign(req *http.Request, payload []byte) error {
+	timestamp := strconv.FormatInt(s.Now().Unix(), 10)
+	bodyHash := sha256.Sum256(payload)
+	mac := hmac.New(sha256.New, s.Secret)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s", req.Method, req.URL.RequestURI(), timestamp, hex.EncodeToString(bodyHash[:]))
+
+	req.Header.Set("X-Signature-Timestamp", timestamp)
+	req.Header.Set("X-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	if s.KeyID != "" {
+		req.Header.Set("X-Signature-Key-Id", s.KeyID)
+	}
+	return nil
+}
+
+// AWSSigV4Signer signs the requests with the AWS Signature Version 4, so the AWS API Gateway
+// endpoints (or any other AWS service) can be called without a proxy
+type AWSSigV4Signer struct {
+	Region          string
+	Service         string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// Now returns the current time
+	Now func() time.Time
+}
+
+// Sign implements the RequestSigner interface
+func (s *AWSSigV4Signer) Sign(req *http.Request, payload []byte) error {
+	now := s.Now().UTC()
+	amzDate := now.Format(awsTimeFormat)
+	date := amzDate[:8]
+	bodyHash := sha256.Sum256(payload)
+	payloadHash := hex.EncodeToString(bodyHash[:])
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+	if s.Service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for k, v := range req.Header {
+		name := strings.ToLower(k)
+		if name == "content-type" || strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	canonicalHeaders := &strings.Builder{}
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if s.Service != "s3" {
+		path = awsEscape(path, true)
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		awsCanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.Region + "/" + s.Service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + s.SecretAccessKey)
+	for _, part := range []string{date, s.Region, s.Service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKeyID, scope, signedHeaders, signature,
+	))
+	return nil
+}
+
+func awsCanonicalQuery(query url.Values) string {
+	pairs := make([]string, 0, len(query))
+	for k, values := range query {
+		for _, v := range values {
+			pairs = append(pairs, awsEscape(k, false)+"="+awsEscape(v, false))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// awsEscape encodes every byte but the unreserved characters of the RFC 3986 and, optionally,
+// the slashes
+func awsEscape(s string, keepSlash bool) string {
+	buf := &strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (keepSlash && c == '/') {
+			buf.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(buf, "%%%02X", c)
+	}
+	return buf.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,115 @@
+package engine
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAWSSigV4Signer(t *testing.T) {
+	// get-vanilla and get-vanilla-query-order-key-case from the AWS SigV4 test suite
+	s := &AWSSigV4Signer{
+		Region:          "us-east-1",
+		Service:         "service",
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		Now:             func() time.Time { return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC) },
+	}
+	for _, tc := range []struct {
+		url      string
+		expected string
+	}{
+		{
+			url:      "https://example.amazonaws.com/",
+			expected: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			url:      "https://example.amazonaws.com/?Param2=value2&Param1=value1",
+			expected: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		},
+	} {
+		req, _ := http.NewRequest("GET", tc.url, nil)
+		if err := s.Sign(req, nil); err != nil {
+			t.Error(err)
+			continue
+		}
+		if h := req.Header.Get("X-Amz-Date"); h != "20150830T123600Z" {
+			t.Errorf("%s: unexpected X-Amz-Date header: %s", tc.url, h)
+		}
+		if h := req.Header.Get("Authorization"); h != tc.expected {
+			t.Errorf("%s: unexpected Authorization header: %s", tc.url, h)
+		}
+	}
+}
+
+func TestHMACSigner(t *testing.T) {
+	s := &HMACSigner{KeyID: "k1", Secret: []byte("secret"), Now: func() time.Time { return time.Unix(1500000000, 0) }}
+	req, _ := http.NewRequest("POST", "http://example.com/a?b=c", nil)
+	if err := s.Sign(req, []byte("body")); err != nil {
+		t.Error(err)
+		return
+	}
+
+	bodyHash := sha256.Sum256([]byte("body"))
+	mac := hmac.New(sha256.New, []byte("secret"))
+	fmt.Fprintf(mac, "POST\n/a?b=c\n1500000000\n%s", hex.EncodeToString(bodyHash[:]))
+	if h := req.Header.Get("X-Signature"); h != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+		t.Errorf("unexpected X-Signature header: %s", h)
+	}
+	if h := req.Header.Get("X-Signature-Timestamp"); h != "1500000000" {
+		t.Errorf("unexpected X-Signature-Timestamp header: %s", h)
+	}
+	if h := req.Header.Get("X-Signature-Key-Id"); h != "k1" {
+		t.Errorf("unexpected X-Signature-Key-Id header: %s", h)
+	}
+}
+
+func TestNewSigningTransport(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		fmt.Fprintf(w, "%s %s", r.Header.Get("X-Signature-Timestamp"), body)
+	}))
+	defer backend.Close()
+
+	signer, err := NewRequestSigner(BackendSigning{Type: HMACSigning, Secret: "secret"})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	signer.(*HMACSigner).Now = func() time.Time { return time.Unix(1500000000, 0) }
+	client := &http.Client{Transport: NewSigningTransport(http.DefaultTransport, signer)}
+
+	req, _ := http.NewRequest("POST", backend.URL, bytes.NewBufferString("payload"))
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "1500000000 payload" {
+		t.Errorf("unexpected response: %s", body)
+	}
+	if req.Header.Get("X-Signature") != "" {
+		t.Error("the original request has been modified")
+	}
+}
+
+func TestNewRequestSigner_ko(t *testing.T) {
+	for _, cfg := range []BackendSigning{
+		{Type: "unknown"},
+		{Type: HMACSigning},
+		{Type: AWSSigV4Signing, AccessKeyID: "a", SecretAccessKey: "b"},
+	} {
+		if _, err := NewRequestSigner(cfg); err == nil {
+			t.Errorf("%s: error expected", cfg.Type)
+		}
+	}
+}
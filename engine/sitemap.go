@@ -0,0 +1,169 @@
+package engine
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	sitemapNamespace       = "http://www.sitemaps.org/schemas/sitemap/0.9"
+	defaultSitemapInterval = time.Hour
+)
+
+// NewSitemapGenerator creates a SitemapGenerator for the pages of the config
+func NewSitemapGenerator(cfg SitemapXML, pages []Page) *SitemapGenerator {
+	return &SitemapGenerator{
+		BaseURL:  strings.TrimSuffix(cfg.BaseURL, "/"),
+		Interval: parseDurationOr(cfg.Interval, defaultSitemapInterval),
+		Pages:    pages,
+		Client:   http.DefaultClient,
+	}
+}
+
+// SitemapGenerator builds the sitemap.xml file with the URLs of the pages. The pages without
+// params are added as they are, while the ones with params are expanded with the items listed
+// by their sitemap source. The pages with params and without source are skipped
+type SitemapGenerator struct {
+	BaseURL  string
+	Interval time.Duration
+	Pages    []Page
+	Client   *http.Client
+	mutex    sync.RWMutex
+	content  []byte
+	modTime  time.Time
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc        string `xml:"loc"`
+	LastMod    string `xml:"lastmod,omitempty"`
+	ChangeFreq string `xml:"changefreq,omitempty"`
+	Priority   string `xml:"priority,omitempty"`
+}
+
+// Generate builds the sitemap, replacing the served one. If a source fails, the current sitemap
+// is kept
+func (s *SitemapGenerator) Generate() error {
+	set := sitemapURLSet{XMLNS: sitemapNamespace}
+	for _, page := range s.Pages {
+		urls, err := s.pageURLs(page)
+		if err != nil {
+			return fmt.Errorf("sitemap of the page %s: %s", page.Name, err.Error())
+		}
+		set.URLs = append(set.URLs, urls...)
+	}
+	data, err := xml.MarshalIndent(set, "", "  ")
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	s.content = append([]byte(xml.Header), data...)
+	s.modTime = time.Now()
+	s.mutex.Unlock()
+	return nil
+}
+
+// Run generates the sitemap every Interval. The returned function stops it
+func (s *SitemapGenerator) Run() func() {
+	ticker := time.NewTicker(s.Interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				ticker.Stop()
+				return
+			case <-ticker.C:
+			}
+			if err := s.Generate(); err != nil {
+				log.Println("generating the sitemap:", err.Error())
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// HandlerFunc returns a gin handler serving the last generated sitemap
+func (s *SitemapGenerator) HandlerFunc() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		s.mutex.RLock()
+		h := StaticHandler{
+			Content:     s.content,
+			ModTime:     s.modTime,
+			ETag:        contentETag(s.content),
+			ContentType: "application/xml; charset=utf-8",
+		}
+		s.mutex.RUnlock()
+		if len(h.Content) == 0 {
+			c.AbortWithStatus(http.StatusServiceUnavailable)
+			return
+		}
+		h.HandlerFunc()(c)
+	}
+}
+
+func (s *SitemapGenerator) pageURLs(page Page) ([]sitemapURL, error) {
+	cfg := PageSitemap{}
+	if page.Sitemap != nil {
+		cfg = *page.Sitemap
+	}
+	if cfg.Exclude {
+		return nil, nil
+	}
+	newURL := func(path, lastMod string) sitemapURL {
+		return sitemapURL{
+			Loc:        s.BaseURL + path,
+			LastMod:    lastMod,
+			ChangeFreq: cfg.ChangeFreq,
+			Priority:   cfg.Priority,
+		}
+	}
+	if !strings.ContainsAny(page.URLPattern, ":*") {
+		return []sitemapURL{newURL(page.URLPattern, "")}, nil
+	}
+	if cfg.Source == "" {
+		return nil, nil
+	}
+
+	resp, err := s.Client.Get(cfg.Source)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	var items []map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&items); err != nil {
+		return nil, err
+	}
+
+	urls := make([]sitemapURL, 0, len(items))
+	for _, item := range items {
+		path := page.URLPattern
+		for param, field := range cfg.Params {
+			path = strings.Replace(path, ":"+param, url.PathEscape(fmt.Sprint(item[field])), -1)
+		}
+		lastMod := ""
+		if v, ok := item[cfg.LastMod]; ok && cfg.LastMod != "" {
+			lastMod = fmt.Sprint(v)
+		}
+		urls = append(urls, newURL(path, lastMod))
+	}
+	return urls, nil
+}
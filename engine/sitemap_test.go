@@ -0,0 +1,96 @@
+package engine
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSitemapGenerator(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"slug":"shoes","updated":"2024-01-02"},{"slug":"red hats","updated":"2024-02-03"}]`)
+	}))
+	defer backend.Close()
+
+	s := NewSitemapGenerator(SitemapXML{BaseURL: "https://example.com/"}, []Page{
+		{Name: "home", URLPattern: "/"},
+		{Name: "admin", URLPattern: "/admin", Sitemap: &PageSitemap{Exclude: true}},
+		{Name: "user", URLPattern: "/users/:id"},
+		{
+			Name:       "product",
+			URLPattern: "/products/:slug",
+			Sitemap: &PageSitemap{
+				Source:     backend.URL,
+				Params:     map[string]string{"slug": "slug"},
+				LastMod:    "updated",
+				ChangeFreq: "daily",
+			},
+		},
+	})
+
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.GET("/sitemap.xml", s.HandlerFunc())
+
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/sitemap.xml", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("unexpected status code before the generation: %d", w.Code)
+	}
+
+	if err := s.Generate(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/sitemap.xml", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/xml; charset=utf-8" {
+		t.Errorf("unexpected content type: %s", ct)
+	}
+	body := w.Body.String()
+	for _, expected := range []string{
+		`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`,
+		"<loc>https://example.com/</loc>",
+		"<loc>https://example.com/products/shoes</loc>\n    <lastmod>2024-01-02</lastmod>\n    <changefreq>daily</changefreq>",
+		"<loc>https://example.com/products/red%20hats</loc>",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("%s not found in the sitemap:\n%s", expected, body)
+		}
+	}
+	for _, unexpected := range []string{"/admin", "/users"} {
+		if strings.Contains(body, unexpected) {
+			t.Errorf("%s found in the sitemap:\n%s", unexpected, body)
+		}
+	}
+
+	r := httptest.NewRequest("GET", "/sitemap.xml", nil)
+	r.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, r)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("unexpected status code of the conditional request: %d", w.Code)
+	}
+}
+
+func TestSitemapGenerator_Generate_sourceError(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer backend.Close()
+
+	s := NewSitemapGenerator(SitemapXML{}, []Page{
+		{Name: "product", URLPattern: "/products/:slug", Sitemap: &PageSitemap{Source: backend.URL}},
+	})
+	if err := s.Generate(); err == nil {
+		t.Error("error expected")
+	}
+}
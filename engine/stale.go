@@ -0,0 +1,159 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// staleMaxEntries is the number of entries of a StaleCache triggering the removal of the expired
+// ones
+const staleMaxEntries = 10000
+
+// newStaleCache returns the StaleCache of the page or nil if its stale TTL is not defined
+func newStaleCache(page Page) *StaleCache {
+	ttl, err := time.ParseDuration(page.StaleTTL)
+	if err != nil || ttl <= 0 {
+		return nil
+	}
+	fresh, err := time.ParseDuration(page.CacheTTL)
+	if err != nil {
+		fresh = time.Hour
+	}
+	return &StaleCache{Fresh: fresh, TTL: ttl, entries: map[string]*staleEntry{}}
+}
+
+// StaleCache keeps the last rendered version of every URL of a page. Once they are older than
+// Fresh, and during TTL, they are served while they are rendered again in the background. They
+// are also served if the rendering fails
+type StaleCache struct {
+	Fresh   time.Duration
+	TTL     time.Duration
+	mutex   sync.Mutex
+	entries map[string]*staleEntry
+}
+
+type staleEntry struct {
+	body       []byte
+	rendered   time.Time
+	refreshing bool
+}
+
+func (s *StaleCache) get(key string) (*staleEntry, time.Duration, bool) {
+	e, ok := s.entries[key]
+	if !ok {
+		return nil, 0, false
+	}
+	age := time.Since(e.rendered)
+	return e, age, age <= s.Fresh+s.TTL
+}
+
+func (s *StaleCache) set(key string, body []byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.entries) >= staleMaxEntries {
+		for k := range s.entries {
+			if _, _, ok := s.get(k); !ok {
+				delete(s.entries, k)
+			}
+		}
+	}
+	s.entries[key] = &staleEntry{body: body, rendered: time.Now()}
+}
+
+// purge discards the rendered versions of all the URLs
+func (s *StaleCache) purge() {
+	s.mutex.Lock()
+	s.entries = map[string]*staleEntry{}
+	s.mutex.Unlock()
+}
+
+func (s *StaleCache) refreshed(key string) {
+	s.mutex.Lock()
+	if e, ok := s.entries[key]; ok {
+		e.refreshing = false
+	}
+	s.mutex.Unlock()
+}
+
+// cacheControl returns the Cache-Control directives allowing the intermediate caches to do the
+// same
+func (s *StaleCache) cacheControl() string {
+	ttl := int(s.TTL.Seconds())
+	return fmt.Sprintf("stale-while-revalidate=%d, stale-if-error=%d", ttl, ttl)
+}
+
+// staleKey returns the key of the entry of the request: its URL and the locale and location
+// negotiated for it, since they change the rendered response without changing the URL
+func staleKey(c *gin.Context) string {
+	return requestVariant(c) + " " + c.Request.URL.RequestURI()
+}
+
+// serveRevalidating writes the entry of the request if it is stale and triggers its refresh
+func (h *Handler) serveRevalidating(c *gin.Context) bool {
+	key := staleKey(c)
+	h.Stale.mutex.Lock()
+	e, age, ok := h.Stale.get(key)
+	if !ok || age <= h.Stale.Fresh {
+		h.Stale.mutex.Unlock()
+		return false
+	}
+	if !e.refreshing {
+		e.refreshing = true
+		// the refresh outlives the request, so its backend calls must not be cancelled with it
+		refresh := c.Copy()
+		refresh.Request = c.Request.WithContext(context.WithoutCancel(c.Request.Context()))
+		go h.refresh(refresh, key)
+	}
+	body := e.body
+	h.Stale.mutex.Unlock()
+
+	h.writeStale(c, body, age, `110 - "Response is Stale"`)
+	return true
+}
+
+// serveOnError writes the entry of the request if it is still usable
+func (h *Handler) serveOnError(c *gin.Context, err error) bool {
+	if errorLocation(err) != "" {
+		// the redirections are not failures
+		return false
+	}
+	h.Stale.mutex.Lock()
+	e, age, ok := h.Stale.get(staleKey(c))
+	h.Stale.mutex.Unlock()
+	if !ok {
+		return false
+	}
+	log.Println(h.Page.Name, "serving a stale response:", err.Error())
+	h.writeStale(c, e.body, age, `111 - "Revalidation Failed"`)
+	return true
+}
+
+func (h *Handler) writeStale(c *gin.Context, body []byte, age time.Duration, warning string) {
+	setHeaders(c, h.ResponseHeaders())
+	c.Header("Age", strconv.Itoa(int(age.Seconds())))
+	c.Header("Warning", warning)
+	h.writeRendered(c, body)
+}
+
+// refresh renders the request in the background, replacing its entry
+func (h *Handler) refresh(c *gin.Context, key string) {
+	defer h.Stale.refreshed(key)
+	result, err := h.ResponseGenerator(c)
+	if err != nil {
+		log.Println(h.Page.Name, "refreshing a stale response:", err.Error())
+		return
+	}
+	buf := &bytes.Buffer{}
+	if err := h.render(c, buf, result); err != nil {
+		log.Println(h.Page.Name, "refreshing a stale response:", err.Error())
+		return
+	}
+	h.Stale.set(key, buf.Bytes())
+}
@@ -0,0 +1,135 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestHandler_staleWhileRevalidate(t *testing.T) {
+	var calls int32
+	cfg := HandlerConfig{
+		Renderer: RendererFunc(func(w io.Writer, v interface{}) error {
+			_, err := fmt.Fprintf(w, "v%v", v.(ResponseContext).Extra["version"])
+			return err
+		}),
+		ResponseGenerator: func(_ *gin.Context) (ResponseContext, error) {
+			n := atomic.AddInt32(&calls, 1)
+			return ResponseContext{Extra: map[string]interface{}{"version": n}}, nil
+		},
+		CacheControl: "public, max-age=0",
+		Page:         Page{CacheTTL: "10ms", StaleTTL: "1m"},
+	}
+	h := NewHandler(cfg, make(chan Subscription, 10))
+
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.GET("/", h.HandlerFunc)
+
+	for _, expected := range []string{"v1", "v1"} {
+		time.Sleep(20 * time.Millisecond)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		e.ServeHTTP(w, req)
+		if body := w.Body.String(); body != expected {
+			t.Errorf("unexpected response content: %s", body)
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	e.ServeHTTP(w, req)
+	if body := w.Body.String(); body != "v2" {
+		t.Errorf("unexpected response content: %s", body)
+	}
+	if w.Header().Get("Warning") == "" {
+		t.Error("expecting a stale response")
+	}
+	if cc := w.Header().Get("Cache-Control"); !strings.Contains(cc, "stale-while-revalidate=60") {
+		t.Errorf("unexpected Cache-Control header: %s", cc)
+	}
+}
+
+func TestHandler_staleIfError(t *testing.T) {
+	var calls int32
+	cfg := HandlerConfig{
+		Renderer: RendererFunc(func(w io.Writer, _ interface{}) error {
+			_, err := w.Write([]byte("rendered"))
+			return err
+		}),
+		ResponseGenerator: func(_ *gin.Context) (ResponseContext, error) {
+			if atomic.AddInt32(&calls, 1) > 1 {
+				return ResponseContext{}, newBackendError(fmt.Errorf("boom"))
+			}
+			return ResponseContext{}, nil
+		},
+		Page: Page{StaleTTL: "1m"},
+	}
+	h := NewHandler(cfg, make(chan Subscription, 10))
+
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.GET("/", h.HandlerFunc)
+
+	for _, path := range []string{"/", "/", "/?other"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		e.ServeHTTP(w, req)
+		res, _ := ioutil.ReadAll(w.Result().Body)
+		if path == "/?other" {
+			if w.Result().StatusCode != http.StatusBadGateway {
+				t.Errorf("unexpected status code: %d", w.Result().StatusCode)
+			}
+			continue
+		}
+		if w.Result().StatusCode != 200 || string(res) != "rendered" {
+			t.Errorf("unexpected response: %d %s", w.Result().StatusCode, string(res))
+		}
+	}
+}
+
+func TestHandler_staleVariants(t *testing.T) {
+	cfg := HandlerConfig{
+		Renderer: RendererFunc(func(w io.Writer, v interface{}) error {
+			_, err := fmt.Fprintf(w, "%v", v.(ResponseContext).Extra["locale"])
+			return err
+		}),
+		ResponseGenerator: func(c *gin.Context) (ResponseContext, error) {
+			if c.GetHeader("X-Fail") != "" {
+				return ResponseContext{}, newBackendError(fmt.Errorf("boom"))
+			}
+			return ResponseContext{Extra: map[string]interface{}{"locale": LocaleFromContext(c)}}, nil
+		},
+		Page: Page{StaleTTL: "1m"},
+	}
+	h := NewHandler(cfg, make(chan Subscription, 10))
+
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.GET("/", func(c *gin.Context) {
+		c.Set(localeKey, c.GetHeader("Accept-Language"))
+	}, h.HandlerFunc)
+
+	// every locale keeps its own stale response
+	for _, fail := range []string{"", "true"} {
+		for _, locale := range []string{"en", "es"} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/", nil)
+			req.Header.Set("Accept-Language", locale)
+			req.Header.Set("X-Fail", fail)
+			e.ServeHTTP(w, req)
+			if w.Code != http.StatusOK || w.Body.String() != locale {
+				t.Errorf("%s: unexpected response: %d %s", locale, w.Code, w.Body.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamMarker is the content of the Stream property of the context rendering the page of a
+// StreamHandler, replaced by the rendered items
+const streamMarker = "api2html-stream-b3f0c2a9"
+
+// ErrNoStreamMarker is returned when the rendered page does not include the {{{Stream}}} tag
+var ErrNoStreamMarker = errors.New("the template of the streamed page does not contain the {{{Stream}}} tag")
+
+// NewStreamHandler creates a StreamHandler rendering the page with the renderers of the received
+// Handler and the item template of the page. The returned handler will be keeping itself
+// subscribed to the latest version of the item template using the given subscription channel
+func NewStreamHandler(h *Handler, subscriptionChan chan Subscription) *StreamHandler {
+	s := &StreamHandler{
+		Handler:   h,
+		Backend:   NewBackend(pageClient(h.Page), h.Page.BackendURLPattern),
+		Renderer:  EmptyRenderer,
+		Input:     make(chan Renderer),
+		Subscribe: subscriptionChan,
+	}
+	go s.updateRenderer()
+	return s
+}
+
+// StreamHandler renders the pages backed by large arrays incrementally. The elements of the
+// array are decoded and rendered one by one with the item template of the page, and sent with
+// chunked transfer as soon as they are rendered. The rest of the page, rendered by the Handler
+// with an empty Array, surrounds them and marks their position with the {{{Stream}}} tag.
+//
+// The streamed responses do not support the conditional requests nor the stale responses, and
+// the failures after sending the first chunk truncate them
+type StreamHandler struct {
+	Handler   *Handler
+	Backend   Backend
+	Renderer  Renderer
+	Input     chan Renderer
+	Subscribe chan Subscription
+	mutex     sync.RWMutex
+}
+
+func (s *StreamHandler) updateRenderer() {
+	subscribeRenderer(s.Subscribe, s.Handler.Page.Stream.ItemTemplate, s.Input, s.setRenderer)
+}
+
+// loadRenderers implements the rendererSubscriber interface
+func (s *StreamHandler) loadRenderers(store *TemplateStore) {
+	if r, ok := store.Get(s.Handler.Page.Stream.ItemTemplate); ok {
+		s.setRenderer(r)
+	}
+}
+
+func (s *StreamHandler) setRenderer(r Renderer) {
+	s.mutex.Lock()
+	s.Renderer = r
+	s.mutex.Unlock()
+}
+
+func (s *StreamHandler) itemRenderer() Renderer {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if s.Handler.Page.Minify {
+		return MinifiedRenderer{s.Renderer}
+	}
+	return s.Renderer
+}
+
+// HandlerFunc handles a gin request streaming the items of the backend response
+func (s *StreamHandler) HandlerFunc(c *gin.Context) {
+	page := s.Handler.Page
+	hooks := HooksFromContext(c)
+	hooks.OnRequest(c, page.Name)
+
+	params := requestParams(c)
+	headers := backendHeaders(page, c)
+	newContext := func() ResponseContext {
+		return ResponseContext{
+			Extra:   requestExtra(c, page.Extra),
+			Robots:  page.Robots,
+			Context: c,
+			Params:  params,
+			Geo:     GeoFromContext(c),
+			Helper:  newTplHelper(c),
+		}
+	}
+
+	resp, err := s.Backend(params, headers, c)
+	if err != nil {
+		s.abort(c, newBackendError(err))
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		s.abort(c, newDecodeError(resp, fmt.Errorf("unexpected status code %d", resp.StatusCode)))
+		return
+	}
+	decoder := json.NewDecoder(resp.Body)
+	decoder.UseNumber()
+	if t, err := decoder.Token(); err != nil || t != json.Delim('[') {
+		if err == nil {
+			err = errors.New("the backend response is not an array")
+		}
+		s.abort(c, newDecodeError(resp, err))
+		return
+	}
+
+	done := hooks.OnRender(c)
+	result := newContext()
+	result.Stream = streamMarker
+	buf := &bytes.Buffer{}
+	if err := s.Handler.render(c, buf, result); err != nil {
+		done(err)
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	parts := bytes.SplitN(buf.Bytes(), []byte(streamMarker), 2)
+	if len(parts) != 2 {
+		done(ErrNoStreamMarker)
+		c.AbortWithError(http.StatusInternalServerError, ErrNoStreamMarker)
+		return
+	}
+
+	setHeaders(c, s.Handler.ResponseHeaders())
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusOK)
+	c.Writer.Write(parts[0])
+	c.Writer.Flush()
+
+	item := s.itemRenderer()
+	for decoder.More() {
+		result := newContext()
+		if err = decoder.Decode(&result.Data); err != nil {
+			err = newDecodeError(resp, err)
+			break
+		}
+		if err = item.Render(c.Writer, result); err != nil {
+			break
+		}
+		c.Writer.Flush()
+	}
+	done(err)
+	if err != nil {
+		// the status code is already sent, so the response is truncated
+		c.Error(err)
+		return
+	}
+	c.Writer.Write(parts[1])
+}
+
+func (s *StreamHandler) abort(c *gin.Context, err error) {
+	status, headers := ErrorResponse(err)
+	setHeaders(c, headers)
+	c.AbortWithError(status, err)
+}
@@ -0,0 +1,80 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestStreamHandler(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/items":
+			fmt.Fprint(w, `[{"name":"a"},{"name":"b"},{"name":"c"}]`)
+		case "/truncated":
+			fmt.Fprint(w, `[{"name":"a"},{"name":`)
+		case "/object":
+			fmt.Fprint(w, `{"name":"a"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer backend.Close()
+
+	page, _ := NewMustacheRenderer(bytes.NewBufferString(`<h1>{{Extra.title}}</h1><ul>{{{Stream}}}</ul>`))
+	noMarker, _ := NewMustacheRenderer(bytes.NewBufferString(`<ul></ul>`))
+	item, _ := NewMustacheRenderer(bytes.NewBufferString(`<li>{{Data.name}}</li>`))
+
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	for _, tc := range []struct {
+		path     string
+		renderer Renderer
+	}{
+		{"/items", page},
+		{"/truncated", page},
+		{"/object", page},
+		{"/missing", page},
+		{"/no-marker", noMarker},
+	} {
+		backendPath := tc.path
+		if backendPath == "/no-marker" {
+			backendPath = "/items"
+		}
+		s := &StreamHandler{
+			Handler: &Handler{
+				Page: Page{
+					Name:     tc.path,
+					Extra:    map[string]interface{}{"title": "list"},
+					IsArray:  true,
+					Stream:   &PageStream{ItemTemplate: "item"},
+					CacheTTL: "1m",
+				},
+				Renderer:     tc.renderer,
+				CacheControl: cacheControl("1m"),
+			},
+			Backend:  DefaultClient(backend.URL + backendPath),
+			Renderer: item,
+		}
+		e.GET(tc.path, s.HandlerFunc)
+	}
+
+	assertResponse(t, e, "/items", http.StatusOK, "<h1>list</h1><ul><li>a</li><li>b</li><li>c</li></ul>")
+	assertResponse(t, e, "/truncated", http.StatusOK, "<h1>list</h1><ul><li>a</li>")
+	assertResponse(t, e, "/object", http.StatusBadGateway, "")
+	assertResponse(t, e, "/missing", http.StatusNotFound, "")
+	assertResponse(t, e, "/no-marker", http.StatusInternalServerError, "")
+
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/items", nil))
+	if cc := w.Header().Get("Cache-Control"); cc != "public, max-age=60" {
+		t.Errorf("unexpected Cache-Control header: %s", cc)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+		t.Errorf("unexpected Content-Type header: %s", ct)
+	}
+}
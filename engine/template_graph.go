@@ -0,0 +1,494 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+var partialTag = regexp.MustCompile(`\{\{>\s*([^\s}]+)\s*\}\}`)
+
+// NewTemplateGraph creates a TemplateGraph with the templates, the layouts and the pages of the
+// received config, reading them and their partials from the fs.FS (or from the local filesystem
+// if it is nil)
+func NewTemplateGraph(fsys fs.FS, cfg Config) (*TemplateGraph, error) {
+	g := &TemplateGraph{
+		fsys:     fsys,
+		registry: cfg.registry,
+		paths:    map[string]string{},
+		files:    map[string]string{},
+		includes: map[string][]string{},
+		layouts:  map[string][]string{},
+	}
+	for _, section := range []map[string]string{cfg.Templates, cfg.Layouts} {
+		for name, path := range section {
+			g.paths[name] = path
+			if err := g.scan(name, path); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, page := range cfg.Pages {
+		g.addPage(page)
+		for _, variant := range page.GeoVariants {
+			g.addPage(variant.page(page))
+		}
+	}
+	if cfg.RenderProxy != nil {
+		for _, page := range cfg.RenderProxy.pages() {
+			g.addPage(page)
+		}
+	}
+	return g, nil
+}
+
+// TemplateGraph tracks the partials included by every template and layout and the layouts
+// composed with every template, so a change in any of them is propagated to all the renderers
+// using it
+type TemplateGraph struct {
+	fsys fs.FS
+	// registry has the helpers and the partial caches of the renderers of the engine
+	registry *registry
+	// paths contains the files of the templates and the layouts
+	paths map[string]string
+	// files contains the files of the partials
+	files map[string]string
+	// includes contains the partials directly included by every template, layout and partial
+	includes map[string][]string
+	// layouts contains the layouts composed with every template
+	layouts map[string][]string
+	// History, if set, records every version of the templates and layouts published by the graph
+	History *TemplateHistory
+	// CanaryPercentage, if positive, is the percentage of the traffic rendered by the pushed and
+	// reloaded versions until they get promoted
+	CanaryPercentage int
+	mutex            sync.Mutex
+}
+
+func (g *TemplateGraph) addPage(page Page) {
+	if page.Layout == "" {
+		return
+	}
+	for _, layout := range g.layouts[page.Template] {
+		if layout == page.Layout {
+			return
+		}
+	}
+	g.layouts[page.Template] = append(g.layouts[page.Template], page.Layout)
+}
+
+// scan registers the partials included by the source at path, recursively
+func (g *TemplateGraph) scan(name, path string) error {
+	data, err := g.read(path)
+	if err != nil {
+		return err
+	}
+	g.includes[name] = nil
+	for _, match := range partialTag.FindAllSubmatch(data, -1) {
+		partial := string(match[1])
+		g.includes[name] = append(g.includes[name], partial)
+		if _, ok := g.includes[partial]; ok {
+			continue
+		}
+		if _, ok := partials[partial]; ok {
+			continue
+		}
+		file, ok := g.partialFile(partial)
+		if !ok {
+			// the missing partials render as empty strings
+			g.includes[partial] = nil
+			continue
+		}
+		g.files[partial] = file
+		if err := g.scan(partial, file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *TemplateGraph) read(path string) ([]byte, error) {
+	f, err := openFile(g.fsys, path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+// partialFile returns the file of the partial, with the same extensions as the partial providers
+func (g *TemplateGraph) partialFile(name string) (string, bool) {
+	for _, ext := range []string{"", ".mustache", ".stache"} {
+		f, err := openFile(g.fsys, name+ext)
+		if err != nil {
+			continue
+		}
+		f.Close()
+		return name + ext, true
+	}
+	return "", false
+}
+
+// NameOf returns the name of the template, layout or partial stored at the received path
+func (g *TemplateGraph) NameOf(path string) (string, bool) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	path = fsPath(path)
+	for _, section := range []map[string]string{g.paths, g.files} {
+		for name, p := range section {
+			if fsPath(p) == path {
+				return name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Dependents returns the templates and the layouts including the received template, layout or
+// partial, directly or through other partials, and the received one if it is a template or a
+// layout
+func (g *TemplateGraph) Dependents(name string) []string {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.dependents(name)
+}
+
+func (g *TemplateGraph) dependents(name string) []string {
+	res := []string{}
+	for candidate := range g.paths {
+		if g.uses(candidate, name, map[string]bool{}) {
+			res = append(res, candidate)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+func (g *TemplateGraph) uses(node, name string, visited map[string]bool) bool {
+	if node == name {
+		return true
+	}
+	if visited[node] {
+		return false
+	}
+	visited[node] = true
+	for _, partial := range g.includes[node] {
+		if g.uses(partial, name, visited) {
+			return true
+		}
+	}
+	return false
+}
+
+// Topics returns the names of the template store entries to republish after a change in the
+// received template, layout or partial: the dependent templates and layouts and all their
+// compositions
+func (g *TemplateGraph) Topics(name string) []string {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.topics(g.dependents(name))
+}
+
+func (g *TemplateGraph) topics(names []string) []string {
+	affected := map[string]bool{}
+	for _, name := range names {
+		affected[name] = true
+	}
+	res := append([]string{}, names...)
+	for template, layouts := range g.layouts {
+		for _, layout := range layouts {
+			if affected[template] || affected[layout] {
+				res = append(res, layoutTopic(layout, template))
+			}
+		}
+	}
+	sort.Strings(res[len(names):])
+	return res
+}
+
+// Reload parses again all the templates and layouts depending on the received one (or on the
+// received partial) and publishes them and their compositions into the store
+func (g *TemplateGraph) Reload(store *TemplateStore, name string) error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if file, ok := g.files[name]; ok {
+		// the partials are resolved while rendering, so they are validated before republishing
+		data, err := g.read(file)
+		if err != nil {
+			return err
+		}
+		if _, err := newMustacheRenderer(g.fsys, bytes.NewReader(data), g.registry); err != nil {
+			return fmt.Errorf("parsing %s: %s", file, err.Error())
+		}
+		if err := g.scan(name, file); err != nil {
+			return err
+		}
+	}
+	names := g.dependents(name)
+	renderers := map[string]*MustacheRenderer{}
+	for _, dependent := range names {
+		if err := g.scan(dependent, g.paths[dependent]); err != nil {
+			return err
+		}
+		if _, err := g.current(nil, renderers, dependent); err != nil {
+			return err
+		}
+	}
+	return g.publish(store, names, renderers, ReloadedTemplateVersion)
+}
+
+// ReloadAll parses again all the templates and layouts, with their partials, and publishes them
+// and their compositions into the store
+func (g *TemplateGraph) ReloadAll(store *TemplateStore) error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	names := make([]string, 0, len(g.paths))
+	for name := range g.paths {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	renderers := map[string]*MustacheRenderer{}
+	for _, name := range names {
+		if err := g.scan(name, g.paths[name]); err != nil {
+			return err
+		}
+		if _, err := g.current(nil, renderers, name); err != nil {
+			return err
+		}
+	}
+	return g.publish(store, names, renderers, ReloadedTemplateVersion)
+}
+
+// ReloadPaths reloads the renderers depending on the templates, layouts and partials stored at
+// the received paths, logging the errors
+func (g *TemplateGraph) ReloadPaths(store *TemplateStore, paths []string) {
+	for _, path := range paths {
+		name, ok := g.NameOf(path)
+		if !ok {
+			continue
+		}
+		log.Println("reloading the renderers depending on", name)
+		if err := g.Reload(store, name); err != nil {
+			log.Println(err.Error())
+		}
+	}
+}
+
+// Publish stores the received renderer as the template or layout with the received name and
+// republishes its compositions with the current version of the rest of the renderers
+func (g *TemplateGraph) Publish(store *TemplateStore, name string, r *MustacheRenderer) error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.publish(store, []string{name}, map[string]*MustacheRenderer{name: r}, PushedTemplateVersion)
+}
+
+// Rollback publishes again a previous version of the template or layout, recorded by the History
+func (g *TemplateGraph) Rollback(store *TemplateStore, name string, version int) error {
+	if g.History == nil {
+		return ErrUnknownTemplateVersion
+	}
+	v, ok := g.History.get(name, version)
+	if !ok {
+		return ErrUnknownTemplateVersion
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if err := g.publish(store, []string{name}, map[string]*MustacheRenderer{name: v.renderer}, ""); err != nil {
+		return err
+	}
+	g.History.setCurrent(name, version)
+	return nil
+}
+
+// publish stores the renderers and their compositions, recording them in the History with the
+// received source (if not empty)
+func (g *TemplateGraph) publish(store *TemplateStore, names []string, renderers map[string]*MustacheRenderer, source string) error {
+	set := func(topic string, r Renderer) error {
+		if g.CanaryPercentage <= 0 || source == "" {
+			return store.Set(topic, r)
+		}
+		stable, ok := store.Get(topic)
+		if !ok {
+			return store.Set(topic, r)
+		}
+		if canary, ok := stable.(*CanaryRenderer); ok {
+			stable = canary.Stable
+		}
+		return store.Set(topic, NewCanaryRenderer(stable, r, g.CanaryPercentage))
+	}
+
+	for _, name := range names {
+		if err := set(name, renderers[name]); err != nil {
+			return err
+		}
+		if g.History != nil && source != "" {
+			g.History.Add(name, source, renderers[name])
+		}
+	}
+	for _, topic := range g.topics(names)[len(names):] {
+		layout, template := splitLayoutTopic(topic)
+		t, err := g.current(store, renderers, template)
+		if err != nil {
+			return err
+		}
+		l, err := g.current(store, renderers, layout)
+		if err != nil {
+			return err
+		}
+		if err := set(topic, newLayoutRenderer(t, l)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// current returns the received renderer, the stored one (the newest one, in case of canaries) if
+// a store is given or a new one parsed from its file
+func (g *TemplateGraph) current(store *TemplateStore, renderers map[string]*MustacheRenderer, name string) (*MustacheRenderer, error) {
+	if r, ok := renderers[name]; ok {
+		return r, nil
+	}
+	if store != nil {
+		if r, ok := store.Get(name); ok {
+			if canary, ok := r.(*CanaryRenderer); ok {
+				r = canary.Canary
+			}
+			if m, ok := r.(*MustacheRenderer); ok {
+				return m, nil
+			}
+		}
+	}
+	path, ok := g.paths[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown template %s", name)
+	}
+	data, err := g.read(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := newMustacheRenderer(g.fsys, bytes.NewReader(data), g.registry)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %s", path, err.Error())
+	}
+	renderers[name] = r
+	return r, nil
+}
+
+// Canaries returns the reports of the canaries of the template or layout and its compositions
+func (g *TemplateGraph) Canaries(store *TemplateStore, name string) map[string]CanaryReport {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	res := map[string]CanaryReport{}
+	for _, topic := range g.topics([]string{name}) {
+		if r, ok := store.Get(topic); ok {
+			if canary, ok := r.(*CanaryRenderer); ok {
+				res[topic] = canary.Report()
+			}
+		}
+	}
+	return res
+}
+
+// PromoteCanary replaces the canaries of the template or layout and its compositions with their
+// new versions
+func (g *TemplateGraph) PromoteCanary(store *TemplateStore, name string) error {
+	return g.resolveCanary(store, name, true)
+}
+
+// AbortCanary replaces the canaries of the template or layout and its compositions with their
+// previous versions
+func (g *TemplateGraph) AbortCanary(store *TemplateStore, name string) error {
+	return g.resolveCanary(store, name, false)
+}
+
+func (g *TemplateGraph) resolveCanary(store *TemplateStore, name string, promote bool) error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	found := false
+	for _, topic := range g.topics([]string{name}) {
+		r, ok := store.Get(topic)
+		if !ok {
+			continue
+		}
+		canary, ok := r.(*CanaryRenderer)
+		if !ok {
+			continue
+		}
+		found = true
+		next := canary.Stable
+		if promote {
+			next = canary.Canary
+		}
+		if err := store.Set(topic, next); err != nil {
+			return err
+		}
+	}
+	if !found {
+		return ErrNoCanary
+	}
+	return nil
+}
+
+// Watch reloads the dependents of the templates, layouts and partials changed in the local
+// filesystem. The returned function stops watching them
+func (g *TemplateGraph) Watch(store *TemplateStore) (func() error, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	g.mutex.Lock()
+	names := map[string]string{}
+	for name, path := range g.paths {
+		names[filepath.Clean(path)] = name
+	}
+	for name, path := range g.files {
+		names[filepath.Clean(path)] = name
+	}
+	g.mutex.Unlock()
+
+	for path := range names {
+		if err := watcher.Add(filepath.Dir(path)); err != nil && !os.IsNotExist(err) {
+			watcher.Close()
+			return nil, err
+		}
+	}
+
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				name, ok := names[filepath.Clean(event.Name)]
+				if !ok || event.Op == fsnotify.Chmod {
+					continue
+				}
+				log.Println("reloading the renderers depending on", name)
+				if err := g.Reload(store, name); err != nil {
+					log.Println(err.Error())
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Println("watching the templates:", err.Error())
+			}
+		}
+	}()
+
+	return watcher.Close, nil
+}
@@ -0,0 +1,170 @@
+package engine
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func newTestTemplateGraph(t *testing.T) (*TemplateGraph, fstest.MapFS) {
+	fsys := fstest.MapFS{
+		"tmpl/home.mustache":       {Data: []byte(`home {{> partials/header }}`)},
+		"tmpl/about.mustache":      {Data: []byte(`about`)},
+		"layouts/main.mustache":    {Data: []byte(`-{{{ content }}}-`)},
+		"partials/header.mustache": {Data: []byte(`header {{> partials/logo}} {{> partials/missing}}`)},
+		"partials/logo":            {Data: []byte(`logo v1`)},
+	}
+	cfg := Config{
+		Templates: map[string]string{"home": "tmpl/home.mustache", "about": "tmpl/about.mustache"},
+		Layouts:   map[string]string{"main": "layouts/main.mustache"},
+		Pages: []Page{
+			{Name: "home", Template: "home", Layout: "main"},
+			{Name: "about", Template: "about", Layout: "main"},
+			{Name: "home-raw", Template: "home"},
+		},
+	}
+	g, err := NewTemplateGraph(fsys, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g, fsys
+}
+
+func TestTemplateGraph_Topics(t *testing.T) {
+	g, _ := newTestTemplateGraph(t)
+
+	for name, expected := range map[string][]string{
+		"partials/logo":   {"home", "main-:-home"},
+		"partials/header": {"home", "main-:-home"},
+		"home":            {"home", "main-:-home"},
+		"about":           {"about", "main-:-about"},
+		"main":            {"main", "main-:-about", "main-:-home"},
+		"unknown":         {},
+	} {
+		if topics := g.Topics(name); !reflect.DeepEqual(topics, expected) {
+			t.Errorf("%s: unexpected topics: %v", name, topics)
+		}
+	}
+	if dependents := g.Dependents("partials/logo"); !reflect.DeepEqual(dependents, []string{"home"}) {
+		t.Errorf("unexpected dependents: %v", dependents)
+	}
+}
+
+func TestTemplateGraph_Reload(t *testing.T) {
+	g, fsys := newTestTemplateGraph(t)
+	store := NewTemplateStore()
+
+	in := make(chan Renderer)
+	store.Subscribe <- Subscription{"main-:-home", in}
+
+	fsys["partials/logo"] = &fstest.MapFile{Data: []byte(`logo v2`)}
+	errs := make(chan error)
+	go func() { errs <- g.Reload(store, "partials/logo") }()
+
+	var r Renderer
+	select {
+	case r = <-in:
+	case err := <-errs:
+		t.Errorf("the composition was not republished: %v", err)
+		return
+	}
+	if err := <-errs; err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	buf := &bytes.Buffer{}
+	if err := r.Render(buf, nil); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if buf.String() != "-home header logo v2 -" {
+		t.Errorf("unexpected render: %s", buf.String())
+	}
+	if _, ok := store.Get("main-:-about"); ok {
+		t.Error("the unaffected compositions should not be republished")
+	}
+
+	fsys["partials/logo"] = &fstest.MapFile{Data: []byte(`{{ logo `)}
+	if err := g.Reload(store, "partials/logo"); err == nil {
+		t.Error("expecting an error")
+	}
+}
+
+func TestTemplateGraph_Publish(t *testing.T) {
+	g, _ := newTestTemplateGraph(t)
+	store := NewTemplateStore()
+
+	about, err := NewMustacheRenderer(bytes.NewBufferString(`about`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Set("about", about)
+
+	layout, err := NewMustacheRenderer(bytes.NewBufferString(`[{{{ content }}}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Publish(store, "main", layout); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+
+	r, ok := store.Get("main-:-about")
+	if !ok {
+		t.Error("the composition should be republished")
+		return
+	}
+	buf := &bytes.Buffer{}
+	r.Render(buf, nil)
+	if buf.String() != "[about]" {
+		t.Errorf("unexpected render: %s", buf.String())
+	}
+	r, ok = store.Get("main-:-home")
+	if !ok {
+		t.Error("the composition should be republished")
+		return
+	}
+	buf.Reset()
+	r.Render(buf, nil)
+	if buf.String() != "[home header logo v1 ]" {
+		t.Errorf("unexpected render: %s", buf.String())
+	}
+}
+
+func TestTemplateGraph_Rollback(t *testing.T) {
+	g, _ := newTestTemplateGraph(t)
+	store := NewTemplateStore()
+	if err := g.Rollback(store, "main", 1); err != ErrUnknownTemplateVersion {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	g.History = NewTemplateHistory(0)
+	for _, layout := range []string{`[{{{ content }}}]`, `({{{ content }}})`} {
+		r, err := NewMustacheRenderer(bytes.NewBufferString(layout))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := g.Publish(store, "main", r); err != nil {
+			t.Errorf("unexpected error: %s", err.Error())
+			return
+		}
+	}
+
+	if err := g.Rollback(store, "main", 1); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	r, _ := store.Get("main-:-about")
+	buf := &bytes.Buffer{}
+	r.Render(buf, nil)
+	if buf.String() != "[about]" {
+		t.Errorf("unexpected render: %s", buf.String())
+	}
+	if versions := g.History.Versions("main"); len(versions) != 2 || !versions[0].Current {
+		t.Errorf("unexpected versions: %v", versions)
+	}
+	if err := g.Rollback(store, "main", 3); err != ErrUnknownTemplateVersion {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
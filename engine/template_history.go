@@ -0,0 +1,103 @@
+package engine
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTemplateVersions = 10
+
+	// InitialTemplateVersion is the source of the versions loaded on start
+	InitialTemplateVersion = "initial"
+	// PushedTemplateVersion is the source of the versions uploaded to the template endpoint
+	PushedTemplateVersion = "push"
+	// ReloadedTemplateVersion is the source of the versions reloaded from the filesystem
+	ReloadedTemplateVersion = "reload"
+)
+
+// ErrUnknownTemplateVersion is the error returned when rolling back to a missing version
+var ErrUnknownTemplateVersion = fmt.Errorf("unknown template version")
+
+// NewTemplateHistory creates a TemplateHistory keeping the last max versions of every template
+// (10 if max is not positive)
+func NewTemplateHistory(max int) *TemplateHistory {
+	if max <= 0 {
+		max = defaultTemplateVersions
+	}
+	return &TemplateHistory{
+		max:      max,
+		versions: map[string][]TemplateVersion{},
+		last:     map[string]int{},
+		current:  map[string]int{},
+	}
+}
+
+// TemplateHistory keeps the last versions of the templates and layouts, so they can be rolled
+// back
+type TemplateHistory struct {
+	max      int
+	mutex    sync.Mutex
+	versions map[string][]TemplateVersion
+	last     map[string]int
+	current  map[string]int
+}
+
+// TemplateVersion is a version of a template or a layout
+type TemplateVersion struct {
+	Version   int       `json:"version"`
+	Source    string    `json:"source"`
+	CreatedAt time.Time `json:"created_at"`
+	Current   bool      `json:"current"`
+	renderer  *MustacheRenderer
+}
+
+// Add registers the received renderer as the current version of the template, discarding the
+// oldest version if the history is full
+func (h *TemplateHistory) Add(name, source string, r *MustacheRenderer) TemplateVersion {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.last[name]++
+	v := TemplateVersion{Version: h.last[name], Source: source, CreatedAt: time.Now(), renderer: r}
+	versions := append(h.versions[name], v)
+	if len(versions) > h.max {
+		versions = versions[len(versions)-h.max:]
+	}
+	h.versions[name] = versions
+	h.current[name] = v.Version
+	return v
+}
+
+// Versions returns the kept versions of the template, from the oldest to the newest
+func (h *TemplateHistory) Versions(name string) []TemplateVersion {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	res := make([]TemplateVersion, len(h.versions[name]))
+	for i, v := range h.versions[name] {
+		v.Current = v.Version == h.current[name]
+		res[i] = v
+	}
+	return res
+}
+
+// get returns the received version of the template
+func (h *TemplateHistory) get(name string, version int) (TemplateVersion, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for _, v := range h.versions[name] {
+		if v.Version == version {
+			return v, true
+		}
+	}
+	return TemplateVersion{}, false
+}
+
+func (h *TemplateHistory) setCurrent(name string, version int) {
+	h.mutex.Lock()
+	h.current[name] = version
+	h.mutex.Unlock()
+}
@@ -0,0 +1,39 @@
+package engine
+
+import (
+	"testing"
+)
+
+func TestTemplateHistory(t *testing.T) {
+	h := NewTemplateHistory(2)
+	for _, source := range []string{InitialTemplateVersion, PushedTemplateVersion, ReloadedTemplateVersion} {
+		h.Add("a", source, &MustacheRenderer{})
+	}
+	h.Add("b", PushedTemplateVersion, &MustacheRenderer{})
+
+	versions := h.Versions("a")
+	if len(versions) != 2 {
+		t.Errorf("unexpected versions: %v", versions)
+		return
+	}
+	if versions[0].Version != 2 || versions[0].Source != PushedTemplateVersion || versions[0].Current {
+		t.Errorf("unexpected version: %v", versions[0])
+	}
+	if versions[1].Version != 3 || versions[1].Source != ReloadedTemplateVersion || !versions[1].Current {
+		t.Errorf("unexpected version: %v", versions[1])
+	}
+	if _, ok := h.get("a", 1); ok {
+		t.Error("the oldest version should be discarded")
+	}
+
+	h.setCurrent("a", 2)
+	if versions := h.Versions("a"); !versions[0].Current || versions[1].Current {
+		t.Errorf("unexpected current version: %v", versions)
+	}
+	if versions := h.Versions("b"); len(versions) != 1 || versions[0].Version != 1 {
+		t.Errorf("unexpected versions: %v", versions)
+	}
+	if versions := h.Versions("unknown"); len(versions) != 0 {
+		t.Errorf("unexpected versions: %v", versions)
+	}
+}
@@ -0,0 +1,141 @@
+package engine
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrUnknownTemplateSet is the error returned when activating a missing template set
+var ErrUnknownTemplateSet = fmt.Errorf("unknown template set")
+
+// NewTemplateSwitch creates a TemplateSwitch with the sets of the received config
+func NewTemplateSwitch(cfg TemplateSets) (*TemplateSwitch, error) {
+	if len(cfg.Sets) == 0 {
+		return nil, fmt.Errorf("no template sets defined")
+	}
+	s := &TemplateSwitch{}
+	for name := range cfg.Sets {
+		s.sets = append(s.sets, name)
+	}
+	sort.Strings(s.sets)
+
+	active := cfg.Active
+	if active == "" {
+		active = s.sets[0]
+	}
+	if err := s.Activate(active); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// TemplateSwitch holds the active template set, shared by all the TemplateSetRenderers, so
+// every page switches to a new set at the same time
+type TemplateSwitch struct {
+	sets   []string
+	active atomic.Value
+}
+
+// Sets returns the names of the template sets
+func (s *TemplateSwitch) Sets() []string {
+	return append([]string{}, s.sets...)
+}
+
+// Active returns the name of the active template set
+func (s *TemplateSwitch) Active() string {
+	active, _ := s.active.Load().(string)
+	return active
+}
+
+// Activate makes all the pages render with the received template set
+func (s *TemplateSwitch) Activate(name string) error {
+	for _, set := range s.sets {
+		if set == name {
+			s.active.Store(name)
+			return nil
+		}
+	}
+	return ErrUnknownTemplateSet
+}
+
+// Routes returns a route setter registering the switch endpoints: the listing of the sets and
+// the activation of one of them. If the token is not empty, it is required as a bearer token
+func (s *TemplateSwitch) Routes(token string) func(*gin.Engine) {
+	authorized := bearerTokenAuth(token)
+
+	return func(e *gin.Engine) {
+		e.GET("/template_sets", authorized, func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"active": s.Active(), "sets": s.Sets()})
+		})
+		e.POST("/template_sets/:name/activate", authorized, func(c *gin.Context) {
+			name := c.Param("name")
+			if err := s.Activate(name); err != nil {
+				c.AbortWithError(http.StatusNotFound, err)
+				return
+			}
+			c.String(http.StatusOK, fmt.Sprintf("template set '%s' activated!", name))
+		})
+	}
+}
+
+// bearerTokenAuth returns a gin middleware rejecting the requests without the token as a bearer
+// token. If the token is empty, all the requests are accepted
+func bearerTokenAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !validBearerToken(c, token) {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Next()
+	}
+}
+
+// validBearerToken returns true if the token is empty or the request has it as a bearer token
+func validBearerToken(c *gin.Context, token string) bool {
+	expected := []byte("Bearer " + token)
+	return token == "" || subtle.ConstantTimeCompare([]byte(c.Request.Header.Get("Authorization")), expected) == 1
+}
+
+// TemplateSetRenderer is a Renderer delegating to the renderer of the active template set
+type TemplateSetRenderer struct {
+	Switch    *TemplateSwitch
+	Renderers map[string]Renderer
+}
+
+// Render implements the Renderer interface
+func (r *TemplateSetRenderer) Render(w io.Writer, v interface{}) error {
+	return r.Renderers[r.Switch.Active()].Render(w, v)
+}
+
+// templateSetConfigs returns the config of every template set, with its templates and layouts
+// overriding the global ones
+func templateSetConfigs(cfg Config) map[string]Config {
+	res := map[string]Config{}
+	if cfg.TemplateSets == nil {
+		return res
+	}
+	for name, set := range cfg.TemplateSets.Sets {
+		setCfg := cfg
+		setCfg.Templates = mergeTemplatePaths(cfg.Templates, set.Templates)
+		setCfg.Layouts = mergeTemplatePaths(cfg.Layouts, set.Layouts)
+		res[name] = setCfg
+	}
+	return res
+}
+
+func mergeTemplatePaths(base, overrides map[string]string) map[string]string {
+	res := map[string]string{}
+	for k, v := range base {
+		res[k] = v
+	}
+	for k, v := range overrides {
+		res[k] = v
+	}
+	return res
+}
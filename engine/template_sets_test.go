@@ -0,0 +1,105 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestNewTemplateSwitch(t *testing.T) {
+	if _, err := NewTemplateSwitch(TemplateSets{}); err == nil {
+		t.Error("expecting an error")
+	}
+	sets := map[string]TemplateSet{"green": {}, "blue": {}}
+	if _, err := NewTemplateSwitch(TemplateSets{Sets: sets, Active: "red"}); err != ErrUnknownTemplateSet {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	s, err := NewTemplateSwitch(TemplateSets{Sets: sets})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if s.Active() != "blue" || !reflect.DeepEqual(s.Sets(), []string{"blue", "green"}) {
+		t.Errorf("unexpected switch: %s %v", s.Active(), s.Sets())
+	}
+	if err := s.Activate("green"); err != nil || s.Active() != "green" {
+		t.Errorf("unexpected active set: %s %v", s.Active(), err)
+	}
+	if err := s.Activate("red"); err != ErrUnknownTemplateSet || s.Active() != "green" {
+		t.Errorf("unexpected active set: %s %v", s.Active(), err)
+	}
+}
+
+func TestFactory_New_templateSets(t *testing.T) {
+	ef := DefaultFactory
+	ef.TemplateFS = fstest.MapFS{
+		"a.mustache":       {Data: []byte("a")},
+		"b.mustache":       {Data: []byte("b")},
+		"layout.mustache":  {Data: []byte("[{{{ content }}}]")},
+		"blue/a.mustache":  {Data: []byte("blue a")},
+		"green/a.mustache": {Data: []byte("green a")},
+		"green/layout":     {Data: []byte("({{{ content }}})")},
+	}
+	ef.Parser = func(_ string) (Config, error) {
+		return Config{
+			Pages: []Page{
+				{URLPattern: "/a", Template: "a", Layout: "layout"},
+				{URLPattern: "/b", Template: "b", Layout: "layout"},
+			},
+			Templates: map[string]string{"a": "a.mustache", "b": "b.mustache"},
+			Layouts:   map[string]string{"layout": "layout.mustache"},
+			TemplateSets: &TemplateSets{
+				Sets: map[string]TemplateSet{
+					"blue":  {Templates: map[string]string{"a": "blue/a.mustache"}},
+					"green": {Templates: map[string]string{"a": "green/a.mustache"}, Layouts: map[string]string{"layout": "green/layout"}},
+				},
+				Active: "blue",
+				Token:  "secret",
+			},
+		}, nil
+	}
+
+	e, err := ef.New("something", false)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	assertResponse(t, e, "/a", http.StatusOK, "[blue a]")
+	assertResponse(t, e, "/b", http.StatusOK, "[b]")
+
+	for _, tc := range []struct {
+		url, token string
+		status     int
+	}{
+		{url: "/template_sets/green/activate", status: http.StatusUnauthorized},
+		{url: "/template_sets/red/activate", token: "secret", status: http.StatusNotFound},
+		{url: "/template_sets/green/activate", token: "secret", status: http.StatusOK},
+	} {
+		req, _ := http.NewRequest("POST", tc.url, nil)
+		if tc.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		if w.Code != tc.status {
+			t.Errorf("[%s] unexpected status code: %d", tc.url, w.Code)
+		}
+	}
+
+	assertResponse(t, e, "/a", http.StatusOK, "(green a)")
+	assertResponse(t, e, "/b", http.StatusOK, "(b)")
+
+	req, _ := http.NewRequest("GET", "/template_sets", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	if w.Body.String() != `{"active":"green","sets":["blue","green"]}` {
+		t.Errorf("unexpected body: %s", w.Body.String())
+	}
+}
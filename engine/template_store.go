@@ -1,19 +1,30 @@
 package engine
 
-import "sync"
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
 
 // NewTemplateStore creates a TemplateStore ready to be used
 //
 // The returned TemplateStore will be accepting and managing
-// subscriptions
+// subscriptions until it is closed
 func NewTemplateStore() *TemplateStore {
 	store := &TemplateStore{
 		&templateStore{
-			map[string]Renderer{},
-			map[string]*sync.RWMutex{},
+			data:     map[string]Renderer{},
+			mutex:    map[string]*sync.RWMutex{},
+			versions: map[string]uint64{},
 		},
 		make(chan Subscription),
 		&sync.Map{},
+		sync.Mutex{},
+		map[chan Renderer]bool{},
+		map[Subscription]uint64{},
+		make(chan struct{}),
+		sync.Once{},
 	}
 	go store.subscribe()
 	return store
@@ -24,16 +35,76 @@ type TemplateStore struct {
 	*templateStore
 	Subscribe chan Subscription
 	observers *sync.Map
+	// observersMutex keeps the subscriptions received while notifying a change from being lost
+	observersMutex sync.Mutex
+	// inputs are the channels of all the subscribers, closed with the store
+	inputs map[chan Renderer]bool
+	// delivered is the version of the last renderer sent to every subscription
+	delivered map[Subscription]uint64
+	done      chan struct{}
+	once      sync.Once
 }
 
 func (p *TemplateStore) subscribe() {
 	for {
-		subscription := <-p.Subscribe
+		var subscription Subscription
+		select {
+		case <-p.done:
+			return
+		case subscription = <-p.Subscribe:
+		}
+		p.observersMutex.Lock()
+		if p.closed() {
+			// the subscribers arriving after the store was closed are released right away
+			if !p.inputs[subscription.In] {
+				p.inputs[subscription.In] = true
+				close(subscription.In)
+			}
+			p.observersMutex.Unlock()
+			continue
+		}
+		p.inputs[subscription.In] = true
+		if r, v, ok := p.getVersion(subscription.Name); ok && p.delivered[subscription] < v {
+			// the subscriber missed the current version while it was not subscribed, so it gets it
+			// right away instead of waiting for the next change
+			p.delivered[subscription] = v
+			subscription.In <- r
+			p.observersMutex.Unlock()
+			continue
+		}
 		actual, loaded := p.observers.LoadOrStore(subscription.Name, []chan Renderer{subscription.In})
 		if loaded {
 			chans := actual.([]chan Renderer)
 			p.observers.Store(subscription.Name, append(chans, subscription.In))
 		}
+		p.observersMutex.Unlock()
+	}
+}
+
+// Close stops accepting subscriptions and closes the channels of the subscribers, so they stop
+// waiting for new renderers. The renderers stored are kept
+func (p *TemplateStore) Close() error {
+	p.once.Do(func() {
+		p.observersMutex.Lock()
+		defer p.observersMutex.Unlock()
+		close(p.done)
+		for in := range p.inputs {
+			close(in)
+		}
+		p.observers.Range(func(name, _ interface{}) bool {
+			p.observers.Delete(name)
+			return true
+		})
+	})
+	return nil
+}
+
+func (p *TemplateStore) closed() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
 	}
 }
 
@@ -44,11 +115,14 @@ func (p *TemplateStore) Set(name string, tmpl Renderer) error {
 		return err
 	}
 
+	p.observersMutex.Lock()
+	defer p.observersMutex.Unlock()
 	if actual, ok := p.observers.Load(name); ok {
-		r := p.data[name]
+		r, v, _ := p.getVersion(name)
 		chans := actual.([]chan Renderer)
 		for _, out := range chans {
 			out <- r
+			p.delivered[Subscription{name, out}] = v
 		}
 	}
 
@@ -59,6 +133,12 @@ func (p *TemplateStore) Set(name string, tmpl Renderer) error {
 type templateStore struct {
 	data  map[string]Renderer
 	mutex map[string]*sync.RWMutex
+	// versions counts the changes of every renderer
+	versions map[string]uint64
+	// guard protects the maps from concurrent writes
+	guard sync.RWMutex
+	// updated is the time of the last change
+	updated time.Time
 }
 
 // Get returns a Renderer and a boolean signaling if the given name is not in the store
@@ -66,19 +146,46 @@ func (p *templateStore) Get(name string) (Renderer, bool) {
 	m := p.getMutex(name)
 	m.RLock()
 	defer m.RUnlock()
+	p.guard.RLock()
+	defer p.guard.RUnlock()
 	t, ok := p.data[name]
 	return t, ok
 }
 
+// getVersion returns a Renderer along with the number of times it has been set
+func (p *templateStore) getVersion(name string) (Renderer, uint64, bool) {
+	m := p.getMutex(name)
+	m.RLock()
+	defer m.RUnlock()
+	p.guard.RLock()
+	defer p.guard.RUnlock()
+	t, ok := p.data[name]
+	return t, p.versions[name], ok
+}
+
 func (p *templateStore) Set(name string, tmpl Renderer) error {
 	m := p.getMutex(name)
 	m.Lock()
+	p.guard.Lock()
 	p.data[name] = tmpl
+	p.versions[name]++
+	p.updated = time.Now()
+	p.guard.Unlock()
 	m.Unlock()
 	return nil
 }
 
+// UpdatedAt returns the time of the last change of the renderers, or the zero time if the store
+// is empty
+func (p *templateStore) UpdatedAt() time.Time {
+	p.guard.RLock()
+	defer p.guard.RUnlock()
+	return p.updated
+}
+
 func (p *templateStore) getMutex(name string) *sync.RWMutex {
+	p.guard.Lock()
+	defer p.guard.Unlock()
 	m, ok := p.mutex[name]
 	if !ok {
 		m = &sync.RWMutex{}
@@ -86,3 +193,48 @@ func (p *templateStore) getMutex(name string) *sync.RWMutex {
 	}
 	return m
 }
+
+// rendererSubscriber is a component keeping its renderers updated through the subscriptions of
+// a TemplateStore
+type rendererSubscriber interface {
+	// loadRenderers takes the renderers already in the store, so the component does not depend
+	// on the subscriptions to get their first version
+	loadRenderers(store *TemplateStore)
+}
+
+// subscribeRenderer keeps the renderer of the topic updated through the received function,
+// sending the input channel to the subscription channel of a TemplateStore every time it gets a
+// new version. It blocks until the store closes the input channel
+func subscribeRenderer(subscriptions chan Subscription, topic string, in chan Renderer, update func(Renderer)) {
+	for {
+		select {
+		case subscriptions <- Subscription{topic, in}:
+		case r, ok := <-in:
+			// the store closes the input channels of the subscribers waiting to subscribe again
+			if !ok {
+				return
+			}
+			update(r)
+			continue
+		}
+		r, ok := <-in
+		if !ok {
+			return
+		}
+		update(r)
+	}
+}
+
+// layoutTopic returns the name of the composition of a template with a layout in the store
+func layoutTopic(layout, template string) string {
+	return fmt.Sprintf("%s-:-%s", layout, template)
+}
+
+// splitLayoutTopic returns the layout and the template of a composition
+func splitLayoutTopic(topic string) (string, string) {
+	parts := strings.SplitN(topic, "-:-", 2)
+	if len(parts) < 2 {
+		return "", topic
+	}
+	return parts[0], parts[1]
+}
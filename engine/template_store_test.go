@@ -0,0 +1,84 @@
+package engine
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func TestTemplateStore_Close(t *testing.T) {
+	store := NewTemplateStore()
+	updates := make(chan Renderer, 1)
+	done := make(chan struct{})
+	go func() {
+		subscribeRenderer(store.Subscribe, "home", make(chan Renderer), func(r Renderer) { updates <- r })
+		close(done)
+	}()
+
+	// the subscription is received before the store closes
+	for i := 0; i < 50; i++ {
+		if actual, ok := store.observers.Load("home"); ok && len(actual.([]chan Renderer)) == 1 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := store.Set("home", RendererFunc(func(w io.Writer, _ interface{}) error { return nil })); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	select {
+	case <-updates:
+	case <-time.After(time.Second):
+		t.Error("the subscriber did not get the renderer")
+		return
+	}
+
+	if err := store.Close(); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("the subscriber is still running")
+	}
+
+	// the updates after closing the store are not sent to anybody
+	if err := store.Set("home", EmptyRenderer); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+}
+
+func TestTemplateStore_missedVersion(t *testing.T) {
+	store := NewTemplateStore()
+	defer store.Close()
+
+	if err := store.Set("home", EmptyRenderer); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+
+	// the subscriptions arriving after a change get the current version right away
+	in := make(chan Renderer)
+	store.Subscribe <- Subscription{"home", in}
+	select {
+	case <-in:
+	case <-time.After(time.Second):
+		t.Error("the subscriber did not get the current renderer")
+		return
+	}
+
+	// the subscribers already holding the current version wait for the next change
+	store.Subscribe <- Subscription{"home", in}
+	select {
+	case <-in:
+		t.Error("the subscriber got the same version twice")
+		return
+	case <-time.After(50 * time.Millisecond):
+	}
+	go store.Set("home", EmptyRenderer)
+	select {
+	case <-in:
+	case <-time.After(time.Second):
+		t.Error("the subscriber did not get the new renderer")
+	}
+}
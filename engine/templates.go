@@ -27,6 +27,90 @@ var (
 	<p>You might want to customize this file by editing <code>static/500</code></p>
 </body>`
 
+	default401Tmpl = `<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="utf-8">
+	<link rel="stylesheet" href="https://maxcdn.bootstrapcdn.com/bootstrap/4.0.0/css/bootstrap.min.css" integrity="sha384-Gn5384xqQ1aoWXA+058RXPxPg6fy4IWvTNh0E263XmFcJlSAwiGgFAW/dAiS6JXm" crossorigin="anonymous">
+	<title>Login required</title>
+</head>
+<body class="text-center">
+	<h1 class="my-5">Login required!</h1>
+	<p>You need to be logged in to see this page</p>
+	<p>You might want to customize this file by editing <code>static/401</code></p>
+</body>`
+
+	default403Tmpl = `<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="utf-8">
+	<link rel="stylesheet" href="https://maxcdn.bootstrapcdn.com/bootstrap/4.0.0/css/bootstrap.min.css" integrity="sha384-Gn5384xqQ1aoWXA+058RXPxPg6fy4IWvTNh0E263XmFcJlSAwiGgFAW/dAiS6JXm" crossorigin="anonymous">
+	<title>Access denied</title>
+</head>
+<body class="text-center">
+	<h1 class="my-5">Access denied!</h1>
+	<p>You are not allowed to see this page</p>
+	<p>You might want to customize this file by editing <code>static/403</code></p>
+</body>`
+
+	debugPanelTmpl = `<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="utf-8">
+    <title>API2HTML Debugger: {{ Page }}</title>
+</head>
+<body>
+<div class="api2html-debug">
+    <h1>API2HTML Debugger</h1>
+    <h2>Page</h2>
+    <div class="response">
+        <p>Page <strong>{{ Page }}</strong> rendered with the template <strong>{{ Template }}</strong>{{ #Layout }} and the layout <strong>{{ Layout }}</strong>{{ /Layout }}</p>
+        <p>Status <strong>{{ Status }}</strong>, <strong>{{ Size }}</strong> bytes in <strong>{{ TotalTime }}</strong>{{ #RenderTime }} (rendered in <strong>{{ RenderTime }}</strong>){{ /RenderTime }}</p>
+        {{ #Error }}<p>Error: <strong>{{ Error }}</strong></p>{{ /Error }}
+    </div>
+    <h2>Backend calls</h2>
+    <div class="response">
+        {{ #Backends }}
+        <p><strong>{{ URL }}</strong>: {{ #Status }}status {{ Status }}{{ /Status }}{{ #Error }}{{ Error }}{{ /Error }} in {{ Duration }}</p>
+        {{ /Backends }}
+        {{ ^Backends }}
+            <p>The page didn't call any backend.</p>
+        {{ /Backends }}
+    </div>
+    <h2>Response context</h2>
+    <div class="response">
+    <pre>{{ String }}</pre>
+    </div>
+</div>
+<style type="text/css">
+    .api2html-debug {
+        background-color: #f1f1f1;
+        border: 1px solid #666;
+        color: #333;
+        margin:2rem;
+    }
+    .api2html-debug .response {
+        padding: 1em;
+    }
+    .api2html-debug pre, .api2html-debug strong {
+        color: #cb2027;
+        font-family: monospace;
+    }
+
+    .api2html-debug h1 {
+        text-align: center;
+    }
+
+    .api2html-debug h1, .api2html-debug h2, .api2html-debug h3 {
+        margin: 0;
+        background-color: #e0e0e0;
+        color: #cb2027;
+        padding:0.5em;
+    }
+</style>
+</body>
+</html>`
+
 	debuggerTmpl = `<div class="api2html-debug">
     <h1>API2HTML Debugger</h1>
     <p class="response">Page generated at <strong>{{ Helper.Now }}</strong></p>
@@ -0,0 +1,57 @@
+package engine
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// NewTLSConfig returns the TLS config serving the certificate files or the certificates obtained
+// from Let's Encrypt, and the handler of the plain HTTP requests, answering the ACME challenges
+// and redirecting the rest to HTTPS
+func NewTLSConfig(cfg TLS, httpsAddr string) (*tls.Config, http.Handler, error) {
+	redirect := httpsRedirectHandler(httpsAddr)
+	if cfg.Autocert != nil {
+		if len(cfg.Autocert.Hosts) == 0 {
+			return nil, nil, fmt.Errorf("the autocert mode requires at least one host")
+		}
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(cfg.Autocert.Hosts...),
+			Email:      cfg.Autocert.Email,
+		}
+		if cfg.Autocert.CacheDir != "" {
+			m.Cache = autocert.DirCache(cfg.Autocert.CacheDir)
+		}
+		if cfg.Autocert.DirectoryURL != "" {
+			m.Client = &acme.Client{DirectoryURL: cfg.Autocert.DirectoryURL}
+		}
+		return m.TLSConfig(), m.HTTPHandler(redirect), nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, redirect, nil
+}
+
+// httpsRedirectHandler returns a handler redirecting the requests to the same URL served by the
+// HTTPS listener at the received address
+func httpsRedirectHandler(httpsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
@@ -0,0 +1,112 @@
+package engine
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewTLSConfig_certFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := TLS{CertFile: filepath.Join(dir, "cert.pem"), KeyFile: filepath.Join(dir, "key.pem")}
+	if _, _, err := NewTLSConfig(cfg, ":443"); err == nil {
+		t.Error("expecting an error loading unknown files")
+	}
+
+	if err := writeSelfSignedCert(cfg.CertFile, cfg.KeyFile); err != nil {
+		t.Error(err)
+		return
+	}
+	tlsConfig, redirect, err := NewTLSConfig(cfg, ":443")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(tlsConfig.Certificates) != 1 {
+		t.Errorf("unexpected certificates: %d", len(tlsConfig.Certificates))
+	}
+	if redirect == nil {
+		t.Error("the redirect handler is missing")
+	}
+}
+
+func TestNewTLSConfig_autocert(t *testing.T) {
+	if _, _, err := NewTLSConfig(TLS{Autocert: &Autocert{}}, ":443"); err == nil {
+		t.Error("expecting an error without hosts")
+	}
+
+	tlsConfig, redirect, err := NewTLSConfig(TLS{Autocert: &Autocert{Hosts: []string{"example.com"}}}, ":443")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if tlsConfig.GetCertificate == nil {
+		t.Error("the certificates are not obtained from the ACME server")
+	}
+
+	w := httptest.NewRecorder()
+	redirect.ServeHTTP(w, httptest.NewRequest("GET", "http://example.com/a?b=c", nil))
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "https://example.com/a?b=c" {
+		t.Errorf("unexpected redirection: %d %s", w.Code, w.Header().Get("Location"))
+	}
+}
+
+func Test_httpsRedirectHandler(t *testing.T) {
+	for addr, location := range map[string]string{
+		":443":  "https://example.com/a?b=c",
+		":8443": "https://example.com:8443/a?b=c",
+		"":      "https://example.com/a?b=c",
+	} {
+		w := httptest.NewRecorder()
+		httpsRedirectHandler(addr).ServeHTTP(w, httptest.NewRequest("GET", "http://example.com:8080/a?b=c", nil))
+		if w.Code != http.StatusMovedPermanently {
+			t.Errorf("%s: unexpected status code: %d", addr, w.Code)
+		}
+		if l := w.Header().Get("Location"); l != location {
+			t.Errorf("%s: unexpected location: %s", addr, l)
+		}
+	}
+}
+
+func writeSelfSignedCert(certFile, keyFile string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+}
@@ -0,0 +1,81 @@
+package engine
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultTraceHeaders are the W3C Trace Context, B3 and Google Cloud trace headers forwarded
+// to the backends
+var DefaultTraceHeaders = []string{
+	"traceparent",
+	"tracestate",
+	"b3",
+	"X-B3-TraceId",
+	"X-B3-SpanId",
+	"X-B3-ParentSpanId",
+	"X-B3-Sampled",
+	"X-B3-Flags",
+	"X-Cloud-Trace-Context",
+}
+
+// NewTracePropagationInstrumentation returns the instrumentation forwarding the inbound trace
+// headers to the backend requests
+func NewTracePropagationInstrumentation(cfg TracePropagation) Instrumentation {
+	return Instrumentation{Middlewares: []gin.HandlerFunc{HooksMiddleware(NewTracePropagationHooks(cfg.Headers))}}
+}
+
+// NewTracePropagationHooks creates a TracePropagationHooks forwarding the received headers (or
+// the DefaultTraceHeaders if empty)
+func NewTracePropagationHooks(headers []string) *TracePropagationHooks {
+	if len(headers) == 0 {
+		headers = DefaultTraceHeaders
+	}
+	canonical := make([]string, len(headers))
+	for i, h := range headers {
+		canonical[i] = http.CanonicalHeaderKey(h)
+	}
+	return &TracePropagationHooks{headers: canonical}
+}
+
+// TracePropagationHooks is a Hooks implementation copying the trace headers of the inbound
+// request to the backend requests and logging them with the result of every backend call, so
+// the existing distributed traces include this hop without a tracer
+type TracePropagationHooks struct {
+	NoopHooks
+	headers []string
+}
+
+// OnBackendCall implements the Hooks interface by forwarding the trace headers
+func (t *TracePropagationHooks) OnBackendCall(c *gin.Context, req *http.Request) func(*http.Response, error) {
+	if c == nil || c.Request == nil {
+		return func(_ *http.Response, _ error) {}
+	}
+	trace := []string{}
+	for _, h := range t.headers {
+		v := c.Request.Header.Get(h)
+		if v == "" {
+			continue
+		}
+		// the headers already set, like the ones injected by a tracer, take precedence
+		if req.Header.Get(h) == "" {
+			req.Header.Set(h, v)
+		}
+		trace = append(trace, fmt.Sprintf("%s=%s", strings.ToLower(h), req.Header.Get(h)))
+	}
+	if len(trace) == 0 {
+		return func(_ *http.Response, _ error) {}
+	}
+	target := req.URL.String()
+	return func(resp *http.Response, err error) {
+		status := "error"
+		if err == nil && resp != nil {
+			status = fmt.Sprintf("%d", resp.StatusCode)
+		}
+		log.Println("trace:", c.Request.URL.Path, "->", target, status, strings.Join(trace, " "))
+	}
+}
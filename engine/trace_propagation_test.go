@@ -0,0 +1,76 @@
+package engine
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestFactory_New_tracePropagation(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"traceparent":%q,"b3":%q,"cloud":%q}`,
+			r.Header.Get("traceparent"), r.Header.Get("b3"), r.Header.Get("X-Cloud-Trace-Context"))
+	}))
+	defer backend.Close()
+
+	ef := DefaultFactory
+	ef.TemplateFS = fstest.MapFS{
+		"a.mustache": {Data: []byte("{{Data.traceparent}}|{{Data.b3}}|{{Data.cloud}}")},
+	}
+	ef.Parser = func(_ string) (Config, error) {
+		return Config{
+			Pages: []Page{
+				{URLPattern: "/a", Template: "a", BackendURLPattern: backend.URL},
+			},
+			Templates:        map[string]string{"a": "a.mustache"},
+			TracePropagation: &TracePropagation{},
+		}, nil
+	}
+
+	e, err := ef.New("something", false)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	req, _ := http.NewRequest("GET", "/a", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set("X-Cloud-Trace-Context", "105445aa7843bc8bf206b12000100000/1;o=1")
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+
+	expected := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01||105445aa7843bc8bf206b12000100000/1;o=1"
+	if w.Code != http.StatusOK || w.Body.String() != expected {
+		t.Errorf("unexpected response: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestTracePropagationHooks_OnBackendCall(t *testing.T) {
+	hooks := NewTracePropagationHooks([]string{"x-request-trace"})
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request, _ = http.NewRequest("GET", "/a", nil)
+	c.Request.Header.Set("X-Request-Trace", "abc")
+	c.Request.Header.Set("traceparent", "ignored")
+
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	hooks.OnBackendCall(c, req)(&http.Response{StatusCode: http.StatusOK}, nil)
+	if req.Header.Get("X-Request-Trace") != "abc" || req.Header.Get("traceparent") != "" {
+		t.Errorf("unexpected headers: %v", req.Header)
+	}
+
+	req, _ = http.NewRequest("GET", "http://example.com", nil)
+	req.Header.Set("X-Request-Trace", "injected")
+	hooks.OnBackendCall(c, req)(nil, http.ErrHandlerTimeout)
+	if req.Header.Get("X-Request-Trace") != "injected" {
+		t.Errorf("unexpected headers: %v", req.Header)
+	}
+
+	hooks.OnBackendCall(nil, req)(nil, nil)
+}
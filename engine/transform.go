@@ -0,0 +1,304 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmespath/go-jmespath"
+)
+
+const (
+	// RenameTransformation renames the keys of the objects
+	RenameTransformation = "rename"
+	// PickTransformation keeps just the listed keys of the objects
+	PickTransformation = "pick"
+	// OmitTransformation removes the listed keys of the objects
+	OmitTransformation = "omit"
+	// SortTransformation sorts the arrays of objects by the value of a key
+	SortTransformation = "sort"
+	// GroupByTransformation replaces the arrays of objects with the groups of objects with the
+	// same value of a key
+	GroupByTransformation = "group_by"
+	// ComputeTransformation adds the result of a JMESPath expression evaluated against the
+	// objects as a new key
+	ComputeTransformation = "compute"
+)
+
+// TransformFunc transforms a value of the backend data
+type TransformFunc func(interface{}) (interface{}, error)
+
+// NewTransformFunc returns the TransformFunc of the received transformation. It is applied to
+// the value at the path of the transformation
+func NewTransformFunc(cfg Transformation) (TransformFunc, error) {
+	var f TransformFunc
+	switch cfg.Type {
+	case RenameTransformation:
+		f = objectsTransformFunc(func(obj map[string]interface{}) (map[string]interface{}, error) {
+			for from, to := range cfg.Fields {
+				if v, ok := obj[from]; ok {
+					delete(obj, from)
+					obj[to] = v
+				}
+			}
+			return obj, nil
+		})
+	case PickTransformation:
+		f = objectsTransformFunc(func(obj map[string]interface{}) (map[string]interface{}, error) {
+			picked := make(map[string]interface{}, len(cfg.Keys))
+			for _, k := range cfg.Keys {
+				if v, ok := obj[k]; ok {
+					picked[k] = v
+				}
+			}
+			return picked, nil
+		})
+	case OmitTransformation:
+		f = objectsTransformFunc(func(obj map[string]interface{}) (map[string]interface{}, error) {
+			for _, k := range cfg.Keys {
+				delete(obj, k)
+			}
+			return obj, nil
+		})
+	case SortTransformation:
+		if cfg.By == "" {
+			return nil, fmt.Errorf("the sort transformation requires a key")
+		}
+		f = func(v interface{}) (interface{}, error) {
+			array, ok := v.([]interface{})
+			if !ok {
+				return v, nil
+			}
+			sort.SliceStable(array, func(i, j int) bool {
+				a, b := objectValue(array[i], cfg.By), objectValue(array[j], cfg.By)
+				if cfg.Desc {
+					return compareValues(b, a) < 0
+				}
+				return compareValues(a, b) < 0
+			})
+			return array, nil
+		}
+	case GroupByTransformation:
+		if cfg.By == "" {
+			return nil, fmt.Errorf("the group_by transformation requires a key")
+		}
+		f = func(v interface{}) (interface{}, error) {
+			array, ok := v.([]interface{})
+			if !ok {
+				return v, nil
+			}
+			groups := []interface{}{}
+			index := map[string]map[string]interface{}{}
+			for _, item := range array {
+				key := objectValue(item, cfg.By)
+				id := fmt.Sprint(key)
+				group, ok := index[id]
+				if !ok {
+					group = map[string]interface{}{"key": key, "items": []interface{}{}}
+					index[id] = group
+					groups = append(groups, group)
+				}
+				group["items"] = append(group["items"].([]interface{}), item)
+			}
+			return groups, nil
+		}
+	case ComputeTransformation:
+		if cfg.Field == "" {
+			return nil, fmt.Errorf("the compute transformation requires a field")
+		}
+		expr, err := jmespath.Compile(cfg.Expression)
+		if err != nil {
+			return nil, err
+		}
+		f = objectsTransformFunc(func(obj map[string]interface{}) (map[string]interface{}, error) {
+			v, err := expr.Search(floatNumbers(obj))
+			if err != nil {
+				return nil, err
+			}
+			obj[cfg.Field] = v
+			return obj, nil
+		})
+	default:
+		return nil, fmt.Errorf("unknown transformation type: %q", cfg.Type)
+	}
+
+	if cfg.Path == "" {
+		return f, nil
+	}
+	keys := strings.Split(cfg.Path, ".")
+	return func(v interface{}) (interface{}, error) {
+		return transformPath(v, keys, f)
+	}, nil
+}
+
+// withTransformations wraps the ResponseGenerator applying the transformations of the page, in
+// order, to the backend data
+func withTransformations(page Page, rg ResponseGenerator) ResponseGenerator {
+	if len(page.Transform) == 0 {
+		return rg
+	}
+	funcs, err := newTransformFuncs(page.Transform)
+	if err != nil {
+		return func(_ *gin.Context) (ResponseContext, error) {
+			return ResponseContext{}, err
+		}
+	}
+	return func(c *gin.Context) (ResponseContext, error) {
+		result, err := rg(c)
+		if err != nil {
+			return result, err
+		}
+		var data interface{} = result.Data
+		if result.Array != nil {
+			array := make([]interface{}, len(result.Array))
+			for i, item := range result.Array {
+				array[i] = item
+			}
+			data = array
+		}
+		for _, f := range funcs {
+			if data, err = f(data); err != nil {
+				return result, err
+			}
+		}
+		result.Data, result.Array, err = extractedData(data)
+		return result, err
+	}
+}
+
+func newTransformFuncs(transformations []Transformation) ([]TransformFunc, error) {
+	funcs := make([]TransformFunc, len(transformations))
+	for i, t := range transformations {
+		f, err := NewTransformFunc(t)
+		if err != nil {
+			return nil, fmt.Errorf("transformation #%d: %s", i, err.Error())
+		}
+		funcs[i] = f
+	}
+	return funcs, nil
+}
+
+// transformPath replaces the value found at the received path with the result of the
+// TransformFunc. The arrays found along the path are traversed
+func transformPath(v interface{}, keys []string, f TransformFunc) (interface{}, error) {
+	if len(keys) == 0 {
+		return f(v)
+	}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		child, ok := v[keys[0]]
+		if !ok {
+			return v, nil
+		}
+		child, err := transformPath(child, keys[1:], f)
+		if err != nil {
+			return nil, err
+		}
+		v[keys[0]] = child
+	case []interface{}:
+		for i, item := range v {
+			item, err := transformPath(item, keys, f)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = item
+		}
+	}
+	return v, nil
+}
+
+// objectsTransformFunc returns a TransformFunc applying the received function to the object or
+// to every object of the array it receives
+func objectsTransformFunc(f func(map[string]interface{}) (map[string]interface{}, error)) TransformFunc {
+	return func(v interface{}) (interface{}, error) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			return f(v)
+		case []interface{}:
+			for i, item := range v {
+				if obj, ok := item.(map[string]interface{}); ok {
+					obj, err := f(obj)
+					if err != nil {
+						return nil, err
+					}
+					v[i] = obj
+				}
+			}
+		}
+		return v, nil
+	}
+}
+
+func objectValue(v interface{}, key string) interface{} {
+	if obj, ok := v.(map[string]interface{}); ok {
+		return obj[key]
+	}
+	return nil
+}
+
+// compareValues compares the numbers numerically and the rest of the values by their text. The
+// missing values go first
+func compareValues(a, b interface{}) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return -1
+		}
+		return 1
+	}
+	fa, aNumber := numberValue(a)
+	fb, bNumber := numberValue(b)
+	if aNumber && bNumber {
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+func numberValue(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// floatNumbers returns a copy of the value with the json numbers converted into float64, so
+// the JMESPath functions and the scripts can operate with them
+func floatNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			m[k] = floatNumbers(item)
+		}
+		return m
+	case []interface{}:
+		array := make([]interface{}, len(v))
+		for i, item := range v {
+			array[i] = floatNumbers(item)
+		}
+		return array
+	}
+	return v
+}
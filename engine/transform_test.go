@@ -0,0 +1,91 @@
+package engine
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func Test_withTransformations(t *testing.T) {
+	rg := func(_ *gin.Context) (ResponseContext, error) {
+		var data map[string]interface{}
+		decoder := json.NewDecoder(strings.NewReader(`{
+			"title": "products",
+			"internal_id": 42,
+			"items": [
+				{"product_name": "laptop", "category": "computers", "price": 999, "units": 2, "sku": "a"},
+				{"product_name": "phone", "category": "phones", "price": 499, "units": 1, "sku": "b"},
+				{"product_name": "desktop", "category": "computers", "price": 1299, "units": 1, "sku": "c"}
+			]
+		}`))
+		decoder.UseNumber()
+		err := decoder.Decode(&data)
+		return ResponseContext{Data: data}, err
+	}
+	page := Page{Transform: []Transformation{
+		{Type: OmitTransformation, Keys: []string{"internal_id"}},
+		{Type: RenameTransformation, Path: "items", Fields: map[string]string{"product_name": "name"}},
+		{Type: ComputeTransformation, Path: "items", Field: "expensive", Expression: "price > `900`"},
+		{Type: PickTransformation, Path: "items", Keys: []string{"name", "category", "price", "expensive"}},
+		{Type: SortTransformation, Path: "items", By: "price", Desc: true},
+		{Type: GroupByTransformation, Path: "items", By: "category"},
+	}}
+
+	result, err := withTransformations(page, rg)(nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, ok := result.Data["internal_id"]; ok || result.Data["title"] != "products" {
+		t.Errorf("unexpected data: %v", result.Data)
+	}
+	groups, ok := result.Data["items"].([]interface{})
+	if !ok || len(groups) != 2 {
+		t.Errorf("unexpected groups: %v", result.Data["items"])
+		return
+	}
+	computers := groups[0].(map[string]interface{})
+	items := computers["items"].([]interface{})
+	if computers["key"] != "computers" || len(items) != 2 {
+		t.Errorf("unexpected group: %v", computers)
+		return
+	}
+	expected := map[string]interface{}{"name": "desktop", "category": "computers", "price": json.Number("1299"), "expensive": true}
+	if !reflect.DeepEqual(items[0], expected) {
+		t.Errorf("unexpected item: %v", items[0])
+	}
+	if groups[1].(map[string]interface{})["key"] != "phones" {
+		t.Errorf("unexpected group: %v", groups[1])
+	}
+}
+
+func Test_withTransformations_array(t *testing.T) {
+	rg := func(_ *gin.Context) (ResponseContext, error) {
+		return ResponseContext{Array: []map[string]interface{}{{"name": "b"}, {"other": "c"}, {"name": "a"}}}, nil
+	}
+	result, err := withTransformations(Page{Transform: []Transformation{{Type: SortTransformation, By: "name"}}}, rg)(nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(result.Array) != 3 || result.Array[0]["other"] != "c" || result.Array[1]["name"] != "a" {
+		t.Errorf("unexpected array: %v", result.Array)
+	}
+}
+
+func TestNewTransformFunc_ko(t *testing.T) {
+	for _, cfg := range []Transformation{
+		{Type: "unknown"},
+		{Type: SortTransformation},
+		{Type: GroupByTransformation},
+		{Type: ComputeTransformation, Expression: "a"},
+		{Type: ComputeTransformation, Field: "a", Expression: "a.["},
+	} {
+		if _, err := NewTransformFunc(cfg); err == nil {
+			t.Errorf("expecting an error with %v", cfg)
+		}
+	}
+}
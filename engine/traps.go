@@ -0,0 +1,116 @@
+package engine
+
+import (
+	"log"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// denyListMaxEntries is the max number of clients blocked at the same time
+const denyListMaxEntries = 10000
+
+// NewTrapMiddleware creates a middleware tarpitting the requests to the trap paths of the
+// received config and blocking their clients for a while
+func NewTrapMiddleware(cfg TrapRoutes) (gin.HandlerFunc, error) {
+	var tarpit, block time.Duration
+	var err error
+	if cfg.Tarpit != "" {
+		if tarpit, err = time.ParseDuration(cfg.Tarpit); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.BlockDuration != "" {
+		if block, err = time.ParseDuration(cfg.BlockDuration); err != nil {
+			return nil, err
+		}
+	}
+	denied := &denyList{until: map[string]time.Time{}, max: denyListMaxEntries}
+
+	return func(c *gin.Context) {
+		// the engine only takes the client IP from the forwarded headers of the trusted proxies,
+		// so the clients can not get other IPs blocked by forging them
+		ip := c.ClientIP()
+		if denied.contains(ip) {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		if !isTrap(cfg.Paths, c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+
+		log.Println("trap route", c.Request.URL.Path, "requested by", ip)
+		if block > 0 {
+			denied.add(ip, block)
+		}
+		if tarpit > 0 {
+			select {
+			case <-time.After(tarpit):
+			case <-c.Request.Context().Done():
+			}
+		}
+		c.AbortWithStatus(http.StatusNotFound)
+	}, nil
+}
+
+func isTrap(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if pattern == name {
+			return true
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// denyList contains the blocked clients and the expiration of their blocks, up to max clients
+type denyList struct {
+	mutex sync.Mutex
+	until map[string]time.Time
+	max   int
+}
+
+// add blocks the client. Once the list is full, the expired blocks are purged and, if there are
+// none, the block expiring first is dropped, so the clients rotating their IPs can not exhaust
+// the memory
+func (d *denyList) add(ip string, duration time.Duration) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	now := time.Now()
+	if _, ok := d.until[ip]; !ok && len(d.until) >= d.max {
+		var first string
+		for k, until := range d.until {
+			if now.After(until) {
+				delete(d.until, k)
+				continue
+			}
+			if first == "" || until.Before(d.until[first]) {
+				first = k
+			}
+		}
+		if len(d.until) >= d.max {
+			delete(d.until, first)
+		}
+	}
+	d.until[ip] = now.Add(duration)
+}
+
+func (d *denyList) contains(ip string) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	until, ok := d.until[ip]
+	if !ok {
+		return false
+	}
+	if time.Now().After(until) {
+		delete(d.until, ip)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,84 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNewTrapMiddleware(t *testing.T) {
+	for _, cfg := range []TrapRoutes{{Tarpit: "nope"}, {BlockDuration: "nope"}} {
+		if _, err := NewTrapMiddleware(cfg); err == nil {
+			t.Errorf("expecting an error with %v", cfg)
+		}
+	}
+
+	trap, err := NewTrapMiddleware(TrapRoutes{
+		Paths:         []string{"/wp-login.php", "/*.env"},
+		Tarpit:        "20ms",
+		BlockDuration: "100ms",
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	e := gin.New()
+	e.Use(trap)
+	e.GET("/", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+
+	request := func(ip, url string) int {
+		req, _ := http.NewRequest("GET", url, nil)
+		req.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if status := request("10.0.0.1", "/"); status != http.StatusOK {
+		t.Errorf("unexpected status code: %d", status)
+	}
+	for i, url := range []string{"/wp-login.php", "/.env"} {
+		ip := []string{"10.0.0.1", "10.0.0.2"}[i]
+		start := time.Now()
+		if status := request(ip, url); status != http.StatusNotFound {
+			t.Errorf("%s: unexpected status code: %d", url, status)
+		}
+		if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+			t.Errorf("%s: the request was not tarpitted: %s", url, elapsed)
+		}
+	}
+
+	if status := request("10.0.0.1", "/"); status != http.StatusForbidden {
+		t.Errorf("unexpected status code for a blocked client: %d", status)
+	}
+	if status := request("10.0.0.3", "/"); status != http.StatusOK {
+		t.Errorf("unexpected status code: %d", status)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if status := request("10.0.0.1", "/"); status != http.StatusOK {
+		t.Errorf("unexpected status code after the block: %d", status)
+	}
+}
+
+func TestDenyList_max(t *testing.T) {
+	denied := &denyList{until: map[string]time.Time{}, max: 2}
+	denied.add("10.0.0.1", time.Millisecond)
+	denied.add("10.0.0.2", time.Minute)
+	time.Sleep(5 * time.Millisecond)
+
+	// the expired blocks are purged first
+	denied.add("10.0.0.3", 2*time.Minute)
+	if len(denied.until) != 2 || !denied.contains("10.0.0.2") || !denied.contains("10.0.0.3") {
+		t.Errorf("unexpected blocks: %v", denied.until)
+	}
+
+	// then, the block expiring first is dropped
+	denied.add("10.0.0.4", time.Minute)
+	if len(denied.until) != 2 || denied.contains("10.0.0.2") || !denied.contains("10.0.0.3") || !denied.contains("10.0.0.4") {
+		t.Errorf("unexpected blocks: %v", denied.until)
+	}
+}
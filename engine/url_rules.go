@@ -0,0 +1,197 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rewrittenKey marks the context of the requests already rewritten, so they are not rewritten
+// again when they are routed to their new path
+type rewrittenKey struct{}
+
+// urlRule is a redirect or a rewrite rule with its source pattern split into segments
+type urlRule struct {
+	route    RoutePattern
+	segments []string
+	target   string
+}
+
+func newURLRule(source, target string) (urlRule, error) {
+	if !strings.HasPrefix(source, "/") {
+		return urlRule{}, fmt.Errorf("the source %q must start with /", source)
+	}
+	if target == "" {
+		return urlRule{}, fmt.Errorf("empty target")
+	}
+	route, err := ParseRoutePattern(source)
+	if err != nil {
+		return urlRule{}, err
+	}
+	return urlRule{route: route, segments: strings.Split(route.Path, "/"), target: target}, nil
+}
+
+// match returns the params of the path if it matches the source pattern of the rule
+func (r urlRule) match(path string) (map[string]string, bool) {
+	parts := strings.Split(path, "/")
+	params := map[string]string{}
+	for i, segment := range r.segments {
+		if i >= len(parts) {
+			return nil, false
+		}
+		switch {
+		case strings.HasPrefix(segment, "*"):
+			// like the pages, the catch-all params declared with braces skip the leading slash
+			value := "/" + strings.Join(parts[i:], "/")
+			if segment[1:] == r.route.CatchAll {
+				value = value[1:]
+			}
+			params[segment[1:]] = value
+			return r.constrained(params)
+		case strings.HasPrefix(segment, ":"):
+			if parts[i] == "" {
+				return nil, false
+			}
+			params[segment[1:]] = parts[i]
+		case segment != parts[i]:
+			return nil, false
+		}
+	}
+	if len(parts) != len(r.segments) {
+		return nil, false
+	}
+	return r.constrained(params)
+}
+
+// constrained checks the params against the constraints of the source pattern, adding the
+// named groups of their regular expressions
+func (r urlRule) constrained(params map[string]string) (map[string]string, bool) {
+	for name, re := range r.route.Constraints {
+		m := re.FindStringSubmatch(params[name])
+		if m == nil {
+			return nil, false
+		}
+		for i, group := range re.SubexpNames() {
+			if group != "" {
+				params[group] = m[i]
+			}
+		}
+	}
+	return params, true
+}
+
+// location returns the target of the rule with the params replaced
+func (r urlRule) location(params map[string]string) string {
+	return string(replaceParams([]byte(r.target), params))
+}
+
+// NewURLRedirects returns a middleware redirecting the requests matching the first of the
+// rules to its target. It returns nil if there are no rules
+func NewURLRedirects(redirects []URLRedirect) (gin.HandlerFunc, error) {
+	if len(redirects) == 0 {
+		return nil, nil
+	}
+	type redirect struct {
+		urlRule
+		status        int
+		preserveQuery bool
+	}
+	rules := make([]redirect, len(redirects))
+	for i, r := range redirects {
+		rule, err := newURLRule(r.Source, r.Target)
+		if err != nil {
+			return nil, fmt.Errorf("redirect #%d: %s", i, err.Error())
+		}
+		status := r.Status
+		switch status {
+		case 0:
+			status = http.StatusMovedPermanently
+		case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+			http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		default:
+			return nil, fmt.Errorf("redirect #%d: invalid status %d", i, status)
+		}
+		rules[i] = redirect{rule, status, r.PreserveQuery}
+	}
+
+	return func(c *gin.Context) {
+		for _, rule := range rules {
+			params, ok := rule.match(c.Request.URL.Path)
+			if !ok {
+				continue
+			}
+			location := rule.location(params)
+			if rule.preserveQuery && c.Request.URL.RawQuery != "" {
+				if strings.Contains(location, "?") {
+					location += "&" + c.Request.URL.RawQuery
+				} else {
+					location += "?" + c.Request.URL.RawQuery
+				}
+			}
+			c.Redirect(rule.status, location)
+			c.Abort()
+			return
+		}
+	}, nil
+}
+
+// URLRewriter routes the requests matching its rules to their target path
+type URLRewriter struct {
+	rules []urlRule
+}
+
+// NewURLRewriter returns a URLRewriter with the received rules. It returns nil if there are no
+// rules
+func NewURLRewriter(rewrites []URLRewrite) (*URLRewriter, error) {
+	if len(rewrites) == 0 {
+		return nil, nil
+	}
+	rules := make([]urlRule, len(rewrites))
+	for i, r := range rewrites {
+		rule, err := newURLRule(r.Source, r.Target)
+		if err != nil {
+			return nil, fmt.Errorf("rewrite #%d: %s", i, err.Error())
+		}
+		if !strings.HasPrefix(rule.target, "/") {
+			return nil, fmt.Errorf("rewrite #%d: the target %q must be a path", i, rule.target)
+		}
+		rules[i] = rule
+	}
+	return &URLRewriter{rules: rules}, nil
+}
+
+// HandlerFunc returns a middleware changing the path of the requests matching the first of the
+// rules and routing them again with the engine. It must be the first middleware of the engine,
+// so the rest of them run just once, for the rewritten request
+func (rw *URLRewriter) HandlerFunc(e *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Context().Value(rewrittenKey{}) != nil {
+			return
+		}
+		for _, rule := range rw.rules {
+			params, ok := rule.match(c.Request.URL.Path)
+			if !ok {
+				continue
+			}
+			path, query := rule.location(params), ""
+			if i := strings.Index(path, "?"); i >= 0 {
+				path, query = path[:i], path[i+1:]
+			}
+			u := *c.Request.URL
+			u.Path, u.RawPath = path, ""
+			if query != "" && u.RawQuery != "" {
+				u.RawQuery = query + "&" + u.RawQuery
+			} else if query != "" {
+				u.RawQuery = query
+			}
+			c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), rewrittenKey{}, true))
+			c.Request.URL = &u
+			e.HandleContext(c)
+			c.Abort()
+			return
+		}
+	}
+}
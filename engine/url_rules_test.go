@@ -0,0 +1,147 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestURLRule_match(t *testing.T) {
+	for _, tc := range []struct {
+		source, path string
+		params       map[string]string
+	}{
+		{"/old", "/old", map[string]string{}},
+		{"/old", "/old/", nil},
+		{"/old", "/new", nil},
+		{"/blog/:year/:slug", "/blog/2019/hello", map[string]string{"year": "2019", "slug": "hello"}},
+		{"/blog/:year/:slug", "/blog/2019", nil},
+		{"/blog/:year/:slug", "/blog//hello", nil},
+		{"/blog/:year/:slug", "/blog/2019/hello/world", nil},
+		{"/blog/{year:\\d{4}}/:slug", "/blog/2019/hello", map[string]string{"year": "2019", "slug": "hello"}},
+		{"/blog/{year:\\d{4}}/:slug", "/blog/latest/hello", nil},
+		{"/p/{id:(?P<sku>[a-z]+)-\\d+}", "/p/abc-1", map[string]string{"id": "abc-1", "sku": "abc"}},
+		{"/docs/*path", "/docs/a/b", map[string]string{"path": "/a/b"}},
+		{"/docs/{path:*}", "/docs/a/b", map[string]string{"path": "a/b"}},
+		{"/docs/{path:*}", "/docs/", map[string]string{"path": ""}},
+		{"/docs/{path:*}", "/docs", nil},
+	} {
+		rule, err := newURLRule(tc.source, "/target")
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tc.source, err.Error())
+			continue
+		}
+		params, ok := rule.match(tc.path)
+		if ok != (tc.params != nil) {
+			t.Errorf("%s %s: unexpected match: %v", tc.source, tc.path, ok)
+			continue
+		}
+		if len(params) != len(tc.params) {
+			t.Errorf("%s %s: unexpected params: %v", tc.source, tc.path, params)
+			continue
+		}
+		for k, v := range tc.params {
+			if params[k] != v {
+				t.Errorf("%s %s: unexpected param %s: %s", tc.source, tc.path, k, params[k])
+			}
+		}
+	}
+}
+
+func TestNewURLRedirects(t *testing.T) {
+	if h, err := NewURLRedirects(nil); h != nil || err != nil {
+		t.Error("unexpected middleware")
+	}
+	for _, redirects := range [][]URLRedirect{
+		{{Source: "old", Target: "/new"}},
+		{{Source: "/old", Target: ""}},
+		{{Source: "/old/{id", Target: "/new"}},
+		{{Source: "/old", Target: "/new", Status: http.StatusOK}},
+	} {
+		if _, err := NewURLRedirects(redirects); err == nil {
+			t.Errorf("%+v: expecting an error", redirects)
+		}
+	}
+
+	h, err := NewURLRedirects([]URLRedirect{
+		{Source: "/blog/:year/:slug", Target: "/articles/:slug", PreserveQuery: true},
+		{Source: "/docs/{path:*}", Target: "https://docs.example.com/:path", Status: http.StatusFound},
+		{Source: "/search", Target: "/find?source=legacy", PreserveQuery: true},
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(h)
+	e.GET("/articles/:slug", func(c *gin.Context) { c.String(http.StatusOK, c.Param("slug")) })
+
+	for _, tc := range []struct {
+		url      string
+		status   int
+		location string
+	}{
+		{"/blog/2019/hello?page=2", http.StatusMovedPermanently, "/articles/hello?page=2"},
+		{"/blog/2019/hello", http.StatusMovedPermanently, "/articles/hello"},
+		{"/docs/guides/install?v=1", http.StatusFound, "https://docs.example.com/guides/install"},
+		{"/search?q=shoes", http.StatusMovedPermanently, "/find?source=legacy&q=shoes"},
+		{"/articles/hello", http.StatusOK, ""},
+	} {
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, httptest.NewRequest("GET", tc.url, nil))
+		if w.Code != tc.status || w.Header().Get("Location") != tc.location {
+			t.Errorf("%s: unexpected response: %d %s", tc.url, w.Code, w.Header().Get("Location"))
+		}
+	}
+}
+
+func TestURLRewriter(t *testing.T) {
+	if rw, err := NewURLRewriter(nil); rw != nil || err != nil {
+		t.Error("unexpected rewriter")
+	}
+	if _, err := NewURLRewriter([]URLRewrite{{Source: "/old", Target: "https://example.com/new"}}); err == nil {
+		t.Error("expecting an error with an absolute target")
+	}
+
+	rw, err := NewURLRewriter([]URLRewrite{
+		{Source: "/blog/:year/:slug", Target: "/articles/:slug"},
+		{Source: "/category/:name", Target: "/search?category=:name"},
+		{Source: "/loop", Target: "/loop"},
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	calls := 0
+	e.Use(rw.HandlerFunc(e), func(_ *gin.Context) { calls++ })
+	e.GET("/articles/:slug", func(c *gin.Context) { c.String(http.StatusOK, "article "+c.Param("slug")) })
+	e.GET("/search", func(c *gin.Context) { c.String(http.StatusOK, c.Request.URL.RawQuery) })
+	e.GET("/loop", func(c *gin.Context) { c.String(http.StatusOK, "loop") })
+
+	for _, tc := range []struct {
+		url    string
+		status int
+		body   string
+	}{
+		{"/blog/2019/hello", http.StatusOK, "article hello"},
+		{"/articles/hello", http.StatusOK, "article hello"},
+		{"/category/shoes?page=2", http.StatusOK, "category=shoes&page=2"},
+		{"/loop", http.StatusOK, "loop"},
+		{"/unknown", http.StatusNotFound, "404 page not found"},
+	} {
+		calls = 0
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, httptest.NewRequest("GET", tc.url, nil))
+		if w.Code != tc.status || w.Body.String() != tc.body {
+			t.Errorf("%s: unexpected response: %d %s", tc.url, w.Code, w.Body.String())
+		}
+		if calls != 1 {
+			t.Errorf("%s: the middlewares ran %d times", tc.url, calls)
+		}
+	}
+}
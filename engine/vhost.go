@@ -0,0 +1,117 @@
+package engine
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const hostRouteKey = "api2html-host-route"
+
+// hostRoute is the handler chain of a page and the host it is restricted to, if any
+type hostRoute struct {
+	host     string
+	handlers gin.HandlersChain
+}
+
+// hostRoutes groups the handler chains of the pages by URL pattern, so the pages of different
+// hosts can share the same one
+type hostRoutes struct {
+	paths  []string
+	routes map[string][]hostRoute
+}
+
+func newHostRoutes() *hostRoutes {
+	return &hostRoutes{routes: map[string][]hostRoute{}}
+}
+
+func (r *hostRoutes) add(path, host string, handlers ...gin.HandlerFunc) {
+	if _, ok := r.routes[path]; !ok {
+		r.paths = append(r.paths, path)
+	}
+	r.routes[path] = append(r.routes[path], hostRoute{strings.ToLower(host), handlers})
+}
+
+// register mounts a route for every URL pattern into the engine. The handlers of the patterns
+// shared by several hosts are chained, each one running just for the requests of its host, and
+// the requests of the hosts without a page get the not found handler
+func (r *hostRoutes) register(e *gin.Engine, notFound gin.HandlerFunc) {
+	for _, path := range r.paths {
+		routes := r.routes[path]
+		if len(routes) == 1 && routes[0].host == "" {
+			e.GET(path, routes[0].handlers...)
+			continue
+		}
+		e.GET(path, hostDispatchChain(routes, notFound)...)
+	}
+}
+
+// hostDispatchChain merges the chains of the routes into a single one. The first handler
+// selects the route of the request host, and the rest of them are skipped unless they belong
+// to it, so the middlewares calling c.Next() still wrap the handler of their page
+func hostDispatchChain(routes []hostRoute, notFound gin.HandlerFunc) gin.HandlersChain {
+	if notFound == nil {
+		notFound = func(c *gin.Context) { c.AbortWithStatus(http.StatusNotFound) }
+	}
+	hosts := []string{}
+	hasDefault := false
+	for _, route := range routes {
+		if route.host == "" {
+			hasDefault = true
+			continue
+		}
+		hosts = append(hosts, route.host)
+	}
+
+	chain := gin.HandlersChain{func(c *gin.Context) {
+		c.Set(hostRouteKey, matchHost(hosts, requestHost(c.Request)))
+	}}
+	for _, route := range routes {
+		host := route.host
+		for _, h := range route.handlers {
+			h := h
+			chain = append(chain, func(c *gin.Context) {
+				if c.GetString(hostRouteKey) == host {
+					h(c)
+				}
+			})
+		}
+	}
+	if !hasDefault {
+		chain = append(chain, func(c *gin.Context) {
+			if c.GetString(hostRouteKey) == "" {
+				// the not found handlers keep the status of the special handlers of gin
+				c.Status(http.StatusNotFound)
+				notFound(c)
+			}
+		})
+	}
+	return chain
+}
+
+// matchHost returns the host pattern matching the host: the same host or, if there is none,
+// the wildcard (*.example.com) with the longest domain. It returns an empty string if none of
+// them matches
+func matchHost(patterns []string, host string) string {
+	match := ""
+	for _, pattern := range patterns {
+		if pattern == host {
+			return pattern
+		}
+		if strings.HasPrefix(pattern, "*.") && strings.HasSuffix(host, pattern[1:]) && len(pattern) > len(match) {
+			match = pattern
+		}
+	}
+	return match
+}
+
+// requestHost returns the host of the request in lower case, without the port
+func requestHost(r *http.Request) string {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(host)
+}
@@ -0,0 +1,76 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestHostRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	page := func(body string) gin.HandlerFunc {
+		return func(c *gin.Context) { c.String(http.StatusOK, body) }
+	}
+	// the middleware wraps the handler of its page
+	wrapper := func(c *gin.Context) {
+		c.Header("X-Before", "yes")
+		c.Next()
+		if c.Writer.Written() {
+			c.Writer.WriteString(" (wrapped)")
+		}
+	}
+	routes := newHostRoutes()
+	routes.add("/", "", page("home"))
+	routes.add("/", "Blog.example.com", wrapper, page("blog"))
+	routes.add("/", "*.example.com", page("subdomain"))
+	routes.add("/products/:id", "shop.example.com", func(c *gin.Context) { c.String(http.StatusOK, "product "+c.Param("id")) })
+	routes.add("/about", "", page("about"))
+	routes.register(e, func(c *gin.Context) { c.String(http.StatusNotFound, "not found") })
+
+	for _, tc := range []struct {
+		host, path string
+		status     int
+		body       string
+	}{
+		{"example.com", "/", http.StatusOK, "home"},
+		{"blog.example.com:8080", "/", http.StatusOK, "blog (wrapped)"},
+		{"BLOG.example.com", "/", http.StatusOK, "blog (wrapped)"},
+		{"www.example.com", "/", http.StatusOK, "subdomain"},
+		{"www.example.org", "/", http.StatusOK, "home"},
+		{"shop.example.com", "/products/42", http.StatusOK, "product 42"},
+		{"example.com", "/products/42", http.StatusNotFound, "not found"},
+		{"shop.example.com", "/about", http.StatusOK, "about"},
+	} {
+		req := httptest.NewRequest("GET", tc.path, nil)
+		req.Host = tc.host
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		if w.Code != tc.status || w.Body.String() != tc.body {
+			t.Errorf("%s%s: unexpected response: %d %s", tc.host, tc.path, w.Code, w.Body.String())
+		}
+		if wrapped := w.Header().Get("X-Before") == "yes"; wrapped != (tc.body == "blog (wrapped)") {
+			t.Errorf("%s%s: unexpected middleware execution", tc.host, tc.path)
+		}
+	}
+}
+
+func Test_matchHost(t *testing.T) {
+	patterns := []string{"*.example.com", "www.example.com", "*.shop.example.com"}
+	for host, expected := range map[string]string{
+		"www.example.com":      "www.example.com",
+		"blog.example.com":     "*.example.com",
+		"eu.shop.example.com":  "*.shop.example.com",
+		"example.com":          "",
+		"www.example.org":      "",
+		"notexample.com":       "",
+		"www.notexample.com":   "",
+		"www.example.com.evil": "",
+	} {
+		if match := matchHost(patterns, host); match != expected {
+			t.Errorf("%s: unexpected match %q", host, match)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package engine
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultWarmupConcurrency = 4
+	defaultWarmupTimeout     = time.Minute
+	warmupAttempts           = 3
+	warmupRetryDelay         = 100 * time.Millisecond
+)
+
+// NewCacheWarmer creates a CacheWarmer requesting the URLs of the config and, if enabled, the ones
+// of the pages without params to the received handler
+func NewCacheWarmer(cfg Warmup, pages []Page, h http.Handler, store *TemplateStore) *CacheWarmer {
+	urls := append([]string{}, cfg.URLs...)
+	if cfg.StaticPages {
+		for _, page := range pages {
+			if !strings.ContainsAny(page.URLPattern, ":*") {
+				urls = append(urls, page.URLPattern)
+			}
+		}
+	}
+	concurrency := cfg.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultWarmupConcurrency
+	}
+	timeout, err := time.ParseDuration(cfg.Timeout)
+	if err != nil || timeout <= 0 {
+		timeout = defaultWarmupTimeout
+	}
+	return &CacheWarmer{
+		URLs:        urls,
+		Headers:     cfg.Headers,
+		Handler:     h,
+		Concurrency: concurrency,
+		Timeout:     timeout,
+		templates:   &HealthChecker{Pages: pages, Store: store},
+	}
+}
+
+// CacheWarmer renders a list of URLs before the instance reports itself as ready, so the backend
+// responses and the rendered pages are already cached when the first users arrive. The requests
+// are dispatched to the Handler once all the templates of the pages are published, retrying the
+// failed ones
+type CacheWarmer struct {
+	URLs []string
+	// Headers are added to every request
+	Headers     map[string]string
+	Handler     http.Handler
+	Concurrency int
+	// Timeout is the max duration of the warm up. Once exceeded, the instance reports itself as
+	// ready even if some URLs are not rendered yet
+	Timeout   time.Duration
+	templates *HealthChecker
+	done      int32
+}
+
+// Done returns true once the warm up has finished or timed out
+func (w *CacheWarmer) Done() bool {
+	return atomic.LoadInt32(&w.done) == 1
+}
+
+// Run renders the URLs, returning once all of them are rendered or the timeout is exceeded
+func (w *CacheWarmer) Run() {
+	defer atomic.StoreInt32(&w.done, 1)
+	start := time.Now()
+	deadline := start.Add(w.Timeout)
+	for len(w.templates.missingTemplates()) > 0 {
+		if time.Now().After(deadline) {
+			log.Println("warm up: timed out waiting for the templates")
+			return
+		}
+		time.Sleep(warmupRetryDelay)
+	}
+
+	urls := make(chan string)
+	var failed int32
+	var wg sync.WaitGroup
+	for i := 0; i < w.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for u := range urls {
+				if !w.warm(u, deadline) {
+					atomic.AddInt32(&failed, 1)
+				}
+			}
+		}()
+	}
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	finished := make(chan struct{})
+	go func() {
+		for _, u := range w.URLs {
+			urls <- u
+		}
+		close(urls)
+		wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		log.Println("warm up:", len(w.URLs), "urls rendered in", time.Since(start), "with", atomic.LoadInt32(&failed), "failures")
+	case <-timer.C:
+		log.Println("warm up: timed out after", w.Timeout)
+	}
+}
+
+// warm requests the URL until it is rendered, the attempts are exhausted or the deadline is
+// exceeded. It returns false if the URL could not be rendered
+func (w *CacheWarmer) warm(u string, deadline time.Time) bool {
+	for attempt := 1; ; attempt++ {
+		req, err := http.NewRequest(http.MethodGet, u, nil)
+		if err != nil {
+			log.Println("warm up:", err.Error())
+			return false
+		}
+		for k, v := range w.Headers {
+			req.Header.Set(k, v)
+		}
+		rec := &fragmentWriter{header: http.Header{}, status: http.StatusOK}
+		w.Handler.ServeHTTP(rec, req)
+		if rec.status < http.StatusInternalServerError {
+			return true
+		}
+		if attempt == warmupAttempts || time.Now().Add(warmupRetryDelay).After(deadline) {
+			log.Println("warm up:", u, "failed with the status code", rec.status)
+			return false
+		}
+		time.Sleep(warmupRetryDelay)
+	}
+}
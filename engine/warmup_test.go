@@ -0,0 +1,82 @@
+package engine
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCacheWarmer(t *testing.T) {
+	var mutex sync.Mutex
+	requests := map[string]int{}
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.GET("/*path", func(c *gin.Context) {
+		mutex.Lock()
+		requests[c.Request.URL.RequestURI()]++
+		n := requests[c.Request.URL.RequestURI()]
+		mutex.Unlock()
+		if c.Request.URL.Path == "/flaky" && n == 1 {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		if c.GetHeader("X-Warmup") != "true" {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		c.String(http.StatusOK, "ok")
+	})
+
+	store := NewTemplateStore()
+	pages := []Page{
+		{Name: "home", URLPattern: "/", Template: "home"},
+		{Name: "product", URLPattern: "/products/:id", Template: "product"},
+	}
+	w := NewCacheWarmer(Warmup{
+		URLs:        []string{"/flaky", "/products/42?color=red"},
+		StaticPages: true,
+		Headers:     map[string]string{"X-Warmup": "true"},
+	}, pages, e, store)
+	checker := &HealthChecker{Pages: pages, Store: store, Warmer: w}
+
+	done := make(chan struct{})
+	go func() {
+		w.Run()
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if report := checker.Check(); report.Status != statusNotReady || !report.Warming {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	mutex.Lock()
+	if len(requests) != 0 {
+		t.Errorf("unexpected requests before publishing the templates: %v", requests)
+	}
+	mutex.Unlock()
+
+	store.Set("home", EmptyRenderer)
+	store.Set("product", EmptyRenderer)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the warm up did not finish")
+	}
+
+	if report := checker.Check(); report.Status != statusReady || report.Warming {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	if requests["/flaky"] != 2 || requests["/products/42?color=red"] != 1 || requests["/"] != 1 || len(requests) != 3 {
+		t.Errorf("unexpected requests: %v", requests)
+	}
+}
+
+func TestCacheWarmer_timeout(t *testing.T) {
+	w := NewCacheWarmer(Warmup{URLs: []string{"/"}, Timeout: "50ms"}, []Page{{Name: "home", URLPattern: "/", Template: "home"}}, gin.New(), NewTemplateStore())
+	w.Run()
+	if !w.Done() {
+		t.Error("the warm up is not done")
+	}
+}
@@ -0,0 +1,57 @@
+package engine
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"mime"
+	"path"
+	"strings"
+)
+
+var wellKnownContentTypes = map[string]string{
+	".txt":         "text/plain; charset=utf-8",
+	".json":        "application/json",
+	".webmanifest": "application/manifest+json",
+}
+
+// NewWellKnownHandler creates a StaticHandler serving the well-known file defined by the received
+// config, rendered with the received extra values. The template is read from the fs.FS or from
+// the local filesystem if the fs.FS is nil
+func NewWellKnownHandler(fsys fs.FS, cfg WellKnown, extra map[string]interface{}) (StaticHandler, error) {
+	return newWellKnownHandler(fsys, cfg, extra, nil)
+}
+
+// newWellKnownHandler creates the StaticHandler of the well-known file, rendering its template
+// with the helpers of the registry
+func newWellKnownHandler(fsys fs.FS, cfg WellKnown, extra map[string]interface{}, reg *registry) (StaticHandler, error) {
+	var src io.Reader = strings.NewReader(cfg.Content)
+	if cfg.Template != "" {
+		f, err := openFile(fsys, cfg.Template)
+		if err != nil {
+			return StaticHandler{}, err
+		}
+		defer f.Close()
+		src = f
+	}
+
+	r, err := newMustacheRenderer(fsys, src, reg)
+	if err != nil {
+		return StaticHandler{}, err
+	}
+	buf := &bytes.Buffer{}
+	if err := r.Render(buf, ResponseContext{Extra: extra}); err != nil {
+		return StaticHandler{}, err
+	}
+
+	contentType := cfg.ContentType
+	if contentType == "" {
+		ext := path.Ext(cfg.Path)
+		if contentType = wellKnownContentTypes[ext]; contentType == "" {
+			contentType = mime.TypeByExtension(ext)
+		}
+	}
+
+	content := buf.Bytes()
+	return StaticHandler{Content: content, ETag: contentETag(content), ContentType: contentType}, nil
+}
@@ -0,0 +1,97 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestNewWellKnownHandler(t *testing.T) {
+	fsys := fstest.MapFS{
+		"wk/security.txt": {Data: []byte("Contact: {{Extra.contact}}\nExpires: {{Extra.expires}}\n")},
+	}
+	extra := map[string]interface{}{"contact": "mailto:security@example.com", "expires": "2030-01-01T00:00:00.000Z", "name": "example"}
+
+	for _, tc := range []struct {
+		cfg         WellKnown
+		content     string
+		contentType string
+	}{
+		{
+			cfg:         WellKnown{Path: "/.well-known/security.txt", Template: "wk/security.txt"},
+			content:     "Contact: mailto:security@example.com\nExpires: 2030-01-01T00:00:00.000Z\n",
+			contentType: "text/plain; charset=utf-8",
+		},
+		{
+			cfg:         WellKnown{Path: "/site.webmanifest", Content: `{"name":"{{Extra.name}}"}`},
+			content:     `{"name":"example"}`,
+			contentType: "application/manifest+json",
+		},
+		{
+			cfg:         WellKnown{Path: "/humans", Content: "by {{Extra.name}}", ContentType: "text/plain"},
+			content:     "by example",
+			contentType: "text/plain",
+		},
+	} {
+		h, err := NewWellKnownHandler(fsys, tc.cfg, extra)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tc.cfg.Path, err.Error())
+			continue
+		}
+		if string(h.Content) != tc.content {
+			t.Errorf("%s: unexpected content: %s", tc.cfg.Path, string(h.Content))
+		}
+		if h.ContentType != tc.contentType {
+			t.Errorf("%s: unexpected content type: %s", tc.cfg.Path, h.ContentType)
+		}
+	}
+}
+
+func TestNewWellKnownHandler_ko(t *testing.T) {
+	if _, err := NewWellKnownHandler(fstest.MapFS{}, WellKnown{Path: "/humans.txt", Template: "unknown"}, nil); err == nil {
+		t.Error("error expected")
+	}
+	if _, err := NewWellKnownHandler(nil, WellKnown{Path: "/humans.txt", Content: "{{#unclosed}}"}, nil); err == nil {
+		t.Error("error expected")
+	}
+}
+
+func TestFactory_New_wellKnown(t *testing.T) {
+	ef := DefaultFactory
+	ef.Parser = func(_ string) (Config, error) {
+		return Config{
+			Extra: map[string]interface{}{"team": "devops"},
+			WellKnown: []WellKnown{
+				{Path: "/humans.txt", Content: "Team: {{Extra.team}}"},
+			},
+		}, nil
+	}
+
+	e, err := ef.New("something", false)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	assertResponse(t, e, "/humans.txt", http.StatusOK, "Team: devops")
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/humans.txt", nil)
+	e.ServeHTTP(w, req)
+	if contentType := w.Header().Get("Content-Type"); contentType != "text/plain; charset=utf-8" {
+		t.Errorf("unexpected content type: %s", contentType)
+	}
+}
+
+func TestFactory_New_koWellKnown(t *testing.T) {
+	ef := DefaultFactory
+	ef.Parser = func(_ string) (Config, error) {
+		return Config{WellKnown: []WellKnown{{Path: "/humans.txt", Template: "unknown_file_not_present_in_the_fs"}}}, nil
+	}
+	if _, err := ef.New("something", false); err == nil {
+		t.Error("error expected")
+	}
+}
@@ -8,7 +8,7 @@ import (
 	"sort"
 	"strings"
 
-	"github.com/Unknwon/goconfig"
+	"github.com/unknwon/goconfig"
 )
 
 // Collector defines the interface for collecting config and translation data
@@ -29,7 +29,7 @@ type Data struct {
 func (d Data) String() string {
 	b, err := json.Marshal(d)
 	if err != nil {
-		log.Printf(err.Error())
+		log.Print(err.Error())
 		return ""
 	}
 	return string(b)
@@ -40,7 +40,7 @@ type Map map[string]string
 func (d Map) String() string {
 	b, err := json.Marshal(d)
 	if err != nil {
-		log.Printf(err.Error())
+		log.Print(err.Error())
 		return ""
 	}
 	return string(b)
@@ -0,0 +1,234 @@
+package skeleton
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var pageNameRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+const (
+	siteConfig = `{
+  "pages": [
+    {
+      "name": "home",
+      "URLPattern": "/",
+      "BackendURLPattern": "https://jsonplaceholder.typicode.com/posts",
+      "Template": "home",
+      "Layout": "main",
+      "CacheTTL": "3600s",
+      "IsArray": true
+    }
+  ],
+  "templates": {"home": "tmpl/home.mustache"},
+  "layouts": {"main": "tmpl/main_layout.mustache"},
+  "extra": {
+    "lang": "en-US",
+    "site_name": "My api2html site"
+  }
+}
+`
+
+	siteLayout = `<!DOCTYPE html>
+<html lang="{{ Extra.lang }}">
+<head>
+	<meta charset="utf-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+	<title>{{ Extra.site_name }}</title>
+</head>
+<body>
+	<header><a href="/">{{ Extra.site_name }}</a></header>
+	<main>
+		{{{ content }}}
+	</main>
+</body>
+</html>
+`
+
+	siteHome = `<h1>Latest posts</h1>
+{{#Array}}
+<article>
+	<h2>{{ title }}</h2>
+	<p>{{ body }}</p>
+</article>
+{{/Array}}
+`
+
+	siteFixture = `[
+  {"userId": 1, "id": 1, "title": "First post", "body": "The content of the first post"},
+  {"userId": 1, "id": 2, "title": "Second post", "body": "The content of the second post"}
+]
+`
+
+	pageTemplate = `<h1>{{ Data.title }}</h1>
+<p>{{ Data.body }}</p>
+`
+
+	pageFixture = `{
+  "title": "%s",
+  "body": "The content of the %s page"
+}
+`
+)
+
+// NewSite returns a Skel creating a minimal site in the output path: a config with a home page,
+// its template and layout and an example fixture of its backend response
+func NewSite(outputPath string) Skel {
+	return &siteSkel{outputPath: outputPath}
+}
+
+type siteSkel struct {
+	outputPath string
+}
+
+// Create writes the files of the site, failing if any of them already exists
+func (s *siteSkel) Create() error {
+	for _, f := range []struct {
+		name    string
+		content string
+	}{
+		{"config.json", siteConfig},
+		{"tmpl/main_layout.mustache", siteLayout},
+		{"tmpl/home.mustache", siteHome},
+		{"fixtures/home.json", siteFixture},
+	} {
+		if err := writeNewFile(filepath.Join(s.outputPath, f.name), f.content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewPage returns a Skel adding a page to the JSON config: the page entry, its template and an
+// example fixture of its backend response. The files are created in the tmpl and fixtures
+// folders next to the config. The page gets the layout only if the config declares it
+func NewPage(cfgPath, name, layout string) Skel {
+	return &pageSkel{cfgPath: cfgPath, name: name, layout: layout}
+}
+
+type pageSkel struct {
+	cfgPath string
+	name    string
+	layout  string
+}
+
+// Create adds the page to the config and writes its files, failing if the page is already
+// declared or any of its files already exists
+func (p *pageSkel) Create() (err error) {
+	if !pageNameRegexp.MatchString(p.name) {
+		return fmt.Errorf("invalid page name %q: use lowercase letters, digits, - and _", p.name)
+	}
+	data, err := ioutil.ReadFile(p.cfgPath)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	cfg := map[string]interface{}{}
+	if err := decoder.Decode(&cfg); err != nil {
+		return fmt.Errorf("decoding %s: only the JSON configs can be updated: %s", p.cfgPath, err.Error())
+	}
+
+	pagesKey := configKey(cfg, "pages")
+	pages, _ := cfg[pagesKey].([]interface{})
+	for _, page := range pages {
+		if m, ok := page.(map[string]interface{}); ok && m[configKey(m, "name")] == p.name {
+			return fmt.Errorf("the page %s is already declared", p.name)
+		}
+	}
+	templatesKey := configKey(cfg, "templates")
+	templates, _ := cfg[templatesKey].(map[string]interface{})
+	if templates == nil {
+		templates = map[string]interface{}{}
+	}
+	if _, ok := templates[p.name]; ok {
+		return fmt.Errorf("the template %s is already declared", p.name)
+	}
+
+	tmplPath := "tmpl/" + p.name + ".mustache"
+	page := map[string]interface{}{
+		"name":              p.name,
+		"URLPattern":        "/" + p.name,
+		"BackendURLPattern": "http://localhost:8000/" + p.name,
+		"Template":          p.name,
+		"CacheTTL":          "3600s",
+	}
+	if layouts, ok := cfg[configKey(cfg, "layouts")].(map[string]interface{}); ok {
+		if _, ok := layouts[p.layout]; ok {
+			page["Layout"] = p.layout
+		}
+	}
+	templates[p.name] = tmplPath
+	cfg[templatesKey] = templates
+	cfg[pagesKey] = append(pages, page)
+
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(cfg); err != nil {
+		return err
+	}
+
+	// the files already created are removed if the page can not be completed, so a failed
+	// attempt can be repeated
+	created := []string{}
+	defer func() {
+		if err != nil {
+			for _, name := range created {
+				os.Remove(name)
+			}
+		}
+	}()
+	dir := filepath.Dir(p.cfgPath)
+	fixture := fmt.Sprintf(pageFixture, strings.Title(p.name), p.name)
+	for _, f := range []struct {
+		name    string
+		content string
+	}{
+		{filepath.Join(dir, filepath.FromSlash(tmplPath)), pageTemplate},
+		{filepath.Join(dir, "fixtures", p.name+".json"), fixture},
+	} {
+		if err = writeNewFile(f.name, f.content); err != nil {
+			return err
+		}
+		created = append(created, f.name)
+	}
+	if err = ioutil.WriteFile(p.cfgPath, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Adding the page %s to %s\n", p.name, p.cfgPath)
+	return nil
+}
+
+// configKey returns the key of the config matching the received one, ignoring the case as the
+// config parser does. If there is no match, the received key is returned
+func configKey(m map[string]interface{}, key string) string {
+	for k := range m {
+		if strings.EqualFold(k, key) {
+			return k
+		}
+	}
+	return key
+}
+
+// writeNewFile creates the file and its folders, failing if it already exists
+func writeNewFile(filename, content string) error {
+	if _, err := os.Stat(filename); err == nil {
+		return fmt.Errorf("the file %s already exists", filename)
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), os.ModePerm); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Creating skeleton file: %s\n", filename)
+	return nil
+}
@@ -0,0 +1,96 @@
+package skeleton
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewSite(t *testing.T) {
+	dir := t.TempDir()
+	if err := NewSite(dir).Create(); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	for _, name := range []string{"config.json", "tmpl/main_layout.mustache", "tmpl/home.mustache", "fixtures/home.json"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s: %s", name, err.Error())
+		}
+	}
+	var cfg map[string]interface{}
+	data, _ := ioutil.ReadFile(filepath.Join(dir, "config.json"))
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+
+	if err := NewSite(dir).Create(); err == nil {
+		t.Error("expecting an error overwriting the site")
+	}
+}
+
+func TestNewPage(t *testing.T) {
+	dir := t.TempDir()
+	if err := NewSite(dir).Create(); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	cfgPath := filepath.Join(dir, "config.json")
+
+	if err := NewPage(cfgPath, "about-us", "main").Create(); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if err := NewPage(cfgPath, "contact", "unknown").Create(); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	for _, name := range []string{"tmpl/about-us.mustache", "fixtures/about-us.json", "tmpl/contact.mustache", "fixtures/contact.json"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s: %s", name, err.Error())
+		}
+	}
+
+	var cfg struct {
+		Pages     []map[string]interface{}
+		Templates map[string]string
+		Extra     map[string]interface{}
+	}
+	data, _ := ioutil.ReadFile(cfgPath)
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if len(cfg.Pages) != 3 || cfg.Templates["about-us"] != "tmpl/about-us.mustache" || cfg.Extra["lang"] != "en-US" {
+		t.Errorf("unexpected config: %s", data)
+		return
+	}
+	if cfg.Pages[1]["URLPattern"] != "/about-us" || cfg.Pages[1]["Layout"] != "main" {
+		t.Errorf("unexpected page: %v", cfg.Pages[1])
+	}
+	if _, ok := cfg.Pages[2]["Layout"]; ok {
+		t.Errorf("unexpected layout: %v", cfg.Pages[2])
+	}
+
+	for _, name := range []string{"about-us", "home", "About Us"} {
+		if err := NewPage(cfgPath, name, "main").Create(); err == nil {
+			t.Errorf("%s: expecting an error", name)
+		}
+	}
+
+	// the files of a failed page are removed and the config is not updated
+	if err := ioutil.WriteFile(filepath.Join(dir, "fixtures", "faq.json"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewPage(cfgPath, "faq", "main").Create(); err == nil {
+		t.Error("expecting an error")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "tmpl", "faq.mustache")); !os.IsNotExist(err) {
+		t.Errorf("the template of the failed page was not removed: %v", err)
+	}
+	if updated, _ := ioutil.ReadFile(cfgPath); !bytes.Equal(updated, data) {
+		t.Errorf("the config was updated: %s", updated)
+	}
+}